@@ -0,0 +1,241 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"google.golang.org/grpc/status"
+
+	napv1 "github.com/nupi-ai/nupi/api/nap/v1"
+
+	"github.com/nupi-ai/plugin-vad-local-silero/internal/config"
+	"github.com/nupi-ai/plugin-vad-local-silero/internal/server"
+)
+
+// Annotation output formats.
+const (
+	annotateJSON = "json"
+	annotateRTTM = "rttm"
+)
+
+// annotation is the JSON record written for one file.
+type annotation struct {
+	File     string              `json:"file"`
+	Engine   string              `json:"engine"`
+	AudioMs  float64             `json:"audio_ms"`
+	SpeechMs float64             `json:"speech_ms"`
+	Segments []annotationSegment `json:"segments"`
+}
+
+// annotationSegment is one speech segment, in milliseconds from the start
+// of the file.
+type annotationSegment struct {
+	StartMs    float64 `json:"start_ms"`
+	EndMs      float64 `json:"end_ms"`
+	DurationMs float64 `json:"duration_ms"`
+}
+
+// annotateOptions is the parsed annotate command line.
+type annotateOptions struct {
+	cli        cliOptions
+	format     string
+	output     string
+	configJSON string
+	files      []string
+}
+
+// runAnnotate segments local WAV files with the configured engine, without
+// gRPC, and writes the speech segments as JSON lines or RTTM. Each file runs
+// through the same pipeline as DetectSpeechInBuffer, configured from the
+// adapter's flags, environment and config file. It returns the process exit
+// code: 0 when every file was annotated, 1 when any failed, 2 on a usage
+// error.
+func runAnnotate(ctx context.Context, args []string, stdout, stderr io.Writer) int {
+	opts, err := parseAnnotateArgs(args, stderr)
+	if errors.Is(err, flag.ErrHelp) {
+		return 0
+	}
+	if err != nil {
+		return 2
+	}
+
+	loader := config.Loader{Lookup: opts.cli.lookup(os.LookupEnv), Origin: opts.cli.origin(os.LookupEnv)}
+	loadResult, err := loader.Load()
+	if err != nil {
+		fmt.Fprintf(stderr, "annotate: configuration error: %v\n", err)
+		return 1
+	}
+	cfg := loadResult.Config
+	for _, warn := range loadResult.Warnings {
+		fmt.Fprintf(stderr, "annotate: warning: %s\n", warn)
+	}
+	if _, err := installLocalModel(cfg); err != nil {
+		fmt.Fprintf(stderr, "annotate: %v\n", err)
+		return 1
+	}
+	engineName, newEngine, err := localEngine(cfg.Engine, cfg.HopMs)
+	if err != nil {
+		fmt.Fprintf(stderr, "annotate: engine %q is not usable: %v\n", cfg.Engine, err)
+		return 1
+	}
+	// Stub output does not depend on the audio, so it is only written when
+	// asked for by name.
+	if engineName == config.EngineStub && cfg.Engine != config.EngineStub {
+		fmt.Fprintln(stderr, "annotate: the silero engine is not compiled in; build with -tags silero, or set the engine explicitly")
+		return 1
+	}
+
+	out := stdout
+	if opts.output != "" {
+		f, err := os.Create(opts.output)
+		if err != nil {
+			fmt.Fprintf(stderr, "annotate: %v\n", err)
+			return 1
+		}
+		defer f.Close()
+		out = f
+	}
+
+	logger := slog.New(slog.NewTextHandler(stderr, &slog.HandlerOptions{Level: slog.LevelWarn}))
+	srv := server.New(cfg, logger, newEngine, server.WithEngineName(engineName))
+	failed := 0
+	for _, path := range opts.files {
+		res, err := annotateFile(ctx, srv, path, opts.configJSON)
+		if err != nil {
+			fmt.Fprintf(stderr, "annotate: %s: %v\n", path, err)
+			failed++
+			continue
+		}
+		if opts.format == annotateRTTM {
+			err = writeRTTM(out, path, res)
+		} else {
+			err = writeAnnotation(out, path, engineName, res)
+		}
+		if err != nil {
+			fmt.Fprintf(stderr, "annotate: %v\n", err)
+			return 1
+		}
+	}
+	if failed > 0 {
+		fmt.Fprintf(stderr, "annotate: %d of %d files failed\n", failed, len(opts.files))
+		return 1
+	}
+	return 0
+}
+
+func parseAnnotateArgs(args []string, stderr io.Writer) (annotateOptions, error) {
+	opts := annotateOptions{cli: cliOptions{values: map[string]string{}}}
+	fs := flag.NewFlagSet("annotate", flag.ContinueOnError)
+	fs.SetOutput(stderr)
+	fs.Usage = func() {
+		fmt.Fprintf(stderr, `Usage: %s annotate [flags] file.wav...
+
+Segments WAV files with the configured engine and writes the speech segments
+as JSON lines (one object per file) or RTTM. The VAD settings are read from
+the flags, the environment and the config file exactly as the adapter reads
+them.
+
+Flags:
+`, adapterName)
+		fs.PrintDefaults()
+	}
+	fs.StringVar(&opts.format, "format", annotateJSON, "output `format`: json or rttm")
+	fs.StringVar(&opts.output, "output", "", "write to this `file` instead of stdout")
+	fs.StringVar(&opts.configJSON, "config-json", "", "per-stream config_json `object` applied to every file, e.g. '{\"preset\": \"dictation\"}'")
+	addConfigFlags(fs, opts.cli.values)
+	if err := fs.Parse(args); err != nil {
+		return annotateOptions{}, err
+	}
+	opts.files = fs.Args()
+
+	fail := func(format string, a ...any) (annotateOptions, error) {
+		err := fmt.Errorf(format, a...)
+		fmt.Fprintf(stderr, "annotate: %v\n", err)
+		fs.Usage()
+		return annotateOptions{}, err
+	}
+	switch {
+	case len(opts.files) == 0:
+		return fail("no input files")
+	case opts.format != annotateJSON && opts.format != annotateRTTM:
+		return fail("--format must be json or rttm, got %q", opts.format)
+	case opts.configJSON != "" && !json.Valid([]byte(opts.configJSON)):
+		return fail("--config-json is not valid JSON")
+	}
+	return opts, nil
+}
+
+// annotateFile runs the WAV file at path through srv, as a buffer with
+// per-stream configuration configJSON.
+func annotateFile(ctx context.Context, srv *server.Server, path, configJSON string) (server.BufferResult, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return server.BufferResult{}, err
+	}
+	res, err := srv.DetectSpeechInBuffer(ctx, &napv1.DetectSpeechRequest{
+		SessionId:  "annotate",
+		StreamId:   fileID(path),
+		Format:     &napv1.AudioFormat{Encoding: server.EncodingWAV},
+		PcmData:    data,
+		ConfigJson: configJSON,
+	})
+	if err != nil {
+		// Errors are gRPC statuses; the code means nothing here.
+		return server.BufferResult{}, errors.New(status.Convert(err).Message())
+	}
+	return res, nil
+}
+
+func writeAnnotation(out io.Writer, path, engineName string, res server.BufferResult) error {
+	a := annotation{
+		File:     path,
+		Engine:   engineName,
+		AudioMs:  ms(res.AudioDuration),
+		Segments: make([]annotationSegment, 0, len(res.Segments)),
+	}
+	var speech time.Duration
+	for _, sg := range res.Segments {
+		speech += sg.End - sg.Start
+		a.Segments = append(a.Segments, annotationSegment{
+			StartMs:    ms(sg.Start),
+			EndMs:      ms(sg.End),
+			DurationMs: ms(sg.End - sg.Start),
+		})
+	}
+	a.SpeechMs = ms(speech)
+	return json.NewEncoder(out).Encode(a)
+}
+
+// writeRTTM writes one SPEAKER line per segment, with the speaker "speech"
+// on channel 1.
+func writeRTTM(out io.Writer, path string, res server.BufferResult) error {
+	id := fileID(path)
+	for _, sg := range res.Segments {
+		if _, err := fmt.Fprintf(out, "SPEAKER %s 1 %.3f %.3f <NA> <NA> speech <NA> <NA>\n",
+			id, sg.Start.Seconds(), (sg.End - sg.Start).Seconds()); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// fileID is the RTTM file ID of path: its base name without the extension,
+// with whitespace replaced as RTTM fields are whitespace-separated.
+func fileID(path string) string {
+	base := filepath.Base(path)
+	return strings.Join(strings.Fields(strings.TrimSuffix(base, filepath.Ext(base))), "_")
+}
+
+// ms renders d in fractional milliseconds.
+func ms(d time.Duration) float64 {
+	return float64(d) / float64(time.Millisecond)
+}
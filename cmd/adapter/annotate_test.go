@@ -0,0 +1,126 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// writeDemoWAV writes the embedded demo sample to dir under name.
+func writeDemoWAV(t *testing.T, dir, name string) string {
+	t.Helper()
+	path := filepath.Join(dir, name)
+	if err := os.WriteFile(path, demoWAV, 0o600); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestRunAnnotateJSON(t *testing.T) {
+	dir := t.TempDir()
+	first := writeDemoWAV(t, dir, "first.wav")
+	second := writeDemoWAV(t, dir, "second.wav")
+
+	var stdout, stderr bytes.Buffer
+	code := runAnnotate(context.Background(), []string{"--engine", "energy", first, second}, &stdout, &stderr)
+	if code != 0 {
+		t.Fatalf("exit code %d\n%s", code, stderr.String())
+	}
+	lines := strings.Split(strings.TrimSpace(stdout.String()), "\n")
+	if len(lines) != 2 {
+		t.Fatalf("want one line per file, got:\n%s", stdout.String())
+	}
+	var a annotation
+	if err := json.Unmarshal([]byte(lines[1]), &a); err != nil {
+		t.Fatal(err)
+	}
+	// The sample holds two utterances.
+	if a.File != second || a.Engine != "energy" || len(a.Segments) != 2 || a.AudioMs < 3000 {
+		t.Fatalf("annotation %+v, want two segments of %s", a, second)
+	}
+	var speech float64
+	for _, sg := range a.Segments {
+		if sg.EndMs <= sg.StartMs || sg.DurationMs != sg.EndMs-sg.StartMs {
+			t.Errorf("segment %+v", sg)
+		}
+		speech += sg.DurationMs
+	}
+	if a.SpeechMs != speech {
+		t.Errorf("speech_ms %v, want the segments' %v", a.SpeechMs, speech)
+	}
+}
+
+func TestRunAnnotateRTTM(t *testing.T) {
+	dir := t.TempDir()
+	path := writeDemoWAV(t, dir, "call one.wav")
+	output := filepath.Join(dir, "out.rttm")
+
+	var stdout, stderr bytes.Buffer
+	code := runAnnotate(context.Background(), []string{"--engine", "energy", "--format", "rttm", "--output", output, path}, &stdout, &stderr)
+	if code != 0 || stdout.Len() != 0 {
+		t.Fatalf("exit code %d, stdout %q\n%s", code, stdout.String(), stderr.String())
+	}
+	data, err := os.ReadFile(output)
+	if err != nil {
+		t.Fatal(err)
+	}
+	lines := strings.Split(strings.TrimSpace(string(data)), "\n")
+	if len(lines) != 2 {
+		t.Fatalf("want two segments, got:\n%s", data)
+	}
+	for _, line := range lines {
+		f := strings.Fields(line)
+		if len(f) != 10 || f[0] != "SPEAKER" || f[1] != "call_one" || f[2] != "1" || f[7] != "speech" {
+			t.Errorf("line %q is not an RTTM speech segment of call_one", line)
+		}
+	}
+}
+
+func TestRunAnnotateConfigJSON(t *testing.T) {
+	path := writeDemoWAV(t, t.TempDir(), "sample.wav")
+	var stdout, stderr bytes.Buffer
+	// A threshold no frame reaches leaves the file without segments.
+	code := runAnnotate(context.Background(), []string{"--engine", "energy", "--config-json", `{"threshold": 1}`, path}, &stdout, &stderr)
+	if code != 0 {
+		t.Fatalf("exit code %d\n%s", code, stderr.String())
+	}
+	if !strings.Contains(stdout.String(), `"segments":[]`) {
+		t.Errorf("want no segments, got %s", stdout.String())
+	}
+}
+
+func TestRunAnnotateFailures(t *testing.T) {
+	dir := t.TempDir()
+	good := writeDemoWAV(t, dir, "good.wav")
+	notWAV := filepath.Join(dir, "notes.wav")
+	if err := os.WriteFile(notWAV, []byte("not audio"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	var stdout, stderr bytes.Buffer
+	code := runAnnotate(context.Background(), []string{"--engine", "energy", notWAV, good, filepath.Join(dir, "missing.wav")}, &stdout, &stderr)
+	if code != 1 {
+		t.Errorf("exit code %d, want 1", code)
+	}
+	if n := strings.Count(stdout.String(), "\n"); n != 1 {
+		t.Errorf("want the good file annotated, got:\n%s", stdout.String())
+	}
+	if !strings.Contains(stderr.String(), "notes.wav") || !strings.Contains(stderr.String(), "2 of 3 files failed") {
+		t.Errorf("stderr:\n%s", stderr.String())
+	}
+
+	for _, args := range [][]string{
+		{},
+		{"--format", "csv", good},
+		{"--config-json", "{", good},
+	} {
+		stderr.Reset()
+		if code := runAnnotate(context.Background(), args, &stdout, &stderr); code != 2 {
+			t.Errorf("%q: exit code %d, want 2\n%s", args, code, stderr.String())
+		}
+	}
+}
@@ -0,0 +1,128 @@
+//go:build ignore
+
+// gen_demo writes demo.wav, the sample played by "vad-local-silero demo".
+// The audio is synthesized (no recording licence to track): two voiced
+// utterances from a glottal pulse train shaped by vowel formants, separated
+// by low-level background noise. After regenerating, run
+// TestDemoSampleSilero (make test-silero) to check the model still hears it
+// as speech.
+//
+// Regenerate with: go run ./cmd/adapter/assets/gen_demo.go
+package main
+
+import (
+	"encoding/binary"
+	"log"
+	"math"
+	"math/rand/v2"
+	"os"
+	"path/filepath"
+	"runtime"
+)
+
+const sampleRate = 16000
+
+type segment struct {
+	seconds  float64
+	formants []float64 // Hz; empty for background noise
+	pitch    [2]float64
+}
+
+var script = []segment{
+	{seconds: 0.6},
+	{seconds: 1.3, formants: []float64{730, 1090, 2440}, pitch: [2]float64{140, 110}}, // "ah"
+	{seconds: 0.9},
+	{seconds: 1.0, formants: []float64{300, 870, 2240}, pitch: [2]float64{125, 150}}, // "oo"
+	{seconds: 0.8},
+}
+
+func main() {
+	rng := rand.New(rand.NewPCG(1, 2))
+	var samples []float64
+	for _, seg := range script {
+		n := int(seg.seconds * sampleRate)
+		if len(seg.formants) == 0 {
+			for i := 0; i < n; i++ {
+				samples = append(samples, 0.002*rng.NormFloat64())
+			}
+			continue
+		}
+		samples = append(samples, voiced(seg, n, rng)...)
+	}
+
+	_, file, _, _ := runtime.Caller(0)
+	out := filepath.Join(filepath.Dir(file), "demo.wav")
+	if err := os.WriteFile(out, wav(samples), 0o644); err != nil {
+		log.Fatal(err)
+	}
+	log.Printf("wrote %s (%.1f s)", out, float64(len(samples))/sampleRate)
+}
+
+// voiced synthesizes one utterance: a pulse train with a pitch glide,
+// filtered through two-pole resonators at the formant frequencies, with a
+// syllable-rate amplitude envelope.
+func voiced(seg segment, n int, rng *rand.Rand) []float64 {
+	src := make([]float64, n)
+	phase := 0.0
+	for i := range src {
+		t := float64(i) / float64(n)
+		f0 := seg.pitch[0] + (seg.pitch[1]-seg.pitch[0])*t
+		phase += f0 / sampleRate
+		if phase >= 1 {
+			phase--
+			src[i] = 1
+		}
+		src[i] += 0.02 * rng.NormFloat64() // breathiness
+	}
+
+	out := make([]float64, n)
+	for k, f := range seg.formants {
+		bw := 80.0 + 40*float64(k)
+		r := math.Exp(-math.Pi * bw / sampleRate)
+		a1 := 2 * r * math.Cos(2*math.Pi*f/sampleRate)
+		a2 := -r * r
+		gain := 1.0 / float64(k+1)
+		var y1, y2 float64
+		for i, x := range src {
+			y := x + a1*y1 + a2*y2
+			y2, y1 = y1, y
+			out[i] += gain * y
+		}
+	}
+
+	var peak float64
+	for _, v := range out {
+		peak = max(peak, math.Abs(v))
+	}
+	for i := range out {
+		t := float64(i) / sampleRate
+		// 4 Hz syllable modulation inside a 50 ms fade-in/out.
+		env := 0.6 + 0.4*math.Sin(2*math.Pi*4*t)
+		fade := math.Min(1, math.Min(t, float64(n-i)/sampleRate)/0.05)
+		out[i] = 0.5 * out[i] / peak * env * fade
+	}
+	return out
+}
+
+// wav encodes mono 16-bit PCM as a canonical 44-byte-header WAV file.
+func wav(samples []float64) []byte {
+	data := make([]byte, 2*len(samples))
+	for i, v := range samples {
+		v = math.Max(-1, math.Min(1, v))
+		binary.LittleEndian.PutUint16(data[2*i:], uint16(int16(math.Round(v*32767))))
+	}
+	h := make([]byte, 44)
+	copy(h[0:], "RIFF")
+	binary.LittleEndian.PutUint32(h[4:], uint32(36+len(data)))
+	copy(h[8:], "WAVEfmt ")
+	binary.LittleEndian.PutUint32(h[16:], 16)
+	binary.LittleEndian.PutUint16(h[20:], 1) // PCM
+	binary.LittleEndian.PutUint16(h[22:], 1) // mono
+	binary.LittleEndian.PutUint32(h[24:], sampleRate)
+	binary.LittleEndian.PutUint32(h[28:], sampleRate*2)
+	binary.LittleEndian.PutUint16(h[32:], 2)
+	binary.LittleEndian.PutUint16(h[34:], 16)
+	copy(h[36:], "data")
+	binary.LittleEndian.PutUint32(h[40:], uint32(len(data)))
+	return append(h, data...)
+}
@@ -0,0 +1,85 @@
+package main
+
+import (
+	"context"
+	"crypto/subtle"
+	"log/slog"
+	"strings"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	healthgrpc "google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+
+	"github.com/nupi-ai/plugin-vad-local-silero/internal/metrics"
+)
+
+// tokenAuth rejects RPCs that do not carry the configured token in their
+// authorization metadata. Health checks stay open so probes and load
+// balancers need no secret.
+type tokenAuth struct {
+	token    []byte
+	failures *metrics.Counter
+	log      *slog.Logger
+}
+
+func newTokenAuth(token string, reg *metrics.Registry, logger *slog.Logger) *tokenAuth {
+	return &tokenAuth{
+		token:    []byte(token),
+		failures: reg.Counter("vad_auth_failures_total"),
+		log:      logger,
+	}
+}
+
+// healthMethodPrefix is the full method prefix of the gRPC health service.
+var healthMethodPrefix = "/" + healthgrpc.Health_ServiceDesc.ServiceName + "/"
+
+// check returns an Unauthenticated error unless ctx carries the token or
+// fullMethod is a health check.
+func (a *tokenAuth) check(ctx context.Context, fullMethod string) error {
+	if strings.HasPrefix(fullMethod, healthMethodPrefix) {
+		return nil
+	}
+	md, _ := metadata.FromIncomingContext(ctx)
+	for _, value := range md.Get("authorization") {
+		// The scheme is case-insensitive (RFC 9110); a bare secret is
+		// accepted too.
+		if len(value) > len("bearer ") && strings.EqualFold(value[:len("bearer ")], "bearer ") {
+			value = value[len("bearer "):]
+		}
+		if subtle.ConstantTimeCompare([]byte(strings.TrimSpace(value)), a.token) == 1 {
+			return nil
+		}
+	}
+	a.failures.Inc()
+	var remote string
+	if p, ok := peer.FromContext(ctx); ok && p.Addr != nil {
+		remote = p.Addr.String()
+	}
+	a.log.Warn("rejected unauthenticated call", "method", fullMethod, "remote_addr", remote, "token_sent", len(md.Get("authorization")) > 0)
+	return status.Error(codes.Unauthenticated, "missing or invalid authorization token")
+}
+
+func (a *tokenAuth) unary(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+	if err := a.check(ctx, info.FullMethod); err != nil {
+		return nil, err
+	}
+	return handler(ctx, req)
+}
+
+func (a *tokenAuth) stream(srv any, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+	if err := a.check(ss.Context(), info.FullMethod); err != nil {
+		return err
+	}
+	return handler(srv, ss)
+}
+
+// serverOptions returns the interceptors enforcing the token.
+func (a *tokenAuth) serverOptions() []grpc.ServerOption {
+	return []grpc.ServerOption{
+		grpc.ChainUnaryInterceptor(a.unary),
+		grpc.ChainStreamInterceptor(a.stream),
+	}
+}
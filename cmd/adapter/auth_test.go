@@ -0,0 +1,83 @@
+package main
+
+import (
+	"context"
+	"io"
+	"log/slog"
+	"net"
+	"testing"
+	"time"
+
+	napv1 "github.com/nupi-ai/nupi/api/nap/v1"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/health"
+	healthgrpc "google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+
+	"github.com/nupi-ai/plugin-vad-local-silero/internal/metrics"
+)
+
+func TestTokenAuth(t *testing.T) {
+	reg := metrics.NewRegistry()
+	auth := newTokenAuth("s3cret", reg, slog.New(slog.NewTextHandler(io.Discard, nil)))
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	srv := grpc.NewServer(auth.serverOptions()...)
+	healthgrpc.RegisterHealthServer(srv, health.NewServer())
+	// The uninitialized service answers Unavailable once a call gets through.
+	napv1.RegisterVoiceActivityDetectionServiceServer(srv, &lazyVADServer{startedAt: time.Now()})
+	go srv.Serve(lis)
+	defer srv.Stop()
+
+	conn, err := grpc.NewClient(lis.Addr().String(), grpc.WithTransportCredentials(insecure.NewCredentials()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	if _, err := healthgrpc.NewHealthClient(conn).Check(ctx, &healthgrpc.HealthCheckRequest{}); err != nil {
+		t.Errorf("health check without token: %v", err)
+	}
+
+	client := napv1.NewVoiceActivityDetectionServiceClient(conn)
+	detect := func(authorization ...string) codes.Code {
+		callCtx := ctx
+		for _, v := range authorization {
+			callCtx = metadata.AppendToOutgoingContext(callCtx, "authorization", v)
+		}
+		stream, err := client.DetectSpeech(callCtx)
+		if err != nil {
+			t.Fatal(err)
+		}
+		_, err = stream.Recv()
+		return status.Code(err)
+	}
+	tests := []struct {
+		name          string
+		authorization []string
+		want          codes.Code
+	}{
+		{"no token", nil, codes.Unauthenticated},
+		{"wrong token", []string{"Bearer nope"}, codes.Unauthenticated},
+		{"bearer", []string{"Bearer s3cret"}, codes.Unavailable},
+		{"lowercase scheme", []string{"bearer s3cret"}, codes.Unavailable},
+		{"bare secret", []string{"s3cret"}, codes.Unavailable},
+		{"second value", []string{"Basic x", "Bearer s3cret"}, codes.Unavailable},
+		{"prefix of token", []string{"Bearer s3cre"}, codes.Unauthenticated},
+	}
+	for _, tt := range tests {
+		if got := detect(tt.authorization...); got != tt.want {
+			t.Errorf("%s: code %v, want %v", tt.name, got, tt.want)
+		}
+	}
+	if got := reg.Counter("vad_auth_failures_total").Value(); got != 3 {
+		t.Errorf("vad_auth_failures_total = %d, want 3", got)
+	}
+}
@@ -0,0 +1,44 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/nupi-ai/plugin-vad-local-silero/internal/config"
+)
+
+// openBillingOutput opens the configured billing_output. Closing the result
+// leaves stdout and stderr open. Files are opened for appending so restarts
+// never truncate earlier records.
+func openBillingOutput(cfg config.Config) (io.WriteCloser, error) {
+	switch cfg.BillingOutput {
+	case config.BillingStdout:
+		return nopWriteCloser{os.Stdout}, nil
+	case config.BillingStderr:
+		return nopWriteCloser{os.Stderr}, nil
+	}
+	if fd, ok := cfg.BillingFD(); ok {
+		f := os.NewFile(uintptr(fd), fmt.Sprintf("billing-fd-%d", fd))
+		if f == nil {
+			return nil, fmt.Errorf("billing_output: invalid descriptor %d", fd)
+		}
+		if _, err := f.Stat(); err != nil {
+			return nil, fmt.Errorf("billing_output: descriptor %d is not open: %w", fd, err)
+		}
+		return f, nil
+	}
+	path, _ := cfg.BillingFile()
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0o644)
+	if err != nil {
+		return nil, fmt.Errorf("billing_output: %w", err)
+	}
+	return f, nil
+}
+
+// nopWriteCloser keeps the process's standard streams open on Close.
+type nopWriteCloser struct {
+	io.Writer
+}
+
+func (nopWriteCloser) Close() error { return nil }
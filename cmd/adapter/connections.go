@@ -0,0 +1,54 @@
+package main
+
+import (
+	"fmt"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/keepalive"
+
+	"github.com/nupi-ai/plugin-vad-local-silero/internal/config"
+	"github.com/nupi-ai/plugin-vad-local-silero/internal/server"
+)
+
+// recvMsgLimit returns the largest message a client may send. It is
+// derived from max_chunk_bytes; grpc.max_recv_msg_bytes may only raise it,
+// so a full-size chunk is always accepted.
+func recvMsgLimit(cfg config.Config) (int, error) {
+	limit, err := server.MaxRecvMsgSize(cfg.EffectiveMaxChunkBytes())
+	if err != nil {
+		return 0, err
+	}
+	if g := cfg.GRPC.MaxRecvMsgBytes; g != 0 {
+		if g < limit {
+			return 0, fmt.Errorf("grpc.max_recv_msg_bytes (%d) is below the %d bytes a max_chunk_bytes chunk needs", g, limit)
+		}
+		limit = g
+	}
+	return limit, nil
+}
+
+// connectionOptions returns the gRPC server options for cfg's receive limit
+// (recvMsgLimit) and connection management (grpc.*).
+func connectionOptions(cfg config.Config) ([]grpc.ServerOption, error) {
+	recvLimit, err := recvMsgLimit(cfg)
+	if err != nil {
+		return nil, err
+	}
+	seconds := func(s int) time.Duration { return time.Duration(s) * time.Second }
+	return []grpc.ServerOption{
+		grpc.MaxRecvMsgSize(recvLimit),
+		// Zero fields keep the gRPC defaults.
+		grpc.KeepaliveParams(keepalive.ServerParameters{
+			MaxConnectionIdle:     seconds(cfg.GRPC.MaxConnectionIdleSec),
+			MaxConnectionAge:      seconds(cfg.GRPC.MaxConnectionAgeSec),
+			MaxConnectionAgeGrace: seconds(cfg.GRPC.MaxConnectionAgeGraceSec),
+			Time:                  seconds(cfg.GRPC.KeepaliveTimeSec),
+			Timeout:               seconds(cfg.GRPC.KeepaliveTimeoutSec),
+		}),
+		grpc.KeepaliveEnforcementPolicy(keepalive.EnforcementPolicy{
+			MinTime:             seconds(cfg.GRPC.KeepaliveMinTimeSec),
+			PermitWithoutStream: cfg.GRPC.KeepalivePermitWithoutStream,
+		}),
+	}, nil
+}
@@ -0,0 +1,27 @@
+package main
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/nupi-ai/plugin-vad-local-silero/internal/config"
+	"github.com/nupi-ai/plugin-vad-local-silero/internal/server"
+)
+
+func TestConnectionOptionsRecvLimit(t *testing.T) {
+	derived, err := server.MaxRecvMsgSize(config.DefaultMaxChunkBytes)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := connectionOptions(config.Config{}); err != nil {
+		t.Errorf("defaults: %v", err)
+	}
+	cfg := config.Config{GRPC: config.GRPCServer{MaxRecvMsgBytes: derived + 1}}
+	if _, err := connectionOptions(cfg); err != nil {
+		t.Errorf("raised limit: %v", err)
+	}
+	cfg.GRPC.MaxRecvMsgBytes = derived - 1
+	if _, err := connectionOptions(cfg); err == nil || !strings.Contains(err.Error(), "grpc.max_recv_msg_bytes") {
+		t.Errorf("limit below a full chunk: err = %v, want grpc.max_recv_msg_bytes error", err)
+	}
+}
@@ -0,0 +1,37 @@
+//go:build !windows
+
+package main
+
+import (
+	"context"
+	"log/slog"
+	"os"
+	"os/signal"
+	"syscall"
+)
+
+// watchDebugToggle switches the log level between debug and the configured
+// level on every SIGUSR1 until ctx is done.
+func watchDebugToggle(ctx context.Context, level *slog.LevelVar, logger *slog.Logger) {
+	configured := level.Level()
+	sig := make(chan os.Signal, 1)
+	signal.Notify(sig, syscall.SIGUSR1)
+	go func() {
+		defer signal.Stop(sig)
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-sig:
+				// Log while debug is enabled, so both switches are visible.
+				if level.Level() == slog.LevelDebug {
+					logger.Info("log level changed (SIGUSR1)", "level", configured.String())
+					level.Set(configured)
+				} else {
+					level.Set(slog.LevelDebug)
+					logger.Info("log level changed (SIGUSR1)", "level", slog.LevelDebug.String())
+				}
+			}
+		}
+	}()
+}
@@ -0,0 +1,11 @@
+//go:build windows
+
+package main
+
+import (
+	"context"
+	"log/slog"
+)
+
+// watchDebugToggle is a no-op on Windows, which has no SIGUSR1.
+func watchDebugToggle(context.Context, *slog.LevelVar, *slog.Logger) {}
@@ -0,0 +1,263 @@
+package main
+
+//go:generate go run ./assets/gen_demo.go
+
+import (
+	"context"
+	_ "embed"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net"
+	"os"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials/insecure"
+
+	napv1 "github.com/nupi-ai/nupi/api/nap/v1"
+
+	"github.com/nupi-ai/plugin-vad-local-silero/internal/audio/decode"
+	"github.com/nupi-ai/plugin-vad-local-silero/internal/config"
+	"github.com/nupi-ai/plugin-vad-local-silero/internal/engine"
+	"github.com/nupi-ai/plugin-vad-local-silero/internal/server"
+	"github.com/nupi-ai/plugin-vad-local-silero/internal/timestamp"
+)
+
+// demoWAV is a synthesized 16 kHz mono sample with two voiced utterances
+// (see assets/gen_demo.go).
+//
+//go:embed assets/demo.wav
+var demoWAV []byte
+
+// demoChunkMs is the chunk size the demo streams, matching a typical client.
+const demoChunkMs = 20
+
+// runDemo streams the embedded sample through the configured engine and the
+// real DetectSpeech pipeline and prints the resulting event timeline. It
+// returns the process exit code: 0 when the native engine detected speech,
+// 1 when the install is not healthy.
+func runDemo(out io.Writer) int {
+	loadResult, err := config.Loader{}.Load()
+	if err != nil {
+		fmt.Fprintf(out, "configuration error: %v\n", err)
+		return 1
+	}
+	cfg := loadResult.Config
+	for _, warn := range loadResult.Warnings {
+		fmt.Fprintf(out, "warning: %s\n", warn)
+	}
+
+	digest, err := installLocalModel(cfg)
+	if err != nil {
+		fmt.Fprintln(out, err)
+		return 1
+	}
+	if digest != "" {
+		fmt.Fprintf(out, "model %s %s %s (sha256 %s)\n", cfg.ModelVersion, cfg.ModelPrecision, cfg.ModelPath, digest)
+	}
+
+	engineName, newEngine, err := localEngine(cfg.Engine, cfg.HopMs)
+	if err != nil {
+		fmt.Fprintf(out, "engine %q is not usable: %v\n", cfg.Engine, err)
+		return 1
+	}
+
+	sample, err := decode.File(demoWAV)
+	if err != nil {
+		fmt.Fprintf(out, "embedded sample is corrupt: %v\n", err)
+		return 1
+	}
+	pcm, sampleRate := sample.PCM, sample.SampleRate
+
+	fmt.Fprintf(out, "Nupi Silero VAD demo — engine %s, sample %.2f s at %d Hz\n",
+		engineName, float64(len(pcm)/2)/float64(sampleRate), sampleRate)
+	fmt.Fprintf(out, "threshold %.2f, min speech %d ms, min silence %d ms\n\n",
+		cfg.Threshold, cfg.MinSpeechDurationMs, cfg.MinSilenceDurationMs)
+
+	// Only warnings and errors from the pipeline; the timeline is the output.
+	logger := slog.New(slog.NewTextHandler(os.Stderr, &slog.HandlerOptions{Level: slog.LevelWarn}))
+	segments, err := streamDemo(out, server.New(cfg, logger, newEngine), pcm, sampleRate, timestamp.Format(cfg.TimestampFormat))
+	if err != nil {
+		fmt.Fprintf(out, "\ndemo stream failed: %v\n", err)
+		return 1
+	}
+
+	fmt.Fprintln(out)
+	switch {
+	case engineName == config.EngineStub:
+		fmt.Fprintf(out, "%d speech segments from the stub engine, which ignores audio content.\n", segments)
+		if cfg.Engine != config.EngineStub {
+			fmt.Fprintln(out, "The silero engine is not compiled in; build with -tags silero for real detection.")
+			return 1
+		}
+		return 0
+	case segments == 0:
+		fmt.Fprintln(out, "No speech detected. Check the threshold and duration settings, or the model file.")
+		return 1
+	default:
+		fmt.Fprintf(out, "%d speech segments detected. The engine, model and configuration are working.\n", segments)
+		return 0
+	}
+}
+
+// installLocalModel applies cfg's model and ONNX Runtime settings for the
+// commands that run the engine in-process, after verifying the library and
+// embedded model as the adapter does. It returns the model file's digest
+// when one is loaded. The model file is checked strictly: falling
+// back to the embedded model, as the adapter does, would hide the problem
+// the demo is run to find, or label a dataset with the wrong model.
+func installLocalModel(cfg config.Config) (string, error) {
+	if err := engine.SetModelVersion(cfg.ModelVersion); err != nil {
+		return "", fmt.Errorf("configuration error: %w", err)
+	}
+	if err := engine.SetModelPrecision(cfg.ModelPrecision); err != nil {
+		return "", fmt.Errorf("configuration error: %w", err)
+	}
+	if err := engine.SetSessionOptions(cfg.ORT.SessionOptions()); err != nil {
+		return "", fmt.Errorf("configuration error: %w", err)
+	}
+	if _, err := verifyIntegrity(cfg); err != nil {
+		return "", fmt.Errorf("integrity check failed: %w", err)
+	}
+	if cfg.ModelPath == "" || !engine.NativeAvailable() {
+		return "", nil
+	}
+	data, digest, err := engine.LoadModelFile(cfg.ModelPath, cfg.ModelSHA256)
+	if err != nil {
+		return "", fmt.Errorf("model file is not usable: %w", err)
+	}
+	engine.SetModel(data)
+	return digest, nil
+}
+
+// localEngine resolves the configured engine for the commands that run it
+// in-process. Unlike the adapter, "auto" falls back to the stub without
+// NUPI_DEV_MODE so the demo can still show the pipeline; runDemo then
+// reports the install as unhealthy and runAnnotate refuses to label with it.
+func localEngine(name string, hopMs int) (string, func() engine.Engine, error) {
+	if name == config.EngineStub || (name == config.EngineAuto && !engine.NativeAvailable()) {
+		return config.EngineStub, func() engine.Engine { return engine.NewStubEngine() }, nil
+	}
+	if name == config.EngineEnergy {
+		return config.EngineEnergy, func() engine.Engine { return engine.NewEnergyEngine() }, nil
+	}
+	if factory, ok := engine.Lookup(name); ok {
+		probe, err := factory(hopMs)
+		if err != nil {
+			return "", nil, err
+		}
+		probe.Close()
+		return name, func() engine.Engine {
+			eng, err := factory(hopMs)
+			if err != nil {
+				return nil
+			}
+			return eng
+		}, nil
+	}
+	probe, err := engine.NewNativeEngine(hopMs)
+	if err != nil {
+		return "", nil, err
+	}
+	probe.Close()
+	return config.EngineSilero, func() engine.Engine {
+		eng, err := engine.NewNativeEngine(hopMs)
+		if err != nil {
+			return nil
+		}
+		return eng
+	}, nil
+}
+
+// streamDemo serves srv on a loopback listener, streams pcm to it in
+// demoChunkMs chunks and prints each boundary event as it arrives, stamped
+// in tsFormat (seconds into the sample when empty). It returns the number of
+// speech segments.
+func streamDemo(out io.Writer, srv *server.Server, pcm []byte, sampleRate uint32, tsFormat timestamp.Format) (int, error) {
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		return 0, err
+	}
+	grpcServer := grpc.NewServer()
+	napv1.RegisterVoiceActivityDetectionServiceServer(grpcServer, srv)
+	go grpcServer.Serve(lis)
+	defer grpcServer.Stop()
+
+	conn, err := grpc.NewClient(lis.Addr().String(), grpc.WithTransportCredentials(insecure.NewCredentials()))
+	if err != nil {
+		return 0, err
+	}
+	defer conn.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	stream, err := napv1.NewVoiceActivityDetectionServiceClient(conn).DetectSpeech(ctx)
+	if err != nil {
+		return 0, err
+	}
+
+	sendErr := make(chan error, 1)
+	go func() {
+		chunk := int(sampleRate) * demoChunkMs / 1000 * 2
+		format := &napv1.AudioFormat{Encoding: "pcm_s16le", SampleRate: sampleRate, Channels: 1, BitDepth: 16}
+		for off := 0; off < len(pcm); off += chunk {
+			if err := stream.Send(&napv1.DetectSpeechRequest{
+				SessionId: "demo",
+				StreamId:  "demo",
+				Format:    format,
+				PcmData:   pcm[off:min(off+chunk, len(pcm))],
+			}); err != nil {
+				sendErr <- err
+				return
+			}
+		}
+		sendErr <- stream.CloseSend()
+	}()
+
+	var (
+		segments     int
+		ongoing      int
+		segmentStart time.Duration
+		epoch        time.Time
+	)
+	for {
+		evt, err := stream.Recv()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return segments, err
+		}
+		if epoch.IsZero() {
+			// The audio epoch header arrives with the first event.
+			md, err := stream.Header()
+			if err != nil {
+				return segments, err
+			}
+			var ok bool
+			if epoch, ok = server.ParseAudioEpoch(md); !ok {
+				return segments, fmt.Errorf("response header has no %s", server.AudioEpochHeader)
+			}
+		}
+		at := server.AudioOffset(evt, epoch)
+		stamp := fmt.Sprintf("%6.2fs", at.Seconds())
+		if tsFormat != "" {
+			stamp = tsFormat.Render(evt.GetTimestamp().AsTime(), epoch)
+		}
+		switch evt.GetType() {
+		case napv1.SpeechEventType_SPEECH_EVENT_TYPE_START:
+			segments++
+			ongoing = 0
+			segmentStart = at
+			fmt.Fprintf(out, "  %s  ▶ speech start  (p=%.2f)\n", stamp, evt.GetConfidence())
+		case napv1.SpeechEventType_SPEECH_EVENT_TYPE_ONGOING:
+			ongoing++
+		case napv1.SpeechEventType_SPEECH_EVENT_TYPE_END:
+			fmt.Fprintf(out, "  %s  ■ speech end    (p=%.2f)  %.2f s, %d ongoing events\n",
+				stamp, evt.GetConfidence(), (at - segmentStart).Seconds(), ongoing)
+		}
+	}
+	return segments, <-sendErr
+}
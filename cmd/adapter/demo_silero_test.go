@@ -0,0 +1,64 @@
+//go:build silero
+
+package main
+
+import (
+	"bytes"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"testing"
+
+	"github.com/nupi-ai/plugin-vad-local-silero/internal/config"
+	"github.com/nupi-ai/plugin-vad-local-silero/internal/server"
+	"github.com/nupi-ai/plugin-vad-local-silero/internal/timestamp"
+)
+
+// demoBoundary matches a START or END line of a timeline stamped in
+// timestamp.OffsetMillis.
+var demoBoundary = regexp.MustCompile(`(?m)^\s+(\d+)\s+(▶ speech start|■ speech end)`)
+
+// TestDemoSampleSilero runs the demo pipeline with the native engine on the
+// embedded sample, which is synthesized rather than recorded: if the model
+// stops hearing it as speech, "demo" would report a healthy install as
+// broken.
+func TestDemoSampleSilero(t *testing.T) {
+	// The ORT library is resolved from the project root in dev mode.
+	t.Chdir(filepath.Join("..", ".."))
+	t.Setenv("NUPI_DEV_MODE", "1")
+	name, newEngine, err := localEngine(config.EngineSilero, 0)
+	if err != nil {
+		t.Skipf("native engine unavailable — run 'make download-ort': %v", err)
+	}
+	if name != config.EngineSilero {
+		t.Fatalf("engine = %s, want silero", name)
+	}
+
+	pcm, rate := demoSample(t)
+	cfg := config.Config{
+		Threshold:            0.5,
+		MinSpeechDurationMs:  250,
+		MinSilenceDurationMs: 300,
+	}
+	var out bytes.Buffer
+	segments, err := streamDemo(&out, server.New(cfg, nil, newEngine), pcm, rate, timestamp.OffsetMillis)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if segments == 0 {
+		t.Fatalf("no speech detected in the demo sample\n%s", out.String())
+	}
+
+	// The first utterance ("ah") spans 0.6-1.9 s: START is confirmed
+	// min_speech_duration_ms after onset and END min_silence_duration_ms
+	// after it fades.
+	m := demoBoundary.FindAllStringSubmatch(out.String(), 2)
+	if len(m) < 2 || m[0][2] != "▶ speech start" || m[1][2] != "■ speech end" {
+		t.Fatalf("timeline lacks the first segment's boundaries\n%s", out.String())
+	}
+	start, _ := strconv.Atoi(m[0][1])
+	end, _ := strconv.Atoi(m[1][1])
+	if start < 500 || start > 1500 || end < 1700 || end > 2700 {
+		t.Errorf("first segment %d-%d ms, want about 850-2200 ms\n%s", start, end, out.String())
+	}
+}
@@ -0,0 +1,76 @@
+package main
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/nupi-ai/plugin-vad-local-silero/internal/audio/decode"
+	"github.com/nupi-ai/plugin-vad-local-silero/internal/config"
+	"github.com/nupi-ai/plugin-vad-local-silero/internal/engine"
+	"github.com/nupi-ai/plugin-vad-local-silero/internal/server"
+	"github.com/nupi-ai/plugin-vad-local-silero/internal/timestamp"
+)
+
+// demoSample decodes the embedded demo sample.
+func demoSample(t *testing.T) ([]byte, uint32) {
+	t.Helper()
+	sample, err := decode.File(demoWAV)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return sample.PCM, sample.SampleRate
+}
+
+func TestEmbeddedDemoWAV(t *testing.T) {
+	pcm, rate := demoSample(t)
+	if rate != engine.ExpectedSampleRate {
+		t.Errorf("sample rate = %d, want %d", rate, engine.ExpectedSampleRate)
+	}
+	if secs := float64(len(pcm)/2) / float64(rate); secs < 3 || secs > 10 {
+		t.Errorf("sample is %.1f s, want a few seconds", secs)
+	}
+}
+
+func TestStreamDemoStub(t *testing.T) {
+	pcm, rate := demoSample(t)
+	cfg := config.Config{
+		Threshold:            0.5,
+		MinSpeechDurationMs:  250,
+		MinSilenceDurationMs: 300,
+	}
+	srv := server.New(cfg, nil, func() engine.Engine { return engine.NewStubEngine() })
+
+	var out bytes.Buffer
+	segments, err := streamDemo(&out, srv, pcm, rate, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	// 4.6 s of stub output toggles to speech twice (at 1 s and 3 s).
+	if segments != 2 {
+		t.Errorf("segments = %d, want 2\n%s", segments, out.String())
+	}
+	if got := strings.Count(out.String(), "speech end"); got != 2 {
+		t.Errorf("timeline has %d end lines, want 2\n%s", got, out.String())
+	}
+}
+
+func TestStreamDemoTimestampFormat(t *testing.T) {
+	pcm, rate := demoSample(t)
+	cfg := config.Config{
+		Threshold:            0.5,
+		MinSpeechDurationMs:  250,
+		MinSilenceDurationMs: 300,
+	}
+	srv := server.New(cfg, nil, func() engine.Engine { return engine.NewStubEngine() })
+
+	var out bytes.Buffer
+	if _, err := streamDemo(&out, srv, pcm, rate, timestamp.OffsetMillis); err != nil {
+		t.Fatal(err)
+	}
+	// The stub turns to speech at 980 ms; min_speech_duration_ms confirms
+	// it at 1220 ms.
+	if !strings.Contains(out.String(), "  1220  ▶ speech start") {
+		t.Errorf("timeline does not stamp the first START as offset 1220 ms\n%s", out.String())
+	}
+}
@@ -0,0 +1,29 @@
+package main
+
+import (
+	"fmt"
+	"log/slog"
+
+	"github.com/nupi-ai/plugin-vad-local-silero/internal/config"
+)
+
+// logEffectiveConfig logs the config the adapter runs with, as "value
+// (source)" per option: the options set by something other than their
+// default at info, so the line stays short, and every option at debug.
+// GetConfig on the admin service reports the same.
+func logEffectiveConfig(logger *slog.Logger, cfg config.Config, prov config.Provenance) {
+	var set, all []any
+	for _, o := range config.Effective(cfg, prov) {
+		value := o.Value
+		if value == nil {
+			value = "unset"
+		}
+		attr := slog.String(o.Name, fmt.Sprintf("%v (%s)", value, o.Source))
+		all = append(all, attr)
+		if o.Source != config.SourceDefault {
+			set = append(set, attr)
+		}
+	}
+	logger.Info("effective configuration", set...)
+	logger.Debug("effective configuration, all options", all...)
+}
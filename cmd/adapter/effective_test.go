@@ -0,0 +1,46 @@
+package main
+
+import (
+	"bytes"
+	"log/slog"
+	"strings"
+	"testing"
+
+	"github.com/nupi-ai/plugin-vad-local-silero/internal/config"
+)
+
+func TestLogEffectiveConfig(t *testing.T) {
+	env := map[string]string{
+		"NUPI_VAD_ENGINE":         "stub",
+		"NUPI_ADAPTER_CONFIG":     `{"threshold": 0.6}`,
+		"NUPI_ADAPTER_AUTH_TOKEN": "s3cret",
+	}
+	result, err := config.Loader{Lookup: func(key string) (string, bool) {
+		v, ok := env[key]
+		return v, ok
+	}}.Load()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var logs bytes.Buffer
+	logEffectiveConfig(slog.New(slog.NewTextHandler(&logs, nil)), result.Config, result.Provenance)
+	out := logs.String()
+	for _, want := range []string{`threshold="0.6 (NUPI_ADAPTER_CONFIG)"`, `engine="stub (NUPI_VAD_ENGINE)"`} {
+		if !strings.Contains(out, want) {
+			t.Errorf("log lacks %s:\n%s", want, out)
+		}
+	}
+	if strings.Contains(out, "s3cret") {
+		t.Errorf("log leaks the auth token:\n%s", out)
+	}
+	if strings.Contains(out, "max_streams") || strings.Contains(out, "all options") {
+		t.Errorf("info log lists default options:\n%s", out)
+	}
+
+	logs.Reset()
+	logEffectiveConfig(slog.New(slog.NewTextHandler(&logs, &slog.HandlerOptions{Level: slog.LevelDebug})), result.Config, result.Provenance)
+	if !strings.Contains(logs.String(), `max_streams="0 (default)"`) {
+		t.Errorf("debug log lacks the default options:\n%s", logs.String())
+	}
+}
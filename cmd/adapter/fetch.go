@@ -0,0 +1,359 @@
+package main
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"time"
+
+	"github.com/nupi-ai/plugin-vad-local-silero/internal/engine"
+)
+
+// sileroModelURL is the Silero model fetched by fetch-runtime, checked
+// against the v5 digest pinned in the engine; keep in sync with
+// SILERO_MODEL_URL in the Makefile.
+const sileroModelURL = "https://github.com/snakers4/silero-vad/raw/84768cefdf5a3852400e9d8237f7315d14b64a08/src/silero_vad/data/silero_vad.onnx"
+
+// ortReleaseURL is the ONNX Runtime release archive URL, formatted with the
+// version, the ORT platform name, the version again and the extension.
+const ortReleaseURL = "https://github.com/microsoft/onnxruntime/releases/download/v%s/onnxruntime-%s-%s.%s"
+
+// ortPlatforms maps <goos>-<goarch> to ONNX Runtime's platform names.
+var ortPlatforms = map[string]string{
+	"darwin-arm64":  "osx-arm64",
+	"darwin-amd64":  "osx-x86_64",
+	"linux-amd64":   "linux-x64",
+	"linux-arm64":   "linux-aarch64",
+	"windows-amd64": "win-x64",
+	"windows-arm64": "win-arm64",
+}
+
+// ortArchiveSHA256 pins the ONNX Runtime release archives by
+// "<ort platform>:<version>"; keep in sync with scripts/download-ort.sh.
+var ortArchiveSHA256 = map[string]string{
+	"osx-arm64:1.23.0":     "8182db0ebb5caa21036a3c78178f17fabb98a7916bdab454467c8f4cf34bcfdf",
+	"osx-x86_64:1.23.0":    "a8e43edcaa349cbfc51578a7fc61ea2b88793ccf077b4bc65aca58999d20cf0f",
+	"linux-x64:1.23.0":     "b6deea7f2e22c10c043019f294a0ea4d2a6c0ae52a009c34847640db75ec5580",
+	"linux-aarch64:1.23.0": "0b9f47d140411d938e47915824d8daaa424df95a88b5f1fc843172a75168f7a0",
+	"win-x64:1.23.0":       "72c23470310ec79a7d42d27fe9d257e6c98540c73fa5a1db1f67f538c6c16f2f",
+	"win-arm64:1.23.0":     "1c61071732e0b9e83c3ee4e42d8acea4acbd5ddb4dacd5e93a3ddf0ad4df590d",
+}
+
+// maxFetchBytes bounds a download: ORT archives are tens of MiB.
+const maxFetchBytes = 512 << 20
+
+// fetchOptions is the parsed fetch-runtime command line.
+type fetchOptions struct {
+	dir        string
+	goos       string
+	goarch     string
+	ortVersion string
+	ortSHA256  string // archive digest for versions without a pinned one
+	skipORT    bool
+	skipModel  bool
+	force      bool
+}
+
+// fetchSources are the download locations and digests, replaced in tests.
+type fetchSources struct {
+	ortURL      func(version, platform, ext string) string
+	ortSHA256   map[string]string
+	modelURL    string
+	modelSHA256 string
+	client      *http.Client
+}
+
+func defaultFetchSources() fetchSources {
+	return fetchSources{
+		ortURL: func(version, platform, ext string) string {
+			return fmt.Sprintf(ortReleaseURL, version, platform, version, ext)
+		},
+		ortSHA256:   ortArchiveSHA256,
+		modelURL:    sileroModelURL,
+		modelSHA256: engine.PinnedModelSHA256(engine.ModelV5, engine.PrecisionFP32),
+		client:      &http.Client{Timeout: 10 * time.Minute},
+	}
+}
+
+// runFetchRuntime downloads the ONNX Runtime library and the Silero model
+// into the layout the adapter resolves them from: lib/<os>-<arch>/ and
+// models/ under the binary's directory. Every download is verified against
+// a pinned SHA-256 before anything is written. It returns the process exit
+// code: 0 when both are installed, 1 on a failure, 2 on a usage error.
+func runFetchRuntime(ctx context.Context, args []string, stdout, stderr io.Writer) int {
+	opts, err := parseFetchArgs(args, stderr)
+	if errors.Is(err, flag.ErrHelp) {
+		return 0
+	}
+	if err != nil {
+		return 2
+	}
+	if err := fetchRuntime(ctx, opts, defaultFetchSources(), stdout); err != nil {
+		fmt.Fprintf(stderr, "fetch-runtime: %v\n", err)
+		return 1
+	}
+	return 0
+}
+
+func parseFetchArgs(args []string, stderr io.Writer) (fetchOptions, error) {
+	opts := fetchOptions{goos: runtime.GOOS, goarch: runtime.GOARCH}
+	if exe, err := os.Executable(); err == nil {
+		opts.dir = filepath.Dir(exe)
+	}
+	fs := flag.NewFlagSet("fetch-runtime", flag.ContinueOnError)
+	fs.SetOutput(stderr)
+	fs.Usage = func() {
+		fmt.Fprintf(stderr, `Usage: %s fetch-runtime [flags]
+
+Downloads the ONNX Runtime shared library for this platform into
+lib/<os>-<arch>/ and the Silero model into models/, verifying each download
+against a pinned SHA-256. Files already in place are kept.
+
+Flags:
+`, adapterName)
+		fs.PrintDefaults()
+	}
+	fs.StringVar(&opts.dir, "dir", opts.dir, "install under this `directory` (default: the binary's)")
+	fs.StringVar(&opts.ortVersion, "ort-version", engine.ORTVersion(), "ONNX Runtime `version`; the library is refused at startup unless it matches the build's")
+	fs.StringVar(&opts.ortSHA256, "ort-sha256", "", "SHA-256 of the ONNX Runtime archive, for versions without a pinned `digest`")
+	fs.BoolVar(&opts.skipORT, "skip-ort", false, "do not fetch the ONNX Runtime library")
+	fs.BoolVar(&opts.skipModel, "skip-model", false, "do not fetch the Silero model")
+	fs.BoolVar(&opts.force, "force", false, "download again even when the files exist")
+	if err := fs.Parse(args); err != nil {
+		return fetchOptions{}, err
+	}
+
+	fail := func(format string, a ...any) (fetchOptions, error) {
+		err := fmt.Errorf(format, a...)
+		fmt.Fprintf(stderr, "fetch-runtime: %v\n", err)
+		fs.Usage()
+		return fetchOptions{}, err
+	}
+	switch {
+	case fs.NArg() > 0:
+		return fail("unexpected arguments %q", fs.Args())
+	case opts.dir == "":
+		return fail("--dir is required when the binary's location is unknown")
+	case opts.skipORT && opts.skipModel:
+		return fail("nothing to fetch with both --skip-ort and --skip-model")
+	}
+	return opts, nil
+}
+
+// fetchRuntime installs what opts asks for from src, reporting progress to
+// out.
+func fetchRuntime(ctx context.Context, opts fetchOptions, src fetchSources, out io.Writer) error {
+	if !opts.skipORT {
+		if err := fetchORT(ctx, opts, src, out); err != nil {
+			return err
+		}
+	}
+	if !opts.skipModel {
+		if err := fetchModel(ctx, opts, src, out); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func fetchORT(ctx context.Context, opts fetchOptions, src fetchSources, out io.Writer) error {
+	platform := opts.goos + "-" + opts.goarch
+	ortPlatform, ok := ortPlatforms[platform]
+	if !ok {
+		return fmt.Errorf("ort: no ONNX Runtime release for %s", platform)
+	}
+	name := engine.ORTLibFilename(opts.goos, opts.ortVersion)
+	dest := filepath.Join(opts.dir, "lib", platform, name)
+	if !opts.force && fileExists(dest) {
+		fmt.Fprintf(out, "ort: %s already installed\n", dest)
+		return nil
+	}
+	digest := opts.ortSHA256
+	if digest == "" {
+		digest = src.ortSHA256[ortPlatform+":"+opts.ortVersion]
+	}
+	if digest == "" {
+		return fmt.Errorf("ort: no pinned SHA-256 for ONNX Runtime %s on %s; pass --ort-sha256", opts.ortVersion, ortPlatform)
+	}
+	ext := "tgz"
+	if opts.goos == "windows" {
+		ext = "zip"
+	}
+	url := src.ortURL(opts.ortVersion, ortPlatform, ext)
+	fmt.Fprintf(out, "ort: downloading %s\n", url)
+	archive, err := download(ctx, src.client, url, digest)
+	if err != nil {
+		return fmt.Errorf("ort: %w", err)
+	}
+	lib, err := extractORTLib(archive, ext, opts.goos)
+	if err != nil {
+		return fmt.Errorf("ort: %s: %w", url, err)
+	}
+	if err := writeFileAtomic(dest, lib); err != nil {
+		return fmt.Errorf("ort: %w", err)
+	}
+	fmt.Fprintf(out, "ort: installed %s (ONNX Runtime %s)\n", dest, opts.ortVersion)
+	if opts.ortVersion != engine.ORTVersion() {
+		fmt.Fprintf(out, "ort: note: this binary requires ONNX Runtime %s; run a build pinned to %s with this library\n", engine.ORTVersion(), opts.ortVersion)
+	}
+	return nil
+}
+
+func fetchModel(ctx context.Context, opts fetchOptions, src fetchSources, out io.Writer) error {
+	dest := filepath.Join(opts.dir, "models", "silero_vad.onnx")
+	if !opts.force && fileDigest(dest) == src.modelSHA256 {
+		fmt.Fprintf(out, "model: %s already installed\n", dest)
+	} else {
+		fmt.Fprintf(out, "model: downloading %s\n", src.modelURL)
+		data, err := download(ctx, src.client, src.modelURL, src.modelSHA256)
+		if err != nil {
+			return fmt.Errorf("model: %w", err)
+		}
+		if err := writeFileAtomic(dest, data); err != nil {
+			return fmt.Errorf("model: %w", err)
+		}
+		fmt.Fprintf(out, "model: installed %s\n", dest)
+	}
+	// Silero builds embed the same model; the file serves model_path.
+	fmt.Fprintf(out, "model: to run it instead of the embedded copy, set NUPI_VAD_MODEL_PATH=%s NUPI_VAD_MODEL_SHA256=%s\n", dest, src.modelSHA256)
+	return nil
+}
+
+// download fetches url and checks its SHA-256 against digest.
+func download(ctx context.Context, client *http.Client, url, digest string) ([]byte, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("%s: %s", url, resp.Status)
+	}
+	data, err := io.ReadAll(io.LimitReader(resp.Body, maxFetchBytes+1))
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", url, err)
+	}
+	if len(data) > maxFetchBytes {
+		return nil, fmt.Errorf("%s: larger than %d MiB", url, maxFetchBytes>>20)
+	}
+	sum := sha256.Sum256(data)
+	if got := hex.EncodeToString(sum[:]); !strings.EqualFold(got, digest) {
+		return nil, fmt.Errorf("%s: SHA-256 mismatch: expected %s, got %s", url, digest, got)
+	}
+	return data, nil
+}
+
+// extractORTLib returns the shared library from an ONNX Runtime release
+// archive: the regular file under lib/ named onnxruntime.dll on Windows,
+// libonnxruntime.so* or libonnxruntime*.dylib elsewhere.
+func extractORTLib(archive []byte, ext, goos string) ([]byte, error) {
+	isLib := func(name string) bool {
+		if path.Base(path.Dir(name)) != "lib" {
+			return false
+		}
+		base := path.Base(name)
+		switch goos {
+		case "windows":
+			return base == "onnxruntime.dll"
+		case "darwin":
+			return strings.HasPrefix(base, "libonnxruntime") && (strings.HasSuffix(base, ".dylib") || strings.Contains(base, ".dylib."))
+		default:
+			return base == "libonnxruntime.so" || strings.HasPrefix(base, "libonnxruntime.so.")
+		}
+	}
+	if ext == "zip" {
+		zr, err := zip.NewReader(bytes.NewReader(archive), int64(len(archive)))
+		if err != nil {
+			return nil, err
+		}
+		for _, f := range zr.File {
+			if f.Mode().IsRegular() && isLib(f.Name) {
+				rc, err := f.Open()
+				if err != nil {
+					return nil, err
+				}
+				defer rc.Close()
+				return io.ReadAll(io.LimitReader(rc, maxFetchBytes))
+			}
+		}
+		return nil, errors.New("no ONNX Runtime library in the archive")
+	}
+	gz, err := gzip.NewReader(bytes.NewReader(archive))
+	if err != nil {
+		return nil, err
+	}
+	tr := tar.NewReader(gz)
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			return nil, errors.New("no ONNX Runtime library in the archive")
+		}
+		if err != nil {
+			return nil, err
+		}
+		// Versioned releases ship the library once, the other names being
+		// symlinks to it.
+		if hdr.Typeflag == tar.TypeReg && isLib(hdr.Name) {
+			return io.ReadAll(io.LimitReader(tr, maxFetchBytes))
+		}
+	}
+}
+
+// writeFileAtomic writes data to path through a temporary file in the same
+// directory, so a failed download never leaves a truncated library.
+func writeFileAtomic(path string, data []byte) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(path), "."+filepath.Base(path)+".*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Chmod(0o644); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
+
+func fileExists(path string) bool {
+	info, err := os.Stat(path)
+	return err == nil && info.Mode().IsRegular()
+}
+
+// fileDigest returns the hex SHA-256 of the file at path, or "" when it
+// cannot be read.
+func fileDigest(path string) string {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return ""
+	}
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,182 @@
+package main
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func sha256Hex(data []byte) string {
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+// ortTGZ builds a release archive holding the library as a versioned file
+// and an unversioned symlink to it, as ONNX Runtime's Linux releases do.
+func ortTGZ(t *testing.T, lib []byte) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	gz := gzip.NewWriter(&buf)
+	tw := tar.NewWriter(gz)
+	for _, hdr := range []*tar.Header{
+		{Name: "onnxruntime-linux-x64-1.23.0/lib/libonnxruntime.so", Typeflag: tar.TypeSymlink, Linkname: "libonnxruntime.so.1.23.0"},
+		{Name: "onnxruntime-linux-x64-1.23.0/include/onnxruntime_c_api.h", Typeflag: tar.TypeReg, Size: 3},
+		{Name: "onnxruntime-linux-x64-1.23.0/lib/libonnxruntime.so.1.23.0", Typeflag: tar.TypeReg, Size: int64(len(lib))},
+	} {
+		if err := tw.WriteHeader(hdr); err != nil {
+			t.Fatal(err)
+		}
+		switch {
+		case strings.HasSuffix(hdr.Name, ".h"):
+			tw.Write([]byte("api"))
+		case hdr.Typeflag == tar.TypeReg:
+			tw.Write(lib)
+		}
+	}
+	if err := tw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if err := gz.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+// fetchServer serves files by path and returns sources pointing at it.
+func fetchServer(t *testing.T, files map[string][]byte) fetchSources {
+	t.Helper()
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		data, ok := files[r.URL.Path]
+		if !ok {
+			http.NotFound(w, r)
+			return
+		}
+		w.Write(data)
+	}))
+	t.Cleanup(srv.Close)
+	src := defaultFetchSources()
+	src.ortURL = func(version, platform, ext string) string {
+		return srv.URL + "/ort/" + platform + "-" + version + "." + ext
+	}
+	src.modelURL = srv.URL + "/model.onnx"
+	src.client = srv.Client()
+	return src
+}
+
+func TestFetchRuntime(t *testing.T) {
+	lib, model := []byte("ort library"), []byte("silero model")
+	archive := ortTGZ(t, lib)
+	src := fetchServer(t, map[string][]byte{
+		"/ort/linux-x64-1.23.0.tgz": archive,
+		"/model.onnx":               model,
+	})
+	src.ortSHA256 = map[string]string{"linux-x64:1.23.0": sha256Hex(archive)}
+	src.modelSHA256 = sha256Hex(model)
+
+	dir := t.TempDir()
+	opts := fetchOptions{dir: dir, goos: "linux", goarch: "amd64", ortVersion: "1.23.0"}
+	var out bytes.Buffer
+	if err := fetchRuntime(context.Background(), opts, src, &out); err != nil {
+		t.Fatal(err)
+	}
+	for path, want := range map[string][]byte{
+		filepath.Join(dir, "lib", "linux-amd64", "libonnxruntime.so.1.23.0"): lib,
+		filepath.Join(dir, "models", "silero_vad.onnx"):                      model,
+	} {
+		got, err := os.ReadFile(path)
+		if err != nil || !bytes.Equal(got, want) {
+			t.Errorf("%s = %q, %v; want %q", path, got, err, want)
+		}
+	}
+	if !strings.Contains(out.String(), "NUPI_VAD_MODEL_SHA256="+src.modelSHA256) {
+		t.Errorf("output lacks the model_path hint:\n%s", out.String())
+	}
+
+	// A second run keeps what is in place without downloading.
+	src.client = &http.Client{Transport: failingTransport{t}}
+	out.Reset()
+	if err := fetchRuntime(context.Background(), opts, src, &out); err != nil {
+		t.Fatal(err)
+	}
+	if strings.Count(out.String(), "already installed") != 2 {
+		t.Errorf("second run did not skip both files:\n%s", out.String())
+	}
+}
+
+type failingTransport struct{ t *testing.T }
+
+func (f failingTransport) RoundTrip(r *http.Request) (*http.Response, error) {
+	f.t.Errorf("unexpected download of %s", r.URL)
+	return nil, http.ErrNotSupported
+}
+
+func TestFetchRuntimeChecksumMismatch(t *testing.T) {
+	src := fetchServer(t, map[string][]byte{"/model.onnx": []byte("tampered")})
+	src.modelSHA256 = sha256Hex([]byte("silero model"))
+
+	dir := t.TempDir()
+	opts := fetchOptions{dir: dir, goos: "linux", goarch: "amd64", ortVersion: "1.23.0", skipORT: true}
+	err := fetchRuntime(context.Background(), opts, src, &bytes.Buffer{})
+	if err == nil || !strings.Contains(err.Error(), "SHA-256 mismatch") {
+		t.Fatalf("err = %v, want a SHA-256 mismatch", err)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "models")); !os.IsNotExist(err) {
+		t.Errorf("models/ written after a failed check: %v", err)
+	}
+
+	// A version without a pinned digest needs --ort-sha256.
+	opts = fetchOptions{dir: dir, goos: "linux", goarch: "amd64", ortVersion: "1.99.0", skipModel: true}
+	if err := fetchRuntime(context.Background(), opts, src, &bytes.Buffer{}); err == nil || !strings.Contains(err.Error(), "--ort-sha256") {
+		t.Errorf("unpinned version: err = %v, want a hint at --ort-sha256", err)
+	}
+}
+
+func TestExtractORTLibZip(t *testing.T) {
+	var buf bytes.Buffer
+	zw := zip.NewWriter(&buf)
+	for name, data := range map[string]string{
+		"onnxruntime-win-x64-1.23.0/lib/onnxruntime.lib":         "import library",
+		"onnxruntime-win-x64-1.23.0/lib/onnxruntime.dll":         "dll",
+		"onnxruntime-win-x64-1.23.0/include/onnxruntime_c_api.h": "api",
+	} {
+		w, err := zw.Create(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		w.Write([]byte(data))
+	}
+	if err := zw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	got, err := extractORTLib(buf.Bytes(), "zip", "windows")
+	if err != nil || string(got) != "dll" {
+		t.Errorf("extractORTLib = %q, %v; want the DLL", got, err)
+	}
+	if _, err := extractORTLib(buf.Bytes(), "zip", "linux"); err == nil {
+		t.Error("found a Linux library in a Windows archive")
+	}
+}
+
+func TestParseFetchArgs(t *testing.T) {
+	var stderr bytes.Buffer
+	opts, err := parseFetchArgs([]string{"--dir", "/opt/vad", "--skip-model"}, &stderr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if opts.dir != "/opt/vad" || !opts.skipModel || opts.ortVersion == "" {
+		t.Errorf("opts = %+v", opts)
+	}
+	if _, err := parseFetchArgs([]string{"--skip-ort", "--skip-model"}, &stderr); err == nil {
+		t.Error("accepted both --skip-ort and --skip-model")
+	}
+}
@@ -0,0 +1,154 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"runtime"
+	"strconv"
+	"strings"
+
+	"github.com/nupi-ai/plugin-vad-local-silero/internal/engine"
+)
+
+// cliFlag is a command-line flag standing in for an environment variable.
+type cliFlag struct {
+	name    string
+	env     string
+	usage   string
+	boolean bool
+}
+
+// cliFlags are the flags for launching the adapter outside nupi, which
+// configures it through the environment. Each sets the variable it names,
+// so values are parsed and validated the same way; the variable itself,
+// when set, still wins, and a flag overrides NUPI_ADAPTER_CONFIG and the
+// config file like the variable would.
+var cliFlags = []cliFlag{
+	{name: "config", env: "NUPI_ADAPTER_CONFIG_FILE", usage: "YAML, TOML or JSON config `file`"},
+	{name: "watch-config", env: "NUPI_ADAPTER_CONFIG_WATCH", usage: "reload the config file's VAD parameters when it changes", boolean: true},
+	{name: "listen-addr", env: "NUPI_ADAPTER_LISTEN_ADDR", usage: "comma-separated `host:port` bind addresses"},
+	{name: "engine", env: "NUPI_VAD_ENGINE", usage: "engine: auto, silero, stub, energy or a registered `name`"},
+	{name: "preset", env: "NUPI_VAD_PRESET", usage: "tuning `preset`: low-latency, balanced or dictation"},
+	{name: "threshold", env: "NUPI_VAD_THRESHOLD", usage: "speech confidence threshold, a `probability` in [0, 1]"},
+	{name: "neg-threshold", env: "NUPI_VAD_NEG_THRESHOLD", usage: "`probability` below which speech ends, at most the threshold"},
+	{name: "min-speech-duration-ms", env: "NUPI_VAD_MIN_SPEECH_DURATION_MS", usage: "min speech duration before START, `ms`"},
+	{name: "min-silence-duration-ms", env: "NUPI_VAD_MIN_SILENCE_DURATION_MS", usage: "min silence duration before END, `ms`"},
+	{name: "max-streams", env: "NUPI_VAD_MAX_STREAMS", usage: "at most `n` concurrent streams, 0 = unlimited"},
+	{name: "log-level", env: "NUPI_LOG_LEVEL", usage: "log `level`: debug, info, warn or error"},
+	{name: "log-format", env: "NUPI_LOG_FORMAT", usage: "log `format`: text or json"},
+	{name: "metrics-addr", env: "NUPI_VAD_METRICS_ADDR", usage: "serve expvar metrics on this `host:port`"},
+	{name: "gateway-addr", env: "NUPI_VAD_GATEWAY_ADDR", usage: "serve the HTTP/WebSocket gateway on this `host:port`"},
+	{name: "instance-id", env: "NUPI_VAD_INSTANCE_ID", usage: "instance `id` attached to logs and stream headers"},
+}
+
+// cliOptions is the parsed command line.
+type cliOptions struct {
+	// values holds the flags given, keyed by their environment variable.
+	values  map[string]string
+	version bool
+}
+
+// parseFlags parses the adapter's command line. It returns flag.ErrHelp
+// after writing the usage for -h or --help.
+func parseFlags(args []string, out io.Writer) (cliOptions, error) {
+	opts := cliOptions{values: map[string]string{}}
+	fs := flag.NewFlagSet(adapterName, flag.ContinueOnError)
+	fs.SetOutput(out)
+	fs.Usage = func() { writeUsage(fs, out) }
+	fs.BoolVar(&opts.version, "version", false, "print the version and exit")
+	addConfigFlags(fs, opts.values)
+	if err := fs.Parse(args); err != nil {
+		return cliOptions{}, err
+	}
+	if fs.NArg() > 0 {
+		fmt.Fprintf(out, "unexpected argument %q\n", fs.Arg(0))
+		fs.Usage()
+		return cliOptions{}, errors.New("unexpected arguments")
+	}
+	return opts, nil
+}
+
+// addConfigFlags defines cliFlags on fs, recording the values given in
+// values keyed by their environment variable.
+func addConfigFlags(fs *flag.FlagSet, values map[string]string) {
+	for _, f := range cliFlags {
+		set := func(value string) error {
+			values[f.env] = value
+			return nil
+		}
+		usage := fmt.Sprintf("%s (env %s)", f.usage, f.env)
+		if f.boolean {
+			fs.BoolFunc(f.name, usage, func(value string) error {
+				on, err := strconv.ParseBool(value)
+				if err != nil {
+					return errors.New("must be true or false")
+				}
+				return set(strconv.FormatBool(on))
+			})
+		} else {
+			fs.Func(f.name, usage, set)
+		}
+	}
+}
+
+// lookup returns a lookup for config.Loader that reads env first and falls
+// back to the flags. A variable set to an empty string counts as unset, as
+// it does for the loader.
+func (o cliOptions) lookup(env func(string) (string, bool)) func(string) (string, bool) {
+	return func(key string) (string, bool) {
+		if value, ok := env(key); ok && strings.TrimSpace(value) != "" {
+			return value, true
+		}
+		value, ok := o.values[key]
+		return value, ok
+	}
+}
+
+// origin returns the Origin for config.Loader that matches lookup: the
+// flag, when it supplied the variable's value, or the variable.
+func (o cliOptions) origin(env func(string) (string, bool)) func(string) string {
+	return func(key string) string {
+		if value, ok := env(key); ok && strings.TrimSpace(value) != "" {
+			return key
+		}
+		if _, ok := o.values[key]; ok {
+			for _, f := range cliFlags {
+				if f.env == key {
+					return "--" + f.name
+				}
+			}
+		}
+		return key
+	}
+}
+
+func writeUsage(fs *flag.FlagSet, out io.Writer) {
+	fmt.Fprintf(out, `Usage: %[1]s [flags]
+       %[1]s demo
+       %[1]s annotate [flags] file.wav...
+       %[1]s fetch-runtime [flags]
+
+Local voice activity detection adapter for nupi, serving the NAP
+VoiceActivityDetectionService over gRPC. Every option can also be set through
+its environment variable, which takes precedence over the flag; see the
+README for the options without a flag.
+
+Flags:
+`, adapterName)
+	fs.PrintDefaults()
+}
+
+// versionString describes the build for --version.
+func versionString() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "%s %s (%s, %s/%s)\n", adapterName, version, runtime.Version(), runtime.GOOS, runtime.GOARCH)
+	engines := []string{"stub", "energy"}
+	if engine.NativeAvailable() {
+		engines = append([]string{"silero"}, engines...)
+	}
+	engines = append(engines, engine.RegisteredNames()...)
+	fmt.Fprintf(&b, "engines: %s\n", strings.Join(engines, ", "))
+	return b.String()
+}
@@ -0,0 +1,91 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"flag"
+	"strings"
+	"testing"
+
+	"github.com/nupi-ai/plugin-vad-local-silero/internal/config"
+)
+
+func TestParseFlagsPrecedence(t *testing.T) {
+	var out bytes.Buffer
+	cli, err := parseFlags([]string{"--engine", "stub", "--threshold=0.7", "--min-silence-duration-ms", "500", "--watch-config=false", "--log-level", "debug"}, &out)
+	if err != nil {
+		t.Fatal(err)
+	}
+	env := map[string]string{
+		"NUPI_ADAPTER_CONFIG": `{"threshold": 0.6, "min_speech_duration_ms": 120}`,
+		"NUPI_LOG_LEVEL":      "warn",
+		"NUPI_VAD_THRESHOLD":  " ",
+	}
+	lookupEnv := func(key string) (string, bool) {
+		v, ok := env[key]
+		return v, ok
+	}
+	result, err := config.Loader{Lookup: cli.lookup(lookupEnv), Origin: cli.origin(lookupEnv)}.Load()
+	if err != nil {
+		t.Fatal(err)
+	}
+	cfg := result.Config
+	// A flag overrides NUPI_ADAPTER_CONFIG; a blank variable does not mask it.
+	if cfg.Threshold != 0.7 || cfg.MinSilenceDurationMs != 500 || cfg.Engine != config.EngineStub {
+		t.Errorf("flags: threshold %v, min silence %d, engine %q", cfg.Threshold, cfg.MinSilenceDurationMs, cfg.Engine)
+	}
+	if cfg.MinSpeechDurationMs != 120 {
+		t.Errorf("min_speech_duration_ms = %d, want 120 from NUPI_ADAPTER_CONFIG", cfg.MinSpeechDurationMs)
+	}
+	// The environment overrides a flag.
+	if cfg.LogLevel != "warn" {
+		t.Errorf("log level = %q, want warn from NUPI_LOG_LEVEL", cfg.LogLevel)
+	}
+	for name, want := range map[string]string{
+		"threshold":              "--threshold",
+		"min_speech_duration_ms": config.SourceJSON,
+		"log_level":              "NUPI_LOG_LEVEL",
+		"max_streams":            config.SourceDefault,
+	} {
+		if got := result.Provenance[name]; got != want {
+			t.Errorf("source of %s = %q, want %q", name, got, want)
+		}
+	}
+}
+
+func TestParseFlagsErrors(t *testing.T) {
+	for _, args := range [][]string{
+		{"--no-such-flag"},
+		{"--watch-config=maybe"},
+		{"--threshold"},
+		{"serve"},
+	} {
+		var out bytes.Buffer
+		if _, err := parseFlags(args, &out); err == nil || errors.Is(err, flag.ErrHelp) {
+			t.Errorf("%q: err = %v, want a usage error", args, err)
+		}
+		if !strings.Contains(out.String(), "Usage:") {
+			t.Errorf("%q: no usage printed: %q", args, out.String())
+		}
+	}
+
+	var out bytes.Buffer
+	if _, err := parseFlags([]string{"--help"}, &out); !errors.Is(err, flag.ErrHelp) {
+		t.Errorf("--help: err = %v, want flag.ErrHelp", err)
+	}
+	for _, want := range []string{"-listen-addr host:port", "env NUPI_VAD_THRESHOLD", "-version"} {
+		if !strings.Contains(out.String(), want) {
+			t.Errorf("usage lacks %q:\n%s", want, out.String())
+		}
+	}
+}
+
+func TestVersionFlag(t *testing.T) {
+	cli, err := parseFlags([]string{"--version"}, &bytes.Buffer{})
+	if err != nil || !cli.version {
+		t.Fatalf("--version: %+v, %v", cli, err)
+	}
+	if got := versionString(); !strings.HasPrefix(got, adapterName+" "+version+" ") || !strings.Contains(got, "stub") {
+		t.Errorf("versionString() = %q", got)
+	}
+}
@@ -0,0 +1,111 @@
+package main
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"log/slog"
+	"net"
+	"net/http"
+	"time"
+
+	napv1 "github.com/nupi-ai/nupi/api/nap/v1"
+	"google.golang.org/grpc"
+	"google.golang.org/protobuf/types/known/structpb"
+
+	"github.com/nupi-ai/plugin-vad-local-silero/internal/batch"
+	"github.com/nupi-ai/plugin-vad-local-silero/internal/config"
+	"github.com/nupi-ai/plugin-vad-local-silero/internal/gateway"
+	"github.com/nupi-ai/plugin-vad-local-silero/internal/timestamp"
+)
+
+// detectSpeechMethod is the full gRPC method name of DetectSpeech, as the
+// interceptors see it.
+var detectSpeechMethod = "/" + napv1.VoiceActivityDetectionService_ServiceDesc.ServiceName + "/DetectSpeech"
+
+// gatewayDetect returns the gateway's DetectSpeech handler: service behind
+// interceptors, chained in order as the gRPC server chains them, so gateway
+// streams get the same panic recovery and token check.
+func gatewayDetect(service napv1.VoiceActivityDetectionServiceServer, interceptors []grpc.StreamServerInterceptor) gateway.DetectFunc {
+	info := &grpc.StreamServerInfo{FullMethod: detectSpeechMethod, IsClientStream: true, IsServerStream: true}
+	handler := func(_ any, ss grpc.ServerStream) error {
+		return service.DetectSpeech(ss.(napv1.VoiceActivityDetectionService_DetectSpeechServer))
+	}
+	for i := len(interceptors) - 1; i >= 0; i-- {
+		interceptor, next := interceptors[i], handler
+		handler = func(srv any, ss grpc.ServerStream) error {
+			return interceptor(srv, ss, info, next)
+		}
+	}
+	return func(stream napv1.VoiceActivityDetectionService_DetectSpeechServer) error {
+		return handler(service, stream)
+	}
+}
+
+// gatewayBuffer returns the gateway's buffer detection handler: service
+// behind interceptors, chained in order like gatewayDetect's.
+func gatewayBuffer(service *batch.Service, interceptors []grpc.UnaryServerInterceptor) gateway.BufferFunc {
+	info := &grpc.UnaryServerInfo{Server: service, FullMethod: batch.DetectSpeechInBufferMethod}
+	handler := func(ctx context.Context, req any) (any, error) {
+		return service.DetectSpeechInBuffer(ctx, req.(*napv1.DetectSpeechRequest))
+	}
+	for i := len(interceptors) - 1; i >= 0; i-- {
+		interceptor, next := interceptors[i], handler
+		handler = func(ctx context.Context, req any) (any, error) {
+			return interceptor(ctx, req, info, next)
+		}
+	}
+	return func(ctx context.Context, req *napv1.DetectSpeechRequest) (*structpb.Struct, error) {
+		res, err := handler(ctx, req)
+		if err != nil {
+			return nil, err
+		}
+		return res.(*structpb.Struct), nil
+	}
+}
+
+// serveGateway serves the HTTP gateway on cfg.GatewayAddr until ctx is done,
+// over TLS when the gRPC port uses it. Streams still open at shutdown are
+// cut when the process exits.
+func serveGateway(ctx context.Context, cfg config.Config, detect gateway.DetectFunc, buffer gateway.BufferFunc, logger *slog.Logger) error {
+	maxMessage, err := recvMsgLimit(cfg)
+	if err != nil {
+		return err
+	}
+	gw := gateway.New(detect, gateway.Options{
+		TimestampFormat: timestamp.Format(cfg.TimestampFormat),
+		Origins:         cfg.GatewayOriginList(),
+		MaxMessageBytes: maxMessage,
+		Buffer:          buffer,
+	}, logger)
+
+	lis, err := net.Listen("tcp", cfg.GatewayAddr)
+	if err != nil {
+		return err
+	}
+	if cfg.TLSEnabled() {
+		tlsCfg, err := serverTLSConfig(cfg)
+		if err != nil {
+			lis.Close()
+			return err
+		}
+		lis = tls.NewListener(lis, tlsCfg)
+	}
+	srv := &http.Server{
+		Handler:           gw.Handler(),
+		ReadHeaderTimeout: 5 * time.Second,
+	}
+	go func() {
+		if err := srv.Serve(lis); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			logger.Error("gateway listener failed", "error", err)
+		}
+	}()
+	go func() {
+		<-ctx.Done()
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), time.Second)
+		defer cancel()
+		srv.Shutdown(shutdownCtx)
+	}()
+	logger.Info("gateway listener started", "addr", lis.Addr().String(), "tls", cfg.TLSEnabled(), "stream_path", gateway.StreamPath, "detect_path", gateway.DetectPath)
+	return nil
+}
@@ -0,0 +1,188 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"math"
+	"sync"
+	"time"
+
+	"google.golang.org/grpc/health"
+	healthgrpc "google.golang.org/grpc/health/grpc_health_v1"
+
+	"github.com/nupi-ai/plugin-vad-local-silero/internal/engine"
+	"github.com/nupi-ai/plugin-vad-local-silero/internal/metrics"
+)
+
+// servingState combines the conditions the health status depends on and
+// reports SERVING only when all of them allow it: initialization is done,
+// the instance is not quiesced or stopping, and the engine self-test passes.
+type servingState struct {
+	health   *health.Server
+	services []string
+
+	mu                                  sync.Mutex
+	ready, quiesced, unhealthy, stopped bool
+}
+
+func newServingState(h *health.Server, services ...string) *servingState {
+	s := &servingState{health: h, services: services}
+	s.apply()
+	return s
+}
+
+// update changes the state under the lock and publishes the result.
+func (s *servingState) update(change func(s *servingState)) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	change(s)
+	s.apply()
+}
+
+// apply publishes the status. Called with s.mu held, or before sharing s.
+func (s *servingState) apply() {
+	st := healthgrpc.HealthCheckResponse_NOT_SERVING
+	if s.ready && !s.quiesced && !s.unhealthy && !s.stopped {
+		st = healthgrpc.HealthCheckResponse_SERVING
+	}
+	for _, name := range s.services {
+		s.health.SetServingStatus(name, st)
+	}
+}
+
+// selfTestPCM is the canned buffer of the engine self-test: 320 ms of a
+// 440 Hz tone at 16 kHz, a whole number of 20 ms, 32 ms and 512-sample frames.
+var selfTestPCM = func() []byte {
+	pcm := make([]byte, 0, 2*5120)
+	for i := 0; i < 5120; i++ {
+		v := int16(0.3 * math.MaxInt16 * math.Sin(2*math.Pi*440*float64(i)/float64(engine.ExpectedSampleRate)))
+		pcm = append(pcm, byte(v), byte(v>>8))
+	}
+	return pcm
+}()
+
+// selfTest runs one inference on selfTestPCM through a probe engine of its
+// own, so a runtime that stops working (ONNX Runtime unloaded, memory
+// corruption) takes the instance out of rotation instead of failing every
+// new stream. The probe engine is recreated after a failure.
+type selfTest struct {
+	newEngine func() engine.Engine
+	timeout   time.Duration
+	state     *servingState
+	failures  *metrics.Counter
+	log       *slog.Logger
+
+	probe   engine.Engine
+	failing bool
+	running chan struct{} // closed when the inference in flight returns
+}
+
+func newSelfTest(newEngine func() engine.Engine, timeout time.Duration, state *servingState, reg *metrics.Registry, logger *slog.Logger) *selfTest {
+	return &selfTest{
+		newEngine: newEngine,
+		timeout:   timeout,
+		state:     state,
+		failures:  reg.Counter("vad_self_test_failures_total"),
+		log:       logger,
+	}
+}
+
+// run checks the engine every interval until ctx is done.
+func (t *selfTest) run(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			t.check()
+		}
+	}
+}
+
+// check runs the self-test once and updates the serving state.
+func (t *selfTest) check() {
+	err := t.infer()
+	if err != nil {
+		t.failures.Inc()
+	}
+	switch {
+	case err != nil && !t.failing:
+		t.log.Error("engine self-test failed, reporting NOT_SERVING", "error", err)
+	case err == nil && t.failing:
+		t.log.Info("engine self-test passed again, reporting SERVING")
+	case err != nil:
+		t.log.Warn("engine self-test still failing", "error", err)
+	}
+	t.failing = err != nil
+	t.state.update(func(s *servingState) { s.unhealthy = t.failing })
+}
+
+// infer runs one inference with the timeout. An inference that hangs is
+// abandoned, and counts as failing until it returns.
+func (t *selfTest) infer() error {
+	if t.running != nil {
+		select {
+		case <-t.running:
+			t.running = nil
+		default:
+			return errors.New("previous self-test inference has not returned")
+		}
+	}
+	if t.probe == nil {
+		if t.probe = t.newEngine(); t.probe == nil {
+			return errors.New("probe engine creation failed")
+		}
+	}
+	probe := t.probe
+	done := make(chan struct{})
+	var err error
+	go func() {
+		defer close(done)
+		err = runSelfTestInference(probe)
+	}()
+	select {
+	case <-done:
+	case <-time.After(t.timeout):
+		t.running = done
+		t.probe = nil // never reused, even if it returns
+		return fmt.Errorf("inference took longer than %s", t.timeout)
+	}
+	if err != nil {
+		probe.Close()
+		t.probe = nil
+	}
+	return err
+}
+
+// runSelfTestInference feeds selfTestPCM to eng from a clean state and
+// checks its results.
+func runSelfTestInference(eng engine.Engine) (err error) {
+	defer func() {
+		if p := recover(); p != nil {
+			err = fmt.Errorf("inference panicked: %v", p)
+		}
+	}()
+	if err := eng.Reset(); err != nil {
+		return fmt.Errorf("reset: %w", err)
+	}
+	if err := eng.SetSampleRate(engine.ExpectedSampleRate); err != nil {
+		return fmt.Errorf("set sample rate: %w", err)
+	}
+	results, err := eng.ProcessChunk(selfTestPCM, engine.ExpectedSampleRate)
+	if err != nil {
+		return fmt.Errorf("inference: %w", err)
+	}
+	if len(results) == 0 {
+		return errors.New("inference returned no frames")
+	}
+	for _, r := range results {
+		if c := float64(r.Confidence); math.IsNaN(c) || c < 0 || c > 1 {
+			return fmt.Errorf("inference returned probability %v", r.Confidence)
+		}
+	}
+	return nil
+}
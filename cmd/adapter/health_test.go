@@ -0,0 +1,146 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"io"
+	"log/slog"
+	"testing"
+	"time"
+
+	"google.golang.org/grpc/health"
+	healthgrpc "google.golang.org/grpc/health/grpc_health_v1"
+
+	"github.com/nupi-ai/plugin-vad-local-silero/internal/engine"
+	"github.com/nupi-ai/plugin-vad-local-silero/internal/metrics"
+)
+
+// flakyEngine fails inference while fail is set and blocks it while block
+// is non-nil.
+type flakyEngine struct {
+	*engine.StubEngine
+	fail  *bool
+	block chan struct{}
+}
+
+func (e flakyEngine) ProcessChunk(pcm []byte, rate uint32) ([]engine.Result, error) {
+	if e.block != nil {
+		<-e.block
+	}
+	if *e.fail {
+		return nil, errors.New("ORT session lost")
+	}
+	return e.StubEngine.ProcessChunk(pcm, rate)
+}
+
+func servingStatus(t *testing.T, h *health.Server) healthgrpc.HealthCheckResponse_ServingStatus {
+	t.Helper()
+	resp, err := h.Check(context.Background(), &healthgrpc.HealthCheckRequest{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return resp.GetStatus()
+}
+
+func TestServingState(t *testing.T) {
+	h := health.NewServer()
+	s := newServingState(h, "")
+	if got := servingStatus(t, h); got != healthgrpc.HealthCheckResponse_NOT_SERVING {
+		t.Errorf("initial status = %v", got)
+	}
+	s.update(func(s *servingState) { s.ready = true })
+	if got := servingStatus(t, h); got != healthgrpc.HealthCheckResponse_SERVING {
+		t.Errorf("ready status = %v", got)
+	}
+	for _, change := range []func(*servingState){
+		func(s *servingState) { s.quiesced = true },
+		func(s *servingState) { s.unhealthy = true },
+		func(s *servingState) { s.stopped = true },
+	} {
+		s.update(change)
+		if got := servingStatus(t, h); got != healthgrpc.HealthCheckResponse_NOT_SERVING {
+			t.Errorf("status = %v, want NOT_SERVING", got)
+		}
+		s.update(func(s *servingState) { s.quiesced, s.unhealthy, s.stopped = false, false, false })
+	}
+	// Leaving quiesce does not override a failing self-test.
+	s.update(func(s *servingState) { s.unhealthy, s.quiesced = true, true })
+	s.update(func(s *servingState) { s.quiesced = false })
+	if got := servingStatus(t, h); got != healthgrpc.HealthCheckResponse_NOT_SERVING {
+		t.Errorf("unquiesced but unhealthy: status = %v", got)
+	}
+}
+
+func TestSelfTestFlipsServing(t *testing.T) {
+	h := health.NewServer()
+	state := newServingState(h, "")
+	state.update(func(s *servingState) { s.ready = true })
+	reg := metrics.NewRegistry()
+	fail := false
+	created := 0
+	st := newSelfTest(func() engine.Engine {
+		created++
+		return flakyEngine{StubEngine: engine.NewStubEngine(), fail: &fail}
+	}, time.Second, state, reg, slog.New(slog.NewTextHandler(io.Discard, nil)))
+
+	st.check()
+	if got := servingStatus(t, h); got != healthgrpc.HealthCheckResponse_SERVING {
+		t.Errorf("passing self-test: status = %v", got)
+	}
+	fail = true
+	st.check()
+	if got := servingStatus(t, h); got != healthgrpc.HealthCheckResponse_NOT_SERVING {
+		t.Errorf("failing self-test: status = %v", got)
+	}
+	fail = false
+	st.check()
+	if got := servingStatus(t, h); got != healthgrpc.HealthCheckResponse_SERVING {
+		t.Errorf("recovered self-test: status = %v", got)
+	}
+	if created != 2 {
+		t.Errorf("probe engines created = %d, want 2 (recreated after the failure)", created)
+	}
+	if got := reg.Counter("vad_self_test_failures_total").Value(); got != 1 {
+		t.Errorf("vad_self_test_failures_total = %d, want 1", got)
+	}
+}
+
+func TestSelfTestTimeout(t *testing.T) {
+	h := health.NewServer()
+	state := newServingState(h, "")
+	state.update(func(s *servingState) { s.ready = true })
+	fail := false
+	block := make(chan struct{})
+	st := newSelfTest(func() engine.Engine {
+		return flakyEngine{StubEngine: engine.NewStubEngine(), fail: &fail, block: block}
+	}, 10*time.Millisecond, state, metrics.NewRegistry(), slog.New(slog.NewTextHandler(io.Discard, nil)))
+
+	st.check()
+	if got := servingStatus(t, h); got != healthgrpc.HealthCheckResponse_NOT_SERVING {
+		t.Errorf("hung inference: status = %v", got)
+	}
+	st.check() // still hung
+	if got := servingStatus(t, h); got != healthgrpc.HealthCheckResponse_NOT_SERVING {
+		t.Errorf("inference still hung: status = %v", got)
+	}
+	close(block)
+	deadline := time.Now().Add(5 * time.Second)
+	for servingStatus(t, h) != healthgrpc.HealthCheckResponse_SERVING {
+		if time.Now().After(deadline) {
+			t.Fatal("self-test never recovered after the inference returned")
+		}
+		time.Sleep(5 * time.Millisecond)
+		st.check()
+	}
+}
+
+func TestSelfTestPCMIsWholeFrames(t *testing.T) {
+	for _, eng := range []engine.Engine{engine.NewStubEngine(), engine.NewEnergyEngine()} {
+		if err := runSelfTestInference(eng); err != nil {
+			t.Errorf("%T: %v", eng, err)
+		}
+		if n := len(selfTestPCM) / 2 % eng.FrameSamples(); n != 0 {
+			t.Errorf("%T: %d samples left over", eng, n)
+		}
+	}
+}
@@ -0,0 +1,105 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+
+	"github.com/nupi-ai/plugin-vad-local-silero/internal/config"
+	"github.com/nupi-ai/plugin-vad-local-silero/internal/engine"
+)
+
+// integrityCheck is the outcome of checking one startup dependency against
+// its known-good digest.
+type integrityCheck struct {
+	what   string // "ONNX Runtime library" or "embedded model"
+	path   string // the library's path; empty for the embedded model
+	digest string
+	want   string // empty when no digest is known: the object is unverified
+	env    string // the variable overriding want
+	err    error  // the mismatch, when want is set and does not match
+}
+
+// verifyIntegrity checks the ONNX Runtime library native engines load and
+// the embedded model of the configured version and precision against the
+// digests in cfg.Integrity, or those recorded in the build. It returns the
+// checks made, and an error for the first mismatch unless
+// integrity.allow_mismatch is set. Without a native engine compiled in
+// there is nothing to check; a library that cannot be found is left to the
+// engine probe to report.
+func verifyIntegrity(cfg config.Config) ([]integrityCheck, error) {
+	if !engine.NativeAvailable() {
+		return nil, nil
+	}
+	var checks []integrityCheck
+	if path, err := engine.ORTLibPath(); err == nil {
+		digest, err := engine.FileSHA256(path)
+		if err != nil {
+			return nil, fmt.Errorf("read ONNX Runtime library: %w", err)
+		}
+		want := cfg.Integrity.ORTLibSHA256
+		if want == "" {
+			want = engine.PinnedORTLibSHA256()
+		}
+		checks = append(checks, integrityCheck{
+			what: "ONNX Runtime library", path: path, digest: digest, want: want,
+			env: "NUPI_VAD_INTEGRITY_ORT_LIB_SHA256",
+		})
+	}
+	if model := engine.EmbeddedModel(cfg.ModelVersion, cfg.ModelPrecision); len(model) > 0 {
+		want := cfg.Integrity.EmbeddedModelSHA256
+		if want == "" {
+			want = engine.PinnedModelSHA256(cfg.ModelVersion, cfg.ModelPrecision)
+		}
+		checks = append(checks, integrityCheck{
+			what: "embedded model", digest: engine.ModelSHA256(model), want: want,
+			env: "NUPI_VAD_INTEGRITY_EMBEDDED_MODEL_SHA256",
+		})
+	}
+	for i := range checks {
+		c := &checks[i]
+		if c.want == "" {
+			continue
+		}
+		what := c.what
+		if c.path != "" {
+			what += " " + c.path
+		}
+		c.err = engine.CheckSHA256(what, c.digest, c.want)
+		if c.err != nil && !cfg.Integrity.AllowMismatch {
+			return checks, fmt.Errorf("%w (set %s if this %s is trusted, or NUPI_VAD_INTEGRITY_ALLOW_MISMATCH=1)", c.err, c.env, c.what)
+		}
+	}
+	return checks, nil
+}
+
+// logIntegrity logs the outcome of each check.
+func logIntegrity(logger *slog.Logger, checks []integrityCheck) {
+	for _, c := range checks {
+		var attrs []any
+		if c.path != "" {
+			attrs = append(attrs, "path", c.path)
+		}
+		attrs = append(attrs, "sha256", c.digest)
+		switch {
+		case c.err != nil:
+			logger.Error(c.what+" failed its checksum, starting anyway (integrity.allow_mismatch)", append(attrs, "want_sha256", c.want)...)
+		case c.want == "":
+			logger.Warn(c.what+" not verified: no known-good digest (set "+c.env+")", attrs...)
+		default:
+			logger.Info(c.what+" verified", attrs...)
+		}
+	}
+}
+
+// loadModelFile reads cfg.ModelPath and checks it against cfg.ModelSHA256.
+// With integrity.allow_mismatch a file failing the check is still returned,
+// and the mismatch is returned as allowed instead of err.
+func loadModelFile(cfg config.Config) (data []byte, digest string, allowed, err error) {
+	data, digest, err = engine.LoadModelFile(cfg.ModelPath, cfg.ModelSHA256)
+	if errors.Is(err, engine.ErrChecksumMismatch) && cfg.Integrity.AllowMismatch {
+		allowed = err
+		data, digest, err = engine.LoadModelFile(cfg.ModelPath, "")
+	}
+	return data, digest, allowed, err
+}
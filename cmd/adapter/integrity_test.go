@@ -0,0 +1,29 @@
+package main
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/nupi-ai/plugin-vad-local-silero/internal/config"
+)
+
+func TestLoadModelFileAllowMismatch(t *testing.T) {
+	data := []byte("model")
+	path := filepath.Join(t.TempDir(), "silero_vad.onnx")
+	if err := os.WriteFile(path, data, 0o600); err != nil {
+		t.Fatal(err)
+	}
+	cfg := config.Config{ModelPath: path, ModelSHA256: strings.Repeat("0", 64)}
+	if _, _, _, err := loadModelFile(cfg); err == nil {
+		t.Fatal("mismatched model file accepted")
+	}
+
+	cfg.Integrity.AllowMismatch = true
+	got, _, allowed, err := loadModelFile(cfg)
+	if err != nil || !bytes.Equal(got, data) || allowed == nil {
+		t.Errorf("allow_mismatch: %q, allowed %v, err %v; want the file and the mismatch", got, allowed, err)
+	}
+}
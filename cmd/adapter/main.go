@@ -3,36 +3,65 @@ package main
 import (
 	"context"
 	"errors"
+	"flag"
+	"fmt"
+	"io"
 	"log/slog"
 	"net"
 	"os"
 	"os/signal"
+	"strconv"
 	"strings"
 	"sync/atomic"
 	"syscall"
 	"time"
 
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
 	"google.golang.org/grpc"
 	"google.golang.org/grpc/codes"
 	"google.golang.org/grpc/health"
 	healthgrpc "google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/metadata"
 	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/durationpb"
 
 	napv1 "github.com/nupi-ai/nupi/api/nap/v1"
 
+	"github.com/nupi-ai/plugin-vad-local-silero/internal/admin"
+	"github.com/nupi-ai/plugin-vad-local-silero/internal/batch"
+	_ "github.com/nupi-ai/plugin-vad-local-silero/internal/compression" // gzip and zstd requests
 	"github.com/nupi-ai/plugin-vad-local-silero/internal/config"
 	"github.com/nupi-ai/plugin-vad-local-silero/internal/engine"
+	"github.com/nupi-ai/plugin-vad-local-silero/internal/feedback"
+	"github.com/nupi-ai/plugin-vad-local-silero/internal/metrics"
+	"github.com/nupi-ai/plugin-vad-local-silero/internal/proxyproto"
+	"github.com/nupi-ai/plugin-vad-local-silero/internal/sdnotify"
+	"github.com/nupi-ai/plugin-vad-local-silero/internal/selfcheck"
 	"github.com/nupi-ai/plugin-vad-local-silero/internal/server"
 )
 
 // version is set at build time by GoReleaser via -ldflags.
 var version = "dev"
 
+// adapterName identifies the adapter in logs.
+const adapterName = "vad-local-silero"
+
+// expectedWarmup is the typical time from startup to serving (engine probe and
+// model load). Clients that connect earlier are told to retry once it has
+// elapsed, and never sooner than minRetryHint.
+const (
+	expectedWarmup = 2 * time.Second
+	minRetryHint   = 250 * time.Millisecond
+)
+
 // lazyVADServer wraps a VoiceActivityDetectionServiceServer and allows deferred
 // initialization. It returns Unavailable errors until the underlying server is set.
 type lazyVADServer struct {
 	napv1.UnimplementedVoiceActivityDetectionServiceServer
 	server atomic.Pointer[napv1.VoiceActivityDetectionServiceServer]
+
+	// startedAt is when initialization began, for the retry hint.
+	startedAt time.Time
 }
 
 func (l *lazyVADServer) setServer(srv napv1.VoiceActivityDetectionServiceServer) {
@@ -42,23 +71,78 @@ func (l *lazyVADServer) setServer(srv napv1.VoiceActivityDetectionServiceServer)
 func (l *lazyVADServer) DetectSpeech(stream napv1.VoiceActivityDetectionService_DetectSpeechServer) error {
 	srv := l.server.Load()
 	if srv == nil {
-		return status.Error(codes.Unavailable, "VAD service is initializing, please retry in a moment")
+		hint := l.retryHint(time.Now())
+		stream.SetTrailer(metadata.Pairs(server.RetryPushbackKey, strconv.FormatInt(hint.Milliseconds(), 10)))
+		return initializingError(hint)
 	}
 	return (*srv).DetectSpeech(stream)
 }
 
+// retryHint estimates the remaining warmup at now.
+func (l *lazyVADServer) retryHint(now time.Time) time.Duration {
+	return max(expectedWarmup-now.Sub(l.startedAt), minRetryHint)
+}
+
+// initializingError is the Unavailable status returned while initializing. It
+// carries a google.rpc.RetryInfo detail with the retry hint, so clients back
+// off instead of reconnecting in a tight loop during model load.
+func initializingError(hint time.Duration) error {
+	st := status.Newf(codes.Unavailable, "VAD service is initializing, please retry in %s", hint.Round(time.Millisecond))
+	withInfo, err := st.WithDetails(&errdetails.RetryInfo{RetryDelay: durationpb.New(hint)})
+	if err != nil {
+		return st.Err()
+	}
+	return withInfo.Err()
+}
+
 func main() {
+	if len(os.Args) > 1 && os.Args[1] == "demo" {
+		os.Exit(runDemo(os.Stdout))
+	}
+	if len(os.Args) > 1 && os.Args[1] == "annotate" {
+		os.Exit(runAnnotate(context.Background(), os.Args[2:], os.Stdout, os.Stderr))
+	}
+	if len(os.Args) > 1 && os.Args[1] == "fetch-runtime" {
+		os.Exit(runFetchRuntime(context.Background(), os.Args[2:], os.Stdout, os.Stderr))
+	}
+
+	cli, err := parseFlags(os.Args[1:], os.Stderr)
+	if errors.Is(err, flag.ErrHelp) {
+		os.Exit(0)
+	}
+	if err != nil {
+		os.Exit(2)
+	}
+	if cli.version {
+		fmt.Print(versionString())
+		os.Exit(0)
+	}
+
+	startedAt := time.Now()
 	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
 	defer stop()
 
-	loadResult, err := config.Loader{}.Load()
+	loader := config.Loader{Lookup: cli.lookup(os.LookupEnv), Origin: cli.origin(os.LookupEnv)}
+	loadResult, err := loader.Load()
 	if err != nil {
 		slog.Error("failed to load configuration", "error", err)
 		os.Exit(1)
 	}
 	cfg := loadResult.Config
 
-	logger := newLogger(cfg.LogLevel)
+	logger, logLevel := newLogger(os.Stdout, cfg.LogLevel, cfg.LogFormat)
+	// Every log line names the adapter and its build, so records stay
+	// attributable once shipped to a shared log store.
+	logger = logger.With("adapter", adapterName, "version", version)
+	if cfg.InstanceID != "" {
+		// Every log line carries the instance so fleets sharing a log or
+		// event pipeline can attribute records to the adapter that wrote them.
+		logger = logger.With("instance_id", cfg.InstanceID)
+	}
+
+	// SIGUSR1 toggles debug logging (e.g. ignored request fields) without a
+	// restart; a no-op on platforms without the signal.
+	watchDebugToggle(ctx, logLevel, logger)
 
 	// Log warnings for deprecated/unsupported config options.
 	for _, warn := range loadResult.Warnings {
@@ -66,48 +150,127 @@ func main() {
 	}
 
 	logger.Info("starting adapter",
-		"adapter", "vad-local-silero",
-		"version", version,
 		"engine_config", cfg.Engine, // configured value, may be "auto"
 		"listen_addr", cfg.ListenAddr,
 		"threshold", cfg.Threshold,
+		"neg_threshold", cfg.EffectiveNegThreshold(),
 		"min_speech_duration_ms", cfg.MinSpeechDurationMs,
 		"min_silence_duration_ms", cfg.MinSilenceDurationMs,
+		"candidate_percent", cfg.CandidatePercent,
+		"stateless", cfg.Stateless,
+		"strict_proto", cfg.StrictProto,
 	)
+	logEffectiveConfig(logger, cfg, loadResult.Provenance)
+
+	if cfg.TraceDir != "" {
+		if err := os.MkdirAll(cfg.TraceDir, 0o700); err != nil {
+			logger.Error("failed to create trace directory", "trace_dir", cfg.TraceDir, "error", err)
+			os.Exit(1)
+		}
+		logger.Warn("per-frame tracing enabled", "trace_dir", cfg.TraceDir, "trace_stream_id", cfg.TraceStreamID)
+	}
+
+	// Counters are served from startup so initialization is observable too.
+	reg := metrics.NewRegistry()
+	if cfg.MetricsAddr != "" {
+		if err := serveMetrics(ctx, cfg.MetricsAddr, reg, logger); err != nil {
+			logger.Error("failed to start metrics listener", "metrics_addr", cfg.MetricsAddr, "error", err)
+			os.Exit(1)
+		}
+	}
 
 	// STEP 1: Bind port IMMEDIATELY (before engine init)
-	lis, err := net.Listen("tcp", cfg.ListenAddr)
-	if err != nil {
-		logger.Error("failed to bind listener", "error", err)
-		os.Exit(1)
+	// Every address is bound before serving, so a partial bind fails startup.
+	network := cfg.EffectiveListenNetwork()
+	var proxyErrors *metrics.Counter
+	if cfg.ProxyProtocol {
+		proxyErrors = reg.Counter("vad_proxy_protocol_errors_total")
+	}
+	var listeners []net.Listener
+	for _, addr := range cfg.ListenAddrs() {
+		lis, err := net.Listen(network, addr)
+		if err != nil {
+			logger.Error("failed to bind listener", "network", network, "listen_addr", addr, "error", err)
+			os.Exit(1)
+		}
+		defer lis.Close()
+		if cfg.ProxyProtocol {
+			lis = proxyproto.NewListener(lis, proxyproto.DefaultHeaderTimeout, func(remote net.Addr, err error) {
+				proxyErrors.Inc()
+				logger.Warn("closing connection without a valid PROXY protocol header", "remote_addr", remote.String(), "error", err)
+			})
+		}
+		listeners = append(listeners, lis)
+		logger.Info("listener bound, port ready", "addr", lis.Addr().String(), "network", network, "proxy_protocol", cfg.ProxyProtocol)
 	}
-	defer lis.Close()
-	logger.Info("listener bound, port ready", "addr", lis.Addr().String())
 
 	// STEP 2: Setup gRPC server with lazy VAD service wrapper
-	// Limit message size to prevent memory spikes from oversized payloads.
-	// Add 64KB headroom for protobuf overhead beyond PCM data.
-	grpcServer := grpc.NewServer(
-		grpc.MaxRecvMsgSize(server.MaxPCMChunkBytes+64*1024),
-	)
+	// Limit message size to prevent memory spikes from oversized payloads;
+	// the limit is derived from max_chunk_bytes.
+	grpcOpts, err := connectionOptions(cfg)
+	if err != nil {
+		logger.Error("invalid message size limit — cannot start", "max_chunk_bytes", cfg.EffectiveMaxChunkBytes(), "error", err)
+		os.Exit(1)
+	}
+	recovery := newPanicRecovery(reg, logger)
+	grpcOpts = append(grpcOpts, recovery.serverOptions()...)
+	// The gateway runs its calls behind the same interceptors.
+	streamInterceptors := []grpc.StreamServerInterceptor{recovery.stream}
+	unaryInterceptors := []grpc.UnaryServerInterceptor{recovery.unary}
+	creds, err := serverCredentials(cfg)
+	if err != nil {
+		logger.Error("invalid TLS configuration — cannot start", "error", err)
+		os.Exit(1)
+	}
+	if creds != nil {
+		grpcOpts = append(grpcOpts, grpc.Creds(creds))
+		logger.Info("TLS enabled", "client_auth", cfg.TLSClientCAFile != "")
+	}
+	if cfg.AuthToken != "" {
+		auth := newTokenAuth(cfg.AuthToken, reg, logger.With("component", "auth"))
+		grpcOpts = append(grpcOpts, auth.serverOptions()...)
+		streamInterceptors = append(streamInterceptors, auth.stream)
+		unaryInterceptors = append(unaryInterceptors, auth.unary)
+		if cfg.TLSEnabled() {
+			logger.Info("auth token enabled")
+		} else {
+			logger.Warn("auth token enabled without TLS, the token is sent in plaintext")
+		}
+	}
+	grpcServer := grpc.NewServer(grpcOpts...)
 	healthServer := health.NewServer()
 	healthgrpc.RegisterHealthServer(grpcServer, healthServer)
 
 	serviceName := napv1.VoiceActivityDetectionService_ServiceDesc.ServiceName
-	healthServer.SetServingStatus("", healthgrpc.HealthCheckResponse_NOT_SERVING)
-	healthServer.SetServingStatus(serviceName, healthgrpc.HealthCheckResponse_NOT_SERVING)
+	serving := newServingState(healthServer, "", serviceName)
 
-	lazyService := &lazyVADServer{}
+	lazyService := &lazyVADServer{startedAt: startedAt}
 	napv1.RegisterVoiceActivityDetectionServiceServer(grpcServer, lazyService)
+	adminService := admin.New(logLevel, logger.With("component", "admin"))
+	adminService.SetProvenance(loadResult.Provenance)
+	admin.Register(grpcServer, adminService)
+	batchService := batch.New()
+	batch.Register(grpcServer, batchService)
+	feedbackService := feedback.New()
+	feedback.Register(grpcServer, feedbackService)
 
 	// STEP 3: Start gRPC server in background
-	serverErr := make(chan error, 1)
-	go func() {
-		if err := grpcServer.Serve(lis); err != nil && !errors.Is(err, grpc.ErrServerStopped) {
-			serverErr <- err
-		}
-	}()
+	serverErr := make(chan error, len(listeners))
+	for _, lis := range listeners {
+		go func() {
+			if err := grpcServer.Serve(lis); err != nil && !errors.Is(err, grpc.ErrServerStopped) {
+				serverErr <- err
+			}
+		}()
+	}
 	logger.Info("gRPC server started (NOT_SERVING while initializing)")
+	if cfg.GatewayAddr != "" {
+		detect, buffer := gatewayDetect(lazyService, streamInterceptors), gatewayBuffer(batchService, unaryInterceptors)
+		if err := serveGateway(ctx, cfg, detect, buffer, logger.With("component", "gateway")); err != nil {
+			logger.Error("failed to start gateway listener", "gateway_addr", cfg.GatewayAddr, "error", err)
+			os.Exit(1)
+		}
+	}
 
 	// STEP 4: Engine factory — each stream gets its own engine instance.
 	// Resolve "auto" to actual engine based on what's compiled in and working.
@@ -123,6 +286,18 @@ func main() {
 		}
 	}
 
+	if err := engine.SetSessionOptions(cfg.ORT.SessionOptions()); err != nil {
+		logger.Error("invalid ONNX Runtime session options", "error", err)
+		os.Exit(1)
+	}
+	externalModel := installModel(logger, cfg)
+	checks, err := verifyIntegrity(cfg)
+	logIntegrity(logger, checks)
+	if err != nil {
+		logger.Error("integrity check failed — cannot start", "error", err)
+		os.Exit(1)
+	}
+	skipProbe := os.Getenv("NUPI_VAD_SKIP_PROBE") == "1"
 	var newEngine func() engine.Engine
 	switch resolvedEngine {
 	case "silero":
@@ -131,7 +306,21 @@ func main() {
 			os.Exit(1)
 		}
 		// Probe: verify native engine can be created before accepting traffic.
-		probe, err := engine.NewNativeEngine(cfg.Threshold)
+		// NUPI_VAD_SKIP_PROBE=1 defers that to the first stream, for fast
+		// restarts during local development.
+		var probe engine.Engine
+		var err error
+		if skipProbe {
+			logger.Warn("NUPI_VAD_SKIP_PROBE=1: silero engine NOT probed, ONNX Runtime problems will only surface on the first stream — development only, never use in production")
+		} else {
+			probe, err = engine.NewNativeEngine(cfg.HopMs)
+			if err != nil && externalModel {
+				logger.Error("model file failed to load, falling back to embedded model",
+					"path", cfg.ModelPath, "error", err)
+				engine.SetModel(nil)
+				probe, err = engine.NewNativeEngine(cfg.HopMs)
+			}
+		}
 		if err != nil {
 			devMode := os.Getenv("NUPI_DEV_MODE") == "1"
 			if isAutoMode && devMode {
@@ -152,14 +341,17 @@ func main() {
 				os.Exit(1)
 			}
 		} else {
-			probe.Close()
-			logger.Info("engine ready", "type", "silero")
+			if probe != nil {
+				probe.Close()
+			}
+			logger.Info("engine ready", "type", "silero", "hop_ms", cfg.HopMs, "probed", !skipProbe,
+				"intra_op_threads", cfg.ORT.IntraOpThreads, "inter_op_threads", cfg.ORT.InterOpThreads)
 
 			// TODO(perf): For high concurrency, consider pooling ONNX sessions or
 			// sharing a single session with per-stream RNN state. Currently each
 			// stream creates its own session and tensors, which scales linearly.
 			newEngine = func() engine.Engine {
-				eng, err := engine.NewNativeEngine(cfg.Threshold)
+				eng, err := engine.NewNativeEngine(cfg.HopMs)
 				if err != nil {
 					// Should not happen after successful probe; return nil,
 					// handled by server as stream error.
@@ -170,27 +362,221 @@ func main() {
 			}
 		}
 	case "stub":
-		logger.Warn("using stub engine — VAD results are deterministic and NOT based on audio content")
+		factory, err := stubEngineFactory(cfg.StubScript)
+		if err != nil {
+			logger.Error("stub script unusable — cannot start", "stub_script", cfg.StubScript, "error", err)
+			os.Exit(1)
+		}
+		if cfg.StubScript != "" {
+			logger.Warn("using scripted stub engine — VAD results follow the script and are NOT based on audio content", "stub_script", cfg.StubScript)
+		} else {
+			logger.Warn("using stub engine — VAD results are deterministic and NOT based on audio content")
+		}
+		newEngine = factory
+	case config.EngineEnergy:
+		logger.Info("engine ready", "type", config.EngineEnergy)
 		newEngine = func() engine.Engine {
-			return engine.NewStubEngine()
+			return engine.NewEnergyEngine()
+		}
+	default:
+		// A custom engine compiled in with its build tag (config validation
+		// accepts only registered names). It is always probed.
+		factory, err := registeredEngineFactory(resolvedEngine, "stream", cfg.HopMs, logger)
+		if err != nil {
+			logger.Error("engine probe failed — cannot start", "engine", resolvedEngine, "error", err)
+			os.Exit(1)
+		}
+		logger.Info("engine ready", "type", resolvedEngine, "hop_ms", cfg.HopMs, "probed", true)
+		newEngine = factory
+	}
+
+	// The health self-test creates its own engine, outside the warm pool.
+	probeFactory := newEngine
+
+	// Warm engines for the expected concurrency before reporting SERVING,
+	// trading startup time for flat latency when a burst of streams arrives.
+	// Skipping the probe means no engine is created before the first stream,
+	// so the warm pool is skipped too.
+	if cfg.ExpectedStreams > 0 && skipProbe && resolvedEngine == config.EngineSilero {
+		logger.Warn("NUPI_VAD_SKIP_PROBE=1: engine warm pool disabled", "expected_streams", cfg.ExpectedStreams)
+	} else if cfg.ExpectedStreams > 0 {
+		pool := engine.NewPool(newEngine, cfg.ExpectedStreams)
+		defer pool.Close()
+		warmStart := time.Now()
+		warm := pool.Warm()
+		if p, stack := pool.WarmPanic(); p != nil {
+			reg.Counter("vad_panics_total").Inc()
+			logger.Error("recovered from panic creating a warm pool engine",
+				"panic", fmt.Sprint(p),
+				"stack", string(stack),
+			)
+		}
+		logger.Info("engine warm pool ready",
+			"engines", warm,
+			"expected_streams", cfg.ExpectedStreams,
+			"duration", time.Since(warmStart),
+		)
+		newEngine = pool.Get
+	}
+
+	// Check OS resources against the workload before accepting traffic, so
+	// mis-sized containers are flagged at startup instead of under load.
+	runSelfCheck(logger, selfCheckOptions(resolvedEngine, cfg))
+
+	serverOpts := []server.Option{server.WithMetrics(reg), server.WithEngineName(resolvedEngine)}
+
+	// Optional shadow comparison engine. It only records divergence metrics
+	// and never affects the events sent to clients.
+	if cfg.ShadowPercent > 0 {
+		shadowName := cfg.ShadowEngine
+		if shadowName == "" {
+			shadowName = resolvedEngine
+		}
+		newShadow, err := auxEngineFactory(shadowName, "shadow", cfg.HopMs, logger)
+		if err != nil {
+			logger.Error("shadow engine unavailable — cannot start", "shadow_engine", shadowName, "error", err)
+			os.Exit(1)
+		}
+		serverOpts = append(serverOpts, server.WithShadowEngine(newShadow))
+		logger.Info("shadow comparison enabled", "shadow_engine", shadowName, "shadow_percent", cfg.ShadowPercent)
+	}
+
+	// Ensemble engines are selected per stream (config_json "ensemble"), so
+	// every engine compiled in is offered; silero ones are not probed here.
+	ensembleEngines := ensembleEngineFactories(cfg.HopMs, logger)
+	if cfg.Ensemble.Enabled() {
+		if _, ok := ensembleEngines[cfg.Ensemble.Engine]; !ok {
+			logger.Error("ensemble engine not compiled in — cannot start", "ensemble_engine", cfg.Ensemble.Engine)
+			os.Exit(1)
+		}
+		logger.Info("ensemble enabled (experimental)", "ensemble_engine", cfg.Ensemble.Engine, "mode", cfg.Ensemble.Mode)
+	}
+	serverOpts = append(serverOpts, server.WithEnsembleEngines(ensembleEngines))
+
+	// A/B mode: streams with config_json "model": "b" run model_b.
+	if cfg.ModelB.Enabled() {
+		newModelB, err := modelBEngineFactory(cfg, logger)
+		if err != nil {
+			logger.Error("model b unusable — cannot start", "error", err)
+			os.Exit(1)
+		}
+		if newModelB != nil {
+			serverOpts = append(serverOpts, server.WithModelBEngine(newModelB))
+		}
+	}
+
+	if cfg.CPUBudget.Enabled() {
+		// Downgraded silero streams run at the default hop, the cheapest.
+		if cfg.CPUBudget.Action == config.CPUBudgetDowngrade {
+			newCheaper, err := auxEngineFactory(cfg.CPUBudget.Engine, "downgrade", config.DefaultHopMs, logger)
+			if err != nil {
+				logger.Error("cpu budget downgrade engine unavailable — cannot start", "engine", cfg.CPUBudget.Engine, "error", err)
+				os.Exit(1)
+			}
+			serverOpts = append(serverOpts, server.WithDowngradeEngine(newCheaper))
 		}
+		logger.Info("cpu budget enabled",
+			"percent", cfg.CPUBudget.Percent,
+			"slice_s", cfg.CPUBudget.EffectiveSliceSec(),
+			"action", cfg.CPUBudget.Action,
+			"engine", cfg.CPUBudget.Engine)
 	}
 
+	if cfg.BillingOutput != "" {
+		billingOut, err := openBillingOutput(cfg)
+		if err != nil {
+			logger.Error("billing output unavailable — cannot start", "error", err)
+			os.Exit(1)
+		}
+		defer billingOut.Close()
+		serverOpts = append(serverOpts, server.WithBillingOutput(billingOut))
+		logger.Info("billing records enabled", "billing_output", cfg.BillingOutput)
+	}
+	if cfg.SegmentSink != "" {
+		sink, err := server.NewSegmentSink(cfg.SegmentSinkTarget())
+		if err != nil {
+			logger.Error("segment sink unavailable — cannot start", "error", err)
+			os.Exit(1)
+		}
+		serverOpts = append(serverOpts, server.WithSegmentSink(sink))
+		logger.Info("segment sink enabled", "segment_sink", cfg.SegmentSink)
+	}
+
+	if cfg.Feedback.StateFile != "" {
+		offsets, err := server.ReadFeedbackState(cfg.Feedback.StateFile)
+		if err != nil {
+			logger.Error("feedback state unreadable — cannot start", "error", err)
+			os.Exit(1)
+		}
+		serverOpts = append(serverOpts, server.WithFeedbackOffsets(offsets))
+	}
+	if cfg.Feedback.Enabled() {
+		logger.Info("feedback threshold tuning enabled",
+			"step", cfg.Feedback.Step, "max_offset", cfg.Feedback.EffectiveMaxOffset(), "state_file", cfg.Feedback.StateFile)
+	}
+
+	// Quiesce mode (admin Quiesce) takes the instance out of load balancer
+	// rotation: readiness follows it, open streams are not affected.
+	serverOpts = append(serverOpts, server.WithQuiesceHook(func(quiesced bool) {
+		serving.update(func(s *servingState) { s.quiesced = quiesced })
+	}))
+
 	// STEP 5: Activate the real VAD service
-	realService := server.New(cfg, logger, newEngine)
+	realService := server.New(cfg, logger, newEngine, serverOpts...)
 	lazyService.setServer(napv1.VoiceActivityDetectionServiceServer(realService))
+	adminService.SetServer(realService)
+	batchService.SetServer(realService)
+	feedbackService.SetServer(realService)
 
-	healthServer.SetServingStatus("", healthgrpc.HealthCheckResponse_SERVING)
-	healthServer.SetServingStatus(serviceName, healthgrpc.HealthCheckResponse_SERVING)
+	if cfg.StuckStreamTimeoutSec > 0 {
+		go realService.RunWatchdog(ctx)
+	}
+	if cfg.DriftReportIntervalSec > 0 {
+		go realService.RunDriftMonitor(ctx, time.Duration(cfg.DriftReportIntervalSec)*time.Second)
+	}
+
+	if cfg.ConfigWatch {
+		reloader := newConfigReloader(loader.Load, realService, reg, logger.With("component", "config"))
+		prov := loadResult.Provenance
+		reloader.reloaded = func(reloaded config.Provenance) {
+			// Only the VAD parameters were applied; the other options keep
+			// the source they started with.
+			prov = prov.WithTunable(reloaded)
+			adminService.SetProvenance(prov)
+		}
+		go config.WatchFile(ctx, cfg.ConfigFile, config.DefaultWatchInterval, reloader.reload)
+		logger.Info("watching config file for VAD parameter changes", "path", cfg.ConfigFile)
+	}
+
+	// The self-test keeps SERVING honest: an engine that stops producing
+	// results takes the instance out of rotation until it recovers.
+	if cfg.SelfTestIntervalSec > 0 {
+		interval := time.Duration(cfg.SelfTestIntervalSec) * time.Second
+		st := newSelfTest(probeFactory, min(interval, 10*time.Second), serving, reg, logger.With("component", "self_test"))
+		go st.run(ctx, interval)
+	}
+
+	serving.update(func(s *servingState) { s.ready = true })
 	logger.Info("adapter ready to serve requests", "engine", resolvedEngine)
 
+	// Under systemd (Type=notify), readiness follows SERVING, and with
+	// WatchdogSec the health service's liveness feeds the watchdog.
+	systemd := sdnotify.New(nil)
+	if systemd.Enabled() {
+		notifySystemd(systemd, logger, sdnotify.Ready, sdnotify.Status("serving, engine "+resolvedEngine))
+		if interval := systemd.WatchdogInterval(); interval > 0 {
+			go runSystemdWatchdog(ctx, systemd, interval, healthServer, logger)
+		}
+		logger.Info("systemd notification enabled", "watchdog_interval_ms", systemd.WatchdogInterval().Milliseconds())
+	}
+
 	// STEP 6: Setup graceful shutdown
 	shutdownDone := make(chan struct{})
 	go func() {
 		<-ctx.Done()
 		logger.Info("shutdown requested, stopping gRPC server")
-		healthServer.SetServingStatus(serviceName, healthgrpc.HealthCheckResponse_NOT_SERVING)
-		healthServer.SetServingStatus("", healthgrpc.HealthCheckResponse_NOT_SERVING)
+		notifySystemd(systemd, logger, sdnotify.Stopping, sdnotify.Status("draining streams"))
+		serving.update(func(s *servingState) { s.stopped = true })
 
 		stopped := make(chan struct{})
 		go func() {
@@ -201,8 +587,16 @@ func main() {
 		select {
 		case <-stopped:
 		case <-time.After(5 * time.Second):
-			logger.Warn("graceful stop timed out, forcing stop")
-			grpcServer.Stop()
+			// Streams still open are ended with a SHUTDOWN status so clients
+			// know to reconnect elsewhere rather than seeing a reset.
+			n := realService.TerminateStreams(server.ReasonShutdown, "adapter is shutting down, retry on another instance")
+			logger.Warn("graceful stop timed out, terminating streams", "streams", n)
+			select {
+			case <-stopped:
+			case <-time.After(time.Second):
+				logger.Warn("streams did not finish, forcing stop")
+				grpcServer.Stop()
+			}
 		}
 		close(shutdownDone)
 	}()
@@ -215,15 +609,268 @@ func main() {
 	case <-shutdownDone:
 		// Normal shutdown — graceful drain completed
 	}
+	realService.CloseSegmentSink()
 
 	logger.Info("adapter stopped")
 }
 
-func newLogger(level string) *slog.Logger {
-	handler := slog.NewTextHandler(os.Stdout, &slog.HandlerOptions{
-		Level: parseLevel(level),
-	})
-	return slog.New(handler)
+// streamMemoryEstimate is the approximate per-stream footprint of each
+// engine: an ONNX Runtime session with its own copy of the model, tensors
+// and buffers for silero; only PCM bookkeeping for stub and energy.
+var streamMemoryEstimate = map[string]uint64{
+	config.EngineSilero: 16 << 20,
+	config.EngineStub:   64 << 10,
+	config.EngineEnergy: 64 << 10,
+}
+
+// selfCheckOptions describes the workload cfg configures engineName for.
+// Silero sessions run ort.intra_op_threads threads each; stub and energy
+// score frames on the stream's own goroutine.
+func selfCheckOptions(engineName string, cfg config.Config) selfcheck.Options {
+	threads := 1
+	if engineName == config.EngineSilero {
+		threads = cfg.ORT.IntraOpThreads
+		if threads == 0 {
+			threads = config.DefaultORTThreads
+		}
+	}
+	return selfcheck.Options{
+		MaxStreams:        cfg.MaxStreams,
+		StreamMemoryBytes: streamMemoryEstimate[engineName],
+		InferenceThreads:  threads,
+	}
+}
+
+// runSelfCheck logs the resources available to the adapter and a warning for
+// each limit likely to be hit under load; with opts.MaxStreams set, the file
+// and memory checks are made for that many streams.
+func runSelfCheck(logger *slog.Logger, opts selfcheck.Options) {
+	report := selfcheck.Run(opts)
+	logger.Info("resource self-check",
+		"open_files_limit", report.OpenFilesLimit,
+		"memory_available_mib", report.MemoryBytes>>20,
+		"cpu_quota", report.CPUQuota,
+		"visible_cpus", report.VisibleCPUs,
+		"gomaxprocs", report.GOMAXPROCS,
+		"estimated_stream_capacity", report.StreamCapacity,
+	)
+	for _, w := range report.Warnings {
+		logger.Warn("resource self-check: " + w)
+	}
+}
+
+// installModel selects the model generation of native engines and makes
+// them use the model file at cfg.ModelPath, reporting whether it did. A file that cannot be read or fails its checksum
+// is logged and the embedded model is kept, so a bad model rollout degrades
+// to the model shipped with the binary instead of a crash loop.
+func installModel(logger *slog.Logger, cfg config.Config) bool {
+	if err := engine.SetModelVersion(cfg.ModelVersion); err != nil {
+		logger.Error("invalid model version", "error", err)
+		os.Exit(1)
+	}
+	if err := engine.SetModelPrecision(cfg.ModelPrecision); err != nil {
+		logger.Error("invalid model precision", "error", err)
+		os.Exit(1)
+	}
+	if cfg.ModelPath == "" {
+		if engine.NativeAvailable() {
+			logger.Info("using embedded model", "version", cfg.ModelVersion, "precision", cfg.ModelPrecision, "sha256", engine.EmbeddedModelSHA256())
+		}
+		return false
+	}
+	if !engine.NativeAvailable() {
+		logger.Warn("model_path ignored: native silero not compiled in", "path", cfg.ModelPath)
+		return false
+	}
+	data, digest, allowed, err := loadModelFile(cfg)
+	if err != nil {
+		logger.Error("model file rejected, falling back to embedded model",
+			"path", cfg.ModelPath, "error", err, "version", cfg.ModelVersion, "precision", cfg.ModelPrecision, "embedded_sha256", engine.EmbeddedModelSHA256())
+		return false
+	}
+	if allowed != nil {
+		logger.Error("model file failed its checksum, using it anyway (integrity.allow_mismatch)", "path", cfg.ModelPath, "error", allowed)
+	}
+	if cfg.ModelSHA256 == "" {
+		logger.Warn("model file loaded without checksum validation (set NUPI_VAD_MODEL_SHA256)", "path", cfg.ModelPath)
+	}
+	engine.SetModel(data)
+	logger.Info("using model file", "path", cfg.ModelPath, "version", cfg.ModelVersion, "precision", cfg.ModelPrecision, "sha256", digest, "bytes", len(data))
+	return true
+}
+
+// auxEngineFactory returns a per-stream factory for an auxiliary engine
+// (shadow or CPU budget downgrade), named role in logs. A silero engine is
+// probed once so a broken backend fails at startup rather than silently on
+// every stream.
+func auxEngineFactory(name, role string, hopMs int, logger *slog.Logger) (func() engine.Engine, error) {
+	switch name {
+	case config.EngineStub:
+		return func() engine.Engine { return engine.NewStubEngine() }, nil
+	case config.EngineEnergy:
+		return func() engine.Engine { return engine.NewEnergyEngine() }, nil
+	case config.EngineSilero:
+		probe, err := engine.NewNativeEngine(hopMs)
+		if err != nil {
+			return nil, err
+		}
+		probe.Close()
+		return func() engine.Engine {
+			eng, err := engine.NewNativeEngine(hopMs)
+			if err != nil {
+				logger.Warn("per-stream "+role+" engine creation failed", "error", err)
+				return nil
+			}
+			return eng
+		}, nil
+	default:
+		return registeredEngineFactory(name, role, hopMs, logger)
+	}
+}
+
+// modelBEngineFactory returns the factory of the silero engines running
+// model_b: the file at cfg.ModelB.Path, checked like model_path, or else the
+// embedded model of its version and precision. Unlike model_path, a model
+// that fails to load is an error rather than a fallback: streams selecting
+// "b" would silently run model a. Without a native engine compiled in it
+// returns a nil factory, and streams selecting "b" fail.
+func modelBEngineFactory(cfg config.Config, logger *slog.Logger) (func() engine.Engine, error) {
+	m := cfg.ModelB
+	if !engine.NativeAvailable() {
+		logger.Warn("model_b ignored: native silero not compiled in")
+		return nil, nil
+	}
+	var (
+		data   []byte
+		digest string
+	)
+	if m.Path != "" {
+		file := cfg
+		file.ModelPath, file.ModelSHA256 = m.Path, m.SHA256
+		var allowed, err error
+		data, digest, allowed, err = loadModelFile(file)
+		if err != nil {
+			return nil, err
+		}
+		if allowed != nil {
+			logger.Error("model b file failed its checksum, using it anyway (integrity.allow_mismatch)", "path", m.Path, "error", allowed)
+		}
+		if m.SHA256 == "" {
+			logger.Warn("model b file loaded without checksum validation (set NUPI_VAD_MODEL_B_SHA256)", "path", m.Path)
+		}
+	} else {
+		data = engine.EmbeddedModel(m.Version, m.Precision)
+		if len(data) == 0 {
+			return nil, fmt.Errorf("no embedded %s %s model in this build (set NUPI_VAD_MODEL_B_PATH)", m.Version, m.Precision)
+		}
+		digest = engine.ModelSHA256(data)
+		if want := engine.PinnedModelSHA256(m.Version, m.Precision); want != "" {
+			if err := engine.CheckSHA256("embedded model b", digest, want); err != nil {
+				if !cfg.Integrity.AllowMismatch {
+					return nil, err
+				}
+				logger.Error("embedded model b failed its checksum, using it anyway (integrity.allow_mismatch)", "error", err)
+			}
+		}
+	}
+	probe, err := engine.NewNativeEngineFromModel(cfg.HopMs, data, m.Version)
+	if err != nil {
+		return nil, err
+	}
+	probe.Close()
+	logger.Info("model b loaded", "path", m.Path, "version", m.Version, "precision", m.Precision, "sha256", digest)
+	return func() engine.Engine {
+		eng, err := engine.NewNativeEngineFromModel(cfg.HopMs, data, m.Version)
+		if err != nil {
+			logger.Error("per-stream model b engine creation failed", "error", err)
+			return nil
+		}
+		return eng
+	}, nil
+}
+
+// stubEngineFactory returns the stub engine factory, with every engine
+// following the script in the file at scriptPath when it is set.
+func stubEngineFactory(scriptPath string) (func() engine.Engine, error) {
+	if scriptPath == "" {
+		return func() engine.Engine { return engine.NewStubEngine() }, nil
+	}
+	data, err := os.ReadFile(scriptPath)
+	if err != nil {
+		return nil, err
+	}
+	script, err := engine.ParseScript(data)
+	if err != nil {
+		return nil, err
+	}
+	return func() engine.Engine { return engine.NewStubEngine(engine.WithScript(script)) }, nil
+}
+
+// registeredEngineFactory returns a per-stream factory for the custom engine
+// registered as name, named role in logs, after probing it once.
+func registeredEngineFactory(name, role string, hopMs int, logger *slog.Logger) (func() engine.Engine, error) {
+	factory, ok := engine.Lookup(name)
+	if !ok {
+		return nil, fmt.Errorf("unknown %s engine %q", role, name)
+	}
+	probe, err := factory(hopMs)
+	if err != nil {
+		return nil, err
+	}
+	probe.Close()
+	return func() engine.Engine {
+		eng, err := factory(hopMs)
+		if err != nil {
+			logger.Warn("per-stream "+role+" engine creation failed", "engine", name, "error", err)
+			return nil
+		}
+		return eng
+	}, nil
+}
+
+// ensembleEngineFactories returns the per-stream factories for ensemble
+// engines, keyed by engine name: the stub and energy engines always, silero
+// when compiled in, and the registered custom engines.
+func ensembleEngineFactories(hopMs int, logger *slog.Logger) map[string]func() engine.Engine {
+	factories := map[string]func() engine.Engine{
+		config.EngineStub:   func() engine.Engine { return engine.NewStubEngine() },
+		config.EngineEnergy: func() engine.Engine { return engine.NewEnergyEngine() },
+	}
+	if engine.NativeAvailable() {
+		factories[config.EngineSilero] = func() engine.Engine {
+			eng, err := engine.NewNativeEngine(hopMs)
+			if err != nil {
+				logger.Warn("per-stream ensemble engine creation failed", "error", err)
+				return nil
+			}
+			return eng
+		}
+	}
+	for _, name := range engine.RegisteredNames() {
+		factory, _ := engine.Lookup(name)
+		factories[name] = func() engine.Engine {
+			eng, err := factory(hopMs)
+			if err != nil {
+				logger.Warn("per-stream ensemble engine creation failed", "engine", name, "error", err)
+				return nil
+			}
+			return eng
+		}
+	}
+	return factories
+}
+
+// newLogger returns the adapter logger, writing format ("text" or "json") to
+// w, and its level, which can be changed at runtime.
+func newLogger(w io.Writer, level, format string) (*slog.Logger, *slog.LevelVar) {
+	levelVar := new(slog.LevelVar)
+	levelVar.Set(parseLevel(level).Level())
+	opts := &slog.HandlerOptions{Level: levelVar}
+	var handler slog.Handler = slog.NewTextHandler(w, opts)
+	if format == config.LogFormatJSON {
+		handler = slog.NewJSONHandler(w, opts)
+	}
+	return slog.New(handler), levelVar
 }
 
 func parseLevel(value string) slog.Leveler {
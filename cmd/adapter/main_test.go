@@ -0,0 +1,136 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	napv1 "github.com/nupi-ai/nupi/api/nap/v1"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+
+	"github.com/nupi-ai/plugin-vad-local-silero/internal/config"
+	"github.com/nupi-ai/plugin-vad-local-silero/internal/server"
+)
+
+// trailerStream records the trailer of a DetectSpeech call that is rejected
+// before reading any request.
+type trailerStream struct {
+	napv1.VoiceActivityDetectionService_DetectSpeechServer
+	trailer metadata.MD
+}
+
+func (s *trailerStream) SetTrailer(md metadata.MD) {
+	s.trailer = metadata.Join(s.trailer, md)
+}
+
+func TestLazyServerRetryHint(t *testing.T) {
+	lazy := &lazyVADServer{startedAt: time.Now().Add(-500 * time.Millisecond)}
+	stream := &trailerStream{}
+
+	err := lazy.DetectSpeech(stream)
+	st := status.Convert(err)
+	if st.Code() != codes.Unavailable {
+		t.Fatalf("code = %v, want Unavailable", st.Code())
+	}
+	var info *errdetails.RetryInfo
+	for _, d := range st.Details() {
+		if ri, ok := d.(*errdetails.RetryInfo); ok {
+			info = ri
+		}
+	}
+	if info == nil {
+		t.Fatalf("no RetryInfo in details: %v", st.Details())
+	}
+	if d := info.GetRetryDelay().AsDuration(); d <= minRetryHint || d > expectedWarmup-500*time.Millisecond {
+		t.Errorf("retry delay = %v, want the remaining warmup (< 1.5s)", d)
+	}
+	if got := stream.trailer.Get(server.RetryPushbackKey); len(got) != 1 || got[0] == "" {
+		t.Errorf("trailer %s = %v", server.RetryPushbackKey, got)
+	}
+
+	// Past the expected warmup the hint does not drop below the floor.
+	if got := lazy.retryHint(lazy.startedAt.Add(time.Minute)); got != minRetryHint {
+		t.Errorf("late retryHint = %v, want %v", got, minRetryHint)
+	}
+}
+
+func TestNewLoggerFormat(t *testing.T) {
+	var buf bytes.Buffer
+	logger, level := newLogger(&buf, "warn", config.LogFormatJSON)
+	logger.Info("dropped")
+	logger.With("session_id", "s1").Warn("kept", "frames", 3)
+	var rec map[string]any
+	if err := json.Unmarshal(buf.Bytes(), &rec); err != nil {
+		t.Fatalf("JSON log line %q: %v", buf.String(), err)
+	}
+	if rec["msg"] != "kept" || rec["level"] != "WARN" || rec["session_id"] != "s1" || rec["frames"] != 3.0 {
+		t.Errorf("record = %v", rec)
+	}
+
+	buf.Reset()
+	level.Set(slog.LevelDebug) // as the SIGUSR1 toggle does
+	logger.Debug("now visible")
+	if !strings.Contains(buf.String(), `"msg":"now visible"`) {
+		t.Errorf("debug record after level change = %q", buf.String())
+	}
+
+	buf.Reset()
+	logger, _ = newLogger(&buf, "info", "")
+	logger.Info("plain", "stream_id", "mic")
+	if got := buf.String(); !strings.Contains(got, "msg=plain stream_id=mic") {
+		t.Errorf("text log line = %q", got)
+	}
+}
+
+func TestStubEngineFactory(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "script.json")
+	if err := os.WriteFile(path, []byte(`{"steps": [{"frames": 1, "confidence": 0.7}]}`), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	newEngine, err := stubEngineFactory(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	results, err := newEngine().ProcessChunk(make([]byte, 640), 16000)
+	if err != nil || len(results) != 1 || results[0].Confidence != 0.7 {
+		t.Errorf("scripted stub results = %v, %v; want the script's 0.7", results, err)
+	}
+
+	bad := filepath.Join(dir, "bad.json")
+	if err := os.WriteFile(bad, []byte(`{"steps": []}`), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	for _, p := range []string{bad, filepath.Join(dir, "missing.json")} {
+		if _, err := stubEngineFactory(p); err == nil {
+			t.Errorf("%s: expected error", p)
+		}
+	}
+}
+
+func TestSelfCheckOptionsThreads(t *testing.T) {
+	for _, tc := range []struct {
+		engine string
+		intra  int
+		want   int
+	}{
+		{config.EngineSilero, 4, 4},
+		{config.EngineSilero, 0, config.DefaultORTThreads},
+		{config.EngineStub, 4, 1},
+		{config.EngineEnergy, 0, 1},
+	} {
+		cfg := config.Config{MaxStreams: 8, ORT: config.ORT{IntraOpThreads: tc.intra}}
+		opts := selfCheckOptions(tc.engine, cfg)
+		if opts.InferenceThreads != tc.want || opts.MaxStreams != 8 {
+			t.Errorf("%s with intra_op_threads %d: %+v, want %d threads", tc.engine, tc.intra, opts, tc.want)
+		}
+	}
+}
@@ -0,0 +1,48 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"expvar"
+	"log/slog"
+	"net"
+	"net/http"
+	"time"
+
+	"github.com/nupi-ai/plugin-vad-local-silero/internal/metrics"
+)
+
+// metricsVar is the expvar name of the adapter's counters.
+const metricsVar = "vad"
+
+// serveMetrics publishes reg through expvar and serves it, together with the
+// runtime's memstats and cmdline, at /debug/vars on addr until ctx is done.
+// It needs nothing beyond the standard library, so every build has at least
+// this much numeric observability.
+func serveMetrics(ctx context.Context, addr string, reg *metrics.Registry, logger *slog.Logger) error {
+	lis, err := net.Listen("tcp", addr)
+	if err != nil {
+		return err
+	}
+	expvar.Publish(metricsVar, reg.Var())
+
+	mux := http.NewServeMux()
+	mux.Handle("GET /debug/vars", expvar.Handler())
+	srv := &http.Server{
+		Handler:           mux,
+		ReadHeaderTimeout: 5 * time.Second,
+	}
+	go func() {
+		if err := srv.Serve(lis); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			logger.Error("metrics listener failed", "error", err)
+		}
+	}()
+	go func() {
+		<-ctx.Done()
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), time.Second)
+		defer cancel()
+		srv.Shutdown(shutdownCtx)
+	}()
+	logger.Info("metrics listener started", "addr", lis.Addr().String(), "path", "/debug/vars")
+	return nil
+}
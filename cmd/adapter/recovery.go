@@ -0,0 +1,66 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"runtime/debug"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	"github.com/nupi-ai/plugin-vad-local-silero/internal/metrics"
+)
+
+// panicRecovery turns a panic in an RPC handler, e.g. in engine or boundary
+// code, into an Internal status on that call. Without it the panic would
+// crash the process and every other stream with it. Handler defers still
+// run while the panic unwinds, so the stream's engine is closed as usual.
+type panicRecovery struct {
+	panics *metrics.Counter
+	log    *slog.Logger
+}
+
+func newPanicRecovery(reg *metrics.Registry, logger *slog.Logger) *panicRecovery {
+	return &panicRecovery{panics: reg.Counter("vad_panics_total"), log: logger}
+}
+
+// recovered logs the panic value p of fullMethod with its stack and returns
+// the status the caller gets.
+func (r *panicRecovery) recovered(fullMethod string, p any) error {
+	r.panics.Inc()
+	r.log.Error("recovered from panic in RPC handler",
+		"method", fullMethod,
+		"panic", fmt.Sprint(p),
+		"stack", string(debug.Stack()),
+	)
+	return status.Error(codes.Internal, "internal error, the call was aborted")
+}
+
+func (r *panicRecovery) unary(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (resp any, err error) {
+	defer func() {
+		if p := recover(); p != nil {
+			resp, err = nil, r.recovered(info.FullMethod, p)
+		}
+	}()
+	return handler(ctx, req)
+}
+
+func (r *panicRecovery) stream(srv any, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) (err error) {
+	defer func() {
+		if p := recover(); p != nil {
+			err = r.recovered(info.FullMethod, p)
+		}
+	}()
+	return handler(srv, ss)
+}
+
+// serverOptions returns the recovery interceptors. They must come first in
+// the chain so they also cover the interceptors after them.
+func (r *panicRecovery) serverOptions() []grpc.ServerOption {
+	return []grpc.ServerOption{
+		grpc.ChainUnaryInterceptor(r.unary),
+		grpc.ChainStreamInterceptor(r.stream),
+	}
+}
@@ -0,0 +1,108 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"log/slog"
+	"net"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	napv1 "github.com/nupi-ai/nupi/api/nap/v1"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/status"
+
+	"github.com/nupi-ai/plugin-vad-local-silero/internal/config"
+	"github.com/nupi-ai/plugin-vad-local-silero/internal/engine"
+	"github.com/nupi-ai/plugin-vad-local-silero/internal/metrics"
+	"github.com/nupi-ai/plugin-vad-local-silero/internal/server"
+)
+
+func TestPanicRecoveryIsolatesStream(t *testing.T) {
+	var logs bytes.Buffer
+	reg := metrics.NewRegistry()
+	recovery := newPanicRecovery(reg, slog.New(slog.NewTextHandler(&logs, nil)))
+
+	// The second stream's engine panics on its first chunk, like an engine
+	// bug would.
+	var created atomic.Int32
+	srv := server.New(config.Config{Threshold: 0.5}, nil, func() engine.Engine {
+		if created.Add(1) == 2 {
+			return engine.NewFaultEngine(engine.NewStubEngine(), engine.PanicAfter(0, "engine bug"))
+		}
+		return engine.NewStubEngine()
+	})
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	gs := grpc.NewServer(recovery.serverOptions()...)
+	napv1.RegisterVoiceActivityDetectionServiceServer(gs, srv)
+	go gs.Serve(lis)
+	defer gs.Stop()
+
+	conn, err := grpc.NewClient(lis.Addr().String(), grpc.WithTransportCredentials(insecure.NewCredentials()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	client := napv1.NewVoiceActivityDetectionServiceClient(conn)
+	chunk := &napv1.DetectSpeechRequest{Format: &napv1.AudioFormat{SampleRate: 16000}, PcmData: make([]byte, 640)}
+	open := func() napv1.VoiceActivityDetectionService_DetectSpeechClient {
+		stream, err := client.DetectSpeech(context.Background())
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := stream.Send(chunk); err != nil {
+			t.Fatal(err)
+		}
+		return stream
+	}
+	finish := func(stream napv1.VoiceActivityDetectionService_DetectSpeechClient) error {
+		stream.CloseSend()
+		for {
+			if _, err := stream.Recv(); err != nil {
+				return err
+			}
+		}
+	}
+
+	// The first stream stays open across the second one's panic.
+	concurrent := open()
+	for active := srv.ListActiveStreams(); len(active) == 0 || active[0].Frames == 0; active = srv.ListActiveStreams() {
+		time.Sleep(time.Millisecond)
+	}
+	if err := finish(open()); status.Code(err) != codes.Internal {
+		t.Errorf("panicking stream: err = %v, want Internal", err)
+	}
+	if err := concurrent.Send(chunk); err != nil {
+		t.Fatalf("concurrent stream after the panic: %v", err)
+	}
+	if err := finish(concurrent); err != io.EOF {
+		t.Errorf("concurrent stream: err = %v, want clean EOF", err)
+	}
+	// The process survived and serves the next stream.
+	if err := finish(open()); err != io.EOF {
+		t.Errorf("next stream: err = %v, want clean EOF", err)
+	}
+	if got := reg.Counter("vad_panics_total").Value(); got != 1 {
+		t.Errorf("vad_panics_total = %d, want 1", got)
+	}
+	if !strings.Contains(logs.String(), "engine bug") || !strings.Contains(logs.String(), "ProcessChunk") {
+		t.Errorf("log lacks the panic value or stack:\n%s", logs.String())
+	}
+}
+
+func TestPanicRecoveryUnary(t *testing.T) {
+	recovery := newPanicRecovery(metrics.NewRegistry(), slog.New(slog.NewTextHandler(io.Discard, nil)))
+	resp, err := recovery.unary(context.Background(), nil, &grpc.UnaryServerInfo{FullMethod: "/test/Panic"},
+		func(context.Context, any) (any, error) { panic("boom") })
+	if resp != nil || status.Code(err) != codes.Internal {
+		t.Errorf("got %v, %v, want nil and Internal", resp, err)
+	}
+}
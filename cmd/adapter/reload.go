@@ -0,0 +1,89 @@
+package main
+
+import (
+	"log/slog"
+	"reflect"
+
+	"github.com/nupi-ai/plugin-vad-local-silero/internal/config"
+	"github.com/nupi-ai/plugin-vad-local-silero/internal/metrics"
+)
+
+// tunableServer is the part of the VAD server a config reload updates.
+type tunableServer interface {
+	Config() config.Config
+	SetVADParams(config.VADParams) error
+}
+
+// configReloader reloads the config when NUPI_ADAPTER_CONFIG_WATCH sees the
+// config file change. It runs the full loader, so the environment still
+// overrides the file, and applies the VAD parameters to new streams; other
+// options need a restart and only get a warning. A file that fails to load
+// or validate is logged and leaves the running config unchanged.
+type configReloader struct {
+	load     func() (config.LoadResult, error)
+	srv      tunableServer
+	reloads  *metrics.Counter
+	failures *metrics.Counter
+	log      *slog.Logger
+	// reloaded, when set, receives the provenance of every config whose VAD
+	// parameters are in effect after a reload.
+	reloaded func(config.Provenance)
+}
+
+func newConfigReloader(load func() (config.LoadResult, error), srv tunableServer, reg *metrics.Registry, logger *slog.Logger) *configReloader {
+	return &configReloader{
+		load:     load,
+		srv:      srv,
+		reloads:  reg.Counter("vad_config_reloads_total", "result", "ok"),
+		failures: reg.Counter("vad_config_reloads_total", "result", "error"),
+		log:      logger,
+	}
+}
+
+// reload loads the config and applies its VAD parameters.
+func (r *configReloader) reload() {
+	result, err := r.load()
+	if err != nil {
+		r.failures.Inc()
+		r.log.Error("config file reload failed, keeping the running config", "error", err)
+		return
+	}
+	for _, w := range result.Warnings {
+		r.log.Warn(w)
+	}
+	next := result.Config
+	current := r.srv.Config()
+
+	// Anything that still differs once the VAD parameters are equal needs
+	// a restart.
+	restartOnly := current
+	next.TunableParams().Apply(&restartOnly)
+	if !reflect.DeepEqual(restartOnly, next) {
+		r.log.Warn("config file changes options other than the VAD parameters, they take effect after a restart")
+	}
+	if reflect.DeepEqual(restartOnly, current) {
+		r.reloads.Inc()
+		r.notify(result.Provenance)
+		r.log.Debug("config file reloaded, VAD parameters unchanged")
+		return
+	}
+	if err := r.srv.SetVADParams(next.TunableParams()); err != nil {
+		r.failures.Inc()
+		r.log.Error("config file reload failed, keeping the running config", "error", err)
+		return
+	}
+	r.reloads.Inc()
+	r.notify(result.Provenance)
+	r.log.Info("config file reloaded, new streams use the updated VAD parameters",
+		"threshold", next.Threshold,
+		"neg_threshold", next.NegThreshold,
+		"min_speech_duration_ms", next.MinSpeechDurationMs,
+		"min_silence_duration_ms", next.MinSilenceDurationMs,
+	)
+}
+
+func (r *configReloader) notify(p config.Provenance) {
+	if r.reloaded != nil {
+		r.reloaded(p)
+	}
+}
@@ -0,0 +1,76 @@
+package main
+
+import (
+	"bytes"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/nupi-ai/plugin-vad-local-silero/internal/config"
+	"github.com/nupi-ai/plugin-vad-local-silero/internal/engine"
+	"github.com/nupi-ai/plugin-vad-local-silero/internal/metrics"
+	"github.com/nupi-ai/plugin-vad-local-silero/internal/server"
+)
+
+func TestConfigReload(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "vad.yaml")
+	write := func(content string) {
+		t.Helper()
+		if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+			t.Fatal(err)
+		}
+	}
+	write("threshold: 0.5\nmin_gap_ms: 100\n")
+	env := map[string]string{"NUPI_VAD_ENGINE": "stub", "NUPI_ADAPTER_CONFIG_FILE": path, "NUPI_VAD_MIN_GAP_MS": "150"}
+	loader := config.Loader{Lookup: func(key string) (string, bool) {
+		v, ok := env[key]
+		return v, ok
+	}}
+	loaded, err := loader.Load()
+	if err != nil {
+		t.Fatal(err)
+	}
+	srv := server.New(loaded.Config, nil, func() engine.Engine { return engine.NewStubEngine() })
+	reg := metrics.NewRegistry()
+	var logs bytes.Buffer
+	r := newConfigReloader(loader.Load, srv, reg, slog.New(slog.NewTextHandler(&logs, nil)))
+	var sources []config.Provenance
+	r.reloaded = func(p config.Provenance) { sources = append(sources, p) }
+
+	write("threshold: 0.7\nmin_gap_ms: 300\n")
+	r.reload()
+	if got := srv.Config(); got.Threshold != 0.7 || got.MinGapMs != 150 {
+		t.Errorf("after reload: threshold %v, min_gap_ms %d, want 0.7 and the env's 150", got.Threshold, got.MinGapMs)
+	}
+	if len(sources) != 1 || sources[0]["threshold"] != config.SourceFile || sources[0]["min_gap_ms"] != "NUPI_VAD_MIN_GAP_MS" {
+		t.Errorf("reloaded provenance = %v, want threshold from the file and min_gap_ms from the env", sources)
+	}
+	if strings.Contains(logs.String(), "after a restart") {
+		t.Errorf("restart warning for a VAD-only change: %s", logs.String())
+	}
+
+	// Non-tunable options are reported, not applied.
+	write("threshold: 0.7\nmax_streams: 8\n")
+	r.reload()
+	if got := srv.Config().MaxStreams; got != 0 {
+		t.Errorf("max_streams = %d after reload, want it unchanged", got)
+	}
+	if !strings.Contains(logs.String(), "take effect after a restart") {
+		t.Errorf("no restart warning for max_streams: %s", logs.String())
+	}
+
+	// An invalid file keeps the running config.
+	write("threshold: 7\n")
+	r.reload()
+	if got := srv.Config().Threshold; got != 0.7 {
+		t.Errorf("threshold = %v after an invalid reload, want 0.7", got)
+	}
+	if ok, failed := reg.Counter("vad_config_reloads_total", "result", "ok").Value(), reg.Counter("vad_config_reloads_total", "result", "error").Value(); ok != 2 || failed != 1 {
+		t.Errorf("vad_config_reloads_total ok=%d error=%d, want 2 and 1", ok, failed)
+	}
+	if len(sources) != 2 {
+		t.Errorf("provenance reported for %d reloads, want the 2 that succeeded", len(sources))
+	}
+}
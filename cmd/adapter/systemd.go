@@ -0,0 +1,42 @@
+package main
+
+import (
+	"context"
+	"log/slog"
+	"time"
+
+	healthgrpc "google.golang.org/grpc/health/grpc_health_v1"
+
+	"github.com/nupi-ai/plugin-vad-local-silero/internal/sdnotify"
+)
+
+// notifySystemd sends states to systemd when the adapter runs as a
+// Type=notify unit; failures are logged, never fatal.
+func notifySystemd(n *sdnotify.Notifier, logger *slog.Logger, states ...string) {
+	if err := n.Notify(states...); err != nil {
+		logger.Warn("systemd notification failed", "states", states, "error", err)
+	}
+}
+
+// runSystemdWatchdog sends WATCHDOG=1 every interval until ctx is done, as
+// long as the health service answers. A wedged process stops answering and
+// stops pinging, and systemd restarts it after WatchdogSec.
+func runSystemdWatchdog(ctx context.Context, n *sdnotify.Notifier, interval time.Duration, health healthgrpc.HealthServer, logger *slog.Logger) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+		checkCtx, cancel := context.WithTimeout(ctx, interval)
+		_, err := health.Check(checkCtx, &healthgrpc.HealthCheckRequest{})
+		cancel()
+		if err != nil {
+			logger.Warn("health check failed, skipping systemd watchdog ping", "error", err)
+			continue
+		}
+		notifySystemd(n, logger, sdnotify.Watchdog)
+	}
+}
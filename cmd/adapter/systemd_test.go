@@ -0,0 +1,50 @@
+package main
+
+import (
+	"context"
+	"io"
+	"log/slog"
+	"net"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"google.golang.org/grpc/health"
+
+	"github.com/nupi-ai/plugin-vad-local-silero/internal/sdnotify"
+)
+
+func TestSystemdWatchdogPings(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "notify.sock")
+	conn, err := net.ListenUnixgram("unixgram", &net.UnixAddr{Name: path, Net: "unixgram"})
+	if err != nil {
+		t.Skipf("unixgram sockets unavailable: %v", err)
+	}
+	defer conn.Close()
+	n := sdnotify.New(func(key string) (string, bool) {
+		if key == "NOTIFY_SOCKET" {
+			return path, true
+		}
+		return "", false
+	})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		runSystemdWatchdog(ctx, n, 10*time.Millisecond, health.NewServer(), slog.New(slog.NewTextHandler(io.Discard, nil)))
+		close(done)
+	}()
+	buf := make([]byte, 64)
+	for i := 0; i < 2; i++ {
+		conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+		m, err := conn.Read(buf)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := string(buf[:m]); got != sdnotify.Watchdog {
+			t.Errorf("datagram = %q, want %q", got, sdnotify.Watchdog)
+		}
+	}
+	cancel()
+	<-done
+}
@@ -0,0 +1,54 @@
+package main
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"os"
+
+	"google.golang.org/grpc/credentials"
+
+	"github.com/nupi-ai/plugin-vad-local-silero/internal/config"
+)
+
+// serverTLSConfig loads the certificate and, with tls_client_ca_file, the
+// client CA bundle configured in cfg. Clients must then present a certificate
+// chaining to one of those CAs, so only the orchestrator holding such a
+// certificate can connect when the port is reachable from a shared network.
+// Files are read once; replacing them takes a restart.
+func serverTLSConfig(cfg config.Config) (*tls.Config, error) {
+	cert, err := tls.LoadX509KeyPair(cfg.TLSCertFile, cfg.TLSKeyFile)
+	if err != nil {
+		return nil, fmt.Errorf("tls: load certificate: %w", err)
+	}
+	tlsCfg := &tls.Config{
+		Certificates: []tls.Certificate{cert},
+		MinVersion:   tls.VersionTLS12,
+	}
+	if cfg.TLSClientCAFile != "" {
+		pem, err := os.ReadFile(cfg.TLSClientCAFile)
+		if err != nil {
+			return nil, fmt.Errorf("tls: read client CA bundle: %w", err)
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("tls: no PEM certificates in client CA bundle %s", cfg.TLSClientCAFile)
+		}
+		tlsCfg.ClientCAs = pool
+		tlsCfg.ClientAuth = tls.RequireAndVerifyClientCert
+	}
+	return tlsCfg, nil
+}
+
+// serverCredentials returns the gRPC transport credentials of cfg, nil when
+// TLS is disabled.
+func serverCredentials(cfg config.Config) (credentials.TransportCredentials, error) {
+	if !cfg.TLSEnabled() {
+		return nil, nil
+	}
+	tlsCfg, err := serverTLSConfig(cfg)
+	if err != nil {
+		return nil, err
+	}
+	return credentials.NewTLS(tlsCfg), nil
+}
@@ -0,0 +1,165 @@
+package main
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/health"
+	healthgrpc "google.golang.org/grpc/health/grpc_health_v1"
+
+	"github.com/nupi-ai/plugin-vad-local-silero/internal/config"
+)
+
+// testCert issues a certificate for name, signed by parent (self-signed when
+// nil), and returns it with its key.
+func testCert(t *testing.T, name string, parent *tls.Certificate, isCA bool) tls.Certificate {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tmpl := &x509.Certificate{
+		SerialNumber:          big.NewInt(time.Now().UnixNano()),
+		Subject:               pkix.Name{CommonName: name},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		IsCA:                  isCA,
+		BasicConstraintsValid: true,
+		ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth, x509.ExtKeyUsageClientAuth},
+		IPAddresses:           []net.IP{net.IPv4(127, 0, 0, 1)},
+	}
+	if isCA {
+		tmpl.KeyUsage = x509.KeyUsageCertSign
+	}
+	signer, signerKey := tmpl, any(key)
+	if parent != nil {
+		signer, signerKey = parent.Leaf, parent.PrivateKey
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, signer, &key.PublicKey, signerKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	leaf, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return tls.Certificate{Certificate: [][]byte{der}, PrivateKey: key, Leaf: leaf}
+}
+
+// writePEM writes cert and, when keyPath is set, its key as PEM files.
+func writePEM(t *testing.T, cert tls.Certificate, certPath, keyPath string) {
+	t.Helper()
+	if err := os.WriteFile(certPath, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: cert.Certificate[0]}), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if keyPath == "" {
+		return
+	}
+	der, err := x509.MarshalPKCS8PrivateKey(cert.PrivateKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(keyPath, pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der}), 0o600); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestServerMutualTLS(t *testing.T) {
+	dir := t.TempDir()
+	ca := testCert(t, "orchestrator CA", nil, true)
+	serverCert := testCert(t, "vad", &ca, false)
+	client := testCert(t, "orchestrator", &ca, false)
+	rogue := testCert(t, "rogue", nil, false)
+
+	cfg := config.Config{
+		TLSCertFile:     filepath.Join(dir, "server.pem"),
+		TLSKeyFile:      filepath.Join(dir, "server.key"),
+		TLSClientCAFile: filepath.Join(dir, "ca.pem"),
+	}
+	writePEM(t, serverCert, cfg.TLSCertFile, cfg.TLSKeyFile)
+	writePEM(t, ca, cfg.TLSClientCAFile, "")
+
+	creds, err := serverCredentials(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	srv := grpc.NewServer(grpc.Creds(creds))
+	healthgrpc.RegisterHealthServer(srv, health.NewServer())
+	go srv.Serve(lis)
+	defer srv.Stop()
+
+	roots := x509.NewCertPool()
+	roots.AddCert(ca.Leaf)
+	check := func(certs ...tls.Certificate) error {
+		conn, err := grpc.NewClient(lis.Addr().String(), grpc.WithTransportCredentials(
+			credentials.NewTLS(&tls.Config{RootCAs: roots, Certificates: certs})))
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer conn.Close()
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		_, err = healthgrpc.NewHealthClient(conn).Check(ctx, &healthgrpc.HealthCheckRequest{})
+		return err
+	}
+	if err := check(client); err != nil {
+		t.Errorf("client certificate signed by the CA: %v", err)
+	}
+	if err := check(); err == nil {
+		t.Error("connection without a client certificate accepted")
+	}
+	if err := check(rogue); err == nil {
+		t.Error("client certificate from another CA accepted")
+	}
+}
+
+func TestServerCredentialsErrors(t *testing.T) {
+	dir := t.TempDir()
+	if creds, err := serverCredentials(config.Config{}); creds != nil || err != nil {
+		t.Errorf("TLS disabled: got %v, %v", creds, err)
+	}
+
+	cert := testCert(t, "vad", nil, false)
+	cfg := config.Config{
+		TLSCertFile: filepath.Join(dir, "server.pem"),
+		TLSKeyFile:  filepath.Join(dir, "server.key"),
+	}
+	writePEM(t, cert, cfg.TLSCertFile, cfg.TLSKeyFile)
+	tlsCfg, err := serverTLSConfig(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if tlsCfg.ClientAuth != tls.NoClientCert {
+		t.Errorf("ClientAuth = %v without a client CA", tlsCfg.ClientAuth)
+	}
+
+	cfg.TLSClientCAFile = filepath.Join(dir, "empty.pem")
+	if err := os.WriteFile(cfg.TLSClientCAFile, []byte("not a certificate"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := serverTLSConfig(cfg); err == nil {
+		t.Error("client CA bundle without certificates accepted")
+	}
+	cfg.TLSKeyFile = filepath.Join(dir, "missing.key")
+	if _, err := serverTLSConfig(cfg); err == nil {
+		t.Error("missing key file accepted")
+	}
+}
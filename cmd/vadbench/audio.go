@@ -0,0 +1,80 @@
+package main
+
+import (
+	"encoding/binary"
+	"fmt"
+	"math"
+	"math/rand/v2"
+	"os"
+	"time"
+
+	"github.com/nupi-ai/plugin-vad-local-silero/internal/audio/decode"
+	"github.com/nupi-ai/plugin-vad-local-silero/internal/engine"
+)
+
+// Synthetic audio alternates voiced bursts with quiet pauses, so every
+// stream produces a steady flow of START, ONGOING and END events.
+const (
+	synthSpeech = 1200 * time.Millisecond
+	synthPause  = 800 * time.Millisecond
+)
+
+// synthetic returns d of speech-like 16 kHz mono s16le PCM: a 140 Hz
+// harmonic tone modulated at a syllable rate of 4 Hz, then a pause of faint
+// noise, repeated.
+func synthetic(d time.Duration) []byte {
+	rate := time.Duration(engine.ExpectedSampleRate)
+	n := int(d * rate / time.Second)
+	pcm := make([]byte, 2*n)
+	period := synthSpeech + synthPause
+	noise := rand.New(rand.NewPCG(1, 2))
+	for i := range n {
+		t := time.Duration(i) * time.Second / rate
+		phase := t % period
+		var v float64
+		if phase < synthSpeech {
+			sec := t.Seconds()
+			for h := 1.0; h <= 8; h++ {
+				v += math.Sin(2*math.Pi*140*h*sec) / h
+			}
+			v *= 0.25 * (0.6 + 0.4*math.Sin(2*math.Pi*4*sec))
+		}
+		v += 0.002 * noise.NormFloat64()
+		binary.LittleEndian.PutUint16(pcm[2*i:], uint16(int16(max(-1, min(1, v))*math.MaxInt16)))
+	}
+	return pcm
+}
+
+// loadInput decodes the WAV file at path to mono 16-bit PCM, resampling it
+// to 16 kHz at a rate the engines do not take natively.
+func loadInput(path string) ([]byte, uint32, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, 0, err
+	}
+	a, err := decode.File(data)
+	if err != nil {
+		return nil, 0, fmt.Errorf("%s: %w", path, err)
+	}
+	if a.SampleRate != engine.ExpectedSampleRate && a.SampleRate != engine.TelephonySampleRate {
+		if a, err = a.Resample(engine.ExpectedSampleRate); err != nil {
+			return nil, 0, fmt.Errorf("%s: %w", path, err)
+		}
+	}
+	if len(a.PCM) < 2 {
+		return nil, 0, fmt.Errorf("%s: no audio", path)
+	}
+	return a.PCM, a.SampleRate, nil
+}
+
+// loop repeats pcm, starting at sample offset, to fill d at rate.
+func loop(pcm []byte, rate uint32, d time.Duration, offset int) []byte {
+	out := make([]byte, 2*int(d*time.Duration(rate)/time.Second))
+	pos := (2 * offset) % len(pcm)
+	for i := 0; i < len(out); {
+		copied := copy(out[i:], pcm[pos:])
+		i += copied
+		pos = (pos + copied) % len(pcm)
+	}
+	return out
+}
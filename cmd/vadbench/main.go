@@ -0,0 +1,265 @@
+// Command vadbench load-tests a running adapter: it opens concurrent
+// DetectSpeech streams, pushes synthetic or recorded audio at a chosen
+// multiple of real time and reports event latency percentiles, throughput
+// and how busy the server's engines were:
+//
+//	go run ./cmd/vadbench --addr localhost:50051 --streams 50 --duration 60s
+//	go run ./cmd/vadbench --addr localhost:50051 --streams 8 --speed 0 --input call.wav
+//
+// See the README's "vadbench" section for what each figure measures.
+package main
+
+import (
+	"context"
+	"crypto/tls"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"strings"
+	"sync"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/protobuf/types/known/structpb"
+
+	napv1 "github.com/nupi-ai/nupi/api/nap/v1"
+
+	"github.com/nupi-ai/plugin-vad-local-silero/internal/admin"
+	"github.com/nupi-ai/plugin-vad-local-silero/internal/engine"
+)
+
+// options is the parsed command line.
+type options struct {
+	addr     string
+	streams  int
+	duration time.Duration
+	input    string
+	speed    float64
+	chunkMs  int
+	ramp     time.Duration
+	session  string
+	config   string
+	token    string
+	tls      bool
+	caFile   string
+	json     bool
+}
+
+func main() {
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+	os.Exit(run(ctx, os.Args[1:], os.Stdout, os.Stderr))
+}
+
+func run(ctx context.Context, args []string, stdout, stderr io.Writer) int {
+	opts, err := parseArgs(args, stderr)
+	if errors.Is(err, flag.ErrHelp) {
+		return 0
+	}
+	if err != nil {
+		return 2
+	}
+
+	// Streams loop over one recording. Synthetic audio is made a period
+	// longer than a stream, so every start offset fits without wrapping.
+	var (
+		input []byte
+		rate  = engine.ExpectedSampleRate
+	)
+	if opts.input != "" {
+		if input, rate, err = loadInput(opts.input); err != nil {
+			fmt.Fprintf(stderr, "vadbench: %v\n", err)
+			return 1
+		}
+	} else {
+		input = synthetic(opts.duration + synthSpeech + synthPause)
+	}
+	conn, err := dial(opts)
+	if err != nil {
+		fmt.Fprintf(stderr, "vadbench: %v\n", err)
+		return 1
+	}
+	defer conn.Close()
+	client := napv1.NewVoiceActivityDetectionServiceClient(conn)
+
+	results := make([]streamResult, opts.streams)
+	var wg sync.WaitGroup
+	start := time.Now()
+	for i := range opts.streams {
+		// Each stream starts at a different point of the audio, so they do
+		// not switch between speech and silence in lockstep.
+		offset := (time.Duration(i) * 137 * time.Millisecond) % (synthSpeech + synthPause)
+		pcm := loop(input, rate, opts.duration, int(offset*time.Duration(rate)/time.Second))
+		delay := time.Duration(0)
+		if opts.streams > 1 {
+			delay = opts.ramp * time.Duration(i) / time.Duration(opts.streams-1)
+		}
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			select {
+			case <-ctx.Done():
+				results[i] = streamResult{err: ctx.Err()}
+				return
+			case <-time.After(delay):
+			}
+			results[i] = runStream(ctx, client, opts, fmt.Sprintf("bench-%04d", i), pcm, rate)
+		}()
+	}
+	wg.Wait()
+	rep := summarize(opts, results, time.Since(start))
+	rep.Server = serverLoad(ctx, conn, opts, rep.WallSeconds)
+
+	if opts.json {
+		enc := json.NewEncoder(stdout)
+		enc.SetIndent("", "  ")
+		enc.Encode(rep)
+	} else {
+		rep.write(stdout)
+	}
+	if rep.Failed > 0 {
+		return 1
+	}
+	return 0
+}
+
+func parseArgs(args []string, stderr io.Writer) (options, error) {
+	var opts options
+	fs := flag.NewFlagSet("vadbench", flag.ContinueOnError)
+	fs.SetOutput(stderr)
+	fs.Usage = func() {
+		fmt.Fprintf(stderr, "Usage: vadbench --addr host:port [flags]\n\nFlags:\n")
+		fs.PrintDefaults()
+	}
+	fs.StringVar(&opts.addr, "addr", "", "adapter `host:port` (required)")
+	fs.IntVar(&opts.streams, "streams", 10, "concurrent streams")
+	fs.DurationVar(&opts.duration, "duration", 30*time.Second, "audio per stream")
+	fs.StringVar(&opts.input, "input", "", "WAV `file` to stream, looped to --duration (default synthetic speech)")
+	fs.Float64Var(&opts.speed, "speed", 1, "pace audio at this `factor` of real time, 0 = as fast as possible")
+	fs.IntVar(&opts.chunkMs, "chunk-ms", 20, "audio per request, `ms`")
+	fs.DurationVar(&opts.ramp, "ramp", 0, "spread stream starts over this long")
+	fs.StringVar(&opts.session, "session", fmt.Sprintf("vadbench-%d", time.Now().Unix()), "session `id` shared by the streams")
+	fs.StringVar(&opts.config, "config", "", "extra per-stream config_json `object`, e.g. '{\"threshold\": 0.6}'")
+	fs.StringVar(&opts.token, "token", os.Getenv("NUPI_ADAPTER_AUTH_TOKEN"), "API `token` (default $NUPI_ADAPTER_AUTH_TOKEN)")
+	fs.BoolVar(&opts.tls, "tls", false, "connect over TLS, verifying the server against the system roots")
+	fs.StringVar(&opts.caFile, "ca", "", "connect over TLS, verifying the server against this CA `file`")
+	fs.BoolVar(&opts.json, "json", false, "print the report as JSON")
+	if err := fs.Parse(args); err != nil {
+		return options{}, err
+	}
+
+	fail := func(format string, a ...any) (options, error) {
+		err := fmt.Errorf(format, a...)
+		fmt.Fprintf(stderr, "vadbench: %v\n", err)
+		fs.Usage()
+		return options{}, err
+	}
+	switch {
+	case fs.NArg() > 0:
+		return fail("unexpected argument %q", fs.Arg(0))
+	case opts.addr == "":
+		return fail("--addr is required")
+	case opts.streams < 1:
+		return fail("--streams must be at least 1, got %d", opts.streams)
+	case opts.duration < time.Duration(opts.chunkMs)*time.Millisecond:
+		return fail("--duration must be at least one chunk, got %v", opts.duration)
+	case opts.chunkMs < 1 || opts.chunkMs > 1000:
+		return fail("--chunk-ms must be in [1, 1000], got %d", opts.chunkMs)
+	case opts.speed < 0:
+		return fail("--speed must not be negative, got %v", opts.speed)
+	case opts.ramp < 0:
+		return fail("--ramp must not be negative, got %v", opts.ramp)
+	}
+	cfg, err := mergeConfig(benchConfig, opts.config)
+	if err != nil {
+		return fail("--config: %v", err)
+	}
+	opts.config = cfg
+	return opts, nil
+}
+
+// mergeConfig returns the config_json object base with the fields of extra
+// added; extra's override base's.
+func mergeConfig(base, extra string) (string, error) {
+	fields := map[string]json.RawMessage{}
+	if err := json.Unmarshal([]byte(base), &fields); err != nil {
+		return "", err
+	}
+	if strings.TrimSpace(extra) != "" {
+		if err := json.Unmarshal([]byte(extra), &fields); err != nil {
+			return "", err
+		}
+	}
+	raw, err := json.Marshal(fields)
+	return string(raw), err
+}
+
+func dial(opts options) (*grpc.ClientConn, error) {
+	creds := insecure.NewCredentials()
+	switch {
+	case opts.caFile != "":
+		c, err := credentials.NewClientTLSFromFile(opts.caFile, "")
+		if err != nil {
+			return nil, err
+		}
+		creds = c
+	case opts.tls:
+		creds = credentials.NewTLS(&tls.Config{MinVersion: tls.VersionTLS12})
+	}
+	return grpc.NewClient(opts.addr, grpc.WithTransportCredentials(creds))
+}
+
+// serverLoad reads the streams' summaries from the admin API and totals the
+// engine time they took. When the admin API cannot be used, the report
+// carries the error instead.
+func serverLoad(ctx context.Context, cc grpc.ClientConnInterface, opts options, wallSeconds float64) *serverReport {
+	if opts.token != "" {
+		ctx = metadata.AppendToOutgoingContext(ctx, "authorization", "Bearer "+opts.token)
+	}
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+	req, _ := structpb.NewStruct(map[string]any{"session_id": opts.session, "limit": float64(opts.streams)})
+	resp, err := admin.ListRecentStreams(ctx, cc, req)
+	if err != nil {
+		return &serverReport{Error: err.Error()}
+	}
+	var (
+		rep         serverReport
+		engineMs    float64
+		audioMs     float64
+		meanSum     float64
+		withEngines int
+	)
+	for _, v := range resp.GetFields()["streams"].GetListValue().GetValues() {
+		f := v.GetStructValue().GetFields()
+		rep.Streams++
+		inference := f["inference"].GetStructValue().GetFields()
+		if inference == nil {
+			continue
+		}
+		withEngines++
+		mean := inference["mean_ms"].GetNumberValue()
+		meanSum += mean
+		engineMs += mean * f["chunks"].GetNumberValue()
+		audioMs += f["audio_duration_ms"].GetNumberValue()
+		rep.InferenceMaxP95Ms = max(rep.InferenceMaxP95Ms, inference["p95_ms"].GetNumberValue())
+	}
+	if withEngines > 0 {
+		rep.InferenceMeanMs = meanSum / float64(withEngines)
+	}
+	if audioMs > 0 {
+		rep.EngineRTF = engineMs / audioMs
+	}
+	if wallSeconds > 0 && rep.Streams > 0 {
+		// Scaled up when the server's history kept fewer than all streams.
+		rep.EngineCores = engineMs / 1000 / wallSeconds * float64(opts.streams) / float64(rep.Streams)
+	}
+	return &rep
+}
@@ -0,0 +1,136 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"log/slog"
+	"net"
+	"strings"
+	"testing"
+	"time"
+
+	"google.golang.org/grpc"
+
+	napv1 "github.com/nupi-ai/nupi/api/nap/v1"
+
+	"github.com/nupi-ai/plugin-vad-local-silero/internal/admin"
+	"github.com/nupi-ai/plugin-vad-local-silero/internal/config"
+	"github.com/nupi-ai/plugin-vad-local-silero/internal/engine"
+	"github.com/nupi-ai/plugin-vad-local-silero/internal/server"
+)
+
+// startAdapter serves DetectSpeech and, with withAdmin, the admin API with
+// the energy engine on a loopback port and returns its address.
+func startAdapter(t *testing.T, withAdmin bool) string {
+	t.Helper()
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	cfg := config.Config{Threshold: 0.5, MinSpeechDurationMs: 100, MinSilenceDurationMs: 200, RecentStreams: 100}
+	srv := server.New(cfg, slog.New(slog.DiscardHandler), func() engine.Engine { return engine.NewEnergyEngine() })
+	grpcServer := grpc.NewServer()
+	napv1.RegisterVoiceActivityDetectionServiceServer(grpcServer, srv)
+	if withAdmin {
+		svc := admin.New(nil, nil)
+		svc.SetServer(srv)
+		admin.Register(grpcServer, svc)
+	}
+	go grpcServer.Serve(lis)
+	t.Cleanup(grpcServer.Stop)
+	return lis.Addr().String()
+}
+
+func TestRun(t *testing.T) {
+	addr := startAdapter(t, true)
+	var stdout, stderr bytes.Buffer
+	code := run(context.Background(), []string{"--addr", addr, "--streams", "4", "--duration", "4s", "--speed", "0", "--json"}, &stdout, &stderr)
+	if code != 0 {
+		t.Fatalf("exit code %d\n%s%s", code, stdout.String(), stderr.String())
+	}
+	var rep report
+	if err := json.Unmarshal(stdout.Bytes(), &rep); err != nil {
+		t.Fatalf("%v\n%s", err, stdout.String())
+	}
+	if rep.Streams != 4 || rep.Failed != 0 || rep.AudioSeconds != 16 {
+		t.Errorf("streams %d, failed %d, audio %v s; want 4, 0 and 16", rep.Streams, rep.Failed, rep.AudioSeconds)
+	}
+	// Two speech bursts per stream, each with a START, ONGOING events and
+	// an END.
+	if rep.Latency.Events < 4*2*2 || rep.Latency.P50 <= 0 || rep.Latency.Max < rep.Latency.P99 {
+		t.Errorf("latency %+v", rep.Latency)
+	}
+	if s := rep.Server; s == nil || s.Error != "" || s.Streams != 4 || s.EngineRTF <= 0 {
+		t.Errorf("server %+v, want the engine time of 4 streams", s)
+	}
+}
+
+func TestRunWithoutAdmin(t *testing.T) {
+	addr := startAdapter(t, false)
+	var stdout, stderr bytes.Buffer
+	code := run(context.Background(), []string{"--addr", addr, "--streams", "2", "--duration", "1s", "--speed", "0"}, &stdout, &stderr)
+	if code != 0 {
+		t.Fatalf("exit code %d\n%s%s", code, stdout.String(), stderr.String())
+	}
+	for _, want := range []string{"streams     2 (2 ok, 0 failed), as fast as possible", "server      unavailable:"} {
+		if !strings.Contains(stdout.String(), want) {
+			t.Errorf("report lacks %q:\n%s", want, stdout.String())
+		}
+	}
+}
+
+func TestRunStreamErrors(t *testing.T) {
+	addr := startAdapter(t, false)
+	var stdout, stderr bytes.Buffer
+	code := run(context.Background(), []string{"--addr", addr, "--streams", "3", "--duration", "1s", "--speed", "0", "--config", `{"threshold": 2}`}, &stdout, &stderr)
+	if code != 1 || !strings.Contains(stdout.String(), "3 failed") || !strings.Contains(stdout.String(), "error       3×") {
+		t.Errorf("exit code %d, want 1 and 3 identical errors:\n%s", code, stdout.String())
+	}
+}
+
+func TestSendLogAndPercentile(t *testing.T) {
+	base := time.Now()
+	log := &sendLog{chunk: 20 * time.Millisecond}
+	for i := range 3 {
+		log.add(base.Add(time.Duration(i) * time.Second))
+	}
+	// An event at 40 ms closes the second chunk's audio.
+	if at, ok := log.at(40 * time.Millisecond); !ok || !at.Equal(base.Add(time.Second)) {
+		t.Errorf("at(40ms) = %v, %v; want the second chunk", at, ok)
+	}
+	if _, ok := log.at(100 * time.Millisecond); ok {
+		t.Error("at(100ms) found a chunk that was never sent")
+	}
+
+	sorted := []time.Duration{1, 2, 3, 4, 5, 6, 7, 8, 9, 10}
+	for p, want := range map[float64]time.Duration{50: 5, 90: 9, 99: 10, 100: 10, 1: 1} {
+		if got := percentile(sorted, p); got != want {
+			t.Errorf("percentile(%v) = %v, want %v", p, got, want)
+		}
+	}
+	if got := percentile(nil, 50); got != 0 {
+		t.Errorf("percentile of nothing = %v", got)
+	}
+}
+
+func TestParseArgsErrors(t *testing.T) {
+	for _, args := range [][]string{
+		{},
+		{"--addr", "localhost:1", "extra"},
+		{"--addr", "localhost:1", "--streams", "0"},
+		{"--addr", "localhost:1", "--duration", "1ms"},
+		{"--addr", "localhost:1", "--chunk-ms", "0"},
+		{"--addr", "localhost:1", "--speed", "-2"},
+		{"--addr", "localhost:1", "--config", "[]"},
+	} {
+		var stderr bytes.Buffer
+		if code := run(context.Background(), args, &stderr, &stderr); code != 2 {
+			t.Errorf("%q: exit code %d, want 2\n%s", args, code, stderr.String())
+		}
+	}
+	cfg, err := mergeConfig(benchConfig, `{"preroll_ms": 100, "threshold": 0.6}`)
+	if err != nil || cfg != `{"min_gap_ms":0,"preroll_ms":100,"threshold":0.6}` {
+		t.Errorf("mergeConfig = %s, %v", cfg, err)
+	}
+}
@@ -0,0 +1,138 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"slices"
+	"time"
+)
+
+// report is the outcome of a run.
+type report struct {
+	Streams      int     `json:"streams"`
+	Failed       int     `json:"failed"`
+	Speed        float64 `json:"speed"`
+	WallSeconds  float64 `json:"wall_seconds"`
+	AudioSeconds float64 `json:"audio_seconds"`
+	// Throughput is audio processed per second of wall time, across all
+	// streams.
+	Throughput   float64        `json:"throughput"`
+	ChunksPerSec float64        `json:"chunks_per_second"`
+	EventsPerSec float64        `json:"events_per_second"`
+	Latency      latencyReport  `json:"latency"`
+	Errors       map[string]int `json:"errors,omitempty"`
+	Server       *serverReport  `json:"server"`
+}
+
+// latencyReport holds event latency percentiles in milliseconds.
+type latencyReport struct {
+	Events int     `json:"events"`
+	P50    float64 `json:"p50_ms"`
+	P90    float64 `json:"p90_ms"`
+	P99    float64 `json:"p99_ms"`
+	Max    float64 `json:"max_ms"`
+}
+
+// serverReport is the engine load the admin API's stream summaries show.
+type serverReport struct {
+	// Streams is how many of the run's streams the server's history still
+	// held.
+	Streams int `json:"streams"`
+	// EngineCores is the engine time per second of wall time: roughly the
+	// CPU cores the run kept busy with inference.
+	EngineCores float64 `json:"engine_cores"`
+	// EngineRTF is engine time per second of audio.
+	EngineRTF         float64 `json:"engine_rtf"`
+	InferenceMeanMs   float64 `json:"inference_mean_ms"`
+	InferenceMaxP95Ms float64 `json:"inference_max_p95_ms"`
+	Error             string  `json:"error,omitempty"`
+}
+
+func summarize(opts options, results []streamResult, wall time.Duration) report {
+	rep := report{
+		Streams:     len(results),
+		Speed:       opts.speed,
+		WallSeconds: wall.Seconds(),
+	}
+	var (
+		latencies []time.Duration
+		chunks    int
+		events    int
+	)
+	for _, r := range results {
+		if r.err != nil {
+			rep.Failed++
+			if rep.Errors == nil {
+				rep.Errors = map[string]int{}
+			}
+			rep.Errors[r.err.Error()]++
+		}
+		latencies = append(latencies, r.latencies...)
+		chunks += r.chunks
+		events += r.events
+		rep.AudioSeconds += r.audio.Seconds()
+	}
+	if rep.WallSeconds > 0 {
+		rep.Throughput = rep.AudioSeconds / rep.WallSeconds
+		rep.ChunksPerSec = float64(chunks) / rep.WallSeconds
+		rep.EventsPerSec = float64(events) / rep.WallSeconds
+	}
+	slices.Sort(latencies)
+	rep.Latency = latencyReport{
+		Events: len(latencies),
+		P50:    ms(percentile(latencies, 50)),
+		P90:    ms(percentile(latencies, 90)),
+		P99:    ms(percentile(latencies, 99)),
+		Max:    ms(percentile(latencies, 100)),
+	}
+	return rep
+}
+
+// percentile returns the p-th percentile of sorted by the nearest-rank
+// method, or 0 for no samples.
+func percentile(sorted []time.Duration, p float64) time.Duration {
+	if len(sorted) == 0 {
+		return 0
+	}
+	rank := int(p / 100 * float64(len(sorted)))
+	if float64(rank) < p/100*float64(len(sorted)) {
+		rank++
+	}
+	return sorted[max(rank, 1)-1]
+}
+
+func ms(d time.Duration) float64 {
+	return float64(d) / float64(time.Millisecond)
+}
+
+func (r report) write(out io.Writer) {
+	speed := "as fast as possible"
+	if r.Speed > 0 {
+		speed = fmt.Sprintf("%gx real time", r.Speed)
+	}
+	fmt.Fprintf(out, "streams     %d (%d ok, %d failed), %s\n", r.Streams, r.Streams-r.Failed, r.Failed, speed)
+	fmt.Fprintf(out, "wall time   %.1f s\n", r.WallSeconds)
+	fmt.Fprintf(out, "throughput  %.1f s of audio per second, %.0f chunks/s, %.0f events/s\n", r.Throughput, r.ChunksPerSec, r.EventsPerSec)
+	if r.Latency.Events > 0 {
+		fmt.Fprintf(out, "latency     p50 %.1f ms, p90 %.1f ms, p99 %.1f ms, max %.1f ms (%d events)\n",
+			r.Latency.P50, r.Latency.P90, r.Latency.P99, r.Latency.Max, r.Latency.Events)
+	} else {
+		fmt.Fprintln(out, "latency     no events")
+	}
+	switch s := r.Server; {
+	case s == nil:
+	case s.Error != "":
+		fmt.Fprintf(out, "server      unavailable: %s\n", s.Error)
+	default:
+		fmt.Fprintf(out, "server      %.2f engine cores busy, engine time %.3f of audio, inference per chunk mean %.2f ms, worst p95 %.2f ms (%d streams)\n",
+			s.EngineCores, s.EngineRTF, s.InferenceMeanMs, s.InferenceMaxP95Ms, s.Streams)
+	}
+	errs := make([]string, 0, len(r.Errors))
+	for msg := range r.Errors {
+		errs = append(errs, msg)
+	}
+	slices.Sort(errs)
+	for _, msg := range errs {
+		fmt.Fprintf(out, "error       %d× %s\n", r.Errors[msg], msg)
+	}
+}
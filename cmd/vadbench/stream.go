@@ -0,0 +1,149 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"sync"
+	"time"
+
+	"google.golang.org/grpc/metadata"
+
+	napv1 "github.com/nupi-ai/nupi/api/nap/v1"
+
+	"github.com/nupi-ai/plugin-vad-local-silero/internal/server"
+)
+
+// benchConfig is the config_json every stream starts with. Without pre-roll
+// and gap merging each event is stamped with the frame that triggered it,
+// so the time its audio was sent is known.
+const benchConfig = `{"preroll_ms": 0, "min_gap_ms": 0}`
+
+// streamResult is what one stream measured.
+type streamResult struct {
+	// latencies holds, per boundary or ONGOING event, the time from sending
+	// the chunk holding the event's audio to receiving the event.
+	latencies []time.Duration
+	events    int
+	chunks    int
+	audio     time.Duration
+	err       error
+}
+
+// sendLog records when each chunk was sent, for matching events to it.
+type sendLog struct {
+	mu    sync.Mutex
+	sent  []time.Time
+	chunk time.Duration
+}
+
+func (l *sendLog) add(t time.Time) {
+	l.mu.Lock()
+	l.sent = append(l.sent, t)
+	l.mu.Unlock()
+}
+
+// at returns when the chunk holding the audio just before offset was sent.
+func (l *sendLog) at(offset time.Duration) (time.Time, bool) {
+	i := int((offset - 1) / l.chunk)
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if i < 0 || i >= len(l.sent) {
+		return time.Time{}, false
+	}
+	return l.sent[i], true
+}
+
+// runStream streams pcm as one DetectSpeech stream and measures its events.
+func runStream(ctx context.Context, client napv1.VoiceActivityDetectionServiceClient, opts options, streamID string, pcm []byte, rate uint32) streamResult {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	if opts.token != "" {
+		ctx = metadata.AppendToOutgoingContext(ctx, "authorization", "Bearer "+opts.token)
+	}
+	s, err := client.DetectSpeech(ctx)
+	if err != nil {
+		return streamResult{err: err}
+	}
+
+	chunkBytes := int(rate) * opts.chunkMs / 1000 * 2
+	log := &sendLog{chunk: time.Duration(chunkBytes/2) * time.Second / time.Duration(rate)}
+	sent := make(chan error, 1)
+	go func() {
+		sent <- sendAudio(ctx, s, opts, streamID, pcm, rate, chunkBytes, log)
+	}()
+
+	var (
+		res   streamResult
+		epoch time.Time
+	)
+	for {
+		evt, err := s.Recv()
+		received := time.Now()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			res.err = err
+			return res
+		}
+		if epoch.IsZero() {
+			md, err := s.Header()
+			if err != nil {
+				res.err = err
+				return res
+			}
+			var ok bool
+			if epoch, ok = server.ParseAudioEpoch(md); !ok {
+				res.err = fmt.Errorf("response header has no %s", server.AudioEpochHeader)
+				return res
+			}
+		}
+		if evt.GetType() == server.ProbabilityEventType {
+			continue
+		}
+		res.events++
+		if sentAt, ok := log.at(server.AudioOffset(evt, epoch)); ok {
+			res.latencies = append(res.latencies, received.Sub(sentAt))
+		}
+	}
+	if err := <-sent; err != nil {
+		res.err = err
+	}
+	res.chunks = len(log.sent)
+	res.audio = time.Duration(len(pcm)/2) * time.Second / time.Duration(rate)
+	return res
+}
+
+// sendAudio sends pcm in chunkBytes chunks paced at opts.speed and closes
+// the send side.
+func sendAudio(ctx context.Context, s napv1.VoiceActivityDetectionService_DetectSpeechClient, opts options, streamID string, pcm []byte, rate uint32, chunkBytes int, log *sendLog) error {
+	format := &napv1.AudioFormat{Encoding: "pcm_s16le", SampleRate: rate, Channels: 1, BitDepth: 16}
+	start := time.Now()
+	for off := 0; off < len(pcm); off += chunkBytes {
+		req := &napv1.DetectSpeechRequest{
+			SessionId: opts.session,
+			StreamId:  streamID,
+			Format:    format,
+			PcmData:   pcm[off:min(off+chunkBytes, len(pcm))],
+		}
+		if off == 0 {
+			req.ConfigJson = opts.config
+		}
+		log.add(time.Now())
+		if err := s.Send(req); err != nil {
+			// The server's status is reported by Recv.
+			return nil
+		}
+		if opts.speed > 0 {
+			end := time.Duration((off+len(req.PcmData))/2) * time.Second / time.Duration(rate)
+			select {
+			case <-ctx.Done():
+				return ctx.Err()
+			case <-time.After(time.Until(start.Add(time.Duration(float64(end) / opts.speed)))):
+			}
+		}
+	}
+	return s.CloseSend()
+}
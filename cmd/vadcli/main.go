@@ -0,0 +1,348 @@
+// Command vadcli streams a recording to a running adapter over DetectSpeech
+// and prints the speech events it returns, for manual testing without a
+// custom gRPC client:
+//
+//	go run ./cmd/vadcli --addr localhost:50051 recording.wav
+//	go run ./cmd/vadcli --addr localhost:50051 --speed 0 recording.wav
+//	arecord -q -f S16_LE -r 16000 -c 1 | go run ./cmd/vadcli --addr localhost:50051 --speed 0 -
+//
+// A WAV file is decoded (downmixed to mono, converted to 16-bit and, at a
+// rate the engines do not take, resampled to 16 kHz); "-" reads raw mono
+// s16le PCM at --rate from stdin. Audio is sent in --chunk-ms chunks paced
+// at --speed times real time, or as fast as the adapter takes it with
+// --speed 0. Each event prints with its offset into the audio.
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"strings"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/metadata"
+
+	napv1 "github.com/nupi-ai/nupi/api/nap/v1"
+
+	"github.com/nupi-ai/plugin-vad-local-silero/internal/audio/decode"
+	"github.com/nupi-ai/plugin-vad-local-silero/internal/compression"
+	"github.com/nupi-ai/plugin-vad-local-silero/internal/engine"
+	"github.com/nupi-ai/plugin-vad-local-silero/internal/server"
+	"github.com/nupi-ai/plugin-vad-local-silero/internal/timestamp"
+)
+
+// options is the parsed command line.
+type options struct {
+	addr      string
+	input     string
+	rate      uint
+	chunkMs   uint
+	speed     float64
+	session   string
+	stream    string
+	config    string
+	token     string
+	tls       bool
+	caFile    string
+	tsFormat  timestamp.Format
+	showProbs bool
+	compress  string
+}
+
+func main() {
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+	os.Exit(run(ctx, os.Args[1:], os.Stdin, os.Stdout, os.Stderr))
+}
+
+func run(ctx context.Context, args []string, stdin io.Reader, stdout, stderr io.Writer) int {
+	opts, err := parseArgs(args, stderr)
+	if errors.Is(err, flag.ErrHelp) {
+		return 0
+	}
+	if err != nil {
+		return 2
+	}
+
+	pcm, rate, err := openInput(opts, stdin, stderr)
+	if err != nil {
+		fmt.Fprintf(stderr, "vadcli: %v\n", err)
+		return 1
+	}
+	conn, err := dial(opts)
+	if err != nil {
+		fmt.Fprintf(stderr, "vadcli: %v\n", err)
+		return 1
+	}
+	defer conn.Close()
+
+	sum, err := stream(ctx, napv1.NewVoiceActivityDetectionServiceClient(conn), opts, pcm, rate, stdout)
+	if err != nil {
+		fmt.Fprintf(stderr, "vadcli: %v\n", err)
+		return 1
+	}
+	fmt.Fprintf(stdout, "%d speech segments, %.2f s of speech in %.2f s of audio\n",
+		sum.segments, sum.speech.Seconds(), sum.audio.Seconds())
+	return 0
+}
+
+func parseArgs(args []string, stderr io.Writer) (options, error) {
+	var opts options
+	fs := flag.NewFlagSet("vadcli", flag.ContinueOnError)
+	fs.SetOutput(stderr)
+	fs.Usage = func() {
+		fmt.Fprintf(stderr, "Usage: vadcli --addr host:port [flags] file.wav|-\n\nFlags:\n")
+		fs.PrintDefaults()
+	}
+	fs.StringVar(&opts.addr, "addr", "", "adapter `host:port` (required)")
+	fs.UintVar(&opts.rate, "rate", uint(engine.ExpectedSampleRate), "sample rate of raw PCM read from stdin, `Hz`")
+	fs.UintVar(&opts.chunkMs, "chunk-ms", 20, "audio per request, `ms`")
+	fs.Float64Var(&opts.speed, "speed", 1, "pace audio at this `factor` of real time, 0 = as fast as possible")
+	fs.StringVar(&opts.session, "session", "vadcli", "session `id`")
+	fs.StringVar(&opts.stream, "stream", "vadcli", "stream `id`")
+	fs.StringVar(&opts.config, "config", "", "per-stream config_json `object`, e.g. '{\"threshold\": 0.6}'")
+	fs.StringVar(&opts.token, "token", os.Getenv("NUPI_ADAPTER_AUTH_TOKEN"), "API `token` (default $NUPI_ADAPTER_AUTH_TOKEN)")
+	fs.BoolVar(&opts.tls, "tls", false, "connect over TLS, verifying the server against the system roots")
+	fs.StringVar(&opts.caFile, "ca", "", "connect over TLS, verifying the server against this CA `file`")
+	fs.BoolVar(&opts.showProbs, "probabilities", false, "request and print per-frame speech probabilities")
+	fs.StringVar(&opts.compress, "compress", "", "compress requests with gzip or zstd")
+	tsFormat := fs.String("timestamp-format", os.Getenv("NUPI_VAD_TIMESTAMP_FORMAT"),
+		"also print event timestamps as rfc3339, epoch_ms or offset_ms (default $NUPI_VAD_TIMESTAMP_FORMAT)")
+	if err := fs.Parse(args); err != nil {
+		return options{}, err
+	}
+
+	fail := func(format string, a ...any) (options, error) {
+		err := fmt.Errorf(format, a...)
+		fmt.Fprintf(stderr, "vadcli: %v\n", err)
+		fs.Usage()
+		return options{}, err
+	}
+	if fs.NArg() != 1 {
+		return fail("want one input, a WAV file or - for stdin, got %d arguments", fs.NArg())
+	}
+	opts.input = fs.Arg(0)
+	if opts.addr == "" {
+		return fail("--addr is required")
+	}
+	if opts.chunkMs == 0 || opts.chunkMs > 1000 {
+		return fail("--chunk-ms must be in [1, 1000], got %d", opts.chunkMs)
+	}
+	if opts.speed < 0 {
+		return fail("--speed must not be negative, got %v", opts.speed)
+	}
+	if opts.rate == 0 {
+		return fail("--rate must be positive")
+	}
+	if err := compression.Check(opts.compress); err != nil {
+		return fail("--compress: %v", err)
+	}
+	if *tsFormat != "" {
+		f, err := timestamp.Parse(*tsFormat)
+		if err != nil {
+			return fail("--timestamp-format: %v", err)
+		}
+		opts.tsFormat = f
+	}
+	if opts.showProbs {
+		cfg, err := withEmitProbabilities(opts.config)
+		if err != nil {
+			return fail("--config: %v", err)
+		}
+		opts.config = cfg
+	}
+	return opts, nil
+}
+
+// openInput returns the PCM to stream and its sample rate.
+func openInput(opts options, stdin io.Reader, stderr io.Writer) (io.Reader, uint32, error) {
+	if opts.input == "-" {
+		return stdin, uint32(opts.rate), nil
+	}
+	data, err := os.ReadFile(opts.input)
+	if err != nil {
+		return nil, 0, err
+	}
+	a, err := decode.File(data)
+	if err != nil {
+		return nil, 0, fmt.Errorf("%s: %w", opts.input, err)
+	}
+	if a.SampleRate != engine.ExpectedSampleRate && a.SampleRate != engine.TelephonySampleRate {
+		fmt.Fprintf(stderr, "vadcli: resampling %d Hz to %d Hz\n", a.SampleRate, engine.ExpectedSampleRate)
+		if a, err = a.Resample(engine.ExpectedSampleRate); err != nil {
+			return nil, 0, fmt.Errorf("%s: %w", opts.input, err)
+		}
+	}
+	return bytes.NewReader(a.PCM), a.SampleRate, nil
+}
+
+func dial(opts options) (*grpc.ClientConn, error) {
+	creds := insecure.NewCredentials()
+	switch {
+	case opts.caFile != "":
+		c, err := credentials.NewClientTLSFromFile(opts.caFile, "")
+		if err != nil {
+			return nil, err
+		}
+		creds = c
+	case opts.tls:
+		creds = credentials.NewTLS(&tls.Config{MinVersion: tls.VersionTLS12})
+	}
+	return grpc.NewClient(opts.addr, grpc.WithTransportCredentials(creds))
+}
+
+// summary totals a stream's events.
+type summary struct {
+	segments int
+	speech   time.Duration
+	audio    time.Duration
+}
+
+// stream sends pcm to client's DetectSpeech and prints each event to out as
+// it arrives.
+func stream(ctx context.Context, client napv1.VoiceActivityDetectionServiceClient, opts options, pcm io.Reader, rate uint32, out io.Writer) (summary, error) {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	if opts.token != "" {
+		ctx = metadata.AppendToOutgoingContext(ctx, "authorization", "Bearer "+opts.token)
+	}
+	var callOpts []grpc.CallOption
+	if opts.compress != "" {
+		callOpts = append(callOpts, grpc.UseCompressor(opts.compress))
+	}
+	s, err := client.DetectSpeech(ctx, callOpts...)
+	if err != nil {
+		return summary{}, err
+	}
+
+	type sendResult struct {
+		audio time.Duration
+		err   error
+	}
+	sent := make(chan sendResult, 1)
+	go func() {
+		audio, err := send(ctx, s, opts, pcm, rate)
+		sent <- sendResult{audio, err}
+	}()
+
+	var (
+		sum          summary
+		epoch        time.Time
+		segmentStart time.Duration
+	)
+	for {
+		evt, err := s.Recv()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return sum, err
+		}
+		if epoch.IsZero() {
+			md, err := s.Header()
+			if err != nil {
+				return sum, err
+			}
+			var ok bool
+			if epoch, ok = server.ParseAudioEpoch(md); !ok {
+				return sum, fmt.Errorf("response header has no %s", server.AudioEpochHeader)
+			}
+		}
+		at := server.AudioOffset(evt, epoch)
+		stamp := fmt.Sprintf("%8.3fs", at.Seconds())
+		if opts.tsFormat != "" {
+			stamp += "  " + opts.tsFormat.Render(evt.GetTimestamp().AsTime(), epoch)
+		}
+		if server.IsHeartbeat(evt) {
+			fmt.Fprintf(out, "%s  heartbeat\n", stamp)
+			continue
+		}
+		switch evt.GetType() {
+		case server.ProbabilityEventType:
+			fmt.Fprintf(out, "%s  probability   p=%.2f\n", stamp, evt.GetConfidence())
+		case napv1.SpeechEventType_SPEECH_EVENT_TYPE_START:
+			sum.segments++
+			segmentStart = at
+			fmt.Fprintf(out, "%s  speech start  p=%.2f\n", stamp, evt.GetConfidence())
+		case napv1.SpeechEventType_SPEECH_EVENT_TYPE_ONGOING:
+			fmt.Fprintf(out, "%s  ongoing       p=%.2f\n", stamp, evt.GetConfidence())
+		case napv1.SpeechEventType_SPEECH_EVENT_TYPE_END:
+			sum.speech += at - segmentStart
+			fmt.Fprintf(out, "%s  speech end    p=%.2f  %.2f s\n", stamp, evt.GetConfidence(), (at - segmentStart).Seconds())
+		}
+	}
+	result := <-sent
+	sum.audio = result.audio
+	return sum, result.err
+}
+
+// send streams pcm in opts.chunkMs chunks, paced at opts.speed, and closes
+// the send side at the end of pcm. It returns the duration of audio sent.
+func send(ctx context.Context, s napv1.VoiceActivityDetectionService_DetectSpeechClient, opts options, pcm io.Reader, rate uint32) (time.Duration, error) {
+	format := &napv1.AudioFormat{Encoding: "pcm_s16le", SampleRate: rate, Channels: 1, BitDepth: 16}
+	buf := make([]byte, int(rate)*int(opts.chunkMs)/1000*2)
+	start := time.Now()
+	var (
+		samples int64
+		total   time.Duration
+	)
+	for first := true; ; first = false {
+		n, err := io.ReadFull(pcm, buf)
+		n -= n % 2 // a trailing odd byte is not a sample
+		if n > 0 {
+			req := &napv1.DetectSpeechRequest{
+				SessionId: opts.session,
+				StreamId:  opts.stream,
+				Format:    format,
+				PcmData:   buf[:n],
+			}
+			if first {
+				req.ConfigJson = opts.config
+			}
+			if err := s.Send(req); err != nil {
+				// The server's status is reported by Recv.
+				return total, nil
+			}
+			samples += int64(n / 2)
+			total = time.Duration(samples) * time.Second / time.Duration(rate)
+			if opts.speed > 0 {
+				due := start.Add(time.Duration(float64(total) / opts.speed))
+				select {
+				case <-ctx.Done():
+					return total, ctx.Err()
+				case <-time.After(time.Until(due)):
+				}
+			}
+		}
+		if errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF) {
+			return total, s.CloseSend()
+		}
+		if err != nil {
+			return total, fmt.Errorf("read input: %w", err)
+		}
+	}
+}
+
+// withEmitProbabilities sets "emit_probabilities" in the config_json
+// object cfg.
+func withEmitProbabilities(cfg string) (string, error) {
+	fields := map[string]json.RawMessage{}
+	if strings.TrimSpace(cfg) != "" {
+		if err := json.Unmarshal([]byte(cfg), &fields); err != nil {
+			return "", err
+		}
+	}
+	fields["emit_probabilities"] = json.RawMessage("true")
+	raw, err := json.Marshal(fields)
+	return string(raw), err
+}
@@ -0,0 +1,157 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"log/slog"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"google.golang.org/grpc"
+
+	napv1 "github.com/nupi-ai/nupi/api/nap/v1"
+
+	"github.com/nupi-ai/plugin-vad-local-silero/internal/config"
+	"github.com/nupi-ai/plugin-vad-local-silero/internal/engine"
+	"github.com/nupi-ai/plugin-vad-local-silero/internal/server"
+)
+
+// startAdapter serves DetectSpeech with the stub engine on a loopback port
+// and returns its address.
+func startAdapter(t *testing.T, cfg config.Config) string {
+	t.Helper()
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	grpcServer := grpc.NewServer()
+	napv1.RegisterVoiceActivityDetectionServiceServer(grpcServer,
+		server.New(cfg, slog.New(slog.DiscardHandler), func() engine.Engine { return engine.NewStubEngine() }))
+	go grpcServer.Serve(lis)
+	t.Cleanup(grpcServer.Stop)
+	return lis.Addr().String()
+}
+
+func stubConfig() config.Config {
+	return config.Config{Threshold: 0.5, MinSpeechDurationMs: 250, MinSilenceDurationMs: 300}
+}
+
+// writeWAV writes ms of silent mono 16-bit PCM at rate as a WAV file.
+func writeWAV(t *testing.T, rate uint32, ms int) string {
+	t.Helper()
+	data := make([]byte, int(rate)*ms/1000*2)
+	var b bytes.Buffer
+	b.WriteString("RIFF")
+	binary.Write(&b, binary.LittleEndian, uint32(36+len(data)))
+	b.WriteString("WAVEfmt ")
+	for _, v := range []any{uint32(16), uint16(1), uint16(1), rate, rate * 2, uint16(2), uint16(16)} {
+		binary.Write(&b, binary.LittleEndian, v)
+	}
+	b.WriteString("data")
+	binary.Write(&b, binary.LittleEndian, uint32(len(data)))
+	b.Write(data)
+	path := filepath.Join(t.TempDir(), "input.wav")
+	if err := os.WriteFile(path, b.Bytes(), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestRunWAV(t *testing.T) {
+	addr := startAdapter(t, stubConfig())
+	var stdout, stderr bytes.Buffer
+	code := run(context.Background(), []string{"--addr", addr, "--speed", "0", "--timestamp-format", "offset_ms", writeWAV(t, 16000, 4600)},
+		nil, &stdout, &stderr)
+	if code != 0 {
+		t.Fatalf("exit code %d\n%s", code, stderr.String())
+	}
+	out := stdout.String()
+	// The stub turns to speech at 980 ms; min_speech_duration_ms confirms it
+	// at 1220 ms.
+	if !strings.Contains(out, "   1.220s  1220  speech start") {
+		t.Errorf("first START not at offset 1.220 s:\n%s", out)
+	}
+	if !strings.Contains(out, "2 speech segments") || !strings.Contains(out, "in 4.60 s of audio") {
+		t.Errorf("summary:\n%s", out)
+	}
+}
+
+func TestRunCompressed(t *testing.T) {
+	addr := startAdapter(t, stubConfig())
+	for _, name := range []string{"gzip", "zstd"} {
+		var stdout, stderr bytes.Buffer
+		code := run(context.Background(), []string{"--addr", addr, "--speed", "0", "--compress", name, writeWAV(t, 16000, 4600)},
+			nil, &stdout, &stderr)
+		if code != 0 {
+			t.Fatalf("%s: exit code %d\n%s", name, code, stderr.String())
+		}
+		if !strings.Contains(stdout.String(), "2 speech segments") {
+			t.Errorf("%s: summary:\n%s", name, stdout.String())
+		}
+	}
+}
+
+func TestRunResamplesWAV(t *testing.T) {
+	addr := startAdapter(t, stubConfig())
+	var stdout, stderr bytes.Buffer
+	if code := run(context.Background(), []string{"--addr", addr, "--speed", "0", writeWAV(t, 48000, 1000)}, nil, &stdout, &stderr); code != 0 {
+		t.Fatalf("exit code %d\n%s", code, stderr.String())
+	}
+	if !strings.Contains(stderr.String(), "resampling 48000 Hz to 16000 Hz") {
+		t.Errorf("stderr: %s", stderr.String())
+	}
+	if !strings.Contains(stdout.String(), "in 1.00 s of audio") {
+		t.Errorf("summary:\n%s", stdout.String())
+	}
+}
+
+func TestRunStdinProbabilities(t *testing.T) {
+	addr := startAdapter(t, stubConfig())
+	var stdout, stderr bytes.Buffer
+	stdin := bytes.NewReader(make([]byte, 8000)) // 500 ms at 8 kHz
+	code := run(context.Background(), []string{"--addr", addr, "--speed", "0", "--rate", "8000", "--probabilities", "--config", `{"threshold": 0.6}`, "-"},
+		stdin, &stdout, &stderr)
+	if code != 0 {
+		t.Fatalf("exit code %d\n%s", code, stderr.String())
+	}
+	if got := strings.Count(stdout.String(), "probability"); got != 25 {
+		t.Errorf("%d probability lines, want one per 20 ms frame (25)\n%s", got, stdout.String())
+	}
+}
+
+func TestRunServerError(t *testing.T) {
+	addr := startAdapter(t, stubConfig())
+	var stdout, stderr bytes.Buffer
+	code := run(context.Background(), []string{"--addr", addr, "--speed", "0", "--config", `{"threshold": 2}`, writeWAV(t, 16000, 100)},
+		nil, &stdout, &stderr)
+	if code != 1 || !strings.Contains(stderr.String(), "threshold") {
+		t.Errorf("exit code %d, stderr %q; want 1 and the server's validation error", code, stderr.String())
+	}
+}
+
+func TestParseArgsErrors(t *testing.T) {
+	for _, args := range [][]string{
+		{"in.wav"},
+		{"--addr", "localhost:1"},
+		{"--addr", "localhost:1", "a.wav", "b.wav"},
+		{"--addr", "localhost:1", "--chunk-ms", "0", "-"},
+		{"--addr", "localhost:1", "--speed", "-1", "-"},
+		{"--addr", "localhost:1", "--timestamp-format", "iso", "-"},
+		{"--addr", "localhost:1", "--probabilities", "--config", "[1]", "-"},
+		{"--addr", "localhost:1", "--compress", "brotli", "-"},
+		{"--bogus"},
+	} {
+		var stderr bytes.Buffer
+		if code := run(context.Background(), args, nil, &stderr, &stderr); code != 2 {
+			t.Errorf("%q: exit code %d, want 2\n%s", args, code, stderr.String())
+		}
+	}
+	var stderr bytes.Buffer
+	if code := run(context.Background(), []string{"--help"}, nil, &stderr, &stderr); code != 0 || !strings.Contains(stderr.String(), "Usage: vadcli") {
+		t.Errorf("--help: exit code %d\n%s", code, stderr.String())
+	}
+}
@@ -0,0 +1,638 @@
+// Package admin implements the adapter's operator-facing gRPC service. NAP
+// defines no admin API, so the service is declared by hand with well-known
+// protobuf types: requests and responses are google.protobuf.Struct objects
+// whose fields are documented on each method.
+package admin
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/structpb"
+
+	"github.com/nupi-ai/plugin-vad-local-silero/internal/config"
+	"github.com/nupi-ai/plugin-vad-local-silero/internal/errs"
+	"github.com/nupi-ai/plugin-vad-local-silero/internal/server"
+)
+
+// ServiceName is the fully qualified gRPC service name.
+const ServiceName = "nupi.vad.admin.v1.AdminService"
+
+// Full method names, for clients and interceptors.
+const (
+	ListRecentStreamsMethod = "/" + ServiceName + "/ListRecentStreams"
+	GetCapabilitiesMethod   = "/" + ServiceName + "/GetCapabilities"
+	QuiesceMethod           = "/" + ServiceName + "/Quiesce"
+	ListActiveStreamsMethod = "/" + ServiceName + "/ListActiveStreams"
+	GetConfigMethod         = "/" + ServiceName + "/GetConfig"
+	SetLogLevelMethod       = "/" + ServiceName + "/SetLogLevel"
+	ListSessionsMethod      = "/" + ServiceName + "/ListSessions"
+	CancelSessionMethod     = "/" + ServiceName + "/CancelSession"
+)
+
+// StreamHistory is the server state the admin service reads.
+type StreamHistory interface {
+	RecentStreams(sessionID string, limit int) []server.StreamSummary
+	Capabilities() (server.Capabilities, error)
+	SetQuiesced(on bool) int
+	Quiesced() bool
+	ActiveStreams() int
+	ListActiveStreams() []server.ActiveStream
+	ListSessions() []server.ActiveSession
+	TerminateSession(sessionID, msg string) int
+	Config() config.Config
+}
+
+// Service implements AdminService. It is registered before the VAD server is
+// ready and answers Unavailable until SetServer is called, like the VAD
+// service itself; SetLogLevel works from the start.
+type Service struct {
+	history atomic.Pointer[StreamHistory]
+	sources atomic.Pointer[config.Provenance]
+	level   *slog.LevelVar
+	log     *slog.Logger
+}
+
+// New returns a Service with no server attached. level is the adapter's log
+// level, changed by SetLogLevel; when nil, SetLogLevel is unimplemented.
+// logger records level changes and may be nil.
+func New(level *slog.LevelVar, logger *slog.Logger) *Service {
+	if logger == nil {
+		logger = slog.New(slog.DiscardHandler)
+	}
+	return &Service{level: level, log: logger}
+}
+
+// SetServer attaches the running VAD server.
+func (s *Service) SetServer(h StreamHistory) {
+	s.history.Store(&h)
+}
+
+// SetProvenance records which source set each option of the server's
+// config, for GetConfig. The provenance is not modified afterwards.
+func (s *Service) SetProvenance(p config.Provenance) {
+	s.sources.Store(&p)
+}
+
+// Register adds the service to a gRPC server.
+func Register(r grpc.ServiceRegistrar, s *Service) {
+	r.RegisterService(&serviceDesc, s)
+}
+
+// ListRecentStreams returns the summaries of recently closed streams, most
+// recent first.
+//
+// Request fields (all optional): "session_id" (string) keeps only that
+// session's streams; "limit" (number) caps the result.
+//
+// Response: {"streams": [ {...}, ... ]}, one object per stream with
+// session_id, stream_id, variant, peer (the client address), opened_at and
+// closed_at (RFC 3339), audio_duration_ms, speech_duration_ms, sample_rate,
+// frames, segments, chunks, max_chunk_bytes, inference ({mean_ms, p95_ms,
+// max_ms} engine time per chunk), error (empty on a clean close) and the VAD parameters in effect (threshold,
+// neg_threshold, min_speech_duration_ms, min_silence_duration_ms,
+// max_speech_duration_ms, preroll_ms).
+func (s *Service) ListRecentStreams(_ context.Context, req *structpb.Struct) (*structpb.Struct, error) {
+	h := s.history.Load()
+	if h == nil {
+		return nil, status.Error(codes.Unavailable, "VAD service is initializing, please retry in a moment")
+	}
+	fields := req.GetFields()
+	sessionID := fields["session_id"].GetStringValue()
+	limit := int(fields["limit"].GetNumberValue())
+	if limit < 0 {
+		return nil, status.Errorf(codes.InvalidArgument, "limit must not be negative, got %d", limit)
+	}
+
+	summaries := (*h).RecentStreams(sessionID, limit)
+	streams := make([]any, 0, len(summaries))
+	for _, sum := range summaries {
+		streams = append(streams, summaryFields(sum))
+	}
+	return structpb.NewStruct(map[string]any{"streams": streams})
+}
+
+// GetCapabilities returns the engine's frame timing, so clients can size
+// their chunks to whole inference frames.
+//
+// The request is empty. Response: {"engine", "resampling" (bool),
+// "max_chunk_bytes", "rates": [ {...}, ... ]}, one object per native sample
+// rate with sample_rate, frame_samples (the hop between results),
+// frame_duration_ms, window_samples (the audio each result is scored over)
+// and chunk_alignment_bytes (send chunks that are a multiple of it).
+func (s *Service) GetCapabilities(_ context.Context, _ *structpb.Struct) (*structpb.Struct, error) {
+	h := s.history.Load()
+	if h == nil {
+		return nil, status.Error(codes.Unavailable, "VAD service is initializing, please retry in a moment")
+	}
+	caps, err := (*h).Capabilities()
+	if err != nil {
+		return nil, errs.ToStatus(err)
+	}
+	rates := make([]any, 0, len(caps.Rates))
+	for _, r := range caps.Rates {
+		rates = append(rates, map[string]any{
+			"sample_rate":           float64(r.SampleRate),
+			"frame_samples":         float64(r.FrameSamples),
+			"frame_duration_ms":     float64(r.FrameDuration) / float64(time.Millisecond),
+			"window_samples":        float64(r.WindowSamples),
+			"chunk_alignment_bytes": float64(r.ChunkAlignBytes),
+		})
+	}
+	return structpb.NewStruct(map[string]any{
+		"engine":          caps.Engine,
+		"resampling":      caps.Resampling,
+		"max_chunk_bytes": float64(caps.MaxChunkBytes),
+		"rates":           rates,
+	})
+}
+
+// Quiesce turns maintenance mode on or off: while quiesced, open streams
+// continue but new DetectSpeech streams fail with UNAVAILABLE and reason
+// QUIESCED, and health readiness reports NOT_SERVING, so the instance drains
+// from its load balancer.
+//
+// Request fields: "quiesce" (bool) sets the mode; without it the call only
+// reports the current state.
+//
+// Response: {"quiesced" (bool), "active_streams"}. Poll until
+// active_streams reaches 0 before stopping the instance.
+func (s *Service) Quiesce(_ context.Context, req *structpb.Struct) (*structpb.Struct, error) {
+	h := s.history.Load()
+	if h == nil {
+		return nil, status.Error(codes.Unavailable, "VAD service is initializing, please retry in a moment")
+	}
+	active := (*h).ActiveStreams()
+	if v, ok := req.GetFields()["quiesce"]; ok {
+		if _, isBool := v.GetKind().(*structpb.Value_BoolValue); !isBool {
+			return nil, status.Error(codes.InvalidArgument, "quiesce must be a bool")
+		}
+		active = (*h).SetQuiesced(v.GetBoolValue())
+	}
+	return structpb.NewStruct(map[string]any{
+		"quiesced":       (*h).Quiesced(),
+		"active_streams": float64(active),
+	})
+}
+
+// ListActiveStreams returns the streams open right now, oldest first.
+//
+// The request is empty. Response: {"streams": [ {...}, ... ]}, one object
+// per stream with session_id, stream_id and variant (empty until the first
+// audio chunk), peer, opened_at (RFC 3339), uptime_ms and frames (inference
+// frames processed so far).
+func (s *Service) ListActiveStreams(_ context.Context, _ *structpb.Struct) (*structpb.Struct, error) {
+	h := s.history.Load()
+	if h == nil {
+		return nil, status.Error(codes.Unavailable, "VAD service is initializing, please retry in a moment")
+	}
+	return structpb.NewStruct(map[string]any{"streams": activeFields((*h).ListActiveStreams(), time.Now())})
+}
+
+// activeFields renders open streams for ListActiveStreams and ListSessions.
+func activeFields(active []server.ActiveStream, now time.Time) []any {
+	streams := make([]any, 0, len(active))
+	for _, st := range active {
+		streams = append(streams, map[string]any{
+			"session_id": st.SessionID,
+			"stream_id":  st.StreamID,
+			"variant":    st.Variant,
+			"peer":       st.Peer,
+			"opened_at":  st.OpenedAt.UTC().Format(time.RFC3339Nano),
+			"uptime_ms":  float64(now.Sub(st.OpenedAt)) / float64(time.Millisecond),
+			"frames":     float64(st.Frames),
+		})
+	}
+	return streams
+}
+
+// ListSessions returns the sessions with open streams, oldest first, so
+// concurrent streams of one session (e.g. a far-field and a headset
+// microphone) can be seen together. Streams join their session with their
+// first audio chunk; streams without a session_id belong to none.
+//
+// The request is empty. Response: {"sessions": [ {...}, ... ]}, one object
+// per session with session_id, opened_at (RFC 3339, when its first stream
+// joined) and streams, its open streams as in ListActiveStreams.
+func (s *Service) ListSessions(_ context.Context, _ *structpb.Struct) (*structpb.Struct, error) {
+	h := s.history.Load()
+	if h == nil {
+		return nil, status.Error(codes.Unavailable, "VAD service is initializing, please retry in a moment")
+	}
+	now := time.Now()
+	active := (*h).ListSessions()
+	sessions := make([]any, 0, len(active))
+	for _, sess := range active {
+		sessions = append(sessions, map[string]any{
+			"session_id": sess.SessionID,
+			"opened_at":  sess.OpenedAt.UTC().Format(time.RFC3339Nano),
+			"streams":    activeFields(sess.Streams, now),
+		})
+	}
+	return structpb.NewStruct(map[string]any{"sessions": sessions})
+}
+
+// CancelSession ends every open stream of a session. The streams fail with
+// ABORTED and reason ADMIN_ACTION (see server.TerminationError); streams the
+// session opens afterwards are not affected.
+//
+// Request fields: "session_id" (string, required). Response: {"cancelled"},
+// the number of streams ended. A session with no open streams is NotFound.
+func (s *Service) CancelSession(_ context.Context, req *structpb.Struct) (*structpb.Struct, error) {
+	h := s.history.Load()
+	if h == nil {
+		return nil, status.Error(codes.Unavailable, "VAD service is initializing, please retry in a moment")
+	}
+	id := req.GetFields()["session_id"].GetStringValue()
+	if id == "" {
+		return nil, status.Error(codes.InvalidArgument, "session_id is required")
+	}
+	n := (*h).TerminateSession(id, "session cancelled by an operator")
+	if n == 0 {
+		return nil, status.Errorf(codes.NotFound, "session %q has no open streams", id)
+	}
+	s.log.Info("session cancelled (admin)", "session_id", id, "streams", n)
+	return structpb.NewStruct(map[string]any{"cancelled": float64(n)})
+}
+
+// GetConfig returns the server-wide config in effect, as loaded from
+// NUPI_ADAPTER_CONFIG and the environment. Per-stream config_json overrides
+// are not included; auth_token is redacted.
+//
+// The request is empty. Response: {"config": {...}} with the fields of
+// config.Config under their JSON names; "sources", when the provenance is
+// known, mapping each option's path ("threshold", "agc.target_dbfs") to
+// what set it: "default", "preset", NUPI_ADAPTER_CONFIG_FILE,
+// NUPI_ADAPTER_CONFIG, an environment variable or a --flag; and "log_level", the current level,
+// which SetLogLevel and SIGUSR1 may have changed since startup.
+func (s *Service) GetConfig(_ context.Context, _ *structpb.Struct) (*structpb.Struct, error) {
+	h := s.history.Load()
+	if h == nil {
+		return nil, status.Error(codes.Unavailable, "VAD service is initializing, please retry in a moment")
+	}
+	cfg, err := configFields((*h).Config())
+	if err != nil {
+		return nil, status.Errorf(codes.Internal, "encode config: %v", err)
+	}
+	resp := map[string]any{"config": cfg}
+	if p := s.sources.Load(); p != nil {
+		sources := make(map[string]any, len(*p))
+		for name, source := range *p {
+			sources[name] = source
+		}
+		resp["sources"] = sources
+	}
+	if s.level != nil {
+		resp["log_level"] = strings.ToLower(s.level.Level().String())
+	}
+	return structpb.NewStruct(resp)
+}
+
+// redacted replaces secrets in GetConfig responses.
+const redacted = "<redacted>"
+
+// configFields converts cfg to Struct-compatible values through its JSON
+// form, so the field names match the config file.
+func configFields(cfg config.Config) (map[string]any, error) {
+	if cfg.AuthToken != "" {
+		cfg.AuthToken = redacted
+	}
+	raw, err := json.Marshal(cfg)
+	if err != nil {
+		return nil, err
+	}
+	var fields map[string]any
+	if err := json.Unmarshal(raw, &fields); err != nil {
+		return nil, err
+	}
+	return fields, nil
+}
+
+// SetLogLevel changes the adapter's log level without a restart, e.g. to
+// debug a live problem. The change lasts until the next SetLogLevel or
+// SIGUSR1, or the process exits.
+//
+// Request fields: "level" (string: debug, info, warn or error) sets the
+// level; without it the call only reports the current one.
+//
+// Response: {"level", "previous"}.
+func (s *Service) SetLogLevel(_ context.Context, req *structpb.Struct) (*structpb.Struct, error) {
+	if s.level == nil {
+		return nil, status.Error(codes.Unimplemented, "log level is not adjustable on this instance")
+	}
+	previous := s.level.Level()
+	if v, ok := req.GetFields()["level"]; ok {
+		level, err := parseLevel(v.GetStringValue())
+		if err != nil {
+			return nil, status.Error(codes.InvalidArgument, err.Error())
+		}
+		s.level.Set(level)
+		if level != previous {
+			// Logged at the higher level of the two, so it shows in both.
+			s.log.Log(context.Background(), max(level, previous, slog.LevelInfo), "log level changed (admin)", "level", levelName(level), "previous", levelName(previous))
+		}
+	}
+	return structpb.NewStruct(map[string]any{
+		"level":    levelName(s.level.Level()),
+		"previous": levelName(previous),
+	})
+}
+
+// parseLevel accepts the level names of the log_level option.
+func parseLevel(name string) (slog.Level, error) {
+	switch strings.ToLower(strings.TrimSpace(name)) {
+	case "debug":
+		return slog.LevelDebug, nil
+	case "info":
+		return slog.LevelInfo, nil
+	case "warn", "warning":
+		return slog.LevelWarn, nil
+	case "error":
+		return slog.LevelError, nil
+	}
+	return 0, errors.New("level must be debug, info, warn or error, got " + strconv.Quote(name))
+}
+
+func levelName(l slog.Level) string {
+	return strings.ToLower(l.String())
+}
+
+func summaryFields(sum server.StreamSummary) map[string]any {
+	fields := map[string]any{
+		"session_id":              sum.SessionID,
+		"stream_id":               sum.StreamID,
+		"variant":                 sum.Variant,
+		"model":                   sum.Model,
+		"peer":                    sum.Peer,
+		"opened_at":               sum.OpenedAt.UTC().Format(time.RFC3339Nano),
+		"closed_at":               sum.ClosedAt.UTC().Format(time.RFC3339Nano),
+		"audio_duration_ms":       float64(sum.AudioDuration) / float64(time.Millisecond),
+		"speech_duration_ms":      float64(sum.SpeechDuration) / float64(time.Millisecond),
+		"sample_rate":             float64(sum.SampleRate),
+		"frames":                  float64(sum.Frames),
+		"segments":                float64(sum.Segments),
+		"mean_confidence":         sum.MeanConfidence,
+		"events":                  float64(sum.Events),
+		"events_dropped":          float64(sum.EventsDropped),
+		"peak_buffered_bytes":     float64(sum.PeakBufferedBytes),
+		"chunks":                  float64(sum.Chunks),
+		"max_chunk_bytes":         float64(sum.MaxChunkBytes),
+		"error":                   sum.Error,
+		"threshold":               sum.Threshold,
+		"neg_threshold":           sum.NegThreshold,
+		"min_speech_duration_ms":  float64(sum.MinSpeechDurationMs),
+		"min_silence_duration_ms": float64(sum.MinSilenceDurationMs),
+		"max_speech_duration_ms":  float64(sum.MaxSpeechDurationMs),
+		"preroll_ms":              float64(sum.PrerollMs),
+		"min_gap_ms":              float64(sum.MinGapMs),
+	}
+	if len(sum.Latency) > 0 {
+		latency := make(map[string]any, len(sum.Latency))
+		for stage, st := range sum.Latency {
+			latency[stage] = map[string]any{
+				"count":   float64(st.Count),
+				"mean_ms": float64(st.Mean) / float64(time.Millisecond),
+				"p95_ms":  float64(st.P95) / float64(time.Millisecond),
+				"max_ms":  float64(st.Max) / float64(time.Millisecond),
+			}
+		}
+		fields["latency"] = latency
+	}
+	if sum.Inference.Count > 0 {
+		fields["inference"] = map[string]any{
+			"mean_ms": float64(sum.Inference.Mean) / float64(time.Millisecond),
+			"p95_ms":  float64(sum.Inference.P95) / float64(time.Millisecond),
+			"max_ms":  float64(sum.Inference.Max) / float64(time.Millisecond),
+		}
+	}
+	return fields
+}
+
+// ListRecentStreams calls AdminService.ListRecentStreams on cc.
+func ListRecentStreams(ctx context.Context, cc grpc.ClientConnInterface, req *structpb.Struct, opts ...grpc.CallOption) (*structpb.Struct, error) {
+	out := new(structpb.Struct)
+	if err := cc.Invoke(ctx, ListRecentStreamsMethod, req, out, opts...); err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// GetCapabilities calls AdminService.GetCapabilities on cc.
+func GetCapabilities(ctx context.Context, cc grpc.ClientConnInterface, req *structpb.Struct, opts ...grpc.CallOption) (*structpb.Struct, error) {
+	out := new(structpb.Struct)
+	if err := cc.Invoke(ctx, GetCapabilitiesMethod, req, out, opts...); err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// Quiesce calls AdminService.Quiesce on cc.
+func Quiesce(ctx context.Context, cc grpc.ClientConnInterface, req *structpb.Struct, opts ...grpc.CallOption) (*structpb.Struct, error) {
+	out := new(structpb.Struct)
+	if err := cc.Invoke(ctx, QuiesceMethod, req, out, opts...); err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// ListActiveStreams calls AdminService.ListActiveStreams on cc.
+func ListActiveStreams(ctx context.Context, cc grpc.ClientConnInterface, req *structpb.Struct, opts ...grpc.CallOption) (*structpb.Struct, error) {
+	out := new(structpb.Struct)
+	if err := cc.Invoke(ctx, ListActiveStreamsMethod, req, out, opts...); err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// GetConfig calls AdminService.GetConfig on cc.
+func GetConfig(ctx context.Context, cc grpc.ClientConnInterface, req *structpb.Struct, opts ...grpc.CallOption) (*structpb.Struct, error) {
+	out := new(structpb.Struct)
+	if err := cc.Invoke(ctx, GetConfigMethod, req, out, opts...); err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// SetLogLevel calls AdminService.SetLogLevel on cc.
+func SetLogLevel(ctx context.Context, cc grpc.ClientConnInterface, req *structpb.Struct, opts ...grpc.CallOption) (*structpb.Struct, error) {
+	out := new(structpb.Struct)
+	if err := cc.Invoke(ctx, SetLogLevelMethod, req, out, opts...); err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// ListSessions calls AdminService.ListSessions on cc.
+func ListSessions(ctx context.Context, cc grpc.ClientConnInterface, req *structpb.Struct, opts ...grpc.CallOption) (*structpb.Struct, error) {
+	out := new(structpb.Struct)
+	if err := cc.Invoke(ctx, ListSessionsMethod, req, out, opts...); err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// CancelSession calls AdminService.CancelSession on cc.
+func CancelSession(ctx context.Context, cc grpc.ClientConnInterface, req *structpb.Struct, opts ...grpc.CallOption) (*structpb.Struct, error) {
+	out := new(structpb.Struct)
+	if err := cc.Invoke(ctx, CancelSessionMethod, req, out, opts...); err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// adminServer is the handler type checked by grpc.Server.RegisterService.
+type adminServer interface {
+	ListRecentStreams(context.Context, *structpb.Struct) (*structpb.Struct, error)
+	GetCapabilities(context.Context, *structpb.Struct) (*structpb.Struct, error)
+	Quiesce(context.Context, *structpb.Struct) (*structpb.Struct, error)
+	ListActiveStreams(context.Context, *structpb.Struct) (*structpb.Struct, error)
+	GetConfig(context.Context, *structpb.Struct) (*structpb.Struct, error)
+	SetLogLevel(context.Context, *structpb.Struct) (*structpb.Struct, error)
+	ListSessions(context.Context, *structpb.Struct) (*structpb.Struct, error)
+	CancelSession(context.Context, *structpb.Struct) (*structpb.Struct, error)
+}
+
+func listRecentStreamsHandler(srv any, ctx context.Context, dec func(any) error, interceptor grpc.UnaryServerInterceptor) (any, error) {
+	in := new(structpb.Struct)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(adminServer).ListRecentStreams(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{Server: srv, FullMethod: ListRecentStreamsMethod}
+	handler := func(ctx context.Context, req any) (any, error) {
+		return srv.(adminServer).ListRecentStreams(ctx, req.(*structpb.Struct))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func getCapabilitiesHandler(srv any, ctx context.Context, dec func(any) error, interceptor grpc.UnaryServerInterceptor) (any, error) {
+	in := new(structpb.Struct)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(adminServer).GetCapabilities(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{Server: srv, FullMethod: GetCapabilitiesMethod}
+	handler := func(ctx context.Context, req any) (any, error) {
+		return srv.(adminServer).GetCapabilities(ctx, req.(*structpb.Struct))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func quiesceHandler(srv any, ctx context.Context, dec func(any) error, interceptor grpc.UnaryServerInterceptor) (any, error) {
+	in := new(structpb.Struct)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(adminServer).Quiesce(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{Server: srv, FullMethod: QuiesceMethod}
+	handler := func(ctx context.Context, req any) (any, error) {
+		return srv.(adminServer).Quiesce(ctx, req.(*structpb.Struct))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func listActiveStreamsHandler(srv any, ctx context.Context, dec func(any) error, interceptor grpc.UnaryServerInterceptor) (any, error) {
+	in := new(structpb.Struct)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(adminServer).ListActiveStreams(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{Server: srv, FullMethod: ListActiveStreamsMethod}
+	handler := func(ctx context.Context, req any) (any, error) {
+		return srv.(adminServer).ListActiveStreams(ctx, req.(*structpb.Struct))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func getConfigHandler(srv any, ctx context.Context, dec func(any) error, interceptor grpc.UnaryServerInterceptor) (any, error) {
+	in := new(structpb.Struct)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(adminServer).GetConfig(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{Server: srv, FullMethod: GetConfigMethod}
+	handler := func(ctx context.Context, req any) (any, error) {
+		return srv.(adminServer).GetConfig(ctx, req.(*structpb.Struct))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func setLogLevelHandler(srv any, ctx context.Context, dec func(any) error, interceptor grpc.UnaryServerInterceptor) (any, error) {
+	in := new(structpb.Struct)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(adminServer).SetLogLevel(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{Server: srv, FullMethod: SetLogLevelMethod}
+	handler := func(ctx context.Context, req any) (any, error) {
+		return srv.(adminServer).SetLogLevel(ctx, req.(*structpb.Struct))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func listSessionsHandler(srv any, ctx context.Context, dec func(any) error, interceptor grpc.UnaryServerInterceptor) (any, error) {
+	in := new(structpb.Struct)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(adminServer).ListSessions(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{Server: srv, FullMethod: ListSessionsMethod}
+	handler := func(ctx context.Context, req any) (any, error) {
+		return srv.(adminServer).ListSessions(ctx, req.(*structpb.Struct))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func cancelSessionHandler(srv any, ctx context.Context, dec func(any) error, interceptor grpc.UnaryServerInterceptor) (any, error) {
+	in := new(structpb.Struct)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(adminServer).CancelSession(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{Server: srv, FullMethod: CancelSessionMethod}
+	handler := func(ctx context.Context, req any) (any, error) {
+		return srv.(adminServer).CancelSession(ctx, req.(*structpb.Struct))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+var serviceDesc = grpc.ServiceDesc{
+	ServiceName: ServiceName,
+	HandlerType: (*adminServer)(nil),
+	Methods: []grpc.MethodDesc{
+		{MethodName: "ListRecentStreams", Handler: listRecentStreamsHandler},
+		{MethodName: "GetCapabilities", Handler: getCapabilitiesHandler},
+		{MethodName: "Quiesce", Handler: quiesceHandler},
+		{MethodName: "ListActiveStreams", Handler: listActiveStreamsHandler},
+		{MethodName: "GetConfig", Handler: getConfigHandler},
+		{MethodName: "SetLogLevel", Handler: setLogLevelHandler},
+		{MethodName: "ListSessions", Handler: listSessionsHandler},
+		{MethodName: "CancelSession", Handler: cancelSessionHandler},
+	},
+	Streams: []grpc.StreamDesc{},
+}
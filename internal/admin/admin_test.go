@@ -0,0 +1,403 @@
+package admin
+
+import (
+	"bytes"
+	"context"
+	"log/slog"
+	"net"
+	"strings"
+	"testing"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/structpb"
+
+	"github.com/nupi-ai/plugin-vad-local-silero/internal/config"
+	"github.com/nupi-ai/plugin-vad-local-silero/internal/errs"
+	"github.com/nupi-ai/plugin-vad-local-silero/internal/server"
+)
+
+type fakeHistory struct {
+	gotSession string
+	gotLimit   int
+	summaries  []server.StreamSummary
+	caps       server.Capabilities
+	capsErr    error
+	quiesced   bool
+	active     int
+	streams    []server.ActiveStream
+	sessions   []server.ActiveSession
+	cancelled  []string
+	cfg        config.Config
+}
+
+func (f *fakeHistory) ListActiveStreams() []server.ActiveStream { return f.streams }
+
+func (f *fakeHistory) ListSessions() []server.ActiveSession { return f.sessions }
+
+func (f *fakeHistory) TerminateSession(sessionID, _ string) int {
+	for _, sess := range f.sessions {
+		if sess.SessionID == sessionID {
+			f.cancelled = append(f.cancelled, sessionID)
+			return len(sess.Streams)
+		}
+	}
+	return 0
+}
+
+func (f *fakeHistory) Config() config.Config { return f.cfg }
+
+func (f *fakeHistory) SetQuiesced(on bool) int {
+	f.quiesced = on
+	return f.active
+}
+
+func (f *fakeHistory) Quiesced() bool { return f.quiesced }
+
+func (f *fakeHistory) ActiveStreams() int { return f.active }
+
+func (f *fakeHistory) Capabilities() (server.Capabilities, error) {
+	return f.caps, f.capsErr
+}
+
+func (f *fakeHistory) RecentStreams(sessionID string, limit int) []server.StreamSummary {
+	f.gotSession, f.gotLimit = sessionID, limit
+	return f.summaries
+}
+
+// startAdmin serves svc on a loopback listener and returns a client
+// connection.
+func startAdmin(t *testing.T, svc *Service) *grpc.ClientConn {
+	t.Helper()
+	lis, err := net.Listen("tcp", "localhost:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	srv := grpc.NewServer()
+	Register(srv, svc)
+	go srv.Serve(lis)
+	t.Cleanup(srv.Stop)
+
+	conn, err := grpc.NewClient(lis.Addr().String(), grpc.WithTransportCredentials(insecure.NewCredentials()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	return conn
+}
+
+func TestListRecentStreams(t *testing.T) {
+	svc := New(nil, nil)
+	conn := startAdmin(t, svc)
+
+	if _, err := ListRecentStreams(context.Background(), conn, &structpb.Struct{}); status.Code(err) != codes.Unavailable {
+		t.Fatalf("before SetServer: err = %v, want Unavailable", err)
+	}
+
+	history := &fakeHistory{summaries: []server.StreamSummary{
+		{SessionID: "sess-1", StreamID: "mic-1", Variant: "baseline", Segments: 2, Error: "rpc error"},
+	}}
+	svc.SetServer(history)
+
+	req, _ := structpb.NewStruct(map[string]any{"session_id": "sess-1", "limit": 5})
+	resp, err := ListRecentStreams(context.Background(), conn, req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if history.gotSession != "sess-1" || history.gotLimit != 5 {
+		t.Errorf("RecentStreams called with (%q, %d), want (sess-1, 5)", history.gotSession, history.gotLimit)
+	}
+	streams := resp.GetFields()["streams"].GetListValue().GetValues()
+	if len(streams) != 1 {
+		t.Fatalf("streams = %v, want 1 entry", streams)
+	}
+	fields := streams[0].GetStructValue().GetFields()
+	if got := fields["stream_id"].GetStringValue(); got != "mic-1" {
+		t.Errorf("stream_id = %q, want mic-1", got)
+	}
+	if got := fields["segments"].GetNumberValue(); got != 2 {
+		t.Errorf("segments = %v, want 2", got)
+	}
+	if got := fields["error"].GetStringValue(); got != "rpc error" {
+		t.Errorf("error = %q, want %q", got, "rpc error")
+	}
+
+	bad, _ := structpb.NewStruct(map[string]any{"limit": -1})
+	if _, err := ListRecentStreams(context.Background(), conn, bad); status.Code(err) != codes.InvalidArgument {
+		t.Errorf("negative limit: err = %v, want InvalidArgument", err)
+	}
+}
+
+func TestGetCapabilities(t *testing.T) {
+	svc := New(nil, nil)
+	conn := startAdmin(t, svc)
+
+	if _, err := GetCapabilities(context.Background(), conn, &structpb.Struct{}); status.Code(err) != codes.Unavailable {
+		t.Fatalf("before SetServer: err = %v, want Unavailable", err)
+	}
+
+	history := &fakeHistory{caps: server.Capabilities{
+		Engine:        "silero",
+		MaxChunkBytes: server.MaxPCMChunkBytes,
+		Rates: []server.RateTiming{
+			{SampleRate: 16000, FrameSamples: 256, FrameDuration: 16 * time.Millisecond, WindowSamples: 512, ChunkAlignBytes: 512},
+		},
+	}}
+	svc.SetServer(history)
+	resp, err := GetCapabilities(context.Background(), conn, &structpb.Struct{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	fields := resp.GetFields()
+	if got := fields["engine"].GetStringValue(); got != "silero" {
+		t.Errorf("engine = %q, want silero", got)
+	}
+	rates := fields["rates"].GetListValue().GetValues()
+	if len(rates) != 1 {
+		t.Fatalf("rates = %v, want 1 entry", rates)
+	}
+	rate := rates[0].GetStructValue().GetFields()
+	for key, want := range map[string]float64{
+		"sample_rate":           16000,
+		"frame_samples":         256,
+		"frame_duration_ms":     16,
+		"window_samples":        512,
+		"chunk_alignment_bytes": 512,
+	} {
+		if got := rate[key].GetNumberValue(); got != want {
+			t.Errorf("%s = %v, want %v", key, got, want)
+		}
+	}
+
+	history.capsErr = errs.New(errs.ErrEngineUnavailable, "no engine")
+	if _, err := GetCapabilities(context.Background(), conn, &structpb.Struct{}); status.Code(err) != codes.Unavailable {
+		t.Errorf("engine failure: err = %v, want Unavailable", err)
+	}
+}
+
+func TestQuiesce(t *testing.T) {
+	svc := New(nil, nil)
+	conn := startAdmin(t, svc)
+
+	if _, err := Quiesce(context.Background(), conn, &structpb.Struct{}); status.Code(err) != codes.Unavailable {
+		t.Fatalf("before SetServer: err = %v, want Unavailable", err)
+	}
+
+	history := &fakeHistory{active: 3}
+	svc.SetServer(history)
+	on, _ := structpb.NewStruct(map[string]any{"quiesce": true})
+	resp, err := Quiesce(context.Background(), conn, on)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !history.quiesced || !resp.GetFields()["quiesced"].GetBoolValue() {
+		t.Error("quiesce: true did not enable quiesce mode")
+	}
+	if got := resp.GetFields()["active_streams"].GetNumberValue(); got != 3 {
+		t.Errorf("active_streams = %v, want 3", got)
+	}
+
+	// Without "quiesce" the state is only reported.
+	resp, err = Quiesce(context.Background(), conn, &structpb.Struct{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !resp.GetFields()["quiesced"].GetBoolValue() {
+		t.Error("status query changed the quiesce state")
+	}
+
+	bad, _ := structpb.NewStruct(map[string]any{"quiesce": "yes"})
+	if _, err := Quiesce(context.Background(), conn, bad); status.Code(err) != codes.InvalidArgument {
+		t.Errorf("non-bool quiesce: err = %v, want InvalidArgument", err)
+	}
+}
+
+func TestListActiveStreams(t *testing.T) {
+	svc := New(nil, nil)
+	conn := startAdmin(t, svc)
+
+	if _, err := ListActiveStreams(context.Background(), conn, &structpb.Struct{}); status.Code(err) != codes.Unavailable {
+		t.Fatalf("before SetServer: err = %v, want Unavailable", err)
+	}
+
+	opened := time.Now().Add(-time.Minute)
+	svc.SetServer(&fakeHistory{streams: []server.ActiveStream{
+		{SessionID: "sess-1", StreamID: "mic-1", Variant: "baseline", Peer: "10.0.0.7:5000", OpenedAt: opened, Frames: 1875},
+		{Peer: "10.0.0.8:5000", OpenedAt: time.Now()},
+	}})
+	resp, err := ListActiveStreams(context.Background(), conn, &structpb.Struct{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	streams := resp.GetFields()["streams"].GetListValue().GetValues()
+	if len(streams) != 2 {
+		t.Fatalf("streams = %v, want 2 entries", streams)
+	}
+	fields := streams[0].GetStructValue().GetFields()
+	if got := fields["session_id"].GetStringValue(); got != "sess-1" {
+		t.Errorf("session_id = %q, want sess-1", got)
+	}
+	if got := fields["frames"].GetNumberValue(); got != 1875 {
+		t.Errorf("frames = %v, want 1875", got)
+	}
+	if got := fields["uptime_ms"].GetNumberValue(); got < 60000 || got > 120000 {
+		t.Errorf("uptime_ms = %v, want about a minute", got)
+	}
+	if got := streams[1].GetStructValue().GetFields()["stream_id"].GetStringValue(); got != "" {
+		t.Errorf("stream without audio: stream_id = %q, want empty", got)
+	}
+}
+
+func TestSessions(t *testing.T) {
+	svc := New(nil, nil)
+	conn := startAdmin(t, svc)
+
+	if _, err := ListSessions(context.Background(), conn, &structpb.Struct{}); status.Code(err) != codes.Unavailable {
+		t.Fatalf("before SetServer: err = %v, want Unavailable", err)
+	}
+
+	opened := time.Now().Add(-time.Minute)
+	history := &fakeHistory{sessions: []server.ActiveSession{{
+		SessionID: "user-1",
+		OpenedAt:  opened,
+		Streams: []server.ActiveStream{
+			{SessionID: "user-1", StreamID: "far-field", OpenedAt: opened},
+			{SessionID: "user-1", StreamID: "headset", OpenedAt: opened.Add(time.Second)},
+		},
+	}}}
+	svc.SetServer(history)
+	resp, err := ListSessions(context.Background(), conn, &structpb.Struct{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	sessions := resp.GetFields()["sessions"].GetListValue().GetValues()
+	if len(sessions) != 1 {
+		t.Fatalf("sessions = %v, want 1 entry", sessions)
+	}
+	fields := sessions[0].GetStructValue().GetFields()
+	streams := fields["streams"].GetListValue().GetValues()
+	if fields["session_id"].GetStringValue() != "user-1" || len(streams) != 2 {
+		t.Fatalf("session = %v, want user-1 with 2 streams", fields)
+	}
+	if got := streams[1].GetStructValue().GetFields()["stream_id"].GetStringValue(); got != "headset" {
+		t.Errorf("second stream_id = %q, want headset", got)
+	}
+
+	req, _ := structpb.NewStruct(map[string]any{"session_id": "user-1"})
+	resp, err = CancelSession(context.Background(), conn, req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := resp.GetFields()["cancelled"].GetNumberValue(); got != 2 || len(history.cancelled) != 1 {
+		t.Errorf("cancelled = %v (calls %v), want 2 streams of user-1", got, history.cancelled)
+	}
+
+	req, _ = structpb.NewStruct(map[string]any{"session_id": "user-2"})
+	if _, err := CancelSession(context.Background(), conn, req); status.Code(err) != codes.NotFound {
+		t.Errorf("unknown session: err = %v, want NotFound", err)
+	}
+	if _, err := CancelSession(context.Background(), conn, &structpb.Struct{}); status.Code(err) != codes.InvalidArgument {
+		t.Errorf("no session_id: err = %v, want InvalidArgument", err)
+	}
+}
+
+func TestGetConfig(t *testing.T) {
+	svc := New(new(slog.LevelVar), nil)
+	conn := startAdmin(t, svc)
+
+	if _, err := GetConfig(context.Background(), conn, &structpb.Struct{}); status.Code(err) != codes.Unavailable {
+		t.Fatalf("before SetServer: err = %v, want Unavailable", err)
+	}
+
+	svc.SetServer(&fakeHistory{cfg: config.Config{
+		Engine:      "silero",
+		Threshold:   0.6,
+		MaxStreams:  32,
+		AuthToken:   "s3cret",
+		TLSCertFile: "/etc/vad/server.pem",
+	}})
+	resp, err := GetConfig(context.Background(), conn, &structpb.Struct{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(resp.String(), "s3cret") {
+		t.Errorf("response leaks the auth token: %v", resp)
+	}
+	cfg := resp.GetFields()["config"].GetStructValue().GetFields()
+	if got := cfg["engine"].GetStringValue(); got != "silero" {
+		t.Errorf("engine = %q, want silero", got)
+	}
+	if got := cfg["threshold"].GetNumberValue(); got != 0.6 {
+		t.Errorf("threshold = %v, want 0.6", got)
+	}
+	if got := cfg["max_streams"].GetNumberValue(); got != 32 {
+		t.Errorf("max_streams = %v, want 32", got)
+	}
+	if got := cfg["auth_token"].GetStringValue(); got != redacted {
+		t.Errorf("auth_token = %q, want %q", got, redacted)
+	}
+	if got := resp.GetFields()["log_level"].GetStringValue(); got != "info" {
+		t.Errorf("log_level = %q, want info", got)
+	}
+	if _, ok := resp.GetFields()["sources"]; ok {
+		t.Errorf("sources reported without a provenance: %v", resp.GetFields()["sources"])
+	}
+
+	svc.SetProvenance(config.Provenance{"threshold": "NUPI_VAD_THRESHOLD", "engine": config.SourceDefault})
+	resp, err = GetConfig(context.Background(), conn, &structpb.Struct{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	sources := resp.GetFields()["sources"].GetStructValue().GetFields()
+	if got := sources["threshold"].GetStringValue(); got != "NUPI_VAD_THRESHOLD" {
+		t.Errorf("sources.threshold = %q, want NUPI_VAD_THRESHOLD", got)
+	}
+	if got := sources["engine"].GetStringValue(); got != "default" {
+		t.Errorf("sources.engine = %q, want default", got)
+	}
+}
+
+func TestSetLogLevel(t *testing.T) {
+	if _, err := SetLogLevel(context.Background(), startAdmin(t, New(nil, nil)), &structpb.Struct{}); status.Code(err) != codes.Unimplemented {
+		t.Errorf("without a level var: err = %v, want Unimplemented", err)
+	}
+
+	level := new(slog.LevelVar)
+	var logs bytes.Buffer
+	conn := startAdmin(t, New(level, slog.New(slog.NewTextHandler(&logs, &slog.HandlerOptions{Level: level}))))
+
+	req, _ := structpb.NewStruct(map[string]any{"level": "DEBUG"})
+	resp, err := SetLogLevel(context.Background(), conn, req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if level.Level() != slog.LevelDebug {
+		t.Errorf("level = %v, want DEBUG", level.Level())
+	}
+	if got, prev := resp.GetFields()["level"].GetStringValue(), resp.GetFields()["previous"].GetStringValue(); got != "debug" || prev != "info" {
+		t.Errorf("response level = %q, previous = %q, want debug, info", got, prev)
+	}
+	if !strings.Contains(logs.String(), "log level changed") {
+		t.Errorf("level change not logged: %q", logs.String())
+	}
+
+	// Without "level" the call only reports it.
+	resp, err = SetLogLevel(context.Background(), conn, &structpb.Struct{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := resp.GetFields()["level"].GetStringValue(); got != "debug" || level.Level() != slog.LevelDebug {
+		t.Errorf("status query: level = %q (%v), want debug", got, level.Level())
+	}
+
+	bad, _ := structpb.NewStruct(map[string]any{"level": "verbose"})
+	if _, err := SetLogLevel(context.Background(), conn, bad); status.Code(err) != codes.InvalidArgument {
+		t.Errorf("unknown level: err = %v, want InvalidArgument", err)
+	}
+	if level.Level() != slog.LevelDebug {
+		t.Errorf("invalid request changed the level to %v", level.Level())
+	}
+}
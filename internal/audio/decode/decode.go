@@ -0,0 +1,200 @@
+// Package decode turns recorded audio files into the mono s16le PCM the VAD
+// engines take, so recordings can be segmented without external conversion
+// tools. WAV (RIFF/WAVE) is decoded natively; OGG and FLAC containers are
+// recognized and rejected with a hint, as decoding them needs codecs this
+// module does not vendor.
+package decode
+
+import (
+	"bytes"
+	"encoding/binary"
+	"math"
+
+	"github.com/nupi-ai/plugin-vad-local-silero/internal/audio"
+	"github.com/nupi-ai/plugin-vad-local-silero/internal/errs"
+)
+
+// WAV format tags (wFormatTag), and the GUID prefix of
+// WAVE_FORMAT_EXTENSIBLE subformats.
+const (
+	formatPCM        = 0x0001
+	formatFloat      = 0x0003
+	formatExtensible = 0xFFFE
+)
+
+// Audio is a decoded recording: mono s16le PCM at SampleRate, with the
+// source layout for reporting.
+type Audio struct {
+	PCM        []byte
+	SampleRate uint32
+	// Channels and BitDepth describe the source before downmixing and
+	// conversion to 16-bit.
+	Channels int
+	BitDepth int
+}
+
+// Container formats recognized by Container.
+const (
+	ContainerWAV  = "wav"
+	ContainerOGG  = "ogg"
+	ContainerFLAC = "flac"
+)
+
+// Container names the container format of data from its magic bytes:
+// ContainerWAV, ContainerOGG, ContainerFLAC, or "" when unknown.
+func Container(data []byte) string {
+	switch {
+	case len(data) >= 12 && string(data[:4]) == "RIFF" && string(data[8:12]) == "WAVE":
+		return ContainerWAV
+	case bytes.HasPrefix(data, []byte("OggS")):
+		return ContainerOGG
+	case bytes.HasPrefix(data, []byte("fLaC")):
+		return ContainerFLAC
+	default:
+		return ""
+	}
+}
+
+// File decodes a complete audio file. Multi-channel audio is downmixed to
+// mono by averaging; 8, 24 and 32-bit integer and 32/64-bit float samples
+// are converted to 16-bit. Errors are errs.ErrUnsupportedFormat for
+// containers and encodings that cannot be decoded and errs.ErrInvalidPCM for
+// malformed files.
+func File(data []byte) (Audio, error) {
+	switch c := Container(data); c {
+	case ContainerWAV:
+		return wav(data)
+	case ContainerOGG, ContainerFLAC:
+		return Audio{}, errs.New(errs.ErrUnsupportedFormat, "%s files are not supported, convert to WAV (e.g. ffmpeg -i in.%s out.wav)", c, c)
+	default:
+		return Audio{}, errs.New(errs.ErrUnsupportedFormat, "unrecognized audio file, expected a RIFF/WAVE file")
+	}
+}
+
+// wav decodes a RIFF/WAVE file.
+func wav(data []byte) (Audio, error) {
+	var (
+		format, channels, bits uint16
+		rate                   uint32
+		haveFmt                bool
+		samples                []byte
+	)
+	for pos := 12; pos+8 <= len(data); {
+		id := string(data[pos : pos+4])
+		size := int64(binary.LittleEndian.Uint32(data[pos+4 : pos+8]))
+		body := data[pos+8:]
+		if size < int64(len(body)) {
+			body = body[:size]
+		}
+		switch id {
+		case "fmt ":
+			if len(body) < 16 {
+				return Audio{}, errs.New(errs.ErrInvalidPCM, "wav: fmt chunk too short (%d bytes)", len(body))
+			}
+			format = binary.LittleEndian.Uint16(body[0:2])
+			channels = binary.LittleEndian.Uint16(body[2:4])
+			rate = binary.LittleEndian.Uint32(body[4:8])
+			bits = binary.LittleEndian.Uint16(body[14:16])
+			if format == formatExtensible {
+				if len(body) < 26 {
+					return Audio{}, errs.New(errs.ErrInvalidPCM, "wav: extensible fmt chunk too short (%d bytes)", len(body))
+				}
+				// The subformat GUID starts with the plain format tag.
+				format = binary.LittleEndian.Uint16(body[24:26])
+			}
+			haveFmt = true
+		case "data":
+			// Streamed WAVs may carry a placeholder size; the chunk then
+			// runs to the end of the file.
+			samples = body
+		}
+		if samples != nil {
+			break
+		}
+		pos += 8 + int(size) + int(size&1) // chunks are padded to even sizes
+	}
+	if !haveFmt {
+		return Audio{}, errs.New(errs.ErrInvalidPCM, "wav: missing fmt chunk")
+	}
+	if samples == nil {
+		return Audio{}, errs.New(errs.ErrInvalidPCM, "wav: missing data chunk")
+	}
+	if channels == 0 || rate == 0 {
+		return Audio{}, errs.New(errs.ErrInvalidPCM, "wav: invalid header (%d channels, %d Hz)", channels, rate)
+	}
+	if rate < audio.MinSampleRate || rate > audio.MaxSampleRate {
+		return Audio{}, errs.New(errs.ErrUnsupportedFormat, "wav: sample rate %d Hz outside %d-%d Hz", rate, audio.MinSampleRate, audio.MaxSampleRate)
+	}
+	sample, err := sampleReader(format, bits)
+	if err != nil {
+		return Audio{}, err
+	}
+
+	width := int(bits) / 8
+	frame := width * int(channels)
+	frames := len(samples) / frame // a truncated last frame is dropped
+	out := make([]byte, 0, 2*frames)
+	for i := 0; i < frames; i++ {
+		var sum float64
+		for c := 0; c < int(channels); c++ {
+			off := i*frame + c*width
+			sum += sample(samples[off : off+width])
+		}
+		out = binary.LittleEndian.AppendUint16(out, uint16(toInt16(sum/float64(channels))))
+	}
+	return Audio{PCM: out, SampleRate: rate, Channels: int(channels), BitDepth: int(bits)}, nil
+}
+
+// sampleReader returns the decoder of one sample of the given format to
+// [-1, 1).
+func sampleReader(format, bits uint16) (func([]byte) float64, error) {
+	switch {
+	case format == formatPCM && bits == 8:
+		return func(b []byte) float64 { return (float64(b[0]) - 128) / 128 }, nil
+	case format == formatPCM && bits == 16:
+		return func(b []byte) float64 { return float64(int16(binary.LittleEndian.Uint16(b))) / 32768 }, nil
+	case format == formatPCM && bits == 24:
+		return func(b []byte) float64 {
+			v := int32(b[0]) | int32(b[1])<<8 | int32(int8(b[2]))<<16
+			return float64(v) / (1 << 23)
+		}, nil
+	case format == formatPCM && bits == 32:
+		return func(b []byte) float64 { return float64(int32(binary.LittleEndian.Uint32(b))) / (1 << 31) }, nil
+	case format == formatFloat && bits == 32:
+		return func(b []byte) float64 { return float64(math.Float32frombits(binary.LittleEndian.Uint32(b))) }, nil
+	case format == formatFloat && bits == 64:
+		return func(b []byte) float64 { return math.Float64frombits(binary.LittleEndian.Uint64(b)) }, nil
+	default:
+		return nil, errs.New(errs.ErrUnsupportedFormat, "wav: unsupported encoding (format tag 0x%04x, %d bits), expected integer PCM or IEEE float", format, bits)
+	}
+}
+
+// toInt16 converts a sample in [-1, 1] to 16-bit, clipping out-of-range and
+// NaN float samples.
+func toInt16(v float64) int16 {
+	switch {
+	case math.IsNaN(v):
+		return 0
+	case v >= 32767.0/32768:
+		return math.MaxInt16
+	case v <= -1:
+		return math.MinInt16
+	default:
+		return int16(math.Round(v * 32768))
+	}
+}
+
+// Resample converts a to rate with audio.Resampler. It returns a unchanged
+// when it is already at rate.
+func (a Audio) Resample(rate uint32) (Audio, error) {
+	if a.SampleRate == rate {
+		return a, nil
+	}
+	r, err := audio.NewResampler(a.SampleRate, rate)
+	if err != nil {
+		return Audio{}, errs.New(errs.ErrUnsupportedFormat, "resample %d Hz to %d Hz: %v", a.SampleRate, rate, err)
+	}
+	a.PCM = r.Process(a.PCM)
+	a.SampleRate = rate
+	return a, nil
+}
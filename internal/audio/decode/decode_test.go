@@ -0,0 +1,138 @@
+package decode
+
+import (
+	"encoding/binary"
+	"errors"
+	"math"
+	"testing"
+
+	"github.com/nupi-ai/plugin-vad-local-silero/internal/errs"
+)
+
+// wavFile builds a RIFF/WAVE file with the given fmt fields and sample data.
+// extra chunks are inserted before "data".
+func wavFile(format, channels uint16, rate uint32, bits uint16, data []byte, extra ...[]byte) []byte {
+	fmtChunk := binary.LittleEndian.AppendUint16(nil, format)
+	fmtChunk = binary.LittleEndian.AppendUint16(fmtChunk, channels)
+	fmtChunk = binary.LittleEndian.AppendUint32(fmtChunk, rate)
+	fmtChunk = binary.LittleEndian.AppendUint32(fmtChunk, rate*uint32(channels*bits/8))
+	fmtChunk = binary.LittleEndian.AppendUint16(fmtChunk, channels*bits/8)
+	fmtChunk = binary.LittleEndian.AppendUint16(fmtChunk, bits)
+	if format == formatExtensible {
+		fmtChunk = binary.LittleEndian.AppendUint16(fmtChunk, 22)
+		fmtChunk = binary.LittleEndian.AppendUint16(fmtChunk, bits)
+		fmtChunk = binary.LittleEndian.AppendUint32(fmtChunk, 0)
+		fmtChunk = binary.LittleEndian.AppendUint16(fmtChunk, formatPCM)
+		fmtChunk = append(fmtChunk, make([]byte, 14)...)
+	}
+	body := []byte("WAVE")
+	body = appendChunk(body, "fmt ", fmtChunk)
+	for _, c := range extra {
+		body = append(body, c...)
+	}
+	body = appendChunk(body, "data", data)
+	out := append([]byte("RIFF"), binary.LittleEndian.AppendUint32(nil, uint32(len(body)))...)
+	return append(out, body...)
+}
+
+func appendChunk(b []byte, id string, data []byte) []byte {
+	b = append(b, id...)
+	b = binary.LittleEndian.AppendUint32(b, uint32(len(data)))
+	b = append(b, data...)
+	if len(data)%2 == 1 {
+		b = append(b, 0)
+	}
+	return b
+}
+
+func int16s(pcm []byte) []int16 {
+	out := make([]int16, len(pcm)/2)
+	for i := range out {
+		out[i] = int16(binary.LittleEndian.Uint16(pcm[2*i:]))
+	}
+	return out
+}
+
+func TestFileEncodings(t *testing.T) {
+	f32 := func(vs ...float32) []byte {
+		var b []byte
+		for _, v := range vs {
+			b = binary.LittleEndian.AppendUint32(b, math.Float32bits(v))
+		}
+		return b
+	}
+	tests := []struct {
+		name string
+		file []byte
+		want []int16
+	}{
+		{"pcm16", wavFile(formatPCM, 1, 16000, 16, []byte{0x00, 0x40, 0x00, 0xc0}), []int16{16384, -16384}},
+		{"pcm8", wavFile(formatPCM, 1, 8000, 8, []byte{128, 192, 0}), []int16{0, 16384, -32768}},
+		{"pcm24", wavFile(formatPCM, 1, 16000, 24, []byte{0, 0, 0x40, 0, 0, 0xc0}), []int16{16384, -16384}},
+		{"pcm32", wavFile(formatPCM, 1, 16000, 32, []byte{0, 0, 0, 0x40}), []int16{16384}},
+		{"float32 clipped", wavFile(formatFloat, 1, 16000, 32, f32(0.5, 2, -2)), []int16{16384, 32767, -32768}},
+		{"stereo downmix", wavFile(formatPCM, 2, 16000, 16, []byte{0x00, 0x40, 0x00, 0x00}), []int16{8192}},
+		{"extensible", wavFile(formatExtensible, 1, 16000, 16, []byte{0x00, 0x40}), []int16{16384}},
+		{"odd chunk before data", wavFile(formatPCM, 1, 16000, 16, []byte{0x00, 0x40}, appendChunk(nil, "LIST", []byte{1, 2, 3})), []int16{16384}},
+		{"truncated frame dropped", wavFile(formatPCM, 2, 16000, 16, []byte{0x00, 0x40, 0x00, 0x40, 0x00}), []int16{16384}},
+	}
+	for _, tt := range tests {
+		a, err := File(tt.file)
+		if err != nil {
+			t.Errorf("%s: %v", tt.name, err)
+			continue
+		}
+		got := int16s(a.PCM)
+		if len(got) != len(tt.want) {
+			t.Errorf("%s: samples = %v, want %v", tt.name, got, tt.want)
+			continue
+		}
+		for i := range got {
+			if got[i] != tt.want[i] {
+				t.Errorf("%s: samples = %v, want %v", tt.name, got, tt.want)
+				break
+			}
+		}
+	}
+}
+
+func TestFileErrors(t *testing.T) {
+	tests := []struct {
+		name string
+		data []byte
+		kind error
+	}{
+		{"ogg", []byte("OggS\x00\x02rest"), errs.ErrUnsupportedFormat},
+		{"flac", []byte("fLaC\x00\x00\x00\x22"), errs.ErrUnsupportedFormat},
+		{"unknown", make([]byte, 64), errs.ErrUnsupportedFormat},
+		{"alaw", wavFile(6, 1, 8000, 8, []byte{0}), errs.ErrUnsupportedFormat},
+		{"rate", wavFile(formatPCM, 1, 4000, 16, []byte{0, 0}), errs.ErrUnsupportedFormat},
+		{"no channels", wavFile(formatPCM, 0, 16000, 16, []byte{0, 0}), errs.ErrInvalidPCM},
+		{"no data", []byte("RIFF\x04\x00\x00\x00WAVE"), errs.ErrInvalidPCM},
+		{"not riff", []byte("OggX0000WAVE"), errs.ErrUnsupportedFormat},
+		{"truncated fmt", wavFile(formatPCM, 1, 16000, 16, []byte{0, 0})[:30], errs.ErrInvalidPCM},
+		{"data before fmt", append([]byte("RIFF\x00\x00\x00\x00WAVE"), appendChunk(nil, "data", []byte{0, 0})...), errs.ErrInvalidPCM},
+	}
+	for _, tt := range tests {
+		if _, err := File(tt.data); !errors.Is(err, tt.kind) {
+			t.Errorf("%s: err = %v, want %v", tt.name, err, tt.kind)
+		}
+	}
+}
+
+func TestResample(t *testing.T) {
+	a, err := File(wavFile(formatPCM, 1, 48000, 16, make([]byte, 2*48000)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	r, err := a.Resample(16000)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if r.SampleRate != 16000 || len(r.PCM) < 2*15900 || len(r.PCM) > 2*16000 {
+		t.Errorf("resampled to %d Hz, %d samples, want 16000 Hz, about 16000 samples", r.SampleRate, len(r.PCM)/2)
+	}
+	if same, _ := r.Resample(16000); len(same.PCM) != len(r.PCM) {
+		t.Error("Resample to the current rate changed the audio")
+	}
+}
@@ -0,0 +1,96 @@
+package dsp
+
+import (
+	"fmt"
+	"math"
+)
+
+const (
+	// agcBlockMs is the block the AGC measures levels over and ramps its
+	// gain across.
+	agcBlockMs = 10
+
+	// agcReleaseDb is how far the tracked level may fall per block (6 dB/s):
+	// a louder block is followed at once, so onsets do not clip, but pauses
+	// in speech barely raise the gain.
+	agcReleaseDb = 0.06
+
+	// agcSilenceDb is the block level (dBFS) below which the tracked level
+	// is left alone: digital silence says nothing about the speaker.
+	agcSilenceDb = -90.0
+)
+
+// AGC is an automatic gain control that brings a stream's level to a target,
+// so speech from quiet microphones reaches the engine at the level it was
+// trained on. It tracks the RMS level of 10 ms blocks, following louder
+// blocks at once and quieter ones slowly, and applies the gain that moves
+// that level to the target, capped at a maximum boost. The gain ramps
+// linearly across each block and output beyond full scale is clipped.
+type AGC struct {
+	targetDb, maxGainDb float64
+	block               int
+
+	levelDb float64 // tracked level in dBFS
+	gain    float64 // linear gain at the end of the last block
+	next    float64 // linear gain the current block ramps to
+	sumSq   float64
+	n       int
+}
+
+// NewAGC returns an AGC for PCM at sampleRate that aims for targetDbfs
+// (negative) and boosts by at most maxGainDb.
+func NewAGC(targetDbfs, maxGainDb float64, sampleRate uint32) (*AGC, error) {
+	if sampleRate == 0 || !(targetDbfs < 0) || !(maxGainDb >= 0) {
+		return nil, fmt.Errorf("agc: invalid target %g dBFS, max gain %g dB or sample rate %d", targetDbfs, maxGainDb, sampleRate)
+	}
+	a := &AGC{targetDb: targetDbfs, maxGainDb: maxGainDb, block: max(int(sampleRate)*agcBlockMs/1000, 1)}
+	a.Reset()
+	return a, nil
+}
+
+// GainDb returns the gain the AGC currently ramps to.
+func (a *AGC) GainDb() float64 {
+	return 20 * math.Log10(a.next)
+}
+
+// Process applies the gain to a chunk of s16le PCM, returning a new slice.
+// It adds no delay. An odd trailing byte is ignored.
+func (a *AGC) Process(pcm []byte) []byte {
+	out := make([]byte, 0, len(pcm)&^1)
+	for i := 0; i < len(pcm)/2; i++ {
+		x := readSample(pcm, i)
+		a.sumSq += x * x
+		a.n++
+		g := a.gain + (a.next-a.gain)*float64(a.n)/float64(a.block)
+		out = appendSample(out, x*g)
+		if a.n == a.block {
+			a.endBlock()
+		}
+	}
+	return out
+}
+
+// endBlock updates the tracked level from the finished block and sets the
+// gain the next block ramps to.
+func (a *AGC) endBlock() {
+	blockDb := 10 * math.Log10(max(a.sumSq/float64(a.n), 1e-12))
+	a.sumSq, a.n = 0, 0
+	a.gain = a.next
+	if blockDb < agcSilenceDb {
+		return
+	}
+	if blockDb > a.levelDb {
+		a.levelDb = blockDb
+	} else {
+		a.levelDb = max(a.levelDb-agcReleaseDb, blockDb)
+	}
+	a.next = math.Pow(10, min(a.targetDb-a.levelDb, a.maxGainDb)/20)
+}
+
+// Reset restarts level tracking at the maximum gain.
+func (a *AGC) Reset() {
+	a.levelDb = a.targetDb - a.maxGainDb
+	a.gain = math.Pow(10, a.maxGainDb/20)
+	a.next = a.gain
+	a.sumSq, a.n = 0, 0
+}
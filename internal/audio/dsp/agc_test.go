@@ -0,0 +1,70 @@
+package dsp
+
+import (
+	"math"
+	"testing"
+)
+
+// tone returns n s16le samples at 16 kHz of a 300 Hz sine whose RMS level
+// is levelDb dBFS.
+func tone(n int, levelDb float64) []byte {
+	amp := math.Sqrt2 * math.Pow(10, levelDb/20)
+	var pcm []byte
+	for i := 0; i < n; i++ {
+		pcm = appendSample(pcm, amp*math.Sin(2*math.Pi*300*float64(i)/16000))
+	}
+	return pcm
+}
+
+func TestAGCNormalizesLevel(t *testing.T) {
+	tests := []struct {
+		name              string
+		in, target, limit float64
+		want              float64
+	}{
+		{"quiet mic boosted", -50, -20, 40, -20},
+		{"boost capped", -60, -20, 20, -40},
+		{"loud input attenuated", -6, -20, 30, -20},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			a, err := NewAGC(tt.target, tt.limit, 16000)
+			if err != nil {
+				t.Fatal(err)
+			}
+			a.Process(tone(8000, tt.in)) // settle
+			out := a.Process(tone(16000, tt.in))
+			if len(out) != 32000 {
+				t.Fatalf("got %d bytes, want 32000", len(out))
+			}
+			if got := 20 * math.Log10(rms(out)); math.Abs(got-tt.want) > 1 {
+				t.Errorf("output level = %.1f dBFS, want %.1f", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestAGCHoldsGainThroughPauses(t *testing.T) {
+	a, err := NewAGC(-20, 30, 16000)
+	if err != nil {
+		t.Fatal(err)
+	}
+	a.Process(tone(16000, -30))
+	before := a.GainDb()
+	a.Process(make([]byte, 2*8000)) // half a second of digital silence
+	if after := a.GainDb(); math.Abs(after-before) > 0.1 {
+		t.Errorf("gain moved from %.1f to %.1f dB over silence", before, after)
+	}
+	a.Process(tone(8000, -60)) // half a second of faint noise
+	if after := a.GainDb(); after-before > 4 {
+		t.Errorf("gain rose from %.1f to %.1f dB in a half-second pause", before, after)
+	}
+}
+
+func TestNewAGCRejectsParams(t *testing.T) {
+	for _, p := range [][2]float64{{0, 30}, {3, 30}, {-20, -1}, {math.NaN(), 30}} {
+		if _, err := NewAGC(p[0], p[1], 16000); err == nil {
+			t.Errorf("NewAGC(%g, %g): no error", p[0], p[1])
+		}
+	}
+}
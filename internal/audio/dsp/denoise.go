@@ -0,0 +1,164 @@
+package dsp
+
+import (
+	"fmt"
+	"math"
+)
+
+const (
+	// denoiseFrameMs is the analysis frame, rounded up to a power of two
+	// samples: 512 at 16 kHz, 256 at 8 kHz. Frames overlap by half.
+	denoiseFrameMs = 32
+
+	// denoiseInitFrames frames at the stream start seed the noise estimate
+	// with their average spectrum (about 160 ms at 16 kHz), on the
+	// assumption that audio rarely opens with speech.
+	denoiseInitFrames = 10
+
+	// Per bin, the smoothed power follows the signal with denoiseSmoothing.
+	// Bins within denoiseNoiseRatio of the noise estimate count as noise and
+	// move the estimate by denoiseNoiseAdapt per frame (about 0.3 s at 16 ms
+	// hops); louder bins only raise it by denoiseNoiseRise (about 8 s), so
+	// speech barely leaks into the estimate but a new, louder background is
+	// eventually learned.
+	denoiseSmoothing  = 0.5
+	denoiseNoiseRatio = 3.0
+	denoiseNoiseAdapt = 0.05
+	denoiseNoiseRise  = 0.002
+
+	// denoiseOverSubtraction scales the noise power subtracted from the
+	// smoothed power, so bins at the noise level are fully attenuated.
+	denoiseOverSubtraction = 2.0
+	// denoiseMinGain limits the attenuation of any bin to -20 dB, which
+	// keeps the residual noise even instead of "musical".
+	denoiseMinGain = 0.1
+)
+
+// Denoiser removes stationary background noise (fans, hum, hiss) by
+// spectral subtraction: a per-frequency noise estimate, tracked over the
+// stream, is subtracted from each frame's power spectrum, and the frames are
+// overlap-added back into PCM. Speech and other non-stationary sounds pass
+// with little change.
+//
+// Output lags input by Latency samples. Each Process call returns as many
+// samples as there were whole hops of input, so over a stream the output
+// count matches the input count less the Buffered remainder.
+type Denoiser struct {
+	n, hop int
+	fft    *fft
+	window []float64 // sqrt-Hann: analysis and synthesis windows
+	in     []float64 // input not yet consumed by a hop, primed with n-hop zeros
+	out    []float64 // overlap-add accumulator, n samples
+	frame  []complex128
+
+	smoothed []float64 // per-bin smoothed power
+	noise    []float64 // per-bin noise power estimate
+	frames   int
+}
+
+// NewDenoiser returns a Denoiser for PCM at sampleRate.
+func NewDenoiser(sampleRate uint32) (*Denoiser, error) {
+	if sampleRate < 8000 || sampleRate > 48000 {
+		return nil, fmt.Errorf("denoise: unsupported sample rate %d", sampleRate)
+	}
+	n := 1
+	for n < int(sampleRate)*denoiseFrameMs/1000 {
+		n <<= 1
+	}
+	d := &Denoiser{
+		n:        n,
+		hop:      n / 2,
+		fft:      newFFT(n),
+		window:   make([]float64, n),
+		out:      make([]float64, n),
+		frame:    make([]complex128, n),
+		smoothed: make([]float64, n/2+1),
+		noise:    make([]float64, n/2+1),
+	}
+	// A periodic Hann window overlap-adds to one at half overlap; its square
+	// root, applied before and after, reconstructs the input exactly when no
+	// bin is attenuated.
+	for i := range d.window {
+		d.window[i] = math.Sqrt(0.5 - 0.5*math.Cos(2*math.Pi*float64(i)/float64(n)))
+	}
+	d.Reset()
+	return d, nil
+}
+
+// Latency returns the delay of the output in samples.
+func (d *Denoiser) Latency() int { return d.n - d.hop }
+
+// Buffered returns the input samples held short of the next hop.
+func (d *Denoiser) Buffered() int { return len(d.in) - (d.n - d.hop) }
+
+// Process denoises a chunk of s16le PCM and returns the output completed so
+// far. An odd trailing byte is ignored.
+func (d *Denoiser) Process(pcm []byte) []byte {
+	for i := 0; i < len(pcm)/2; i++ {
+		d.in = append(d.in, readSample(pcm, i))
+	}
+	var out []byte
+	consumed := 0
+	for len(d.in)-consumed >= d.n {
+		d.processFrame(d.in[consumed : consumed+d.n])
+		for _, v := range d.out[:d.hop] {
+			out = appendSample(out, v)
+		}
+		copy(d.out, d.out[d.hop:])
+		clear(d.out[d.n-d.hop:])
+		consumed += d.hop
+	}
+	d.in = d.in[:copy(d.in, d.in[consumed:])]
+	return out
+}
+
+// processFrame denoises one windowed frame and overlap-adds it into d.out.
+func (d *Denoiser) processFrame(samples []float64) {
+	for i, s := range samples {
+		d.frame[i] = complex(s*d.window[i], 0)
+	}
+	d.fft.transform(d.frame, false)
+
+	d.frames++
+	for k := range d.noise {
+		re, im := real(d.frame[k]), imag(d.frame[k])
+		power := re*re + im*im
+		switch {
+		case d.frames <= denoiseInitFrames:
+			d.smoothed[k] += (power - d.smoothed[k]) / float64(d.frames)
+			d.noise[k] = d.smoothed[k]
+		default:
+			d.smoothed[k] += (power - d.smoothed[k]) * denoiseSmoothing
+			if d.smoothed[k] < denoiseNoiseRatio*d.noise[k] {
+				d.noise[k] += (d.smoothed[k] - d.noise[k]) * denoiseNoiseAdapt
+			} else {
+				d.noise[k] += (d.smoothed[k] - d.noise[k]) * denoiseNoiseRise
+			}
+		}
+		gain := 1.0
+		if d.noise[k] > 0 {
+			gain = math.Sqrt(max(1-denoiseOverSubtraction*d.noise[k]/d.smoothed[k], denoiseMinGain*denoiseMinGain))
+		}
+		d.frame[k] *= complex(gain, 0)
+		if k > 0 && k < d.n/2 {
+			d.frame[d.n-k] *= complex(gain, 0) // keep the spectrum conjugate-symmetric
+		}
+	}
+
+	d.fft.transform(d.frame, true)
+	for i, v := range d.frame {
+		d.out[i] += real(v) * d.window[i]
+	}
+}
+
+// Reset drops buffered audio and the noise estimate.
+func (d *Denoiser) Reset() {
+	d.in = d.in[:0]
+	for i := 0; i < d.n-d.hop; i++ {
+		d.in = append(d.in, 0)
+	}
+	clear(d.out)
+	clear(d.smoothed)
+	clear(d.noise)
+	d.frames = 0
+}
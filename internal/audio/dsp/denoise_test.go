@@ -0,0 +1,118 @@
+package dsp
+
+import (
+	"math"
+	"math/cmplx"
+	"math/rand"
+	"testing"
+)
+
+func TestFFTMatchesDFT(t *testing.T) {
+	const n = 16
+	rng := rand.New(rand.NewSource(1))
+	x := make([]complex128, n)
+	for i := range x {
+		x[i] = complex(rng.Float64()-0.5, 0)
+	}
+	got := append([]complex128(nil), x...)
+	f := newFFT(n)
+	f.transform(got, false)
+	for k := 0; k < n; k++ {
+		var want complex128
+		for i, v := range x {
+			want += v * cmplx.Exp(complex(0, -2*math.Pi*float64(k*i)/n))
+		}
+		if cmplx.Abs(got[k]-want) > 1e-9 {
+			t.Fatalf("bin %d = %v, want %v", k, got[k], want)
+		}
+	}
+	f.transform(got, true)
+	for i := range x {
+		if cmplx.Abs(got[i]-x[i]) > 1e-12 {
+			t.Fatalf("inverse sample %d = %v, want %v", i, got[i], x[i])
+		}
+	}
+}
+
+// testSignal returns n s16le samples at 16 kHz: a 300 Hz tone at amplitude
+// tone plus uniform noise at amplitude noise.
+func testSignal(rng *rand.Rand, n int, tone, noise float64) []byte {
+	var pcm []byte
+	for i := 0; i < n; i++ {
+		pcm = appendSample(pcm, tone*math.Sin(2*math.Pi*300*float64(i)/16000)+noise*(2*rng.Float64()-1))
+	}
+	return pcm
+}
+
+func rms(pcm []byte) float64 {
+	var sum float64
+	for i := 0; i < len(pcm)/2; i++ {
+		s := readSample(pcm, i)
+		sum += s * s
+	}
+	return math.Sqrt(sum / float64(len(pcm)/2))
+}
+
+func TestDenoiserKeepsSampleCount(t *testing.T) {
+	d, err := NewDenoiser(16000)
+	if err != nil {
+		t.Fatal(err)
+	}
+	rng := rand.New(rand.NewSource(2))
+	in, out := 0, 0
+	for _, n := range []int{1, 100, 255, 256, 257, 1000, 3} {
+		out += len(d.Process(testSignal(rng, n, 0.1, 0.01))) / 2
+		in += n
+		if out+d.Buffered() != in {
+			t.Fatalf("after %d samples: %d out + %d buffered", in, out, d.Buffered())
+		}
+	}
+	if d.Latency() != 256 {
+		t.Errorf("Latency = %d, want 256", d.Latency())
+	}
+}
+
+func TestDenoiserPassesSignalAfterSilence(t *testing.T) {
+	d, err := NewDenoiser(16000)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// With a silent background nothing is subtracted: the output is the
+	// input delayed by Latency.
+	d.Process(make([]byte, 2*64*256)) // whole hops, so the chunk below is aligned
+	tone := testSignal(rand.New(rand.NewSource(3)), 1600, 0.3, 0)
+	out := d.Process(tone)
+	lag := d.Latency()
+	for i := lag; i < len(out)/2; i++ {
+		if diff := math.Abs(readSample(out, i) - readSample(tone, i-lag)); diff > 0.01 {
+			t.Fatalf("sample %d differs from the delayed input by %v", i, diff)
+		}
+	}
+}
+
+func TestDenoiserSuppressesStationaryNoise(t *testing.T) {
+	rng := rand.New(rand.NewSource(4))
+	d, err := NewDenoiser(16000)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Two seconds of noise to settle, then measure another second of it.
+	d.Process(testSignal(rng, 32000, 0, 0.05))
+	noise := testSignal(rng, 16000, 0, 0.05)
+	if gain := 20 * math.Log10(rms(d.Process(noise))/rms(noise)); gain > -10 {
+		t.Errorf("stationary noise attenuated by %.1f dB, want at least 10 dB", -gain)
+	}
+	// A tone well above the noise keeps its level.
+	speech := testSignal(rng, 8000, 0.3, 0.05)
+	if gain := 20 * math.Log10(rms(d.Process(speech))/rms(speech)); math.Abs(gain) > 3 {
+		t.Errorf("tone over noise changed by %.1f dB, want within 3 dB", gain)
+	}
+}
+
+func TestNewDenoiserRejectsRates(t *testing.T) {
+	for _, rate := range []uint32{0, 4000, 96000} {
+		if _, err := NewDenoiser(rate); err == nil {
+			t.Errorf("NewDenoiser(%d): no error", rate)
+		}
+	}
+}
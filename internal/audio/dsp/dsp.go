@@ -0,0 +1,23 @@
+// Package dsp contains the optional pre-processing stages the server runs on
+// a stream's PCM, at the engine's sample rate, before the engine sees it.
+// Stages take and return s16le PCM and keep their state per stream.
+package dsp
+
+import "math"
+
+// readSample returns s16le sample i of pcm normalized to [-1, 1).
+func readSample(pcm []byte, i int) float64 {
+	return float64(int16(uint16(pcm[2*i])|uint16(pcm[2*i+1])<<8)) / 32768.0
+}
+
+// appendSample appends v, clipped to the int16 range, to out as s16le.
+func appendSample(out []byte, v float64) []byte {
+	scaled := math.Round(v * 32768.0)
+	if scaled > math.MaxInt16 {
+		scaled = math.MaxInt16
+	} else if scaled < math.MinInt16 {
+		scaled = math.MinInt16
+	}
+	u := uint16(int16(scaled))
+	return append(out, byte(u), byte(u>>8))
+}
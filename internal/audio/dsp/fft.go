@@ -0,0 +1,58 @@
+package dsp
+
+import (
+	"math"
+	"math/bits"
+	"math/cmplx"
+)
+
+// fft is an in-place radix-2 FFT of a fixed power-of-two size.
+type fft struct {
+	n       int
+	twiddle []complex128 // exp(-2πik/n) for k < n/2
+	rev     []int        // bit-reversal permutation
+}
+
+func newFFT(n int) *fft {
+	if n < 2 || n&(n-1) != 0 {
+		panic("dsp: FFT size must be a power of two")
+	}
+	f := &fft{n: n, twiddle: make([]complex128, n/2), rev: make([]int, n)}
+	for k := range f.twiddle {
+		f.twiddle[k] = cmplx.Exp(complex(0, -2*math.Pi*float64(k)/float64(n)))
+	}
+	shift := bits.UintSize - bits.TrailingZeros(uint(n))
+	for i := range f.rev {
+		f.rev[i] = int(bits.Reverse(uint(i)) >> shift)
+	}
+	return f
+}
+
+// transform computes the forward FFT of x in place, or the inverse FFT
+// (scaled by 1/n) when inverse is set.
+func (f *fft) transform(x []complex128, inverse bool) {
+	for i, j := range f.rev {
+		if i < j {
+			x[i], x[j] = x[j], x[i]
+		}
+	}
+	for size := 2; size <= f.n; size <<= 1 {
+		half, step := size/2, f.n/size
+		for start := 0; start < f.n; start += size {
+			for k := 0; k < half; k++ {
+				w := f.twiddle[k*step]
+				if inverse {
+					w = cmplx.Conj(w)
+				}
+				a, b := x[start+k], w*x[start+k+half]
+				x[start+k], x[start+k+half] = a+b, a-b
+			}
+		}
+	}
+	if inverse {
+		scale := complex(1/float64(f.n), 0)
+		for i := range x {
+			x[i] *= scale
+		}
+	}
+}
@@ -0,0 +1,61 @@
+package dsp
+
+import (
+	"math"
+	"testing"
+)
+
+func TestHighPassRemovesDCOffset(t *testing.T) {
+	h, err := NewHighPass(80, 16000)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// A 1 kHz tone riding on a DC offset of a quarter of full scale.
+	var pcm []byte
+	for i := 0; i < 16000; i++ {
+		pcm = appendSample(pcm, 0.25+0.2*math.Sin(2*math.Pi*1000*float64(i)/16000))
+	}
+	out := h.Process(pcm)
+	if len(out) != len(pcm) {
+		t.Fatalf("got %d bytes, want %d", len(out), len(pcm))
+	}
+	// After the filter settles (tens of ms) the mean is gone and the tone
+	// keeps its level.
+	var sum, sq float64
+	settled := out[2*4000:]
+	for i := 0; i < len(settled)/2; i++ {
+		s := readSample(settled, i)
+		sum += s
+		sq += s * s
+	}
+	n := float64(len(settled) / 2)
+	if mean := sum / n; math.Abs(mean) > 0.005 {
+		t.Errorf("DC offset after filtering = %.4f, want ~0", mean)
+	}
+	if gain := 20 * math.Log10(math.Sqrt(sq/n)/(0.2/math.Sqrt2)); math.Abs(gain) > 0.5 {
+		t.Errorf("1 kHz tone changed by %.2f dB, want within 0.5 dB", gain)
+	}
+}
+
+func TestHighPassAttenuatesRumble(t *testing.T) {
+	h, err := NewHighPass(100, 16000)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var rumble []byte
+	for i := 0; i < 16000; i++ {
+		rumble = appendSample(rumble, 0.3*math.Sin(2*math.Pi*20*float64(i)/16000))
+	}
+	// A one-pole filter attenuates 20 Hz by about 14 dB at a 100 Hz cutoff.
+	if gain := 20 * math.Log10(rms(h.Process(rumble)[2*8000:])/rms(rumble)); gain > -12 {
+		t.Errorf("20 Hz rumble attenuated by %.1f dB, want at least 12 dB", -gain)
+	}
+}
+
+func TestNewHighPassRejectsCutoffs(t *testing.T) {
+	for _, cutoff := range []float64{0, -10, 8000, math.NaN()} {
+		if _, err := NewHighPass(cutoff, 16000); err == nil {
+			t.Errorf("NewHighPass(%g, 16000): no error", cutoff)
+		}
+	}
+}
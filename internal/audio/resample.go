@@ -0,0 +1,189 @@
+// Package audio contains PCM processing stages applied before audio reaches
+// a VAD engine.
+package audio
+
+import (
+	"fmt"
+	"math"
+)
+
+const (
+	// MinSampleRate and MaxSampleRate bound the input rates accepted by
+	// NewResampler.
+	MinSampleRate = 8000
+	MaxSampleRate = 192000
+
+	// resampleZeroCrossings is the number of sinc zero crossings on each
+	// side of the interpolation point. 8 gives a stopband well below what
+	// VAD models are sensitive to at a modest per-sample cost.
+	resampleZeroCrossings = 8
+
+	// resamplePhases is the number of precomputed fractional positions
+	// between two input samples. Interpolation points are rounded to the
+	// nearest phase.
+	resamplePhases = 256
+
+	// resampleRolloff places the low-pass cutoff slightly below the output
+	// Nyquist frequency to leave room for the filter's transition band.
+	resampleRolloff = 0.95
+)
+
+// Resampler converts a stream of mono s16le PCM from one sample rate to
+// another with a polyphase windowed-sinc filter. When downsampling, the
+// filter is also the anti-aliasing low-pass. A Resampler keeps the tail of
+// the previous chunk between calls, so chunk boundaries do not produce
+// discontinuities; it is not safe for concurrent use.
+type Resampler struct {
+	inRate  uint32
+	outRate uint32
+
+	// halfWidth is the number of input samples on each side of an output
+	// position that contribute to it.
+	halfWidth int
+	// table holds resamplePhases+1 filter kernels of 2*halfWidth taps.
+	// Kernel p applies to output positions p/resamplePhases past an input
+	// sample.
+	table [][]float32
+
+	// buf holds input samples from absolute index bufStart onwards.
+	buf      []float32
+	bufStart int64
+	// produced is the number of output samples emitted so far; the next
+	// output sample lies at input position produced*inRate/outRate.
+	produced int64
+}
+
+// NewResampler returns a Resampler converting inRate to outRate. Both rates
+// must be within [MinSampleRate, MaxSampleRate].
+func NewResampler(inRate, outRate uint32) (*Resampler, error) {
+	for _, rate := range []uint32{inRate, outRate} {
+		if rate < MinSampleRate || rate > MaxSampleRate {
+			return nil, fmt.Errorf("audio: sample rate %d outside supported range [%d, %d]", rate, MinSampleRate, MaxSampleRate)
+		}
+	}
+
+	// Cutoff relative to the input Nyquist frequency.
+	cutoff := resampleRolloff
+	if outRate < inRate {
+		cutoff *= float64(outRate) / float64(inRate)
+	}
+	halfWidth := int(math.Ceil(resampleZeroCrossings / cutoff))
+
+	table := make([][]float32, resamplePhases+1)
+	for p := range table {
+		frac := float64(p) / resamplePhases
+		kernel := make([]float32, 2*halfWidth)
+		var sum float64
+		for i := range kernel {
+			// Tap i weighs input sample floor(pos)-halfWidth+1+i.
+			x := float64(i-halfWidth+1) - frac
+			v := cutoff * sinc(cutoff*x) * hann(x/float64(halfWidth))
+			kernel[i] = float32(v)
+			sum += v
+		}
+		// Normalize so DC passes with unit gain at every phase.
+		if sum != 0 {
+			for i := range kernel {
+				kernel[i] = float32(float64(kernel[i]) / sum)
+			}
+		}
+		table[p] = kernel
+	}
+
+	return &Resampler{
+		inRate:    inRate,
+		outRate:   outRate,
+		halfWidth: halfWidth,
+		table:     table,
+	}, nil
+}
+
+// InRate returns the input sample rate.
+func (r *Resampler) InRate() uint32 { return r.inRate }
+
+// OutRate returns the output sample rate.
+func (r *Resampler) OutRate() uint32 { return r.outRate }
+
+// Process resamples one chunk of s16le PCM and returns the output samples
+// that can be computed so far, also as s16le. Output lags input by
+// halfWidth input samples (well under a millisecond at common rates); the
+// remainder is produced by later calls. An odd trailing byte is ignored.
+func (r *Resampler) Process(pcm []byte) []byte {
+	n := len(pcm) / 2
+	for i := 0; i < n; i++ {
+		s := int16(uint16(pcm[2*i]) | uint16(pcm[2*i+1])<<8)
+		r.buf = append(r.buf, float32(s)/32768.0)
+	}
+	bufEnd := r.bufStart + int64(len(r.buf))
+
+	var out []byte
+	for {
+		num := r.produced * int64(r.inRate)
+		base := num / int64(r.outRate)
+		// The last tap reads input sample base+halfWidth.
+		if base+int64(r.halfWidth) >= bufEnd {
+			break
+		}
+		rem := num % int64(r.outRate)
+		phase := int((rem*resamplePhases + int64(r.outRate)/2) / int64(r.outRate))
+		kernel := r.table[phase]
+
+		first := base - int64(r.halfWidth) + 1
+		var acc float32
+		for i, w := range kernel {
+			idx := first + int64(i) - r.bufStart
+			if idx >= 0 {
+				acc += w * r.buf[idx]
+			}
+			// Samples before the stream start are treated as silence.
+		}
+		out = appendSample(out, acc)
+		r.produced++
+	}
+
+	// Drop input no longer needed by any future output sample.
+	nextBase := r.produced * int64(r.inRate) / int64(r.outRate)
+	if drop := nextBase - int64(r.halfWidth) + 1 - r.bufStart; drop > 0 {
+		drop = min(drop, int64(len(r.buf)))
+		r.buf = append(r.buf[:0], r.buf[drop:]...)
+		r.bufStart += drop
+	}
+	return out
+}
+
+// Buffered returns the input samples held as filter history.
+func (r *Resampler) Buffered() int { return len(r.buf) }
+
+// Reset discards buffered input and restarts the output clock.
+func (r *Resampler) Reset() {
+	r.buf = r.buf[:0]
+	r.bufStart = 0
+	r.produced = 0
+}
+
+func appendSample(out []byte, v float32) []byte {
+	scaled := math.Round(float64(v) * 32768.0)
+	if scaled > math.MaxInt16 {
+		scaled = math.MaxInt16
+	} else if scaled < math.MinInt16 {
+		scaled = math.MinInt16
+	}
+	u := uint16(int16(scaled))
+	return append(out, byte(u), byte(u>>8))
+}
+
+func sinc(x float64) float64 {
+	if x == 0 {
+		return 1
+	}
+	px := math.Pi * x
+	return math.Sin(px) / px
+}
+
+// hann is a Hann window over [-1, 1], zero outside.
+func hann(x float64) float64 {
+	if x <= -1 || x >= 1 {
+		return 0
+	}
+	return 0.5 + 0.5*math.Cos(math.Pi*x)
+}
@@ -0,0 +1,129 @@
+package audio
+
+import (
+	"bytes"
+	"math"
+	"testing"
+)
+
+// sinePCM returns n samples of a sine tone as s16le PCM.
+func sinePCM(freq float64, rate uint32, n int, amp float64) []byte {
+	out := make([]byte, 0, 2*n)
+	for i := 0; i < n; i++ {
+		v := amp * math.Sin(2*math.Pi*freq*float64(i)/float64(rate))
+		u := uint16(int16(math.Round(v * 32767)))
+		out = append(out, byte(u), byte(u>>8))
+	}
+	return out
+}
+
+// rms returns the RMS of s16le PCM normalized to [-1, 1], skipping the
+// first skip samples (filter warm-up).
+func rms(pcm []byte, skip int) float64 {
+	var sum float64
+	n := 0
+	for i := skip; i < len(pcm)/2; i++ {
+		v := float64(int16(uint16(pcm[2*i])|uint16(pcm[2*i+1])<<8)) / 32768
+		sum += v * v
+		n++
+	}
+	if n == 0 {
+		return 0
+	}
+	return math.Sqrt(sum / float64(n))
+}
+
+func TestNewResamplerRejectsOutOfRangeRates(t *testing.T) {
+	for _, rates := range [][2]uint32{{4000, 16000}, {48000, 0}, {384000, 16000}} {
+		if _, err := NewResampler(rates[0], rates[1]); err == nil {
+			t.Errorf("NewResampler(%d, %d) = nil error, want error", rates[0], rates[1])
+		}
+	}
+}
+
+func TestResamplerOutputLength(t *testing.T) {
+	tests := []struct {
+		in, out uint32
+	}{
+		{48000, 16000},
+		{44100, 16000},
+		{22050, 16000},
+		{8000, 16000},
+	}
+	for _, tt := range tests {
+		r, err := NewResampler(tt.in, tt.out)
+		if err != nil {
+			t.Fatal(err)
+		}
+		// One second of input yields one second of output, less the
+		// filter look-ahead still buffered.
+		out := r.Process(make([]byte, 2*int(tt.in)))
+		got := len(out) / 2
+		lag := int(int64(r.halfWidth)*int64(tt.out)/int64(tt.in)) + 1
+		if got > int(tt.out) || got < int(tt.out)-lag {
+			t.Errorf("%d→%d: %d output samples, want within [%d, %d]", tt.in, tt.out, got, int(tt.out)-lag, tt.out)
+		}
+	}
+}
+
+func TestResamplerPreservesPassband(t *testing.T) {
+	for _, in := range []uint32{48000, 44100} {
+		r, err := NewResampler(in, 16000)
+		if err != nil {
+			t.Fatal(err)
+		}
+		out := r.Process(sinePCM(1000, in, int(in), 0.5))
+		want := 0.5 / math.Sqrt2
+		if got := rms(out, 100); math.Abs(got-want)/want > 0.02 {
+			t.Errorf("%d Hz input: 1 kHz tone RMS = %.4f, want %.4f ±2%%", in, got, want)
+		}
+	}
+}
+
+func TestResamplerAttenuatesAliases(t *testing.T) {
+	// 12 kHz is above the 8 kHz output Nyquist and must be filtered out
+	// rather than folded back to 4 kHz.
+	r, err := NewResampler(48000, 16000)
+	if err != nil {
+		t.Fatal(err)
+	}
+	out := r.Process(sinePCM(12000, 48000, 48000, 0.5))
+	if got := rms(out, 100); got > 0.5/math.Sqrt2*0.05 {
+		t.Errorf("12 kHz tone RMS after 48k→16k = %.4f, want < -26 dB of input", got)
+	}
+}
+
+func TestResamplerChunkingInvariant(t *testing.T) {
+	in := sinePCM(440, 44100, 44100, 0.8)
+
+	whole, err := NewResampler(44100, 16000)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := whole.Process(in)
+
+	chunked, err := NewResampler(44100, 16000)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got []byte
+	for off := 0; off < len(in); off += 882 { // 10ms chunks
+		got = append(got, chunked.Process(in[off:min(off+882, len(in))])...)
+	}
+	if !bytes.Equal(got, want) {
+		t.Fatalf("chunked output differs from single-call output (%d vs %d bytes)", len(got), len(want))
+	}
+}
+
+func TestResamplerReset(t *testing.T) {
+	r, err := NewResampler(48000, 16000)
+	if err != nil {
+		t.Fatal(err)
+	}
+	in := sinePCM(1000, 48000, 4800, 0.5)
+	first := r.Process(in)
+	r.Reset()
+	if second := r.Process(in); !bytes.Equal(first, second) {
+		t.Fatal("output after Reset differs from a fresh resampler")
+	}
+}
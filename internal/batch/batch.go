@@ -0,0 +1,148 @@
+// Package batch implements the adapter's unary detection service for offline
+// pipelines that segment whole files instead of streaming audio. NAP defines
+// no unary RPC, so the service is declared by hand: the request is a
+// DetectSpeechRequest carrying the complete buffer, the response a
+// google.protobuf.Struct documented on the method.
+package batch
+
+import (
+	"context"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	napv1 "github.com/nupi-ai/nupi/api/nap/v1"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/structpb"
+
+	"github.com/nupi-ai/plugin-vad-local-silero/internal/server"
+)
+
+// ServiceName is the fully qualified gRPC service name.
+const ServiceName = "nupi.vad.batch.v1.BatchService"
+
+// DetectSpeechInBufferMethod is the full method name, for clients and
+// interceptors.
+const DetectSpeechInBufferMethod = "/" + ServiceName + "/DetectSpeechInBuffer"
+
+// Detector is the server the batch service runs buffers through.
+type Detector interface {
+	DetectSpeechInBuffer(ctx context.Context, req *napv1.DetectSpeechRequest) (server.BufferResult, error)
+}
+
+// Service implements BatchService. It is registered before the VAD server is
+// ready and answers Unavailable until SetServer is called, like the VAD
+// service itself.
+type Service struct {
+	detector atomic.Pointer[Detector]
+}
+
+// New returns a Service with no server attached.
+func New() *Service {
+	return &Service{}
+}
+
+// SetServer attaches the running VAD server.
+func (s *Service) SetServer(d Detector) {
+	s.detector.Store(&d)
+}
+
+// Register adds the service to a gRPC server.
+func Register(r grpc.ServiceRegistrar, s *Service) {
+	r.RegisterService(&serviceDesc, s)
+}
+
+// DetectSpeechInBuffer segments a complete PCM buffer.
+//
+// The request is a DetectSpeechRequest with the whole buffer in pcm_data
+// and, as on a stream, format, config_json and optional session and stream
+// IDs. With format encoding "wav", pcm_data holds a WAV file instead (see
+// server.EncodingWAV). The buffer is bounded by the gRPC receive limit (see
+// server.MaxRecvMsgSize).
+//
+// Response: {"audio_ms", "speech_ms", "segments": [ {...}, ... ],
+// "events": [ {...}, ... ]}: one segment per START/END pair with start_ms,
+// end_ms and duration_ms, and every event the equivalent stream would have
+// sent with type (START, END, ONGOING or UNSPECIFIED for probability
+// samples), offset_ms and confidence. Offsets are milliseconds from the
+// buffer's first sample.
+func (s *Service) DetectSpeechInBuffer(ctx context.Context, req *napv1.DetectSpeechRequest) (*structpb.Struct, error) {
+	d := s.detector.Load()
+	if d == nil {
+		return nil, status.Error(codes.Unavailable, "VAD service is initializing, please retry in a moment")
+	}
+	res, err := (*d).DetectSpeechInBuffer(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+
+	var speech time.Duration
+	segments := make([]any, 0, len(res.Segments))
+	for _, sg := range res.Segments {
+		speech += sg.End - sg.Start
+		segments = append(segments, map[string]any{
+			"start_ms":    ms(sg.Start),
+			"end_ms":      ms(sg.End),
+			"duration_ms": ms(sg.End - sg.Start),
+		})
+	}
+	events := make([]any, 0, len(res.Events))
+	for _, evt := range res.Events {
+		events = append(events, map[string]any{
+			"type":       strings.TrimPrefix(evt.GetType().String(), "SPEECH_EVENT_TYPE_"),
+			"offset_ms":  ms(server.AudioOffset(evt, res.Epoch)),
+			"confidence": float64(evt.GetConfidence()),
+		})
+	}
+	return structpb.NewStruct(map[string]any{
+		"audio_ms":  ms(res.AudioDuration),
+		"speech_ms": ms(speech),
+		"segments":  segments,
+		"events":    events,
+	})
+}
+
+// ms renders d in fractional milliseconds.
+func ms(d time.Duration) float64 {
+	return float64(d) / float64(time.Millisecond)
+}
+
+// DetectSpeechInBuffer calls BatchService.DetectSpeechInBuffer on cc.
+func DetectSpeechInBuffer(ctx context.Context, cc grpc.ClientConnInterface, req *napv1.DetectSpeechRequest, opts ...grpc.CallOption) (*structpb.Struct, error) {
+	out := new(structpb.Struct)
+	if err := cc.Invoke(ctx, DetectSpeechInBufferMethod, req, out, opts...); err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// batchServer is the handler type checked by grpc.Server.RegisterService.
+type batchServer interface {
+	DetectSpeechInBuffer(context.Context, *napv1.DetectSpeechRequest) (*structpb.Struct, error)
+}
+
+func detectSpeechInBufferHandler(srv any, ctx context.Context, dec func(any) error, interceptor grpc.UnaryServerInterceptor) (any, error) {
+	in := new(napv1.DetectSpeechRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(batchServer).DetectSpeechInBuffer(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{Server: srv, FullMethod: DetectSpeechInBufferMethod}
+	handler := func(ctx context.Context, req any) (any, error) {
+		return srv.(batchServer).DetectSpeechInBuffer(ctx, req.(*napv1.DetectSpeechRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+var serviceDesc = grpc.ServiceDesc{
+	ServiceName: ServiceName,
+	HandlerType: (*batchServer)(nil),
+	Methods: []grpc.MethodDesc{
+		{MethodName: "DetectSpeechInBuffer", Handler: detectSpeechInBufferHandler},
+	},
+	Streams: []grpc.StreamDesc{},
+}
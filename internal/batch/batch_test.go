@@ -0,0 +1,86 @@
+package batch
+
+import (
+	"context"
+	"net"
+	"testing"
+
+	napv1 "github.com/nupi-ai/nupi/api/nap/v1"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/status"
+
+	"github.com/nupi-ai/plugin-vad-local-silero/internal/config"
+	"github.com/nupi-ai/plugin-vad-local-silero/internal/engine"
+	"github.com/nupi-ai/plugin-vad-local-silero/internal/server"
+)
+
+// startBatch serves svc on a loopback listener and returns a client
+// connection.
+func startBatch(t *testing.T, svc *Service) *grpc.ClientConn {
+	t.Helper()
+	lis, err := net.Listen("tcp", "localhost:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	srv := grpc.NewServer()
+	Register(srv, svc)
+	go srv.Serve(lis)
+	t.Cleanup(srv.Stop)
+
+	conn, err := grpc.NewClient(lis.Addr().String(), grpc.WithTransportCredentials(insecure.NewCredentials()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	return conn
+}
+
+func TestDetectSpeechInBuffer(t *testing.T) {
+	svc := New()
+	conn := startBatch(t, svc)
+	req := &napv1.DetectSpeechRequest{
+		Format:  &napv1.AudioFormat{SampleRate: 16000},
+		PcmData: make([]byte, 149*640),
+	}
+
+	if _, err := DetectSpeechInBuffer(context.Background(), conn, req); status.Code(err) != codes.Unavailable {
+		t.Fatalf("before SetServer: err = %v, want Unavailable", err)
+	}
+
+	cfg := config.Config{Threshold: 0.5, MinSpeechDurationMs: 20, MinSilenceDurationMs: 20}
+	svc.SetServer(server.New(cfg, nil, func() engine.Engine { return engine.NewStubEngine() }))
+	resp, err := DetectSpeechInBuffer(context.Background(), conn, req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	fields := resp.GetFields()
+	if got := fields["audio_ms"].GetNumberValue(); got != 2980 {
+		t.Errorf("audio_ms = %v, want 2980", got)
+	}
+	if got := fields["speech_ms"].GetNumberValue(); got != 1000 {
+		t.Errorf("speech_ms = %v, want 1000", got)
+	}
+	segments := fields["segments"].GetListValue().GetValues()
+	if len(segments) != 1 {
+		t.Fatalf("segments = %v, want 1 entry", segments)
+	}
+	sg := segments[0].GetStructValue().GetFields()
+	if start, end := sg["start_ms"].GetNumberValue(), sg["end_ms"].GetNumberValue(); start != 980 || end != 1980 {
+		t.Errorf("segment = [%v, %v], want [980, 1980]", start, end)
+	}
+	events := fields["events"].GetListValue().GetValues()
+	if len(events) == 0 {
+		t.Fatal("no events")
+	}
+	first := events[0].GetStructValue().GetFields()
+	if first["type"].GetStringValue() != "START" || first["offset_ms"].GetNumberValue() != 980 {
+		t.Errorf("first event = %v, want START at 980 ms", first)
+	}
+
+	req.PcmData = nil
+	if _, err := DetectSpeechInBuffer(context.Background(), conn, req); status.Code(err) != codes.InvalidArgument {
+		t.Errorf("empty buffer: err = %v, want InvalidArgument", err)
+	}
+}
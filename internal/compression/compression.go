@@ -0,0 +1,125 @@
+// Package compression registers the gRPC compressors the adapter accepts:
+// gzip and zstd. Edge devices on constrained links may compress their
+// requests with either (grpc-encoding), which roughly halves 16-bit PCM,
+// without any change to the protocol; the server answers with the
+// compressor of the request. Importing the package registers both, for the
+// server and for clients such as vadcli.
+package compression
+
+import (
+	"fmt"
+	"io"
+	"sync"
+
+	"github.com/klauspost/compress/zstd"
+	"google.golang.org/grpc/encoding"
+	"google.golang.org/grpc/encoding/gzip"
+)
+
+// Compressor names, as sent in grpc-encoding.
+const (
+	Gzip = gzip.Name
+	Zstd = "zstd"
+)
+
+// Names lists the registered compressors, for help and error messages.
+var Names = []string{Gzip, Zstd}
+
+// Check returns an error unless name is empty (no compression) or one of
+// Names.
+func Check(name string) error {
+	if name == "" || encoding.GetCompressor(name) != nil {
+		return nil
+	}
+	return fmt.Errorf("unknown compressor %q, want one of %v", name, Names)
+}
+
+// maxWindow bounds the zstd window a peer may ask the decoder to keep, so a
+// crafted frame cannot make it allocate more. Decompressed messages are
+// bounded by the gRPC receive limit.
+const maxWindow = 8 << 20
+
+func init() {
+	encoding.RegisterCompressor(&zstdCompressor{})
+}
+
+// zstdCompressor implements encoding.Compressor with pooled encoders and
+// decoders. Both run synchronously (concurrency 1): gRPC compresses each
+// message on the goroutine sending it.
+type zstdCompressor struct {
+	encoders sync.Pool
+	decoders sync.Pool
+}
+
+func (c *zstdCompressor) Name() string { return Zstd }
+
+func (c *zstdCompressor) Compress(w io.Writer) (io.WriteCloser, error) {
+	enc, _ := c.encoders.Get().(*zstd.Encoder)
+	if enc == nil {
+		var err error
+		// PCM has few repeated sequences: without entropy coding of
+		// literal-only blocks zstd would not shrink it at all.
+		enc, err = zstd.NewWriter(w,
+			zstd.WithEncoderConcurrency(1),
+			zstd.WithEncoderLevel(zstd.SpeedFastest),
+			zstd.WithAllLitEntropyCompression(true),
+		)
+		if err != nil {
+			return nil, err
+		}
+	} else {
+		enc.Reset(w)
+	}
+	return &zstdWriter{Encoder: enc, pool: &c.encoders}, nil
+}
+
+func (c *zstdCompressor) Decompress(r io.Reader) (io.Reader, error) {
+	dec, _ := c.decoders.Get().(*zstd.Decoder)
+	if dec == nil {
+		var err error
+		dec, err = zstd.NewReader(r,
+			zstd.WithDecoderConcurrency(1),
+			zstd.WithDecoderLowmem(true),
+			zstd.WithDecoderMaxWindow(maxWindow),
+		)
+		if err != nil {
+			return nil, err
+		}
+	} else if err := dec.Reset(r); err != nil {
+		c.decoders.Put(dec)
+		return nil, err
+	}
+	return &zstdReader{dec: dec, pool: &c.decoders}, nil
+}
+
+// zstdWriter returns its encoder to the pool once closed.
+type zstdWriter struct {
+	*zstd.Encoder
+	pool *sync.Pool
+}
+
+func (w *zstdWriter) Close() error {
+	err := w.Encoder.Close()
+	w.pool.Put(w.Encoder)
+	return err
+}
+
+// zstdReader returns its decoder to the pool once the message was read to
+// the end; a reader abandoned earlier leaves its decoder to the garbage
+// collector.
+type zstdReader struct {
+	dec  *zstd.Decoder
+	pool *sync.Pool
+}
+
+func (r *zstdReader) Read(p []byte) (int, error) {
+	if r.dec == nil {
+		return 0, io.EOF
+	}
+	n, err := r.dec.Read(p)
+	if err == io.EOF {
+		r.pool.Put(r.dec)
+		r.dec = nil
+	}
+	return n, err
+}
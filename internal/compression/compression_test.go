@@ -0,0 +1,60 @@
+package compression
+
+import (
+	"bytes"
+	"encoding/binary"
+	"io"
+	"math/rand"
+	"testing"
+
+	"google.golang.org/grpc/encoding"
+)
+
+func TestRoundTrip(t *testing.T) {
+	// A second of quiet noise as 16-bit PCM: no repeats, but small samples.
+	pcm := make([]byte, 32000)
+	rng := rand.New(rand.NewSource(1))
+	for i := 0; i < len(pcm); i += 2 {
+		binary.LittleEndian.PutUint16(pcm[i:], uint16(int16(rng.NormFloat64()*300)))
+	}
+	for _, name := range Names {
+		c := encoding.GetCompressor(name)
+		if c == nil {
+			t.Fatalf("%s is not registered", name)
+		}
+		// Twice, so the second round reuses pooled encoders and decoders.
+		for round := 0; round < 2; round++ {
+			var buf bytes.Buffer
+			w, err := c.Compress(&buf)
+			if err != nil {
+				t.Fatal(err)
+			}
+			w.Write(pcm)
+			if err := w.Close(); err != nil {
+				t.Fatal(err)
+			}
+			if buf.Len() >= len(pcm)*4/5 {
+				t.Errorf("%s: %d bytes compressed to %d", name, len(pcm), buf.Len())
+			}
+			r, err := c.Decompress(&buf)
+			if err != nil {
+				t.Fatal(err)
+			}
+			got, err := io.ReadAll(r)
+			if err != nil || !bytes.Equal(got, pcm) {
+				t.Fatalf("%s round %d: decompressed %d bytes, err %v", name, round, len(got), err)
+			}
+		}
+	}
+}
+
+func TestCheck(t *testing.T) {
+	for _, name := range []string{"", Gzip, Zstd} {
+		if err := Check(name); err != nil {
+			t.Errorf("Check(%q) = %v", name, err)
+		}
+	}
+	if err := Check("brotli"); err == nil {
+		t.Error("Check accepted brotli")
+	}
+}
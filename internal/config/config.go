@@ -3,7 +3,13 @@ package config
 import (
 	"fmt"
 	"math"
+	"net"
+	"net/url"
+	"strconv"
 	"strings"
+
+	"github.com/nupi-ai/plugin-vad-local-silero/internal/engine"
+	"github.com/nupi-ai/plugin-vad-local-silero/internal/timestamp"
 )
 
 const (
@@ -12,15 +18,235 @@ const (
 	DefaultMinSpeechDurationMs  = 250
 	DefaultMinSilenceDurationMs = 300
 
+	// NegThresholdOffset is how far below threshold the speech offset
+	// threshold sits when neg_threshold is not set, as in upstream Silero.
+	NegThresholdOffset = 0.15
+
+	// DefaultHopMs is the default stride between Silero windows: the full
+	// 32 ms window, i.e. no overlap.
+	DefaultHopMs = 32
+
+	// MaxInstanceIDLen bounds instance_id, which is sent as gRPC metadata.
+	MaxInstanceIDLen = 128
+
+	// MaxDriftReportIntervalSec bounds drift_report_interval_s (one day).
+	MaxDriftReportIntervalSec = 86400
+
+	// MaxStuckStreamTimeoutSec bounds stuck_stream_timeout_s (one day).
+	MaxStuckStreamTimeoutSec = 86400
+
+	// DefaultSelfTestIntervalSec is how often the health self-test runs an
+	// inference; MaxSelfTestIntervalSec bounds self_test_interval_s.
+	DefaultSelfTestIntervalSec = 30
+	MaxSelfTestIntervalSec     = 3600
+
+	// MaxStreamDurationLimitSec bounds max_stream_duration_s (one week) and
+	// MaxStreamAudioLimitMB max_stream_audio_mb (1 TiB).
+	MaxStreamDurationLimitSec = 7 * 86400
+	MaxStreamAudioLimitMB     = 1 << 20
+
 	// MaxDurationMs is the upper bound for min_speech_duration_ms and
 	// min_silence_duration_ms to prevent integer overflow in frame calculations.
 	MaxDurationMs = 60000 // 1 minute
+
+	// MinHeartbeatIntervalMs and MaxHeartbeatIntervalMs bound a non-zero
+	// heartbeat_interval_ms.
+	MinHeartbeatIntervalMs = 100
+	MaxHeartbeatIntervalMs = 600000
+
+	// DefaultRecentStreams is how many closed stream summaries are kept for
+	// the admin API by default.
+	DefaultRecentStreams = 100
+
+	// MaxRecentStreams bounds recent_streams.
+	MaxRecentStreams = 100000
+
+	// MaxAdaptiveSilenceGrowth bounds adaptive_silence.growth: at 10, one
+	// second of speech already asks for ten seconds of silence.
+	MaxAdaptiveSilenceGrowth = 10
+
+	// MaxSmoothingFrames bounds smoothing_frames (about 1.6 s at 32 ms).
+	MaxSmoothingFrames = 50
+
+	// MaxHighPassHz bounds high_pass_hz: higher cutoffs eat into the
+	// speech band.
+	MaxHighPassHz = 1000
+
+	// MaxExpectedStreams bounds expected_streams: each warm engine holds an
+	// ONNX session in memory.
+	MaxExpectedStreams = 4096
+
+	// MaxMaxStreams bounds max_streams.
+	MaxMaxStreams = 65536
+
+	// MaxSpeechDurationLimitMs bounds max_speech_duration_ms (1 hour).
+	MaxSpeechDurationLimitMs = 3600000
+
+	// DefaultMaxChunkBytes is the default limit on one pcm_data chunk:
+	// 1 MiB, about 32 seconds at 16 kHz mono s16le, the chunk size the
+	// engines' pooled sample buffers are sized for.
+	DefaultMaxChunkBytes = engine.MaxChunkBytes
+
+	// MinMaxChunkBytes and MaxMaxChunkBytes bound max_chunk_bytes. Every
+	// stream may hold a chunk this large in memory, so the ceiling stays far
+	// below gRPC's own 2 GiB message limit.
+	MinMaxChunkBytes = 1 << 10
+	MaxMaxChunkBytes = 16 << 20
+
+	// DefaultMaxBufferedBytes is the default limit on the PCM a stream holds
+	// between chunks: 1 MiB, far above the window or two engines buffer.
+	DefaultMaxBufferedBytes = 1 << 20
+
+	// MinMaxBufferedBytes and MaxMaxBufferedBytes bound max_buffered_bytes.
+	// The floor leaves room for a resampler's history and a full Silero
+	// window on both an ensemble's engines and a shadow engine.
+	MinMaxBufferedBytes = 16 << 10
+	MaxMaxBufferedBytes = 64 << 20
+
+	// MaxEventQueueSize bounds event_queue_size.
+	MaxEventQueueSize = 4096
+
+	// DefaultPipelineDepth is how many received requests a stream queues
+	// for inference by default, and MaxPipelineDepth bounds pipeline_depth.
+	DefaultPipelineDepth = 4
+	MaxPipelineDepth     = 64
+
+	// DefaultDebugDumpMaxStreamMB and DefaultDebugDumpMaxTotalMB are the
+	// debug dump caps when unset: 16 MiB is about 8.7 minutes at 16 kHz.
+	// MaxDebugDumpStreamMB and MaxDebugDumpTotalMB bound them.
+	DefaultDebugDumpMaxStreamMB = 16
+	DefaultDebugDumpMaxTotalMB  = 1024
+	MaxDebugDumpStreamMB        = 1024
+	MaxDebugDumpTotalMB         = 1 << 20
+
+	// MaxFeedbackStep bounds feedback.step and MaxFeedbackOffset
+	// feedback.max_offset: feedback may move a threshold, not replace it.
+	MaxFeedbackStep   = 0.1
+	MaxFeedbackOffset = 0.3
+
+	// DefaultFeedbackMaxOffset is feedback.max_offset when tuning is enabled
+	// without one.
+	DefaultFeedbackMaxOffset = 0.1
+
+	// DefaultCPUBudgetSliceSec is cpu_budget.slice_s when unset;
+	// MaxCPUBudgetSliceSec bounds it.
+	DefaultCPUBudgetSliceSec = 5
+	MaxCPUBudgetSliceSec     = 600
+
+	// MaxInferenceBudgetFrameMs bounds inference_budget.frame_ms.
+	MaxInferenceBudgetFrameMs = 1000
+
+	// DefaultInferenceBudgetFrames is inference_budget.frames when unset;
+	// MaxInferenceBudgetFrames bounds it.
+	DefaultInferenceBudgetFrames = 50
+	MaxInferenceBudgetFrames     = 10000
+
+	// MaxGRPCDurationSec bounds the grpc.* durations; MaxGRPCRecvMsgBytes
+	// bounds grpc.max_recv_msg_bytes.
+	MaxGRPCDurationSec  = 7 * 24 * 3600
+	MaxGRPCRecvMsgBytes = 64 << 20
+)
+
+// Valid CPUBudget.Action values; empty means CPUBudgetTerminate.
+const (
+	CPUBudgetTerminate = "terminate"
+	CPUBudgetDowngrade = "downgrade"
+)
+
+// Valid InferenceBudget.Action values; empty means InferenceBudgetWarn.
+const (
+	InferenceBudgetWarn = "warn"
+	InferenceBudgetSkip = "skip"
+)
+
+// Valid ListenNetwork values; empty means ListenTCP.
+const (
+	ListenTCP  = "tcp"  // IPv4 and IPv6 (dual-stack where the host allows it)
+	ListenTCP4 = "tcp4" // IPv4 only
+	ListenTCP6 = "tcp6" // IPv6 only
 )
 
-// Valid Engine values.
+// Valid LogFormat values; empty means LogFormatText.
+const (
+	LogFormatText = "text" // logfmt-style key=value lines
+	LogFormatJSON = "json" // one JSON object per line
+)
+
+// Valid ModelVersion values; empty means ModelV5.
+const (
+	ModelV4 = "v4"
+	ModelV5 = "v5"
+)
+
+// Valid ModelPrecision values; empty means PrecisionFP32.
+const (
+	PrecisionFP32 = "fp32"
+	PrecisionInt8 = "int8"
+)
+
+// Valid Model values: the model a stream runs. Empty means ModelA.
+const (
+	ModelA = "a" // the model of model_path, model_version and model_precision
+	ModelB = "b" // the model of model_b
+)
+
+// Built-in Engine values. Custom engines registered with engine.Register
+// are valid too.
 const (
 	EngineSilero = "silero"
 	EngineStub   = "stub"
+	EngineEnergy = "energy"
+)
+
+// Valid Ensemble.Mode values; empty means EnsembleAnd.
+const (
+	EnsembleAnd      = "and"
+	EnsembleOr       = "or"
+	EnsembleWeighted = "weighted"
+)
+
+// DefaultEnsembleWeight is the primary engine's weight in a weighted
+// ensemble when none is set.
+const DefaultEnsembleWeight = 0.5
+
+// DefaultAdaptiveThresholdMargin is adaptive_threshold.margin when unset.
+const DefaultAdaptiveThresholdMargin = 0.3
+
+// Frame skip bounds: frame_skip.stride lies in [2, MaxFrameSkipStride];
+// frame_skip.after_ms and frame_skip.margin default to
+// DefaultFrameSkipAfterMs and DefaultFrameSkipMargin, the margin staying
+// below MaxFrameSkipMargin.
+const (
+	MaxFrameSkipStride      = 4
+	DefaultFrameSkipAfterMs = 500
+	DefaultFrameSkipMargin  = 0.1
+	MaxFrameSkipMargin      = 0.5
+)
+
+// AGC bounds: agc.target_dbfs lies in [MinAGCTargetDbfs, 0) and
+// agc.max_gain_db in [0, MaxAGCGainDb], DefaultAGCMaxGainDb when unset.
+const (
+	MinAGCTargetDbfs    = -60
+	DefaultAGCMaxGainDb = 30
+	MaxAGCGainDb        = 60
+)
+
+// Valid SmoothingMethod values; empty means SmoothingMean.
+const (
+	SmoothingMean   = "mean"
+	SmoothingMedian = "median"
+)
+
+// Event queue overflow policies (event_queue_policy).
+const (
+	// EventQueueDropOngoing drops ONGOING and probability events when the
+	// queue is full. START and END are never dropped: one arriving at a full
+	// queue replaces its oldest droppable event, or waits for room when
+	// there is none.
+	EventQueueDropOngoing = "drop_ongoing"
+	// EventQueueBlock drops nothing: a full queue stalls audio processing
+	// until the client catches up, as without a queue.
+	EventQueueBlock = "block"
 )
 
 // Config holds the adapter configuration.
@@ -33,46 +259,1585 @@ type Config struct {
 	Engine               string  `json:"engine"`
 	ListenAddr           string  `json:"listen_addr"`
 	LogLevel             string  `json:"log_level"`
+	LogFormat            string  `json:"log_format"`
+	InstanceID           string  `json:"instance_id"`
 	Threshold            float64 `json:"threshold"`
 	MinSpeechDurationMs  int     `json:"min_speech_duration_ms"`
 	MinSilenceDurationMs int     `json:"min_silence_duration_ms"`
+
+	// Preset names the tuning preset (see PresetNames) whose threshold,
+	// min_speech_duration_ms, min_silence_duration_ms and preroll_ms apply
+	// unless set explicitly; empty for none.
+	Preset string `json:"preset"`
+
+	// StubScript is a JSON file with a frame script (see engine.ParseScript)
+	// the stub engine follows instead of its fixed toggle, for integration
+	// tests that need specific boundary scenarios. It requires engine "stub".
+	StubScript string `json:"stub_script"`
+
+	// ListenAddr above may hold several comma-separated host:port bind
+	// addresses; IPv6 hosts must be bracketed, e.g. "[::1]:50051".
+	// ListenNetwork restricts every listener to IPv4 ("tcp4") or IPv6
+	// ("tcp6"); empty means "tcp".
+	ListenNetwork string `json:"listen_network"`
+
+	// ProxyProtocol requires a PROXY protocol (v1 or v2) header on every
+	// accepted connection, so the client address behind an L4 load balancer
+	// survives. Connections without one are closed.
+	ProxyProtocol bool `json:"proxy_protocol"`
+
+	// TLSCertFile and TLSKeyFile, PEM files, serve gRPC over TLS instead of
+	// plaintext. TLSClientCAFile, a PEM bundle, additionally requires every
+	// client to present a certificate signed by one of its CAs (mTLS).
+	TLSCertFile     string `json:"tls_cert_file"`
+	TLSKeyFile      string `json:"tls_key_file"`
+	TLSClientCAFile string `json:"tls_client_ca_file"`
+
+	// AuthToken, when set, is the shared secret every RPC except health
+	// checks must send in the authorization metadata, as "Bearer <token>"
+	// or bare. It is never logged.
+	AuthToken string `json:"auth_token"`
+
+	// ConfigFile is the NUPI_ADAPTER_CONFIG_FILE the config was loaded from,
+	// if any. With ConfigWatch, changes to the file's VAD parameters apply
+	// to new streams without a restart (see TunableParams). Both are set
+	// from the environment only.
+	ConfigFile  string `json:"config_file"`
+	ConfigWatch bool   `json:"config_watch"`
+
+	// ModelPath loads the Silero ONNX model from a file at startup instead of
+	// the copy embedded in the binary, so models can be updated without a
+	// rebuild. ModelSHA256, when set, is the file's expected hex SHA-256
+	// digest. A file that is missing, fails the checksum or does not load
+	// falls back to the embedded model.
+	ModelPath   string `json:"model_path"`
+	ModelSHA256 string `json:"model_sha256"`
+
+	// ModelVersion selects the Silero generation: "v5" (default) or "v4",
+	// which some deployments prefer on far-field audio. A ModelPath file
+	// must be of this generation.
+	ModelVersion string `json:"model_version"`
+
+	// ModelPrecision selects the model's weight precision: "fp32" (default)
+	// or "int8", which roughly halves inference CPU on ARM edge devices. A
+	// ModelPath file must be of this precision.
+	ModelPrecision string `json:"model_precision"`
+
+	// ModelB loads a second model next to the one above (model "a"), for
+	// A/B evaluation on production traffic. Model selects the one a stream
+	// runs, usually per stream from config_json.
+	ModelB SecondModel `json:"model_b"`
+	Model  string      `json:"model"`
+
+	// ORT tunes the ONNX Runtime session each silero stream runs.
+	ORT ORT `json:"ort"`
+
+	// Integrity checks the ONNX Runtime library and the embedded model
+	// against known-good SHA-256 digests at startup.
+	Integrity Integrity `json:"integrity"`
+
+	// AdaptiveSilence, when enabled, replaces MinSilenceDurationMs with a
+	// silence that grows with the length of the ongoing segment.
+	AdaptiveSilence AdaptiveSilence `json:"adaptive_silence"`
+
+	// AdaptiveThreshold, when enabled, moves the stream's threshold with its
+	// measured noise floor instead of keeping Threshold fixed.
+	AdaptiveThreshold AdaptiveThreshold `json:"adaptive_threshold"`
+
+	// FrameSkip, when enabled, scores only every few frames while the
+	// stream stays confidently in speech or in silence.
+	FrameSkip FrameSkip `json:"frame_skip"`
+
+	// NegThreshold is the probability below which a frame counts as silence
+	// once speech has started; frames between it and Threshold keep the
+	// current state, so probabilities hovering around Threshold do not flap.
+	// Zero means Threshold - NegThresholdOffset.
+	NegThreshold float64 `json:"neg_threshold"`
+
+	// MaxSpeechDurationMs force-splits speech segments that run longer: a
+	// SPEECH_END is emitted immediately followed by a new SPEECH_START, so
+	// downstream ASR can flush very long utterances. Zero disables it.
+	MaxSpeechDurationMs int `json:"max_speech_duration_ms"`
+
+	// MinGapMs merges segments separated by short pauses: a SPEECH_END is
+	// held back for MinGapMs and, if speech starts again within that window,
+	// both the END and the new START are dropped and the original segment
+	// continues. Zero emits SPEECH_END as soon as min silence is reached.
+	MinGapMs int `json:"min_gap_ms"`
+
+	// PrerollMs moves each SPEECH_START timestamp back from the frame where
+	// hysteresis confirmed speech to the onset of that speech run, minus
+	// PrerollMs, so consumers can recover the leading phonemes from their own
+	// audio. Zero keeps START at the confirming frame.
+	PrerollMs int `json:"preroll_ms"`
+
+	// SmoothingFrames smooths frame probabilities over the last N frames
+	// before the threshold and hysteresis logic, so jittery output on noisy
+	// audio does not flap START/END. SmoothingMethod is "mean" (moving
+	// average, the default) or "median". Zero or one disables smoothing.
+	SmoothingFrames int    `json:"smoothing_frames"`
+	SmoothingMethod string `json:"smoothing_method"`
+
+	// Ensemble runs a second engine on each stream and combines the two
+	// engines' probabilities before the boundary detector. Experimental.
+	Ensemble Ensemble `json:"ensemble"`
+
+	// Denoise runs spectral-subtraction noise suppression on the stream's
+	// audio before the engine, for fan, hum and keyboard noise. It cannot
+	// change once a stream's audio started.
+	Denoise bool `json:"denoise"`
+
+	// HighPassHz is the cutoff of a one-pole high-pass filter run on the
+	// stream's audio before the engine, removing DC offset and rumble.
+	// Zero disables it. It cannot change once a stream's audio started.
+	HighPassHz int `json:"high_pass_hz"`
+
+	// AGC normalizes the stream's level before the engine, so quiet
+	// microphones still cross the threshold. It cannot change once a
+	// stream's audio started.
+	AGC AGC `json:"agc"`
+
+	// EmitProbabilities streams every frame's raw speech probability to the
+	// client in addition to boundary events. It is only set per stream, with
+	// emit_probabilities in config_json.
+	EmitProbabilities bool `json:"-"`
+
+	// HeartbeatIntervalMs sends a heartbeat event to a stream's client once
+	// no event was sent for this long, so quiet streams can be told apart
+	// from dead connections. Streams may set their own with
+	// heartbeat_interval_ms in config_json. Zero disables it.
+	HeartbeatIntervalMs int `json:"heartbeat_interval_ms"`
+
+	// HopMs is the stride between Silero inference windows: 32 (default,
+	// non-overlapping), 16 or 8. Shorter hops run the 32 ms window on
+	// overlapping audio, detecting onsets sooner at 2x or 4x the inference
+	// cost. Duration parameters keep their meaning in milliseconds; the
+	// boundary detector converts them using the hop.
+	HopMs int `json:"hop_ms"`
+
+	// Candidate is an alternative set of default VAD parameters rolled out to
+	// CandidatePercent of new streams (blue/green tuning). Fields left nil
+	// inherit the baseline value above.
+	Candidate        VADParams `json:"candidate"`
+	CandidatePercent int       `json:"candidate_percent"`
+
+	// ShadowEngine runs alongside the primary engine on ShadowPercent of
+	// streams. Its decisions are never sent to clients; they are only compared
+	// with the primary's to record divergence metrics. An empty ShadowEngine
+	// reuses the primary engine type, so Shadow alone can evaluate a config.
+	ShadowEngine  string    `json:"shadow_engine"`
+	Shadow        VADParams `json:"shadow"`
+	ShadowPercent int       `json:"shadow_percent"`
+
+	// AllowResampling lets clients stream any rate in
+	// [audio.MinSampleRate, audio.MaxSampleRate]; audio the engine does not
+	// support natively is resampled to 16 kHz. When off, such streams are
+	// rejected.
+	AllowResampling bool `json:"allow_resampling"`
+
+	// StrictProto fails streams whose requests carry protobuf fields this
+	// build does not know, instead of ignoring them, for certification
+	// environments that must rule out silent feature loss between versions.
+	StrictProto bool `json:"strict_proto"`
+
+	// Stateless rejects, at startup, every option that would write node-local
+	// state (dumps, journals, feedback tuning, ...), so autoscaled replicas
+	// stay interchangeable and a stream's outcome never depends on the node
+	// that served it.
+	Stateless bool `json:"stateless"`
+
+	// TraceDir and TraceStreamID enable the per-frame NDJSON trace: streams
+	// whose stream ID or session ID equals TraceStreamID write every frame's
+	// probability, decision, detector counters and events to a file in
+	// TraceDir. Both must be set together.
+	TraceDir      string `json:"trace_dir"`
+	TraceStreamID string `json:"trace_stream_id"`
+
+	// DebugDumpDir enables debug dumps: streams with DebugDump set (all
+	// streams when it is set server-wide, otherwise those asking for it in
+	// config_json) write their received PCM as WAV and their frame trace to
+	// this directory. DebugDumpMaxStreamMB caps the audio kept per stream and
+	// DebugDumpMaxTotalMB the directory, oldest dumps being deleted first;
+	// zero means the defaults.
+	DebugDumpDir         string `json:"debug_dump_dir"`
+	DebugDump            bool   `json:"debug_dump"`
+	DebugDumpMaxStreamMB int    `json:"debug_dump_max_stream_mb"`
+	DebugDumpMaxTotalMB  int    `json:"debug_dump_max_total_mb"`
+
+	// BillingOutput enables per-stream completion records (one JSON object
+	// per line) for billing collectors: "stdout", "stderr", "fd:<n>" for a
+	// descriptor inherited from the parent process, or a file path, which is
+	// appended to. Empty disables the records.
+	BillingOutput string `json:"billing_output"`
+
+	// SegmentSink forwards the audio of every closed speech segment,
+	// pre-roll included, as the client sent it: "dir:<path>" writes one WAV
+	// file per segment to path, "unix:<path>" sends each segment over a new
+	// connection to the Unix socket at path. Empty disables it.
+	SegmentSink string `json:"segment_sink"`
+
+	// TimestampFormat selects how event timestamps are rendered by gateways
+	// and command-line output: "rfc3339" (UTC), "epoch_ms" or "offset_ms"
+	// (audio offset only); see package timestamp. Empty keeps each output's
+	// own default.
+	TimestampFormat string `json:"timestamp_format"`
+
+	// MetricsAddr is the host:port of an optional HTTP listener serving the
+	// adapter's counters in expvar format at /debug/vars. Empty disables it.
+	MetricsAddr string `json:"metrics_addr"`
+
+	// GatewayAddr is the host:port of an optional HTTP listener for clients
+	// that cannot use gRPC, such as browsers: a WebSocket bridge to
+	// DetectSpeech at /v1/stream (see package gateway). It serves TLS when
+	// the gRPC port does. Empty disables it.
+	GatewayAddr string `json:"gateway_addr"`
+
+	// GatewayOrigins is a comma-separated list of the origins
+	// (scheme://host[:port]) browser pages may open gateway WebSockets
+	// from; "*" allows any. Empty allows only pages served from the
+	// gateway's own host.
+	GatewayOrigins string `json:"gateway_origins"`
+
+	// RecentStreams is the number of closed stream summaries kept in memory
+	// for the admin ListRecentStreams RPC. Zero disables the history.
+	RecentStreams int `json:"recent_streams"`
+
+	// ExpectedStreams is the number of engines created at startup and kept
+	// warm for new streams, so a burst of streams does not pay for engine
+	// creation on its first audio. Zero creates engines on demand.
+	ExpectedStreams int `json:"expected_streams"`
+
+	// MaxStreams caps the DetectSpeech streams open at once; further streams
+	// are rejected as overloaded rather than slowing every stream's
+	// inference down. Zero means no cap.
+	MaxStreams int `json:"max_streams"`
+
+	// MaxChunkBytes limits the size of one pcm_data chunk; larger chunks
+	// fail the stream. Zero means DefaultMaxChunkBytes. The gRPC receive
+	// limit is derived from it (server.MaxRecvMsgSize).
+	MaxChunkBytes int `json:"max_chunk_bytes"`
+
+	// GRPC tunes the gRPC server's connection management: keepalive pings,
+	// connection lifetimes and the receive limit. The zero value keeps the
+	// gRPC defaults and the receive limit derived from MaxChunkBytes.
+	GRPC GRPCServer `json:"grpc"`
+
+	// MaxBufferedBytes limits the PCM a stream holds between chunks: audio
+	// its engines (primary and shadow) buffered short of a frame plus the
+	// resampler's history. A stream exceeding it fails with
+	// BUFFER_LIMIT_EXCEEDED. Zero means DefaultMaxBufferedBytes.
+	MaxBufferedBytes int `json:"max_buffered_bytes"`
+
+	// EventQueueSize, when positive, sends each stream's events from a
+	// goroutine through a queue of this many events, so a client slow to
+	// read them does not stall audio processing. EventQueuePolicy decides
+	// what happens when the queue is full (see EventQueueDropOngoing and
+	// EventQueueBlock). Zero sends every event before the next chunk is
+	// processed.
+	EventQueueSize   int    `json:"event_queue_size"`
+	EventQueuePolicy string `json:"event_queue_policy"`
+
+	// PipelineDepth is how many received requests wait for inference while
+	// the stream keeps reading; once they are queued the stream stops
+	// reading, and gRPC flow control holds the client back. Every queued
+	// request may carry a chunk of up to MaxChunkBytes. Zero means
+	// DefaultPipelineDepth.
+	PipelineDepth int `json:"pipeline_depth"`
+
+	// MaxStreamDurationSec and MaxStreamAudioMB bound a stream's wall time
+	// from its first request and the PCM it may send (MiB). A stream
+	// reaching either gets the END of an open segment and then fails with
+	// STREAM_LIMIT_EXCEEDED. Zero means no limit.
+	MaxStreamDurationSec int `json:"max_stream_duration_s"`
+	MaxStreamAudioMB     int `json:"max_stream_audio_mb"`
+
+	// DriftReportIntervalSec enables periodic fleet-wide confidence
+	// distribution reports. Zero disables drift monitoring.
+	DriftReportIntervalSec int `json:"drift_report_interval_s"`
+
+	// StuckStreamTimeoutSec enables the stream watchdog: a stream whose
+	// Recv has waited, or whose Send has blocked, longer than this is
+	// terminated and its engine freed, e.g. when the client vanished behind
+	// NAT without a TCP reset. Zero disables the watchdog.
+	StuckStreamTimeoutSec int `json:"stuck_stream_timeout_s"`
+
+	// SelfTestIntervalSec is how often a probe engine runs one inference on
+	// a canned buffer; while it fails, health reports NOT_SERVING. Zero
+	// disables the self-test.
+	SelfTestIntervalSec int `json:"self_test_interval_s"`
+
+	// CPUBudget limits the inference time each stream may use, so one
+	// stream cannot monopolize a shared instance.
+	CPUBudget CPUBudget `json:"cpu_budget"`
+
+	// InferenceBudget is a per-frame inference deadline, so streams that
+	// fall behind real time under CPU contention are visible and, with
+	// action "skip", degrade instead of lagging further.
+	InferenceBudget InferenceBudget `json:"inference_budget"`
+
+	// Feedback tunes each rollout variant's threshold from the segments
+	// downstream consumers report as false positives or false negatives.
+	Feedback FeedbackTuning `json:"feedback"`
+}
+
+// FeedbackTuning configures the feedback loop. Every report is counted;
+// with Step set, each false positive also raises the reporting variant's
+// threshold by Step and each false negative lowers it, within MaxOffset of
+// the configured value. StateFile, when set, keeps the learned offsets
+// across restarts. The zero value only counts reports.
+type FeedbackTuning struct {
+	Step      float64 `json:"step"`
+	MaxOffset float64 `json:"max_offset"`
+	StateFile string  `json:"state_file"`
+}
+
+// Enabled reports whether feedback adjusts thresholds.
+func (f FeedbackTuning) Enabled() bool {
+	return f.Step > 0
+}
+
+// EffectiveMaxOffset returns MaxOffset, or DefaultFeedbackMaxOffset when
+// unset.
+func (f FeedbackTuning) EffectiveMaxOffset() float64 {
+	if f.MaxOffset == 0 {
+		return DefaultFeedbackMaxOffset
+	}
+	return f.MaxOffset
+}
+
+func (f *FeedbackTuning) validate() error {
+	f.StateFile = strings.TrimSpace(f.StateFile)
+	if math.IsNaN(f.Step) || f.Step < 0 || f.Step > MaxFeedbackStep {
+		return fmt.Errorf("config: feedback.step must be in [0, %g], got %f (set NUPI_VAD_FEEDBACK_STEP)", MaxFeedbackStep, f.Step)
+	}
+	if math.IsNaN(f.MaxOffset) || f.MaxOffset < 0 || f.MaxOffset > MaxFeedbackOffset {
+		return fmt.Errorf("config: feedback.max_offset must be in [0, %g], got %f (set NUPI_VAD_FEEDBACK_MAX_OFFSET)", MaxFeedbackOffset, f.MaxOffset)
+	}
+	if f.StateFile != "" && !f.Enabled() {
+		return fmt.Errorf("config: feedback.state_file requires feedback.step (set NUPI_VAD_FEEDBACK_STEP)")
+	}
+	return nil
+}
+
+// GRPCServer configures the gRPC server's connections, for deployments
+// behind L4 load balancers and proxies that drop idle connections or need
+// them recycled. Durations are in seconds; zero keeps the gRPC default.
+//
+// KeepaliveTimeSec pings a client after the connection was idle that long,
+// and KeepaliveTimeoutSec closes it when the ping is not acknowledged in
+// time. MaxConnectionIdleSec closes connections without streams for that
+// long; MaxConnectionAgeSec closes any connection after that long with a
+// GOAWAY, giving open streams MaxConnectionAgeGraceSec to finish.
+// KeepaliveMinTimeSec and KeepalivePermitWithoutStream are the enforcement
+// policy for client pings: a client pinging more often, or without open
+// streams when not permitted, is disconnected. MaxRecvMsgBytes raises the
+// receive limit above the one derived from max_chunk_bytes; it cannot lower
+// it.
+type GRPCServer struct {
+	KeepaliveTimeSec             int  `json:"keepalive_time_s"`
+	KeepaliveTimeoutSec          int  `json:"keepalive_timeout_s"`
+	MaxConnectionIdleSec         int  `json:"max_connection_idle_s"`
+	MaxConnectionAgeSec          int  `json:"max_connection_age_s"`
+	MaxConnectionAgeGraceSec     int  `json:"max_connection_age_grace_s"`
+	KeepaliveMinTimeSec          int  `json:"keepalive_min_time_s"`
+	KeepalivePermitWithoutStream bool `json:"keepalive_permit_without_stream"`
+	MaxRecvMsgBytes              int  `json:"max_recv_msg_bytes"`
+}
+
+func (g GRPCServer) validate() error {
+	for _, d := range []struct {
+		name, env string
+		value     int
+	}{
+		{"keepalive_time_s", "NUPI_VAD_GRPC_KEEPALIVE_TIME_S", g.KeepaliveTimeSec},
+		{"keepalive_timeout_s", "NUPI_VAD_GRPC_KEEPALIVE_TIMEOUT_S", g.KeepaliveTimeoutSec},
+		{"max_connection_idle_s", "NUPI_VAD_GRPC_MAX_CONNECTION_IDLE_S", g.MaxConnectionIdleSec},
+		{"max_connection_age_s", "NUPI_VAD_GRPC_MAX_CONNECTION_AGE_S", g.MaxConnectionAgeSec},
+		{"max_connection_age_grace_s", "NUPI_VAD_GRPC_MAX_CONNECTION_AGE_GRACE_S", g.MaxConnectionAgeGraceSec},
+		{"keepalive_min_time_s", "NUPI_VAD_GRPC_KEEPALIVE_MIN_TIME_S", g.KeepaliveMinTimeSec},
+	} {
+		if d.value < 0 || d.value > MaxGRPCDurationSec {
+			return fmt.Errorf("config: grpc.%s must be in [0, %d], got %d (set %s)", d.name, MaxGRPCDurationSec, d.value, d.env)
+		}
+	}
+	if g.MaxConnectionAgeGraceSec != 0 && g.MaxConnectionAgeSec == 0 {
+		return fmt.Errorf("config: grpc.max_connection_age_grace_s requires grpc.max_connection_age_s (set NUPI_VAD_GRPC_MAX_CONNECTION_AGE_S)")
+	}
+	if g.MaxRecvMsgBytes < 0 || g.MaxRecvMsgBytes > MaxGRPCRecvMsgBytes {
+		return fmt.Errorf("config: grpc.max_recv_msg_bytes must be in [0, %d], got %d (set NUPI_VAD_GRPC_MAX_RECV_MSG_BYTES)", MaxGRPCRecvMsgBytes, g.MaxRecvMsgBytes)
+	}
+	return nil
+}
+
+// CPUBudget caps a stream's inference time as a percentage of the audio it
+// covers. Time is measured around every engine call and checked per slice of
+// SliceSec seconds of audio: a stream that spends more than Percent percent
+// of a slice in inference exceeds the budget. Action "terminate" ends it with
+// RESOURCE_EXHAUSTED; "downgrade" switches it to the cheaper Engine for the
+// rest of the stream and terminates it only if that engine exceeds the
+// budget too. The zero value disables the budget.
+type CPUBudget struct {
+	Percent  int    `json:"percent"`
+	SliceSec int    `json:"slice_s"`
+	Action   string `json:"action"`
+	Engine   string `json:"engine"`
+}
+
+// Enabled reports whether streams have a CPU budget.
+func (b CPUBudget) Enabled() bool {
+	return b.Percent > 0
+}
+
+// EffectiveSliceSec returns SliceSec, or DefaultCPUBudgetSliceSec when
+// unset.
+func (b CPUBudget) EffectiveSliceSec() int {
+	if b.SliceSec == 0 {
+		return DefaultCPUBudgetSliceSec
+	}
+	return b.SliceSec
+}
+
+func (b *CPUBudget) validate() error {
+	b.Action = strings.ToLower(strings.TrimSpace(b.Action))
+	b.Engine = strings.ToLower(strings.TrimSpace(b.Engine))
+	if b.Percent < 0 || b.Percent > 100 {
+		return fmt.Errorf("config: cpu_budget.percent must be in [0, 100], got %d (set NUPI_VAD_CPU_BUDGET_PERCENT)", b.Percent)
+	}
+	if b.SliceSec < 0 || b.SliceSec > MaxCPUBudgetSliceSec {
+		return fmt.Errorf("config: cpu_budget.slice_s must be in [0, %d], got %d (set NUPI_VAD_CPU_BUDGET_SLICE_S)", MaxCPUBudgetSliceSec, b.SliceSec)
+	}
+	if !b.Enabled() {
+		if b.Action != "" || b.Engine != "" || b.SliceSec != 0 {
+			return fmt.Errorf("config: cpu_budget options require cpu_budget.percent (set NUPI_VAD_CPU_BUDGET_PERCENT)")
+		}
+		return nil
+	}
+	if b.Action == "" {
+		b.Action = CPUBudgetTerminate
+	}
+	switch b.Action {
+	case CPUBudgetTerminate:
+		if b.Engine != "" {
+			return fmt.Errorf("config: cpu_budget.engine requires cpu_budget.action %q", CPUBudgetDowngrade)
+		}
+	case CPUBudgetDowngrade:
+		if !knownEngine(b.Engine) {
+			return fmt.Errorf("config: cpu_budget.engine must be %q, %q or %q with action %q, got %q (set NUPI_VAD_CPU_BUDGET_ENGINE)", EngineSilero, EngineStub, EngineEnergy, CPUBudgetDowngrade, b.Engine)
+		}
+	default:
+		return fmt.Errorf("config: cpu_budget.action must be %q or %q, got %q (set NUPI_VAD_CPU_BUDGET_ACTION)", CPUBudgetTerminate, CPUBudgetDowngrade, b.Action)
+	}
+	return nil
+}
+
+// InferenceBudget bounds the engine time a stream may spend per frame of
+// audio. Inference time is averaged over every Frames frames; a stream
+// averaging more than FrameMs milliseconds per frame is over budget, which
+// is logged and counted. Action "skip" also makes the engine score only
+// every other frame until full-rate inference would fit the budget again.
+// The zero value disables the budget.
+type InferenceBudget struct {
+	FrameMs float64 `json:"frame_ms"`
+	Frames  int     `json:"frames"`
+	Action  string  `json:"action"`
+}
+
+// Enabled reports whether streams have an inference budget.
+func (b InferenceBudget) Enabled() bool {
+	return b.FrameMs > 0
+}
+
+// EffectiveFrames returns Frames, or DefaultInferenceBudgetFrames when
+// unset.
+func (b InferenceBudget) EffectiveFrames() int {
+	if b.Frames == 0 {
+		return DefaultInferenceBudgetFrames
+	}
+	return b.Frames
+}
+
+func (b *InferenceBudget) validate() error {
+	b.Action = strings.ToLower(strings.TrimSpace(b.Action))
+	if !(b.FrameMs >= 0 && b.FrameMs <= MaxInferenceBudgetFrameMs) {
+		return fmt.Errorf("config: inference_budget.frame_ms must be in [0, %d], got %g (set NUPI_VAD_INFERENCE_BUDGET_MS)", MaxInferenceBudgetFrameMs, b.FrameMs)
+	}
+	if b.Frames < 0 || b.Frames > MaxInferenceBudgetFrames {
+		return fmt.Errorf("config: inference_budget.frames must be in [0, %d], got %d (set NUPI_VAD_INFERENCE_BUDGET_FRAMES)", MaxInferenceBudgetFrames, b.Frames)
+	}
+	if !b.Enabled() {
+		if b.Action != "" || b.Frames != 0 {
+			return fmt.Errorf("config: inference_budget options require inference_budget.frame_ms (set NUPI_VAD_INFERENCE_BUDGET_MS)")
+		}
+		return nil
+	}
+	if b.Action == "" {
+		b.Action = InferenceBudgetWarn
+	}
+	if b.Action != InferenceBudgetWarn && b.Action != InferenceBudgetSkip {
+		return fmt.Errorf("config: inference_budget.action must be %q or %q, got %q (set NUPI_VAD_INFERENCE_BUDGET_ACTION)", InferenceBudgetWarn, InferenceBudgetSkip, b.Action)
+	}
+	return nil
+}
+
+// SecondModel is the silero model streams run with model "b": the file at
+// Path, checked against SHA256 when set, or without Path the embedded
+// model of Version and Precision. Version and Precision default to
+// model_version and model_precision. The zero value loads no second model.
+type SecondModel struct {
+	Path      string `json:"path"`
+	SHA256    string `json:"sha256"`
+	Version   string `json:"version"`
+	Precision string `json:"precision"`
+}
+
+// Enabled reports whether a second model is loaded.
+func (m SecondModel) Enabled() bool {
+	return m.Path != "" || m.Version != "" || m.Precision != ""
+}
+
+// validate checks m and defaults its version and precision to c's; it
+// runs after validateModel.
+func (m *SecondModel) validate(c *Config) error {
+	m.Path = strings.TrimSpace(m.Path)
+	m.SHA256 = strings.ToLower(strings.TrimSpace(m.SHA256))
+	m.Version = strings.ToLower(strings.TrimSpace(m.Version))
+	m.Precision = strings.ToLower(strings.TrimSpace(m.Precision))
+	if m.SHA256 != "" && m.Path == "" {
+		return fmt.Errorf("config: model_b.sha256 requires model_b.path (set NUPI_VAD_MODEL_B_PATH)")
+	}
+	if !m.Enabled() {
+		return nil
+	}
+	if !isSHA256(m.SHA256) {
+		return fmt.Errorf("config: model_b.sha256 must be 64 hex digits, got %q (set NUPI_VAD_MODEL_B_SHA256)", m.SHA256)
+	}
+	if m.Version == "" {
+		m.Version = c.ModelVersion
+	}
+	if m.Version != ModelV4 && m.Version != ModelV5 {
+		return fmt.Errorf("config: model_b.version must be %q or %q, got %q (set NUPI_VAD_MODEL_B_VERSION)", ModelV4, ModelV5, m.Version)
+	}
+	if m.Precision == "" {
+		m.Precision = c.ModelPrecision
+	}
+	if m.Precision != PrecisionFP32 && m.Precision != PrecisionInt8 {
+		return fmt.Errorf("config: model_b.precision must be %q or %q, got %q (set NUPI_VAD_MODEL_B_PRECISION)", PrecisionFP32, PrecisionInt8, m.Precision)
+	}
+	if m.Path == "" && c.ModelPath == "" && m.Version == c.ModelVersion && m.Precision == c.ModelPrecision {
+		return fmt.Errorf("config: model_b is the same embedded model as model a; set model_b.path, model_b.version or model_b.precision (set NUPI_VAD_MODEL_B_PATH)")
+	}
+	return nil
+}
+
+// Integrity sets the digests the ONNX Runtime library and the embedded
+// model are checked against at startup. Empty digests use those recorded
+// in the build, if any; a library or model that does not match keeps the
+// adapter from starting unless AllowMismatch is set, which only logs it.
+type Integrity struct {
+	ORTLibSHA256        string `json:"ort_lib_sha256"`
+	EmbeddedModelSHA256 string `json:"embedded_model_sha256"`
+	AllowMismatch       bool   `json:"allow_mismatch"`
+}
+
+func (i *Integrity) validate() error {
+	i.ORTLibSHA256 = strings.ToLower(strings.TrimSpace(i.ORTLibSHA256))
+	if !isSHA256(i.ORTLibSHA256) {
+		return fmt.Errorf("config: integrity.ort_lib_sha256 must be 64 hex digits, got %q (set NUPI_VAD_INTEGRITY_ORT_LIB_SHA256)", i.ORTLibSHA256)
+	}
+	i.EmbeddedModelSHA256 = strings.ToLower(strings.TrimSpace(i.EmbeddedModelSHA256))
+	if !isSHA256(i.EmbeddedModelSHA256) {
+		return fmt.Errorf("config: integrity.embedded_model_sha256 must be 64 hex digits, got %q (set NUPI_VAD_INTEGRITY_EMBEDDED_MODEL_SHA256)", i.EmbeddedModelSHA256)
+	}
+	return nil
+}
+
+// isSHA256 reports whether s is empty or a lower-case hex SHA-256 digest.
+func isSHA256(s string) bool {
+	return s == "" || len(s) == 64 && strings.Trim(s, "0123456789abcdef") == ""
+}
+
+// DefaultORTThreads is the default size of each session's intra-op and
+// inter-op thread pools: streams already run in parallel, one session each.
+const DefaultORTThreads = 1
+
+// MaxORTThreads caps the ORT thread counts.
+const MaxORTThreads = 256
+
+// ORT holds ONNX Runtime session options. Zero thread counts mean
+// DefaultORTThreads; an empty GraphOptimization and unset MemArena and
+// MemPattern keep ONNX Runtime's defaults.
+type ORT struct {
+	IntraOpThreads    int    `json:"intra_op_threads"`
+	InterOpThreads    int    `json:"inter_op_threads"`
+	GraphOptimization string `json:"graph_optimization"`
+	MemArena          *bool  `json:"mem_arena"`
+	MemPattern        *bool  `json:"mem_pattern"`
+}
+
+func (o *ORT) validate() error {
+	if o.IntraOpThreads == 0 {
+		o.IntraOpThreads = DefaultORTThreads
+	}
+	if o.InterOpThreads == 0 {
+		o.InterOpThreads = DefaultORTThreads
+	}
+	if o.IntraOpThreads < 0 || o.IntraOpThreads > MaxORTThreads {
+		return fmt.Errorf("config: ort.intra_op_threads must be in [1, %d], got %d (set NUPI_VAD_ORT_INTRA_OP_THREADS)", MaxORTThreads, o.IntraOpThreads)
+	}
+	if o.InterOpThreads < 0 || o.InterOpThreads > MaxORTThreads {
+		return fmt.Errorf("config: ort.inter_op_threads must be in [1, %d], got %d (set NUPI_VAD_ORT_INTER_OP_THREADS)", MaxORTThreads, o.InterOpThreads)
+	}
+	o.GraphOptimization = strings.ToLower(strings.TrimSpace(o.GraphOptimization))
+	switch o.GraphOptimization {
+	case "", engine.GraphOptimizationDisabled, engine.GraphOptimizationBasic, engine.GraphOptimizationExtended, engine.GraphOptimizationAll:
+		return nil
+	default:
+		return fmt.Errorf("config: ort.graph_optimization must be %q, %q, %q or %q, got %q (set NUPI_VAD_ORT_GRAPH_OPTIMIZATION)",
+			engine.GraphOptimizationDisabled, engine.GraphOptimizationBasic, engine.GraphOptimizationExtended, engine.GraphOptimizationAll, o.GraphOptimization)
+	}
+}
+
+// SessionOptions returns the options as the engine takes them.
+func (o ORT) SessionOptions() engine.SessionOptions {
+	return engine.SessionOptions{
+		IntraOpThreads:    o.IntraOpThreads,
+		InterOpThreads:    o.InterOpThreads,
+		GraphOptimization: o.GraphOptimization,
+		MemArena:          o.MemArena,
+		MemPattern:        o.MemPattern,
+	}
+}
+
+// Ensemble combines the stream's engine with a second one, frame by frame:
+// "and" takes the lower of the two speech probabilities (speech only where
+// both engines hear it), "or" the higher, and "weighted" Weight times the
+// primary's plus (1 - Weight) times the secondary's. The zero value disables
+// it.
+type Ensemble struct {
+	Engine string  `json:"engine"`
+	Mode   string  `json:"mode"`
+	Weight float64 `json:"weight"`
+}
+
+// Enabled reports whether a second engine runs on the stream.
+func (e Ensemble) Enabled() bool {
+	return e.Engine != ""
+}
+
+// EffectiveWeight returns Weight, or DefaultEnsembleWeight when unset.
+func (e Ensemble) EffectiveWeight() float64 {
+	if e.Weight == 0 {
+		return DefaultEnsembleWeight
+	}
+	return e.Weight
+}
+
+func (e *Ensemble) validate() error {
+	if *e == (Ensemble{}) {
+		return nil
+	}
+	e.Engine = strings.ToLower(strings.TrimSpace(e.Engine))
+	e.Mode = strings.ToLower(strings.TrimSpace(e.Mode))
+	if !knownEngine(e.Engine) {
+		return fmt.Errorf("config: ensemble.engine must be %q, %q or %q, got %q", EngineSilero, EngineStub, EngineEnergy, e.Engine)
+	}
+	if e.Mode == "" {
+		e.Mode = EnsembleAnd
+	}
+	switch e.Mode {
+	case EnsembleAnd, EnsembleOr, EnsembleWeighted:
+	default:
+		return fmt.Errorf("config: ensemble.mode must be %q, %q or %q, got %q", EnsembleAnd, EnsembleOr, EnsembleWeighted, e.Mode)
+	}
+	if math.IsNaN(e.Weight) || e.Weight < 0 || e.Weight > 1 {
+		return fmt.Errorf("config: ensemble.weight must be in [0, 1], got %f", e.Weight)
+	}
+	return nil
+}
+
+// AGC brings the stream's level to TargetDbfs (RMS), boosting by at most
+// MaxGainDb, before the engine sees it. The zero value disables it.
+type AGC struct {
+	TargetDbfs float64 `json:"target_dbfs"`
+	MaxGainDb  float64 `json:"max_gain_db"`
+}
+
+// Enabled reports whether gain control runs on the stream.
+func (a AGC) Enabled() bool {
+	return a.TargetDbfs != 0
+}
+
+// EffectiveMaxGainDb returns MaxGainDb, or DefaultAGCMaxGainDb when unset.
+func (a AGC) EffectiveMaxGainDb() float64 {
+	if a.MaxGainDb == 0 {
+		return DefaultAGCMaxGainDb
+	}
+	return a.MaxGainDb
+}
+
+func (a AGC) validate() error {
+	if a == (AGC{}) {
+		return nil
+	}
+	if math.IsNaN(a.TargetDbfs) || a.TargetDbfs < MinAGCTargetDbfs || a.TargetDbfs >= 0 {
+		return fmt.Errorf("config: agc.target_dbfs must be in [%d, 0), got %f", MinAGCTargetDbfs, a.TargetDbfs)
+	}
+	if math.IsNaN(a.MaxGainDb) || a.MaxGainDb < 0 || a.MaxGainDb > MaxAGCGainDb {
+		return fmt.Errorf("config: agc.max_gain_db must be in [0, %d], got %f", MaxAGCGainDb, a.MaxGainDb)
+	}
+	return nil
+}
+
+// AdaptiveSilence makes the silence needed to end a segment grow with the
+// segment: MinMs plus Growth times the segment's length so far, capped at
+// MaxMs. Short utterances end quickly, long dictation tolerates longer pauses.
+// The zero value disables it.
+type AdaptiveSilence struct {
+	MinMs  int     `json:"min_ms"`
+	MaxMs  int     `json:"max_ms"`
+	Growth float64 `json:"growth"`
+}
+
+// Enabled reports whether adaptive silence replaces min_silence_duration_ms.
+func (a AdaptiveSilence) Enabled() bool {
+	return a != AdaptiveSilence{}
+}
+
+func (a AdaptiveSilence) validate() error {
+	if !a.Enabled() {
+		return nil
+	}
+	if a.MinMs <= 0 || a.MaxMs < a.MinMs || a.MaxMs > MaxDurationMs {
+		return fmt.Errorf("config: adaptive_silence needs 0 < min_ms <= max_ms <= %d, got min_ms=%d max_ms=%d", MaxDurationMs, a.MinMs, a.MaxMs)
+	}
+	if math.IsNaN(a.Growth) || a.Growth < 0 || a.Growth > MaxAdaptiveSilenceGrowth {
+		return fmt.Errorf("config: adaptive_silence.growth must be in [0, %d], got %f", MaxAdaptiveSilenceGrowth, a.Growth)
+	}
+	return nil
+}
+
+// AdaptiveThreshold derives a stream's threshold from its noise floor: the
+// mean speech probability of the frames outside speech, measured over the
+// stream's first half second and tracked from then on, plus Margin, kept
+// within [Min, Max]. Quiet rooms get a lower threshold, so soft speech is
+// caught; noisy ones a higher one, so noise does not open segments. The
+// zero value disables it.
+type AdaptiveThreshold struct {
+	Min    float64 `json:"min"`
+	Max    float64 `json:"max"`
+	Margin float64 `json:"margin"`
+}
+
+// Enabled reports whether the threshold adapts to the noise floor.
+func (a AdaptiveThreshold) Enabled() bool {
+	return a.Max > 0
+}
+
+// EffectiveMargin returns Margin, or DefaultAdaptiveThresholdMargin when
+// unset.
+func (a AdaptiveThreshold) EffectiveMargin() float64 {
+	if a.Margin == 0 {
+		return DefaultAdaptiveThresholdMargin
+	}
+	return a.Margin
+}
+
+func (a AdaptiveThreshold) validate() error {
+	if a == (AdaptiveThreshold{}) {
+		return nil
+	}
+	if math.IsNaN(a.Min) || math.IsNaN(a.Max) || a.Min <= 0 || a.Min > a.Max || a.Max > 1 {
+		return fmt.Errorf("config: adaptive_threshold needs 0 < min <= max <= 1, got min=%f max=%f", a.Min, a.Max)
+	}
+	if math.IsNaN(a.Margin) || a.Margin < 0 || a.Margin > 1 {
+		return fmt.Errorf("config: adaptive_threshold.margin must be in [0, 1], got %f", a.Margin)
+	}
+	return nil
+}
+
+// FrameSkip cuts inference on streams that stay confidently in speech or in
+// silence: once the probabilities of AfterMs of frames all lie within
+// Margin of 1 or all within Margin of 0, the engine scores only every
+// Stride-th frame, the frames in between repeating the last probability.
+// The first scored frame outside that band returns the stream to full
+// rate. The zero value disables it.
+type FrameSkip struct {
+	Stride  int     `json:"stride"`
+	AfterMs int     `json:"after_ms"`
+	Margin  float64 `json:"margin"`
+}
+
+// Enabled reports whether frames may be skipped.
+func (f FrameSkip) Enabled() bool {
+	return f.Stride > 1
+}
+
+// EffectiveAfterMs returns AfterMs, or DefaultFrameSkipAfterMs when unset.
+func (f FrameSkip) EffectiveAfterMs() int {
+	if f.AfterMs == 0 {
+		return DefaultFrameSkipAfterMs
+	}
+	return f.AfterMs
+}
+
+// EffectiveMargin returns Margin, or DefaultFrameSkipMargin when unset.
+func (f FrameSkip) EffectiveMargin() float64 {
+	if f.Margin == 0 {
+		return DefaultFrameSkipMargin
+	}
+	return f.Margin
+}
+
+func (f FrameSkip) validate() error {
+	if f == (FrameSkip{}) {
+		return nil
+	}
+	if f.Stride < 2 || f.Stride > MaxFrameSkipStride {
+		return fmt.Errorf("config: frame_skip.stride must be in [2, %d], got %d", MaxFrameSkipStride, f.Stride)
+	}
+	if f.AfterMs < 0 || f.AfterMs > MaxDurationMs {
+		return fmt.Errorf("config: frame_skip.after_ms must be in [0, %d], got %d", MaxDurationMs, f.AfterMs)
+	}
+	if math.IsNaN(f.Margin) || f.Margin < 0 || f.Margin >= MaxFrameSkipMargin {
+		return fmt.Errorf("config: frame_skip.margin must be in [0, %g), got %f", MaxFrameSkipMargin, f.Margin)
+	}
+	return nil
+}
+
+// VADParams is a partial set of VAD tuning parameters. Nil fields are left
+// unchanged when applied to a Config.
+type VADParams struct {
+	// Preset expands into its parameters before the other fields apply, so
+	// they override it.
+	Preset               *string  `json:"preset"`
+	Model                *string  `json:"model"`
+	Threshold            *float64 `json:"threshold"`
+	NegThreshold         *float64 `json:"neg_threshold"`
+	MinSpeechDurationMs  *int     `json:"min_speech_duration_ms"`
+	MinSilenceDurationMs *int     `json:"min_silence_duration_ms"`
+	MaxSpeechDurationMs  *int     `json:"max_speech_duration_ms"`
+	PrerollMs            *int     `json:"preroll_ms"`
+	MinGapMs             *int     `json:"min_gap_ms"`
+	SmoothingFrames      *int     `json:"smoothing_frames"`
+	SmoothingMethod      *string  `json:"smoothing_method"`
+
+	AdaptiveSilence   *AdaptiveSilence   `json:"adaptive_silence"`
+	AdaptiveThreshold *AdaptiveThreshold `json:"adaptive_threshold"`
+	FrameSkip         *FrameSkip         `json:"frame_skip"`
+	Ensemble          *Ensemble          `json:"ensemble"`
+	Denoise           *bool              `json:"denoise"`
+	HighPassHz        *int               `json:"high_pass_hz"`
+	AGC               *AGC               `json:"agc"`
+}
+
+// Apply copies every non-nil field of p into cfg.
+func (p VADParams) Apply(cfg *Config) {
+	if p.Preset != nil {
+		if preset, ok := presets[*p.Preset]; ok {
+			preset.Apply(cfg)
+		}
+		cfg.Preset = *p.Preset
+	}
+	if p.Model != nil {
+		cfg.Model = *p.Model
+	}
+	if p.Threshold != nil {
+		cfg.Threshold = *p.Threshold
+	}
+	if p.NegThreshold != nil {
+		cfg.NegThreshold = *p.NegThreshold
+	}
+	if p.MinSpeechDurationMs != nil {
+		cfg.MinSpeechDurationMs = *p.MinSpeechDurationMs
+	}
+	if p.MinSilenceDurationMs != nil {
+		cfg.MinSilenceDurationMs = *p.MinSilenceDurationMs
+	}
+	if p.MaxSpeechDurationMs != nil {
+		cfg.MaxSpeechDurationMs = *p.MaxSpeechDurationMs
+	}
+	if p.PrerollMs != nil {
+		cfg.PrerollMs = *p.PrerollMs
+	}
+	if p.MinGapMs != nil {
+		cfg.MinGapMs = *p.MinGapMs
+	}
+	if p.SmoothingFrames != nil {
+		cfg.SmoothingFrames = *p.SmoothingFrames
+	}
+	if p.SmoothingMethod != nil {
+		cfg.SmoothingMethod = *p.SmoothingMethod
+	}
+	if p.AdaptiveSilence != nil {
+		cfg.AdaptiveSilence = *p.AdaptiveSilence
+	}
+	if p.AdaptiveThreshold != nil {
+		cfg.AdaptiveThreshold = *p.AdaptiveThreshold
+	}
+	if p.FrameSkip != nil {
+		cfg.FrameSkip = *p.FrameSkip
+	}
+	if p.Ensemble != nil {
+		cfg.Ensemble = *p.Ensemble
+	}
+	if p.Denoise != nil {
+		cfg.Denoise = *p.Denoise
+	}
+	if p.HighPassHz != nil {
+		cfg.HighPassHz = *p.HighPassHz
+	}
+	if p.AGC != nil {
+		cfg.AGC = *p.AGC
+	}
+}
+
+// CandidateConfig returns a copy of c with the candidate parameters applied.
+func (c Config) CandidateConfig() Config {
+	c.Candidate.Apply(&c)
+	return c
+}
+
+// TunableParams returns c's VAD parameters that can change while the
+// adapter runs, for new streams only: everything in VADParams except the
+// ensemble, whose engines are created at startup.
+func (c Config) TunableParams() VADParams {
+	return VADParams{
+		Preset:               &c.Preset,
+		Model:                &c.Model,
+		Threshold:            &c.Threshold,
+		NegThreshold:         &c.NegThreshold,
+		MinSpeechDurationMs:  &c.MinSpeechDurationMs,
+		MinSilenceDurationMs: &c.MinSilenceDurationMs,
+		MaxSpeechDurationMs:  &c.MaxSpeechDurationMs,
+		PrerollMs:            &c.PrerollMs,
+		MinGapMs:             &c.MinGapMs,
+		SmoothingFrames:      &c.SmoothingFrames,
+		SmoothingMethod:      &c.SmoothingMethod,
+		AdaptiveSilence:      &c.AdaptiveSilence,
+		AdaptiveThreshold:    &c.AdaptiveThreshold,
+		FrameSkip:            &c.FrameSkip,
+		Denoise:              &c.Denoise,
+		HighPassHz:           &c.HighPassHz,
+		AGC:                  &c.AGC,
+	}
+}
+
+// validateEventQueue checks event_queue_size and event_queue_policy,
+// defaulting the policy to EventQueueDropOngoing when the queue is enabled.
+func (c *Config) validateEventQueue() error {
+	if c.EventQueueSize < 0 || c.EventQueueSize > MaxEventQueueSize {
+		return fmt.Errorf("config: event_queue_size must be in [0, %d], got %d", MaxEventQueueSize, c.EventQueueSize)
+	}
+	c.EventQueuePolicy = strings.ToLower(strings.TrimSpace(c.EventQueuePolicy))
+	if c.EventQueueSize == 0 {
+		if c.EventQueuePolicy != "" {
+			return fmt.Errorf("config: event_queue_policy requires event_queue_size (set NUPI_VAD_EVENT_QUEUE_SIZE)")
+		}
+		return nil
+	}
+	if c.EventQueuePolicy == "" {
+		c.EventQueuePolicy = EventQueueDropOngoing
+	}
+	if c.EventQueuePolicy != EventQueueDropOngoing && c.EventQueuePolicy != EventQueueBlock {
+		return fmt.Errorf("config: event_queue_policy must be %q or %q, got %q (set NUPI_VAD_EVENT_QUEUE_POLICY)", EventQueueDropOngoing, EventQueueBlock, c.EventQueuePolicy)
+	}
+	return nil
+}
+
+// knownEngine reports whether name is a built-in engine or a custom one
+// compiled in and registered with engine.Register.
+func knownEngine(name string) bool {
+	return name == EngineSilero || name == EngineStub || name == EngineEnergy || engine.Registered(name)
 }
 
-// Validate checks that all config values are within acceptable ranges.
-// This is the full startup validation including ListenAddr.
 // EngineAuto is a sentinel value indicating the engine should be auto-detected
 // based on what's compiled into the binary.
 const EngineAuto = "auto"
 
+// Validate checks that all config values are within acceptable ranges.
+// This is the full startup validation including ListenAddr.
 func (c *Config) Validate() error {
 	c.Engine = strings.ToLower(strings.TrimSpace(c.Engine))
 	if c.Engine == "" {
 		c.Engine = EngineAuto
 	}
-	if c.Engine != EngineSilero && c.Engine != EngineStub && c.Engine != EngineAuto {
-		return fmt.Errorf("config: engine must be %q, %q, or %q, got %q (set NUPI_VAD_ENGINE)", EngineSilero, EngineStub, EngineAuto, c.Engine)
+	if c.Engine != EngineAuto && !knownEngine(c.Engine) {
+		return fmt.Errorf("config: engine must be %q, %q, %q, or %q, got %q (set NUPI_VAD_ENGINE)", EngineSilero, EngineStub, EngineEnergy, EngineAuto, c.Engine)
+	}
+	c.StubScript = strings.TrimSpace(c.StubScript)
+	if c.StubScript != "" && c.Engine != EngineStub {
+		return fmt.Errorf("config: stub_script requires engine %q, got %q (set NUPI_VAD_ENGINE)", EngineStub, c.Engine)
+	}
+	if err := c.validateListen(); err != nil {
+		return err
+	}
+	if err := c.validateTLS(); err != nil {
+		return err
+	}
+	if err := c.validateInstanceID(); err != nil {
+		return err
+	}
+	c.MetricsAddr = strings.TrimSpace(c.MetricsAddr)
+	if c.MetricsAddr != "" {
+		if _, _, err := net.SplitHostPort(c.MetricsAddr); err != nil {
+			return fmt.Errorf("config: metrics_addr must be host:port, got %q (set NUPI_VAD_METRICS_ADDR)", c.MetricsAddr)
+		}
+	}
+	if err := c.validateGateway(); err != nil {
+		return err
+	}
+	if c.HopMs == 0 {
+		c.HopMs = DefaultHopMs
+	}
+	if c.HopMs != 8 && c.HopMs != 16 && c.HopMs != DefaultHopMs {
+		return fmt.Errorf("config: hop_ms must be 8, 16 or %d, got %d (set NUPI_VAD_HOP_MS)", DefaultHopMs, c.HopMs)
+	}
+	if err := c.ValidateVADParams(); err != nil {
+		return err
+	}
+	if err := c.validateCandidate(); err != nil {
+		return err
+	}
+	if err := c.validateShadow(); err != nil {
+		return err
+	}
+	if c.RecentStreams < 0 || c.RecentStreams > MaxRecentStreams {
+		return fmt.Errorf("config: recent_streams must be in [0, %d], got %d", MaxRecentStreams, c.RecentStreams)
+	}
+	if c.ExpectedStreams < 0 || c.ExpectedStreams > MaxExpectedStreams {
+		return fmt.Errorf("config: expected_streams must be in [0, %d], got %d", MaxExpectedStreams, c.ExpectedStreams)
+	}
+	if c.MaxStreams < 0 || c.MaxStreams > MaxMaxStreams {
+		return fmt.Errorf("config: max_streams must be in [0, %d], got %d", MaxMaxStreams, c.MaxStreams)
+	}
+	if c.MaxStreams > 0 && c.ExpectedStreams > c.MaxStreams {
+		return fmt.Errorf("config: expected_streams (%d) must not exceed max_streams (%d)", c.ExpectedStreams, c.MaxStreams)
+	}
+	if c.MaxChunkBytes != 0 && (c.MaxChunkBytes < MinMaxChunkBytes || c.MaxChunkBytes > MaxMaxChunkBytes || c.MaxChunkBytes%2 != 0) {
+		return fmt.Errorf("config: max_chunk_bytes must be an even number in [%d, %d], got %d", MinMaxChunkBytes, MaxMaxChunkBytes, c.MaxChunkBytes)
+	}
+	if c.MaxBufferedBytes != 0 && (c.MaxBufferedBytes < MinMaxBufferedBytes || c.MaxBufferedBytes > MaxMaxBufferedBytes) {
+		return fmt.Errorf("config: max_buffered_bytes must be in [%d, %d], got %d", MinMaxBufferedBytes, MaxMaxBufferedBytes, c.MaxBufferedBytes)
+	}
+	if err := c.validateEventQueue(); err != nil {
+		return err
+	}
+	if c.PipelineDepth < 0 || c.PipelineDepth > MaxPipelineDepth {
+		return fmt.Errorf("config: pipeline_depth must be in [0, %d], got %d", MaxPipelineDepth, c.PipelineDepth)
+	}
+	if c.DriftReportIntervalSec < 0 || c.DriftReportIntervalSec > MaxDriftReportIntervalSec {
+		return fmt.Errorf("config: drift_report_interval_s must be in [0, %d], got %d", MaxDriftReportIntervalSec, c.DriftReportIntervalSec)
+	}
+	if c.StuckStreamTimeoutSec < 0 || c.StuckStreamTimeoutSec > MaxStuckStreamTimeoutSec {
+		return fmt.Errorf("config: stuck_stream_timeout_s must be in [0, %d], got %d", MaxStuckStreamTimeoutSec, c.StuckStreamTimeoutSec)
+	}
+	if c.SelfTestIntervalSec < 0 || c.SelfTestIntervalSec > MaxSelfTestIntervalSec {
+		return fmt.Errorf("config: self_test_interval_s must be in [0, %d], got %d", MaxSelfTestIntervalSec, c.SelfTestIntervalSec)
+	}
+	if c.MaxStreamDurationSec < 0 || c.MaxStreamDurationSec > MaxStreamDurationLimitSec {
+		return fmt.Errorf("config: max_stream_duration_s must be in [0, %d], got %d", MaxStreamDurationLimitSec, c.MaxStreamDurationSec)
+	}
+	if c.MaxStreamAudioMB < 0 || c.MaxStreamAudioMB > MaxStreamAudioLimitMB {
+		return fmt.Errorf("config: max_stream_audio_mb must be in [0, %d], got %d", MaxStreamAudioLimitMB, c.MaxStreamAudioMB)
+	}
+	if err := c.validateBillingOutput(); err != nil {
+		return err
+	}
+	if err := c.validateSegmentSink(); err != nil {
+		return err
+	}
+	if err := c.Feedback.validate(); err != nil {
+		return err
+	}
+	if err := c.CPUBudget.validate(); err != nil {
+		return err
+	}
+	if err := c.GRPC.validate(); err != nil {
+		return err
+	}
+	if err := c.InferenceBudget.validate(); err != nil {
+		return err
+	}
+	if err := c.validateModel(); err != nil {
+		return err
+	}
+	if err := c.ORT.validate(); err != nil {
+		return err
+	}
+	if err := c.Integrity.validate(); err != nil {
+		return err
+	}
+	if err := c.ModelB.validate(c); err != nil {
+		return err
+	}
+	c.LogFormat = strings.ToLower(strings.TrimSpace(c.LogFormat))
+	if c.LogFormat != "" && c.LogFormat != LogFormatText && c.LogFormat != LogFormatJSON {
+		return fmt.Errorf("config: log_format must be %q or %q, got %q (set NUPI_LOG_FORMAT)", LogFormatText, LogFormatJSON, c.LogFormat)
+	}
+	c.TimestampFormat = strings.ToLower(strings.TrimSpace(c.TimestampFormat))
+	if c.TimestampFormat != "" {
+		if _, err := timestamp.Parse(c.TimestampFormat); err != nil {
+			return fmt.Errorf("config: timestamp_format must be %q, %q or %q, got %q (set NUPI_VAD_TIMESTAMP_FORMAT)",
+				timestamp.RFC3339, timestamp.EpochMillis, timestamp.OffsetMillis, c.TimestampFormat)
+		}
+	}
+	c.TraceDir = strings.TrimSpace(c.TraceDir)
+	c.TraceStreamID = strings.TrimSpace(c.TraceStreamID)
+	if (c.TraceDir == "") != (c.TraceStreamID == "") {
+		return fmt.Errorf("config: trace_dir and trace_stream_id must be set together (set NUPI_VAD_TRACE_DIR and NUPI_VAD_TRACE_STREAM_ID)")
+	}
+	if err := c.validateDebugDump(); err != nil {
+		return err
+	}
+	if c.Stateless {
+		if features := c.PersistentFeatures(); len(features) > 0 {
+			return fmt.Errorf("config: stateless mode forbids node-local state, but %s enabled (set NUPI_VAD_STATELESS=0 or disable them)", strings.Join(features, ", "))
+		}
+		// Tuned thresholds live in the memory of the node that received
+		// the reports, even without a state file.
+		if c.Feedback.Enabled() {
+			return fmt.Errorf("config: stateless mode forbids node-local state, but feedback.step tunes thresholds per node (set NUPI_VAD_STATELESS=0 or NUPI_VAD_FEEDBACK_STEP=0)")
+		}
+	}
+	return nil
+}
+
+func (c *Config) validateModel() error {
+	c.ModelVersion = strings.ToLower(strings.TrimSpace(c.ModelVersion))
+	if c.ModelVersion == "" {
+		c.ModelVersion = ModelV5
+	}
+	if c.ModelVersion != ModelV4 && c.ModelVersion != ModelV5 {
+		return fmt.Errorf("config: model_version must be %q or %q, got %q (set NUPI_VAD_MODEL_VERSION)", ModelV4, ModelV5, c.ModelVersion)
+	}
+	c.ModelPrecision = strings.ToLower(strings.TrimSpace(c.ModelPrecision))
+	if c.ModelPrecision == "" {
+		c.ModelPrecision = PrecisionFP32
+	}
+	if c.ModelPrecision != PrecisionFP32 && c.ModelPrecision != PrecisionInt8 {
+		return fmt.Errorf("config: model_precision must be %q or %q, got %q (set NUPI_VAD_MODEL_PRECISION)", PrecisionFP32, PrecisionInt8, c.ModelPrecision)
 	}
+	c.ModelPath = strings.TrimSpace(c.ModelPath)
+	c.ModelSHA256 = strings.ToLower(strings.TrimSpace(c.ModelSHA256))
+	if c.ModelSHA256 == "" {
+		return nil
+	}
+	if c.ModelPath == "" {
+		return fmt.Errorf("config: model_sha256 requires model_path (set NUPI_VAD_MODEL_PATH)")
+	}
+	if !isSHA256(c.ModelSHA256) {
+		return fmt.Errorf("config: model_sha256 must be 64 hex digits, got %q (set NUPI_VAD_MODEL_SHA256)", c.ModelSHA256)
+	}
+	return nil
+}
+
+func (c *Config) validateDebugDump() error {
+	c.DebugDumpDir = strings.TrimSpace(c.DebugDumpDir)
+	if c.DebugDump && c.DebugDumpDir == "" {
+		return fmt.Errorf("config: debug_dump requires debug_dump_dir (set NUPI_VAD_DEBUG_DUMP_DIR)")
+	}
+	if c.DebugDumpMaxStreamMB < 0 || c.DebugDumpMaxStreamMB > MaxDebugDumpStreamMB {
+		return fmt.Errorf("config: debug_dump_max_stream_mb must be in [0, %d], got %d", MaxDebugDumpStreamMB, c.DebugDumpMaxStreamMB)
+	}
+	if c.DebugDumpMaxTotalMB < 0 || c.DebugDumpMaxTotalMB > MaxDebugDumpTotalMB {
+		return fmt.Errorf("config: debug_dump_max_total_mb must be in [0, %d], got %d", MaxDebugDumpTotalMB, c.DebugDumpMaxTotalMB)
+	}
+	return nil
+}
+
+// EffectiveDebugDumpMaxStreamBytes returns the per-stream audio cap of
+// debug dumps in bytes.
+func (c Config) EffectiveDebugDumpMaxStreamBytes() int64 {
+	mb := c.DebugDumpMaxStreamMB
+	if mb == 0 {
+		mb = DefaultDebugDumpMaxStreamMB
+	}
+	return int64(mb) << 20
+}
+
+// EffectiveDebugDumpMaxTotalBytes returns the cap on the debug dump
+// directory in bytes.
+func (c Config) EffectiveDebugDumpMaxTotalBytes() int64 {
+	mb := c.DebugDumpMaxTotalMB
+	if mb == 0 {
+		mb = DefaultDebugDumpMaxTotalMB
+	}
+	return int64(mb) << 20
+}
+
+// PersistentFeatures lists the enabled options that write node-local state.
+// Every option that persists data outside the process must be reported here,
+// since stateless mode relies on it to refuse such configurations.
+func (c *Config) PersistentFeatures() []string {
+	var features []string
+	if c.TraceDir != "" {
+		features = append(features, "trace_dir")
+	}
+	if c.DebugDumpDir != "" {
+		features = append(features, "debug_dump_dir")
+	}
+	if _, isFile := c.BillingFile(); isFile {
+		features = append(features, "billing_output")
+	}
+	if kind, _ := c.SegmentSinkTarget(); kind == SegmentSinkDir {
+		features = append(features, "segment_sink")
+	}
+	if c.Feedback.StateFile != "" {
+		features = append(features, "feedback.state_file")
+	}
+	return features
+}
+
+// Standard billing_output targets; see Config.BillingOutput.
+const (
+	BillingStdout   = "stdout"
+	BillingStderr   = "stderr"
+	billingFDPrefix = "fd:"
+)
+
+// BillingFD returns the descriptor of an "fd:<n>" billing_output.
+func (c *Config) BillingFD() (int, bool) {
+	v, ok := strings.CutPrefix(c.BillingOutput, billingFDPrefix)
+	if !ok {
+		return 0, false
+	}
+	fd, err := strconv.Atoi(v)
+	if err != nil || fd < 0 {
+		return 0, false
+	}
+	return fd, true
+}
+
+// BillingFile returns the path of a file billing_output.
+func (c *Config) BillingFile() (string, bool) {
+	switch {
+	case c.BillingOutput == "", c.BillingOutput == BillingStdout, c.BillingOutput == BillingStderr,
+		strings.HasPrefix(c.BillingOutput, billingFDPrefix):
+		return "", false
+	}
+	return c.BillingOutput, true
+}
+
+func (c *Config) validateBillingOutput() error {
+	c.BillingOutput = strings.TrimSpace(c.BillingOutput)
+	if strings.HasPrefix(c.BillingOutput, billingFDPrefix) {
+		if _, ok := c.BillingFD(); !ok {
+			return fmt.Errorf("config: billing_output %q must be fd:<n> with a non-negative descriptor number", c.BillingOutput)
+		}
+	}
+	return nil
+}
+
+// Valid segment_sink kinds; see Config.SegmentSink.
+const (
+	SegmentSinkDir  = "dir"
+	SegmentSinkUnix = "unix"
+)
+
+// SegmentSinkTarget returns the kind and path of SegmentSink, or empty
+// strings when it is unset.
+func (c *Config) SegmentSinkTarget() (kind, path string) {
+	kind, path, _ = strings.Cut(c.SegmentSink, ":")
+	return kind, path
+}
+
+func (c *Config) validateSegmentSink() error {
+	c.SegmentSink = strings.TrimSpace(c.SegmentSink)
+	if c.SegmentSink == "" {
+		return nil
+	}
+	switch kind, path := c.SegmentSinkTarget(); {
+	case kind != SegmentSinkDir && kind != SegmentSinkUnix, strings.TrimSpace(path) == "":
+		return fmt.Errorf("config: segment_sink must be \"dir:<path>\" or \"unix:<path>\", got %q (set NUPI_VAD_SEGMENT_SINK)", c.SegmentSink)
+	}
+	return nil
+}
+
+// ListenAddrs returns the bind addresses of ListenAddr.
+func (c *Config) ListenAddrs() []string {
+	var addrs []string
+	for _, addr := range strings.Split(c.ListenAddr, ",") {
+		if addr = strings.TrimSpace(addr); addr != "" {
+			addrs = append(addrs, addr)
+		}
+	}
+	return addrs
+}
+
+// EffectiveListenNetwork returns ListenNetwork, or ListenTCP when unset.
+func (c *Config) EffectiveListenNetwork() string {
+	if c.ListenNetwork == "" {
+		return ListenTCP
+	}
+	return c.ListenNetwork
+}
+
+// TLSEnabled reports whether gRPC is served over TLS.
+func (c *Config) TLSEnabled() bool {
+	return c.TLSCertFile != ""
+}
+
+// validateTLS checks that the TLS files are set consistently; they are read
+// when the listener starts.
+func (c *Config) validateTLS() error {
+	c.TLSCertFile = strings.TrimSpace(c.TLSCertFile)
+	c.TLSKeyFile = strings.TrimSpace(c.TLSKeyFile)
+	c.TLSClientCAFile = strings.TrimSpace(c.TLSClientCAFile)
+	if (c.TLSCertFile == "") != (c.TLSKeyFile == "") {
+		return fmt.Errorf("config: tls_cert_file and tls_key_file must be set together (set NUPI_VAD_TLS_CERT_FILE and NUPI_VAD_TLS_KEY_FILE)")
+	}
+	if c.TLSClientCAFile != "" && c.TLSCertFile == "" {
+		return fmt.Errorf("config: tls_client_ca_file requires tls_cert_file and tls_key_file (set NUPI_VAD_TLS_CERT_FILE and NUPI_VAD_TLS_KEY_FILE)")
+	}
+	return nil
+}
+
+// validateListen checks the bind addresses against the listen network. Hosts
+// may be names, which are resolved when binding; IP literals must belong to
+// the selected family.
+func (c *Config) validateListen() error {
 	c.ListenAddr = strings.TrimSpace(c.ListenAddr)
 	if c.ListenAddr == "" {
 		return fmt.Errorf("config: listen address is required")
 	}
-	return c.ValidateVADParams()
+	c.ListenNetwork = strings.ToLower(strings.TrimSpace(c.ListenNetwork))
+	network := c.EffectiveListenNetwork()
+	if network != ListenTCP && network != ListenTCP4 && network != ListenTCP6 {
+		return fmt.Errorf("config: listen_network must be %q, %q or %q, got %q (set NUPI_VAD_LISTEN_NETWORK)", ListenTCP, ListenTCP4, ListenTCP6, c.ListenNetwork)
+	}
+	addrs := c.ListenAddrs()
+	if len(addrs) == 0 {
+		return fmt.Errorf("config: listen address is required")
+	}
+	seen := make(map[string]bool, len(addrs))
+	for _, addr := range addrs {
+		host, port, err := net.SplitHostPort(addr)
+		if err != nil {
+			if !strings.HasPrefix(addr, "[") && strings.Count(addr, ":") > 1 {
+				return fmt.Errorf("config: listen address %q: IPv6 addresses must be bracketed, e.g. [::1]:50051", addr)
+			}
+			return fmt.Errorf("config: listen address %q must be host:port: %v", addr, err)
+		}
+		if p, err := strconv.Atoi(port); err != nil || p < 0 || p > 65535 {
+			return fmt.Errorf("config: listen address %q: port must be a number in [0, 65535]", addr)
+		}
+		if ip := net.ParseIP(host); ip != nil {
+			if network == ListenTCP4 && ip.To4() == nil {
+				return fmt.Errorf("config: listen address %q is IPv6 but listen_network is %q", addr, network)
+			}
+			if network == ListenTCP6 && ip.To4() != nil && !strings.Contains(host, ":") {
+				return fmt.Errorf("config: listen address %q is IPv4 but listen_network is %q", addr, network)
+			}
+		}
+		if seen[addr] {
+			return fmt.Errorf("config: listen address %q is listed twice", addr)
+		}
+		seen[addr] = true
+	}
+	return nil
+}
+
+// validateInstanceID checks the deployment identifier. It is attached to logs
+// and sent as a gRPC response header, so it is limited to printable ASCII
+// without spaces.
+func (c *Config) validateInstanceID() error {
+	c.InstanceID = strings.TrimSpace(c.InstanceID)
+	if len(c.InstanceID) > MaxInstanceIDLen {
+		return fmt.Errorf("config: instance_id must be at most %d characters, got %d", MaxInstanceIDLen, len(c.InstanceID))
+	}
+	for _, r := range c.InstanceID {
+		if r <= ' ' || r > '~' {
+			return fmt.Errorf("config: instance_id must be printable ASCII without spaces, got %q", c.InstanceID)
+		}
+	}
+	return nil
+}
+
+// validateShadow checks the shadow comparison settings. The shadow engine may
+// not be "auto": the comparison is only meaningful against a known backend.
+func (c *Config) validateShadow() error {
+	c.ShadowEngine = strings.ToLower(strings.TrimSpace(c.ShadowEngine))
+	if c.ShadowEngine != "" && !knownEngine(c.ShadowEngine) {
+		return fmt.Errorf("config: shadow_engine must be %q, %q or %q, got %q (set NUPI_VAD_SHADOW_ENGINE)", EngineSilero, EngineStub, EngineEnergy, c.ShadowEngine)
+	}
+	if c.ShadowPercent < 0 || c.ShadowPercent > 100 {
+		return fmt.Errorf("config: shadow_percent must be in [0, 100], got %d", c.ShadowPercent)
+	}
+	shadow := c.ShadowConfig(*c)
+	if err := shadow.ValidateVADParams(); err != nil {
+		return fmt.Errorf("shadow: %w", err)
+	}
+	if c.Shadow.Model != nil {
+		c.Shadow.Model = &shadow.Model
+	}
+	return nil
+}
+
+// ShadowConfig returns a copy of streamCfg with the shadow parameters applied.
+// The shadow inherits each stream's effective config (variant and
+// config_json included), so it differs from the primary only where Shadow
+// sets a field.
+func (c Config) ShadowConfig(streamCfg Config) Config {
+	c.Shadow.Apply(&streamCfg)
+	return streamCfg
+}
+
+// validateCandidate checks the blue/green rollout settings. The candidate
+// parameters are validated as a complete config so that a candidate which only
+// overrides one field is still checked against the inherited baseline values.
+func (c *Config) validateCandidate() error {
+	if c.CandidatePercent < 0 || c.CandidatePercent > 100 {
+		return fmt.Errorf("config: candidate_percent must be in [0, 100], got %d", c.CandidatePercent)
+	}
+	candidate := c.CandidateConfig()
+	if err := candidate.ValidateVADParams(); err != nil {
+		return fmt.Errorf("candidate: %w", err)
+	}
+	return nil
+}
+
+// EffectiveNegThreshold returns the speech offset threshold: NegThreshold if
+// set, otherwise Threshold - NegThresholdOffset, floored at 0.01 but never
+// above Threshold.
+func (c Config) EffectiveNegThreshold() float64 {
+	if c.NegThreshold > 0 {
+		return c.NegThreshold
+	}
+	return min(c.Threshold, max(c.Threshold-NegThresholdOffset, 0.01))
+}
+
+// GatewayOriginList returns GatewayOrigins split into origins.
+func (c Config) GatewayOriginList() []string {
+	var origins []string
+	for _, o := range strings.Split(c.GatewayOrigins, ",") {
+		if o = strings.TrimSpace(o); o != "" {
+			origins = append(origins, o)
+		}
+	}
+	return origins
+}
+
+func (c *Config) validateGateway() error {
+	c.GatewayAddr = strings.TrimSpace(c.GatewayAddr)
+	if c.GatewayAddr == "" {
+		if c.GatewayOrigins != "" {
+			return fmt.Errorf("config: gateway_origins requires gateway_addr (set NUPI_VAD_GATEWAY_ADDR)")
+		}
+		return nil
+	}
+	if _, _, err := net.SplitHostPort(c.GatewayAddr); err != nil {
+		return fmt.Errorf("config: gateway_addr must be host:port, got %q (set NUPI_VAD_GATEWAY_ADDR)", c.GatewayAddr)
+	}
+	for _, o := range c.GatewayOriginList() {
+		if o == "*" {
+			continue
+		}
+		u, err := url.Parse(o)
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" || strings.TrimSuffix(u.Path, "/") != "" {
+			return fmt.Errorf("config: gateway_origins entries must be scheme://host[:port] or *, got %q (set NUPI_VAD_GATEWAY_ORIGINS)", o)
+		}
+	}
+	return nil
+}
+
+// EffectiveMaxChunkBytes returns MaxChunkBytes, or DefaultMaxChunkBytes
+// when unset.
+func (c Config) EffectiveMaxChunkBytes() int {
+	if c.MaxChunkBytes == 0 {
+		return DefaultMaxChunkBytes
+	}
+	return c.MaxChunkBytes
+}
+
+// EffectiveMaxBufferedBytes returns MaxBufferedBytes, or
+// DefaultMaxBufferedBytes when unset.
+func (c Config) EffectiveMaxBufferedBytes() int {
+	if c.MaxBufferedBytes == 0 {
+		return DefaultMaxBufferedBytes
+	}
+	return c.MaxBufferedBytes
+}
+
+// EffectivePipelineDepth returns PipelineDepth, or DefaultPipelineDepth
+// when unset.
+func (c Config) EffectivePipelineDepth() int {
+	if c.PipelineDepth == 0 {
+		return DefaultPipelineDepth
+	}
+	return c.PipelineDepth
 }
 
 // ValidateVADParams checks that VAD-specific parameter values are within
 // acceptable ranges. Used for both startup config and per-stream overrides.
 func (c *Config) ValidateVADParams() error {
+	if err := validatePreset(c.Preset); err != nil {
+		return err
+	}
+	c.Model = strings.ToLower(strings.TrimSpace(c.Model))
+	switch c.Model {
+	case "", ModelA:
+	case ModelB:
+		if !c.ModelB.Enabled() {
+			return fmt.Errorf("config: model %q requires model_b (set NUPI_VAD_MODEL_B_PATH)", ModelB)
+		}
+	default:
+		return fmt.Errorf("config: model must be %q or %q, got %q", ModelA, ModelB, c.Model)
+	}
 	if math.IsNaN(c.Threshold) || math.IsInf(c.Threshold, 0) {
 		return fmt.Errorf("config: threshold must be a finite number, got %f", c.Threshold)
 	}
 	if c.Threshold < 0 || c.Threshold > 1.0 {
 		return fmt.Errorf("config: threshold must be in [0.0, 1.0], got %f", c.Threshold)
 	}
+	if math.IsNaN(c.NegThreshold) || c.NegThreshold < 0 || c.NegThreshold > c.Threshold {
+		return fmt.Errorf("config: neg_threshold must be 0 (auto) or in (0.0, threshold=%f], got %f", c.Threshold, c.NegThreshold)
+	}
 	if c.MinSpeechDurationMs <= 0 || c.MinSpeechDurationMs > MaxDurationMs {
 		return fmt.Errorf("config: min_speech_duration_ms must be in (0, %d], got %d", MaxDurationMs, c.MinSpeechDurationMs)
 	}
 	if c.MinSilenceDurationMs <= 0 || c.MinSilenceDurationMs > MaxDurationMs {
 		return fmt.Errorf("config: min_silence_duration_ms must be in (0, %d], got %d", MaxDurationMs, c.MinSilenceDurationMs)
 	}
+	if c.MaxSpeechDurationMs < 0 || c.MaxSpeechDurationMs > MaxSpeechDurationLimitMs {
+		return fmt.Errorf("config: max_speech_duration_ms must be in [0, %d], got %d", MaxSpeechDurationLimitMs, c.MaxSpeechDurationMs)
+	}
+	if c.PrerollMs < 0 || c.PrerollMs > MaxDurationMs {
+		return fmt.Errorf("config: preroll_ms must be in [0, %d], got %d", MaxDurationMs, c.PrerollMs)
+	}
+	if c.MinGapMs < 0 || c.MinGapMs > MaxDurationMs {
+		return fmt.Errorf("config: min_gap_ms must be in [0, %d], got %d", MaxDurationMs, c.MinGapMs)
+	}
+	if err := c.AdaptiveSilence.validate(); err != nil {
+		return err
+	}
+	if err := c.AdaptiveThreshold.validate(); err != nil {
+		return err
+	}
+	if err := c.FrameSkip.validate(); err != nil {
+		return err
+	}
+	if err := c.Ensemble.validate(); err != nil {
+		return err
+	}
+	if c.SmoothingFrames < 0 || c.SmoothingFrames > MaxSmoothingFrames {
+		return fmt.Errorf("config: smoothing_frames must be in [0, %d], got %d", MaxSmoothingFrames, c.SmoothingFrames)
+	}
+	switch c.SmoothingMethod {
+	case "", SmoothingMean, SmoothingMedian:
+	default:
+		return fmt.Errorf("config: smoothing_method must be %q or %q, got %q", SmoothingMean, SmoothingMedian, c.SmoothingMethod)
+	}
+	if err := c.AGC.validate(); err != nil {
+		return err
+	}
+	if c.HighPassHz < 0 || c.HighPassHz > MaxHighPassHz {
+		return fmt.Errorf("config: high_pass_hz must be in [0, %d], got %d", MaxHighPassHz, c.HighPassHz)
+	}
+	if c.MaxSpeechDurationMs > 0 && c.MaxSpeechDurationMs < c.MinSpeechDurationMs {
+		return fmt.Errorf("config: max_speech_duration_ms (%d) must be 0 or at least min_speech_duration_ms (%d)", c.MaxSpeechDurationMs, c.MinSpeechDurationMs)
+	}
+	if c.HeartbeatIntervalMs != 0 && (c.HeartbeatIntervalMs < MinHeartbeatIntervalMs || c.HeartbeatIntervalMs > MaxHeartbeatIntervalMs) {
+		return fmt.Errorf("config: heartbeat_interval_ms must be 0 or in [%d, %d], got %d", MinHeartbeatIntervalMs, MaxHeartbeatIntervalMs, c.HeartbeatIntervalMs)
+	}
 	return nil
 }
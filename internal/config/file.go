@@ -0,0 +1,48 @@
+package config
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/BurntSushi/toml"
+	"gopkg.in/yaml.v3"
+)
+
+// readConfigFile reads the NUPI_ADAPTER_CONFIG_FILE at path and returns it
+// as the equivalent NUPI_ADAPTER_CONFIG JSON, so both go through applyJSON.
+// The format follows the extension: .json, .yaml/.yml or .toml. YAML and
+// TOML documents are decoded into the generic map the JSON would give, so
+// every construct of both languages is accepted and unknown options are
+// reported the same way.
+func readConfigFile(path string) (string, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return "", fmt.Errorf("config: read NUPI_ADAPTER_CONFIG_FILE: %w", err)
+	}
+	var fields map[string]any
+	switch ext := strings.ToLower(filepath.Ext(path)); ext {
+	case ".json":
+		return string(data), nil
+	case ".yaml", ".yml":
+		err = yaml.Unmarshal(data, &fields)
+	case ".toml":
+		_, err = toml.Decode(string(data), &fields)
+	default:
+		return "", fmt.Errorf("config: NUPI_ADAPTER_CONFIG_FILE %s: unknown format %q, use .json, .yaml, .yml or .toml", path, ext)
+	}
+	if err != nil {
+		return "", fmt.Errorf("config: parse NUPI_ADAPTER_CONFIG_FILE %s: %w", path, err)
+	}
+	if fields == nil {
+		// An empty or comment-only document sets nothing.
+		fields = map[string]any{}
+	}
+	raw, err := json.Marshal(fields)
+	if err != nil {
+		return "", fmt.Errorf("config: NUPI_ADAPTER_CONFIG_FILE %s: %w", path, err)
+	}
+	return string(raw), nil
+}
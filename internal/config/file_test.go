@@ -0,0 +1,213 @@
+package config_test
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/nupi-ai/plugin-vad-local-silero/internal/config"
+)
+
+// loadFile loads the config with content written to a file named name and
+// the extra environment env.
+func loadFile(t *testing.T, name, content string, env map[string]string) (config.LoadResult, error) {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), name)
+	if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	vars := map[string]string{"NUPI_VAD_ENGINE": "stub", "NUPI_ADAPTER_CONFIG_FILE": path}
+	for k, v := range env {
+		vars[k] = v
+	}
+	return config.Loader{Lookup: func(key string) (string, bool) {
+		v, ok := vars[key]
+		return v, ok
+	}}.Load()
+}
+
+const yamlConfig = `# VAD adapter
+---
+threshold: 0.65
+min_silence_duration_ms: 400   # ms
+smoothing_method: "median"
+instance_id: 'vad-#1'
+agc:
+  target_dbfs: -20
+  max_gain_db: 12
+adaptive_silence:
+  min_ms: 200
+  max_ms: 800
+`
+
+const tomlConfig = `# VAD adapter
+threshold = 0.65
+min_silence_duration_ms = 400 # ms
+smoothing_method = "median"
+instance_id = 'vad-#1'
+adaptive_silence = { min_ms = 200, max_ms = 800 }
+
+[agc]
+target_dbfs = -20
+max_gain_db = 12
+`
+
+func TestLoaderConfigFile(t *testing.T) {
+	for name, content := range map[string]string{
+		"vad.yaml": yamlConfig,
+		"vad.yml":  yamlConfig,
+		"vad.toml": tomlConfig,
+		"vad.json": `{"threshold": 0.65, "min_silence_duration_ms": 400, "smoothing_method": "median", "instance_id": "vad-#1",
+			"agc": {"target_dbfs": -20, "max_gain_db": 12}, "adaptive_silence": {"min_ms": 200, "max_ms": 800}}`,
+	} {
+		t.Run(name, func(t *testing.T) {
+			result, err := loadFile(t, name, content, nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			cfg := result.Config
+			if cfg.Threshold != 0.65 || cfg.MinSilenceDurationMs != 400 || cfg.SmoothingMethod != "median" || cfg.InstanceID != "vad-#1" {
+				t.Errorf("scalars: threshold %v, min silence %d, smoothing %q, instance %q",
+					cfg.Threshold, cfg.MinSilenceDurationMs, cfg.SmoothingMethod, cfg.InstanceID)
+			}
+			if cfg.AGC.TargetDbfs != -20 || cfg.AGC.MaxGainDb != 12 || cfg.AdaptiveSilence.MaxMs != 800 {
+				t.Errorf("tables: agc %+v, adaptive_silence %+v", cfg.AGC, cfg.AdaptiveSilence)
+			}
+			if cfg.MinSpeechDurationMs != config.DefaultMinSpeechDurationMs {
+				t.Errorf("unset option: min_speech_duration_ms = %d, want the default", cfg.MinSpeechDurationMs)
+			}
+		})
+	}
+}
+
+func TestLoaderConfigFilePrecedence(t *testing.T) {
+	// The file is overridden by NUPI_ADAPTER_CONFIG, which is overridden by
+	// the individual variables.
+	result, err := loadFile(t, "vad.yaml", "threshold: 0.6\nmin_gap_ms: 100\npreroll_ms: 50\n", map[string]string{
+		"NUPI_ADAPTER_CONFIG": `{"min_gap_ms": 200, "preroll_ms": 80}`,
+		"NUPI_VAD_PREROLL_MS": "120",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	cfg := result.Config
+	if cfg.Threshold != 0.6 || cfg.MinGapMs != 200 || cfg.PrerollMs != 120 {
+		t.Errorf("threshold %v (file), min_gap_ms %d (JSON), preroll_ms %d (env), want 0.6, 200, 120",
+			cfg.Threshold, cfg.MinGapMs, cfg.PrerollMs)
+	}
+	if !strings.HasSuffix(cfg.ConfigFile, "vad.yaml") {
+		t.Errorf("ConfigFile = %q", cfg.ConfigFile)
+	}
+}
+
+func TestLoaderConfigFileSyntax(t *testing.T) {
+	// Constructs beyond plain block mappings and key/value pairs decode as
+	// they would anywhere else: anchors, flow mappings, folded and
+	// multi-line strings, each language's escapes and quoted dotted keys.
+	for name, content := range map[string]string{
+		"vad.yaml": `min_silence_duration_ms: &ms 400
+adaptive_silence: {min_ms: 200, max_ms: *ms}
+agc: {target_dbfs: -20}
+auth_token: "vad/\x41\u00e9\e"
+"agc.max_gain_db": 3
+smoothing_method: >-
+  median
+`,
+		"vad.toml": `min_silence_duration_ms = 400
+auth_token = "vad/A\u00e9\e"
+"agc.max_gain_db" = 3
+smoothing_method = """
+median"""
+"agc".'target_dbfs' = -20
+
+[adaptive_silence]
+min_ms = 200
+max_ms = 400
+`,
+	} {
+		t.Run(name, func(t *testing.T) {
+			result, err := loadFile(t, name, content, nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			cfg := result.Config
+			if cfg.AuthToken != "vad/A\u00e9\x1b" || cfg.SmoothingMethod != "median" {
+				t.Errorf("strings: auth_token %q, smoothing %q", cfg.AuthToken, cfg.SmoothingMethod)
+			}
+			// A quoted key is one key, even with a dot in it.
+			if cfg.AGC.MaxGainDb != 0 {
+				t.Errorf(`"agc.max_gain_db" set agc.max_gain_db to %v`, cfg.AGC.MaxGainDb)
+			}
+			if cfg.AGC.TargetDbfs != -20 || cfg.AdaptiveSilence.MinMs != 200 || cfg.AdaptiveSilence.MaxMs != 400 || cfg.MinSilenceDurationMs != 400 {
+				t.Errorf("tables: agc %+v, adaptive_silence %+v, min silence %d", cfg.AGC, cfg.AdaptiveSilence, cfg.MinSilenceDurationMs)
+			}
+		})
+	}
+}
+
+func TestLoaderConfigFileErrors(t *testing.T) {
+	for _, tc := range []struct {
+		name, content, want string
+	}{
+		{"vad.ini", "threshold=0.5", "unknown format"},
+		{"vad.yaml", "threshold: 0.5\n  min_gap_ms: 10\n", "yaml: line 2"},
+		{"vad.yaml", "threshold: 0.5\nthreshold: 0.6\n", `line 2: mapping key "threshold" already defined`},
+		{"vad.yaml", "agc:\n  - 1\n", "cannot unmarshal array"},
+		{"vad.yaml", "threshold: 2\n", "threshold"},
+		{"vad.yaml", "threshold: .inf\n", "unsupported value"},
+		{"vad.toml", "smoothing_method = mean\n", "toml: line 1"},
+		{"vad.toml", "[[agc]]\n", "cannot unmarshal array"},
+		{"vad.toml", "threshold = 0.5\nthreshold.x = 1\n", "toml: line 2"},
+		{"vad.json", "{bad json}", "decode NUPI_ADAPTER_CONFIG_FILE"},
+	} {
+		if _, err := loadFile(t, tc.name, tc.content, nil); err == nil || !strings.Contains(err.Error(), tc.want) {
+			t.Errorf("%s %q: err = %v, want %q", tc.name, tc.content, err, tc.want)
+		}
+	}
+
+	missing := config.Loader{Lookup: func(key string) (string, bool) {
+		return map[string]string{"NUPI_ADAPTER_CONFIG_FILE": filepath.Join(t.TempDir(), "missing.yaml")}[key], key == "NUPI_ADAPTER_CONFIG_FILE"
+	}}
+	if _, err := missing.Load(); err == nil || !strings.Contains(err.Error(), "read NUPI_ADAPTER_CONFIG_FILE") {
+		t.Errorf("missing file: err = %v", err)
+	}
+	watchOnly := config.Loader{Lookup: func(key string) (string, bool) {
+		return "1", key == "NUPI_ADAPTER_CONFIG_WATCH"
+	}}
+	if _, err := watchOnly.Load(); err == nil || !strings.Contains(err.Error(), "requires NUPI_ADAPTER_CONFIG_FILE") {
+		t.Errorf("watch without a file: err = %v", err)
+	}
+}
+
+func TestWatchFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "vad.yaml")
+	if err := os.WriteFile(path, []byte("threshold: 0.5\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	changed := make(chan struct{}, 10)
+	go config.WatchFile(ctx, path, 10*time.Millisecond, func() { changed <- struct{}{} })
+
+	select {
+	case <-changed:
+		t.Fatal("change reported for an unmodified file")
+	case <-time.After(50 * time.Millisecond):
+	}
+	// Replace the file through a rename, as editors do.
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, []byte("threshold: 0.65\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case <-changed:
+	case <-time.After(5 * time.Second):
+		t.Fatal("change not reported")
+	}
+}
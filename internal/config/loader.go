@@ -2,6 +2,7 @@ package config
 
 import (
 	"encoding/json"
+	"errors"
 	"fmt"
 	"os"
 	"strconv"
@@ -12,12 +13,18 @@ import (
 // Lookup to inject deterministic maps.
 type Loader struct {
 	Lookup func(string) (string, bool)
+	// Origin, when set, describes where Lookup found a variable's value,
+	// for Provenance (e.g. the command-line flag standing in for it). By
+	// default the variable's name is the source.
+	Origin func(key string) string
 }
 
 // LoadResult contains the loaded configuration and any warnings.
 type LoadResult struct {
 	Config   Config
 	Warnings []string
+	// Provenance names the source of every option's value.
+	Provenance Provenance
 }
 
 // Load retrieves the adapter configuration from environment variables.
@@ -32,16 +39,52 @@ func (l Loader) Load() (LoadResult, error) {
 		Threshold:            DefaultThreshold,
 		MinSpeechDurationMs:  DefaultMinSpeechDurationMs,
 		MinSilenceDurationMs: DefaultMinSilenceDurationMs,
+		HopMs:                DefaultHopMs,
+		RecentStreams:        DefaultRecentStreams,
+		SelfTestIntervalSec:  DefaultSelfTestIntervalSec,
 	}
 
 	var warnings []string
+	prov := newProvenance(cfg)
+	env := newEnvLayer(l.Lookup, l.Origin, &cfg, prov)
 
+	// The config file is applied first, then NUPI_ADAPTER_CONFIG, then the
+	// individual variables, each overriding the options it sets.
+	overrideString(env, "NUPI_ADAPTER_CONFIG_FILE", &cfg.ConfigFile)
+	if err := overrideBool(env, "NUPI_ADAPTER_CONFIG_WATCH", &cfg.ConfigWatch); err != nil {
+		return LoadResult{}, err
+	}
+	var fileRaw, jsonRaw string
+	if cfg.ConfigFile != "" {
+		raw, err := readConfigFile(cfg.ConfigFile)
+		if err != nil {
+			return LoadResult{}, err
+		}
+		fileRaw = raw
+	} else if cfg.ConfigWatch {
+		return LoadResult{}, errors.New("config: NUPI_ADAPTER_CONFIG_WATCH requires NUPI_ADAPTER_CONFIG_FILE")
+	}
 	if raw, ok := l.Lookup("NUPI_ADAPTER_CONFIG"); ok && strings.TrimSpace(raw) != "" {
-		jsonWarnings, err := applyJSON(raw, &cfg)
+		jsonRaw = raw
+	}
+	if err := applyPreset(env, &cfg, fileRaw, jsonRaw); err != nil {
+		return LoadResult{}, err
+	}
+	if fileRaw != "" {
+		fileWarnings, err := applyJSON(SourceFile, fileRaw, &cfg)
+		if err != nil {
+			return LoadResult{}, err
+		}
+		warnings = append(warnings, fileWarnings...)
+		prov.setFromPayload(fileRaw, SourceFile)
+	}
+	if jsonRaw != "" {
+		jsonWarnings, err := applyJSON(SourceJSON, jsonRaw, &cfg)
 		if err != nil {
 			return LoadResult{}, err
 		}
 		warnings = append(warnings, jsonWarnings...)
+		prov.setFromPayload(jsonRaw, SourceJSON)
 	}
 
 	// Warn about unsupported speech_pad_ms environment variable.
@@ -49,91 +92,678 @@ func (l Loader) Load() (LoadResult, error) {
 		warnings = append(warnings, "NUPI_VAD_SPEECH_PAD_MS is not supported and will be ignored; use min_speech_duration_ms and min_silence_duration_ms instead")
 	}
 
-	overrideString(l.Lookup, "NUPI_VAD_ENGINE", &cfg.Engine)
-	overrideString(l.Lookup, "NUPI_ADAPTER_LISTEN_ADDR", &cfg.ListenAddr)
-	overrideString(l.Lookup, "NUPI_LOG_LEVEL", &cfg.LogLevel)
-	if err := overrideFloat(l.Lookup, "NUPI_VAD_THRESHOLD", &cfg.Threshold); err != nil {
+	overrideString(env, "NUPI_VAD_ENGINE", &cfg.Engine)
+	overrideString(env, "NUPI_VAD_STUB_SCRIPT", &cfg.StubScript)
+	overrideString(env, "NUPI_ADAPTER_LISTEN_ADDR", &cfg.ListenAddr)
+	overrideString(env, "NUPI_VAD_LISTEN_NETWORK", &cfg.ListenNetwork)
+	overrideString(env, "NUPI_LOG_LEVEL", &cfg.LogLevel)
+	overrideString(env, "NUPI_LOG_FORMAT", &cfg.LogFormat)
+	overrideString(env, "NUPI_VAD_INSTANCE_ID", &cfg.InstanceID)
+	overrideString(env, "NUPI_VAD_PRESET", &cfg.Preset)
+	overrideString(env, "NUPI_VAD_SHADOW_ENGINE", &cfg.ShadowEngine)
+	overrideString(env, "NUPI_VAD_TRACE_DIR", &cfg.TraceDir)
+	overrideString(env, "NUPI_VAD_TRACE_STREAM_ID", &cfg.TraceStreamID)
+	overrideString(env, "NUPI_VAD_DEBUG_DUMP_DIR", &cfg.DebugDumpDir)
+	if err := overrideBool(env, "NUPI_VAD_DEBUG_DUMP", &cfg.DebugDump); err != nil {
+		return LoadResult{}, err
+	}
+	if err := overrideInt(env, "NUPI_VAD_DEBUG_DUMP_MAX_STREAM_MB", intRange{"debug_dump_max_stream_mb", 0, MaxDebugDumpStreamMB}, &cfg.DebugDumpMaxStreamMB); err != nil {
+		return LoadResult{}, err
+	}
+	if err := overrideInt(env, "NUPI_VAD_DEBUG_DUMP_MAX_TOTAL_MB", intRange{"debug_dump_max_total_mb", 0, MaxDebugDumpTotalMB}, &cfg.DebugDumpMaxTotalMB); err != nil {
+		return LoadResult{}, err
+	}
+	overrideString(env, "NUPI_VAD_MODEL_PATH", &cfg.ModelPath)
+	overrideString(env, "NUPI_VAD_MODEL_SHA256", &cfg.ModelSHA256)
+	overrideString(env, "NUPI_VAD_MODEL_VERSION", &cfg.ModelVersion)
+	overrideString(env, "NUPI_VAD_MODEL_PRECISION", &cfg.ModelPrecision)
+	overrideString(env, "NUPI_VAD_MODEL", &cfg.Model)
+	overrideString(env, "NUPI_VAD_MODEL_B_PATH", &cfg.ModelB.Path)
+	overrideString(env, "NUPI_VAD_MODEL_B_SHA256", &cfg.ModelB.SHA256)
+	overrideString(env, "NUPI_VAD_MODEL_B_VERSION", &cfg.ModelB.Version)
+	overrideString(env, "NUPI_VAD_MODEL_B_PRECISION", &cfg.ModelB.Precision)
+	overrideString(env, "NUPI_VAD_INTEGRITY_ORT_LIB_SHA256", &cfg.Integrity.ORTLibSHA256)
+	overrideString(env, "NUPI_VAD_INTEGRITY_EMBEDDED_MODEL_SHA256", &cfg.Integrity.EmbeddedModelSHA256)
+	if err := overrideBool(env, "NUPI_VAD_INTEGRITY_ALLOW_MISMATCH", &cfg.Integrity.AllowMismatch); err != nil {
+		return LoadResult{}, err
+	}
+	overrideString(env, "NUPI_VAD_TIMESTAMP_FORMAT", &cfg.TimestampFormat)
+	if err := overrideBool(env, "NUPI_VAD_STATELESS", &cfg.Stateless); err != nil {
+		return LoadResult{}, err
+	}
+	if err := overrideBool(env, "NUPI_VAD_ALLOW_RESAMPLING", &cfg.AllowResampling); err != nil {
+		return LoadResult{}, err
+	}
+	if err := overrideBool(env, "NUPI_VAD_PROXY_PROTOCOL", &cfg.ProxyProtocol); err != nil {
+		return LoadResult{}, err
+	}
+	overrideString(env, "NUPI_VAD_TLS_CERT_FILE", &cfg.TLSCertFile)
+	overrideString(env, "NUPI_VAD_TLS_KEY_FILE", &cfg.TLSKeyFile)
+	overrideString(env, "NUPI_VAD_TLS_CLIENT_CA_FILE", &cfg.TLSClientCAFile)
+	overrideString(env, "NUPI_ADAPTER_AUTH_TOKEN", &cfg.AuthToken)
+	if err := overrideBool(env, "NUPI_VAD_STRICT_PROTO", &cfg.StrictProto); err != nil {
+		return LoadResult{}, err
+	}
+	if err := overrideFloat(env, "NUPI_VAD_THRESHOLD", floatRange{"threshold", 0, 1}, &cfg.Threshold); err != nil {
+		return LoadResult{}, err
+	}
+	if err := overrideFloat(env, "NUPI_VAD_NEG_THRESHOLD", floatRange{"neg_threshold", 0, 1}, &cfg.NegThreshold); err != nil {
+		return LoadResult{}, err
+	}
+	if err := overrideInt(env, "NUPI_VAD_MIN_SPEECH_DURATION_MS", intRange{"min_speech_duration_ms", 1, MaxDurationMs}, &cfg.MinSpeechDurationMs); err != nil {
+		return LoadResult{}, err
+	}
+	if err := overrideInt(env, "NUPI_VAD_MIN_SILENCE_DURATION_MS", intRange{"min_silence_duration_ms", 1, MaxDurationMs}, &cfg.MinSilenceDurationMs); err != nil {
+		return LoadResult{}, err
+	}
+	if err := overrideInt(env, "NUPI_VAD_MAX_SPEECH_DURATION_MS", intRange{"max_speech_duration_ms", 0, MaxSpeechDurationLimitMs}, &cfg.MaxSpeechDurationMs); err != nil {
+		return LoadResult{}, err
+	}
+	if err := overrideInt(env, "NUPI_VAD_PREROLL_MS", intRange{"preroll_ms", 0, MaxDurationMs}, &cfg.PrerollMs); err != nil {
+		return LoadResult{}, err
+	}
+	if err := overrideInt(env, "NUPI_VAD_MIN_GAP_MS", intRange{"min_gap_ms", 0, MaxDurationMs}, &cfg.MinGapMs); err != nil {
+		return LoadResult{}, err
+	}
+	if err := overrideInt(env, "NUPI_VAD_SMOOTHING_FRAMES", intRange{"smoothing_frames", 0, MaxSmoothingFrames}, &cfg.SmoothingFrames); err != nil {
+		return LoadResult{}, err
+	}
+	overrideString(env, "NUPI_VAD_SMOOTHING_METHOD", &cfg.SmoothingMethod)
+	overrideString(env, "NUPI_VAD_ENSEMBLE_ENGINE", &cfg.Ensemble.Engine)
+	overrideString(env, "NUPI_VAD_ENSEMBLE_MODE", &cfg.Ensemble.Mode)
+	if err := overrideFloat(env, "NUPI_VAD_ENSEMBLE_WEIGHT", floatRange{"ensemble.weight", 0, 1}, &cfg.Ensemble.Weight); err != nil {
+		return LoadResult{}, err
+	}
+	if err := overrideBool(env, "NUPI_VAD_DENOISE", &cfg.Denoise); err != nil {
+		return LoadResult{}, err
+	}
+	if err := overrideFloat(env, "NUPI_VAD_AGC_TARGET_DBFS", floatRange{"agc.target_dbfs", MinAGCTargetDbfs, 0}, &cfg.AGC.TargetDbfs); err != nil {
+		return LoadResult{}, err
+	}
+	if err := overrideFloat(env, "NUPI_VAD_AGC_MAX_GAIN_DB", floatRange{"agc.max_gain_db", 0, MaxAGCGainDb}, &cfg.AGC.MaxGainDb); err != nil {
+		return LoadResult{}, err
+	}
+	if err := overrideInt(env, "NUPI_VAD_HIGH_PASS_HZ", intRange{"high_pass_hz", 0, MaxHighPassHz}, &cfg.HighPassHz); err != nil {
+		return LoadResult{}, err
+	}
+	if err := overrideInt(env, "NUPI_VAD_HEARTBEAT_INTERVAL_MS", intRange{"heartbeat_interval_ms", 0, MaxHeartbeatIntervalMs}, &cfg.HeartbeatIntervalMs); err != nil {
+		return LoadResult{}, err
+	}
+	overrideString(env, "NUPI_VAD_CPU_BUDGET_ACTION", &cfg.CPUBudget.Action)
+	overrideString(env, "NUPI_VAD_CPU_BUDGET_ENGINE", &cfg.CPUBudget.Engine)
+	if err := overrideInt(env, "NUPI_VAD_CPU_BUDGET_PERCENT", intRange{"cpu_budget.percent", 0, 100}, &cfg.CPUBudget.Percent); err != nil {
+		return LoadResult{}, err
+	}
+	if err := overrideInt(env, "NUPI_VAD_CPU_BUDGET_SLICE_S", intRange{"cpu_budget.slice_s", 0, MaxCPUBudgetSliceSec}, &cfg.CPUBudget.SliceSec); err != nil {
+		return LoadResult{}, err
+	}
+	if err := overrideFloat(env, "NUPI_VAD_INFERENCE_BUDGET_MS", floatRange{"inference_budget.frame_ms", 0, MaxInferenceBudgetFrameMs}, &cfg.InferenceBudget.FrameMs); err != nil {
+		return LoadResult{}, err
+	}
+	if err := overrideInt(env, "NUPI_VAD_INFERENCE_BUDGET_FRAMES", intRange{"inference_budget.frames", 0, MaxInferenceBudgetFrames}, &cfg.InferenceBudget.Frames); err != nil {
+		return LoadResult{}, err
+	}
+	overrideString(env, "NUPI_VAD_INFERENCE_BUDGET_ACTION", &cfg.InferenceBudget.Action)
+	if err := overrideInt(env, "NUPI_VAD_ORT_INTRA_OP_THREADS", intRange{"ort.intra_op_threads", 0, MaxORTThreads}, &cfg.ORT.IntraOpThreads); err != nil {
+		return LoadResult{}, err
+	}
+	if err := overrideInt(env, "NUPI_VAD_ORT_INTER_OP_THREADS", intRange{"ort.inter_op_threads", 0, MaxORTThreads}, &cfg.ORT.InterOpThreads); err != nil {
+		return LoadResult{}, err
+	}
+	overrideString(env, "NUPI_VAD_ORT_GRAPH_OPTIMIZATION", &cfg.ORT.GraphOptimization)
+	if err := overrideOptionalBool(env, "NUPI_VAD_ORT_MEM_ARENA", &cfg.ORT.MemArena); err != nil {
+		return LoadResult{}, err
+	}
+	if err := overrideOptionalBool(env, "NUPI_VAD_ORT_MEM_PATTERN", &cfg.ORT.MemPattern); err != nil {
+		return LoadResult{}, err
+	}
+	if err := overrideInt(env, "NUPI_VAD_HOP_MS", intRange{"hop_ms", 8, DefaultHopMs}, &cfg.HopMs); err != nil {
+		return LoadResult{}, err
+	}
+	if err := overrideInt(env, "NUPI_VAD_CANDIDATE_PERCENT", intRange{"candidate_percent", 0, 100}, &cfg.CandidatePercent); err != nil {
+		return LoadResult{}, err
+	}
+	if err := overrideInt(env, "NUPI_VAD_SHADOW_PERCENT", intRange{"shadow_percent", 0, 100}, &cfg.ShadowPercent); err != nil {
+		return LoadResult{}, err
+	}
+	if err := overrideInt(env, "NUPI_VAD_RECENT_STREAMS", intRange{"recent_streams", 0, MaxRecentStreams}, &cfg.RecentStreams); err != nil {
+		return LoadResult{}, err
+	}
+	if err := overrideInt(env, "NUPI_VAD_MAX_CHUNK_BYTES", intRange{"max_chunk_bytes", MinMaxChunkBytes, MaxMaxChunkBytes}, &cfg.MaxChunkBytes); err != nil {
+		return LoadResult{}, err
+	}
+	if err := overrideInt(env, "NUPI_VAD_GRPC_KEEPALIVE_TIME_S", intRange{"grpc.keepalive_time_s", 0, MaxGRPCDurationSec}, &cfg.GRPC.KeepaliveTimeSec); err != nil {
+		return LoadResult{}, err
+	}
+	if err := overrideInt(env, "NUPI_VAD_GRPC_KEEPALIVE_TIMEOUT_S", intRange{"grpc.keepalive_timeout_s", 0, MaxGRPCDurationSec}, &cfg.GRPC.KeepaliveTimeoutSec); err != nil {
+		return LoadResult{}, err
+	}
+	if err := overrideInt(env, "NUPI_VAD_GRPC_MAX_CONNECTION_IDLE_S", intRange{"grpc.max_connection_idle_s", 0, MaxGRPCDurationSec}, &cfg.GRPC.MaxConnectionIdleSec); err != nil {
+		return LoadResult{}, err
+	}
+	if err := overrideInt(env, "NUPI_VAD_GRPC_MAX_CONNECTION_AGE_S", intRange{"grpc.max_connection_age_s", 0, MaxGRPCDurationSec}, &cfg.GRPC.MaxConnectionAgeSec); err != nil {
+		return LoadResult{}, err
+	}
+	if err := overrideInt(env, "NUPI_VAD_GRPC_MAX_CONNECTION_AGE_GRACE_S", intRange{"grpc.max_connection_age_grace_s", 0, MaxGRPCDurationSec}, &cfg.GRPC.MaxConnectionAgeGraceSec); err != nil {
+		return LoadResult{}, err
+	}
+	if err := overrideInt(env, "NUPI_VAD_GRPC_KEEPALIVE_MIN_TIME_S", intRange{"grpc.keepalive_min_time_s", 0, MaxGRPCDurationSec}, &cfg.GRPC.KeepaliveMinTimeSec); err != nil {
+		return LoadResult{}, err
+	}
+	if err := overrideInt(env, "NUPI_VAD_GRPC_MAX_RECV_MSG_BYTES", intRange{"grpc.max_recv_msg_bytes", 0, MaxGRPCRecvMsgBytes}, &cfg.GRPC.MaxRecvMsgBytes); err != nil {
+		return LoadResult{}, err
+	}
+	if err := overrideBool(env, "NUPI_VAD_GRPC_KEEPALIVE_PERMIT_WITHOUT_STREAM", &cfg.GRPC.KeepalivePermitWithoutStream); err != nil {
+		return LoadResult{}, err
+	}
+	if err := overrideInt(env, "NUPI_VAD_MAX_BUFFERED_BYTES", intRange{"max_buffered_bytes", MinMaxBufferedBytes, MaxMaxBufferedBytes}, &cfg.MaxBufferedBytes); err != nil {
+		return LoadResult{}, err
+	}
+	if err := overrideInt(env, "NUPI_VAD_EVENT_QUEUE_SIZE", intRange{"event_queue_size", 0, MaxEventQueueSize}, &cfg.EventQueueSize); err != nil {
+		return LoadResult{}, err
+	}
+	overrideString(env, "NUPI_VAD_EVENT_QUEUE_POLICY", &cfg.EventQueuePolicy)
+	if err := overrideInt(env, "NUPI_VAD_PIPELINE_DEPTH", intRange{"pipeline_depth", 0, MaxPipelineDepth}, &cfg.PipelineDepth); err != nil {
+		return LoadResult{}, err
+	}
+	if err := overrideInt(env, "NUPI_VAD_EXPECTED_STREAMS", intRange{"expected_streams", 0, MaxExpectedStreams}, &cfg.ExpectedStreams); err != nil {
+		return LoadResult{}, err
+	}
+	if err := overrideInt(env, "NUPI_VAD_MAX_STREAMS", intRange{"max_streams", 0, MaxMaxStreams}, &cfg.MaxStreams); err != nil {
+		return LoadResult{}, err
+	}
+	overrideString(env, "NUPI_VAD_BILLING_OUTPUT", &cfg.BillingOutput)
+	overrideString(env, "NUPI_VAD_SEGMENT_SINK", &cfg.SegmentSink)
+	if err := overrideFloat(env, "NUPI_VAD_FEEDBACK_STEP", floatRange{"feedback.step", 0, MaxFeedbackStep}, &cfg.Feedback.Step); err != nil {
+		return LoadResult{}, err
+	}
+	if err := overrideFloat(env, "NUPI_VAD_FEEDBACK_MAX_OFFSET", floatRange{"feedback.max_offset", 0, MaxFeedbackOffset}, &cfg.Feedback.MaxOffset); err != nil {
+		return LoadResult{}, err
+	}
+	overrideString(env, "NUPI_VAD_FEEDBACK_STATE_FILE", &cfg.Feedback.StateFile)
+	overrideString(env, "NUPI_VAD_METRICS_ADDR", &cfg.MetricsAddr)
+	overrideString(env, "NUPI_VAD_GATEWAY_ADDR", &cfg.GatewayAddr)
+	overrideString(env, "NUPI_VAD_GATEWAY_ORIGINS", &cfg.GatewayOrigins)
+	if err := overrideInt(env, "NUPI_VAD_STUCK_STREAM_TIMEOUT_S", intRange{"stuck_stream_timeout_s", 0, MaxStuckStreamTimeoutSec}, &cfg.StuckStreamTimeoutSec); err != nil {
+		return LoadResult{}, err
+	}
+	if err := overrideInt(env, "NUPI_VAD_SELF_TEST_INTERVAL_S", intRange{"self_test_interval_s", 0, MaxSelfTestIntervalSec}, &cfg.SelfTestIntervalSec); err != nil {
 		return LoadResult{}, err
 	}
-	if err := overrideInt(l.Lookup, "NUPI_VAD_MIN_SPEECH_DURATION_MS", &cfg.MinSpeechDurationMs); err != nil {
+	if err := overrideInt(env, "NUPI_VAD_MAX_STREAM_DURATION_S", intRange{"max_stream_duration_s", 0, MaxStreamDurationLimitSec}, &cfg.MaxStreamDurationSec); err != nil {
 		return LoadResult{}, err
 	}
-	if err := overrideInt(l.Lookup, "NUPI_VAD_MIN_SILENCE_DURATION_MS", &cfg.MinSilenceDurationMs); err != nil {
+	if err := overrideInt(env, "NUPI_VAD_MAX_STREAM_AUDIO_MB", intRange{"max_stream_audio_mb", 0, MaxStreamAudioLimitMB}, &cfg.MaxStreamAudioMB); err != nil {
+		return LoadResult{}, err
+	}
+	if err := overrideInt(env, "NUPI_VAD_DRIFT_REPORT_INTERVAL_S", intRange{"drift_report_interval_s", 0, MaxDriftReportIntervalSec}, &cfg.DriftReportIntervalSec); err != nil {
 		return LoadResult{}, err
 	}
 
 	if err := cfg.Validate(); err != nil {
 		return LoadResult{}, err
 	}
-	return LoadResult{Config: cfg, Warnings: warnings}, nil
+	return LoadResult{Config: cfg, Warnings: warnings, Provenance: prov}, nil
 }
 
-func applyJSON(raw string, cfg *Config) ([]string, error) {
+// applyJSON applies the options set in raw, a JSON object read from source,
+// to cfg.
+func applyJSON(source, raw string, cfg *Config) ([]string, error) {
 	// Include speech_pad_ms in struct to detect if it was set.
 	type jsonConfig struct {
-		Engine               string   `json:"engine"`
-		ListenAddr           string   `json:"listen_addr"`
-		LogLevel             string   `json:"log_level"`
-		Threshold            *float64 `json:"threshold"`
-		MinSpeechDurationMs  *int     `json:"min_speech_duration_ms"`
-		MinSilenceDurationMs *int     `json:"min_silence_duration_ms"`
-		SpeechPadMs          *int     `json:"speech_pad_ms"` // unsupported, for warning only
+		Engine               string             `json:"engine"`
+		ListenAddr           string             `json:"listen_addr"`
+		ListenNetwork        string             `json:"listen_network"`
+		LogLevel             string             `json:"log_level"`
+		LogFormat            string             `json:"log_format"`
+		InstanceID           string             `json:"instance_id"`
+		Preset               string             `json:"preset"`
+		StubScript           string             `json:"stub_script"`
+		Threshold            *float64           `json:"threshold"`
+		NegThreshold         *float64           `json:"neg_threshold"`
+		MinSpeechDurationMs  *int               `json:"min_speech_duration_ms"`
+		MinSilenceDurationMs *int               `json:"min_silence_duration_ms"`
+		MaxSpeechDurationMs  *int               `json:"max_speech_duration_ms"`
+		PrerollMs            *int               `json:"preroll_ms"`
+		MinGapMs             *int               `json:"min_gap_ms"`
+		SmoothingFrames      *int               `json:"smoothing_frames"`
+		SmoothingMethod      *string            `json:"smoothing_method"`
+		AdaptiveSilence      *AdaptiveSilence   `json:"adaptive_silence"`
+		AdaptiveThreshold    *AdaptiveThreshold `json:"adaptive_threshold"`
+		FrameSkip            *FrameSkip         `json:"frame_skip"`
+		Ensemble             *Ensemble          `json:"ensemble"`
+		Denoise              *bool              `json:"denoise"`
+		HighPassHz           *int               `json:"high_pass_hz"`
+		HeartbeatIntervalMs  *int               `json:"heartbeat_interval_ms"`
+		AGC                  *AGC               `json:"agc"`
+		SpeechPadMs          *int               `json:"speech_pad_ms"` // unsupported, for warning only
+		HopMs                *int               `json:"hop_ms"`
+		Candidate            *VADParams         `json:"candidate"`
+		CandidatePercent     *int               `json:"candidate_percent"`
+		ShadowEngine         string             `json:"shadow_engine"`
+		Shadow               *VADParams         `json:"shadow"`
+		ShadowPercent        *int               `json:"shadow_percent"`
+		DriftReportIntervalS *int               `json:"drift_report_interval_s"`
+		StuckStreamTimeoutS  *int               `json:"stuck_stream_timeout_s"`
+		SelfTestIntervalS    *int               `json:"self_test_interval_s"`
+		MaxStreamDurationS   *int               `json:"max_stream_duration_s"`
+		MaxStreamAudioMB     *int               `json:"max_stream_audio_mb"`
+		RecentStreams        *int               `json:"recent_streams"`
+		ExpectedStreams      *int               `json:"expected_streams"`
+		MaxStreams           *int               `json:"max_streams"`
+		MaxChunkBytes        *int               `json:"max_chunk_bytes"`
+		GRPC                 *GRPCServer        `json:"grpc"`
+		MaxBufferedBytes     *int               `json:"max_buffered_bytes"`
+		EventQueueSize       *int               `json:"event_queue_size"`
+		EventQueuePolicy     string             `json:"event_queue_policy"`
+		PipelineDepth        *int               `json:"pipeline_depth"`
+		BillingOutput        *string            `json:"billing_output"`
+		SegmentSink          *string            `json:"segment_sink"`
+		Feedback             *FeedbackTuning    `json:"feedback"`
+		CPUBudget            *CPUBudget         `json:"cpu_budget"`
+		InferenceBudget      *InferenceBudget   `json:"inference_budget"`
+		ORT                  *ORT               `json:"ort"`
+		Integrity            *Integrity         `json:"integrity"`
+		ModelB               *SecondModel       `json:"model_b"`
+		Model                string             `json:"model"`
+		MetricsAddr          *string            `json:"metrics_addr"`
+		GatewayAddr          *string            `json:"gateway_addr"`
+		GatewayOrigins       *string            `json:"gateway_origins"`
+		Stateless            *bool              `json:"stateless"`
+		AllowResampling      *bool              `json:"allow_resampling"`
+		StrictProto          *bool              `json:"strict_proto"`
+		ProxyProtocol        *bool              `json:"proxy_protocol"`
+		TLSCertFile          string             `json:"tls_cert_file"`
+		TLSKeyFile           string             `json:"tls_key_file"`
+		TLSClientCAFile      string             `json:"tls_client_ca_file"`
+		AuthToken            string             `json:"auth_token"`
+		TraceDir             string             `json:"trace_dir"`
+		TraceStreamID        string             `json:"trace_stream_id"`
+		DebugDumpDir         string             `json:"debug_dump_dir"`
+		DebugDump            *bool              `json:"debug_dump"`
+		DebugDumpMaxStreamMB *int               `json:"debug_dump_max_stream_mb"`
+		DebugDumpMaxTotalMB  *int               `json:"debug_dump_max_total_mb"`
+		ModelPath            string             `json:"model_path"`
+		ModelSHA256          string             `json:"model_sha256"`
+		ModelVersion         string             `json:"model_version"`
+		ModelPrecision       string             `json:"model_precision"`
+		TimestampFormat      string             `json:"timestamp_format"`
 	}
 	var payload jsonConfig
 	if err := json.Unmarshal([]byte(raw), &payload); err != nil {
-		return nil, fmt.Errorf("config: decode NUPI_ADAPTER_CONFIG: %w", err)
+		return nil, fmt.Errorf("config: decode %s: %w", source, err)
 	}
 
 	var warnings []string
 	if payload.SpeechPadMs != nil {
-		warnings = append(warnings, "speech_pad_ms in NUPI_ADAPTER_CONFIG is not supported and will be ignored; use min_speech_duration_ms and min_silence_duration_ms instead")
+		warnings = append(warnings, "speech_pad_ms in "+source+" is not supported and will be ignored; use min_speech_duration_ms and min_silence_duration_ms instead")
 	}
 
 	if payload.Engine != "" {
 		cfg.Engine = payload.Engine
 	}
+	if payload.StubScript != "" {
+		cfg.StubScript = payload.StubScript
+	}
+	// The preset's parameters were expanded by applyPreset.
+	if payload.Preset != "" {
+		cfg.Preset = payload.Preset
+	}
 	if payload.ListenAddr != "" {
 		cfg.ListenAddr = payload.ListenAddr
 	}
+	if payload.ListenNetwork != "" {
+		cfg.ListenNetwork = payload.ListenNetwork
+	}
 	if payload.LogLevel != "" {
 		cfg.LogLevel = payload.LogLevel
 	}
+	if payload.LogFormat != "" {
+		cfg.LogFormat = payload.LogFormat
+	}
+	if payload.InstanceID != "" {
+		cfg.InstanceID = payload.InstanceID
+	}
 	if payload.Threshold != nil {
 		cfg.Threshold = *payload.Threshold
 	}
+	if payload.NegThreshold != nil {
+		cfg.NegThreshold = *payload.NegThreshold
+	}
 	if payload.MinSpeechDurationMs != nil {
 		cfg.MinSpeechDurationMs = *payload.MinSpeechDurationMs
 	}
 	if payload.MinSilenceDurationMs != nil {
 		cfg.MinSilenceDurationMs = *payload.MinSilenceDurationMs
 	}
+	if payload.MaxSpeechDurationMs != nil {
+		cfg.MaxSpeechDurationMs = *payload.MaxSpeechDurationMs
+	}
+	if payload.PrerollMs != nil {
+		cfg.PrerollMs = *payload.PrerollMs
+	}
+	if payload.MinGapMs != nil {
+		cfg.MinGapMs = *payload.MinGapMs
+	}
+	if payload.SmoothingFrames != nil {
+		cfg.SmoothingFrames = *payload.SmoothingFrames
+	}
+	if payload.SmoothingMethod != nil {
+		cfg.SmoothingMethod = *payload.SmoothingMethod
+	}
+	if payload.AdaptiveSilence != nil {
+		cfg.AdaptiveSilence = *payload.AdaptiveSilence
+	}
+	if payload.AdaptiveThreshold != nil {
+		cfg.AdaptiveThreshold = *payload.AdaptiveThreshold
+	}
+	if payload.FrameSkip != nil {
+		cfg.FrameSkip = *payload.FrameSkip
+	}
+	if payload.Ensemble != nil {
+		cfg.Ensemble = *payload.Ensemble
+	}
+	if payload.Denoise != nil {
+		cfg.Denoise = *payload.Denoise
+	}
+	if payload.HighPassHz != nil {
+		cfg.HighPassHz = *payload.HighPassHz
+	}
+	if payload.HeartbeatIntervalMs != nil {
+		cfg.HeartbeatIntervalMs = *payload.HeartbeatIntervalMs
+	}
+	if payload.AGC != nil {
+		cfg.AGC = *payload.AGC
+	}
+	if payload.HopMs != nil {
+		cfg.HopMs = *payload.HopMs
+	}
+	if payload.Candidate != nil {
+		cfg.Candidate = *payload.Candidate
+	}
+	if payload.CandidatePercent != nil {
+		cfg.CandidatePercent = *payload.CandidatePercent
+	}
+	if payload.ShadowEngine != "" {
+		cfg.ShadowEngine = payload.ShadowEngine
+	}
+	if payload.Shadow != nil {
+		cfg.Shadow = *payload.Shadow
+	}
+	if payload.ShadowPercent != nil {
+		cfg.ShadowPercent = *payload.ShadowPercent
+	}
+	if payload.DriftReportIntervalS != nil {
+		cfg.DriftReportIntervalSec = *payload.DriftReportIntervalS
+	}
+	if payload.StuckStreamTimeoutS != nil {
+		cfg.StuckStreamTimeoutSec = *payload.StuckStreamTimeoutS
+	}
+	if payload.SelfTestIntervalS != nil {
+		cfg.SelfTestIntervalSec = *payload.SelfTestIntervalS
+	}
+	if payload.MaxStreamDurationS != nil {
+		cfg.MaxStreamDurationSec = *payload.MaxStreamDurationS
+	}
+	if payload.MaxStreamAudioMB != nil {
+		cfg.MaxStreamAudioMB = *payload.MaxStreamAudioMB
+	}
+	if payload.RecentStreams != nil {
+		cfg.RecentStreams = *payload.RecentStreams
+	}
+	if payload.ExpectedStreams != nil {
+		cfg.ExpectedStreams = *payload.ExpectedStreams
+	}
+	if payload.MaxStreams != nil {
+		cfg.MaxStreams = *payload.MaxStreams
+	}
+	if payload.MaxChunkBytes != nil {
+		cfg.MaxChunkBytes = *payload.MaxChunkBytes
+	}
+	if payload.GRPC != nil {
+		cfg.GRPC = *payload.GRPC
+	}
+	if payload.MaxBufferedBytes != nil {
+		cfg.MaxBufferedBytes = *payload.MaxBufferedBytes
+	}
+	if payload.EventQueueSize != nil {
+		cfg.EventQueueSize = *payload.EventQueueSize
+	}
+	if payload.EventQueuePolicy != "" {
+		cfg.EventQueuePolicy = payload.EventQueuePolicy
+	}
+	if payload.PipelineDepth != nil {
+		cfg.PipelineDepth = *payload.PipelineDepth
+	}
+	if payload.BillingOutput != nil {
+		cfg.BillingOutput = *payload.BillingOutput
+	}
+	if payload.SegmentSink != nil {
+		cfg.SegmentSink = *payload.SegmentSink
+	}
+	if payload.Feedback != nil {
+		cfg.Feedback = *payload.Feedback
+	}
+	if payload.CPUBudget != nil {
+		cfg.CPUBudget = *payload.CPUBudget
+	}
+	if payload.InferenceBudget != nil {
+		cfg.InferenceBudget = *payload.InferenceBudget
+	}
+	if payload.ORT != nil {
+		cfg.ORT = *payload.ORT
+	}
+	if payload.Integrity != nil {
+		cfg.Integrity = *payload.Integrity
+	}
+	if payload.ModelB != nil {
+		cfg.ModelB = *payload.ModelB
+	}
+	if payload.Model != "" {
+		cfg.Model = payload.Model
+	}
+	if payload.MetricsAddr != nil {
+		cfg.MetricsAddr = *payload.MetricsAddr
+	}
+	if payload.GatewayAddr != nil {
+		cfg.GatewayAddr = *payload.GatewayAddr
+	}
+	if payload.GatewayOrigins != nil {
+		cfg.GatewayOrigins = *payload.GatewayOrigins
+	}
+	if payload.Stateless != nil {
+		cfg.Stateless = *payload.Stateless
+	}
+	if payload.AllowResampling != nil {
+		cfg.AllowResampling = *payload.AllowResampling
+	}
+	if payload.StrictProto != nil {
+		cfg.StrictProto = *payload.StrictProto
+	}
+	if payload.ProxyProtocol != nil {
+		cfg.ProxyProtocol = *payload.ProxyProtocol
+	}
+	if payload.TLSCertFile != "" {
+		cfg.TLSCertFile = payload.TLSCertFile
+	}
+	if payload.TLSKeyFile != "" {
+		cfg.TLSKeyFile = payload.TLSKeyFile
+	}
+	if payload.TLSClientCAFile != "" {
+		cfg.TLSClientCAFile = payload.TLSClientCAFile
+	}
+	if payload.AuthToken != "" {
+		cfg.AuthToken = strings.TrimSpace(payload.AuthToken)
+	}
+	if payload.TraceDir != "" {
+		cfg.TraceDir = payload.TraceDir
+	}
+	if payload.TraceStreamID != "" {
+		cfg.TraceStreamID = payload.TraceStreamID
+	}
+	if payload.DebugDumpDir != "" {
+		cfg.DebugDumpDir = payload.DebugDumpDir
+	}
+	if payload.DebugDump != nil {
+		cfg.DebugDump = *payload.DebugDump
+	}
+	if payload.DebugDumpMaxStreamMB != nil {
+		cfg.DebugDumpMaxStreamMB = *payload.DebugDumpMaxStreamMB
+	}
+	if payload.DebugDumpMaxTotalMB != nil {
+		cfg.DebugDumpMaxTotalMB = *payload.DebugDumpMaxTotalMB
+	}
+	if payload.ModelPath != "" {
+		cfg.ModelPath = payload.ModelPath
+	}
+	if payload.ModelSHA256 != "" {
+		cfg.ModelSHA256 = payload.ModelSHA256
+	}
+	if payload.ModelVersion != "" {
+		cfg.ModelVersion = payload.ModelVersion
+	}
+	if payload.ModelPrecision != "" {
+		cfg.ModelPrecision = payload.ModelPrecision
+	}
+	if payload.TimestampFormat != "" {
+		cfg.TimestampFormat = payload.TimestampFormat
+	}
 	return warnings, nil
 }
 
-func overrideString(lookup func(string) (string, bool), key string, target *string) {
-	if value, ok := lookup(key); ok && strings.TrimSpace(value) != "" {
+func overrideString(env *envLayer, key string, target *string) {
+	if value, ok := env.lookup(key); ok && strings.TrimSpace(value) != "" {
 		*target = strings.TrimSpace(value)
+		env.applied(key, target)
 	}
 }
 
-func overrideFloat(lookup func(string) (string, bool), key string, target *float64) error {
-	if value, ok := lookup(key); ok && strings.TrimSpace(value) != "" {
-		parsed, err := strconv.ParseFloat(strings.TrimSpace(value), 64)
+// floatRange and intRange are the accepted range of a numeric environment
+// variable, checked when it is parsed so the error names the variable that
+// was set rather than only the option it feeds. Validate still applies the
+// finer rules (neg_threshold <= threshold, hop_ms in {8, 16, 32}, ...).
+type floatRange struct {
+	option   string
+	min, max float64
+}
+
+type intRange struct {
+	option   string
+	min, max int
+}
+
+func overrideFloat(env *envLayer, key string, r floatRange, target *float64) error {
+	if value, ok := env.lookup(key); ok && strings.TrimSpace(value) != "" {
+		value = strings.TrimSpace(value)
+		parsed, err := parseDecimal(value)
 		if err != nil {
-			return fmt.Errorf("config: invalid value for %s: %w", key, err)
+			return fmt.Errorf("config: invalid value for %s (%s): %w", key, r.option, err)
+		}
+		if parsed < r.min || parsed > r.max {
+			return fmt.Errorf("config: invalid value for %s (%s): must be in [%g, %g], got %s", key, r.option, r.min, r.max, value)
+		}
+		*target = parsed
+		env.applied(key, target)
+	}
+	return nil
+}
+
+func overrideInt(env *envLayer, key string, r intRange, target *int) error {
+	if value, ok := env.lookup(key); ok && strings.TrimSpace(value) != "" {
+		value = strings.TrimSpace(value)
+		parsed, err := parseInteger(value)
+		if err != nil {
+			return fmt.Errorf("config: invalid value for %s (%s): %w", key, r.option, err)
+		}
+		if parsed < r.min || parsed > r.max {
+			return fmt.Errorf("config: invalid value for %s (%s): must be in [%d, %d], got %s", key, r.option, r.min, r.max, value)
 		}
 		*target = parsed
+		env.applied(key, target)
+	}
+	return nil
+}
+
+// parseDecimal parses a plain decimal number such as "0.5", "-1" or "5e-1".
+// strconv.ParseFloat also accepts hex floats, "Inf" and "NaN", none of which
+// is a sensible setting; a decimal comma ("0,5", as written in many locales)
+// gets an error that says so instead of a bare syntax error.
+func parseDecimal(value string) (float64, error) {
+	if strings.Count(value, ",") == 1 {
+		dotted := strings.Replace(value, ",", ".", 1)
+		if _, err := strconv.ParseFloat(dotted, 64); err == nil {
+			return 0, fmt.Errorf("%q uses a decimal comma; write %q", value, dotted)
+		}
+	}
+	for _, r := range value {
+		if (r < '0' || r > '9') && !strings.ContainsRune(".eE+-", r) {
+			return 0, fmt.Errorf("%q is not a decimal number", value)
+		}
+	}
+	parsed, err := strconv.ParseFloat(value, 64)
+	if errors.Is(err, strconv.ErrRange) {
+		return 0, fmt.Errorf("%q is out of range", value)
+	}
+	if err != nil {
+		return 0, fmt.Errorf("%q is not a decimal number", value)
+	}
+	return parsed, nil
+}
+
+// parseInteger parses a base-10 integer, with a hint for the usual mistakes:
+// digit grouping ("1,000", "1_000") and fractions ("250.0", "0,5").
+func parseInteger(value string) (int, error) {
+	parsed, err := strconv.Atoi(value)
+	if err == nil {
+		return parsed, nil
+	}
+	if errors.Is(err, strconv.ErrRange) {
+		return 0, fmt.Errorf("%q is out of range", value)
+	}
+	switch {
+	case strings.ContainsAny(value, ",_'") && isDigits(strings.NewReplacer(",", "", "_", "", "'", "").Replace(value)):
+		return 0, fmt.Errorf("%q uses digit grouping; write it without separators", value)
+	case strings.ContainsAny(value, ".,"):
+		return 0, fmt.Errorf("%q must be a whole number", value)
+	}
+	return 0, fmt.Errorf("%q is not an integer", value)
+}
+
+func isDigits(s string) bool {
+	s = strings.TrimLeft(s, "+-")
+	if s == "" {
+		return false
+	}
+	for _, r := range s {
+		if r < '0' || r > '9' {
+			return false
+		}
+	}
+	return true
+}
+
+// overrideOptionalBool is overrideBool for options where unset differs
+// from false.
+func overrideOptionalBool(env *envLayer, key string, target **bool) error {
+	if value, ok := env.lookup(key); ok && strings.TrimSpace(value) != "" {
+		var parsed bool
+		if err := overrideBool(env, key, &parsed); err != nil {
+			return err
+		}
+		*target = &parsed
+		env.applied(key, target)
 	}
 	return nil
 }
 
-func overrideInt(lookup func(string) (string, bool), key string, target *int) error {
-	if value, ok := lookup(key); ok && strings.TrimSpace(value) != "" {
-		parsed, err := strconv.Atoi(strings.TrimSpace(value))
+func overrideBool(env *envLayer, key string, target *bool) error {
+	if value, ok := env.lookup(key); ok && strings.TrimSpace(value) != "" {
+		parsed, err := strconv.ParseBool(strings.TrimSpace(value))
 		if err != nil {
 			return fmt.Errorf("config: invalid value for %s: %w", key, err)
 		}
 		*target = parsed
+		env.applied(key, target)
 	}
 	return nil
 }
@@ -2,10 +2,12 @@ package config_test
 
 import (
 	"math"
+	"slices"
 	"strings"
 	"testing"
 
 	"github.com/nupi-ai/plugin-vad-local-silero/internal/config"
+	"github.com/nupi-ai/plugin-vad-local-silero/internal/engine"
 )
 
 func TestLoaderDefaults(t *testing.T) {
@@ -468,3 +470,2294 @@ func TestLoaderWarnsSpeechPadMsJSON(t *testing.T) {
 		t.Errorf("expected warning about speech_pad_ms, got: %v", result.Warnings)
 	}
 }
+
+func TestLoaderCandidateJSON(t *testing.T) {
+	env := map[string]string{
+		"NUPI_VAD_ENGINE":     "stub",
+		"NUPI_ADAPTER_CONFIG": `{"threshold":0.5,"candidate":{"threshold":0.65},"candidate_percent":10}`,
+	}
+	loader := config.Loader{
+		Lookup: func(key string) (string, bool) {
+			v, ok := env[key]
+			return v, ok
+		},
+	}
+	result, err := loader.Load()
+	if err != nil {
+		t.Fatal(err)
+	}
+	cfg := result.Config
+	if cfg.CandidatePercent != 10 {
+		t.Errorf("CandidatePercent = %d, want 10", cfg.CandidatePercent)
+	}
+	candidate := cfg.CandidateConfig()
+	if candidate.Threshold != 0.65 {
+		t.Errorf("candidate Threshold = %v, want 0.65", candidate.Threshold)
+	}
+	// Fields not set on the candidate inherit the baseline.
+	if candidate.MinSilenceDurationMs != cfg.MinSilenceDurationMs {
+		t.Errorf("candidate MinSilenceDurationMs = %d, want inherited %d", candidate.MinSilenceDurationMs, cfg.MinSilenceDurationMs)
+	}
+	// The baseline must not be modified by CandidateConfig.
+	if cfg.Threshold != 0.5 {
+		t.Errorf("baseline Threshold = %v, want 0.5", cfg.Threshold)
+	}
+}
+
+func TestLoaderCandidatePercentEnvOverride(t *testing.T) {
+	env := map[string]string{
+		"NUPI_VAD_ENGINE":            "stub",
+		"NUPI_ADAPTER_CONFIG":        `{"candidate_percent":10}`,
+		"NUPI_VAD_CANDIDATE_PERCENT": "25",
+	}
+	loader := config.Loader{
+		Lookup: func(key string) (string, bool) {
+			v, ok := env[key]
+			return v, ok
+		},
+	}
+	result, err := loader.Load()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.Config.CandidatePercent != 25 {
+		t.Errorf("CandidatePercent = %d, want 25 (env override)", result.Config.CandidatePercent)
+	}
+}
+
+func TestLoaderCandidateValidation(t *testing.T) {
+	tests := []struct {
+		name    string
+		json    string
+		wantMsg string
+	}{
+		{"percent_negative", `{"candidate_percent":-1}`, "candidate_percent"},
+		{"percent_above_100", `{"candidate_percent":101}`, "candidate_percent"},
+		{"threshold_out_of_range", `{"candidate":{"threshold":1.5}}`, "candidate"},
+		{"min_silence_zero", `{"candidate":{"min_silence_duration_ms":0}}`, "min_silence_duration_ms"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			env := map[string]string{
+				"NUPI_VAD_ENGINE":     "stub",
+				"NUPI_ADAPTER_CONFIG": tt.json,
+			}
+			loader := config.Loader{
+				Lookup: func(key string) (string, bool) {
+					v, ok := env[key]
+					return v, ok
+				},
+			}
+			_, err := loader.Load()
+			if err == nil {
+				t.Fatal("expected validation error")
+			}
+			if !strings.Contains(err.Error(), tt.wantMsg) {
+				t.Errorf("error should mention %q, got: %v", tt.wantMsg, err)
+			}
+		})
+	}
+}
+
+func TestLoaderShadowConfig(t *testing.T) {
+	env := map[string]string{
+		"NUPI_VAD_ENGINE":         "stub",
+		"NUPI_ADAPTER_CONFIG":     `{"shadow_engine":"Silero","shadow":{"threshold":0.7},"shadow_percent":5}`,
+		"NUPI_VAD_SHADOW_PERCENT": "15",
+	}
+	loader := config.Loader{
+		Lookup: func(key string) (string, bool) {
+			v, ok := env[key]
+			return v, ok
+		},
+	}
+	result, err := loader.Load()
+	if err != nil {
+		t.Fatal(err)
+	}
+	cfg := result.Config
+	if cfg.ShadowEngine != config.EngineSilero {
+		t.Errorf("ShadowEngine = %q, want %q (normalized)", cfg.ShadowEngine, config.EngineSilero)
+	}
+	if cfg.ShadowPercent != 15 {
+		t.Errorf("ShadowPercent = %d, want 15 (env override)", cfg.ShadowPercent)
+	}
+	// The shadow inherits the stream's effective config except where it
+	// overrides a field.
+	streamCfg := cfg
+	streamCfg.MinSilenceDurationMs = 900
+	shadow := cfg.ShadowConfig(streamCfg)
+	if shadow.Threshold != 0.7 {
+		t.Errorf("shadow Threshold = %v, want 0.7", shadow.Threshold)
+	}
+	if shadow.MinSilenceDurationMs != 900 {
+		t.Errorf("shadow MinSilenceDurationMs = %d, want 900 (inherited from stream)", shadow.MinSilenceDurationMs)
+	}
+}
+
+func TestLoaderShadowValidation(t *testing.T) {
+	tests := []struct {
+		name    string
+		json    string
+		wantMsg string
+	}{
+		{"unknown_engine", `{"shadow_engine":"webrtc"}`, "shadow_engine"},
+		{"auto_engine", `{"shadow_engine":"auto"}`, "shadow_engine"},
+		{"percent_above_100", `{"shadow_percent":150}`, "shadow_percent"},
+		{"threshold_out_of_range", `{"shadow":{"threshold":-1}}`, "shadow"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			env := map[string]string{
+				"NUPI_VAD_ENGINE":     "stub",
+				"NUPI_ADAPTER_CONFIG": tt.json,
+			}
+			loader := config.Loader{
+				Lookup: func(key string) (string, bool) {
+					v, ok := env[key]
+					return v, ok
+				},
+			}
+			_, err := loader.Load()
+			if err == nil {
+				t.Fatal("expected validation error")
+			}
+			if !strings.Contains(err.Error(), tt.wantMsg) {
+				t.Errorf("error should mention %q, got: %v", tt.wantMsg, err)
+			}
+		})
+	}
+}
+
+func TestLoaderDriftReportInterval(t *testing.T) {
+	tests := []struct {
+		name    string
+		value   string
+		want    int
+		wantErr bool
+	}{
+		{"disabled_default", "", 0, false},
+		{"five_minutes", "300", 300, false},
+		{"negative", "-1", 0, true},
+		{"above_max", "86401", 0, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			env := map[string]string{
+				"NUPI_VAD_ENGINE":                  "stub",
+				"NUPI_VAD_DRIFT_REPORT_INTERVAL_S": tt.value,
+			}
+			loader := config.Loader{
+				Lookup: func(key string) (string, bool) {
+					v, ok := env[key]
+					return v, ok
+				},
+			}
+			result, err := loader.Load()
+			if tt.wantErr {
+				if err == nil {
+					t.Fatal("expected validation error")
+				}
+				if !strings.Contains(err.Error(), "drift_report_interval_s") {
+					t.Errorf("error should mention drift_report_interval_s, got: %v", err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if result.Config.DriftReportIntervalSec != tt.want {
+				t.Errorf("DriftReportIntervalSec = %d, want %d", result.Config.DriftReportIntervalSec, tt.want)
+			}
+		})
+	}
+}
+
+func TestLoaderInstanceID(t *testing.T) {
+	tests := []struct {
+		name    string
+		env     map[string]string
+		want    string
+		wantErr bool
+	}{
+		{
+			name: "env",
+			env:  map[string]string{"NUPI_VAD_INSTANCE_ID": "us-east-2/vad-7"},
+			want: "us-east-2/vad-7",
+		},
+		{
+			name: "json",
+			env:  map[string]string{"NUPI_ADAPTER_CONFIG": `{"instance_id":"eu-1"}`},
+			want: "eu-1",
+		},
+		{
+			name: "env_overrides_json",
+			env: map[string]string{
+				"NUPI_ADAPTER_CONFIG":  `{"instance_id":"eu-1"}`,
+				"NUPI_VAD_INSTANCE_ID": "eu-2",
+			},
+			want: "eu-2",
+		},
+		{
+			name:    "inner_space",
+			env:     map[string]string{"NUPI_VAD_INSTANCE_ID": "vad 1"},
+			wantErr: true,
+		},
+		{
+			name:    "too_long",
+			env:     map[string]string{"NUPI_VAD_INSTANCE_ID": strings.Repeat("a", config.MaxInstanceIDLen+1)},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.env["NUPI_VAD_ENGINE"] = "stub"
+			loader := config.Loader{
+				Lookup: func(key string) (string, bool) {
+					v, ok := tt.env[key]
+					return v, ok
+				},
+			}
+			result, err := loader.Load()
+			if tt.wantErr {
+				if err == nil {
+					t.Fatal("expected validation error")
+				}
+				if !strings.Contains(err.Error(), "instance_id") {
+					t.Errorf("error should mention instance_id, got: %v", err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if result.Config.InstanceID != tt.want {
+				t.Errorf("InstanceID = %q, want %q", result.Config.InstanceID, tt.want)
+			}
+		})
+	}
+}
+
+func TestLoaderStateless(t *testing.T) {
+	tests := []struct {
+		name    string
+		env     map[string]string
+		want    bool
+		wantErr bool
+	}{
+		{"default_off", map[string]string{}, false, false},
+		{"env_true", map[string]string{"NUPI_VAD_STATELESS": "true"}, true, false},
+		{"env_one", map[string]string{"NUPI_VAD_STATELESS": "1"}, true, false},
+		{"json", map[string]string{"NUPI_ADAPTER_CONFIG": `{"stateless":true}`}, true, false},
+		{"env_overrides_json", map[string]string{
+			"NUPI_ADAPTER_CONFIG": `{"stateless":true}`,
+			"NUPI_VAD_STATELESS":  "false",
+		}, false, false},
+		{"invalid", map[string]string{"NUPI_VAD_STATELESS": "maybe"}, false, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.env["NUPI_VAD_ENGINE"] = "stub"
+			loader := config.Loader{
+				Lookup: func(key string) (string, bool) {
+					v, ok := tt.env[key]
+					return v, ok
+				},
+			}
+			result, err := loader.Load()
+			if tt.wantErr {
+				if err == nil {
+					t.Fatal("expected error")
+				}
+				if !strings.Contains(err.Error(), "NUPI_VAD_STATELESS") {
+					t.Errorf("error should mention NUPI_VAD_STATELESS, got: %v", err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if result.Config.Stateless != tt.want {
+				t.Errorf("Stateless = %v, want %v", result.Config.Stateless, tt.want)
+			}
+		})
+	}
+}
+
+func TestLoaderAllowResampling(t *testing.T) {
+	env := map[string]string{
+		"NUPI_VAD_ENGINE":     "stub",
+		"NUPI_ADAPTER_CONFIG": `{"allow_resampling":true}`,
+	}
+	loader := config.Loader{
+		Lookup: func(key string) (string, bool) {
+			v, ok := env[key]
+			return v, ok
+		},
+	}
+	result, err := loader.Load()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !result.Config.AllowResampling {
+		t.Error("AllowResampling from JSON = false, want true")
+	}
+
+	env["NUPI_VAD_ALLOW_RESAMPLING"] = "false"
+	result, err = loader.Load()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.Config.AllowResampling {
+		t.Error("NUPI_VAD_ALLOW_RESAMPLING=false should override JSON")
+	}
+}
+
+func TestLoaderTraceRequiresBothSettings(t *testing.T) {
+	for _, key := range []string{"NUPI_VAD_TRACE_DIR", "NUPI_VAD_TRACE_STREAM_ID"} {
+		env := map[string]string{"NUPI_VAD_ENGINE": "stub", key: "x"}
+		loader := config.Loader{
+			Lookup: func(k string) (string, bool) {
+				v, ok := env[k]
+				return v, ok
+			},
+		}
+		_, err := loader.Load()
+		if err == nil || !strings.Contains(err.Error(), "trace_dir and trace_stream_id") {
+			t.Errorf("only %s set: err = %v, want pairing error", key, err)
+		}
+	}
+}
+
+func TestLoaderStatelessRejectsTrace(t *testing.T) {
+	env := map[string]string{
+		"NUPI_VAD_ENGINE":          "stub",
+		"NUPI_VAD_STATELESS":       "true",
+		"NUPI_VAD_TRACE_DIR":       "/tmp/vad-trace",
+		"NUPI_VAD_TRACE_STREAM_ID": "s1",
+	}
+	loader := config.Loader{
+		Lookup: func(key string) (string, bool) {
+			v, ok := env[key]
+			return v, ok
+		},
+	}
+	_, err := loader.Load()
+	if err == nil {
+		t.Fatal("expected stateless mode to reject trace_dir")
+	}
+	if !strings.Contains(err.Error(), "stateless") || !strings.Contains(err.Error(), "trace_dir") {
+		t.Errorf("error should name stateless mode and trace_dir, got: %v", err)
+	}
+}
+
+func TestLoaderStatelessRejectsFeedbackTuning(t *testing.T) {
+	env := map[string]string{
+		"NUPI_VAD_ENGINE":        "stub",
+		"NUPI_VAD_STATELESS":     "true",
+		"NUPI_VAD_FEEDBACK_STEP": "0.01",
+	}
+	loader := config.Loader{
+		Lookup: func(key string) (string, bool) {
+			v, ok := env[key]
+			return v, ok
+		},
+	}
+	_, err := loader.Load()
+	if err == nil || !strings.Contains(err.Error(), "stateless") || !strings.Contains(err.Error(), "feedback.step") {
+		t.Fatalf("err = %v, want stateless mode to reject feedback.step without a state file", err)
+	}
+
+	// Counting reports keeps no per-node state.
+	env["NUPI_VAD_FEEDBACK_STEP"] = "0"
+	if _, err := loader.Load(); err != nil {
+		t.Errorf("feedback counting only: %v", err)
+	}
+}
+
+func TestLoaderHopMs(t *testing.T) {
+	env := map[string]string{"NUPI_VAD_ENGINE": "stub"}
+	loader := config.Loader{
+		Lookup: func(key string) (string, bool) {
+			v, ok := env[key]
+			return v, ok
+		},
+	}
+	result, err := loader.Load()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.Config.HopMs != config.DefaultHopMs {
+		t.Errorf("default HopMs = %d, want %d", result.Config.HopMs, config.DefaultHopMs)
+	}
+
+	env["NUPI_ADAPTER_CONFIG"] = `{"hop_ms":16}`
+	result, err = loader.Load()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.Config.HopMs != 16 {
+		t.Errorf("HopMs from JSON = %d, want 16", result.Config.HopMs)
+	}
+
+	env["NUPI_VAD_HOP_MS"] = "8"
+	result, err = loader.Load()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.Config.HopMs != 8 {
+		t.Errorf("NUPI_VAD_HOP_MS=8 should override JSON, got %d", result.Config.HopMs)
+	}
+
+	for _, bad := range []string{"10", "64", "-16"} {
+		env["NUPI_VAD_HOP_MS"] = bad
+		if _, err := loader.Load(); err == nil || !strings.Contains(err.Error(), "hop_ms") {
+			t.Errorf("NUPI_VAD_HOP_MS=%s: err = %v, want hop_ms error", bad, err)
+		}
+	}
+}
+
+func TestLoaderMaxSpeechDuration(t *testing.T) {
+	env := map[string]string{
+		"NUPI_VAD_ENGINE":     "stub",
+		"NUPI_ADAPTER_CONFIG": `{"max_speech_duration_ms":30000,"candidate":{"max_speech_duration_ms":15000}}`,
+	}
+	loader := config.Loader{
+		Lookup: func(key string) (string, bool) {
+			v, ok := env[key]
+			return v, ok
+		},
+	}
+	result, err := loader.Load()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.Config.MaxSpeechDurationMs != 30000 {
+		t.Errorf("MaxSpeechDurationMs = %d, want 30000", result.Config.MaxSpeechDurationMs)
+	}
+	if got := result.Config.CandidateConfig().MaxSpeechDurationMs; got != 15000 {
+		t.Errorf("candidate MaxSpeechDurationMs = %d, want 15000", got)
+	}
+
+	env["NUPI_VAD_MAX_SPEECH_DURATION_MS"] = "0"
+	result, err = loader.Load()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.Config.MaxSpeechDurationMs != 0 {
+		t.Errorf("NUPI_VAD_MAX_SPEECH_DURATION_MS=0 should override JSON, got %d", result.Config.MaxSpeechDurationMs)
+	}
+
+	for _, bad := range []string{"-1", "3600001", "100"} { // 100 < min_speech_duration_ms
+		env["NUPI_VAD_MAX_SPEECH_DURATION_MS"] = bad
+		if _, err := loader.Load(); err == nil || !strings.Contains(err.Error(), "max_speech_duration_ms") {
+			t.Errorf("NUPI_VAD_MAX_SPEECH_DURATION_MS=%s: err = %v, want max_speech_duration_ms error", bad, err)
+		}
+	}
+}
+
+func TestLoaderPreroll(t *testing.T) {
+	env := map[string]string{
+		"NUPI_VAD_ENGINE":     "stub",
+		"NUPI_VAD_PREROLL_MS": "300",
+	}
+	loader := config.Loader{
+		Lookup: func(key string) (string, bool) {
+			v, ok := env[key]
+			return v, ok
+		},
+	}
+	result, err := loader.Load()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.Config.PrerollMs != 300 {
+		t.Errorf("PrerollMs = %d, want 300", result.Config.PrerollMs)
+	}
+
+	for _, bad := range []string{"-1", "60001"} {
+		env["NUPI_VAD_PREROLL_MS"] = bad
+		if _, err := loader.Load(); err == nil || !strings.Contains(err.Error(), "preroll_ms") {
+			t.Errorf("NUPI_VAD_PREROLL_MS=%s: err = %v, want preroll_ms error", bad, err)
+		}
+	}
+}
+
+func TestLoaderStrictProto(t *testing.T) {
+	env := map[string]string{
+		"NUPI_VAD_ENGINE":     "stub",
+		"NUPI_ADAPTER_CONFIG": `{"strict_proto":true}`,
+	}
+	loader := config.Loader{
+		Lookup: func(key string) (string, bool) {
+			v, ok := env[key]
+			return v, ok
+		},
+	}
+	result, err := loader.Load()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !result.Config.StrictProto {
+		t.Error("StrictProto from JSON = false, want true")
+	}
+
+	env["NUPI_VAD_STRICT_PROTO"] = "0"
+	result, err = loader.Load()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.Config.StrictProto {
+		t.Error("NUPI_VAD_STRICT_PROTO=0 should override JSON")
+	}
+}
+
+func TestLoaderRecentStreams(t *testing.T) {
+	env := map[string]string{"NUPI_VAD_ENGINE": "stub"}
+	loader := config.Loader{
+		Lookup: func(key string) (string, bool) {
+			v, ok := env[key]
+			return v, ok
+		},
+	}
+	result, err := loader.Load()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.Config.RecentStreams != config.DefaultRecentStreams {
+		t.Errorf("default RecentStreams = %d, want %d", result.Config.RecentStreams, config.DefaultRecentStreams)
+	}
+
+	env["NUPI_VAD_RECENT_STREAMS"] = "0"
+	result, err = loader.Load()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.Config.RecentStreams != 0 {
+		t.Errorf("NUPI_VAD_RECENT_STREAMS=0: RecentStreams = %d, want 0", result.Config.RecentStreams)
+	}
+
+	env["NUPI_VAD_RECENT_STREAMS"] = "-1"
+	if _, err := loader.Load(); err == nil || !strings.Contains(err.Error(), "recent_streams") {
+		t.Errorf("NUPI_VAD_RECENT_STREAMS=-1: err = %v, want recent_streams error", err)
+	}
+}
+
+func TestLoaderNegThreshold(t *testing.T) {
+	env := map[string]string{
+		"NUPI_VAD_ENGINE":    "stub",
+		"NUPI_VAD_THRESHOLD": "0.6",
+	}
+	loader := config.Loader{
+		Lookup: func(key string) (string, bool) {
+			v, ok := env[key]
+			return v, ok
+		},
+	}
+	result, err := loader.Load()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := result.Config.EffectiveNegThreshold(); math.Abs(got-0.45) > 1e-9 {
+		t.Errorf("default EffectiveNegThreshold = %v, want 0.45", got)
+	}
+
+	env["NUPI_VAD_NEG_THRESHOLD"] = "0.3"
+	result, err = loader.Load()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := result.Config.EffectiveNegThreshold(); got != 0.3 {
+		t.Errorf("EffectiveNegThreshold = %v, want 0.3", got)
+	}
+
+	for _, bad := range []string{"-0.1", "0.7", "NaN"} {
+		env["NUPI_VAD_NEG_THRESHOLD"] = bad
+		if _, err := loader.Load(); err == nil || !strings.Contains(err.Error(), "neg_threshold") {
+			t.Errorf("NUPI_VAD_NEG_THRESHOLD=%s: err = %v, want neg_threshold error", bad, err)
+		}
+	}
+}
+
+func TestLoaderExpectedStreams(t *testing.T) {
+	env := map[string]string{
+		"NUPI_VAD_ENGINE":           "stub",
+		"NUPI_VAD_EXPECTED_STREAMS": "64",
+	}
+	loader := config.Loader{
+		Lookup: func(key string) (string, bool) {
+			v, ok := env[key]
+			return v, ok
+		},
+	}
+	result, err := loader.Load()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.Config.ExpectedStreams != 64 {
+		t.Errorf("ExpectedStreams = %d, want 64", result.Config.ExpectedStreams)
+	}
+
+	for _, bad := range []string{"-1", "4097"} {
+		env["NUPI_VAD_EXPECTED_STREAMS"] = bad
+		if _, err := loader.Load(); err == nil || !strings.Contains(err.Error(), "expected_streams") {
+			t.Errorf("NUPI_VAD_EXPECTED_STREAMS=%s: err = %v, want expected_streams error", bad, err)
+		}
+	}
+}
+
+func TestLoaderBillingOutput(t *testing.T) {
+	tests := []struct {
+		value     string
+		fd        int
+		file      string
+		stateless bool // accepted in stateless mode
+		wantErr   bool
+	}{
+		{value: "stdout", stateless: true},
+		{value: "stderr", stateless: true},
+		{value: "fd:3", fd: 3, stateless: true},
+		{value: "/var/log/vad-billing.ndjson", file: "/var/log/vad-billing.ndjson"},
+		{value: "fd:three", wantErr: true},
+		{value: "fd:-1", wantErr: true},
+	}
+	for _, tt := range tests {
+		env := map[string]string{
+			"NUPI_VAD_ENGINE":         "stub",
+			"NUPI_VAD_BILLING_OUTPUT": tt.value,
+		}
+		loader := config.Loader{
+			Lookup: func(key string) (string, bool) {
+				v, ok := env[key]
+				return v, ok
+			},
+		}
+		result, err := loader.Load()
+		if tt.wantErr {
+			if err == nil || !strings.Contains(err.Error(), "billing_output") {
+				t.Errorf("%s: err = %v, want billing_output error", tt.value, err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Fatalf("%s: %v", tt.value, err)
+		}
+		if fd, ok := result.Config.BillingFD(); ok != (tt.fd != 0) || fd != tt.fd {
+			t.Errorf("%s: BillingFD = %d, %v", tt.value, fd, ok)
+		}
+		if file, ok := result.Config.BillingFile(); ok != (tt.file != "") || file != tt.file {
+			t.Errorf("%s: BillingFile = %q, %v", tt.value, file, ok)
+		}
+
+		env["NUPI_VAD_STATELESS"] = "true"
+		if _, err := loader.Load(); (err == nil) != tt.stateless {
+			t.Errorf("%s in stateless mode: err = %v", tt.value, err)
+		}
+	}
+}
+
+func TestLoaderSegmentSink(t *testing.T) {
+	tests := []struct {
+		value      string
+		kind, path string
+		stateless  bool // accepted in stateless mode
+		wantErr    bool
+	}{
+		{value: "dir:/var/lib/vad/segments", kind: config.SegmentSinkDir, path: "/var/lib/vad/segments"},
+		{value: " unix:/run/vad/segments.sock ", kind: config.SegmentSinkUnix, path: "/run/vad/segments.sock", stateless: true},
+		{value: "grpc:localhost:9000", wantErr: true},
+		{value: "dir:", wantErr: true},
+		{value: "/var/lib/vad/segments", wantErr: true},
+	}
+	for _, tt := range tests {
+		env := map[string]string{
+			"NUPI_VAD_ENGINE":       "stub",
+			"NUPI_VAD_SEGMENT_SINK": tt.value,
+		}
+		loader := config.Loader{
+			Lookup: func(key string) (string, bool) {
+				v, ok := env[key]
+				return v, ok
+			},
+		}
+		result, err := loader.Load()
+		if tt.wantErr {
+			if err == nil || !strings.Contains(err.Error(), "segment_sink") {
+				t.Errorf("%s: err = %v, want segment_sink error", tt.value, err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Fatalf("%s: %v", tt.value, err)
+		}
+		if kind, path := result.Config.SegmentSinkTarget(); kind != tt.kind || path != tt.path {
+			t.Errorf("%s: SegmentSinkTarget = %q, %q", tt.value, kind, path)
+		}
+
+		env["NUPI_VAD_STATELESS"] = "true"
+		if _, err := loader.Load(); (err == nil) != tt.stateless {
+			t.Errorf("%s in stateless mode: err = %v", tt.value, err)
+		}
+	}
+}
+
+func TestLoaderSmoothing(t *testing.T) {
+	env := map[string]string{
+		"NUPI_VAD_ENGINE":           "stub",
+		"NUPI_VAD_SMOOTHING_FRAMES": "5",
+		"NUPI_VAD_SMOOTHING_METHOD": "median",
+	}
+	loader := config.Loader{
+		Lookup: func(key string) (string, bool) {
+			v, ok := env[key]
+			return v, ok
+		},
+	}
+	result, err := loader.Load()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.Config.SmoothingFrames != 5 || result.Config.SmoothingMethod != config.SmoothingMedian {
+		t.Errorf("smoothing = %d %q, want 5 median", result.Config.SmoothingFrames, result.Config.SmoothingMethod)
+	}
+
+	env["NUPI_VAD_SMOOTHING_METHOD"] = "ema"
+	if _, err := loader.Load(); err == nil || !strings.Contains(err.Error(), "smoothing_method") {
+		t.Errorf("smoothing_method=ema: err = %v, want smoothing_method error", err)
+	}
+	env["NUPI_VAD_SMOOTHING_METHOD"] = ""
+	for _, bad := range []string{"-1", "51"} {
+		env["NUPI_VAD_SMOOTHING_FRAMES"] = bad
+		if _, err := loader.Load(); err == nil || !strings.Contains(err.Error(), "smoothing_frames") {
+			t.Errorf("NUPI_VAD_SMOOTHING_FRAMES=%s: err = %v, want smoothing_frames error", bad, err)
+		}
+	}
+}
+
+func TestLoaderAdaptiveSilence(t *testing.T) {
+	env := map[string]string{
+		"NUPI_VAD_ENGINE":     "stub",
+		"NUPI_ADAPTER_CONFIG": `{"adaptive_silence": {"min_ms": 200, "max_ms": 1500, "growth": 0.2}}`,
+	}
+	loader := config.Loader{
+		Lookup: func(key string) (string, bool) {
+			v, ok := env[key]
+			return v, ok
+		},
+	}
+	result, err := loader.Load()
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := config.AdaptiveSilence{MinMs: 200, MaxMs: 1500, Growth: 0.2}
+	if result.Config.AdaptiveSilence != want || !result.Config.AdaptiveSilence.Enabled() {
+		t.Errorf("AdaptiveSilence = %+v, want %+v", result.Config.AdaptiveSilence, want)
+	}
+
+	env["NUPI_ADAPTER_CONFIG"] = `{"adaptive_silence": {"min_ms": 200, "max_ms": 1500, "growth": 11}}`
+	if _, err := loader.Load(); err == nil || !strings.Contains(err.Error(), "adaptive_silence") {
+		t.Errorf("growth=11: err = %v, want adaptive_silence error", err)
+	}
+}
+
+func TestLoaderEnsemble(t *testing.T) {
+	env := map[string]string{
+		"NUPI_VAD_ENGINE":     "stub",
+		"NUPI_ADAPTER_CONFIG": `{"ensemble": {"engine": "Stub", "weight": 0.7}}`,
+	}
+	loader := config.Loader{
+		Lookup: func(key string) (string, bool) {
+			v, ok := env[key]
+			return v, ok
+		},
+	}
+	result, err := loader.Load()
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := config.Ensemble{Engine: config.EngineStub, Mode: config.EnsembleAnd, Weight: 0.7}
+	if result.Config.Ensemble != want || !result.Config.Ensemble.Enabled() {
+		t.Errorf("Ensemble = %+v, want %+v", result.Config.Ensemble, want)
+	}
+
+	env["NUPI_VAD_ENSEMBLE_MODE"] = "weighted"
+	env["NUPI_VAD_ENSEMBLE_WEIGHT"] = "0.25"
+	result, err = loader.Load()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if e := result.Config.Ensemble; e.Mode != config.EnsembleWeighted || e.Weight != 0.25 {
+		t.Errorf("env override: Ensemble = %+v", e)
+	}
+
+	for key, value := range map[string]string{
+		"NUPI_VAD_ENSEMBLE_ENGINE": "webrtc",
+		"NUPI_VAD_ENSEMBLE_MODE":   "xor",
+		"NUPI_VAD_ENSEMBLE_WEIGHT": "1.5",
+	} {
+		saved, had := env[key]
+		env[key] = value
+		if _, err := loader.Load(); err == nil || !strings.Contains(err.Error(), "ensemble") {
+			t.Errorf("%s=%s: err = %v, want ensemble error", key, value, err)
+		}
+		if had {
+			env[key] = saved
+		} else {
+			delete(env, key)
+		}
+	}
+}
+
+func TestLoaderMaxChunkBytes(t *testing.T) {
+	env := map[string]string{"NUPI_VAD_ENGINE": "stub"}
+	loader := config.Loader{
+		Lookup: func(key string) (string, bool) {
+			v, ok := env[key]
+			return v, ok
+		},
+	}
+	result, err := loader.Load()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := result.Config.EffectiveMaxChunkBytes(); got != config.DefaultMaxChunkBytes {
+		t.Errorf("default EffectiveMaxChunkBytes = %d, want %d", got, config.DefaultMaxChunkBytes)
+	}
+
+	env["NUPI_VAD_MAX_CHUNK_BYTES"] = "65536"
+	result, err = loader.Load()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := result.Config.EffectiveMaxChunkBytes(); got != 65536 {
+		t.Errorf("EffectiveMaxChunkBytes = %d, want 65536", got)
+	}
+
+	for _, v := range []string{"512", "65537", "33554432"} {
+		env["NUPI_VAD_MAX_CHUNK_BYTES"] = v
+		if _, err := loader.Load(); err == nil || !strings.Contains(err.Error(), "max_chunk_bytes") {
+			t.Errorf("max_chunk_bytes=%s: err = %v, want max_chunk_bytes error", v, err)
+		}
+	}
+}
+
+func TestLoaderMaxBufferedBytes(t *testing.T) {
+	env := map[string]string{"NUPI_VAD_ENGINE": "stub"}
+	loader := config.Loader{
+		Lookup: func(key string) (string, bool) {
+			v, ok := env[key]
+			return v, ok
+		},
+	}
+	result, err := loader.Load()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := result.Config.EffectiveMaxBufferedBytes(); got != config.DefaultMaxBufferedBytes {
+		t.Errorf("default EffectiveMaxBufferedBytes = %d, want %d", got, config.DefaultMaxBufferedBytes)
+	}
+
+	env["NUPI_VAD_MAX_BUFFERED_BYTES"] = "65536"
+	result, err = loader.Load()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := result.Config.EffectiveMaxBufferedBytes(); got != 65536 {
+		t.Errorf("EffectiveMaxBufferedBytes = %d, want 65536", got)
+	}
+
+	for _, v := range []string{"1024", "134217728"} {
+		env["NUPI_VAD_MAX_BUFFERED_BYTES"] = v
+		if _, err := loader.Load(); err == nil || !strings.Contains(err.Error(), "max_buffered_bytes") {
+			t.Errorf("max_buffered_bytes=%s: err = %v, want max_buffered_bytes error", v, err)
+		}
+	}
+}
+
+func TestLoaderStrictNumerics(t *testing.T) {
+	tests := []struct {
+		key, value string
+		want       []string // substrings of the error
+	}{
+		{"NUPI_VAD_THRESHOLD", "0,5", []string{"NUPI_VAD_THRESHOLD", "decimal comma", `"0.5"`}},
+		{"NUPI_VAD_NEG_THRESHOLD", "0,35", []string{"NUPI_VAD_NEG_THRESHOLD", "decimal comma"}},
+		{"NUPI_VAD_THRESHOLD", "NaN", []string{"NUPI_VAD_THRESHOLD", "not a decimal number"}},
+		{"NUPI_VAD_THRESHOLD", "0x1p-1", []string{"NUPI_VAD_THRESHOLD", "not a decimal number"}},
+		{"NUPI_VAD_THRESHOLD", "1.5", []string{"NUPI_VAD_THRESHOLD", "(threshold): must be in [0, 1]"}},
+		{"NUPI_VAD_MIN_SILENCE_DURATION_MS", "1,000", []string{"NUPI_VAD_MIN_SILENCE_DURATION_MS", "digit grouping"}},
+		{"NUPI_VAD_MIN_SPEECH_DURATION_MS", "250.0", []string{"NUPI_VAD_MIN_SPEECH_DURATION_MS", "whole number"}},
+		{"NUPI_VAD_MIN_SPEECH_DURATION_MS", "0", []string{"NUPI_VAD_MIN_SPEECH_DURATION_MS", "(min_speech_duration_ms): must be in [1, 60000]"}},
+		{"NUPI_VAD_PREROLL_MS", "99999999999999999999", []string{"NUPI_VAD_PREROLL_MS", "out of range"}},
+		{"NUPI_VAD_SHADOW_PERCENT", "101", []string{"NUPI_VAD_SHADOW_PERCENT", "shadow_percent"}},
+	}
+	for _, tt := range tests {
+		env := map[string]string{"NUPI_VAD_ENGINE": "stub", tt.key: tt.value}
+		loader := config.Loader{
+			Lookup: func(key string) (string, bool) {
+				v, ok := env[key]
+				return v, ok
+			},
+		}
+		_, err := loader.Load()
+		if err == nil {
+			t.Errorf("%s=%s: expected error", tt.key, tt.value)
+			continue
+		}
+		for _, want := range tt.want {
+			if !strings.Contains(err.Error(), want) {
+				t.Errorf("%s=%s: error %q should contain %q", tt.key, tt.value, err, want)
+			}
+		}
+	}
+
+	env := map[string]string{"NUPI_VAD_ENGINE": "stub", "NUPI_VAD_THRESHOLD": " 5e-1 ", "NUPI_VAD_PREROLL_MS": "+200"}
+	loader := config.Loader{
+		Lookup: func(key string) (string, bool) {
+			v, ok := env[key]
+			return v, ok
+		},
+	}
+	result, err := loader.Load()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.Config.Threshold != 0.5 || result.Config.PrerollMs != 200 {
+		t.Errorf("threshold, preroll = %v, %d, want 0.5, 200", result.Config.Threshold, result.Config.PrerollMs)
+	}
+}
+
+func TestLoaderMetricsAddr(t *testing.T) {
+	env := map[string]string{
+		"NUPI_VAD_ENGINE":     "stub",
+		"NUPI_ADAPTER_CONFIG": `{"metrics_addr": "127.0.0.1:9100"}`,
+	}
+	loader := config.Loader{
+		Lookup: func(key string) (string, bool) {
+			v, ok := env[key]
+			return v, ok
+		},
+	}
+	result, err := loader.Load()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.Config.MetricsAddr != "127.0.0.1:9100" {
+		t.Errorf("MetricsAddr from JSON = %q, want 127.0.0.1:9100", result.Config.MetricsAddr)
+	}
+
+	env["NUPI_VAD_METRICS_ADDR"] = ":9200"
+	result, err = loader.Load()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.Config.MetricsAddr != ":9200" {
+		t.Errorf("NUPI_VAD_METRICS_ADDR should override JSON, got %q", result.Config.MetricsAddr)
+	}
+
+	env["NUPI_VAD_METRICS_ADDR"] = "9200"
+	if _, err := loader.Load(); err == nil || !strings.Contains(err.Error(), "metrics_addr") {
+		t.Errorf("NUPI_VAD_METRICS_ADDR=9200: err = %v, want metrics_addr error", err)
+	}
+}
+
+func TestLoaderMinGap(t *testing.T) {
+	env := map[string]string{
+		"NUPI_VAD_ENGINE":     "stub",
+		"NUPI_ADAPTER_CONFIG": `{"min_gap_ms": 150, "candidate": {"min_gap_ms": 300}}`,
+	}
+	loader := config.Loader{
+		Lookup: func(key string) (string, bool) {
+			v, ok := env[key]
+			return v, ok
+		},
+	}
+	result, err := loader.Load()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.Config.MinGapMs != 150 {
+		t.Errorf("MinGapMs from JSON = %d, want 150", result.Config.MinGapMs)
+	}
+	if got := result.Config.CandidateConfig().MinGapMs; got != 300 {
+		t.Errorf("candidate MinGapMs = %d, want 300", got)
+	}
+
+	env["NUPI_VAD_MIN_GAP_MS"] = "0"
+	result, err = loader.Load()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.Config.MinGapMs != 0 {
+		t.Errorf("NUPI_VAD_MIN_GAP_MS=0 should override JSON, got %d", result.Config.MinGapMs)
+	}
+
+	env["NUPI_VAD_MIN_GAP_MS"] = "-1"
+	if _, err := loader.Load(); err == nil || !strings.Contains(err.Error(), "min_gap_ms") {
+		t.Errorf("NUPI_VAD_MIN_GAP_MS=-1: err = %v, want min_gap_ms error", err)
+	}
+}
+
+func TestLoaderFeedback(t *testing.T) {
+	env := map[string]string{
+		"NUPI_VAD_ENGINE":     "stub",
+		"NUPI_ADAPTER_CONFIG": `{"feedback": {"step": 0.01, "state_file": " /var/lib/vad/tuning.json "}}`,
+	}
+	loader := config.Loader{
+		Lookup: func(key string) (string, bool) {
+			v, ok := env[key]
+			return v, ok
+		},
+	}
+	result, err := loader.Load()
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := config.FeedbackTuning{Step: 0.01, StateFile: "/var/lib/vad/tuning.json"}
+	if fb := result.Config.Feedback; fb != want || fb.EffectiveMaxOffset() != config.DefaultFeedbackMaxOffset {
+		t.Errorf("Feedback = %+v, want %+v", fb, want)
+	}
+
+	env["NUPI_VAD_FEEDBACK_MAX_OFFSET"] = "0.2"
+	result, err = loader.Load()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := result.Config.Feedback.EffectiveMaxOffset(); got != 0.2 {
+		t.Errorf("env override: max offset = %f, want 0.2", got)
+	}
+
+	env["NUPI_VAD_STATELESS"] = "1"
+	if _, err := loader.Load(); err == nil || !strings.Contains(err.Error(), "feedback.state_file") {
+		t.Errorf("stateless with state file: err = %v, want feedback.state_file error", err)
+	}
+	delete(env, "NUPI_VAD_STATELESS")
+
+	for key, value := range map[string]string{
+		"NUPI_VAD_FEEDBACK_STEP":       "0.5",
+		"NUPI_VAD_FEEDBACK_MAX_OFFSET": "1",
+	} {
+		saved, had := env[key]
+		env[key] = value
+		if _, err := loader.Load(); err == nil || !strings.Contains(err.Error(), "feedback") {
+			t.Errorf("%s=%s: err = %v, want feedback error", key, value, err)
+		}
+		if had {
+			env[key] = saved
+		} else {
+			delete(env, key)
+		}
+	}
+
+	env["NUPI_ADAPTER_CONFIG"] = `{"feedback": {"state_file": "tuning.json"}}`
+	if _, err := loader.Load(); err == nil || !strings.Contains(err.Error(), "feedback.step") {
+		t.Errorf("state file without step: err = %v, want feedback.step error", err)
+	}
+}
+
+func TestLoaderListenAddrs(t *testing.T) {
+	env := map[string]string{
+		"NUPI_VAD_ENGINE":          "stub",
+		"NUPI_ADAPTER_LISTEN_ADDR": "127.0.0.1:50051, [::1]:50051",
+	}
+	loader := config.Loader{
+		Lookup: func(key string) (string, bool) {
+			v, ok := env[key]
+			return v, ok
+		},
+	}
+	result, err := loader.Load()
+	if err != nil {
+		t.Fatal(err)
+	}
+	cfg := result.Config
+	if got := cfg.ListenAddrs(); len(got) != 2 || got[0] != "127.0.0.1:50051" || got[1] != "[::1]:50051" {
+		t.Errorf("ListenAddrs = %q", got)
+	}
+	if got := cfg.EffectiveListenNetwork(); got != config.ListenTCP {
+		t.Errorf("EffectiveListenNetwork = %q, want %q", got, config.ListenTCP)
+	}
+
+	env["NUPI_ADAPTER_LISTEN_ADDR"] = "[::]:0"
+	env["NUPI_VAD_LISTEN_NETWORK"] = "TCP6"
+	result, err = loader.Load()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := result.Config.EffectiveListenNetwork(); got != config.ListenTCP6 {
+		t.Errorf("EffectiveListenNetwork = %q, want %q", got, config.ListenTCP6)
+	}
+
+	for _, tt := range []struct {
+		addr, network, want string
+	}{
+		{"::1:50051", "", "bracketed"},
+		{"[::1]", "", "host:port"},
+		{"localhost:http", "", "port"},
+		{"localhost:0,localhost:0", "", "twice"},
+		{"127.0.0.1:0", "tcp6", "IPv4"},
+		{"[::1]:0", "tcp4", "IPv6"},
+		{"localhost:0", "udp", "listen_network"},
+	} {
+		env["NUPI_ADAPTER_LISTEN_ADDR"] = tt.addr
+		env["NUPI_VAD_LISTEN_NETWORK"] = tt.network
+		if _, err := loader.Load(); err == nil || !strings.Contains(err.Error(), tt.want) {
+			t.Errorf("%q on %q: err = %v, want %q error", tt.addr, tt.network, err, tt.want)
+		}
+	}
+}
+
+func TestLoaderProxyProtocol(t *testing.T) {
+	env := map[string]string{
+		"NUPI_VAD_ENGINE":     "stub",
+		"NUPI_ADAPTER_CONFIG": `{"proxy_protocol":true}`,
+	}
+	loader := config.Loader{
+		Lookup: func(key string) (string, bool) {
+			v, ok := env[key]
+			return v, ok
+		},
+	}
+	result, err := loader.Load()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !result.Config.ProxyProtocol {
+		t.Error("ProxyProtocol from JSON = false, want true")
+	}
+
+	env["NUPI_VAD_PROXY_PROTOCOL"] = "0"
+	result, err = loader.Load()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.Config.ProxyProtocol {
+		t.Error("NUPI_VAD_PROXY_PROTOCOL=0 should override JSON")
+	}
+
+	env["NUPI_VAD_PROXY_PROTOCOL"] = "maybe"
+	if _, err := loader.Load(); err == nil || !strings.Contains(err.Error(), "NUPI_VAD_PROXY_PROTOCOL") {
+		t.Errorf("invalid bool: err = %v, want NUPI_VAD_PROXY_PROTOCOL error", err)
+	}
+}
+
+func TestLoaderStuckStreamTimeout(t *testing.T) {
+	env := map[string]string{
+		"NUPI_VAD_ENGINE":     "stub",
+		"NUPI_ADAPTER_CONFIG": `{"stuck_stream_timeout_s": 120}`,
+	}
+	loader := config.Loader{
+		Lookup: func(key string) (string, bool) {
+			v, ok := env[key]
+			return v, ok
+		},
+	}
+	result, err := loader.Load()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.Config.StuckStreamTimeoutSec != 120 {
+		t.Errorf("StuckStreamTimeoutSec = %d, want 120", result.Config.StuckStreamTimeoutSec)
+	}
+
+	env["NUPI_VAD_STUCK_STREAM_TIMEOUT_S"] = "0"
+	result, err = loader.Load()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.Config.StuckStreamTimeoutSec != 0 {
+		t.Errorf("env override: StuckStreamTimeoutSec = %d, want 0", result.Config.StuckStreamTimeoutSec)
+	}
+
+	for _, bad := range []string{"-1", "86401", "1.5"} {
+		env["NUPI_VAD_STUCK_STREAM_TIMEOUT_S"] = bad
+		if _, err := loader.Load(); err == nil || !strings.Contains(err.Error(), "stuck_stream_timeout_s") {
+			t.Errorf("NUPI_VAD_STUCK_STREAM_TIMEOUT_S=%s: err = %v, want stuck_stream_timeout_s error", bad, err)
+		}
+	}
+	delete(env, "NUPI_VAD_STUCK_STREAM_TIMEOUT_S")
+	env["NUPI_ADAPTER_CONFIG"] = `{"stuck_stream_timeout_s": -5}`
+	if _, err := loader.Load(); err == nil || !strings.Contains(err.Error(), "stuck_stream_timeout_s") {
+		t.Errorf("negative JSON value: err = %v, want stuck_stream_timeout_s error", err)
+	}
+}
+
+func TestLoaderModelPath(t *testing.T) {
+	digest := strings.Repeat("ab", 32)
+	env := map[string]string{
+		"NUPI_VAD_ENGINE":     "stub",
+		"NUPI_ADAPTER_CONFIG": `{"model_path":"/opt/models/silero.onnx","model_sha256":"` + strings.ToUpper(digest) + `"}`,
+	}
+	loader := config.Loader{
+		Lookup: func(key string) (string, bool) {
+			v, ok := env[key]
+			return v, ok
+		},
+	}
+	result, err := loader.Load()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.Config.ModelPath != "/opt/models/silero.onnx" || result.Config.ModelSHA256 != digest {
+		t.Errorf("model = %q, %q, want JSON path and lower-case digest", result.Config.ModelPath, result.Config.ModelSHA256)
+	}
+
+	env["NUPI_VAD_MODEL_PATH"] = "/srv/silero.onnx"
+	result, err = loader.Load()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.Config.ModelPath != "/srv/silero.onnx" {
+		t.Errorf("ModelPath = %q, want the env override", result.Config.ModelPath)
+	}
+
+	env["NUPI_VAD_MODEL_SHA256"] = "abc"
+	if _, err := loader.Load(); err == nil || !strings.Contains(err.Error(), "model_sha256") {
+		t.Errorf("short digest: err = %v, want model_sha256 error", err)
+	}
+
+	env = map[string]string{"NUPI_VAD_ENGINE": "stub", "NUPI_VAD_MODEL_SHA256": digest}
+	if _, err := loader.Load(); err == nil || !strings.Contains(err.Error(), "model_path") {
+		t.Errorf("digest without path: err = %v, want model_path error", err)
+	}
+}
+
+func TestLoaderIntegrity(t *testing.T) {
+	digest := strings.Repeat("cd", 32)
+	env := map[string]string{
+		"NUPI_VAD_ENGINE":     "stub",
+		"NUPI_ADAPTER_CONFIG": `{"integrity": {"ort_lib_sha256": "` + strings.ToUpper(digest) + `", "allow_mismatch": true}}`,
+	}
+	loader := config.Loader{
+		Lookup: func(key string) (string, bool) {
+			v, ok := env[key]
+			return v, ok
+		},
+	}
+	result, err := loader.Load()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := result.Config.Integrity; got.ORTLibSHA256 != digest || got.EmbeddedModelSHA256 != "" || !got.AllowMismatch {
+		t.Errorf("integrity = %+v, want the JSON digest in lower case and allow_mismatch", got)
+	}
+
+	env["NUPI_VAD_INTEGRITY_EMBEDDED_MODEL_SHA256"] = digest
+	env["NUPI_VAD_INTEGRITY_ALLOW_MISMATCH"] = "0"
+	result, err = loader.Load()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := result.Config.Integrity; got.EmbeddedModelSHA256 != digest || got.AllowMismatch {
+		t.Errorf("integrity = %+v, want the env overrides", got)
+	}
+
+	env["NUPI_VAD_INTEGRITY_ORT_LIB_SHA256"] = "not-a-digest"
+	if _, err := loader.Load(); err == nil || !strings.Contains(err.Error(), "integrity.ort_lib_sha256") {
+		t.Errorf("bad digest: err = %v, want integrity.ort_lib_sha256 error", err)
+	}
+}
+
+func TestLoaderModelB(t *testing.T) {
+	digest := strings.Repeat("ab", 32)
+	env := map[string]string{
+		"NUPI_VAD_ENGINE":        "stub",
+		"NUPI_VAD_MODEL_VERSION": "v5",
+		"NUPI_ADAPTER_CONFIG":    `{"model_b": {"path": "/models/next.onnx", "sha256": "` + strings.ToUpper(digest) + `"}, "model": "B"}`,
+	}
+	loader := config.Loader{
+		Lookup: func(key string) (string, bool) {
+			v, ok := env[key]
+			return v, ok
+		},
+	}
+	result, err := loader.Load()
+	if err != nil {
+		t.Fatal(err)
+	}
+	cfg := result.Config
+	if got := cfg.ModelB; got.Path != "/models/next.onnx" || got.SHA256 != digest || got.Version != "v5" || got.Precision != cfg.ModelPrecision {
+		t.Errorf("model_b = %+v, want the file with the model's version and precision", got)
+	}
+	if cfg.Model != config.ModelB {
+		t.Errorf("model = %q, want %q", cfg.Model, config.ModelB)
+	}
+
+	env["NUPI_ADAPTER_CONFIG"] = `{}`
+	env["NUPI_VAD_MODEL_B_VERSION"] = "v4"
+	result, err = loader.Load()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := result.Config.ModelB; !got.Enabled() || got.Path != "" || got.Version != "v4" {
+		t.Errorf("model_b = %+v, want the embedded v4 model", got)
+	}
+
+	env["NUPI_VAD_MODEL_B_VERSION"] = "v5"
+	if _, err := loader.Load(); err == nil || !strings.Contains(err.Error(), "model_b") {
+		t.Errorf("model_b same as model a: err = %v, want a model_b error", err)
+	}
+
+	delete(env, "NUPI_VAD_MODEL_B_VERSION")
+	env["NUPI_VAD_MODEL"] = "b"
+	if _, err := loader.Load(); err == nil || !strings.Contains(err.Error(), "requires model_b") {
+		t.Errorf("model b without model_b: err = %v, want a requires model_b error", err)
+	}
+}
+
+func TestLoaderRegisteredEngine(t *testing.T) {
+	env := map[string]string{"NUPI_VAD_ENGINE": "loader_test_dsp", "NUPI_VAD_SHADOW_ENGINE": "loader_test_dsp"}
+	loader := config.Loader{
+		Lookup: func(key string) (string, bool) {
+			v, ok := env[key]
+			return v, ok
+		},
+	}
+	if _, err := loader.Load(); err == nil {
+		t.Fatal("unregistered engine accepted")
+	}
+	engine.Register("loader_test_dsp", func(int) (engine.Engine, error) { return engine.NewStubEngine(), nil })
+	result, err := loader.Load()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.Config.Engine != "loader_test_dsp" || result.Config.ShadowEngine != "loader_test_dsp" {
+		t.Errorf("engines = %q, %q, want the registered engine", result.Config.Engine, result.Config.ShadowEngine)
+	}
+}
+
+func TestLoaderEnergyEngine(t *testing.T) {
+	env := map[string]string{
+		"NUPI_VAD_ENGINE":             "energy",
+		"NUPI_VAD_SHADOW_ENGINE":      "energy",
+		"NUPI_VAD_ENSEMBLE_ENGINE":    "energy",
+		"NUPI_VAD_CPU_BUDGET_ACTION":  "downgrade",
+		"NUPI_VAD_CPU_BUDGET_ENGINE":  "energy",
+		"NUPI_VAD_CPU_BUDGET_PERCENT": "20",
+	}
+	loader := config.Loader{
+		Lookup: func(key string) (string, bool) {
+			v, ok := env[key]
+			return v, ok
+		},
+	}
+	result, err := loader.Load()
+	if err != nil {
+		t.Fatal(err)
+	}
+	c := result.Config
+	if c.Engine != config.EngineEnergy || c.ShadowEngine != config.EngineEnergy || c.Ensemble.Engine != config.EngineEnergy || c.CPUBudget.Engine != config.EngineEnergy {
+		t.Errorf("engines = %q, %q, %q, %q, want energy everywhere", c.Engine, c.ShadowEngine, c.Ensemble.Engine, c.CPUBudget.Engine)
+	}
+}
+
+func TestLoaderModelVersion(t *testing.T) {
+	env := map[string]string{"NUPI_VAD_ENGINE": "stub"}
+	loader := config.Loader{
+		Lookup: func(key string) (string, bool) {
+			v, ok := env[key]
+			return v, ok
+		},
+	}
+	result, err := loader.Load()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.Config.ModelVersion != config.ModelV5 {
+		t.Errorf("default ModelVersion = %q, want %q", result.Config.ModelVersion, config.ModelV5)
+	}
+
+	env["NUPI_ADAPTER_CONFIG"] = `{"model_version":"v4"}`
+	result, err = loader.Load()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.Config.ModelVersion != config.ModelV4 {
+		t.Errorf("ModelVersion = %q, want the JSON v4", result.Config.ModelVersion)
+	}
+
+	env["NUPI_VAD_MODEL_VERSION"] = " V5 "
+	result, err = loader.Load()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.Config.ModelVersion != config.ModelV5 {
+		t.Errorf("ModelVersion = %q, want the normalized env override", result.Config.ModelVersion)
+	}
+
+	env["NUPI_VAD_MODEL_VERSION"] = "v3"
+	if _, err := loader.Load(); err == nil || !strings.Contains(err.Error(), "NUPI_VAD_MODEL_VERSION") {
+		t.Errorf("v3: err = %v, want model_version error", err)
+	}
+}
+
+func TestLoaderModelPrecision(t *testing.T) {
+	env := map[string]string{"NUPI_VAD_ENGINE": "stub"}
+	loader := config.Loader{
+		Lookup: func(key string) (string, bool) {
+			v, ok := env[key]
+			return v, ok
+		},
+	}
+	result, err := loader.Load()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.Config.ModelPrecision != config.PrecisionFP32 {
+		t.Errorf("default ModelPrecision = %q, want %q", result.Config.ModelPrecision, config.PrecisionFP32)
+	}
+
+	env["NUPI_ADAPTER_CONFIG"] = `{"model_precision":"INT8"}`
+	result, err = loader.Load()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.Config.ModelPrecision != config.PrecisionInt8 {
+		t.Errorf("ModelPrecision = %q, want the normalized JSON int8", result.Config.ModelPrecision)
+	}
+
+	env["NUPI_VAD_MODEL_PRECISION"] = "fp16"
+	if _, err := loader.Load(); err == nil || !strings.Contains(err.Error(), "NUPI_VAD_MODEL_PRECISION") {
+		t.Errorf("fp16: err = %v, want model_precision error", err)
+	}
+}
+
+func TestLoaderORT(t *testing.T) {
+	env := map[string]string{"NUPI_VAD_ENGINE": "stub"}
+	loader := config.Loader{
+		Lookup: func(key string) (string, bool) {
+			v, ok := env[key]
+			return v, ok
+		},
+	}
+	result, err := loader.Load()
+	if err != nil {
+		t.Fatal(err)
+	}
+	ort := result.Config.ORT
+	if ort.IntraOpThreads != config.DefaultORTThreads || ort.InterOpThreads != config.DefaultORTThreads || ort.MemArena != nil || ort.GraphOptimization != "" {
+		t.Errorf("default ORT = %+v, want single-threaded sessions and ORT defaults otherwise", ort)
+	}
+
+	env["NUPI_ADAPTER_CONFIG"] = `{"ort":{"intra_op_threads":4,"graph_optimization":"Basic","mem_arena":false}}`
+	env["NUPI_VAD_ORT_MEM_PATTERN"] = "true"
+	result, err = loader.Load()
+	if err != nil {
+		t.Fatal(err)
+	}
+	ort = result.Config.ORT
+	if ort.IntraOpThreads != 4 || ort.InterOpThreads != config.DefaultORTThreads || ort.GraphOptimization != "basic" {
+		t.Errorf("ORT = %+v, want 4 intra-op threads and basic optimization", ort)
+	}
+	if ort.MemArena == nil || *ort.MemArena || ort.MemPattern == nil || !*ort.MemPattern {
+		t.Errorf("ORT mem_arena = %v, mem_pattern = %v, want false and true", ort.MemArena, ort.MemPattern)
+	}
+	if opts := ort.SessionOptions(); opts.IntraOpThreads != 4 || opts.MemArena != ort.MemArena {
+		t.Errorf("SessionOptions = %+v, want the configured options", opts)
+	}
+
+	env["NUPI_VAD_ORT_GRAPH_OPTIMIZATION"] = "max"
+	if _, err := loader.Load(); err == nil || !strings.Contains(err.Error(), "ort.graph_optimization") {
+		t.Errorf("unknown level: err = %v, want ort.graph_optimization error", err)
+	}
+	delete(env, "NUPI_VAD_ORT_GRAPH_OPTIMIZATION")
+	env["NUPI_VAD_ORT_INTRA_OP_THREADS"] = "1000"
+	if _, err := loader.Load(); err == nil {
+		t.Error("1000 intra-op threads accepted")
+	}
+}
+
+func TestLoaderCPUBudget(t *testing.T) {
+	env := map[string]string{
+		"NUPI_VAD_ENGINE":     "stub",
+		"NUPI_ADAPTER_CONFIG": `{"cpu_budget":{"percent":20,"action":"Downgrade","engine":"stub"}}`,
+	}
+	loader := config.Loader{
+		Lookup: func(key string) (string, bool) {
+			v, ok := env[key]
+			return v, ok
+		},
+	}
+	result, err := loader.Load()
+	if err != nil {
+		t.Fatal(err)
+	}
+	b := result.Config.CPUBudget
+	if !b.Enabled() || b.Percent != 20 || b.Action != config.CPUBudgetDowngrade || b.Engine != config.EngineStub {
+		t.Errorf("CPUBudget = %+v, want 20%% downgrade to stub", b)
+	}
+	if b.EffectiveSliceSec() != config.DefaultCPUBudgetSliceSec {
+		t.Errorf("EffectiveSliceSec = %d, want %d", b.EffectiveSliceSec(), config.DefaultCPUBudgetSliceSec)
+	}
+
+	env["NUPI_VAD_CPU_BUDGET_SLICE_S"] = "30"
+	env["NUPI_VAD_CPU_BUDGET_PERCENT"] = "50"
+	result, err = loader.Load()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if b := result.Config.CPUBudget; b.Percent != 50 || b.SliceSec != 30 {
+		t.Errorf("CPUBudget = %+v, want env overrides", b)
+	}
+
+	for _, tc := range []struct {
+		key, value, want string
+	}{
+		{"NUPI_VAD_CPU_BUDGET_PERCENT", "101", "cpu_budget.percent"},
+		{"NUPI_VAD_CPU_BUDGET_ACTION", "throttle", "cpu_budget.action"},
+		{"NUPI_VAD_CPU_BUDGET_ENGINE", "webrtc", "cpu_budget.engine"},
+	} {
+		old := env[tc.key]
+		env[tc.key] = tc.value
+		if _, err := loader.Load(); err == nil || !strings.Contains(err.Error(), tc.want) {
+			t.Errorf("%s=%s: err = %v, want %s error", tc.key, tc.value, err, tc.want)
+		}
+		env[tc.key] = old
+	}
+
+	env = map[string]string{"NUPI_VAD_ENGINE": "stub", "NUPI_VAD_CPU_BUDGET_ACTION": "terminate"}
+	if _, err := loader.Load(); err == nil || !strings.Contains(err.Error(), "cpu_budget.percent") {
+		t.Errorf("action without percent: err = %v, want cpu_budget.percent error", err)
+	}
+}
+
+func TestLoaderGRPC(t *testing.T) {
+	env := map[string]string{
+		"NUPI_VAD_ENGINE":     "stub",
+		"NUPI_ADAPTER_CONFIG": `{"grpc":{"keepalive_time_s":30,"max_connection_age_s":1800,"max_connection_age_grace_s":60}}`,
+	}
+	loader := config.Loader{
+		Lookup: func(key string) (string, bool) {
+			v, ok := env[key]
+			return v, ok
+		},
+	}
+	result, err := loader.Load()
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := config.GRPCServer{KeepaliveTimeSec: 30, MaxConnectionAgeSec: 1800, MaxConnectionAgeGraceSec: 60}
+	if result.Config.GRPC != want {
+		t.Errorf("GRPC = %+v, want %+v", result.Config.GRPC, want)
+	}
+
+	env["NUPI_VAD_GRPC_KEEPALIVE_MIN_TIME_S"] = "10"
+	env["NUPI_VAD_GRPC_KEEPALIVE_PERMIT_WITHOUT_STREAM"] = "true"
+	env["NUPI_VAD_GRPC_MAX_RECV_MSG_BYTES"] = "4194304"
+	if result, err = loader.Load(); err != nil {
+		t.Fatal(err)
+	}
+	if g := result.Config.GRPC; g.KeepaliveMinTimeSec != 10 || !g.KeepalivePermitWithoutStream || g.MaxRecvMsgBytes != 4<<20 || g.KeepaliveTimeSec != 30 {
+		t.Errorf("GRPC = %+v, want env overrides on top of the file", g)
+	}
+
+	for _, tc := range []struct {
+		key, value, want string
+	}{
+		{"NUPI_VAD_GRPC_KEEPALIVE_TIME_S", "-1", "grpc.keepalive_time_s"},
+		{"NUPI_VAD_GRPC_MAX_CONNECTION_IDLE_S", "604801", "grpc.max_connection_idle_s"},
+		{"NUPI_VAD_GRPC_MAX_RECV_MSG_BYTES", "67108865", "grpc.max_recv_msg_bytes"},
+		{"NUPI_VAD_GRPC_KEEPALIVE_PERMIT_WITHOUT_STREAM", "maybe", "NUPI_VAD_GRPC_KEEPALIVE_PERMIT_WITHOUT_STREAM"},
+		{"NUPI_VAD_GRPC_MAX_CONNECTION_AGE_S", "0", "grpc.max_connection_age_s"},
+	} {
+		old := env[tc.key]
+		env[tc.key] = tc.value
+		if _, err := loader.Load(); err == nil || !strings.Contains(err.Error(), tc.want) {
+			t.Errorf("%s=%s: err = %v, want %s error", tc.key, tc.value, err, tc.want)
+		}
+		env[tc.key] = old
+	}
+}
+
+func TestLoaderGateway(t *testing.T) {
+	env := map[string]string{
+		"NUPI_VAD_ENGINE":     "stub",
+		"NUPI_ADAPTER_CONFIG": `{"gateway_addr":"127.0.0.1:8080","gateway_origins":"https://app.example, http://localhost:3000"}`,
+	}
+	loader := config.Loader{
+		Lookup: func(key string) (string, bool) {
+			v, ok := env[key]
+			return v, ok
+		},
+	}
+	result, err := loader.Load()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.Config.GatewayAddr != "127.0.0.1:8080" {
+		t.Errorf("GatewayAddr = %q", result.Config.GatewayAddr)
+	}
+	if got := result.Config.GatewayOriginList(); len(got) != 2 || got[0] != "https://app.example" || got[1] != "http://localhost:3000" {
+		t.Errorf("GatewayOriginList() = %q", got)
+	}
+
+	env["NUPI_VAD_GATEWAY_ADDR"] = ":9090"
+	env["NUPI_VAD_GATEWAY_ORIGINS"] = "*"
+	if result, err = loader.Load(); err != nil {
+		t.Fatal(err)
+	}
+	if c := result.Config; c.GatewayAddr != ":9090" || c.GatewayOrigins != "*" {
+		t.Errorf("gateway = %q %q, want the env overrides", c.GatewayAddr, c.GatewayOrigins)
+	}
+
+	for _, tc := range []struct {
+		addr, origins, want string
+	}{
+		{"8080", "", "gateway_addr"},
+		{":8080", "app.example", "gateway_origins"},
+		{":8080", "ftp://app.example", "gateway_origins"},
+		{":8080", "https://app.example/demo", "gateway_origins"},
+		{"", "https://app.example", "gateway_origins requires gateway_addr"},
+	} {
+		env["NUPI_VAD_GATEWAY_ADDR"] = tc.addr
+		env["NUPI_VAD_GATEWAY_ORIGINS"] = tc.origins
+		env["NUPI_ADAPTER_CONFIG"] = "{}"
+		if _, err := loader.Load(); err == nil || !strings.Contains(err.Error(), tc.want) {
+			t.Errorf("addr %q origins %q: err = %v, want %s error", tc.addr, tc.origins, err, tc.want)
+		}
+	}
+}
+
+func TestLoaderFrameSkip(t *testing.T) {
+	env := map[string]string{
+		"NUPI_VAD_ENGINE":     "stub",
+		"NUPI_ADAPTER_CONFIG": `{"frame_skip":{"stride":3}}`,
+	}
+	loader := config.Loader{
+		Lookup: func(key string) (string, bool) {
+			v, ok := env[key]
+			return v, ok
+		},
+	}
+	result, err := loader.Load()
+	if err != nil {
+		t.Fatal(err)
+	}
+	fs := result.Config.FrameSkip
+	if !fs.Enabled() || fs.Stride != 3 {
+		t.Errorf("FrameSkip = %+v, want stride 3", fs)
+	}
+	if fs.EffectiveAfterMs() != config.DefaultFrameSkipAfterMs || fs.EffectiveMargin() != config.DefaultFrameSkipMargin {
+		t.Errorf("effective after_ms %d, margin %v; want the defaults", fs.EffectiveAfterMs(), fs.EffectiveMargin())
+	}
+
+	for _, tc := range []struct {
+		json, want string
+	}{
+		{`{"frame_skip":{"stride":5}}`, "frame_skip.stride"},
+		{`{"frame_skip":{"after_ms":200}}`, "frame_skip.stride"},
+		{`{"frame_skip":{"stride":2,"after_ms":-1}}`, "frame_skip.after_ms"},
+		{`{"frame_skip":{"stride":2,"margin":0.5}}`, "frame_skip.margin"},
+	} {
+		env["NUPI_ADAPTER_CONFIG"] = tc.json
+		if _, err := loader.Load(); err == nil || !strings.Contains(err.Error(), tc.want) {
+			t.Errorf("%s: err = %v, want %s error", tc.json, err, tc.want)
+		}
+	}
+}
+
+func TestLoaderInferenceBudget(t *testing.T) {
+	env := map[string]string{
+		"NUPI_VAD_ENGINE":     "stub",
+		"NUPI_ADAPTER_CONFIG": `{"inference_budget":{"frame_ms":2.5,"action":"Skip"}}`,
+	}
+	loader := config.Loader{
+		Lookup: func(key string) (string, bool) {
+			v, ok := env[key]
+			return v, ok
+		},
+	}
+	result, err := loader.Load()
+	if err != nil {
+		t.Fatal(err)
+	}
+	b := result.Config.InferenceBudget
+	if !b.Enabled() || b.FrameMs != 2.5 || b.Action != config.InferenceBudgetSkip {
+		t.Errorf("InferenceBudget = %+v, want 2.5 ms skip", b)
+	}
+	if b.EffectiveFrames() != config.DefaultInferenceBudgetFrames {
+		t.Errorf("EffectiveFrames = %d, want %d", b.EffectiveFrames(), config.DefaultInferenceBudgetFrames)
+	}
+
+	env["NUPI_VAD_INFERENCE_BUDGET_MS"] = "5"
+	env["NUPI_VAD_INFERENCE_BUDGET_FRAMES"] = "100"
+	env["NUPI_VAD_INFERENCE_BUDGET_ACTION"] = "warn"
+	result, err = loader.Load()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if b := result.Config.InferenceBudget; b.FrameMs != 5 || b.Frames != 100 || b.Action != config.InferenceBudgetWarn {
+		t.Errorf("InferenceBudget = %+v, want env overrides", b)
+	}
+
+	for _, tc := range []struct {
+		key, value, want string
+	}{
+		{"NUPI_VAD_INFERENCE_BUDGET_MS", "1001", "inference_budget.frame_ms"},
+		{"NUPI_VAD_INFERENCE_BUDGET_FRAMES", "-1", "inference_budget.frames"},
+		{"NUPI_VAD_INFERENCE_BUDGET_ACTION", "terminate", "inference_budget.action"},
+	} {
+		old := env[tc.key]
+		env[tc.key] = tc.value
+		if _, err := loader.Load(); err == nil || !strings.Contains(err.Error(), tc.want) {
+			t.Errorf("%s=%s: err = %v, want %s error", tc.key, tc.value, err, tc.want)
+		}
+		env[tc.key] = old
+	}
+
+	env = map[string]string{"NUPI_VAD_ENGINE": "stub", "NUPI_VAD_INFERENCE_BUDGET_ACTION": "skip"}
+	if _, err := loader.Load(); err == nil || !strings.Contains(err.Error(), "inference_budget.frame_ms") {
+		t.Errorf("action without frame_ms: err = %v, want inference_budget.frame_ms error", err)
+	}
+}
+
+func TestLoaderTimestampFormat(t *testing.T) {
+	env := map[string]string{
+		"NUPI_VAD_ENGINE":     "stub",
+		"NUPI_ADAPTER_CONFIG": `{"timestamp_format":"EPOCH_MS"}`,
+	}
+	loader := config.Loader{
+		Lookup: func(key string) (string, bool) {
+			v, ok := env[key]
+			return v, ok
+		},
+	}
+	result, err := loader.Load()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.Config.TimestampFormat != "epoch_ms" {
+		t.Errorf("TimestampFormat = %q, want epoch_ms", result.Config.TimestampFormat)
+	}
+
+	env["NUPI_VAD_TIMESTAMP_FORMAT"] = "offset_ms"
+	result, err = loader.Load()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.Config.TimestampFormat != "offset_ms" {
+		t.Errorf("TimestampFormat = %q, want the env override", result.Config.TimestampFormat)
+	}
+
+	env["NUPI_VAD_TIMESTAMP_FORMAT"] = "local"
+	if _, err := loader.Load(); err == nil || !strings.Contains(err.Error(), "timestamp_format") {
+		t.Errorf("invalid format: err = %v, want timestamp_format error", err)
+	}
+}
+
+func TestLoaderDenoise(t *testing.T) {
+	env := map[string]string{
+		"NUPI_VAD_ENGINE":     "stub",
+		"NUPI_ADAPTER_CONFIG": `{"denoise":true}`,
+	}
+	loader := config.Loader{
+		Lookup: func(key string) (string, bool) {
+			v, ok := env[key]
+			return v, ok
+		},
+	}
+	result, err := loader.Load()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !result.Config.Denoise {
+		t.Error("denoise from JSON config not applied")
+	}
+
+	env["NUPI_VAD_DENOISE"] = "false"
+	if result, err = loader.Load(); err != nil {
+		t.Fatal(err)
+	}
+	if result.Config.Denoise {
+		t.Error("NUPI_VAD_DENOISE=false did not override the JSON config")
+	}
+
+	env["NUPI_VAD_DENOISE"] = "maybe"
+	if _, err := loader.Load(); err == nil || !strings.Contains(err.Error(), "NUPI_VAD_DENOISE") {
+		t.Errorf("invalid NUPI_VAD_DENOISE: err = %v", err)
+	}
+}
+
+func TestLoaderHighPassHz(t *testing.T) {
+	env := map[string]string{
+		"NUPI_VAD_ENGINE":     "stub",
+		"NUPI_ADAPTER_CONFIG": `{"high_pass_hz":80}`,
+	}
+	loader := config.Loader{
+		Lookup: func(key string) (string, bool) {
+			v, ok := env[key]
+			return v, ok
+		},
+	}
+	result, err := loader.Load()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.Config.HighPassHz != 80 {
+		t.Errorf("HighPassHz = %d, want 80", result.Config.HighPassHz)
+	}
+
+	env["NUPI_VAD_HIGH_PASS_HZ"] = "120"
+	if result, err = loader.Load(); err != nil {
+		t.Fatal(err)
+	}
+	if result.Config.HighPassHz != 120 {
+		t.Errorf("HighPassHz = %d, want 120 from NUPI_VAD_HIGH_PASS_HZ", result.Config.HighPassHz)
+	}
+
+	for _, v := range []string{"-1", "2000"} {
+		env["NUPI_VAD_HIGH_PASS_HZ"] = v
+		if _, err := loader.Load(); err == nil || !strings.Contains(err.Error(), "high_pass_hz") {
+			t.Errorf("NUPI_VAD_HIGH_PASS_HZ=%s: err = %v, want high_pass_hz error", v, err)
+		}
+	}
+	delete(env, "NUPI_VAD_HIGH_PASS_HZ")
+	env["NUPI_ADAPTER_CONFIG"] = `{"high_pass_hz":5000}`
+	if _, err := loader.Load(); err == nil || !strings.Contains(err.Error(), "high_pass_hz") {
+		t.Errorf("JSON high_pass_hz=5000: err = %v, want high_pass_hz error", err)
+	}
+}
+
+func TestLoaderHeartbeatInterval(t *testing.T) {
+	env := map[string]string{
+		"NUPI_VAD_ENGINE":     "stub",
+		"NUPI_ADAPTER_CONFIG": `{"heartbeat_interval_ms":5000}`,
+	}
+	loader := config.Loader{
+		Lookup: func(key string) (string, bool) {
+			v, ok := env[key]
+			return v, ok
+		},
+	}
+	result, err := loader.Load()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.Config.HeartbeatIntervalMs != 5000 {
+		t.Errorf("HeartbeatIntervalMs = %d, want 5000", result.Config.HeartbeatIntervalMs)
+	}
+
+	env["NUPI_VAD_HEARTBEAT_INTERVAL_MS"] = "0"
+	if result, err = loader.Load(); err != nil {
+		t.Fatal(err)
+	}
+	if result.Config.HeartbeatIntervalMs != 0 {
+		t.Errorf("HeartbeatIntervalMs = %d, want 0 from NUPI_VAD_HEARTBEAT_INTERVAL_MS", result.Config.HeartbeatIntervalMs)
+	}
+
+	for _, v := range []string{"-1", "50", "600001"} {
+		env["NUPI_VAD_HEARTBEAT_INTERVAL_MS"] = v
+		if _, err := loader.Load(); err == nil || !strings.Contains(err.Error(), "heartbeat_interval_ms") {
+			t.Errorf("NUPI_VAD_HEARTBEAT_INTERVAL_MS=%s: err = %v, want heartbeat_interval_ms error", v, err)
+		}
+	}
+}
+
+func TestLoaderAGC(t *testing.T) {
+	env := map[string]string{
+		"NUPI_VAD_ENGINE":     "stub",
+		"NUPI_ADAPTER_CONFIG": `{"agc":{"target_dbfs":-18}}`,
+	}
+	loader := config.Loader{
+		Lookup: func(key string) (string, bool) {
+			v, ok := env[key]
+			return v, ok
+		},
+	}
+	result, err := loader.Load()
+	if err != nil {
+		t.Fatal(err)
+	}
+	agc := result.Config.AGC
+	if !agc.Enabled() || agc.TargetDbfs != -18 || agc.EffectiveMaxGainDb() != config.DefaultAGCMaxGainDb {
+		t.Errorf("AGC = %+v (max gain %v), want target -18 and the default max gain", agc, agc.EffectiveMaxGainDb())
+	}
+
+	env["NUPI_VAD_AGC_TARGET_DBFS"] = "-24.5"
+	env["NUPI_VAD_AGC_MAX_GAIN_DB"] = "12"
+	if result, err = loader.Load(); err != nil {
+		t.Fatal(err)
+	}
+	if got := result.Config.AGC; got != (config.AGC{TargetDbfs: -24.5, MaxGainDb: 12}) {
+		t.Errorf("AGC = %+v, want the env overrides", got)
+	}
+
+	for key, v := range map[string]string{"NUPI_VAD_AGC_TARGET_DBFS": "-70", "NUPI_VAD_AGC_MAX_GAIN_DB": "90"} {
+		env[key] = v
+		if _, err := loader.Load(); err == nil || !strings.Contains(err.Error(), "agc.") {
+			t.Errorf("%s=%s: err = %v, want agc error", key, v, err)
+		}
+		delete(env, key)
+	}
+
+	// A gain limit alone does not enable AGC and is rejected.
+	delete(env, "NUPI_VAD_AGC_TARGET_DBFS")
+	env["NUPI_ADAPTER_CONFIG"] = `{"agc":{"max_gain_db":10}}`
+	if _, err := loader.Load(); err == nil || !strings.Contains(err.Error(), "agc.target_dbfs") {
+		t.Errorf("agc without target: err = %v, want agc.target_dbfs error", err)
+	}
+}
+
+func TestLoaderAdaptiveThreshold(t *testing.T) {
+	env := map[string]string{
+		"NUPI_VAD_ENGINE":     "stub",
+		"NUPI_ADAPTER_CONFIG": `{"adaptive_threshold":{"min":0.3,"max":0.8}}`,
+	}
+	loader := config.Loader{
+		Lookup: func(key string) (string, bool) {
+			v, ok := env[key]
+			return v, ok
+		},
+	}
+	result, err := loader.Load()
+	if err != nil {
+		t.Fatal(err)
+	}
+	at := result.Config.AdaptiveThreshold
+	if !at.Enabled() || at.Min != 0.3 || at.Max != 0.8 || at.EffectiveMargin() != config.DefaultAdaptiveThresholdMargin {
+		t.Errorf("AdaptiveThreshold = %+v, want min 0.3, max 0.8 and the default margin", at)
+	}
+
+	for _, raw := range []string{
+		`{"adaptive_threshold":{"min":0,"max":0.8}}`,
+		`{"adaptive_threshold":{"min":0.6,"max":0.5}}`,
+		`{"adaptive_threshold":{"min":0.3,"max":1.5}}`,
+		`{"adaptive_threshold":{"min":0.3,"max":0.8,"margin":-0.1}}`,
+		`{"adaptive_threshold":{"margin":0.2}}`,
+	} {
+		env["NUPI_ADAPTER_CONFIG"] = raw
+		if _, err := loader.Load(); err == nil || !strings.Contains(err.Error(), "adaptive_threshold") {
+			t.Errorf("%s: err = %v, want adaptive_threshold error", raw, err)
+		}
+	}
+}
+
+func TestLoaderLogFormat(t *testing.T) {
+	env := map[string]string{"NUPI_VAD_ENGINE": "stub", "NUPI_LOG_FORMAT": " JSON "}
+	loader := config.Loader{
+		Lookup: func(key string) (string, bool) {
+			v, ok := env[key]
+			return v, ok
+		},
+	}
+	result, err := loader.Load()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.Config.LogFormat != config.LogFormatJSON {
+		t.Errorf("LogFormat = %q, want %q", result.Config.LogFormat, config.LogFormatJSON)
+	}
+
+	env["NUPI_LOG_FORMAT"] = "logfmt"
+	if _, err := loader.Load(); err == nil || !strings.Contains(err.Error(), "NUPI_LOG_FORMAT") {
+		t.Errorf("invalid format: err = %v, want NUPI_LOG_FORMAT error", err)
+	}
+}
+
+func TestLoaderDebugDump(t *testing.T) {
+	env := map[string]string{
+		"NUPI_VAD_ENGINE":                   "stub",
+		"NUPI_VAD_DEBUG_DUMP":               "1",
+		"NUPI_VAD_DEBUG_DUMP_MAX_STREAM_MB": "4",
+	}
+	loader := config.Loader{
+		Lookup: func(key string) (string, bool) {
+			v, ok := env[key]
+			return v, ok
+		},
+	}
+	if _, err := loader.Load(); err == nil || !strings.Contains(err.Error(), "debug_dump_dir") {
+		t.Errorf("debug_dump without dir: err = %v, want debug_dump_dir error", err)
+	}
+
+	env["NUPI_VAD_DEBUG_DUMP_DIR"] = " /var/tmp/vad "
+	result, err := loader.Load()
+	if err != nil {
+		t.Fatal(err)
+	}
+	cfg := result.Config
+	if cfg.DebugDumpDir != "/var/tmp/vad" || !cfg.DebugDump {
+		t.Errorf("DebugDumpDir = %q, DebugDump = %v", cfg.DebugDumpDir, cfg.DebugDump)
+	}
+	if got := cfg.EffectiveDebugDumpMaxStreamBytes(); got != 4<<20 {
+		t.Errorf("EffectiveDebugDumpMaxStreamBytes = %d, want 4 MiB", got)
+	}
+	if got := cfg.EffectiveDebugDumpMaxTotalBytes(); got != config.DefaultDebugDumpMaxTotalMB<<20 {
+		t.Errorf("EffectiveDebugDumpMaxTotalBytes = %d, want the default", got)
+	}
+	if features := cfg.PersistentFeatures(); !slices.Contains(features, "debug_dump_dir") {
+		t.Errorf("PersistentFeatures = %v, want debug_dump_dir", features)
+	}
+
+	env["NUPI_VAD_DEBUG_DUMP_MAX_STREAM_MB"] = "2048"
+	if _, err := loader.Load(); err == nil || !strings.Contains(err.Error(), "NUPI_VAD_DEBUG_DUMP_MAX_STREAM_MB") {
+		t.Errorf("out-of-range cap: err = %v, want NUPI_VAD_DEBUG_DUMP_MAX_STREAM_MB error", err)
+	}
+}
+
+func TestLoaderTLS(t *testing.T) {
+	env := map[string]string{
+		"NUPI_VAD_ENGINE":             "stub",
+		"NUPI_VAD_TLS_CLIENT_CA_FILE": "/etc/vad/ca.pem",
+	}
+	loader := config.Loader{
+		Lookup: func(key string) (string, bool) {
+			v, ok := env[key]
+			return v, ok
+		},
+	}
+	if _, err := loader.Load(); err == nil || !strings.Contains(err.Error(), "tls_client_ca_file") {
+		t.Errorf("client CA without certificate: err = %v, want tls_client_ca_file error", err)
+	}
+
+	env["NUPI_VAD_TLS_CERT_FILE"] = " /etc/vad/server.pem "
+	if _, err := loader.Load(); err == nil || !strings.Contains(err.Error(), "tls_key_file") {
+		t.Errorf("certificate without key: err = %v, want tls_key_file error", err)
+	}
+
+	env["NUPI_VAD_TLS_KEY_FILE"] = "/etc/vad/server.key"
+	result, err := loader.Load()
+	if err != nil {
+		t.Fatal(err)
+	}
+	cfg := result.Config
+	if !cfg.TLSEnabled() || cfg.TLSCertFile != "/etc/vad/server.pem" || cfg.TLSKeyFile != "/etc/vad/server.key" || cfg.TLSClientCAFile != "/etc/vad/ca.pem" {
+		t.Errorf("TLS config = %q, %q, %q", cfg.TLSCertFile, cfg.TLSKeyFile, cfg.TLSClientCAFile)
+	}
+}
+
+func TestLoaderAuthToken(t *testing.T) {
+	env := map[string]string{
+		"NUPI_VAD_ENGINE":         "stub",
+		"NUPI_ADAPTER_CONFIG":     `{"auth_token": "from-json"}`,
+		"NUPI_ADAPTER_AUTH_TOKEN": " from-env ",
+	}
+	loader := config.Loader{
+		Lookup: func(key string) (string, bool) {
+			v, ok := env[key]
+			return v, ok
+		},
+	}
+	result, err := loader.Load()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.Config.AuthToken != "from-env" {
+		t.Errorf("AuthToken = %q, want the environment's, trimmed", result.Config.AuthToken)
+	}
+}
+
+func TestLoaderMaxStreams(t *testing.T) {
+	env := map[string]string{"NUPI_VAD_ENGINE": "stub", "NUPI_VAD_MAX_STREAMS": "8"}
+	loader := config.Loader{
+		Lookup: func(key string) (string, bool) {
+			v, ok := env[key]
+			return v, ok
+		},
+	}
+	result, err := loader.Load()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.Config.MaxStreams != 8 {
+		t.Errorf("MaxStreams = %d, want 8", result.Config.MaxStreams)
+	}
+
+	env["NUPI_VAD_EXPECTED_STREAMS"] = "16"
+	if _, err := loader.Load(); err == nil || !strings.Contains(err.Error(), "max_streams") {
+		t.Errorf("expected_streams over max_streams: err = %v, want max_streams error", err)
+	}
+
+	env["NUPI_VAD_MAX_STREAMS"] = "-1"
+	if _, err := loader.Load(); err == nil || !strings.Contains(err.Error(), "NUPI_VAD_MAX_STREAMS") {
+		t.Errorf("negative cap: err = %v, want NUPI_VAD_MAX_STREAMS error", err)
+	}
+}
+
+func TestLoaderStreamLimits(t *testing.T) {
+	env := map[string]string{
+		"NUPI_VAD_ENGINE":                "stub",
+		"NUPI_VAD_MAX_STREAM_DURATION_S": "3600",
+		"NUPI_VAD_MAX_STREAM_AUDIO_MB":   "64",
+	}
+	loader := config.Loader{
+		Lookup: func(key string) (string, bool) {
+			v, ok := env[key]
+			return v, ok
+		},
+	}
+	result, err := loader.Load()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.Config.MaxStreamDurationSec != 3600 || result.Config.MaxStreamAudioMB != 64 {
+		t.Errorf("limits = %d s, %d MiB", result.Config.MaxStreamDurationSec, result.Config.MaxStreamAudioMB)
+	}
+
+	env["NUPI_VAD_MAX_STREAM_DURATION_S"] = "1000000"
+	if _, err := loader.Load(); err == nil || !strings.Contains(err.Error(), "NUPI_VAD_MAX_STREAM_DURATION_S") {
+		t.Errorf("duration over a week: err = %v, want NUPI_VAD_MAX_STREAM_DURATION_S error", err)
+	}
+}
+
+func TestLoaderSelfTestInterval(t *testing.T) {
+	env := map[string]string{"NUPI_VAD_ENGINE": "stub"}
+	loader := config.Loader{
+		Lookup: func(key string) (string, bool) {
+			v, ok := env[key]
+			return v, ok
+		},
+	}
+	result, err := loader.Load()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.Config.SelfTestIntervalSec != config.DefaultSelfTestIntervalSec {
+		t.Errorf("default SelfTestIntervalSec = %d, want %d", result.Config.SelfTestIntervalSec, config.DefaultSelfTestIntervalSec)
+	}
+
+	env["NUPI_VAD_SELF_TEST_INTERVAL_S"] = "0"
+	if result, err = loader.Load(); err != nil || result.Config.SelfTestIntervalSec != 0 {
+		t.Errorf("disabled self-test: interval = %d, err = %v", result.Config.SelfTestIntervalSec, err)
+	}
+	env["NUPI_VAD_SELF_TEST_INTERVAL_S"] = "7200"
+	if _, err := loader.Load(); err == nil || !strings.Contains(err.Error(), "NUPI_VAD_SELF_TEST_INTERVAL_S") {
+		t.Errorf("interval over an hour: err = %v, want NUPI_VAD_SELF_TEST_INTERVAL_S error", err)
+	}
+}
+
+func TestLoaderPreset(t *testing.T) {
+	load := func(env map[string]string) (config.LoadResult, error) {
+		return config.Loader{Lookup: func(key string) (string, bool) {
+			v, ok := env[key]
+			return v, ok
+		}}.Load()
+	}
+	lowLatency, ok := config.PresetParams(config.PresetLowLatency)
+	if !ok {
+		t.Fatal("low-latency preset missing")
+	}
+
+	result, err := load(map[string]string{"NUPI_VAD_ENGINE": "stub", "NUPI_VAD_PRESET": "low-latency"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	cfg := result.Config
+	if cfg.Preset != config.PresetLowLatency || cfg.Threshold != *lowLatency.Threshold ||
+		cfg.MinSpeechDurationMs != *lowLatency.MinSpeechDurationMs ||
+		cfg.MinSilenceDurationMs != *lowLatency.MinSilenceDurationMs || cfg.PrerollMs != *lowLatency.PrerollMs {
+		t.Errorf("preset not expanded: %+v", cfg)
+	}
+	if got := result.Provenance["min_silence_duration_ms"]; got != config.SourcePreset {
+		t.Errorf("source of min_silence_duration_ms = %q, want %q", got, config.SourcePreset)
+	}
+
+	// A field set by any layer overrides the preset, even one below the
+	// layer naming it.
+	result, err = load(map[string]string{
+		"NUPI_VAD_ENGINE":     "stub",
+		"NUPI_ADAPTER_CONFIG": `{"preset": "dictation", "threshold": 0.6}`,
+		"NUPI_VAD_PREROLL_MS": "0",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	dictation, _ := config.PresetParams(config.PresetDictation)
+	cfg = result.Config
+	if cfg.Threshold != 0.6 || cfg.PrerollMs != 0 || cfg.MinSilenceDurationMs != *dictation.MinSilenceDurationMs {
+		t.Errorf("overrides: threshold %v, preroll_ms %d, min_silence_duration_ms %d", cfg.Threshold, cfg.PrerollMs, cfg.MinSilenceDurationMs)
+	}
+
+	if _, err := load(map[string]string{"NUPI_VAD_PRESET": "fast"}); err == nil || !strings.Contains(err.Error(), "preset must be balanced, dictation, low-latency") {
+		t.Errorf("unknown preset: err = %v", err)
+	}
+}
+
+func TestLoaderStubScript(t *testing.T) {
+	load := func(env map[string]string) (config.LoadResult, error) {
+		return config.Loader{Lookup: func(key string) (string, bool) {
+			v, ok := env[key]
+			return v, ok
+		}}.Load()
+	}
+	result, err := load(map[string]string{"NUPI_VAD_ENGINE": "stub", "NUPI_VAD_STUB_SCRIPT": " /tmp/script.json "})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.Config.StubScript != "/tmp/script.json" {
+		t.Errorf("stub_script = %q", result.Config.StubScript)
+	}
+	result, err = load(map[string]string{"NUPI_ADAPTER_CONFIG": `{"engine": "stub", "stub_script": "script.json"}`})
+	if err != nil || result.Config.StubScript != "script.json" {
+		t.Errorf("stub_script from JSON = %q, %v", result.Config.StubScript, err)
+	}
+	// The script only drives the stub.
+	if _, err := load(map[string]string{"NUPI_VAD_ENGINE": "energy", "NUPI_VAD_STUB_SCRIPT": "script.json"}); err == nil || !strings.Contains(err.Error(), "stub_script") {
+		t.Errorf("stub_script with engine energy: err = %v", err)
+	}
+}
+
+func TestLoaderEventQueue(t *testing.T) {
+	load := func(env map[string]string) (config.LoadResult, error) {
+		return config.Loader{Lookup: func(key string) (string, bool) {
+			v, ok := env[key]
+			return v, ok
+		}}.Load()
+	}
+	result, err := load(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.Config.EventQueueSize != 0 || result.Config.EventQueuePolicy != "" {
+		t.Errorf("default queue = %d %q, want none", result.Config.EventQueueSize, result.Config.EventQueuePolicy)
+	}
+	// The policy defaults to drop_ongoing once a queue is configured.
+	result, err = load(map[string]string{"NUPI_VAD_EVENT_QUEUE_SIZE": "64"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.Config.EventQueueSize != 64 || result.Config.EventQueuePolicy != config.EventQueueDropOngoing {
+		t.Errorf("queue = %d %q, want 64 drop_ongoing", result.Config.EventQueueSize, result.Config.EventQueuePolicy)
+	}
+	result, err = load(map[string]string{"NUPI_ADAPTER_CONFIG": `{"event_queue_size": 8, "event_queue_policy": "block"}`})
+	if err != nil || result.Config.EventQueueSize != 8 || result.Config.EventQueuePolicy != config.EventQueueBlock {
+		t.Errorf("queue from JSON = %d %q, %v", result.Config.EventQueueSize, result.Config.EventQueuePolicy, err)
+	}
+
+	for _, env := range []map[string]string{
+		{"NUPI_VAD_EVENT_QUEUE_POLICY": "block"},
+		{"NUPI_VAD_EVENT_QUEUE_SIZE": "8", "NUPI_VAD_EVENT_QUEUE_POLICY": "drop_all"},
+		{"NUPI_VAD_EVENT_QUEUE_SIZE": "5000"},
+		{"NUPI_VAD_EVENT_QUEUE_SIZE": "-1"},
+	} {
+		if _, err := load(env); err == nil || !strings.Contains(err.Error(), "event_queue") {
+			t.Errorf("%v: err = %v, want an event_queue error", env, err)
+		}
+	}
+}
+
+func TestLoaderPipelineDepth(t *testing.T) {
+	load := func(env map[string]string) (config.LoadResult, error) {
+		return config.Loader{Lookup: func(key string) (string, bool) {
+			v, ok := env[key]
+			return v, ok
+		}}.Load()
+	}
+	result, err := load(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := result.Config.EffectivePipelineDepth(); got != config.DefaultPipelineDepth {
+		t.Errorf("default pipeline depth = %d, want %d", got, config.DefaultPipelineDepth)
+	}
+	result, err = load(map[string]string{"NUPI_VAD_PIPELINE_DEPTH": "16"})
+	if err != nil || result.Config.EffectivePipelineDepth() != 16 {
+		t.Errorf("pipeline_depth = %d, %v; want 16", result.Config.PipelineDepth, err)
+	}
+	result, err = load(map[string]string{"NUPI_ADAPTER_CONFIG": `{"pipeline_depth": 1}`})
+	if err != nil || result.Config.EffectivePipelineDepth() != 1 {
+		t.Errorf("pipeline_depth from JSON = %d, %v; want 1", result.Config.PipelineDepth, err)
+	}
+	if _, err := load(map[string]string{"NUPI_VAD_PIPELINE_DEPTH": "65"}); err == nil || !strings.Contains(err.Error(), "pipeline_depth") {
+		t.Errorf("pipeline_depth 65: err = %v", err)
+	}
+}
@@ -0,0 +1,98 @@
+package config
+
+import (
+	"encoding/json"
+	"fmt"
+	"slices"
+	"strings"
+)
+
+// Tuning presets: curated VAD parameters for common use cases.
+const (
+	// PresetLowLatency reacts fast, for voice commands and barge-in: short
+	// speech and silence windows, at the cost of splitting speech at brief
+	// pauses.
+	PresetLowLatency = "low-latency"
+	// PresetBalanced is the default tuning with pre-roll, for conversational
+	// turn-taking.
+	PresetBalanced = "balanced"
+	// PresetDictation keeps long utterances in one segment through the
+	// pauses of someone thinking aloud, with a lower threshold for soft
+	// speech and generous pre-roll.
+	PresetDictation = "dictation"
+)
+
+// SourcePreset is the Provenance source of the options a preset set.
+const SourcePreset = "preset"
+
+var presets = map[string]VADParams{
+	PresetLowLatency: presetParams(0.5, 100, 150, 50),
+	PresetBalanced:   presetParams(DefaultThreshold, DefaultMinSpeechDurationMs, DefaultMinSilenceDurationMs, 100),
+	PresetDictation:  presetParams(0.45, 250, 800, 200),
+}
+
+func presetParams(threshold float64, minSpeechMs, minSilenceMs, prerollMs int) VADParams {
+	return VADParams{
+		Threshold:            &threshold,
+		MinSpeechDurationMs:  &minSpeechMs,
+		MinSilenceDurationMs: &minSilenceMs,
+		PrerollMs:            &prerollMs,
+	}
+}
+
+// PresetNames returns the preset names, sorted.
+func PresetNames() []string {
+	names := make([]string, 0, len(presets))
+	for name := range presets {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+	return names
+}
+
+// PresetParams returns the VAD parameters preset name sets.
+func PresetParams(name string) (VADParams, bool) {
+	p, ok := presets[name]
+	return p, ok
+}
+
+func validatePreset(name string) error {
+	if _, ok := presets[name]; name != "" && !ok {
+		return fmt.Errorf("config: preset must be %s, got %q", strings.Join(PresetNames(), ", "), name)
+	}
+	return nil
+}
+
+// applyPreset expands the preset the loader's layers name into cfg. A
+// preset is the baseline the options each layer sets override, so it is
+// expanded before any layer applies, whichever names it: the last of the
+// JSON payloads, in order, and then NUPI_VAD_PRESET.
+func applyPreset(env *envLayer, cfg *Config, payloads ...string) error {
+	var name string
+	for _, raw := range payloads {
+		var payload struct {
+			Preset string `json:"preset"`
+		}
+		// Malformed payloads are reported when applied.
+		if json.Unmarshal([]byte(raw), &payload) == nil && payload.Preset != "" {
+			name = payload.Preset
+		}
+	}
+	if value, ok := env.lookup("NUPI_VAD_PRESET"); ok && strings.TrimSpace(value) != "" {
+		name = strings.TrimSpace(value)
+	}
+	if name == "" {
+		return nil
+	}
+	if err := validatePreset(name); err != nil {
+		return err
+	}
+	params := presets[name]
+	params.Apply(cfg)
+	for option, value := range flattenJSON(params) {
+		if value != nil {
+			env.prov[option] = SourcePreset
+		}
+	}
+	return nil
+}
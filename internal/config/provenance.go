@@ -0,0 +1,186 @@
+package config
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"slices"
+	"strings"
+)
+
+// Sources of an option's value, besides the environment variable (or the
+// description Loader.Origin gives for it) that set it.
+const (
+	SourceDefault = "default"
+	SourceFile    = "NUPI_ADAPTER_CONFIG_FILE"
+	SourceJSON    = "NUPI_ADAPTER_CONFIG"
+)
+
+// Provenance records which source set each option of a loaded Config. Keys
+// are the option's path in the JSON config ("threshold", "agc.target_dbfs");
+// values are SourceDefault, SourcePreset, SourceFile, SourceJSON or the
+// environment variable whose value is in effect. A later source that
+// repeats the value an earlier one set still takes over, since it decides
+// the value.
+type Provenance map[string]string
+
+// Option is one entry of Effective.
+type Option struct {
+	Name   string
+	Value  any
+	Source string
+}
+
+// Effective returns every option of cfg with its value and source, sorted
+// by name: the configuration the adapter actually runs with. auth_token is
+// redacted.
+func Effective(cfg Config, prov Provenance) []Option {
+	if cfg.AuthToken != "" {
+		cfg.AuthToken = "<redacted>"
+	}
+	values := flattenJSON(cfg)
+	options := make([]Option, 0, len(values))
+	for name, value := range values {
+		source, ok := prov[name]
+		if !ok {
+			source = SourceDefault
+		}
+		options = append(options, Option{Name: name, Value: value, Source: source})
+	}
+	slices.SortFunc(options, func(a, b Option) int { return strings.Compare(a.Name, b.Name) })
+	return options
+}
+
+// WithTunable returns a copy of p with the sources of the options
+// TunableParams covers taken from reloaded, for a config reload that only
+// applied those.
+func (p Provenance) WithTunable(reloaded Provenance) Provenance {
+	merged := make(Provenance, len(p))
+	for name, source := range p {
+		merged[name] = source
+	}
+	var tunable Config
+	for name := range flattenJSON(tunable.TunableParams()) {
+		if source, ok := reloaded[name]; ok {
+			merged[name] = source
+		} else {
+			delete(merged, name)
+		}
+	}
+	return merged
+}
+
+// setFromPayload records source for every option raw, a JSON config
+// applied by applyJSON, sets. An object applies as a whole, so every option
+// below it counts as set; null and empty strings leave options unchanged,
+// and unknown keys are ignored.
+func (p Provenance) setFromPayload(raw, source string) {
+	var payload map[string]any
+	if json.Unmarshal([]byte(raw), &payload) != nil {
+		return
+	}
+	record := func(name string) {
+		if _, known := p[name]; known {
+			p[name] = source
+		}
+	}
+	var set func(prefix string, fields map[string]any)
+	set = func(prefix string, fields map[string]any) {
+		for key, value := range fields {
+			switch v := value.(type) {
+			case nil:
+			case string:
+				if v != "" {
+					record(prefix + key)
+				}
+			case map[string]any:
+				for name := range p {
+					if strings.HasPrefix(name, prefix+key+".") {
+						p[name] = source
+					}
+				}
+				set(prefix+key+".", v)
+			default:
+				record(prefix + key)
+			}
+		}
+	}
+	set("", payload)
+}
+
+// envLayer is the environment as the override helpers see it: the lookup,
+// and a record of the options it set.
+type envLayer struct {
+	lookup func(string) (string, bool)
+	origin func(string) string
+	prov   Provenance
+	// paths maps the address of every option in the Config being loaded
+	// to its name.
+	paths map[uintptr]string
+}
+
+func newEnvLayer(lookup func(string) (string, bool), origin func(string) string, cfg *Config, prov Provenance) *envLayer {
+	if origin == nil {
+		origin = func(key string) string { return key }
+	}
+	e := &envLayer{lookup: lookup, origin: origin, prov: prov, paths: map[uintptr]string{}}
+	e.addPaths(reflect.ValueOf(cfg).Elem(), "")
+	return e
+}
+
+func (e *envLayer) addPaths(v reflect.Value, prefix string) {
+	t := v.Type()
+	for i := 0; i < t.NumField(); i++ {
+		name, _, _ := strings.Cut(t.Field(i).Tag.Get("json"), ",")
+		if name == "" || name == "-" {
+			continue
+		}
+		f := v.Field(i)
+		if f.Kind() == reflect.Struct {
+			e.addPaths(f, prefix+name+".")
+			continue
+		}
+		e.paths[f.Addr().Pointer()] = prefix + name
+	}
+}
+
+// applied records that the variable key set the option at target.
+func (e *envLayer) applied(key string, target any) {
+	if name, ok := e.paths[reflect.ValueOf(target).Pointer()]; ok {
+		e.prov[name] = e.origin(key)
+	}
+}
+
+// newProvenance returns the Provenance of cfg, holding only defaults.
+func newProvenance(cfg Config) Provenance {
+	prov := Provenance{}
+	for name := range flattenJSON(cfg) {
+		prov[name] = SourceDefault
+	}
+	return prov
+}
+
+// flattenJSON returns the leaf values of v's JSON encoding by path.
+func flattenJSON(v any) map[string]any {
+	raw, err := json.Marshal(v)
+	if err != nil {
+		panic(fmt.Sprintf("config: encode %T: %v", v, err))
+	}
+	var fields map[string]any
+	if err := json.Unmarshal(raw, &fields); err != nil {
+		panic(fmt.Sprintf("config: decode %T: %v", v, err))
+	}
+	flat := map[string]any{}
+	var walk func(prefix string, fields map[string]any)
+	walk = func(prefix string, fields map[string]any) {
+		for key, value := range fields {
+			if nested, ok := value.(map[string]any); ok {
+				walk(prefix+key+".", nested)
+				continue
+			}
+			flat[prefix+key] = value
+		}
+	}
+	walk("", fields)
+	return flat
+}
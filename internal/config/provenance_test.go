@@ -0,0 +1,71 @@
+package config_test
+
+import (
+	"testing"
+
+	"github.com/nupi-ai/plugin-vad-local-silero/internal/config"
+)
+
+func TestLoaderProvenance(t *testing.T) {
+	result, err := loadFile(t, "vad.yaml", "threshold: 0.6\nmin_gap_ms: 100\nagc:\n  target_dbfs: -20\n", map[string]string{
+		"NUPI_ADAPTER_CONFIG":  `{"min_gap_ms": 200, "instance_id": "", "unknown_option": 1}`,
+		"NUPI_VAD_PREROLL_MS":  "120",
+		"NUPI_VAD_MAX_STREAMS": "8",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for name, want := range map[string]string{
+		"threshold":              config.SourceFile,
+		"agc.target_dbfs":        config.SourceFile,
+		"agc.max_gain_db":        config.SourceFile, // the agc object applies whole
+		"min_gap_ms":             config.SourceJSON,
+		"instance_id":            config.SourceDefault, // empty strings are ignored
+		"preroll_ms":             "NUPI_VAD_PREROLL_MS",
+		"max_streams":            "NUPI_VAD_MAX_STREAMS",
+		"engine":                 "NUPI_VAD_ENGINE",
+		"min_speech_duration_ms": config.SourceDefault,
+	} {
+		if got := result.Provenance[name]; got != want {
+			t.Errorf("source of %s = %q, want %q", name, got, want)
+		}
+	}
+	if _, ok := result.Provenance["unknown_option"]; ok {
+		t.Error("provenance records an unknown option")
+	}
+
+	origin := config.Loader{
+		Lookup: func(key string) (string, bool) { return "0.7", key == "NUPI_VAD_THRESHOLD" },
+		Origin: func(key string) string { return "--" + key },
+	}
+	if result, err := origin.Load(); err != nil || result.Provenance["threshold"] != "--NUPI_VAD_THRESHOLD" {
+		t.Errorf("with an Origin: source of threshold = %q (err %v)", result.Provenance["threshold"], err)
+	}
+}
+
+func TestEffective(t *testing.T) {
+	cfg := config.Config{Threshold: 0.6, AuthToken: "s3cret"}
+	options := config.Effective(cfg, config.Provenance{"threshold": "NUPI_VAD_THRESHOLD"})
+	byName := map[string]config.Option{}
+	for i, o := range options {
+		if i > 0 && options[i-1].Name >= o.Name {
+			t.Errorf("options not sorted: %q before %q", options[i-1].Name, o.Name)
+		}
+		byName[o.Name] = o
+	}
+	if o := byName["threshold"]; o.Value != 0.6 || o.Source != "NUPI_VAD_THRESHOLD" {
+		t.Errorf("threshold = %+v", o)
+	}
+	if o := byName["auth_token"]; o.Value != "<redacted>" || o.Source != config.SourceDefault {
+		t.Errorf("auth_token = %+v, want it redacted", o)
+	}
+	if _, ok := byName["agc.target_dbfs"]; !ok {
+		t.Error("nested option agc.target_dbfs missing")
+	}
+
+	merged := config.Provenance{"threshold": config.SourceFile, "max_streams": "NUPI_VAD_MAX_STREAMS"}.
+		WithTunable(config.Provenance{"threshold": config.SourceJSON, "max_streams": config.SourceDefault})
+	if merged["threshold"] != config.SourceJSON || merged["max_streams"] != "NUPI_VAD_MAX_STREAMS" {
+		t.Errorf("WithTunable = %v, want the reloaded threshold and the original max_streams", merged)
+	}
+}
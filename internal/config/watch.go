@@ -0,0 +1,37 @@
+package config
+
+import (
+	"context"
+	"os"
+	"time"
+)
+
+// DefaultWatchInterval is how often WatchFile checks the config file.
+const DefaultWatchInterval = 2 * time.Second
+
+// WatchFile calls changed whenever the file at path changes, checking its
+// modification time and size every interval until ctx is done. Polling
+// needs no platform notification API and follows the path, so editors that
+// save through a rename and Kubernetes ConfigMap symlink swaps are seen
+// too. A file that disappears is reported again once it reappears.
+func WatchFile(ctx context.Context, path string, interval time.Duration, changed func()) {
+	last, _ := os.Stat(path)
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+		info, err := os.Stat(path)
+		if err != nil {
+			last = nil
+			continue
+		}
+		if last == nil || !info.ModTime().Equal(last.ModTime()) || info.Size() != last.Size() {
+			last = info
+			changed()
+		}
+	}
+}
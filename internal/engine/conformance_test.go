@@ -0,0 +1,111 @@
+package engine
+
+import (
+	"errors"
+	"math"
+	"testing"
+)
+
+// testConformance checks the Engine contract the server relies on: frame
+// geometry, one result per frame of audio whatever the chunking,
+// probabilities in [0, 1], input validation, and Reset restoring the
+// initial state. Every engine runs it; go run ./tools/new-engine hooks new
+// ones up.
+func testConformance(t *testing.T, newEngine func() (Engine, error)) {
+	t.Helper()
+	eng, err := newEngine()
+	if err != nil {
+		t.Fatalf("create engine: %v", err)
+	}
+	defer eng.Close()
+
+	if eng.SampleRate() != ExpectedSampleRate {
+		t.Errorf("SampleRate = %d before SetSampleRate, want %d", eng.SampleRate(), ExpectedSampleRate)
+	}
+	for _, rate := range []uint32{ExpectedSampleRate, TelephonySampleRate} {
+		if err := eng.SetSampleRate(rate); err != nil {
+			if rate == TelephonySampleRate && errors.Is(err, ErrWrongSampleRate) {
+				continue // 8 kHz is optional
+			}
+			t.Fatalf("SetSampleRate(%d): %v", rate, err)
+		}
+		frame := eng.FrameSamples()
+		if frame <= 0 {
+			t.Fatalf("%d Hz: FrameSamples = %d", rate, frame)
+		}
+		if got, want := eng.FrameDurationMs(), frame*1000/int(rate); got != want {
+			t.Errorf("%d Hz: FrameDurationMs = %d, want %d", rate, got, want)
+		}
+		if WindowSamples(eng) < frame {
+			t.Errorf("%d Hz: WindowSamples = %d, shorter than the %d-sample frame", rate, WindowSamples(eng), frame)
+		}
+
+		if err := eng.Reset(); err != nil {
+			t.Fatalf("Reset: %v", err)
+		}
+		first := conformanceRun(t, eng, rate)
+		if err := eng.Reset(); err != nil {
+			t.Fatalf("Reset: %v", err)
+		}
+		again := conformanceRun(t, eng, rate)
+		for i := range first {
+			if first[i] != again[i] {
+				t.Errorf("%d Hz: frame %d scored %v after Reset, %v before", rate, i, again[i], first[i])
+				break
+			}
+		}
+	}
+
+	if err := eng.SetSampleRate(44100); err == nil {
+		t.Error("SetSampleRate(44100): no error")
+	}
+	if err := eng.SetSampleRate(ExpectedSampleRate); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := eng.ProcessChunk(make([]byte, 3), ExpectedSampleRate); err == nil {
+		t.Error("odd-length PCM: no error")
+	}
+	if _, err := eng.ProcessChunk(make([]byte, 640), TelephonySampleRate); err == nil {
+		t.Error("PCM at a rate other than the configured one: no error")
+	}
+}
+
+// conformanceRun feeds one second of a tone in uneven chunks and returns the
+// frame probabilities, checking there is one per whole frame.
+func conformanceRun(t *testing.T, eng Engine, rate uint32) []float32 {
+	t.Helper()
+	samples := int(rate)
+	pcm := make([]byte, 2*samples)
+	for i := 0; i < samples; i++ {
+		v := int16(8000 * math.Sin(2*math.Pi*440*float64(i)/float64(rate)))
+		pcm[2*i], pcm[2*i+1] = byte(v), byte(v>>8)
+	}
+	var conf []float32
+	for off, n := 0, 0; off < len(pcm); n++ {
+		size := min(2*(37+n*53%400), len(pcm)-off)
+		results, err := eng.ProcessChunk(pcm[off:off+size], rate)
+		if err != nil {
+			t.Fatalf("%d Hz: ProcessChunk: %v", rate, err)
+		}
+		for _, r := range results {
+			if !(r.Confidence >= 0 && r.Confidence <= 1) {
+				t.Fatalf("%d Hz: confidence %v outside [0, 1]", rate, r.Confidence)
+			}
+			conf = append(conf, r.Confidence)
+		}
+		off += size
+	}
+	if want := samples / eng.FrameSamples(); len(conf) != want {
+		t.Fatalf("%d Hz: %d results for %d samples, want %d", rate, len(conf), samples, want)
+	}
+	// The server bounds buffered audio per stream; an engine that consumes
+	// its frames never holds a whole window plus a frame.
+	if got, limit := BufferedSamples(eng), WindowSamples(eng)+eng.FrameSamples(); got >= limit {
+		t.Errorf("%d Hz: %d samples buffered after the run, want fewer than %d", rate, got, limit)
+	}
+	return conf
+}
+
+func TestStubConformance(t *testing.T) {
+	testConformance(t, func() (Engine, error) { return NewStubEngine(), nil })
+}
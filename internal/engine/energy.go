@@ -0,0 +1,171 @@
+package engine
+
+import (
+	"math"
+
+	"github.com/nupi-ai/plugin-vad-local-silero/internal/errs"
+)
+
+const (
+	// energyFrameDurationMs is the energy engine's frame: Silero's window, so
+	// the two run frame-aligned as shadow or ensemble engines.
+	energyFrameDurationMs = 32
+
+	// energySilenceDb is the level (dBFS) below which a frame is silence
+	// whatever the noise floor, and the floor's lower bound, so near-digital
+	// silence does not turn faint hiss into speech.
+	energySilenceDb = -60.0
+	// energyInitialFloorDb is the noise floor assumed before any audio.
+	energyInitialFloorDb = -55.0
+	// energyFloorRise is the fraction of the gap to a louder frame the noise
+	// floor closes per frame (about 3 s to adapt to a new background at
+	// 32 ms frames); the floor follows quieter frames energyFloorFall faster.
+	energyFloorRise = 0.01
+	energyFloorFall = 0.5
+
+	// A frame energySNRDb above the noise floor scores 0.5; the probability
+	// moves from about 0.1 to 0.9 over ±energySNRSlopeDb*2.2 around it.
+	energySNRDb      = 10.0
+	energySNRSlopeDb = 2.5
+
+	// Frames whose zero-crossing rate (crossings per sample) is above
+	// energyZCRHigh look like broadband noise rather than voiced speech
+	// (white noise crosses zero about every other sample); their score is
+	// scaled down linearly to energyZCRPenalty at energyZCRMax.
+	energyZCRHigh    = 0.3
+	energyZCRMax     = 0.5
+	energyZCRPenalty = 0.5
+)
+
+// EnergyEngine is a lightweight VAD that scores each 32 ms frame by its
+// level above an adaptive noise floor and its zero-crossing rate. Unlike the
+// stub it analyzes the audio, with no model or native dependency, so it
+// suits constrained hosts and serves as a cross-check of Silero in tests.
+// A steady sound is absorbed into the noise floor within seconds, while the
+// pauses of real speech keep the floor down. It is far less robust than
+// Silero to music, babble and non-stationary noise.
+type EnergyEngine struct {
+	buf        *windowBuffer
+	sampleRate uint32
+	floorDb    float64
+	stride     strider
+}
+
+// NewEnergyEngine creates an EnergyEngine configured for 16kHz input.
+func NewEnergyEngine() *EnergyEngine {
+	e := &EnergyEngine{sampleRate: ExpectedSampleRate, floorDb: energyInitialFloorDb}
+	e.buf = newWindowBuffer(e.FrameSamples(), e.FrameSamples())
+	return e
+}
+
+// ProcessChunk returns one Result per 32 ms frame of pcm, buffering the rest.
+func (e *EnergyEngine) ProcessChunk(pcm []byte, sampleRate uint32) ([]Result, error) {
+	if sampleRate != e.sampleRate {
+		return nil, ErrWrongSampleRate
+	}
+	if len(pcm)%2 != 0 {
+		return nil, errs.New(errs.ErrInvalidPCM, "energy: PCM buffer has odd length %d, expected even (s16le requires 2 bytes per sample)", len(pcm))
+	}
+	e.buf.write(pcm)
+	var results []Result
+	for frame := e.buf.next(); frame != nil; frame = e.buf.next() {
+		if e.stride.next() {
+			e.stride.scored(e.score(frame))
+		}
+		results = append(results, Result{Confidence: e.stride.last})
+	}
+	return results, nil
+}
+
+// score returns the speech probability of frame and updates the noise floor.
+func (e *EnergyEngine) score(frame []float32) float32 {
+	levelDb, zcr := frameFeatures(frame)
+	snr := levelDb - e.floorDb
+	if levelDb < e.floorDb {
+		e.floorDb += (levelDb - e.floorDb) * energyFloorFall
+	} else {
+		e.floorDb += (levelDb - e.floorDb) * energyFloorRise
+	}
+	e.floorDb = max(e.floorDb, energySilenceDb)
+	if levelDb < energySilenceDb {
+		return 0
+	}
+
+	p := 1 / (1 + math.Exp(-(snr-energySNRDb)/energySNRSlopeDb))
+	if zcr > energyZCRHigh {
+		excess := min((zcr-energyZCRHigh)/(energyZCRMax-energyZCRHigh), 1)
+		p *= 1 - excess*(1-energyZCRPenalty)
+	}
+	return float32(p)
+}
+
+// frameFeatures returns frame's RMS level in dBFS and its zero-crossing rate
+// in crossings per sample.
+func frameFeatures(frame []float32) (levelDb, zcr float64) {
+	var sum float64
+	crossings := 0
+	for i, s := range frame {
+		sum += float64(s) * float64(s)
+		if i > 0 && (s >= 0) != (frame[i-1] >= 0) {
+			crossings++
+		}
+	}
+	rms := math.Sqrt(sum / float64(len(frame)))
+	return 20 * math.Log10(max(rms, 1e-9)), float64(crossings) / float64(len(frame))
+}
+
+// Reset drops buffered audio and restarts noise floor tracking.
+func (e *EnergyEngine) Reset() error {
+	e.buf.reset()
+	e.floorDb = energyInitialFloorDb
+	e.stride.reset()
+	return nil
+}
+
+// Close returns the engine's sample buffer to the pool; it holds no
+// external resources.
+func (e *EnergyEngine) Close() error {
+	e.buf.release()
+	return nil
+}
+
+// FrameDurationMs returns 32.
+func (e *EnergyEngine) FrameDurationMs() int {
+	return energyFrameDurationMs
+}
+
+// FrameSamples returns the samples per frame: 512 at 16 kHz, 256 at 8 kHz.
+func (e *EnergyEngine) FrameSamples() int {
+	return int(e.sampleRate) * energyFrameDurationMs / 1000
+}
+
+// SetSampleRate configures the engine for 16 or 8 kHz input and clears
+// buffered audio and the noise floor.
+func (e *EnergyEngine) SetSampleRate(sampleRate uint32) error {
+	if !SupportedSampleRate(sampleRate) {
+		return ErrWrongSampleRate
+	}
+	e.sampleRate = sampleRate
+	e.buf.configure(e.FrameSamples(), e.FrameSamples())
+	e.floorDb = energyInitialFloorDb
+	e.stride.reset()
+	return nil
+}
+
+// SampleRate returns the configured input rate.
+func (e *EnergyEngine) SampleRate() uint32 {
+	return e.sampleRate
+}
+
+// BufferedSamples returns the samples held for the next frame.
+func (e *EnergyEngine) BufferedSamples() int {
+	return e.buf.buffered()
+}
+
+// SetStride makes the engine score every n-th frame. A skipped frame does
+// not update the noise floor.
+func (e *EnergyEngine) SetStride(n int) { e.stride.set(n) }
+
+// SetFrameSkip makes the engine lower its stride while confident (see
+// FrameSkipper).
+func (e *EnergyEngine) SetFrameSkip(fs FrameSkip) { e.stride.setFrameSkip(fs) }
@@ -0,0 +1,90 @@
+package engine
+
+import (
+	"math"
+	"math/rand"
+	"testing"
+)
+
+func TestEnergyConformance(t *testing.T) {
+	testConformance(t, func() (Engine, error) { return NewEnergyEngine(), nil })
+}
+
+// energyPCM returns n samples of a 200 Hz tone at amplitude tone plus
+// uniform noise at amplitude noise (both as fractions of full scale).
+func energyPCM(rng *rand.Rand, n int, tone, noise float64) []byte {
+	pcm := make([]byte, 2*n)
+	for i := 0; i < n; i++ {
+		v := tone*math.Sin(2*math.Pi*200*float64(i)/16000) + noise*(2*rng.Float64()-1)
+		s := int16(v * 32767)
+		pcm[2*i], pcm[2*i+1] = byte(s), byte(s>>8)
+	}
+	return pcm
+}
+
+// meanConfidence runs pcm through eng and returns the mean probability of
+// its frames.
+func meanConfidence(t *testing.T, eng Engine, pcm []byte) float64 {
+	t.Helper()
+	results, err := eng.ProcessChunk(pcm, ExpectedSampleRate)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var sum float64
+	for _, r := range results {
+		sum += float64(r.Confidence)
+	}
+	return sum / float64(len(results))
+}
+
+func TestEnergyEngineScoresAudio(t *testing.T) {
+	rng := rand.New(rand.NewSource(1))
+	eng := NewEnergyEngine()
+
+	if got := meanConfidence(t, eng, make([]byte, 2*16000)); got != 0 {
+		t.Errorf("digital silence: mean confidence %.2f, want 0", got)
+	}
+	// Background noise at about -50 dBFS, then a voiced tone at -20 dBFS on
+	// top of it, then the noise again.
+	if got := meanConfidence(t, eng, energyPCM(rng, 16000, 0, 0.005)); got > 0.2 {
+		t.Errorf("background noise: mean confidence %.2f, want below 0.2", got)
+	}
+	if got := meanConfidence(t, eng, energyPCM(rng, 16000, 0.1, 0.005)); got < 0.8 {
+		t.Errorf("tone over noise: mean confidence %.2f, want above 0.8", got)
+	}
+	if got := meanConfidence(t, eng, energyPCM(rng, 16000, 0, 0.005)); got > 0.2 {
+		t.Errorf("noise after the tone: mean confidence %.2f, want below 0.2", got)
+	}
+}
+
+func TestEnergyEngineAdaptsToSteadyNoise(t *testing.T) {
+	rng := rand.New(rand.NewSource(2))
+	eng := NewEnergyEngine()
+	// Loud broadband noise starts out as "speech" with a high zero-crossing
+	// rate; within seconds the floor adapts and it scores as silence.
+	first := meanConfidence(t, eng, energyPCM(rng, 8000, 0, 0.2))
+	if first >= 0.9 {
+		t.Errorf("first 0.5 s of loud noise: mean confidence %.2f, want the zero-crossing penalty below 0.9", first)
+	}
+	meanConfidence(t, eng, energyPCM(rng, 5*16000, 0, 0.2))
+	if got := meanConfidence(t, eng, energyPCM(rng, 16000, 0, 0.2)); got > 0.2 {
+		t.Errorf("noise after 5 s: mean confidence %.2f, want below 0.2", got)
+	}
+}
+
+func TestFrameFeatures(t *testing.T) {
+	frame := make([]float32, 512)
+	for i := range frame {
+		frame[i] = 0.5
+		if i%2 == 1 {
+			frame[i] = -0.5
+		}
+	}
+	levelDb, zcr := frameFeatures(frame)
+	if math.Abs(levelDb-20*math.Log10(0.5)) > 1e-6 {
+		t.Errorf("level = %.2f dBFS, want %.2f", levelDb, 20*math.Log10(0.5))
+	}
+	if want := 511.0 / 512; zcr != want {
+		t.Errorf("zcr = %v, want %v", zcr, want)
+	}
+}
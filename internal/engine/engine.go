@@ -1,17 +1,40 @@
 package engine
 
-import "fmt"
+import "github.com/nupi-ai/plugin-vad-local-silero/internal/errs"
 
-// ExpectedSampleRate is the audio sample rate (Hz) required by all VAD engines.
-// Both SileroEngine and StubEngine require 16kHz mono audio.
+// ExpectedSampleRate is the default audio sample rate (Hz) of all VAD engines.
+// Engines start configured for 16kHz mono audio; see SetSampleRate.
 const ExpectedSampleRate uint32 = 16000
 
+// TelephonySampleRate is the narrowband rate (Hz) supported alongside
+// ExpectedSampleRate. Silero VAD v5 handles it natively with a 256-sample window.
+const TelephonySampleRate uint32 = 8000
+
 // ErrWrongSampleRate is returned when audio has an unsupported sample rate.
-var ErrWrongSampleRate = fmt.Errorf("unsupported sample rate, expected %d Hz", ExpectedSampleRate)
+var ErrWrongSampleRate = errs.New(errs.ErrUnsupportedFormat, "unsupported sample rate, expected %d or %d Hz", ExpectedSampleRate, TelephonySampleRate)
+
+// SupportedSampleRate reports whether engines accept audio at sampleRate.
+// The server checks it before creating an engine, so invalid streams are
+// rejected without allocating inference resources.
+func SupportedSampleRate(sampleRate uint32) bool {
+	return sampleRate == ExpectedSampleRate || sampleRate == TelephonySampleRate
+}
+
+// DefaultHopMs is the default stride between Silero inference windows: the
+// full 32 ms window, so consecutive windows do not overlap.
+const DefaultHopMs = 32
+
+// SupportedHopMs reports whether hopMs is a valid Silero hop. Hops divide the
+// 32 ms window evenly so they are a whole number of samples at 8 and 16 kHz.
+func SupportedHopMs(hopMs int) bool {
+	return hopMs == 8 || hopMs == 16 || hopMs == DefaultHopMs
+}
 
 // Result holds the output of a single VAD inference frame.
 type Result struct {
-	IsSpeech   bool
+	// Confidence is the raw speech probability in [0, 1]. Engines do not
+	// threshold it: the server's boundary detector decides speech/silence
+	// per stream, with hysteresis between threshold and neg_threshold.
 	Confidence float32
 }
 
@@ -26,12 +49,50 @@ type Engine interface {
 	Reset() error
 	// Close releases resources.
 	Close() error
-	// FrameDurationMs returns the effective audio duration (in ms) covered
-	// by each inferred result. Used by the boundary detector for timing.
+	// FrameDurationMs returns the audio duration (in ms) covered by each
+	// inferred result, rounded down. It is informational; timing is derived
+	// from FrameSamples so windows that are not a whole number of
+	// milliseconds do not accumulate rounding error.
 	FrameDurationMs() int
-	// SetThreshold updates the speech probability threshold used to
-	// determine IsSpeech. This allows per-stream threshold overrides.
-	SetThreshold(threshold float64)
+	// FrameSamples returns the number of input samples (at SampleRate)
+	// covered by each inferred result.
+	FrameSamples() int
+	// SetSampleRate configures the engine for the stream's input rate. It
+	// must be called before the first ProcessChunk and clears any buffered
+	// audio. Returns ErrWrongSampleRate for rates the engine cannot handle.
+	SetSampleRate(sampleRate uint32) error
 	// SampleRate returns the audio sample rate (Hz) the engine expects.
 	SampleRate() uint32
 }
+
+// Windowed is implemented by engines whose inference window is longer than
+// their frame stride (Silero with a hop below 32 ms): each result scores
+// WindowSamples samples ending at the frame's end. For other engines the
+// window is the frame.
+type Windowed interface {
+	WindowSamples() int
+}
+
+// WindowSamples returns the samples each of eng's results is scored over.
+func WindowSamples(eng Engine) int {
+	if w, ok := eng.(Windowed); ok {
+		return w.WindowSamples()
+	}
+	return eng.FrameSamples()
+}
+
+// Buffered is implemented by engines that hold input audio between calls:
+// samples short of the next frame, plus the window history of overlapping
+// engines. The server bounds it per stream with max_buffered_bytes.
+type Buffered interface {
+	BufferedSamples() int
+}
+
+// BufferedSamples returns the input samples eng holds between calls, zero
+// for engines that do not implement Buffered.
+func BufferedSamples(eng Engine) int {
+	if b, ok := eng.(Buffered); ok {
+		return b.BufferedSamples()
+	}
+	return 0
+}
@@ -0,0 +1,126 @@
+package engine
+
+import "errors"
+
+// Ensemble runs two engines on the same audio and combines their speech
+// probabilities frame by frame. It reports the primary engine's frame
+// geometry: each primary frame is paired with the latest secondary frame that
+// ends at or before it (by sample position), so engines with different frame
+// sizes can be mixed. Until the secondary has produced its first frame, the
+// primary's probability is used for both.
+type Ensemble struct {
+	primary   Engine
+	secondary Engine
+	combine   func(primary, secondary float32) float32
+
+	// primaryFrames and secondaryFrames count the frames consumed since the
+	// last reset; pending holds secondary results not yet paired.
+	primaryFrames   int64
+	secondaryFrames int64
+	pending         []float32
+	last            float32
+	haveLast        bool
+}
+
+// NewEnsemble returns an engine that feeds every chunk to primary and
+// secondary and reports combine(p, s) for each primary frame. The ensemble
+// owns both engines: Close closes them.
+func NewEnsemble(primary, secondary Engine, combine func(primary, secondary float32) float32) *Ensemble {
+	return &Ensemble{primary: primary, secondary: secondary, combine: combine}
+}
+
+// ProcessChunk runs both engines on pcm and returns one combined Result per
+// primary frame.
+func (e *Ensemble) ProcessChunk(pcm []byte, sampleRate uint32) ([]Result, error) {
+	results, err := e.primary.ProcessChunk(pcm, sampleRate)
+	if err != nil {
+		return nil, err
+	}
+	secondary, err := e.secondary.ProcessChunk(pcm, sampleRate)
+	if err != nil {
+		return nil, err
+	}
+	for _, r := range secondary {
+		e.pending = append(e.pending, r.Confidence)
+	}
+
+	primarySamples := int64(e.primary.FrameSamples())
+	secondarySamples := int64(e.secondary.FrameSamples())
+	for i := range results {
+		end := (e.primaryFrames + 1) * primarySamples
+		n := 0
+		for n < len(e.pending) && (e.secondaryFrames+1)*secondarySamples <= end {
+			e.last = e.pending[n]
+			e.haveLast = true
+			e.secondaryFrames++
+			n++
+		}
+		e.pending = e.pending[n:]
+		e.primaryFrames++
+
+		s := results[i].Confidence
+		if e.haveLast {
+			s = e.last
+		}
+		results[i].Confidence = e.combine(results[i].Confidence, s)
+	}
+	return results, nil
+}
+
+// Reset resets both engines and the frame pairing.
+func (e *Ensemble) Reset() error {
+	e.clear()
+	return errors.Join(e.primary.Reset(), e.secondary.Reset())
+}
+
+// Close closes both engines.
+func (e *Ensemble) Close() error {
+	return errors.Join(e.primary.Close(), e.secondary.Close())
+}
+
+// FrameDurationMs returns the primary engine's frame duration.
+func (e *Ensemble) FrameDurationMs() int { return e.primary.FrameDurationMs() }
+
+// FrameSamples returns the primary engine's frame size.
+func (e *Ensemble) FrameSamples() int { return e.primary.FrameSamples() }
+
+// WindowSamples returns the primary engine's window.
+func (e *Ensemble) WindowSamples() int { return WindowSamples(e.primary) }
+
+// BufferedSamples returns the samples both engines hold.
+func (e *Ensemble) BufferedSamples() int {
+	return BufferedSamples(e.primary) + BufferedSamples(e.secondary)
+}
+
+// SetSampleRate configures both engines; it fails if either rejects the rate.
+func (e *Ensemble) SetSampleRate(sampleRate uint32) error {
+	e.clear()
+	if err := e.primary.SetSampleRate(sampleRate); err != nil {
+		return err
+	}
+	return e.secondary.SetSampleRate(sampleRate)
+}
+
+// SetStride sets the stride of both engines that implement Strided.
+func (e *Ensemble) SetStride(n int) {
+	SetStride(e.primary, n)
+	SetStride(e.secondary, n)
+}
+
+// SetFrameSkip sets the frame skipping of both engines that implement
+// FrameSkipper; each decides on its own scores.
+func (e *Ensemble) SetFrameSkip(fs FrameSkip) {
+	SetFrameSkip(e.primary, fs)
+	SetFrameSkip(e.secondary, fs)
+}
+
+// SampleRate returns the primary engine's sample rate.
+func (e *Ensemble) SampleRate() uint32 { return e.primary.SampleRate() }
+
+func (e *Ensemble) clear() {
+	e.primaryFrames = 0
+	e.secondaryFrames = 0
+	e.pending = e.pending[:0]
+	e.last = 0
+	e.haveLast = false
+}
@@ -0,0 +1,136 @@
+package engine
+
+import (
+	"errors"
+	"testing"
+)
+
+// seqEngine returns frame i's confidence as float32(i+1)/100 for frames of
+// frameSamples samples, buffering partial frames like the real engines.
+type seqEngine struct {
+	StubEngine
+	frameSamples int
+	buffered     int
+	frames       int
+	closed       bool
+}
+
+func (e *seqEngine) ProcessChunk(pcm []byte, sampleRate uint32) ([]Result, error) {
+	e.buffered += len(pcm) / 2
+	var results []Result
+	for e.buffered >= e.frameSamples {
+		e.buffered -= e.frameSamples
+		e.frames++
+		results = append(results, Result{Confidence: float32(e.frames) / 100})
+	}
+	return results, nil
+}
+
+func (e *seqEngine) FrameSamples() int { return e.frameSamples }
+
+func (e *seqEngine) BufferedSamples() int { return e.buffered }
+
+func (e *seqEngine) Reset() error {
+	e.buffered, e.frames = 0, 0
+	return nil
+}
+
+func (e *seqEngine) Close() error {
+	e.closed = true
+	return nil
+}
+
+func secondaryOnly(_, s float32) float32 { return s }
+
+func TestEnsemblePairsFramesBySamplePosition(t *testing.T) {
+	// Primary 320-sample frames, secondary 512-sample frames. Primary frame
+	// i ends at 320*(i+1); it pairs with the last secondary frame ending at
+	// or before that, or with itself before the secondary's first frame.
+	primary := &seqEngine{frameSamples: 320}
+	secondary := &seqEngine{frameSamples: 512}
+	ens := NewEnsemble(primary, secondary, secondaryOnly)
+
+	var got []float32
+	for i := 0; i < 4; i++ {
+		results, err := ens.ProcessChunk(make([]byte, 400), ExpectedSampleRate) // 200 samples
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, r := range results {
+			got = append(got, r.Confidence)
+		}
+	}
+	// 800 samples: primary frames end at 320 and 640; secondary at 512.
+	want := []float32{0.01, 0.01}
+	if len(got) != len(want) {
+		t.Fatalf("got %d results, want %d", len(got), len(want))
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Errorf("frame %d = %v, want %v", i, got[i], want[i])
+		}
+	}
+
+	results, err := ens.ProcessChunk(make([]byte, 1280), ExpectedSampleRate) // to 1440
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Primary frames end at 960 (secondary 1 at 512) and 1280 (secondary 2
+	// at 1024); the secondary frame ending at 1536 is held for later.
+	want = []float32{0.01, 0.02}
+	if len(results) != len(want) {
+		t.Fatalf("got %d results, want %d", len(results), len(want))
+	}
+	for i := range want {
+		if results[i].Confidence != want[i] {
+			t.Errorf("frame %d = %v, want %v", i+2, results[i].Confidence, want[i])
+		}
+	}
+	if ens.FrameSamples() != 320 {
+		t.Errorf("FrameSamples = %d, want the primary's 320", ens.FrameSamples())
+	}
+
+	if err := ens.Reset(); err != nil {
+		t.Fatal(err)
+	}
+	results, _ = ens.ProcessChunk(make([]byte, 640), ExpectedSampleRate)
+	if len(results) != 1 || results[0].Confidence != 0.01 {
+		t.Errorf("after Reset got %v, want the primary's first frame alone", results)
+	}
+
+	if err := ens.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if !primary.closed || !secondary.closed {
+		t.Error("Close must close both engines")
+	}
+}
+
+func TestEnsembleSampleRateAndErrors(t *testing.T) {
+	ens := NewEnsemble(NewStubEngine(), NewStubEngine(), func(p, s float32) float32 { return min(p, s) })
+	if err := ens.SetSampleRate(TelephonySampleRate); err != nil {
+		t.Fatal(err)
+	}
+	if ens.SampleRate() != TelephonySampleRate || ens.FrameSamples() != 160 {
+		t.Errorf("got rate %d, frame %d; want 8000, 160", ens.SampleRate(), ens.FrameSamples())
+	}
+	if err := ens.SetSampleRate(44100); !errors.Is(err, ErrWrongSampleRate) {
+		t.Errorf("SetSampleRate(44100) = %v, want ErrWrongSampleRate", err)
+	}
+	if _, err := ens.ProcessChunk(make([]byte, 3), TelephonySampleRate); err == nil {
+		t.Error("expected an error for odd-length PCM")
+	}
+}
+
+func TestEnsembleBufferedSamples(t *testing.T) {
+	primary := &seqEngine{frameSamples: 320}
+	secondary := &seqEngine{frameSamples: 512}
+	ens := NewEnsemble(primary, secondary, secondaryOnly)
+	if _, err := ens.ProcessChunk(make([]byte, 2*600), ExpectedSampleRate); err != nil {
+		t.Fatal(err)
+	}
+	// 600 samples: 280 held by the primary, 88 by the secondary.
+	if got := BufferedSamples(ens); got != 280+88 {
+		t.Errorf("BufferedSamples = %d, want %d", got, 280+88)
+	}
+}
@@ -0,0 +1,124 @@
+package engine
+
+import "time"
+
+// FaultEngine wraps an engine and injects failures into ProcessChunk, so
+// tests can drive the server's error paths, timeouts and backpressure
+// handling through an ordinary engine factory:
+//
+//	newEngine := func() engine.Engine {
+//		return engine.NewFaultEngine(engine.NewStubEngine(), engine.FailAfter(100, errBoom))
+//	}
+//
+// Faults are counted in frames the wrapped engine has scored since the last
+// Reset. This engine is for testing only.
+type FaultEngine struct {
+	Engine
+
+	delay time.Duration
+
+	failAfter int
+	failErr   error
+
+	malformAfter int
+	malformConf  float32
+	malform      bool
+
+	panicAfter int
+	panicValue any
+
+	frames int
+}
+
+// FaultOption configures a FaultEngine.
+type FaultOption func(*FaultEngine)
+
+// DelayChunks makes every ProcessChunk call block for d before running the
+// wrapped engine, as a slow or overloaded model would.
+func DelayChunks(d time.Duration) FaultOption {
+	return func(e *FaultEngine) {
+		e.delay = d
+	}
+}
+
+// FailAfter makes ProcessChunk return err, without running the wrapped
+// engine, on every call once frames frames have been scored; 0 fails the
+// first call.
+func FailAfter(frames int, err error) FaultOption {
+	return func(e *FaultEngine) {
+		e.failAfter = frames
+		e.failErr = err
+	}
+}
+
+// MalformAfter replaces the confidence of every frame after the first
+// frames with confidence, e.g. NaN or a value outside [0, 1], as a broken
+// model or custom engine would return.
+func MalformAfter(frames int, confidence float32) FaultOption {
+	return func(e *FaultEngine) {
+		e.malformAfter = frames
+		e.malformConf = confidence
+		e.malform = true
+	}
+}
+
+// PanicAfter makes ProcessChunk panic with value once frames frames have been
+// scored, as an engine bug would; 0 panics on the first call.
+func PanicAfter(frames int, value any) FaultOption {
+	return func(e *FaultEngine) {
+		e.panicAfter = frames
+		e.panicValue = value
+	}
+}
+
+// NewFaultEngine wraps inner with the given faults. Without options it
+// behaves exactly like inner.
+func NewFaultEngine(inner Engine, opts ...FaultOption) *FaultEngine {
+	e := &FaultEngine{Engine: inner}
+	for _, opt := range opts {
+		opt(e)
+	}
+	return e
+}
+
+// ProcessChunk runs the wrapped engine with the configured faults.
+func (e *FaultEngine) ProcessChunk(pcm []byte, sampleRate uint32) ([]Result, error) {
+	if e.delay > 0 {
+		time.Sleep(e.delay)
+	}
+	if e.panicValue != nil && e.frames >= e.panicAfter {
+		panic(e.panicValue)
+	}
+	if e.failErr != nil && e.frames >= e.failAfter {
+		return nil, e.failErr
+	}
+	results, err := e.Engine.ProcessChunk(pcm, sampleRate)
+	if err != nil {
+		return nil, err
+	}
+	for i := range results {
+		if e.malform && e.frames >= e.malformAfter {
+			results[i].Confidence = e.malformConf
+		}
+		e.frames++
+	}
+	return results, nil
+}
+
+// Reset resets the wrapped engine and restarts the frame count.
+func (e *FaultEngine) Reset() error {
+	e.frames = 0
+	return e.Engine.Reset()
+}
+
+// SetStride forwards to the wrapped engine.
+func (e *FaultEngine) SetStride(n int) { SetStride(e.Engine, n) }
+
+// SetFrameSkip forwards to the wrapped engine.
+func (e *FaultEngine) SetFrameSkip(fs FrameSkip) { SetFrameSkip(e.Engine, fs) }
+
+// WindowSamples forwards to the wrapped engine.
+func (e *FaultEngine) WindowSamples() int { return WindowSamples(e.Engine) }
+
+// BufferedSamples forwards to the wrapped engine.
+func (e *FaultEngine) BufferedSamples() int { return BufferedSamples(e.Engine) }
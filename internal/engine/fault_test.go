@@ -0,0 +1,81 @@
+package engine
+
+import (
+	"errors"
+	"math"
+	"testing"
+	"time"
+)
+
+func TestFaultEngineFailAfter(t *testing.T) {
+	boom := errors.New("boom")
+	eng := NewFaultEngine(NewStubEngine(), FailAfter(3, boom))
+	// Two chunks of two frames: the second call starts at 2 frames, under the
+	// limit, and the third at 4.
+	for i := range 2 {
+		if _, err := eng.ProcessChunk(make([]byte, 2*stubFrameBytes), 16000); err != nil {
+			t.Fatalf("chunk %d: %v", i, err)
+		}
+	}
+	if _, err := eng.ProcessChunk(make([]byte, stubFrameBytes), 16000); !errors.Is(err, boom) {
+		t.Fatalf("after 4 frames err = %v, want boom", err)
+	}
+
+	// Reset restarts the count.
+	if err := eng.Reset(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := eng.ProcessChunk(make([]byte, stubFrameBytes), 16000); err != nil {
+		t.Errorf("after Reset: %v", err)
+	}
+
+	if _, err := NewFaultEngine(NewStubEngine(), FailAfter(0, boom)).ProcessChunk(make([]byte, stubFrameBytes), 16000); !errors.Is(err, boom) {
+		t.Errorf("FailAfter(0) first call err = %v, want boom", err)
+	}
+}
+
+func TestFaultEnginePanicAfter(t *testing.T) {
+	eng := NewFaultEngine(NewStubEngine(), PanicAfter(2, "engine bug"))
+	if _, err := eng.ProcessChunk(make([]byte, 2*stubFrameBytes), 16000); err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		if r := recover(); r != "engine bug" {
+			t.Errorf("recovered %v, want the panic value", r)
+		}
+	}()
+	eng.ProcessChunk(make([]byte, stubFrameBytes), 16000)
+	t.Error("ProcessChunk returned after 2 frames, want a panic")
+}
+
+func TestFaultEngineMalformAfter(t *testing.T) {
+	nan := float32(math.NaN())
+	eng := NewFaultEngine(NewStubEngine(), MalformAfter(2, nan))
+	results, err := eng.ProcessChunk(make([]byte, 4*stubFrameBytes), 16000)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i, r := range results {
+		if malformed := math.IsNaN(float64(r.Confidence)); malformed != (i >= 2) {
+			t.Errorf("frame %d: confidence %v", i, r.Confidence)
+		}
+	}
+}
+
+func TestFaultEngineDelayAndPassThrough(t *testing.T) {
+	eng := NewFaultEngine(NewStubEngine(), DelayChunks(5*time.Millisecond))
+	start := time.Now()
+	results, err := eng.ProcessChunk(make([]byte, stubFrameBytes+2), 16000)
+	if elapsed := time.Since(start); elapsed < 5*time.Millisecond {
+		t.Errorf("ProcessChunk took %v, want at least the 5ms delay", elapsed)
+	}
+	if err != nil || len(results) != 1 || results[0].Confidence != StubSilenceConfidence {
+		t.Errorf("results = %v, %v; want the stub's", results, err)
+	}
+	if n := BufferedSamples(eng); n != 1 {
+		t.Errorf("BufferedSamples = %d, want the stub's 1", n)
+	}
+	if n := eng.FrameSamples(); n != 320 {
+		t.Errorf("FrameSamples = %d, want 320", n)
+	}
+}
@@ -0,0 +1,78 @@
+package engine
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+)
+
+// ErrChecksumMismatch is returned, wrapped, when a model or library does
+// not have its expected SHA-256 digest.
+var ErrChecksumMismatch = errors.New("checksum mismatch")
+
+// sileroModelSHA256 is the digest of the v5 model embedded by silero
+// builds; keep in sync with SILERO_MODEL_SHA256 in the Makefile.
+const sileroModelSHA256 = "2623a2953f6ff3d2c1e61740c6cdb7168133479b267dfef114a4a3cc5bdd788f"
+
+// Digests known at link time, set with -ldflags "-X
+// github.com/nupi-ai/plugin-vad-local-silero/internal/engine.<name>=<hex>".
+// ortLibSHA256 is that of the ONNX Runtime library the build is shipped
+// with (make build, release archives); modelV4SHA256 that of the embedded
+// v4 model (SILERO_V4_MODEL_SHA256). Empty when the build does not know it.
+var (
+	ortLibSHA256  string
+	modelV4SHA256 string
+)
+
+// PinnedORTLibSHA256 returns the digest of the ONNX Runtime library this
+// build is shipped with, or "" when it was not recorded at link time.
+func PinnedORTLibSHA256() string { return ortLibSHA256 }
+
+// PinnedModelSHA256 returns the known-good digest of the Silero model of
+// version and precision, or "" when none is pinned: the int8 model is
+// quantized locally, and the v4 digest is only known when the build
+// recorded it.
+func PinnedModelSHA256(version, precision string) string {
+	switch {
+	case precision == PrecisionInt8:
+		return ""
+	case version == ModelV4:
+		return modelV4SHA256
+	default:
+		return sileroModelSHA256
+	}
+}
+
+// EmbeddedModel returns the model of version and precision embedded at
+// build time, or nil when none is.
+func EmbeddedModel(version, precision string) []byte {
+	return embeddedModel(version, precision)
+}
+
+// FileSHA256 returns the hex SHA-256 digest of the file at path.
+func FileSHA256(path string) (string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+	h := sha256.New()
+	if _, err := io.Copy(h, f); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// CheckSHA256 returns an error wrapping ErrChecksumMismatch unless digest
+// matches want (hex, case-insensitive). what names the checked object in
+// the message.
+func CheckSHA256(what, digest, want string) error {
+	if strings.EqualFold(digest, want) {
+		return nil
+	}
+	return fmt.Errorf("engine: %s has SHA-256 %s, want %s: %w", what, digest, strings.ToLower(want), ErrChecksumMismatch)
+}
@@ -0,0 +1,158 @@
+package engine
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"sync/atomic"
+)
+
+// Silero VAD model generations selectable with SetModelVersion. They take
+// the same windows and sample rates but carry different RNN state, so the
+// engine binds different inputs for each.
+const (
+	ModelV4 = "v4"
+	ModelV5 = "v5"
+)
+
+// DefaultModelVersion is the generation used unless SetModelVersion selects
+// another.
+const DefaultModelVersion = ModelV5
+
+// Model weight precisions selectable with SetModelPrecision. The int8 model
+// has its weights quantized, roughly halving inference CPU on ARM at a small
+// accuracy cost; it takes the same float32 windows and state.
+const (
+	PrecisionFP32 = "fp32"
+	PrecisionInt8 = "int8"
+)
+
+// DefaultModelPrecision is the precision used unless SetModelPrecision
+// selects another.
+const DefaultModelPrecision = PrecisionFP32
+
+// modelVersion holds the generation set with SetModelVersion; nil means
+// DefaultModelVersion. modelPrecision likewise holds the precision set with
+// SetModelPrecision. modelOverride holds the model installed with SetModel;
+// nil means the embedded one.
+var (
+	modelVersion   atomic.Pointer[string]
+	modelPrecision atomic.Pointer[string]
+	modelOverride  atomic.Pointer[[]byte]
+)
+
+// SetModelVersion selects the Silero generation of native engines created
+// afterwards: ModelV5 (default) or ModelV4. A model installed with SetModel
+// must be of this generation. Engines already created keep theirs.
+func SetModelVersion(version string) error {
+	if version != ModelV4 && version != ModelV5 {
+		return fmt.Errorf("engine: model version must be %q or %q, got %q", ModelV4, ModelV5, version)
+	}
+	modelVersion.Store(&version)
+	return nil
+}
+
+// ModelVersion returns the Silero generation new native engines use.
+func ModelVersion() string {
+	if p := modelVersion.Load(); p != nil {
+		return *p
+	}
+	return DefaultModelVersion
+}
+
+// SetModelPrecision selects the weight precision of native engines created
+// afterwards: PrecisionFP32 (default) or PrecisionInt8. Only the v5 model
+// is embedded in int8; a model installed with SetModel must be of this
+// precision. Engines already created keep theirs.
+func SetModelPrecision(precision string) error {
+	if precision != PrecisionFP32 && precision != PrecisionInt8 {
+		return fmt.Errorf("engine: model precision must be %q or %q, got %q", PrecisionFP32, PrecisionInt8, precision)
+	}
+	modelPrecision.Store(&precision)
+	return nil
+}
+
+// ModelPrecision returns the weight precision new native engines use.
+func ModelPrecision() string {
+	if p := modelPrecision.Load(); p != nil {
+		return *p
+	}
+	return DefaultModelPrecision
+}
+
+// SetModel makes native engines created afterwards run data instead of the
+// model embedded at build time. Engines already created keep their model.
+// A nil or empty data restores the embedded model.
+func SetModel(data []byte) {
+	if len(data) == 0 {
+		modelOverride.Store(nil)
+		return
+	}
+	modelOverride.Store(&data)
+}
+
+// modelData returns the model new native engines are created from: the one
+// installed with SetModel, else the embedded model of version and
+// precision. It is empty when that model is not embedded.
+func modelData(version, precision string) []byte {
+	if p := modelOverride.Load(); p != nil {
+		return *p
+	}
+	return embeddedModel(version, precision)
+}
+
+// embeddedModel returns the model of version and precision embedded at
+// build time.
+func embeddedModel(version, precision string) []byte {
+	switch {
+	case precision == PrecisionInt8 && version == ModelV5:
+		return sileroInt8ModelData
+	case precision == PrecisionInt8:
+		return nil
+	case version == ModelV4:
+		return sileroV4ModelData
+	default:
+		return sileroModelData
+	}
+}
+
+// EmbeddedModelSHA256 returns the hex SHA-256 digest of the model embedded
+// at build time for the current ModelVersion and ModelPrecision, or "" when
+// none is (build without the silero tag, or without that model).
+func EmbeddedModelSHA256() string {
+	data := embeddedModel(ModelVersion(), ModelPrecision())
+	if len(data) == 0 {
+		return ""
+	}
+	return ModelSHA256(data)
+}
+
+// ModelSHA256 returns the hex SHA-256 digest of a model.
+func ModelSHA256(data []byte) string {
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+// LoadModelFile reads an ONNX model from path. When wantSHA256 is set, the
+// file's SHA-256 digest (hex, case-insensitive) must match it, so a
+// truncated or tampered file is never handed to ONNX Runtime; a mismatch
+// wraps ErrChecksumMismatch. It returns
+// the model and its digest; whether ONNX Runtime accepts the model is only
+// known once an engine is created from it.
+func LoadModelFile(path, wantSHA256 string) ([]byte, string, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, "", fmt.Errorf("engine: read model: %w", err)
+	}
+	if len(data) == 0 {
+		return nil, "", fmt.Errorf("engine: model file %s is empty", path)
+	}
+	digest := ModelSHA256(data)
+	if wantSHA256 != "" {
+		if err := CheckSHA256("model file "+path, digest, wantSHA256); err != nil {
+			return nil, digest, err
+		}
+	}
+	return data, digest, nil
+}
@@ -3,19 +3,34 @@
 package engine
 
 import (
-	_ "embed"
+	"embed"
 )
 
-// sileroModelData contains the Silero VAD v5 ONNX model embedded at build time.
+// sileroModels holds the Silero VAD ONNX models embedded at build time:
+// silero_vad.onnx (v5, required), silero_vad_v4.onnx (v4, optional) and
+// silero_vad_int8.onnx (v5 with int8 weights, optional).
 //
-// BUILD REQUIREMENT: The model file must exist at internal/engine/silero_vad.onnx
-// before compiling with -tags silero. Run these commands in order:
+// BUILD REQUIREMENT: The v5 model file must exist at
+// internal/engine/silero_vad.onnx before compiling with -tags silero. Run
+// these commands in order:
 //
 //	make download-model   # download model to models/ (one-time, ~2MB)
 //	make build            # prepare-model + compile with -tags silero
 //
-// If you see "pattern silero_vad.onnx: no matching files found" during build,
-// it means the model file is missing. Run "make download-model" first.
+// If you see "pattern silero_vad*.onnx: no matching files found" during
+// build, it means the model file is missing. Run "make download-model" first.
+// "make download-model-v4" adds the v4 model, which prepare-model then embeds
+// too; without it model_version "v4" needs model_path. Likewise "make
+// quantize-model" adds the int8 model for model_precision "int8".
 //
-//go:embed silero_vad.onnx
-var sileroModelData []byte
+//go:embed silero_vad*.onnx
+var sileroModels embed.FS
+
+// sileroModelData contains the Silero VAD v5 model, sileroV4ModelData the v4
+// model and sileroInt8ModelData the int8 v5 model; the optional ones are nil
+// when not embedded.
+var (
+	sileroModelData, _     = sileroModels.ReadFile("silero_vad.onnx")
+	sileroV4ModelData, _   = sileroModels.ReadFile("silero_vad_v4.onnx")
+	sileroInt8ModelData, _ = sileroModels.ReadFile("silero_vad_int8.onnx")
+)
@@ -2,5 +2,10 @@
 
 package engine
 
-// sileroModelData is nil when built without the silero tag.
-var sileroModelData []byte
+// sileroModelData, sileroV4ModelData and sileroInt8ModelData are nil when
+// built without the silero tag.
+var (
+	sileroModelData     []byte
+	sileroV4ModelData   []byte
+	sileroInt8ModelData []byte
+)
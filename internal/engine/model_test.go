@@ -0,0 +1,130 @@
+package engine
+
+import (
+	"bytes"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestLoadModelFile(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "model.onnx")
+	data := []byte("not really onnx")
+	if err := os.WriteFile(path, data, 0o600); err != nil {
+		t.Fatal(err)
+	}
+	digest := ModelSHA256(data)
+
+	got, gotDigest, err := LoadModelFile(path, "")
+	if err != nil || !bytes.Equal(got, data) || gotDigest != digest {
+		t.Fatalf("without checksum: %q, %s, %v", got, gotDigest, err)
+	}
+	if _, _, err := LoadModelFile(path, strings.ToUpper(digest)); err != nil {
+		t.Errorf("matching upper-case checksum: %v", err)
+	}
+	_, gotDigest, err = LoadModelFile(path, strings.Repeat("0", 64))
+	if err == nil || !strings.Contains(err.Error(), digest) || !errors.Is(err, ErrChecksumMismatch) {
+		t.Errorf("mismatched checksum: err = %v, want ErrChecksumMismatch naming the actual digest", err)
+	}
+	if gotDigest != digest {
+		t.Errorf("mismatched checksum: digest = %s, want %s", gotDigest, digest)
+	}
+
+	if _, _, err := LoadModelFile(filepath.Join(dir, "missing.onnx"), ""); err == nil {
+		t.Error("missing file: no error")
+	}
+	empty := filepath.Join(dir, "empty.onnx")
+	if err := os.WriteFile(empty, nil, 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if _, _, err := LoadModelFile(empty, ""); err == nil {
+		t.Error("empty file: no error")
+	}
+}
+
+func TestSetModel(t *testing.T) {
+	defer SetModel(nil)
+
+	external := []byte("external model")
+	SetModel(external)
+	if got := modelData(ModelV5, PrecisionFP32); !bytes.Equal(got, external) {
+		t.Errorf("modelData = %q after SetModel, want the external model", got)
+	}
+	SetModel(nil)
+	if !bytes.Equal(modelData(ModelV5, PrecisionFP32), sileroModelData) {
+		t.Error("SetModel(nil) did not restore the embedded model")
+	}
+}
+
+func TestSetModelVersion(t *testing.T) {
+	defer SetModelVersion(DefaultModelVersion)
+
+	if got := ModelVersion(); got != ModelV5 {
+		t.Errorf("default ModelVersion = %q, want %q", got, ModelV5)
+	}
+	if err := SetModelVersion("v3"); err == nil {
+		t.Error("SetModelVersion(v3): no error")
+	}
+	if err := SetModelVersion(ModelV4); err != nil {
+		t.Fatal(err)
+	}
+	if got := ModelVersion(); got != ModelV4 {
+		t.Errorf("ModelVersion = %q, want %q", got, ModelV4)
+	}
+	if !bytes.Equal(modelData(ModelV4, PrecisionFP32), sileroV4ModelData) {
+		t.Error("modelData(v4) is not the embedded v4 model")
+	}
+}
+
+func TestSetModelPrecision(t *testing.T) {
+	defer SetModelPrecision(DefaultModelPrecision)
+
+	if got := ModelPrecision(); got != PrecisionFP32 {
+		t.Errorf("default ModelPrecision = %q, want %q", got, PrecisionFP32)
+	}
+	if err := SetModelPrecision("fp16"); err == nil {
+		t.Error("SetModelPrecision(fp16): no error")
+	}
+	if err := SetModelPrecision(PrecisionInt8); err != nil {
+		t.Fatal(err)
+	}
+	if got := ModelPrecision(); got != PrecisionInt8 {
+		t.Errorf("ModelPrecision = %q, want %q", got, PrecisionInt8)
+	}
+	if !bytes.Equal(modelData(ModelV5, PrecisionInt8), sileroInt8ModelData) {
+		t.Error("modelData(v5, int8) is not the embedded int8 model")
+	}
+	if modelData(ModelV4, PrecisionInt8) != nil {
+		t.Error("modelData(v4, int8) is not empty: no int8 v4 model is embedded")
+	}
+}
+
+func TestFileSHA256(t *testing.T) {
+	data := []byte("onnxruntime")
+	path := filepath.Join(t.TempDir(), "libonnxruntime.so")
+	if err := os.WriteFile(path, data, 0o600); err != nil {
+		t.Fatal(err)
+	}
+	digest, err := FileSHA256(path)
+	if err != nil || digest != ModelSHA256(data) {
+		t.Fatalf("FileSHA256 = %s, %v; want %s", digest, err, ModelSHA256(data))
+	}
+	if err := CheckSHA256("library", digest, strings.ToUpper(digest)); err != nil {
+		t.Errorf("matching upper-case digest: %v", err)
+	}
+	if err := CheckSHA256("library", digest, strings.Repeat("0", 64)); !errors.Is(err, ErrChecksumMismatch) {
+		t.Errorf("mismatched digest: err = %v, want ErrChecksumMismatch", err)
+	}
+}
+
+func TestPinnedModelSHA256(t *testing.T) {
+	if got := PinnedModelSHA256(ModelV5, PrecisionFP32); len(got) != 64 {
+		t.Errorf("v5 fp32 digest = %q, want it pinned", got)
+	}
+	if got := PinnedModelSHA256(ModelV5, PrecisionInt8); got != "" {
+		t.Errorf("int8 digest = %q, want none: the model is quantized locally", got)
+	}
+}
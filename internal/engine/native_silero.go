@@ -5,7 +5,38 @@ package engine
 // NativeAvailable reports that the Silero VAD engine is compiled in.
 func NativeAvailable() bool { return true }
 
-// NewNativeEngine creates a SileroEngine with the given speech threshold.
-func NewNativeEngine(threshold float64) (Engine, error) {
-	return NewSileroEngine(threshold)
+// NewNativeEngine creates a SileroEngine with the given window hop (see
+// SileroEngine.SetHopMs).
+func NewNativeEngine(hopMs int) (Engine, error) {
+	eng, err := NewSileroEngine()
+	if err != nil {
+		return nil, err
+	}
+	return withHop(eng, hopMs)
 }
+
+// NewNativeEngineFromModel creates a SileroEngine running model, of the
+// Silero generation version, with the given window hop (see
+// NewSileroEngineFromModel).
+func NewNativeEngineFromModel(hopMs int, model []byte, version string) (Engine, error) {
+	eng, err := NewSileroEngineFromModel(model, version)
+	if err != nil {
+		return nil, err
+	}
+	return withHop(eng, hopMs)
+}
+
+// withHop sets eng's window hop, closing it on failure.
+func withHop(eng *SileroEngine, hopMs int) (Engine, error) {
+	if hopMs != DefaultHopMs {
+		if err := eng.SetHopMs(hopMs); err != nil {
+			eng.Close()
+			return nil, err
+		}
+	}
+	return eng, nil
+}
+
+// ORTLibPath returns the path of the ONNX Runtime library native engines
+// load (see resolveORTLibPath).
+func ORTLibPath() (string, error) { return resolveORTLibPath() }
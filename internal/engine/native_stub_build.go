@@ -2,15 +2,25 @@
 
 package engine
 
-import "errors"
+import "github.com/nupi-ai/plugin-vad-local-silero/internal/errs"
 
 // ErrNativeUnavailable indicates the Silero engine is not compiled in.
-var ErrNativeUnavailable = errors.New("engine: silero backend not available (build without -tags silero)")
+var ErrNativeUnavailable = errs.New(errs.ErrEngineUnavailable, "engine: silero backend not available (build without -tags silero)")
 
 // NativeAvailable reports that no native engine is compiled in.
 func NativeAvailable() bool { return false }
 
 // NewNativeEngine returns an error when built without the silero tag.
-func NewNativeEngine(_ float64) (Engine, error) {
+func NewNativeEngine(_ int) (Engine, error) {
 	return nil, ErrNativeUnavailable
 }
+
+// NewNativeEngineFromModel returns an error when built without the silero
+// tag.
+func NewNativeEngineFromModel(_ int, _ []byte, _ string) (Engine, error) {
+	return nil, ErrNativeUnavailable
+}
+
+// ORTLibPath returns ErrNativeUnavailable: no ONNX Runtime library is
+// loaded without the silero tag.
+func ORTLibPath() (string, error) { return "", ErrNativeUnavailable }
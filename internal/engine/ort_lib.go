@@ -7,6 +7,8 @@ import (
 	"os"
 	"path/filepath"
 	"runtime"
+	"slices"
+	"strings"
 )
 
 // resolveORTLibPath returns the path to the ONNX Runtime shared library.
@@ -14,8 +16,13 @@ import (
 //  1. NUPI_ORT_LIB_PATH environment variable (explicit override)
 //  2. lib/<goos>-<goarch>/ relative to executable
 //  3. ../lib/<goos>-<goarch>/ relative to executable (bin/ layout)
-//  4. lib/<goos>-<goarch>/ relative to CWD (only if NUPI_DEV_MODE=1)
-//  5. ../lib/<goos>-<goarch>/ relative to CWD (only if NUPI_DEV_MODE=1)
+//  4. ../../lib/<goos>-<goarch>/ relative to executable (fat layout:
+//     bin/<goos>-<goarch>/ binaries next to lib/<goos>-<goarch>/ libraries)
+//  5. lib/<goos>-<goarch>/ relative to CWD (only if NUPI_DEV_MODE=1)
+//  6. ../lib/<goos>-<goarch>/ relative to CWD (only if NUPI_DEV_MODE=1)
+//
+// In each directory the versioned filename (see ortLibFilenames) is tried
+// before the unversioned one.
 //
 // CWD-based lookup is disabled by default to prevent shared library hijacking.
 // Set NUPI_DEV_MODE=1 during development to enable CWD fallback.
@@ -32,34 +39,124 @@ func resolveORTLibPath() (string, error) {
 		return envPath, nil
 	}
 
-	filename := ortLibFilename()
-	libRel := filepath.Join("lib", runtime.GOOS+"-"+runtime.GOARCH, filename)
-	libRelParent := filepath.Join("..", "lib", runtime.GOOS+"-"+runtime.GOARCH, filename)
+	platform := runtime.GOOS + "-" + runtime.GOARCH
+	var searched []string // lib/ directories looked in
 
-	// 2-3. Try relative to executable location.
+	// 2-4. Try relative to executable location.
 	if exePath, err := os.Executable(); err == nil {
 		exeDir := filepath.Dir(exePath)
-		for _, rel := range []string{libRel, libRelParent} {
-			path := filepath.Join(exeDir, rel)
-			if _, err := os.Stat(path); err == nil {
+		for _, rel := range []string{"lib", filepath.Join("..", "lib"), filepath.Join("..", "..", "lib")} {
+			libDir := filepath.Join(exeDir, rel)
+			if path, ok := findORTLib(filepath.Join(libDir, platform)); ok {
 				return path, nil
 			}
+			searched = append(searched, libDir)
 		}
 	}
 
-	// 4-5. Fall back to CWD only in dev mode (prevents shared library hijacking).
+	// 5-6. Fall back to CWD only in dev mode (prevents shared library hijacking).
 	if os.Getenv("NUPI_DEV_MODE") == "1" {
 		if dir, err := os.Getwd(); err == nil {
-			for _, rel := range []string{libRel, libRelParent} {
-				path := filepath.Join(dir, rel)
-				if _, err := os.Stat(path); err == nil {
+			for _, rel := range []string{"lib", filepath.Join("..", "lib")} {
+				libDir := filepath.Join(dir, rel)
+				if path, ok := findORTLib(filepath.Join(libDir, platform)); ok {
 					return path, nil
 				}
+				searched = append(searched, libDir)
 			}
 		}
 	}
 
-	return "", fmt.Errorf("ort: shared library not found; searched lib/<os>-<arch>/%s relative to executable (set NUPI_ORT_LIB_PATH to override, or NUPI_DEV_MODE=1 to enable CWD lookup)", filename)
+	names := strings.Join(ortLibFilenames(), " or ")
+	for _, libDir := range searched {
+		dir := filepath.Join(libDir, platform)
+		if found := ortLibsIn(dir); len(found) > 0 {
+			return "", fmt.Errorf("ort: %s has %s but not %s: this build is pinned to ONNX Runtime %s (run make download-ort, or set NUPI_ORT_LIB_PATH)", dir, strings.Join(found, ", "), names, ortVersion)
+		}
+	}
+	if others := bundledPlatforms(searched, platform); len(others) > 0 {
+		return "", fmt.Errorf("ort: no shared library for %s; the bundled lib/ has %s only (install the %s archive, or set NUPI_ORT_LIB_PATH)", platform, strings.Join(others, ", "), platform)
+	}
+	return "", fmt.Errorf("ort: shared library not found; searched lib/<os>-<arch>/%s relative to executable (set NUPI_ORT_LIB_PATH to override, or NUPI_DEV_MODE=1 to enable CWD lookup)", names)
+}
+
+// findORTLib returns the first of ortLibFilenames present in dir.
+func findORTLib(dir string) (string, bool) {
+	for _, name := range ortLibFilenames() {
+		path := filepath.Join(dir, name)
+		if info, err := os.Stat(path); err == nil && !info.IsDir() {
+			return path, true
+		}
+	}
+	return "", false
+}
+
+// bundledPlatforms returns the <os>-<arch> directories other than platform
+// holding an ORT library in any of libDirs, for the diagnostic of a fat
+// layout that lacks the running platform.
+func bundledPlatforms(libDirs []string, platform string) []string {
+	var platforms []string
+	for _, libDir := range libDirs {
+		entries, err := os.ReadDir(libDir)
+		if err != nil {
+			continue
+		}
+		for _, entry := range entries {
+			name := entry.Name()
+			if !entry.IsDir() || name == platform || slices.Contains(platforms, name) {
+				continue
+			}
+			if len(ortLibsIn(filepath.Join(libDir, name))) > 0 {
+				platforms = append(platforms, name)
+			}
+		}
+	}
+	slices.Sort(platforms)
+	return platforms
+}
+
+// ortLibsIn returns the names of the ONNX Runtime libraries in dir, of
+// any version.
+func ortLibsIn(dir string) []string {
+	matches, _ := filepath.Glob(filepath.Join(dir, "*onnxruntime*"))
+	for i, m := range matches {
+		matches[i] = filepath.Base(m)
+	}
+	return matches
+}
+
+// ortLibFilenames returns the library filenames looked up, in order: the
+// name pinned to ortVersion (see ORTLibFilename), then the unversioned
+// ortLibFilename.
+func ortLibFilenames() []string {
+	pinned := ORTLibFilename(runtime.GOOS, ortVersion)
+	if pinned == ortLibFilename() {
+		return []string{pinned}
+	}
+	return []string{pinned, ortLibFilename()}
+}
+
+// checkORTVersion returns an error unless version, reported by the library
+// loaded from path, is of ortVersion's major.minor release: ONNX Runtime
+// patch releases are compatible, minor releases change the C API.
+func checkORTVersion(version, path string) error {
+	if version == "" {
+		return fmt.Errorf("ort: %s does not report its version; this build requires ONNX Runtime %s", path, ortVersion)
+	}
+	if majorMinor(version) != majorMinor(ortVersion) {
+		return fmt.Errorf("ort: %s is ONNX Runtime %s, but this build requires %s.x (built against %s); install the matching library (make download-ort) or rebuild with ORT_VERSION=%s",
+			path, version, majorMinor(ortVersion), ortVersion, version)
+	}
+	return nil
+}
+
+// majorMinor returns the "major.minor" prefix of a version string.
+func majorMinor(version string) string {
+	parts := strings.SplitN(version, ".", 3)
+	if len(parts) < 2 {
+		return version
+	}
+	return parts[0] + "." + parts[1]
 }
 
 // ortLibFilename returns the platform-specific ONNX Runtime library filename.
@@ -6,6 +6,7 @@ import (
 	"os"
 	"path/filepath"
 	"runtime"
+	"strings"
 	"testing"
 )
 
@@ -173,3 +174,82 @@ func TestResolveORTLibPath_CwdIgnoredWithoutDevMode(t *testing.T) {
 }
 
 // TestOrtLibFilename is in silero_test.go
+
+// devModeLib creates lib/<platform>/ files named names in a temp CWD with dev
+// mode on, so resolveORTLibPath sees them through the CWD fallback.
+func devModeLib(t *testing.T, platform string, names ...string) string {
+	t.Helper()
+	dir := t.TempDir()
+	libDir := filepath.Join(dir, "lib", platform)
+	if err := os.MkdirAll(libDir, 0755); err != nil {
+		t.Fatal(err)
+	}
+	for _, name := range names {
+		if err := os.WriteFile(filepath.Join(libDir, name), []byte("fake"), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	t.Chdir(dir)
+	t.Setenv("NUPI_ORT_LIB_PATH", "")
+	t.Setenv("NUPI_DEV_MODE", "1")
+	return libDir
+}
+
+func TestResolveORTLibPath_PrefersVersioned(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("Windows DLLs are not versioned")
+	}
+	names := ortLibFilenames()
+	if len(names) != 2 || !strings.Contains(names[0], ortVersion) || names[1] != ortLibFilename() {
+		t.Fatalf("ortLibFilenames() = %v, want the %s name, then the unversioned one", names, ortVersion)
+	}
+	devModeLib(t, runtime.GOOS+"-"+runtime.GOARCH, names...)
+	path, err := resolveORTLibPath()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if filepath.Base(path) != names[0] {
+		t.Errorf("resolved %s, want the versioned %s", path, names[0])
+	}
+}
+
+func TestResolveORTLibPath_OtherVersion(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("Windows DLLs are not versioned")
+	}
+	devModeLib(t, runtime.GOOS+"-"+runtime.GOARCH, "libonnxruntime.so.1.0.0")
+	_, err := resolveORTLibPath()
+	if err == nil || !strings.Contains(err.Error(), "libonnxruntime.so.1.0.0") || !strings.Contains(err.Error(), ortVersion) {
+		t.Errorf("err = %v, want the library found and the pinned version", err)
+	}
+}
+
+func TestResolveORTLibPath_FatLayoutWithoutPlatform(t *testing.T) {
+	devModeLib(t, "plan9-mips", "libonnxruntime.so")
+	_, err := resolveORTLibPath()
+	if err == nil || !strings.Contains(err.Error(), "has plan9-mips only") {
+		t.Errorf("err = %v, want the bundled platforms listed", err)
+	}
+}
+
+func TestCheckORTVersion(t *testing.T) {
+	mm := majorMinor(ortVersion)
+	for _, tt := range []struct {
+		version string
+		ok      bool
+	}{
+		{ortVersion, true},
+		{mm + ".9", true},
+		{"0.1.0", false},
+		{mm, true},
+		{"", false},
+	} {
+		err := checkORTVersion(tt.version, "lib/libonnxruntime.so")
+		if (err == nil) != tt.ok {
+			t.Errorf("checkORTVersion(%q) = %v, want ok %v", tt.version, err, tt.ok)
+		}
+		if err != nil && !strings.Contains(err.Error(), ortVersion) {
+			t.Errorf("checkORTVersion(%q) error %q does not name the required version", tt.version, err)
+		}
+	}
+}
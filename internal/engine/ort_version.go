@@ -0,0 +1,28 @@
+package engine
+
+// ortVersion is the ONNX Runtime release this build is pinned to. The
+// library is looked up under its versioned name first, and a library of
+// another major.minor release is refused when ORT is initialized. Builds
+// bundling another release set it with
+// -ldflags "-X github.com/nupi-ai/plugin-vad-local-silero/internal/engine.ortVersion=1.x.y".
+var ortVersion = "1.23.0"
+
+// ORTVersion returns the ONNX Runtime release the silero engine of this
+// build is pinned to. It is set in every build, so stub builds can fetch
+// the library a silero build needs.
+func ORTVersion() string { return ortVersion }
+
+// ORTLibFilename returns the name the ONNX Runtime library of version is
+// installed as in lib/<goos>-<goarch>/: libonnxruntime.so.1.23.0,
+// libonnxruntime.1.23.0.dylib, or onnxruntime.dll as Windows DLLs are not
+// versioned.
+func ORTLibFilename(goos, version string) string {
+	switch goos {
+	case "darwin":
+		return "libonnxruntime." + version + ".dylib"
+	case "windows":
+		return "onnxruntime.dll"
+	default:
+		return "libonnxruntime.so." + version
+	}
+}
@@ -0,0 +1,41 @@
+package engine
+
+import "slices"
+
+// appendS16LE appends PCM s16le samples to dst as float32 normalized to
+// [-1, 1]. Divides by 32768 (not 32767) so that the full int16 range
+// [-32768, 32767] maps to [-1.0, ~0.99997]. A trailing odd byte is ignored.
+//
+// On little-endian platforms a 2-byte aligned pcm is read in place as
+// int16 samples (see int16View); otherwise the bytes are decoded one
+// sample at a time. Both produce the same values.
+func appendS16LE(dst []float32, pcm []byte) []float32 {
+	if samples, ok := int16View(pcm); ok {
+		return appendInt16(dst, samples)
+	}
+	return appendS16LEBytes(dst, pcm)
+}
+
+// appendInt16 appends samples to dst as float32 normalized to [-1, 1].
+func appendInt16(dst []float32, samples []int16) []float32 {
+	n := len(dst)
+	dst = slices.Grow(dst, len(samples))[:n+len(samples)]
+	out := dst[n:]
+	for i, v := range samples {
+		out[i] = float32(v) / 32768.0
+	}
+	return dst
+}
+
+// appendS16LEBytes is the portable appendS16LE, for any alignment and byte
+// order.
+func appendS16LEBytes(dst []float32, pcm []byte) []float32 {
+	n := len(dst)
+	count := len(pcm) / 2
+	dst = slices.Grow(dst, count)[:n+count]
+	out := dst[n:]
+	for i := range out {
+		out[i] = float32(int16(uint16(pcm[2*i])|uint16(pcm[2*i+1])<<8)) / 32768.0
+	}
+	return dst
+}
@@ -0,0 +1,9 @@
+//go:build !(386 || amd64 || arm || arm64 || loong64 || mips64le || mipsle || ppc64le || riscv64 || wasm) || purego
+
+package engine
+
+// int16View never reinterprets pcm on big-endian platforms or with the
+// purego tag; appendS16LE decodes the bytes instead.
+func int16View(pcm []byte) ([]int16, bool) {
+	return nil, false
+}
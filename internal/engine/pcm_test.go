@@ -0,0 +1,131 @@
+package engine
+
+import (
+	"math/rand/v2"
+	"slices"
+	"testing"
+)
+
+// randomPCM returns n bytes of random s16le PCM.
+func randomPCM(n int) []byte {
+	r := rand.New(rand.NewPCG(1, 2))
+	pcm := make([]byte, n)
+	for i := range pcm {
+		pcm[i] = byte(r.Uint32())
+	}
+	return pcm
+}
+
+func TestAppendS16LEFastPathMatchesPortable(t *testing.T) {
+	buf := randomPCM(4097)
+	for _, tc := range []struct {
+		name string
+		pcm  []byte
+	}{
+		{"aligned", buf[:4096]},
+		{"misaligned", buf[1:]},
+		{"odd length", buf[:4095]},
+		{"one byte", buf[:1]},
+		{"empty", nil},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			prefix := []float32{0.5}
+			got := appendS16LE(slices.Clone(prefix), tc.pcm)
+			want := appendS16LEBytes(slices.Clone(prefix), tc.pcm)
+			if !slices.Equal(got, want) {
+				t.Fatalf("appendS16LE differs from the portable decode")
+			}
+			if len(got) != 1+len(tc.pcm)/2 || got[0] != 0.5 {
+				t.Errorf("len %d, first %v; want the prefix kept and %d samples", len(got), got[0], len(tc.pcm)/2)
+			}
+		})
+	}
+
+	// The full int16 range, in both byte positions.
+	extremes := []byte{0x00, 0x80, 0xFF, 0x7F, 0xFF, 0xFF, 0x01, 0x00}
+	want := []float32{-1, 32767.0 / 32768.0, -1.0 / 32768.0, 1.0 / 32768.0}
+	if got := appendS16LE(nil, extremes); !slices.Equal(got, want) {
+		t.Errorf("appendS16LE(extremes) = %v, want %v", got, want)
+	}
+}
+
+func TestInt16ViewAlignment(t *testing.T) {
+	buf := make([]byte, 8)
+	aligned, ok := int16View(buf)
+	if !ok {
+		t.Skip("no in-place decode on this platform")
+	}
+	if len(aligned) != 4 {
+		t.Errorf("len = %d, want 4", len(aligned))
+	}
+	if _, ok := int16View(buf[1:]); ok {
+		t.Error("misaligned view accepted")
+	}
+	if _, ok := int16View(buf[:1]); ok {
+		t.Error("view of a partial sample accepted")
+	}
+}
+
+// Chunk sizes benchmarked: 20 ms at 16 kHz, a Silero window and 1 s.
+var benchChunks = []struct {
+	name  string
+	bytes int
+}{
+	{"20ms", 640},
+	{"32ms", 1024},
+	{"1s", 32000},
+}
+
+func BenchmarkAppendS16LE(b *testing.B) {
+	for _, c := range benchChunks {
+		buf := randomPCM(c.bytes + 1)
+		dst := make([]float32, 0, c.bytes/2)
+		for _, path := range []struct {
+			name   string
+			decode func([]float32, []byte) []float32
+			pcm    []byte
+		}{
+			{"fast", appendS16LE, buf[:c.bytes]},
+			{"misaligned", appendS16LE, buf[1:]},
+			{"portable", appendS16LEBytes, buf[:c.bytes]},
+		} {
+			b.Run(c.name+"/"+path.name, func(b *testing.B) {
+				b.SetBytes(int64(c.bytes))
+				for b.Loop() {
+					dst = path.decode(dst[:0], path.pcm)
+				}
+			})
+		}
+	}
+}
+
+func BenchmarkWindowBufferWrite(b *testing.B) {
+	for _, c := range benchChunks {
+		b.Run(c.name, func(b *testing.B) {
+			buf := newWindowBuffer(512, 512)
+			pcm := randomPCM(c.bytes)
+			b.SetBytes(int64(c.bytes))
+			for b.Loop() {
+				buf.write(pcm)
+				for buf.next() != nil {
+				}
+			}
+		})
+	}
+}
+
+func BenchmarkEnergyProcessChunk(b *testing.B) {
+	for _, c := range benchChunks {
+		b.Run(c.name, func(b *testing.B) {
+			eng := NewEnergyEngine()
+			defer eng.Close()
+			pcm := randomPCM(c.bytes)
+			b.SetBytes(int64(c.bytes))
+			for b.Loop() {
+				if _, err := eng.ProcessChunk(pcm, ExpectedSampleRate); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
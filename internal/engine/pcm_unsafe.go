@@ -0,0 +1,15 @@
+//go:build (386 || amd64 || arm || arm64 || loong64 || mips64le || mipsle || ppc64le || riscv64 || wasm) && !purego
+
+package engine
+
+import "unsafe"
+
+// int16View returns pcm's samples as an []int16 sharing its memory, when pcm
+// starts on a 2-byte boundary. This file is built for little-endian
+// platforms only, where s16le is the native int16 layout.
+func int16View(pcm []byte) ([]int16, bool) {
+	if len(pcm) < 2 || uintptr(unsafe.Pointer(unsafe.SliceData(pcm)))%2 != 0 {
+		return nil, false
+	}
+	return unsafe.Slice((*int16)(unsafe.Pointer(unsafe.SliceData(pcm))), len(pcm)/2), true
+}
@@ -0,0 +1,189 @@
+package engine
+
+import (
+	"runtime"
+	"runtime/debug"
+	"sync"
+)
+
+// Pool keeps up to a fixed number of idle engines so streams do not pay for
+// engine creation (ONNX session, tensors) on their first audio. Engines
+// handed out by Get return themselves to the pool on Close, after Reset; the
+// pool only grows back to its size, extra engines are closed.
+type Pool struct {
+	newEngine func() Engine
+	size      int
+
+	mu     sync.Mutex
+	idle   []Engine
+	closed bool
+	// panicValue and panicStack record the first panic Warm recovered
+	// from newEngine.
+	panicValue any
+	panicStack []byte
+}
+
+// NewPool returns an empty pool of size engines created with newEngine.
+func NewPool(newEngine func() Engine, size int) *Pool {
+	return &Pool{newEngine: newEngine, size: size}
+}
+
+// Warm creates engines in parallel until the pool holds size idle engines,
+// and returns how many it added. Engines the factory fails to create (nil)
+// are skipped; streams will create them on demand. So are engines whose
+// creation panics (see WarmPanic).
+func (p *Pool) Warm() int {
+	p.mu.Lock()
+	missing := p.size - len(p.idle)
+	p.mu.Unlock()
+	if missing <= 0 {
+		return 0
+	}
+
+	created := make(chan Engine, missing)
+	jobs := make(chan struct{}, missing)
+	for i := 0; i < missing; i++ {
+		jobs <- struct{}{}
+	}
+	close(jobs)
+	var wg sync.WaitGroup
+	for w := 0; w < min(missing, runtime.GOMAXPROCS(0)); w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for range jobs {
+				if eng := p.create(); eng != nil {
+					created <- eng
+				}
+			}
+		}()
+	}
+	wg.Wait()
+	close(created)
+
+	added := 0
+	for eng := range created {
+		if p.put(eng) {
+			added++
+		}
+	}
+	return added
+}
+
+// create calls newEngine on a Warm goroutine. No RPC recovery runs above
+// those goroutines, so a panic is recovered as a failed creation instead of
+// crashing the process, and recorded for WarmPanic.
+func (p *Pool) create() (eng Engine) {
+	defer func() {
+		if r := recover(); r != nil {
+			eng = nil
+			p.mu.Lock()
+			if p.panicValue == nil {
+				p.panicValue, p.panicStack = r, debug.Stack()
+			}
+			p.mu.Unlock()
+		}
+	}()
+	return p.newEngine()
+}
+
+// WarmPanic returns the first panic Warm recovered from the engine factory
+// and its stack; value is nil if there was none.
+func (p *Pool) WarmPanic() (value any, stack []byte) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.panicValue, p.panicStack
+}
+
+// Get returns an idle engine, or a new one when the pool is empty. The
+// result is nil if the factory fails. Closing the engine returns it to the
+// pool.
+func (p *Pool) Get() Engine {
+	p.mu.Lock()
+	var eng Engine
+	if n := len(p.idle); n > 0 {
+		eng = p.idle[n-1]
+		p.idle = p.idle[:n-1]
+	}
+	p.mu.Unlock()
+	if eng == nil {
+		eng = p.newEngine()
+		if eng == nil {
+			return nil
+		}
+	}
+	return &pooledEngine{Engine: eng, pool: p}
+}
+
+// Idle returns the number of idle engines.
+func (p *Pool) Idle() int {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return len(p.idle)
+}
+
+// Close closes the idle engines. Engines still in use are closed when they
+// are released.
+func (p *Pool) Close() {
+	p.mu.Lock()
+	idle := p.idle
+	p.idle = nil
+	p.closed = true
+	p.mu.Unlock()
+	for _, eng := range idle {
+		eng.Close()
+	}
+}
+
+// put adds eng to the idle list, or reports false when the pool is full or
+// closed.
+func (p *Pool) put(eng Engine) bool {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.closed || len(p.idle) >= p.size {
+		return false
+	}
+	p.idle = append(p.idle, eng)
+	return true
+}
+
+// release resets eng and returns it to the pool, closing it if it cannot be
+// reused. Reset keeps the stride and frame skipping, which are per-stream
+// settings, so they are restored to their defaults first.
+func (p *Pool) release(eng Engine) error {
+	SetStride(eng, 1)
+	SetFrameSkip(eng, FrameSkip{})
+	if err := eng.Reset(); err != nil {
+		return eng.Close()
+	}
+	if !p.put(eng) {
+		return eng.Close()
+	}
+	return nil
+}
+
+// pooledEngine returns its engine to the pool on Close.
+type pooledEngine struct {
+	Engine
+	pool *Pool
+	once sync.Once
+}
+
+func (e *pooledEngine) Close() error {
+	var err error
+	e.once.Do(func() { err = e.pool.release(e.Engine) })
+	return err
+}
+
+// WindowSamples forwards to the pooled engine, which the embedded interface
+// would hide from a Windowed type assertion.
+func (e *pooledEngine) WindowSamples() int { return WindowSamples(e.Engine) }
+
+// BufferedSamples forwards to the pooled engine, like WindowSamples.
+func (e *pooledEngine) BufferedSamples() int { return BufferedSamples(e.Engine) }
+
+// SetStride forwards to the pooled engine, like WindowSamples.
+func (e *pooledEngine) SetStride(n int) { SetStride(e.Engine, n) }
+
+// SetFrameSkip forwards to the pooled engine, like WindowSamples.
+func (e *pooledEngine) SetFrameSkip(fs FrameSkip) { SetFrameSkip(e.Engine, fs) }
@@ -0,0 +1,104 @@
+package engine
+
+import (
+	"sync/atomic"
+	"testing"
+)
+
+func TestPoolReusesWarmEngines(t *testing.T) {
+	var created atomic.Int32
+	pool := NewPool(func() Engine {
+		created.Add(1)
+		return NewStubEngine()
+	}, 3)
+	defer pool.Close()
+
+	if n := pool.Warm(); n != 3 || pool.Idle() != 3 {
+		t.Fatalf("Warm = %d, idle = %d, want 3", n, pool.Idle())
+	}
+	if n := pool.Warm(); n != 0 {
+		t.Errorf("second Warm added %d engines", n)
+	}
+
+	// A used engine comes back reset.
+	eng := pool.Get()
+	for i := 0; i <= StubToggleInterval; i++ {
+		if _, err := eng.ProcessChunk(make([]byte, stubFrameBytes), 16000); err != nil {
+			t.Fatal(err)
+		}
+	}
+	eng.Close()
+	eng.Close() // double Close must not return it twice
+	if pool.Idle() != 3 {
+		t.Fatalf("idle after release = %d, want 3", pool.Idle())
+	}
+
+	// Draining the pool creates engines on demand; only size of them are
+	// kept when released.
+	var held []Engine
+	for i := 0; i < 5; i++ {
+		held = append(held, pool.Get())
+	}
+	if got := created.Load(); got != 5 {
+		t.Errorf("engines created = %d, want 5 (3 warm + 2 on demand)", got)
+	}
+	for _, e := range held {
+		e.Close()
+	}
+	if pool.Idle() != 3 {
+		t.Errorf("idle after burst = %d, want 3", pool.Idle())
+	}
+
+	res, err := pool.Get().ProcessChunk(make([]byte, stubFrameBytes), 16000)
+	if err != nil || len(res) != 1 || res[0].Confidence != StubSilenceConfidence {
+		t.Errorf("pooled engine not reset: %v, %v", res, err)
+	}
+}
+
+func TestPoolSkipsFailedEngines(t *testing.T) {
+	pool := NewPool(func() Engine { return nil }, 2)
+	if n := pool.Warm(); n != 0 {
+		t.Errorf("Warm = %d, want 0", n)
+	}
+	if eng := pool.Get(); eng != nil {
+		t.Errorf("Get = %v, want nil", eng)
+	}
+}
+
+func TestPoolRecoversFactoryPanics(t *testing.T) {
+	var calls atomic.Int32
+	pool := NewPool(func() Engine {
+		if calls.Add(1) == 1 {
+			panic("factory bug")
+		}
+		return NewStubEngine()
+	}, 2)
+	defer pool.Close()
+	if n := pool.Warm(); n != 1 {
+		t.Errorf("Warm = %d, want 1", n)
+	}
+	if p, stack := pool.WarmPanic(); p != "factory bug" || len(stack) == 0 {
+		t.Errorf("WarmPanic = %v with %d bytes of stack, want the panic value and its stack", p, len(stack))
+	}
+}
+
+func TestPoolForwardsAndResetsStride(t *testing.T) {
+	stub := NewStubEngine()
+	pool := NewPool(func() Engine { return stub }, 1)
+	defer pool.Close()
+	pool.Warm()
+
+	eng := pool.Get()
+	if !SetStride(eng, 2) || !SetFrameSkip(eng, FrameSkip{Stride: 4, After: 1, Low: 0.1, High: 0.9}) {
+		t.Fatal("pooled engine hides Strided or FrameSkipper")
+	}
+	if stub.stride.n != 2 || stub.stride.fs.Stride != 4 {
+		t.Fatalf("stride = %d, frame skip = %+v; not forwarded", stub.stride.n, stub.stride.fs)
+	}
+
+	// The next stream must not inherit the previous one's settings.
+	eng.Close()
+	if stub.stride.n > 1 || stub.stride.fs != (FrameSkip{}) {
+		t.Errorf("released engine kept stride %d, frame skip %+v", stub.stride.n, stub.stride.fs)
+	}
+}
@@ -0,0 +1,66 @@
+package engine
+
+import (
+	"fmt"
+	"slices"
+	"sync"
+)
+
+// Factory creates an engine for one stream. hopMs is the configured window
+// hop (hop_ms); engines without overlapping windows may ignore it.
+type Factory func(hopMs int) (Engine, error)
+
+// reservedNames are the engine names the adapter resolves itself.
+var reservedNames = []string{"silero", "stub", "energy", "auto"}
+
+var (
+	registryMu sync.RWMutex
+	registry   = map[string]Factory{}
+)
+
+// Register makes a custom engine selectable by name wherever an engine is
+// configured (engine, shadow_engine, ensemble.engine, cpu_budget.engine).
+// It is meant to be called from the init function of a file compiled in
+// with a build tag; go run ./tools/new-engine scaffolds one. Like
+// database/sql.Register, it panics if name is empty, reserved for a
+// built-in engine or already registered, or if factory is nil.
+func Register(name string, factory Factory) {
+	if name == "" || slices.Contains(reservedNames, name) {
+		panic(fmt.Sprintf("engine: Register with reserved name %q", name))
+	}
+	if factory == nil {
+		panic(fmt.Sprintf("engine: Register %q with nil factory", name))
+	}
+	registryMu.Lock()
+	defer registryMu.Unlock()
+	if _, dup := registry[name]; dup {
+		panic(fmt.Sprintf("engine: Register called twice for %q", name))
+	}
+	registry[name] = factory
+}
+
+// Lookup returns the factory of the engine registered as name.
+func Lookup(name string) (Factory, bool) {
+	registryMu.RLock()
+	defer registryMu.RUnlock()
+	factory, ok := registry[name]
+	return factory, ok
+}
+
+// Registered reports whether an engine is registered as name.
+func Registered(name string) bool {
+	_, ok := Lookup(name)
+	return ok
+}
+
+// RegisteredNames returns the names of the registered engines, sorted.
+func RegisteredNames() []string {
+	registryMu.RLock()
+	defer registryMu.RUnlock()
+	names := make([]string, 0, len(registry))
+	for name := range registry {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+	return names
+}
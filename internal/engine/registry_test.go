@@ -0,0 +1,38 @@
+package engine
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestRegister(t *testing.T) {
+	defer unregister("conformance-stub")
+
+	Register("conformance-stub", func(int) (Engine, error) { return NewStubEngine(), nil })
+	if !Registered("conformance-stub") || !slices.Contains(RegisteredNames(), "conformance-stub") {
+		t.Fatal("registered engine not found")
+	}
+	factory, ok := Lookup("conformance-stub")
+	if !ok {
+		t.Fatal("Lookup: not found")
+	}
+	testConformance(t, func() (Engine, error) { return factory(DefaultHopMs) })
+
+	for _, name := range []string{"", "silero", "stub", "auto", "conformance-stub"} {
+		func() {
+			defer func() {
+				if recover() == nil {
+					t.Errorf("Register(%q): no panic", name)
+				}
+			}()
+			Register(name, func(int) (Engine, error) { return NewStubEngine(), nil })
+		}()
+	}
+}
+
+// unregister removes name from the registry.
+func unregister(name string) {
+	registryMu.Lock()
+	defer registryMu.Unlock()
+	delete(registry, name)
+}
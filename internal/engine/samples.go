@@ -0,0 +1,46 @@
+package engine
+
+import "sync"
+
+// MaxChunkBytes is the largest PCM chunk the engines' sample buffers are
+// sized for: 1 MiB, about 32 seconds at 16 kHz mono s16le. It is the
+// default max_chunk_bytes; larger chunks still work, but their buffers are
+// not pooled.
+const MaxChunkBytes = 1 << 20
+
+const (
+	// minPooledSamples is the capacity of a new sample buffer: a Silero
+	// window plus a chunk of up to 96 ms at 16 kHz, the sizes clients
+	// usually send.
+	minPooledSamples = 2048
+
+	// maxPooledSamples bounds the buffers kept for reuse: a full chunk of
+	// MaxChunkBytes plus the window left over from the previous one.
+	maxPooledSamples = MaxChunkBytes/2 + 2*minPooledSamples
+)
+
+// samplePool recycles the float32 buffers engines convert PCM into, so
+// streams opening and closing at a high rate, and streams sending larger
+// chunks than their buffer holds, reuse buffers instead of allocating and
+// collecting them.
+var samplePool sync.Pool
+
+// getSamples returns an empty buffer with room for at least n samples.
+func getSamples(n int) []float32 {
+	if p, ok := samplePool.Get().(*[]float32); ok {
+		if cap(*p) >= n {
+			return (*p)[:0]
+		}
+		// Too small for this caller, but fine for the next.
+		samplePool.Put(p)
+	}
+	return make([]float32, 0, max(n, minPooledSamples))
+}
+
+// putSamples returns s to the pool. The caller must not use s afterwards.
+func putSamples(s []float32) {
+	if cap(s) < minPooledSamples || cap(s) > maxPooledSamples {
+		return
+	}
+	samplePool.Put(&s)
+}
@@ -0,0 +1,69 @@
+package engine
+
+import (
+	"fmt"
+	"sync/atomic"
+)
+
+// Graph optimization levels accepted in SessionOptions.GraphOptimization.
+const (
+	GraphOptimizationDisabled = "disabled"
+	GraphOptimizationBasic    = "basic"
+	GraphOptimizationExtended = "extended"
+	GraphOptimizationAll      = "all"
+)
+
+// SessionOptions tunes the ONNX Runtime session of each native engine.
+// Zero fields keep ONNX Runtime's defaults.
+type SessionOptions struct {
+	// IntraOpThreads and InterOpThreads size the session's thread pools.
+	// ONNX Runtime's default intra-op pool has a thread per core, so with
+	// one session per stream many streams oversubscribe the CPUs. The
+	// inter-op pool only runs independent graph nodes in parallel.
+	IntraOpThreads int
+	InterOpThreads int
+
+	// GraphOptimization is one of the GraphOptimization levels; empty means
+	// GraphOptimizationAll.
+	GraphOptimization string
+
+	// MemArena and MemPattern, when set, enable or disable the CPU memory
+	// arena and memory pattern planning.
+	MemArena   *bool
+	MemPattern *bool
+}
+
+// validate checks the option values.
+func (o SessionOptions) validate() error {
+	if o.IntraOpThreads < 0 || o.InterOpThreads < 0 {
+		return fmt.Errorf("engine: session thread counts must not be negative, got %d intra-op and %d inter-op", o.IntraOpThreads, o.InterOpThreads)
+	}
+	switch o.GraphOptimization {
+	case "", GraphOptimizationDisabled, GraphOptimizationBasic, GraphOptimizationExtended, GraphOptimizationAll:
+		return nil
+	default:
+		return fmt.Errorf("engine: unknown graph optimization level %q", o.GraphOptimization)
+	}
+}
+
+// sessionOptions holds the options set with SetSessionOptions; nil means
+// the zero SessionOptions.
+var sessionOptions atomic.Pointer[SessionOptions]
+
+// SetSessionOptions sets the ONNX Runtime options of native engines created
+// afterwards. Engines already created keep theirs.
+func SetSessionOptions(opts SessionOptions) error {
+	if err := opts.validate(); err != nil {
+		return err
+	}
+	sessionOptions.Store(&opts)
+	return nil
+}
+
+// CurrentSessionOptions returns the options new native engines use.
+func CurrentSessionOptions() SessionOptions {
+	if p := sessionOptions.Load(); p != nil {
+		return *p
+	}
+	return SessionOptions{}
+}
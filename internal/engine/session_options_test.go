@@ -0,0 +1,24 @@
+package engine
+
+import "testing"
+
+func TestSetSessionOptions(t *testing.T) {
+	defer SetSessionOptions(SessionOptions{})
+
+	arena := false
+	opts := SessionOptions{IntraOpThreads: 1, InterOpThreads: 1, GraphOptimization: GraphOptimizationBasic, MemArena: &arena}
+	if err := SetSessionOptions(opts); err != nil {
+		t.Fatal(err)
+	}
+	if got := CurrentSessionOptions(); got.IntraOpThreads != 1 || got.GraphOptimization != GraphOptimizationBasic || got.MemArena != &arena {
+		t.Errorf("CurrentSessionOptions = %+v, want %+v", got, opts)
+	}
+	for _, bad := range []SessionOptions{{IntraOpThreads: -1}, {GraphOptimization: "max"}} {
+		if err := SetSessionOptions(bad); err == nil {
+			t.Errorf("SetSessionOptions(%+v): no error", bad)
+		}
+	}
+	if got := CurrentSessionOptions(); got.IntraOpThreads != 1 {
+		t.Errorf("rejected options replaced the current ones: %+v", got)
+	}
+}
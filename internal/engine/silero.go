@@ -7,6 +7,8 @@ import (
 	"sync"
 
 	ort "github.com/yalue/onnxruntime_go"
+
+	"github.com/nupi-ai/plugin-vad-local-silero/internal/errs"
 )
 
 const (
@@ -14,11 +16,90 @@ const (
 	// Silero VAD v5 at 16 kHz requires exactly 512 samples (32 ms).
 	sileroWindowSize = 512
 
+	// sileroWindowSize8k is the window at 8 kHz: 256 samples, also 32 ms.
+	sileroWindowSize8k = 256
+
+	// sileroWindowMs is the window duration at both supported rates.
+	sileroWindowMs = 32
+
 	// sileroStateSize is the hidden state dimension per layer.
 	// Silero VAD v5 uses a combined state tensor of shape [2, 1, 128].
 	sileroStateSize = 128
+
+	// sileroV4StateSize is the v4 LSTM's dimension: separate h and c
+	// tensors of shape [2, 1, 64].
+	sileroV4StateSize = 64
 )
 
+// sileroSpec describes the RNN state one Silero generation carries between
+// windows: its input names, the matching output names and their shape. The
+// window ("input"), sample rate ("sr") and probability ("output") are the
+// same in every generation.
+type sileroSpec struct {
+	stateInputs  []string
+	stateOutputs []string
+	stateShape   ort.Shape
+}
+
+var sileroSpecs = map[string]sileroSpec{
+	ModelV5: {[]string{"state"}, []string{"stateN"}, ort.NewShape(2, 1, sileroStateSize)},
+	ModelV4: {[]string{"h", "c"}, []string{"hn", "cn"}, ort.NewShape(2, 1, sileroV4StateSize)},
+}
+
+// modelInputs caches the inputs each model declares, keyed by the model's
+// SHA-256 digest: reading them costs a throwaway session, hashing far less.
+// The key holds no reference to the model, so one replaced by SetModel or a
+// reload can be freed; only its small entry stays.
+var modelInputs sync.Map // digest -> []ort.InputOutputInfo
+
+// checkModel verifies that model takes the inputs spec binds and reports
+// whether it takes the sample rate input. Exports without one, common for
+// quantized models, are 16 kHz only.
+func checkModel(model []byte, spec sileroSpec) (hasSR bool, err error) {
+	var inputs []ort.InputOutputInfo
+	digest := ModelSHA256(model)
+	if cached, ok := modelInputs.Load(digest); ok {
+		inputs = cached.([]ort.InputOutputInfo)
+	} else {
+		if inputs, _, err = ort.GetInputOutputInfoWithONNXData(model); err != nil {
+			return false, fmt.Errorf("silero: read model inputs: %w", err)
+		}
+		modelInputs.Store(digest, inputs)
+	}
+	declared := make(map[string]ort.InputOutputInfo, len(inputs))
+	for _, in := range inputs {
+		declared[in.Name] = in
+	}
+	if _, ok := declared["input"]; !ok {
+		return false, fmt.Errorf("silero: model has no %q input, not a Silero VAD model", "input")
+	}
+	for _, name := range spec.stateInputs {
+		in, ok := declared[name]
+		if !ok {
+			return false, fmt.Errorf("silero: model has no %q state input (does model_version match the model?)", name)
+		}
+		if !shapeMatches(in.Dimensions, spec.stateShape) {
+			return false, fmt.Errorf("silero: model state %q has shape %v, want %v (does model_version match the model?)", name, in.Dimensions, spec.stateShape)
+		}
+	}
+	_, hasSR = declared["sr"]
+	return hasSR, nil
+}
+
+// shapeMatches reports whether a declared shape accepts want; dynamic
+// dimensions (-1) accept any size.
+func shapeMatches(declared, want ort.Shape) bool {
+	if len(declared) != len(want) {
+		return false
+	}
+	for i, d := range declared {
+		if d >= 0 && d != want[i] {
+			return false
+		}
+	}
+	return true
+}
+
 // ortInitOnce ensures ONNX Runtime environment is initialized exactly once.
 // ortInitErr is stored at package scope so subsequent NewSileroEngine calls
 // surface the failure instead of proceeding with an uninitialized environment.
@@ -27,31 +108,82 @@ var (
 	ortInitErr  error
 )
 
-// SileroEngine runs Silero VAD v5 inference via ONNX Runtime.
+// SileroEngine runs Silero VAD inference via ONNX Runtime: the v5 model by
+// default, v4 when selected with SetModelVersion.
 type SileroEngine struct {
 	session *ort.AdvancedSession
 
+	// model is the ONNX model the session was created from, kept so
+	// SetSampleRate recreates the session from the same model; spec
+	// describes its generation.
+	model []byte
+	spec  sileroSpec
+
+	// hasSR reports whether the model takes the sample rate as an input;
+	// a model without one only runs at 16 kHz.
+	hasSR bool
+
+	// opts are the session options, kept for sessions SetSampleRate creates.
+	opts SessionOptions
+
 	// Input tensors (reused between calls).
-	inputTensor *ort.Tensor[float32] // [1, 512]
-	stateTensor *ort.Tensor[float32] // [2, 1, 128]
-	srTensor    *ort.Tensor[int64]   // scalar
+	inputTensor *ort.Tensor[float32]   // [1, windowSize]
+	srTensor    *ort.Tensor[int64]     // scalar
+	states      []*ort.Tensor[float32] // one per spec.stateInputs
 
 	// Output tensors (reused between calls).
-	outputTensor *ort.Tensor[float32] // [1, 1]
-	stateNTensor *ort.Tensor[float32] // [2, 1, 128]
+	outputTensor *ort.Tensor[float32]   // [1, 1]
+	statesN      []*ort.Tensor[float32] // one per spec.stateOutputs
+
+	// buf accumulates incoming chunks into (possibly overlapping) windows.
+	buf *windowBuffer
 
-	// PCM sample buffer for accumulating 20ms chunks to 512-sample windows.
-	pcmBuf []float32
+	// stride picks the windows inferred (see SetStride).
+	stride strider
 
-	threshold float64
+	// sampleRate and windowSize are 16000/512 by default and 8000/256 after
+	// SetSampleRate(8000).
+	sampleRate uint32
+	windowSize int
+
+	// hopMs is the stride between consecutive windows (SetHopMs); hopSize is
+	// the same stride in samples at sampleRate. A hop shorter than the window
+	// makes consecutive windows overlap.
+	hopMs   int
+	hopSize int
 }
 
 // NewSileroEngine creates a SileroEngine by initializing ONNX Runtime,
-// loading the embedded model, and allocating input/output tensors.
-func NewSileroEngine(threshold float64) (*SileroEngine, error) {
-	if len(sileroModelData) == 0 {
+// loading the model of the current ModelVersion and ModelPrecision (the
+// embedded one unless SetModel installed another), and allocating
+// input/output tensors.
+func NewSileroEngine() (*SileroEngine, error) {
+	version, precision := ModelVersion(), ModelPrecision()
+	model := modelData(version, precision)
+	if len(model) == 0 {
+		switch {
+		case precision == PrecisionInt8 && version == ModelV4:
+			return nil, fmt.Errorf("silero: no int8 v4 model is embedded (set model_path)")
+		case precision == PrecisionInt8:
+			return nil, fmt.Errorf("silero: int8 model not embedded (run make quantize-model before building, or set model_path)")
+		case version == ModelV4:
+			return nil, fmt.Errorf("silero: v4 model not embedded (run make download-model-v4 before building, or set model_path)")
+		}
 		return nil, fmt.Errorf("silero: model data is empty (build without silero tag?)")
 	}
+	return NewSileroEngineFromModel(model, version)
+}
+
+// NewSileroEngineFromModel creates a SileroEngine running model, of the
+// Silero generation version, whatever the current model settings. It lets
+// engines of two models run side by side.
+func NewSileroEngineFromModel(model []byte, version string) (*SileroEngine, error) {
+	if len(model) == 0 {
+		return nil, fmt.Errorf("silero: model data is empty")
+	}
+	if version != ModelV4 && version != ModelV5 {
+		return nil, fmt.Errorf("silero: model version must be %q or %q, got %q", ModelV4, ModelV5, version)
+	}
 
 	ortInitOnce.Do(func() {
 		libPath, err := resolveORTLibPath()
@@ -60,129 +192,306 @@ func NewSileroEngine(threshold float64) (*SileroEngine, error) {
 			return
 		}
 		ort.SetSharedLibraryPath(libPath)
-		ortInitErr = ort.InitializeEnvironment()
+		if err := ort.InitializeEnvironment(); err != nil {
+			ortInitErr = fmt.Errorf("initialize ORT from %s (this build requires ONNX Runtime %s): %w", libPath, ortVersion, err)
+			return
+		}
+		if err := checkORTVersion(ort.GetVersion(), libPath); err != nil {
+			ort.DestroyEnvironment()
+			ortInitErr = err
+		}
 	})
 	if ortInitErr != nil {
 		return nil, fmt.Errorf("silero: %w", ortInitErr)
 	}
 
-	// Allocate input tensors.
-	inputTensor, err := ort.NewEmptyTensor[float32](ort.NewShape(1, sileroWindowSize))
+	spec := sileroSpecs[version]
+	hasSR, err := checkModel(model, spec)
 	if err != nil {
-		return nil, fmt.Errorf("silero: create input tensor: %w", err)
+		return nil, err
 	}
-	stateTensor, err := ort.NewEmptyTensor[float32](ort.NewShape(2, 1, sileroStateSize))
-	if err != nil {
-		inputTensor.Destroy()
-		return nil, fmt.Errorf("silero: create state tensor: %w", err)
+
+	e := &SileroEngine{
+		model:      model,
+		spec:       spec,
+		hasSR:      hasSR,
+		opts:       CurrentSessionOptions(),
+		buf:        newWindowBuffer(sileroWindowSize, sileroWindowSize),
+		sampleRate: ExpectedSampleRate,
+		windowSize: sileroWindowSize,
+		hopMs:      sileroWindowMs,
+		hopSize:    sileroWindowSize,
 	}
-	srTensor, err := ort.NewTensor(ort.NewShape(1), []int64{int64(ExpectedSampleRate)})
-	if err != nil {
-		inputTensor.Destroy()
-		stateTensor.Destroy()
-		return nil, fmt.Errorf("silero: create sr tensor: %w", err)
+	if err := e.allocate(); err != nil {
+		e.Close()
+		return nil, err
+	}
+	return e, nil
+}
+
+// allocate creates the tensors and the session for 16 kHz. On error the
+// tensors created so far are left for Close.
+func (e *SileroEngine) allocate() error {
+	var err error
+	if e.inputTensor, err = ort.NewEmptyTensor[float32](ort.NewShape(1, sileroWindowSize)); err != nil {
+		return fmt.Errorf("silero: create input tensor: %w", err)
+	}
+	if e.srTensor, err = ort.NewTensor(ort.NewShape(1), []int64{int64(ExpectedSampleRate)}); err != nil {
+		return fmt.Errorf("silero: create sr tensor: %w", err)
+	}
+	if e.outputTensor, err = ort.NewEmptyTensor[float32](ort.NewShape(1, 1)); err != nil {
+		return fmt.Errorf("silero: create output tensor: %w", err)
+	}
+	for range e.spec.stateInputs {
+		state, err := ort.NewEmptyTensor[float32](e.spec.stateShape)
+		if err != nil {
+			return fmt.Errorf("silero: create state tensor: %w", err)
+		}
+		e.states = append(e.states, state)
+		stateN, err := ort.NewEmptyTensor[float32](e.spec.stateShape)
+		if err != nil {
+			return fmt.Errorf("silero: create stateN tensor: %w", err)
+		}
+		e.statesN = append(e.statesN, stateN)
+	}
+
+	// Explicitly zero state tensors — onnxruntime_go may not guarantee zeroed memory.
+	for i := range e.states {
+		clearFloat32Slice(e.states[i].GetData())
+		clearFloat32Slice(e.statesN[i].GetData())
 	}
 
-	// Allocate output tensors.
-	outputTensor, err := ort.NewEmptyTensor[float32](ort.NewShape(1, 1))
+	session, err := e.newSession(e.inputTensor)
 	if err != nil {
-		inputTensor.Destroy()
-		stateTensor.Destroy()
-		srTensor.Destroy()
-		return nil, fmt.Errorf("silero: create output tensor: %w", err)
+		return fmt.Errorf("silero: create session: %w", err)
 	}
-	stateNTensor, err := ort.NewEmptyTensor[float32](ort.NewShape(2, 1, sileroStateSize))
+	e.session = session
+	return nil
+}
+
+// newSession creates an ONNX session from the engine's model, bound to
+// input and the engine's other tensors. ONNX Runtime binds them by name, so
+// the state tensors follow the spec's names in order, and the sample rate
+// is only bound when the model takes it.
+func (e *SileroEngine) newSession(input *ort.Tensor[float32]) (*ort.AdvancedSession, error) {
+	options, err := newORTSessionOptions(e.opts)
 	if err != nil {
-		inputTensor.Destroy()
-		stateTensor.Destroy()
-		srTensor.Destroy()
-		outputTensor.Destroy()
-		return nil, fmt.Errorf("silero: create stateN tensor: %w", err)
+		return nil, err
 	}
+	defer options.Destroy()
 
-	// Explicitly zero state tensors — onnxruntime_go may not guarantee zeroed memory.
-	clearFloat32Slice(stateTensor.GetData())
-	clearFloat32Slice(stateNTensor.GetData())
-
-	// Create ONNX session from embedded model data.
-	session, err := ort.NewAdvancedSessionWithONNXData(
-		sileroModelData,
-		[]string{"input", "state", "sr"},
-		[]string{"output", "stateN"},
-		[]ort.Value{inputTensor, stateTensor, srTensor},
-		[]ort.Value{outputTensor, stateNTensor},
-		nil, // default session options
+	inputNames := []string{"input"}
+	inputs := []ort.Value{input}
+	for i, name := range e.spec.stateInputs {
+		inputNames = append(inputNames, name)
+		inputs = append(inputs, e.states[i])
+	}
+	if e.hasSR {
+		inputNames = append(inputNames, "sr")
+		inputs = append(inputs, e.srTensor)
+	}
+
+	outputNames := []string{"output"}
+	outputs := []ort.Value{e.outputTensor}
+	for i, name := range e.spec.stateOutputs {
+		outputNames = append(outputNames, name)
+		outputs = append(outputs, e.statesN[i])
+	}
+	return ort.NewAdvancedSessionWithONNXData(
+		e.model,
+		inputNames,
+		outputNames,
+		inputs,
+		outputs,
+		options,
 	)
+}
+
+// ortGraphOptimization maps SessionOptions.GraphOptimization to ONNX
+// Runtime's levels.
+var ortGraphOptimization = map[string]ort.GraphOptimizationLevel{
+	GraphOptimizationDisabled: ort.GraphOptimizationLevelDisableAll,
+	GraphOptimizationBasic:    ort.GraphOptimizationLevelEnableBasic,
+	GraphOptimizationExtended: ort.GraphOptimizationLevelEnableExtended,
+	GraphOptimizationAll:      ort.GraphOptimizationLevelEnableAll,
+}
+
+// newORTSessionOptions converts opts; the caller destroys the result once
+// the session is created, which copies it.
+func newORTSessionOptions(opts SessionOptions) (*ort.SessionOptions, error) {
+	options, err := ort.NewSessionOptions()
+	if err != nil {
+		return nil, fmt.Errorf("silero: create session options: %w", err)
+	}
+	apply := func() error {
+		if opts.IntraOpThreads > 0 {
+			if err := options.SetIntraOpNumThreads(opts.IntraOpThreads); err != nil {
+				return err
+			}
+		}
+		if opts.InterOpThreads > 0 {
+			if err := options.SetInterOpNumThreads(opts.InterOpThreads); err != nil {
+				return err
+			}
+		}
+		if level, ok := ortGraphOptimization[opts.GraphOptimization]; ok {
+			if err := options.SetGraphOptimizationLevel(level); err != nil {
+				return err
+			}
+		}
+		if opts.MemArena != nil {
+			if err := options.SetCpuMemArena(*opts.MemArena); err != nil {
+				return err
+			}
+		}
+		if opts.MemPattern != nil {
+			if err := options.SetMemPattern(*opts.MemPattern); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	if err := apply(); err != nil {
+		options.Destroy()
+		return nil, fmt.Errorf("silero: set session options: %w", err)
+	}
+	return options, nil
+}
+
+// SetSampleRate switches the engine between 16 kHz (512-sample window) and
+// 8 kHz (256-sample window). The session is bound to the input tensor's
+// shape, so changing rate recreates both; the RNN state and PCM buffer are
+// cleared. Models without a sample rate input only accept 16 kHz.
+func (e *SileroEngine) SetSampleRate(sampleRate uint32) error {
+	var window int
+	switch {
+	case sampleRate == ExpectedSampleRate:
+		window = sileroWindowSize
+	case sampleRate == TelephonySampleRate && e.hasSR:
+		window = sileroWindowSize8k
+	default:
+		return ErrWrongSampleRate
+	}
+	if sampleRate == e.sampleRate {
+		return nil
+	}
+
+	inputTensor, err := ort.NewEmptyTensor[float32](ort.NewShape(1, int64(window)))
+	if err != nil {
+		return fmt.Errorf("silero: create input tensor: %w", err)
+	}
+	e.srTensor.GetData()[0] = int64(sampleRate)
+	session, err := e.newSession(inputTensor)
 	if err != nil {
 		inputTensor.Destroy()
-		stateTensor.Destroy()
-		srTensor.Destroy()
-		outputTensor.Destroy()
-		stateNTensor.Destroy()
-		return nil, fmt.Errorf("silero: create session: %w", err)
+		e.srTensor.GetData()[0] = int64(e.sampleRate)
+		return fmt.Errorf("silero: create session: %w", err)
 	}
+	e.session.Destroy()
+	e.inputTensor.Destroy()
+	e.session = session
+	e.inputTensor = inputTensor
+	e.sampleRate = sampleRate
+	e.windowSize = window
+	e.hopSize = int(sampleRate) * e.hopMs / 1000
+	return e.Reset()
+}
 
-	return &SileroEngine{
-		session:      session,
-		inputTensor:  inputTensor,
-		stateTensor:  stateTensor,
-		srTensor:     srTensor,
-		outputTensor: outputTensor,
-		stateNTensor: stateNTensor,
-		pcmBuf:       make([]float32, 0, sileroWindowSize*2),
-		threshold:    threshold,
-	}, nil
+// SetHopMs sets the stride between inference windows. The default hop equals
+// the 32 ms window; a shorter hop (16 or 8 ms) runs inference on overlapping
+// windows, so each result covers only the newest hop of audio and onsets are
+// seen sooner at the cost of 32/hopMs times more inference. The RNN state
+// still carries over from window to window. Clears buffered audio.
+func (e *SileroEngine) SetHopMs(hopMs int) error {
+	if !SupportedHopMs(hopMs) {
+		return fmt.Errorf("silero: unsupported hop %d ms, expected 8, 16 or 32", hopMs)
+	}
+	e.hopMs = hopMs
+	e.hopSize = int(e.sampleRate) * hopMs / 1000
+	return e.Reset()
 }
 
 // ProcessChunk receives a PCM s16le audio chunk, buffers it, and runs
-// inference for each complete 512-sample window. Returns one Result per
-// inference, or an empty slice if not enough samples have accumulated.
+// inference for each complete window (512 samples at 16 kHz, 256 at 8 kHz),
+// advancing by one hop per inference. Returns one Result per inference, or an
+// empty slice if not enough samples have accumulated.
 func (e *SileroEngine) ProcessChunk(pcm []byte, sampleRate uint32) ([]Result, error) {
-	if sampleRate != ExpectedSampleRate {
+	if sampleRate != e.sampleRate {
 		return nil, ErrWrongSampleRate
 	}
 	if len(pcm)%2 != 0 {
-		return nil, fmt.Errorf("silero: PCM buffer has odd length %d, expected even (s16le requires 2 bytes per sample)", len(pcm))
+		return nil, errs.New(errs.ErrInvalidPCM, "silero: PCM buffer has odd length %d, expected even (s16le requires 2 bytes per sample)", len(pcm))
 	}
 
-	samples := pcmToFloat32(pcm)
-	e.pcmBuf = append(e.pcmBuf, samples...)
+	e.buf.write(pcm)
 
 	var results []Result
-	for len(e.pcmBuf) >= sileroWindowSize {
-		prob, err := e.infer(e.pcmBuf[:sileroWindowSize])
-		if err != nil {
-			return nil, err
+	for window := e.buf.next(); window != nil; window = e.buf.next() {
+		if e.stride.next() {
+			prob, err := e.infer(window)
+			if err != nil {
+				return nil, err
+			}
+			e.stride.scored(prob)
 		}
-		e.pcmBuf = e.pcmBuf[sileroWindowSize:]
-		results = append(results, Result{
-			IsSpeech:   float64(prob) >= e.threshold,
-			Confidence: prob,
-		})
+		results = append(results, Result{Confidence: e.stride.last})
 	}
 
 	return results, nil
 }
 
-// SetThreshold updates the speech probability threshold.
-func (e *SileroEngine) SetThreshold(threshold float64) {
-	e.threshold = threshold
-}
-
-// Reset clears all internal state: RNN hidden states, PCM buffer.
+// Reset clears all internal state: RNN hidden states, PCM buffer. With
+// overlapping windows the buffer is re-primed with silence (see
+// windowBuffer), so results stay aligned to hop boundaries.
 func (e *SileroEngine) Reset() error {
-	clearFloat32Slice(e.stateTensor.GetData())
-	e.pcmBuf = e.pcmBuf[:0]
+	for _, state := range e.states {
+		clearFloat32Slice(state.GetData())
+	}
+	e.buf.configure(e.windowSize, e.hopSize)
+	e.stride.reset()
 	return nil
 }
 
-// FrameDurationMs returns 32 — the Silero VAD window is 512 samples at 16kHz.
+// SetStride makes the engine infer every n-th window, repeating the last
+// probability for the windows in between. The RNN state is carried over
+// the skipped audio.
+func (e *SileroEngine) SetStride(n int) { e.stride.set(n) }
+
+// SetFrameSkip makes the engine lower its stride while confident (see
+// FrameSkipper).
+func (e *SileroEngine) SetFrameSkip(fs FrameSkip) { e.stride.setFrameSkip(fs) }
+
+// FrameDurationMs returns the hop: 32 ms (the Silero VAD window of 512
+// samples at 16kHz and 256 samples at 8kHz) unless changed with SetHopMs.
 func (e *SileroEngine) FrameDurationMs() int {
-	return int(sileroWindowSize * 1000 / ExpectedSampleRate) // 512 * 1000 / 16000 = 32
+	if e.hopMs == 0 {
+		return sileroWindowMs
+	}
+	return e.hopMs
+}
+
+// FrameSamples returns the hop in samples. Without SetHopMs it equals the
+// window size: 512 samples at 16kHz, 256 at 8kHz.
+func (e *SileroEngine) FrameSamples() int {
+	if e.hopSize != 0 {
+		return e.hopSize
+	}
+	if e.sampleRate == TelephonySampleRate {
+		return sileroWindowSize8k
+	}
+	return sileroWindowSize
 }
 
-// SampleRate returns 16000 — Silero VAD requires 16 kHz input.
-func (e *SileroEngine) SampleRate() uint32 { return ExpectedSampleRate }
+// WindowSamples returns the inference window: 512 samples at 16kHz, 256 at
+// 8kHz, whatever the hop.
+func (e *SileroEngine) WindowSamples() int { return e.windowSize }
+
+// BufferedSamples returns the samples held for the next window.
+func (e *SileroEngine) BufferedSamples() int { return e.buf.buffered() }
+
+// SampleRate returns the configured input rate: 16000 by default, or 8000
+// after SetSampleRate(8000).
+func (e *SileroEngine) SampleRate() uint32 { return e.sampleRate }
 
 // Close releases ONNX Runtime resources. Safe to call multiple times.
 func (e *SileroEngine) Close() error {
@@ -194,10 +503,10 @@ func (e *SileroEngine) Close() error {
 		e.inputTensor.Destroy()
 		e.inputTensor = nil
 	}
-	if e.stateTensor != nil {
-		e.stateTensor.Destroy()
-		e.stateTensor = nil
+	for _, state := range e.states {
+		state.Destroy()
 	}
+	e.states = nil
 	if e.srTensor != nil {
 		e.srTensor.Destroy()
 		e.srTensor = nil
@@ -206,14 +515,16 @@ func (e *SileroEngine) Close() error {
 		e.outputTensor.Destroy()
 		e.outputTensor = nil
 	}
-	if e.stateNTensor != nil {
-		e.stateNTensor.Destroy()
-		e.stateNTensor = nil
+	for _, stateN := range e.statesN {
+		stateN.Destroy()
 	}
+	e.statesN = nil
+	e.buf.release()
 	return nil
 }
 
-// infer runs a single Silero VAD inference on exactly 512 float32 samples.
+// infer runs a single Silero VAD inference on exactly one window of float32
+// samples.
 func (e *SileroEngine) infer(window []float32) (float32, error) {
 	// Copy window into input tensor.
 	copy(e.inputTensor.GetData(), window)
@@ -227,7 +538,9 @@ func (e *SileroEngine) infer(window []float32) (float32, error) {
 	prob := e.outputTensor.GetData()[0]
 
 	// Carry forward hidden state: copy stateN → state.
-	copy(e.stateTensor.GetData(), e.stateNTensor.GetData())
+	for i, stateN := range e.statesN {
+		copy(e.states[i].GetData(), stateN.GetData())
+	}
 
 	return prob, nil
 }
@@ -240,12 +553,7 @@ func pcmToFloat32(buf []byte) []float32 {
 	if n == 0 {
 		return nil
 	}
-	samples := make([]float32, n)
-	for i := 0; i < n; i++ {
-		u := uint16(buf[2*i]) | uint16(buf[2*i+1])<<8
-		samples[i] = float32(int16(u)) / 32768.0
-	}
-	return samples
+	return appendS16LE(make([]float32, 0, n), buf)
 }
 
 func clearFloat32Slice(s []float32) {
@@ -64,7 +64,7 @@ func skipWithoutORT(t *testing.T) {
 func TestSileroEngine_Integration(t *testing.T) {
 	skipWithoutORT(t)
 
-	eng, err := NewSileroEngine(0.5)
+	eng, err := NewSileroEngine()
 	if err != nil {
 		t.Fatalf("NewSileroEngine: %v", err)
 	}
@@ -82,15 +82,102 @@ func TestSileroEngine_Integration(t *testing.T) {
 	if results[0].Confidence > 0.5 {
 		t.Errorf("silence confidence = %v, expected < 0.5", results[0].Confidence)
 	}
-	if results[0].IsSpeech {
-		t.Error("silence should not be detected as speech")
+}
+
+func TestSileroEngine_8kHz_Integration(t *testing.T) {
+	skipWithoutORT(t)
+
+	eng, err := NewSileroEngine()
+	if err != nil {
+		t.Fatalf("NewSileroEngine: %v", err)
+	}
+	defer eng.Close()
+
+	if err := eng.SetSampleRate(8000); err != nil {
+		t.Fatalf("SetSampleRate(8000): %v", err)
+	}
+	if sr := eng.SampleRate(); sr != 8000 {
+		t.Fatalf("SampleRate() = %d, want 8000", sr)
+	}
+	if d := eng.FrameDurationMs(); d != 32 {
+		t.Fatalf("FrameDurationMs() = %d, want 32", d)
+	}
+
+	// Two 256-sample windows of silence at 8kHz.
+	silence := make([]byte, sileroWindowSize8k*2*2)
+	results, err := eng.ProcessChunk(silence, 8000)
+	if err != nil {
+		t.Fatalf("ProcessChunk silence: %v", err)
+	}
+	if len(results) != 2 {
+		t.Fatalf("expected 2 results, got %d", len(results))
+	}
+	if results[0].Confidence > 0.5 {
+		t.Errorf("silence confidence = %v, expected < 0.5", results[0].Confidence)
+	}
+
+	// 16kHz audio is rejected once the engine is configured for 8kHz.
+	if _, err := eng.ProcessChunk(silence, 16000); err != ErrWrongSampleRate {
+		t.Errorf("ProcessChunk at 16kHz = %v, want ErrWrongSampleRate", err)
+	}
+	if err := eng.SetSampleRate(44100); err != ErrWrongSampleRate {
+		t.Errorf("SetSampleRate(44100) = %v, want ErrWrongSampleRate", err)
+	}
+}
+
+func TestSileroEngine_OverlappingHop_Integration(t *testing.T) {
+	skipWithoutORT(t)
+
+	eng, err := NewSileroEngine()
+	if err != nil {
+		t.Fatalf("NewSileroEngine: %v", err)
+	}
+	defer eng.Close()
+
+	if err := eng.SetHopMs(8); err != nil {
+		t.Fatalf("SetHopMs(8): %v", err)
+	}
+	if n := eng.FrameSamples(); n != 128 {
+		t.Fatalf("FrameSamples() = %d, want 128", n)
+	}
+	if d := eng.FrameDurationMs(); d != 8 {
+		t.Fatalf("FrameDurationMs() = %d, want 8", d)
+	}
+
+	// One result per 8ms hop, starting with the first hop.
+	silence := make([]byte, sileroWindowSize*2)
+	results, err := eng.ProcessChunk(silence, 16000)
+	if err != nil {
+		t.Fatalf("ProcessChunk silence: %v", err)
+	}
+	if len(results) != 4 {
+		t.Fatalf("expected 4 results for 32ms at an 8ms hop, got %d", len(results))
+	}
+
+	// The hop follows the sample rate.
+	if err := eng.SetSampleRate(8000); err != nil {
+		t.Fatalf("SetSampleRate(8000): %v", err)
+	}
+	if n := eng.FrameSamples(); n != 64 {
+		t.Fatalf("FrameSamples() at 8kHz = %d, want 64", n)
+	}
+	results, err = eng.ProcessChunk(make([]byte, 64*2*3), 8000)
+	if err != nil {
+		t.Fatalf("ProcessChunk 8kHz: %v", err)
+	}
+	if len(results) != 3 {
+		t.Fatalf("expected 3 results at 8kHz, got %d", len(results))
+	}
+
+	if err := eng.SetHopMs(10); err == nil {
+		t.Error("SetHopMs(10) should fail")
 	}
 }
 
 func TestSileroEngine_Reset_Integration(t *testing.T) {
 	skipWithoutORT(t)
 
-	eng, err := NewSileroEngine(0.5)
+	eng, err := NewSileroEngine()
 	if err != nil {
 		t.Fatalf("NewSileroEngine: %v", err)
 	}
@@ -122,7 +209,7 @@ func TestSileroEngine_Reset_Integration(t *testing.T) {
 func TestSileroEngine_SmallChunks_Integration(t *testing.T) {
 	skipWithoutORT(t)
 
-	eng, err := NewSileroEngine(0.5)
+	eng, err := NewSileroEngine()
 	if err != nil {
 		t.Fatalf("NewSileroEngine: %v", err)
 	}
@@ -156,7 +243,7 @@ func TestSileroEngine_SmallChunks_Integration(t *testing.T) {
 func TestSileroEngine_WrongSampleRate(t *testing.T) {
 	skipWithoutORT(t)
 
-	eng, err := NewSileroEngine(0.5)
+	eng, err := NewSileroEngine()
 	if err != nil {
 		t.Fatalf("NewSileroEngine: %v", err)
 	}
@@ -172,7 +259,7 @@ func TestSileroEngine_WrongSampleRate(t *testing.T) {
 func TestSileroEngine_OddPCMLength(t *testing.T) {
 	skipWithoutORT(t)
 
-	eng, err := NewSileroEngine(0.5)
+	eng, err := NewSileroEngine()
 	if err != nil {
 		t.Fatalf("NewSileroEngine: %v", err)
 	}
@@ -191,7 +278,7 @@ func TestSileroEngine_InferenceLatency(t *testing.T) {
 	// This test measures actual inference time over multiple runs.
 	skipWithoutORT(t)
 
-	eng, err := NewSileroEngine(0.5)
+	eng, err := NewSileroEngine()
 	if err != nil {
 		t.Fatalf("NewSileroEngine: %v", err)
 	}
@@ -226,7 +313,7 @@ func TestSileroEngine_InferenceLatency(t *testing.T) {
 func TestSileroEngine_DoubleClose(t *testing.T) {
 	skipWithoutORT(t)
 
-	eng, err := NewSileroEngine(0.5)
+	eng, err := NewSileroEngine()
 	if err != nil {
 		t.Fatalf("NewSileroEngine: %v", err)
 	}
@@ -240,3 +327,11 @@ func TestSileroEngine_DoubleClose(t *testing.T) {
 		t.Fatalf("second Close: %v", err)
 	}
 }
+
+func TestSileroConformance_Integration(t *testing.T) {
+	skipWithoutORT(t)
+
+	for _, hopMs := range []int{DefaultHopMs, 8} {
+		testConformance(t, func() (Engine, error) { return NewNativeEngine(hopMs) })
+	}
+}
@@ -5,6 +5,8 @@ package engine
 import (
 	"runtime"
 	"testing"
+
+	ort "github.com/yalue/onnxruntime_go"
 )
 
 func TestPcmToFloat32_Empty(t *testing.T) {
@@ -125,6 +127,50 @@ func TestSileroConstants(t *testing.T) {
 	}
 }
 
+func TestSileroSpecs(t *testing.T) {
+	for _, version := range []string{ModelV4, ModelV5} {
+		spec, ok := sileroSpecs[version]
+		if !ok {
+			t.Errorf("%s: no spec", version)
+			continue
+		}
+		if len(spec.stateInputs) == 0 || len(spec.stateInputs) != len(spec.stateOutputs) {
+			t.Errorf("%s: state inputs %v do not match outputs %v", version, spec.stateInputs, spec.stateOutputs)
+		}
+	}
+	if got := sileroSpecs[ModelV4].stateShape; got.FlattenedSize() != 2*sileroV4StateSize {
+		t.Errorf("v4 state shape = %v, want [2 1 %d]", got, sileroV4StateSize)
+	}
+}
+
+func TestShapeMatches(t *testing.T) {
+	want := sileroSpecs[ModelV5].stateShape
+	for _, tc := range []struct {
+		declared ort.Shape
+		ok       bool
+	}{
+		{ort.NewShape(2, 1, 128), true},
+		{ort.NewShape(2, -1, 128), true}, // dynamic batch
+		{ort.NewShape(2, 1, 64), false},  // v4 state
+		{ort.NewShape(2, 128), false},
+	} {
+		if got := shapeMatches(tc.declared, want); got != tc.ok {
+			t.Errorf("shapeMatches(%v, %v) = %v, want %v", tc.declared, want, got, tc.ok)
+		}
+	}
+}
+
+func TestORTGraphOptimizationLevels(t *testing.T) {
+	for _, level := range []string{GraphOptimizationDisabled, GraphOptimizationBasic, GraphOptimizationExtended, GraphOptimizationAll} {
+		if err := (SessionOptions{GraphOptimization: level}).validate(); err != nil {
+			t.Errorf("%s: %v", level, err)
+		}
+		if _, ok := ortGraphOptimization[level]; !ok {
+			t.Errorf("%s: no ONNX Runtime level", level)
+		}
+	}
+}
+
 func TestModelDataNotEmpty(t *testing.T) {
 	if len(sileroModelData) == 0 {
 		t.Fatal("sileroModelData is empty — model not embedded")
@@ -143,4 +189,12 @@ func TestSileroFrameDurationMs(t *testing.T) {
 	if d := eng.FrameDurationMs(); d != 32 {
 		t.Fatalf("FrameDurationMs() = %d, want 32", d)
 	}
+	// 256 samples at 8kHz is also 32ms.
+	eng = &SileroEngine{sampleRate: TelephonySampleRate, windowSize: sileroWindowSize8k}
+	if d := eng.FrameDurationMs(); d != 32 {
+		t.Fatalf("FrameDurationMs() at 8kHz = %d, want 32", d)
+	}
+	if n := eng.FrameSamples(); n != 256 {
+		t.Fatalf("FrameSamples() at 8kHz = %d, want 256", n)
+	}
 }
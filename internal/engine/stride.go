@@ -0,0 +1,131 @@
+package engine
+
+// Strided is implemented by engines that can save CPU by scoring only every
+// n-th frame, repeating the last score for the frames in between. Results
+// still come one per frame, so stream timing is unaffected. Skipped frames
+// are not seen by the model at all: Silero's recurrent state carries over
+// the gap.
+type Strided interface {
+	// SetStride makes the engine score every n-th frame; n <= 1 scores
+	// every frame. The next frame is always scored, so a return to stride
+	// 1 takes effect immediately.
+	SetStride(n int)
+}
+
+// SetStride sets eng's inference stride, reporting false when eng does not
+// implement Strided and scores every frame.
+func SetStride(eng Engine, n int) bool {
+	s, ok := eng.(Strided)
+	if ok {
+		s.SetStride(n)
+	}
+	return ok
+}
+
+// FrameSkip configures an engine to lower its own scoring rate while its
+// scores stay confidently on one side of a band, and to return to full rate
+// as soon as they leave it.
+type FrameSkip struct {
+	// Stride is the frames per score while confident; <= 1 disables frame
+	// skipping.
+	Stride int
+	// After is the confident frames in a row needed before skipping.
+	After int
+	// Scores <= Low are confident silence, >= High confident speech.
+	Low, High float32
+}
+
+// FrameSkipper is implemented by Strided engines that apply a FrameSkip
+// themselves. The decision is taken per frame: a scored frame that ends the
+// confident run makes the very next frame scored, even in the middle of a
+// chunk, so a change is seen at most Stride-1 frames late whatever the
+// client's chunk size.
+type FrameSkipper interface {
+	// SetFrameSkip replaces the engine's FrameSkip and restarts its
+	// confident run. It combines with SetStride: the larger stride applies.
+	SetFrameSkip(fs FrameSkip)
+}
+
+// SetFrameSkip sets eng's FrameSkip, reporting false when eng does not
+// implement FrameSkipper and never skips frames on its own.
+func SetFrameSkip(eng Engine, fs FrameSkip) bool {
+	s, ok := eng.(FrameSkipper)
+	if ok {
+		s.SetFrameSkip(fs)
+	}
+	return ok
+}
+
+// strider picks the frames a Strided engine scores. Frames in between hold
+// the last score rather than interpolating towards the next one: that would
+// delay every result by a stride, and results must be returned for the
+// chunk that completes their frame.
+type strider struct {
+	n    int // score every n-th frame; 0 and 1 score every frame
+	fs   FrameSkip
+	skip int     // frames to repeat before the next score
+	last float32 // the last score, repeated for skipped frames
+
+	run    int  // confident frames in a row, all on one side of fs's band
+	speech bool // the side of the run
+}
+
+// set changes the stride; the next frame is scored.
+func (s *strider) set(n int) {
+	s.n = n
+	s.skip = 0
+}
+
+// setFrameSkip changes the frame skipping and forgets the run; the next
+// frame is scored.
+func (s *strider) setFrameSkip(fs FrameSkip) {
+	s.fs = fs
+	s.reset()
+}
+
+// next reports whether the next frame is scored. When it is not, the frame
+// takes s.last, a confident score that extends the run.
+func (s *strider) next() bool {
+	if s.skip > 0 {
+		s.skip--
+		s.run++
+		return false
+	}
+	return true
+}
+
+// scored records the score of a frame next chose to score, and how many
+// frames to skip after it.
+func (s *strider) scored(score float32) {
+	s.last = score
+	n := s.n
+	if s.fs.Stride > 1 {
+		switch {
+		case score >= s.fs.High:
+			s.extend(true)
+		case score <= s.fs.Low:
+			s.extend(false)
+		default:
+			s.run = 0
+		}
+		if s.run >= s.fs.After {
+			n = max(n, s.fs.Stride)
+		}
+	}
+	s.skip = max(n-1, 0)
+}
+
+func (s *strider) extend(speech bool) {
+	if s.run > 0 && s.speech != speech {
+		s.run = 0
+	}
+	s.speech = speech
+	s.run++
+}
+
+// reset makes the next frame scored and forgets the run, keeping the
+// stride and frame skipping.
+func (s *strider) reset() {
+	s.skip = 0
+	s.run = 0
+}
@@ -0,0 +1,83 @@
+package engine
+
+import "testing"
+
+func TestFrameSkipWithinChunk(t *testing.T) {
+	// 18 frames of silence, then 22 of speech, each alternating between two
+	// scores so repeated frames stand out.
+	var script Script
+	for i := range 40 {
+		conf := float32(0.01) + float32(i%2)*0.01
+		if i >= 18 {
+			conf = 0.95 + float32(i%2)*0.01
+		}
+		script.Steps = append(script.Steps, ScriptStep{Frames: 1, Confidence: conf})
+	}
+	eng := NewStubEngine(WithScript(script))
+	if !SetFrameSkip(eng, FrameSkip{Stride: 4, After: 5, Low: 0.1, High: 0.9}) {
+		t.Fatal("stub engine does not implement FrameSkipper")
+	}
+
+	// One chunk crossing the band: the decision to skip, and to return to
+	// full rate, is taken per frame within it.
+	results, err := eng.ProcessChunk(make([]byte, 40*stubFrameBytes), 16000)
+	if err != nil || len(results) != 40 {
+		t.Fatalf("got %d results, %v; want 40", len(results), err)
+	}
+	scored := func(i int) bool { return results[i].Confidence != results[i-1].Confidence }
+	for i := 1; i < 5; i++ {
+		if !scored(i) {
+			t.Errorf("frame %d repeated before the run of 5", i)
+		}
+	}
+	for i := 5; i < 8; i++ {
+		if scored(i) {
+			t.Errorf("frame %d scored, want frame 4's score repeated", i)
+		}
+	}
+	// Speech starting at frame 18 is seen at the next scored frame, 20, and
+	// the frames after it are scored until a new run of 5.
+	if results[19].Confidence > 0.1 || results[20].Confidence < 0.9 {
+		t.Errorf("frames 16-21 = %v, want speech first seen at frame 20", results[16:22])
+	}
+	for i := 21; i < 25; i++ {
+		if !scored(i) {
+			t.Errorf("frame %d repeated right after the band changed", i)
+		}
+	}
+	if scored(25) {
+		t.Errorf("frame 25 scored, want skipping after a new run of 5")
+	}
+
+	// SetFrameSkip with the zero value scores every frame again.
+	eng.Reset()
+	SetFrameSkip(eng, FrameSkip{})
+	results, _ = eng.ProcessChunk(make([]byte, 10*stubFrameBytes), 16000)
+	for i := 1; i < len(results); i++ {
+		if !scored(i) {
+			t.Errorf("frame %d repeated with frame skipping off", i)
+		}
+	}
+}
+
+func TestFrameSkipCombinesWithStride(t *testing.T) {
+	var s strider
+	s.set(2)
+	s.setFrameSkip(FrameSkip{Stride: 4, After: 1, Low: 0.1, High: 0.9})
+	// An unconfident score keeps the budget's stride of 2.
+	if !s.next() {
+		t.Fatal("first frame not scored")
+	}
+	s.scored(0.5)
+	if s.skip != 1 {
+		t.Errorf("skip = %d after an unconfident score, want 1", s.skip)
+	}
+	s.next()
+	if !s.next() {
+		t.Fatal("frame after the skip not scored")
+	}
+	s.scored(0.01)
+	if s.skip != 3 {
+		t.Errorf("skip = %d while confident, want 3", s.skip)
+	}
+}
@@ -1,25 +1,31 @@
 package engine
 
-import "fmt"
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+
+	"github.com/nupi-ai/plugin-vad-local-silero/internal/errs"
+)
 
 const (
 	// StubToggleInterval is the number of frames after which the stub engine
 	// toggles between speech and silence. At 20ms per frame, 50 frames = 1 second.
 	StubToggleInterval = 50
 
-	// StubConfidence is the fixed confidence value returned by the stub engine.
-	StubConfidence float32 = 0.42
+	// StubConfidence is the speech probability the stub engine returns for
+	// speech frames, and StubSilenceConfidence the one for silence frames.
+	// Any threshold in between gives the toggle timing.
+	StubConfidence        float32 = 0.9
+	StubSilenceConfidence float32 = 0.1
 
 	// stubFrameDurationMs is the duration of each inference frame in milliseconds.
 	stubFrameDurationMs = 20
-
-	// stubSamplesPerFrame is the number of samples per 20ms frame at 16kHz.
-	// ExpectedSampleRate * 0.020 = 320 samples = 640 bytes (s16le).
-	stubSamplesPerFrame = int(ExpectedSampleRate) * stubFrameDurationMs / 1000
 )
 
 // StubEngine returns deterministic VAD results by alternating between speech
-// and silence every StubToggleInterval frames. It does not process audio data.
+// and silence every StubToggleInterval frames, or by following a Script (see
+// WithScript). It does not process audio data.
 //
 // Unlike earlier versions, StubEngine now returns N results proportional to PCM
 // length, matching Silero's behavior. This ensures consistent timing regardless
@@ -31,51 +37,172 @@ type StubEngine struct {
 	speaking bool
 	// pcmBuf accumulates samples until a full frame is ready.
 	pcmBuf int
+
+	// sampleRate is the configured input rate; zero means ExpectedSampleRate.
+	sampleRate uint32
+
+	// script, when set, replaces the toggle; step and stepFrame are the
+	// position in it.
+	script    *Script
+	step      int
+	stepFrame int
+
+	// stride picks the frames scored (see SetStride).
+	stride strider
+}
+
+// StubOption configures a StubEngine.
+type StubOption func(*StubEngine)
+
+// WithScript makes the stub return the confidences of script instead of
+// toggling. The script must be valid (see Script.Validate).
+func WithScript(script Script) StubOption {
+	return func(e *StubEngine) {
+		e.script = &script
+	}
+}
+
+// NewStubEngine creates a StubEngine starting in silence state, configured
+// for 16kHz input.
+func NewStubEngine(opts ...StubOption) *StubEngine {
+	e := &StubEngine{}
+	for _, opt := range opts {
+		opt(e)
+	}
+	return e
 }
 
-// NewStubEngine creates a StubEngine starting in silence state.
-func NewStubEngine() *StubEngine {
-	return &StubEngine{}
+// ScriptStep is a run of Frames 20ms frames all scored Confidence.
+type ScriptStep struct {
+	Frames     int     `json:"frames"`
+	Confidence float32 `json:"confidence"`
+}
+
+// Script is a programmed sequence of stub confidences, for simulating
+// boundary scenarios (a blip shorter than min_speech_duration_ms, a dip
+// shorter than min_silence_duration_ms) without audio. Once the steps run
+// out the stub repeats them when Loop is set, and returns
+// StubSilenceConfidence otherwise.
+type Script struct {
+	Steps []ScriptStep `json:"steps"`
+	Loop  bool         `json:"loop"`
+}
+
+// Validate reports whether s can drive a stub: it needs at least one step,
+// and every step a positive frame count and a confidence in [0, 1].
+func (s Script) Validate() error {
+	if len(s.Steps) == 0 {
+		return fmt.Errorf("stub script: no steps")
+	}
+	for i, step := range s.Steps {
+		if step.Frames < 1 {
+			return fmt.Errorf("stub script: step %d: frames must be positive, got %d", i, step.Frames)
+		}
+		if step.Confidence < 0 || step.Confidence > 1 {
+			return fmt.Errorf("stub script: step %d: confidence must be in [0, 1], got %v", i, step.Confidence)
+		}
+	}
+	return nil
+}
+
+// ParseScript decodes and validates a JSON script, e.g.
+//
+//	{"steps": [{"frames": 25, "confidence": 0.1}, {"frames": 50, "confidence": 0.9}], "loop": true}
+func ParseScript(data []byte) (Script, error) {
+	var s Script
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(&s); err != nil {
+		return Script{}, fmt.Errorf("stub script: %w", err)
+	}
+	if err := s.Validate(); err != nil {
+		return Script{}, err
+	}
+	return s, nil
 }
 
 // ProcessChunk returns one Result per 20ms frame contained in the PCM buffer.
 // Partial frames are buffered for the next call. This matches Silero's behavior.
 func (e *StubEngine) ProcessChunk(pcm []byte, sampleRate uint32) ([]Result, error) {
 	// Validate inputs for consistency with SileroEngine.
-	if sampleRate != ExpectedSampleRate {
+	if sampleRate != e.SampleRate() {
 		return nil, ErrWrongSampleRate
 	}
 	if len(pcm)%2 != 0 {
-		return nil, fmt.Errorf("stub: PCM buffer has odd length %d, expected even (s16le requires 2 bytes per sample)", len(pcm))
+		return nil, errs.New(errs.ErrInvalidPCM, "stub: PCM buffer has odd length %d, expected even (s16le requires 2 bytes per sample)", len(pcm))
 	}
 	// Convert bytes to samples (2 bytes per sample for s16le).
 	samples := len(pcm) / 2
 	e.pcmBuf += samples
 
+	samplesPerFrame := e.FrameSamples()
+
 	var results []Result
-	for e.pcmBuf >= stubSamplesPerFrame {
-		e.pcmBuf -= stubSamplesPerFrame
-		e.counter++
-		if e.counter >= StubToggleInterval {
-			e.counter = 0
-			e.speaking = !e.speaking
+	for e.pcmBuf >= samplesPerFrame {
+		e.pcmBuf -= samplesPerFrame
+		// Skipped frames still advance the toggle or script, as audio
+		// would go on without a model looking at it.
+		conf := e.next()
+		if e.stride.next() {
+			e.stride.scored(conf)
 		}
-		results = append(results, Result{
-			IsSpeech:   e.speaking,
-			Confidence: StubConfidence,
-		})
+		results = append(results, Result{Confidence: e.stride.last})
 	}
 	return results, nil
 }
 
-// Reset returns the engine to its initial state (silence, counter zero).
+// next returns the confidence of the next frame.
+func (e *StubEngine) next() float32 {
+	if e.script != nil {
+		return e.nextScripted()
+	}
+	e.counter++
+	if e.counter >= StubToggleInterval {
+		e.counter = 0
+		e.speaking = !e.speaking
+	}
+	if e.speaking {
+		return StubConfidence
+	}
+	return StubSilenceConfidence
+}
+
+func (e *StubEngine) nextScripted() float32 {
+	steps := e.script.Steps
+	if e.step == len(steps) {
+		if !e.script.Loop {
+			return StubSilenceConfidence
+		}
+		e.step = 0
+	}
+	conf := steps[e.step].Confidence
+	if e.stepFrame++; e.stepFrame == steps[e.step].Frames {
+		e.step++
+		e.stepFrame = 0
+	}
+	return conf
+}
+
+// Reset returns the engine to its initial state (silence, counter zero, or
+// the start of its script).
 func (e *StubEngine) Reset() error {
 	e.counter = 0
 	e.speaking = false
 	e.pcmBuf = 0
+	e.step = 0
+	e.stepFrame = 0
+	e.stride.reset()
 	return nil
 }
 
+// SetStride makes the engine score every n-th frame, repeating the last
+// confidence for the frames in between.
+func (e *StubEngine) SetStride(n int) { e.stride.set(n) }
+
+// SetFrameSkip makes the engine lower its stride while confident (see
+// FrameSkipper).
+func (e *StubEngine) SetFrameSkip(fs FrameSkip) { e.stride.setFrameSkip(fs) }
+
 // Close is a no-op for the stub engine.
 func (e *StubEngine) Close() error {
 	return nil
@@ -86,8 +213,32 @@ func (e *StubEngine) FrameDurationMs() int {
 	return stubFrameDurationMs
 }
 
-// SetThreshold is a no-op for the stub engine (IsSpeech is toggle-based).
-func (e *StubEngine) SetThreshold(_ float64) {}
+// FrameSamples returns the samples per 20ms frame: 320 at 16kHz, 160 at 8kHz.
+func (e *StubEngine) FrameSamples() int {
+	return int(e.SampleRate()) * stubFrameDurationMs / 1000
+}
+
+// SetSampleRate switches the stub to 8kHz or 16kHz input. Frames stay 20ms
+// long, so the toggle timing is the same at both rates.
+func (e *StubEngine) SetSampleRate(sampleRate uint32) error {
+	if !SupportedSampleRate(sampleRate) {
+		return ErrWrongSampleRate
+	}
+	e.sampleRate = sampleRate
+	e.pcmBuf = 0
+	return nil
+}
+
+// BufferedSamples returns the samples held short of the next frame.
+func (e *StubEngine) BufferedSamples() int {
+	return e.pcmBuf
+}
 
-// SampleRate returns ExpectedSampleRate (16000 Hz, matching Silero).
-func (e *StubEngine) SampleRate() uint32 { return ExpectedSampleRate }
+// SampleRate returns the configured input rate (16000 Hz unless changed
+// with SetSampleRate, matching Silero).
+func (e *StubEngine) SampleRate() uint32 {
+	if e.sampleRate == 0 {
+		return ExpectedSampleRate
+	}
+	return e.sampleRate
+}
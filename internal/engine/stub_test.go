@@ -1,6 +1,12 @@
 package engine
 
-import "testing"
+import (
+	"errors"
+	"slices"
+	"testing"
+
+	"github.com/nupi-ai/plugin-vad-local-silero/internal/errs"
+)
 
 // stubFrameBytes is the size of a 20ms PCM chunk at 16kHz mono s16le (640 bytes).
 const stubFrameBytes = 640
@@ -19,11 +25,8 @@ func TestStubEngineAlternatesSpeechSilence(t *testing.T) {
 		if len(results) != 1 {
 			t.Fatalf("frame %d: expected 1 result, got %d", i, len(results))
 		}
-		if results[0].IsSpeech {
-			t.Fatalf("frame %d: expected silence, got speech", i)
-		}
-		if results[0].Confidence != StubConfidence {
-			t.Fatalf("frame %d: confidence = %v, want %v", i, results[0].Confidence, StubConfidence)
+		if results[0].Confidence != StubSilenceConfidence {
+			t.Fatalf("frame %d: confidence = %v, want silence (%v)", i, results[0].Confidence, StubSilenceConfidence)
 		}
 	}
 
@@ -32,7 +35,7 @@ func TestStubEngineAlternatesSpeechSilence(t *testing.T) {
 	if err != nil {
 		t.Fatal(err)
 	}
-	if len(results) != 1 || !results[0].IsSpeech {
+	if len(results) != 1 || results[0].Confidence != StubConfidence {
 		t.Fatal("expected speech after toggle")
 	}
 
@@ -46,7 +49,7 @@ func TestStubEngineAlternatesSpeechSilence(t *testing.T) {
 	if err != nil {
 		t.Fatal(err)
 	}
-	if len(results) != 1 || results[0].IsSpeech {
+	if len(results) != 1 || results[0].Confidence != StubSilenceConfidence {
 		t.Fatal("expected silence after second toggle")
 	}
 }
@@ -65,7 +68,7 @@ func TestStubEngineReset(t *testing.T) {
 	if err != nil {
 		t.Fatal(err)
 	}
-	if len(results) != 1 || !results[0].IsSpeech {
+	if len(results) != 1 || results[0].Confidence != StubConfidence {
 		t.Fatal("expected speech before reset")
 	}
 
@@ -77,7 +80,7 @@ func TestStubEngineReset(t *testing.T) {
 	if err != nil {
 		t.Fatal(err)
 	}
-	if len(results) != 1 || results[0].IsSpeech {
+	if len(results) != 1 || results[0].Confidence != StubSilenceConfidence {
 		t.Fatal("expected silence after reset")
 	}
 }
@@ -92,8 +95,8 @@ func TestStubEngineConfidence(t *testing.T) {
 	if len(results) != 1 {
 		t.Fatalf("expected 1 result, got %d", len(results))
 	}
-	if results[0].Confidence != StubConfidence {
-		t.Fatalf("confidence = %v, want %v", results[0].Confidence, StubConfidence)
+	if results[0].Confidence != StubSilenceConfidence {
+		t.Fatalf("confidence = %v, want %v", results[0].Confidence, StubSilenceConfidence)
 	}
 }
 
@@ -123,6 +126,9 @@ func TestStubEnginePartialFrameBuffered(t *testing.T) {
 	if len(results) != 0 {
 		t.Fatalf("expected 0 results for half-frame, got %d", len(results))
 	}
+	if got := BufferedSamples(eng); got != stubFrameBytes/4 {
+		t.Errorf("BufferedSamples = %d after half a frame, want %d", got, stubFrameBytes/4)
+	}
 
 	// Send another half — now we have a full frame.
 	results, err = eng.ProcessChunk(halfChunk, 16000)
@@ -132,6 +138,9 @@ func TestStubEnginePartialFrameBuffered(t *testing.T) {
 	if len(results) != 1 {
 		t.Fatalf("expected 1 result after completing frame, got %d", len(results))
 	}
+	if got := BufferedSamples(eng); got != 0 {
+		t.Errorf("BufferedSamples = %d after a whole frame, want 0", got)
+	}
 }
 
 func TestStubEngineEmptyChunk(t *testing.T) {
@@ -164,6 +173,12 @@ func TestStubEngineOddPCMLength(t *testing.T) {
 	if err == nil {
 		t.Fatal("expected error for odd-length PCM buffer, got nil")
 	}
+	if !errors.Is(err, errs.ErrInvalidPCM) {
+		t.Errorf("odd-length error %v is not errs.ErrInvalidPCM", err)
+	}
+	if !errors.Is(ErrWrongSampleRate, errs.ErrUnsupportedFormat) {
+		t.Errorf("ErrWrongSampleRate is not errs.ErrUnsupportedFormat")
+	}
 }
 
 func TestStubEngineFrameDurationMs(t *testing.T) {
@@ -177,7 +192,7 @@ func TestStubEngineWrongSampleRate(t *testing.T) {
 	eng := NewStubEngine()
 
 	chunk := make([]byte, stubFrameBytes)
-	_, err := eng.ProcessChunk(chunk, 8000)
+	_, err := eng.ProcessChunk(chunk, 44100)
 	if err == nil {
 		t.Fatal("expected error for wrong sample rate, got nil")
 	}
@@ -185,3 +200,123 @@ func TestStubEngineWrongSampleRate(t *testing.T) {
 		t.Errorf("expected ErrWrongSampleRate, got: %v", err)
 	}
 }
+
+func TestStubEngine8kHz(t *testing.T) {
+	eng := NewStubEngine()
+	if err := eng.SetSampleRate(8000); err != nil {
+		t.Fatalf("SetSampleRate(8000): %v", err)
+	}
+	if sr := eng.SampleRate(); sr != 8000 {
+		t.Fatalf("SampleRate() = %d, want 8000", sr)
+	}
+
+	// 20ms at 8kHz = 160 samples = 320 bytes per frame.
+	results, err := eng.ProcessChunk(make([]byte, 320*3), 8000)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(results) != 3 {
+		t.Fatalf("expected 3 results, got %d", len(results))
+	}
+	if d := eng.FrameDurationMs(); d != 20 {
+		t.Errorf("FrameDurationMs() = %d, want 20", d)
+	}
+	if n := eng.FrameSamples(); n != 160 {
+		t.Errorf("FrameSamples() = %d, want 160", n)
+	}
+
+	// The configured rate is enforced on every chunk.
+	if _, err := eng.ProcessChunk(make([]byte, stubFrameBytes), 16000); err != ErrWrongSampleRate {
+		t.Errorf("ProcessChunk at 16kHz = %v, want ErrWrongSampleRate", err)
+	}
+}
+
+func TestStubEngineSetSampleRateUnsupported(t *testing.T) {
+	eng := NewStubEngine()
+	if err := eng.SetSampleRate(44100); err != ErrWrongSampleRate {
+		t.Fatalf("SetSampleRate(44100) = %v, want ErrWrongSampleRate", err)
+	}
+	if sr := eng.SampleRate(); sr != ExpectedSampleRate {
+		t.Errorf("SampleRate() = %d after rejected change, want %d", sr, ExpectedSampleRate)
+	}
+}
+
+// confidences feeds frames 20ms frames to eng and returns their confidences.
+func confidences(t *testing.T, eng *StubEngine, frames int) []float32 {
+	t.Helper()
+	results, err := eng.ProcessChunk(make([]byte, frames*stubFrameBytes), 16000)
+	if err != nil {
+		t.Fatal(err)
+	}
+	out := make([]float32, len(results))
+	for i, r := range results {
+		out[i] = r.Confidence
+	}
+	return out
+}
+
+func TestStubEngineScript(t *testing.T) {
+	script := Script{Steps: []ScriptStep{{Frames: 2, Confidence: 0.2}, {Frames: 1, Confidence: 0.7}}}
+	eng := NewStubEngine(WithScript(script))
+	// Past its end the script holds silence.
+	want := []float32{0.2, 0.2, 0.7, StubSilenceConfidence, StubSilenceConfidence}
+	if got := confidences(t, eng, 5); !slices.Equal(got, want) {
+		t.Errorf("confidences = %v, want %v", got, want)
+	}
+
+	if err := eng.Reset(); err != nil {
+		t.Fatal(err)
+	}
+	if got := confidences(t, eng, 1); got[0] != 0.2 {
+		t.Errorf("after Reset, confidence = %v, want the script's start", got[0])
+	}
+
+	script.Loop = true
+	eng = NewStubEngine(WithScript(script))
+	want = []float32{0.2, 0.2, 0.7, 0.2, 0.2, 0.7, 0.2}
+	if got := confidences(t, eng, 7); !slices.Equal(got, want) {
+		t.Errorf("looped confidences = %v, want %v", got, want)
+	}
+}
+
+func TestParseScript(t *testing.T) {
+	script, err := ParseScript([]byte(`{"steps": [{"frames": 25, "confidence": 0.1}, {"frames": 50, "confidence": 0.9}], "loop": true}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(script.Steps) != 2 || script.Steps[1] != (ScriptStep{Frames: 50, Confidence: 0.9}) || !script.Loop {
+		t.Errorf("script = %+v", script)
+	}
+
+	for name, raw := range map[string]string{
+		"not_json":       `steps`,
+		"no_steps":       `{"steps": []}`,
+		"unknown_field":  `{"steps": [{"frames": 1, "confidence": 0.5}], "repeat": true}`,
+		"zero_frames":    `{"steps": [{"frames": 0, "confidence": 0.5}]}`,
+		"confidence_big": `{"steps": [{"frames": 1, "confidence": 1.5}]}`,
+	} {
+		if _, err := ParseScript([]byte(raw)); err == nil {
+			t.Errorf("%s: expected error", name)
+		}
+	}
+}
+
+func TestStubEngineStride(t *testing.T) {
+	script := Script{Steps: []ScriptStep{
+		{Frames: 1, Confidence: 0.1}, {Frames: 1, Confidence: 0.2}, {Frames: 1, Confidence: 0.3},
+		{Frames: 1, Confidence: 0.4}, {Frames: 1, Confidence: 0.5}, {Frames: 1, Confidence: 0.6},
+	}}
+	eng := NewStubEngine(WithScript(script))
+	if !SetStride(eng, 2) {
+		t.Fatal("SetStride = false, want the stub to be Strided")
+	}
+	// Every other frame is scored; skipped frames repeat the last score.
+	if got := confidences(t, eng, 4); !slices.Equal(got, []float32{0.1, 0.1, 0.3, 0.3}) {
+		t.Errorf("stride 2 = %v", got)
+	}
+	// Back to stride 1, the next frame is scored.
+	eng.SetStride(1)
+	if got := confidences(t, eng, 2); !slices.Equal(got, []float32{0.5, 0.6}) {
+		t.Errorf("stride 1 = %v", got)
+	}
+}
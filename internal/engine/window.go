@@ -0,0 +1,80 @@
+package engine
+
+// windowBuffer accumulates PCM samples and yields fixed-size analysis windows
+// that advance by hop samples. With hop < window consecutive windows overlap;
+// the buffer is primed with window-hop zero samples so the first window is
+// complete after one hop of audio, like every later one.
+//
+// Consumed samples are compacted away on the next write, so the backing array
+// stays around window+chunk samples instead of being reallocated as the
+// slice start moves forward. Backing arrays come from samplePool; a chunk
+// that does not fit swaps in a larger one, and release hands the array
+// back when the engine is closed.
+type windowBuffer struct {
+	samples []float32
+	start   int // index of the next window's first sample
+	window  int
+	hop     int
+}
+
+func newWindowBuffer(window, hop int) *windowBuffer {
+	b := &windowBuffer{samples: getSamples(window * 2)}
+	b.configure(window, hop)
+	return b
+}
+
+// configure changes the window and hop sizes and clears buffered audio.
+func (b *windowBuffer) configure(window, hop int) {
+	b.window = window
+	b.hop = hop
+	b.reset()
+}
+
+// reset drops buffered audio and re-primes the overlap with silence.
+func (b *windowBuffer) reset() {
+	b.samples = b.samples[:0]
+	b.start = 0
+	for i := 0; i < b.window-b.hop; i++ {
+		b.samples = append(b.samples, 0)
+	}
+}
+
+// write appends PCM s16le samples, normalized to [-1, 1].
+func (b *windowBuffer) write(pcm []byte) {
+	if b.start > 0 {
+		n := copy(b.samples, b.samples[b.start:])
+		b.samples = b.samples[:n]
+		b.start = 0
+	}
+	if need := len(b.samples) + len(pcm)/2; need > cap(b.samples) {
+		grown := append(getSamples(max(need, 2*cap(b.samples))), b.samples...)
+		putSamples(b.samples)
+		b.samples = grown
+	}
+	b.samples = appendS16LE(b.samples, pcm)
+}
+
+// release returns the backing array to samplePool. A released buffer can
+// still be written to; it then allocates a new array.
+func (b *windowBuffer) release() {
+	putSamples(b.samples)
+	b.samples = nil
+	b.start = 0
+}
+
+// next returns the next complete window and advances by one hop, or nil if
+// not enough samples are buffered. The returned slice aliases the buffer and
+// is only valid until the next write.
+func (b *windowBuffer) next() []float32 {
+	if len(b.samples)-b.start < b.window {
+		return nil
+	}
+	w := b.samples[b.start : b.start+b.window]
+	b.start += b.hop
+	return w
+}
+
+// buffered returns the number of samples not yet consumed by a hop.
+func (b *windowBuffer) buffered() int {
+	return len(b.samples) - b.start
+}
@@ -0,0 +1,168 @@
+package engine
+
+import (
+	"encoding/binary"
+	"testing"
+)
+
+// rampPCM encodes samples start, start+1, ... as s16le.
+func rampPCM(start, n int) []byte {
+	pcm := make([]byte, n*2)
+	for i := 0; i < n; i++ {
+		binary.LittleEndian.PutUint16(pcm[2*i:], uint16(int16(start+i)))
+	}
+	return pcm
+}
+
+func sampleValue(v int) float32 { return float32(v) / 32768.0 }
+
+func TestWindowBufferNonOverlapping(t *testing.T) {
+	b := newWindowBuffer(4, 4)
+	b.write(rampPCM(1, 3))
+	if w := b.next(); w != nil {
+		t.Fatalf("next() with 3 of 4 samples = %v, want nil", w)
+	}
+	b.write(rampPCM(4, 6))
+	var windows [][]float32
+	for w := b.next(); w != nil; w = b.next() {
+		windows = append(windows, append([]float32(nil), w...))
+	}
+	if len(windows) != 2 {
+		t.Fatalf("got %d windows, want 2", len(windows))
+	}
+	if windows[0][0] != sampleValue(1) || windows[1][0] != sampleValue(5) {
+		t.Errorf("window starts = %v, %v, want samples 1 and 5", windows[0][0], windows[1][0])
+	}
+	if n := b.buffered(); n != 1 {
+		t.Errorf("buffered() = %d, want 1", n)
+	}
+}
+
+func TestWindowBufferOverlapping(t *testing.T) {
+	// Window 4, hop 2: primed with 2 zeros, so every 2 samples yield a window
+	// covering the previous 2 samples and the newest 2.
+	b := newWindowBuffer(4, 2)
+	b.write(rampPCM(1, 2))
+	w := b.next()
+	if w == nil {
+		t.Fatal("first window not ready after one hop")
+	}
+	want := []float32{0, 0, sampleValue(1), sampleValue(2)}
+	for i := range want {
+		if w[i] != want[i] {
+			t.Fatalf("first window = %v, want %v", w, want)
+		}
+	}
+	if b.next() != nil {
+		t.Fatal("second window ready before the second hop")
+	}
+
+	// Feeding one sample at a time must produce the same windows as one
+	// large write.
+	whole := newWindowBuffer(4, 2)
+	whole.write(rampPCM(1, 10))
+	var expected [][]float32
+	for w := whole.next(); w != nil; w = whole.next() {
+		expected = append(expected, append([]float32(nil), w...))
+	}
+	split := newWindowBuffer(4, 2)
+	var got [][]float32
+	for i := 1; i <= 10; i++ {
+		split.write(rampPCM(i, 1))
+		for w := split.next(); w != nil; w = split.next() {
+			got = append(got, append([]float32(nil), w...))
+		}
+	}
+	if len(got) != len(expected) || len(got) != 5 {
+		t.Fatalf("got %d windows one sample at a time, %d in one write, want 5", len(got), len(expected))
+	}
+	for i := range expected {
+		for j := range expected[i] {
+			if got[i][j] != expected[i][j] {
+				t.Fatalf("window %d = %v, want %v", i, got[i], expected[i])
+			}
+		}
+	}
+}
+
+func TestWindowBufferCompacts(t *testing.T) {
+	b := newWindowBuffer(512, 128)
+	chunk := rampPCM(0, 320)
+	for i := 0; i < 1000; i++ {
+		b.write(chunk)
+		for w := b.next(); w != nil; w = b.next() {
+		}
+	}
+	if c := cap(b.samples); c > 4*512 {
+		t.Errorf("cap(samples) = %d after 1000 chunks; consumed samples are not compacted", c)
+	}
+}
+
+func TestWindowBufferReset(t *testing.T) {
+	b := newWindowBuffer(4, 1)
+	b.write(rampPCM(1, 5))
+	b.reset()
+	if n := b.buffered(); n != 3 {
+		t.Errorf("buffered() after reset = %d, want 3 primed samples", n)
+	}
+	b.configure(4, 4)
+	if n := b.buffered(); n != 0 {
+		t.Errorf("buffered() after configure(4, 4) = %d, want 0", n)
+	}
+}
+
+func TestWindowBufferGrowsKeepingSamples(t *testing.T) {
+	b := newWindowBuffer(4, 4)
+	b.write(rampPCM(1, 3))
+	// A chunk larger than the buffer swaps in a larger array.
+	b.write(rampPCM(4, 2*minPooledSamples))
+	if n := b.buffered(); n != 3+2*minPooledSamples {
+		t.Fatalf("buffered() = %d, want %d", n, 3+2*minPooledSamples)
+	}
+	for i := 0; ; i++ {
+		w := b.next()
+		if w == nil {
+			break
+		}
+		if w[0] != sampleValue(1+4*i) {
+			t.Fatalf("window %d starts at %v, want sample %d", i, w[0], 1+4*i)
+		}
+	}
+
+	b.release()
+	if b.buffered() != 0 {
+		t.Errorf("buffered() after release = %d, want 0", b.buffered())
+	}
+	b.reset()
+	b.write(rampPCM(1, 4))
+	if w := b.next(); w == nil || w[3] != sampleValue(4) {
+		t.Errorf("window after release = %v, want samples 1-4", w)
+	}
+}
+
+func TestWindowBufferWriteDoesNotAllocate(t *testing.T) {
+	b := newWindowBuffer(512, 512)
+	pcm := rampPCM(0, 320)
+	allocs := testing.AllocsPerRun(100, func() {
+		b.write(pcm)
+		for b.next() != nil {
+		}
+	})
+	if allocs != 0 {
+		t.Errorf("write allocated %v times per chunk, want 0", allocs)
+	}
+}
+
+func TestSamplePool(t *testing.T) {
+	s := getSamples(10)
+	if len(s) != 0 || cap(s) < minPooledSamples {
+		t.Fatalf("getSamples(10) = len %d cap %d, want empty with %d room", len(s), cap(s), minPooledSamples)
+	}
+	if s := getSamples(maxPooledSamples + 1); cap(s) < maxPooledSamples+1 {
+		t.Errorf("getSamples beyond the pooled size = cap %d", cap(s))
+	}
+	// Oversized and undersized buffers are not kept.
+	putSamples(make([]float32, 0, maxPooledSamples+1))
+	putSamples(make([]float32, 0, 16))
+	putSamples(s)
+}
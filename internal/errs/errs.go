@@ -0,0 +1,90 @@
+// Package errs defines the error kinds shared by the engines and the server
+// and their mapping to gRPC status codes, so code that fails a stream states
+// what went wrong and the status code is decided in one place.
+package errs
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// Error kinds. Match them with errors.Is; the message a client sees comes
+// from the wrapping Error, not from the kind.
+var (
+	// ErrInvalidPCM is audio data the engine cannot take: odd length,
+	// oversized chunk.
+	ErrInvalidPCM = errors.New("invalid PCM")
+	// ErrUnsupportedFormat is an audio format this adapter does not handle:
+	// encoding, channels, bit depth or sample rate.
+	ErrUnsupportedFormat = errors.New("unsupported audio format")
+	// ErrInvalidConfig is a per-stream config_json that does not parse or
+	// validate.
+	ErrInvalidConfig = errors.New("invalid stream config")
+	// ErrEngineUnavailable means no engine can serve the stream right now,
+	// e.g. the backend is not compiled in or still initializing.
+	ErrEngineUnavailable = errors.New("engine unavailable")
+	// ErrOverloaded means the adapter is at capacity; clients should retry
+	// later or elsewhere.
+	ErrOverloaded = errors.New("overloaded")
+	// ErrInternal is a failure of the adapter itself.
+	ErrInternal = errors.New("internal error")
+)
+
+// Error is an error of a given kind with a client-facing message.
+type Error struct {
+	kind error
+	msg  string
+}
+
+// New returns an error of kind with a message formatted like fmt.Sprintf.
+func New(kind error, format string, args ...any) error {
+	return &Error{kind: kind, msg: fmt.Sprintf(format, args...)}
+}
+
+func (e *Error) Error() string { return e.msg }
+
+// Unwrap returns the error's kind.
+func (e *Error) Unwrap() error { return e.kind }
+
+// Code returns the gRPC status code for err. Errors that already carry a
+// gRPC status keep its code; other errors of unknown kind map to Unknown, as
+// gRPC itself would.
+func Code(err error) codes.Code {
+	if err == nil {
+		return codes.OK
+	}
+	if st, ok := status.FromError(err); ok {
+		return st.Code()
+	}
+	switch {
+	case errors.Is(err, ErrInvalidPCM), errors.Is(err, ErrUnsupportedFormat), errors.Is(err, ErrInvalidConfig):
+		return codes.InvalidArgument
+	case errors.Is(err, ErrEngineUnavailable):
+		return codes.Unavailable
+	case errors.Is(err, ErrOverloaded):
+		return codes.ResourceExhausted
+	case errors.Is(err, ErrInternal):
+		return codes.Internal
+	case errors.Is(err, context.Canceled):
+		return codes.Canceled
+	case errors.Is(err, context.DeadlineExceeded):
+		return codes.DeadlineExceeded
+	}
+	return codes.Unknown
+}
+
+// ToStatus converts err to a gRPC status error with Code(err) and err's
+// message. Status errors and nil are returned unchanged.
+func ToStatus(err error) error {
+	if err == nil {
+		return nil
+	}
+	if _, ok := status.FromError(err); ok {
+		return err
+	}
+	return status.Error(Code(err), err.Error())
+}
@@ -0,0 +1,55 @@
+package errs
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func TestCode(t *testing.T) {
+	tests := []struct {
+		err  error
+		want codes.Code
+	}{
+		{nil, codes.OK},
+		{New(ErrInvalidPCM, "odd length %d", 3), codes.InvalidArgument},
+		{New(ErrUnsupportedFormat, "8 bit"), codes.InvalidArgument},
+		{New(ErrInvalidConfig, "bad json"), codes.InvalidArgument},
+		{New(ErrEngineUnavailable, "initializing"), codes.Unavailable},
+		{New(ErrOverloaded, "too many streams"), codes.ResourceExhausted},
+		{New(ErrInternal, "boom"), codes.Internal},
+		{fmt.Errorf("engine: %w", New(ErrInvalidPCM, "odd length")), codes.InvalidArgument},
+		{status.Error(codes.Aborted, "admin"), codes.Aborted},
+		{context.Canceled, codes.Canceled},
+		{errors.New("plain"), codes.Unknown},
+	}
+	for _, tt := range tests {
+		if got := Code(tt.err); got != tt.want {
+			t.Errorf("Code(%v) = %v, want %v", tt.err, got, tt.want)
+		}
+	}
+}
+
+func TestToStatus(t *testing.T) {
+	if ToStatus(nil) != nil {
+		t.Error("ToStatus(nil) != nil")
+	}
+
+	err := New(ErrInvalidPCM, "PCM buffer has odd length %d", 641)
+	if !errors.Is(err, ErrInvalidPCM) || errors.Is(err, ErrUnsupportedFormat) {
+		t.Errorf("errors.Is does not match the kind of %v", err)
+	}
+	st, ok := status.FromError(ToStatus(err))
+	if !ok || st.Code() != codes.InvalidArgument || st.Message() != "PCM buffer has odd length 641" {
+		t.Errorf("ToStatus = %v, want InvalidArgument with the error's message", st)
+	}
+
+	orig := status.Error(codes.Unimplemented, "strict")
+	if got := ToStatus(orig); got != orig {
+		t.Errorf("ToStatus changed a status error: %v", got)
+	}
+}
@@ -0,0 +1,134 @@
+// Package feedback implements the adapter's segment feedback service:
+// downstream consumers (an ASR rejecting empty segments, a reviewer marking
+// missed speech) report segmentation errors per stream, which are counted in
+// the adapter's metrics and, with feedback.step set, tune the thresholds of
+// future streams. NAP defines no feedback API, so the service is declared by
+// hand with google.protobuf.Struct requests and responses documented on the
+// method.
+package feedback
+
+import (
+	"context"
+	"sync/atomic"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/structpb"
+
+	"github.com/nupi-ai/plugin-vad-local-silero/internal/server"
+)
+
+// ServiceName is the fully qualified gRPC service name.
+const ServiceName = "nupi.vad.feedback.v1.FeedbackService"
+
+// ReportSegmentMethod is the full method name, for clients and interceptors.
+const ReportSegmentMethod = "/" + ServiceName + "/ReportSegment"
+
+// Recorder is the server that aggregates feedback.
+type Recorder interface {
+	ReportFeedback(fb server.Feedback) server.FeedbackResult
+}
+
+// Service implements FeedbackService. It is registered before the VAD server
+// is ready and answers Unavailable until SetServer is called, like the VAD
+// service itself.
+type Service struct {
+	recorder atomic.Pointer[Recorder]
+}
+
+// New returns a Service with no server attached.
+func New() *Service {
+	return &Service{}
+}
+
+// SetServer attaches the running VAD server.
+func (s *Service) SetServer(r Recorder) {
+	s.recorder.Store(&r)
+}
+
+// Register adds the service to a gRPC server.
+func Register(r grpc.ServiceRegistrar, s *Service) {
+	r.RegisterService(&serviceDesc, s)
+}
+
+// ReportSegment reports one segmentation error on a stream.
+//
+// Request fields: "kind" (string, required) is "false_positive" (a segment
+// without usable speech) or "false_negative" (speech that was missed);
+// "session_id" and "stream_id" (strings) identify the stream, and
+// "start_ms"/"end_ms" (numbers, optional) locate the segment in its audio.
+// Reports are attributed to the stream's rollout variant; without a session
+// or stream the adapter knows, they cannot be during a candidate rollout.
+//
+// Response: {"variant" (empty if unattributed), "threshold_offset" (the
+// variant's current offset from its configured threshold), "adjusted"
+// (bool, whether this report moved it)}.
+func (s *Service) ReportSegment(_ context.Context, req *structpb.Struct) (*structpb.Struct, error) {
+	r := s.recorder.Load()
+	if r == nil {
+		return nil, status.Error(codes.Unavailable, "VAD service is initializing, please retry in a moment")
+	}
+	fields := req.GetFields()
+	fb := server.Feedback{
+		SessionID: fields["session_id"].GetStringValue(),
+		StreamID:  fields["stream_id"].GetStringValue(),
+		Kind:      fields["kind"].GetStringValue(),
+	}
+	if fb.Kind != server.FeedbackFalsePositive && fb.Kind != server.FeedbackFalseNegative {
+		return nil, status.Errorf(codes.InvalidArgument, "kind must be %q or %q, got %q",
+			server.FeedbackFalsePositive, server.FeedbackFalseNegative, fb.Kind)
+	}
+	startMs, endMs := fields["start_ms"].GetNumberValue(), fields["end_ms"].GetNumberValue()
+	if startMs < 0 || endMs < 0 || (endMs > 0 && endMs < startMs) {
+		return nil, status.Errorf(codes.InvalidArgument, "start_ms and end_ms must satisfy 0 <= start_ms <= end_ms, got %g and %g", startMs, endMs)
+	}
+	fb.Start = time.Duration(startMs * float64(time.Millisecond))
+	fb.End = time.Duration(endMs * float64(time.Millisecond))
+
+	res := (*r).ReportFeedback(fb)
+	return structpb.NewStruct(map[string]any{
+		"variant":          res.Variant,
+		"threshold_offset": res.ThresholdOffset,
+		"adjusted":         res.Adjusted,
+	})
+}
+
+// ReportSegment calls FeedbackService.ReportSegment on cc.
+func ReportSegment(ctx context.Context, cc grpc.ClientConnInterface, req *structpb.Struct, opts ...grpc.CallOption) (*structpb.Struct, error) {
+	out := new(structpb.Struct)
+	if err := cc.Invoke(ctx, ReportSegmentMethod, req, out, opts...); err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// feedbackServer is the handler type checked by grpc.Server.RegisterService.
+type feedbackServer interface {
+	ReportSegment(context.Context, *structpb.Struct) (*structpb.Struct, error)
+}
+
+func reportSegmentHandler(srv any, ctx context.Context, dec func(any) error, interceptor grpc.UnaryServerInterceptor) (any, error) {
+	in := new(structpb.Struct)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(feedbackServer).ReportSegment(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{Server: srv, FullMethod: ReportSegmentMethod}
+	handler := func(ctx context.Context, req any) (any, error) {
+		return srv.(feedbackServer).ReportSegment(ctx, req.(*structpb.Struct))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+var serviceDesc = grpc.ServiceDesc{
+	ServiceName: ServiceName,
+	HandlerType: (*feedbackServer)(nil),
+	Methods: []grpc.MethodDesc{
+		{MethodName: "ReportSegment", Handler: reportSegmentHandler},
+	},
+	Streams: []grpc.StreamDesc{},
+}
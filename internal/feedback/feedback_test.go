@@ -0,0 +1,79 @@
+package feedback
+
+import (
+	"context"
+	"net"
+	"testing"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/structpb"
+
+	"github.com/nupi-ai/plugin-vad-local-silero/internal/config"
+	"github.com/nupi-ai/plugin-vad-local-silero/internal/engine"
+	"github.com/nupi-ai/plugin-vad-local-silero/internal/server"
+)
+
+// startFeedback serves svc on a loopback listener and returns a client
+// connection.
+func startFeedback(t *testing.T, svc *Service) *grpc.ClientConn {
+	t.Helper()
+	lis, err := net.Listen("tcp", "localhost:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	srv := grpc.NewServer()
+	Register(srv, svc)
+	go srv.Serve(lis)
+	t.Cleanup(srv.Stop)
+
+	conn, err := grpc.NewClient(lis.Addr().String(), grpc.WithTransportCredentials(insecure.NewCredentials()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	return conn
+}
+
+func TestReportSegment(t *testing.T) {
+	svc := New()
+	conn := startFeedback(t, svc)
+	req, err := structpb.NewStruct(map[string]any{
+		"session_id": "sess-1",
+		"kind":       "false_positive",
+		"start_ms":   980,
+		"end_ms":     1980,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := ReportSegment(context.Background(), conn, req); status.Code(err) != codes.Unavailable {
+		t.Fatalf("before SetServer: err = %v, want Unavailable", err)
+	}
+
+	cfg := config.Config{Threshold: 0.5, Feedback: config.FeedbackTuning{Step: 0.05}}
+	svc.SetServer(server.New(cfg, nil, func() engine.Engine { return engine.NewStubEngine() }))
+	resp, err := ReportSegment(context.Background(), conn, req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	fields := resp.GetFields()
+	if fields["variant"].GetStringValue() != server.VariantBaseline || fields["threshold_offset"].GetNumberValue() != 0.05 || !fields["adjusted"].GetBoolValue() {
+		t.Errorf("response = %v, want baseline offset 0.05 adjusted", fields)
+	}
+
+	for name, bad := range map[string]map[string]any{
+		"no kind":       {"session_id": "sess-1"},
+		"unknown kind":  {"kind": "maybe"},
+		"negative":      {"kind": "false_negative", "start_ms": -1},
+		"end too early": {"kind": "false_negative", "start_ms": 500, "end_ms": 100},
+	} {
+		req, _ := structpb.NewStruct(bad)
+		if _, err := ReportSegment(context.Background(), conn, req); status.Code(err) != codes.InvalidArgument {
+			t.Errorf("%s: err = %v, want InvalidArgument", name, err)
+		}
+	}
+}
@@ -0,0 +1,163 @@
+package gateway
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"mime"
+	"net/http"
+	"net/url"
+	"strconv"
+
+	napv1 "github.com/nupi-ai/nupi/api/nap/v1"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/types/known/structpb"
+
+	"github.com/nupi-ai/plugin-vad-local-silero/internal/audio/decode"
+	"github.com/nupi-ai/plugin-vad-local-silero/internal/server"
+)
+
+// DetectPath is the path of the buffer detection endpoint.
+const DetectPath = "/v1/detect"
+
+// BufferFunc segments a complete buffer, typically BatchService's
+// DetectSpeechInBuffer behind the same interceptors as the gRPC server.
+type BufferFunc func(ctx context.Context, req *napv1.DetectSpeechRequest) (*structpb.Struct, error)
+
+// wavTypes are the Content-Types of a WAV body.
+var wavTypes = map[string]bool{
+	"audio/wav":      true,
+	"audio/wave":     true,
+	"audio/x-wav":    true,
+	"audio/vnd.wave": true,
+}
+
+// serveDetect handles POST /v1/detect: the body is a WAV file or raw PCM,
+// and the response the JSON object BatchService.DetectSpeechInBuffer
+// returns.
+func (g *Gateway) serveDetect(w http.ResponseWriter, r *http.Request) {
+	if origin := r.Header.Get("Origin"); origin != "" {
+		if u, err := url.Parse(origin); err != nil || !allowedOrigin(u, r.Host, g.opts.Origins) {
+			g.log.Warn("rejected detect request from a foreign origin", "origin", origin, "remote_addr", r.RemoteAddr)
+			writeError(w, status.Error(codes.PermissionDenied, errForbiddenOrigin.Error()))
+			return
+		}
+	}
+	var body io.Reader = r.Body
+	if g.opts.MaxMessageBytes > 0 {
+		body = http.MaxBytesReader(w, r.Body, int64(g.opts.MaxMessageBytes))
+	}
+	data, err := io.ReadAll(body)
+	if err != nil {
+		var tooLarge *http.MaxBytesError
+		if errors.As(err, &tooLarge) {
+			writeStatus(w, http.StatusRequestEntityTooLarge,
+				status.Newf(codes.ResourceExhausted, "request body larger than %d bytes", g.opts.MaxMessageBytes))
+			return
+		}
+		g.log.Debug("detect request body not read", "remote_addr", r.RemoteAddr, "error", err)
+		return
+	}
+	req, err := detectRequest(r, data)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	res, err := g.opts.Buffer(streamContext(r.Context(), r), req)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	out, err := protojson.Marshal(res)
+	if err != nil {
+		writeError(w, status.Errorf(codes.Internal, "encode response: %v", err))
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.Write(append(out, '\n'))
+}
+
+// detectRequest builds the DetectSpeechRequest for r with body data. The
+// body is taken as a WAV file when its Content-Type says so or it starts with
+// a RIFF/WAVE header, and as mono 16-bit little-endian PCM at the
+// sample_rate query parameter (default 16000) otherwise. The session_id,
+// stream_id and config query parameters fill the fields of the same name
+// (config is config_json).
+func detectRequest(r *http.Request, data []byte) (*napv1.DetectSpeechRequest, error) {
+	q := r.URL.Query()
+	var rate uint64
+	if v := q.Get("sample_rate"); v != "" {
+		var err error
+		if rate, err = strconv.ParseUint(v, 10, 32); err != nil {
+			return nil, status.Errorf(codes.InvalidArgument, "sample_rate must be a number of Hz, got %q", v)
+		}
+	}
+	req := &napv1.DetectSpeechRequest{
+		SessionId:  q.Get("session_id"),
+		StreamId:   q.Get("stream_id"),
+		ConfigJson: q.Get("config"),
+		PcmData:    data,
+	}
+	mediaType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	if wavTypes[mediaType] || decode.Container(data) == decode.ContainerWAV {
+		// A rate given for a WAV file must match the file's.
+		req.Format = &napv1.AudioFormat{Encoding: server.EncodingWAV, SampleRate: uint32(rate)}
+		return req, nil
+	}
+	if rate == 0 {
+		rate = 16000
+	}
+	req.Format = &napv1.AudioFormat{Encoding: "pcm_s16le", SampleRate: uint32(rate), Channels: 1, BitDepth: 16}
+	return req, nil
+}
+
+// writeError answers with err's status under the HTTP status matching its
+// code.
+func writeError(w http.ResponseWriter, err error) {
+	st := status.Convert(err)
+	writeStatus(w, httpStatus(st.Code()), st)
+}
+
+// writeStatus answers with st as JSON, {"code", "message"}, as the stream's
+// final status message without the type.
+func writeStatus(w http.ResponseWriter, httpCode int, st *status.Status) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(httpCode)
+	json.NewEncoder(w).Encode(map[string]string{
+		"code":    st.Code().String(),
+		"message": st.Message(),
+	})
+}
+
+// httpStatus maps a gRPC code to an HTTP status as grpc-gateway does.
+func httpStatus(code codes.Code) int {
+	switch code {
+	case codes.OK:
+		return http.StatusOK
+	case codes.InvalidArgument, codes.FailedPrecondition, codes.OutOfRange:
+		return http.StatusBadRequest
+	case codes.Unauthenticated:
+		return http.StatusUnauthorized
+	case codes.PermissionDenied:
+		return http.StatusForbidden
+	case codes.NotFound:
+		return http.StatusNotFound
+	case codes.AlreadyExists, codes.Aborted:
+		return http.StatusConflict
+	case codes.ResourceExhausted:
+		return http.StatusTooManyRequests
+	case codes.Canceled:
+		return 499 // client closed request
+	case codes.DeadlineExceeded:
+		return http.StatusGatewayTimeout
+	case codes.Unimplemented:
+		return http.StatusNotImplemented
+	case codes.Unavailable:
+		return http.StatusServiceUnavailable
+	default:
+		return http.StatusInternalServerError
+	}
+}
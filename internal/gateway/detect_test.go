@@ -0,0 +1,108 @@
+package gateway
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/json"
+	"net/http"
+	"testing"
+)
+
+// post sends body to the detect endpoint and decodes the JSON response.
+func post(t *testing.T, url, contentType string, body []byte, header ...string) (int, map[string]any) {
+	t.Helper()
+	req, err := http.NewRequest(http.MethodPost, url, bytes.NewReader(body))
+	if err != nil {
+		t.Fatal(err)
+	}
+	req.Header.Set("Content-Type", contentType)
+	for i := 0; i+1 < len(header); i += 2 {
+		req.Header.Set(header[i], header[i+1])
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	var out map[string]any
+	if err := json.NewDecoder(resp.Body).Decode(&out); err != nil {
+		t.Fatalf("status %d: %v", resp.StatusCode, err)
+	}
+	return resp.StatusCode, out
+}
+
+// wavFile builds a mono 16-bit PCM WAV file of frames silent frames.
+func wavFile(rate uint32, frames int) []byte {
+	b := []byte("RIFF")
+	b = binary.LittleEndian.AppendUint32(b, uint32(36+2*frames))
+	b = append(b, "WAVEfmt "...)
+	b = binary.LittleEndian.AppendUint32(b, 16)
+	b = binary.LittleEndian.AppendUint16(b, 1)
+	b = binary.LittleEndian.AppendUint16(b, 1)
+	b = binary.LittleEndian.AppendUint32(b, rate)
+	b = binary.LittleEndian.AppendUint32(b, 2*rate)
+	b = binary.LittleEndian.AppendUint16(b, 2)
+	b = binary.LittleEndian.AppendUint16(b, 16)
+	b = append(b, "data"...)
+	b = binary.LittleEndian.AppendUint32(b, uint32(2*frames))
+	return append(b, make([]byte, 2*frames)...)
+}
+
+func TestDetect(t *testing.T) {
+	ts := startGateway(t, Options{MaxMessageBytes: 1 << 20})
+	url := ts.URL + DetectPath
+
+	// 149 stub frames hold one segment, speech on frames 49-98, whether the
+	// body is a WAV file (sniffed without its Content-Type) or raw PCM.
+	for name, tc := range map[string]struct {
+		url, contentType string
+		body             []byte
+	}{
+		"wav":       {url, "audio/wav", wavFile(16000, 149*320)},
+		"sniffed":   {url, "application/octet-stream", wavFile(16000, 149*320)},
+		"pcm":       {url + "?session_id=s1", "application/octet-stream", make([]byte, 149*640)},
+		"pcm 16000": {url + "?sample_rate=16000", "", make([]byte, 149*640)},
+	} {
+		code, res := post(t, tc.url, tc.contentType, tc.body)
+		if code != http.StatusOK {
+			t.Fatalf("%s: status %d: %v", name, code, res)
+		}
+		segments, _ := res["segments"].([]any)
+		if len(segments) != 1 {
+			t.Fatalf("%s: segments = %v, want one", name, res["segments"])
+		}
+		sg := segments[0].(map[string]any)
+		if sg["start_ms"] != 980.0 || sg["end_ms"] != 1980.0 || res["audio_ms"] != 2980.0 {
+			t.Errorf("%s: response = %v, want a 980-1980 ms segment in 2980 ms", name, res)
+		}
+	}
+
+	for name, tc := range map[string]struct {
+		url    string
+		body   []byte
+		header []string
+		status int
+		code   string
+	}{
+		"bad rate":       {url + "?sample_rate=fast", make([]byte, 640), nil, http.StatusBadRequest, "InvalidArgument"},
+		"odd":            {url, make([]byte, 641), nil, http.StatusBadRequest, "InvalidArgument"},
+		"config":         {url + `?config={"threshold":2}`, make([]byte, 640), nil, http.StatusBadRequest, "InvalidArgument"},
+		"wav rate":       {url + "?sample_rate=8000", wavFile(16000, 320), nil, http.StatusBadRequest, "InvalidArgument"},
+		"too large":      {url, make([]byte, 1<<20+2), nil, http.StatusRequestEntityTooLarge, "ResourceExhausted"},
+		"foreign origin": {url, make([]byte, 640), []string{"Origin", "https://evil.example"}, http.StatusForbidden, "PermissionDenied"},
+	} {
+		code, res := post(t, tc.url, "application/octet-stream", tc.body, tc.header...)
+		if code != tc.status || res["code"] != tc.code {
+			t.Errorf("%s: status %d %v, want %d %s", name, code, res, tc.status, tc.code)
+		}
+	}
+
+	resp, err := http.Get(url)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusMethodNotAllowed {
+		t.Errorf("GET: status %d, want 405", resp.StatusCode)
+	}
+}
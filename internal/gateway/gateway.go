@@ -0,0 +1,155 @@
+// Package gateway serves DetectSpeech to clients that cannot speak gRPC,
+// such as browsers, over an HTTP listener. A WebSocket at /v1/stream carries
+// the same requests and events as the gRPC stream and is handled by the same
+// server code, so web demo apps need no gRPC-Web proxy.
+//
+// Client to server, each WebSocket message is one DetectSpeechRequest:
+//
+//   - a binary message is a chunk of PCM (pcm_data);
+//   - a text message is a DetectSpeechRequest in protobuf JSON, e.g.
+//     {"session_id": "s1", "format": {"sample_rate": 16000}, "config_json": "..."};
+//   - the text message {"end": true} closes the request side, as CloseSend
+//     does: the server flushes the open segment and ends the stream.
+//
+// Server to client, every message is a JSON text message with a "type":
+//
+//   - "header": the response header metadata, before the first event, e.g.
+//     {"type": "header", "metadata": {"x-nupi-vad-audio-epoch": ["..."]}};
+//   - "event": a SpeechEvent, {"type": "event", "event": "START",
+//     "confidence": 0.93, "timestamp": 1980}, where event is START, ONGOING,
+//     END, PROBABILITY or HEARTBEAT and timestamp is rendered in the
+//     configured timestamp format;
+//   - "status": the stream's final status, last, {"type": "status", "code":
+//     "OK", "message": "", "trailer": {...}}; the server then closes the
+//     connection.
+//
+// Closing the connection without {"end": true} cancels the stream.
+//
+// POST /v1/detect segments a complete recording in one request, for scripts
+// that do not need streaming: the body is a WAV file or raw PCM (see
+// detectRequest) and the response the JSON object of
+// BatchService.DetectSpeechInBuffer. Errors are {"code", "message"} under
+// the matching HTTP status.
+package gateway
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+
+	napv1 "github.com/nupi-ai/nupi/api/nap/v1"
+	"golang.org/x/net/websocket"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+
+	"github.com/nupi-ai/plugin-vad-local-silero/internal/timestamp"
+)
+
+// StreamPath is the path of the DetectSpeech WebSocket.
+const StreamPath = "/v1/stream"
+
+// DetectFunc handles one DetectSpeech stream, typically the service's
+// DetectSpeech behind the same interceptors as the gRPC server.
+type DetectFunc func(stream napv1.VoiceActivityDetectionService_DetectSpeechServer) error
+
+// Options configures a Gateway.
+type Options struct {
+	// TimestampFormat renders event timestamps; empty means
+	// timestamp.OffsetMillis.
+	TimestampFormat timestamp.Format
+	// Origins lists the origins (scheme://host[:port]) browsers may open the
+	// WebSocket or post to /v1/detect from; "*" allows any. Empty allows only pages served from
+	// the gateway's own host. Requests without an Origin header, from
+	// non-browser clients, are always allowed.
+	Origins []string
+	// MaxMessageBytes limits one WebSocket message and a detect request's
+	// body, like the gRPC receive limit.
+	MaxMessageBytes int
+	// Buffer serves POST /v1/detect; nil leaves the endpoint out.
+	Buffer BufferFunc
+}
+
+// Gateway serves the HTTP endpoints.
+type Gateway struct {
+	detect DetectFunc
+	opts   Options
+	log    *slog.Logger
+}
+
+// New returns a Gateway handing streams to detect.
+func New(detect DetectFunc, opts Options, logger *slog.Logger) *Gateway {
+	if opts.TimestampFormat == "" {
+		opts.TimestampFormat = timestamp.OffsetMillis
+	}
+	if logger == nil {
+		logger = slog.Default()
+	}
+	return &Gateway{detect: detect, opts: opts, log: logger}
+}
+
+// Handler returns the gateway's routes.
+func (g *Gateway) Handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.Handle("GET "+StreamPath, websocket.Server{
+		Handshake: g.checkOrigin,
+		Handler:   g.serveStream,
+	})
+	if g.opts.Buffer != nil {
+		mux.HandleFunc("POST "+DetectPath, g.serveDetect)
+	}
+	return mux
+}
+
+// checkOrigin rejects WebSocket handshakes from browser pages on origins
+// not listed in Options.Origins, so a page on another site cannot use a
+// visitor's network position to reach the adapter.
+func (g *Gateway) checkOrigin(cfg *websocket.Config, req *http.Request) error {
+	origin, err := websocket.Origin(cfg, req)
+	if err != nil {
+		return err
+	}
+	cfg.Origin = origin
+	if origin == nil || allowedOrigin(origin, req.Host, g.opts.Origins) {
+		return nil
+	}
+	g.log.Warn("rejected WebSocket from a foreign origin", "origin", origin.String(), "remote_addr", req.RemoteAddr)
+	return errForbiddenOrigin
+}
+
+var errForbiddenOrigin = errors.New("origin not allowed")
+
+func allowedOrigin(origin *url.URL, host string, allowed []string) bool {
+	if len(allowed) == 0 {
+		return strings.EqualFold(origin.Host, host)
+	}
+	for _, a := range allowed {
+		if a == "*" || strings.EqualFold(strings.TrimSuffix(a, "/"), origin.Scheme+"://"+origin.Host) {
+			return true
+		}
+	}
+	return false
+}
+
+// streamContext returns the context a stream from req runs in: the client
+// address as its peer and, as incoming metadata, the authorization header or
+// the token query parameter (browsers cannot set headers on a WebSocket).
+func streamContext(ctx context.Context, req *http.Request) context.Context {
+	md := metadata.MD{}
+	if auth := req.Header.Get("Authorization"); auth != "" {
+		md.Set("authorization", auth)
+	} else if token := req.URL.Query().Get("token"); token != "" {
+		md.Set("authorization", "Bearer "+token)
+	}
+	if ua := req.Header.Get("User-Agent"); ua != "" {
+		md.Set("user-agent", ua)
+	}
+	ctx = metadata.NewIncomingContext(ctx, md)
+	if addr, err := net.ResolveTCPAddr("tcp", req.RemoteAddr); err == nil {
+		ctx = peer.NewContext(ctx, &peer.Peer{Addr: addr})
+	}
+	return ctx
+}
@@ -0,0 +1,142 @@
+package gateway
+
+import (
+	"encoding/json"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"golang.org/x/net/websocket"
+
+	"github.com/nupi-ai/plugin-vad-local-silero/internal/batch"
+	"github.com/nupi-ai/plugin-vad-local-silero/internal/config"
+	"github.com/nupi-ai/plugin-vad-local-silero/internal/engine"
+	"github.com/nupi-ai/plugin-vad-local-silero/internal/server"
+)
+
+// startGateway serves a gateway, with the detect endpoint, in front of a
+// stub engine server.
+func startGateway(t *testing.T, opts Options) *httptest.Server {
+	t.Helper()
+	cfg := config.Config{Threshold: 0.5, MinSpeechDurationMs: 20, MinSilenceDurationMs: 20}
+	srv := server.New(cfg, nil, func() engine.Engine { return engine.NewStubEngine() })
+	svc := batch.New()
+	svc.SetServer(srv)
+	opts.Buffer = svc.DetectSpeechInBuffer
+	ts := httptest.NewServer(New(srv.DetectSpeech, opts, nil).Handler())
+	t.Cleanup(ts.Close)
+	return ts
+}
+
+func dial(t *testing.T, ts *httptest.Server, origin string) *websocket.Conn {
+	t.Helper()
+	ws, err := websocket.Dial(strings.Replace(ts.URL, "http", "ws", 1)+StreamPath, "", origin)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { ws.Close() })
+	return ws
+}
+
+// readAll returns the messages the server sends until it closes the
+// connection.
+func readAll(t *testing.T, ws *websocket.Conn) []map[string]any {
+	t.Helper()
+	var msgs []map[string]any
+	for {
+		var data string
+		if err := websocket.Message.Receive(ws, &data); err != nil {
+			return msgs
+		}
+		var msg map[string]any
+		if err := json.Unmarshal([]byte(data), &msg); err != nil {
+			t.Fatalf("message %q: %v", data, err)
+		}
+		msgs = append(msgs, msg)
+	}
+}
+
+func TestStream(t *testing.T) {
+	ts := startGateway(t, Options{})
+	ws := dial(t, ts, ts.URL)
+
+	if err := websocket.Message.Send(ws, `{"session_id": "s1", "format": {"sample_rate": 16000}}`); err != nil {
+		t.Fatal(err)
+	}
+	chunk := make([]byte, 640)
+	for i := 0; i < engine.StubToggleInterval*2; i++ {
+		if err := websocket.Message.Send(ws, chunk); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := websocket.Message.Send(ws, `{"end": true}`); err != nil {
+		t.Fatal(err)
+	}
+	msgs := readAll(t, ws)
+
+	if len(msgs) < 4 || msgs[0]["type"] != "header" || msgs[len(msgs)-1]["type"] != "status" {
+		t.Fatalf("messages = %v, want header, events, status", msgs)
+	}
+	md := msgs[0]["metadata"].(map[string]any)
+	if md[server.AudioEpochHeader] == nil {
+		t.Errorf("header metadata = %v, want the audio epoch", md)
+	}
+	var events []string
+	for _, msg := range msgs[1 : len(msgs)-1] {
+		if msg["type"] != "event" {
+			t.Fatalf("message = %v, want an event", msg)
+		}
+		if msg["event"] == "ONGOING" {
+			continue
+		}
+		events = append(events, msg["event"].(string))
+		// offset_ms by default: the stub speaks from its 49th 20 ms chunk.
+		if offset, ok := msg["timestamp"].(float64); !ok || offset < 960 {
+			t.Errorf("%s timestamp = %v, want an audio offset in ms", msg["event"], msg["timestamp"])
+		}
+	}
+	if strings.Join(events, ",") != "START,END" {
+		t.Errorf("events = %v, want START,END", events)
+	}
+	st := msgs[len(msgs)-1]
+	if st["code"] != "OK" {
+		t.Errorf("status = %v, want OK", st)
+	}
+	if trailer := st["trailer"].(map[string]any); trailer[server.EventCountTrailer] == nil {
+		t.Errorf("trailer = %v, want the event count", trailer)
+	}
+}
+
+func TestStreamError(t *testing.T) {
+	ts := startGateway(t, Options{})
+	ws := dial(t, ts, ts.URL)
+	if err := websocket.Message.Send(ws, `{"config_json": "{\"threshold\": 2}", "format": {"sample_rate": 16000}, "pcm_data": "AAAAAA=="}`); err != nil {
+		t.Fatal(err)
+	}
+	msgs := readAll(t, ws)
+	if len(msgs) == 0 {
+		t.Fatal("no messages")
+	}
+	if st := msgs[len(msgs)-1]; st["type"] != "status" || st["code"] != "InvalidArgument" || !strings.Contains(st["message"].(string), "threshold") {
+		t.Errorf("status = %v, want InvalidArgument about the threshold", st)
+	}
+
+	ws = dial(t, ts, ts.URL)
+	websocket.Message.Send(ws, `{"sessionId": 5}`)
+	if msgs := readAll(t, ws); len(msgs) == 0 || msgs[len(msgs)-1]["code"] != "InvalidArgument" {
+		t.Errorf("malformed request: messages = %v, want InvalidArgument", msgs)
+	}
+}
+
+func TestOrigins(t *testing.T) {
+	ts := startGateway(t, Options{})
+	if _, err := websocket.Dial(strings.Replace(ts.URL, "http", "ws", 1)+StreamPath, "", "https://evil.example"); err == nil {
+		t.Error("foreign origin accepted")
+	}
+
+	ts = startGateway(t, Options{Origins: []string{"https://app.example"}})
+	dial(t, ts, "https://app.example")
+	if _, err := websocket.Dial(strings.Replace(ts.URL, "http", "ws", 1)+StreamPath, "", ts.URL); err == nil {
+		t.Error("origin outside the list accepted")
+	}
+}
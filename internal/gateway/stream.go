@@ -0,0 +1,241 @@
+package gateway
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"sync"
+	"time"
+
+	napv1 "github.com/nupi-ai/nupi/api/nap/v1"
+	"golang.org/x/net/websocket"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/proto"
+
+	"github.com/nupi-ai/plugin-vad-local-silero/internal/server"
+	"github.com/nupi-ai/plugin-vad-local-silero/internal/timestamp"
+)
+
+// serveStream runs one DetectSpeech stream over ws.
+func (g *Gateway) serveStream(ws *websocket.Conn) {
+	ctx, cancel := context.WithCancel(streamContext(ws.Request().Context(), ws.Request()))
+	defer cancel()
+	if g.opts.MaxMessageBytes > 0 {
+		ws.MaxPayloadBytes = g.opts.MaxMessageBytes
+	}
+	s := &wsStream{ctx: ctx, cancel: cancel, ws: ws, tsFormat: g.opts.TimestampFormat}
+	err := g.detect(s)
+	if err := s.finish(err); err != nil {
+		g.log.Debug("WebSocket status not delivered", "remote_addr", ws.Request().RemoteAddr, "error", err)
+	}
+}
+
+// wsStream is a DetectSpeech stream over a WebSocket (see the package
+// comment for the framing). Recv runs on the server's receive goroutine
+// while events are sent from the handler, so writes are serialized by mu.
+type wsStream struct {
+	ctx      context.Context
+	cancel   context.CancelFunc
+	ws       *websocket.Conn
+	tsFormat timestamp.Format
+
+	mu         sync.Mutex
+	header     metadata.MD
+	headerSent bool
+	epoch      time.Time
+	trailer    metadata.MD
+}
+
+// endMessage is the text message closing the request side.
+type endMessage struct {
+	End bool `json:"end"`
+}
+
+// frameCodec receives a message with its payload type, so binary PCM can be
+// told from JSON requests.
+var frameCodec = websocket.Codec{
+	Unmarshal: func(data []byte, payloadType byte, v any) error {
+		f := v.(*frame)
+		f.binary = payloadType == websocket.BinaryFrame
+		f.data = data
+		return nil
+	},
+}
+
+type frame struct {
+	binary bool
+	data   []byte
+}
+
+func (s *wsStream) Recv() (*napv1.DetectSpeechRequest, error) {
+	var f frame
+	if err := frameCodec.Receive(s.ws, &f); err != nil {
+		// The connection closed without {"end": true}: the client is
+		// gone, as a cancelled gRPC stream.
+		s.cancel()
+		if errors.Is(err, websocket.ErrFrameTooLarge) {
+			return nil, status.Errorf(codes.ResourceExhausted, "WebSocket message larger than %d bytes", s.ws.MaxPayloadBytes)
+		}
+		return nil, status.Error(codes.Canceled, "WebSocket closed by the client")
+	}
+	if f.binary {
+		return &napv1.DetectSpeechRequest{PcmData: f.data}, nil
+	}
+	var end endMessage
+	if json.Unmarshal(f.data, &end) == nil && end.End {
+		return nil, io.EOF
+	}
+	req := &napv1.DetectSpeechRequest{}
+	if err := protojson.Unmarshal(f.data, req); err != nil {
+		return nil, status.Errorf(codes.InvalidArgument, "invalid request message: %v", err)
+	}
+	return req, nil
+}
+
+func (s *wsStream) Send(evt *napv1.SpeechEvent) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if err := s.sendHeaderLocked(); err != nil {
+		return err
+	}
+	return s.write(s.eventMessage(evt))
+}
+
+// eventMessage is the JSON form of evt.
+func (s *wsStream) eventMessage(evt *napv1.SpeechEvent) map[string]any {
+	msg := map[string]any{
+		"type":       "event",
+		"event":      eventName(evt),
+		"confidence": evt.GetConfidence(),
+	}
+	if ts := evt.GetTimestamp(); ts != nil {
+		msg["timestamp"] = s.tsFormat.Value(ts.AsTime(), s.epoch)
+	}
+	return msg
+}
+
+// eventName names evt's type without the enum prefix, and tells
+// probability samples from heartbeats, which share the unspecified type.
+func eventName(evt *napv1.SpeechEvent) string {
+	switch {
+	case server.IsHeartbeat(evt):
+		return "HEARTBEAT"
+	case evt.GetType() == server.ProbabilityEventType:
+		return "PROBABILITY"
+	case evt.GetType() == napv1.SpeechEventType_SPEECH_EVENT_TYPE_START:
+		return "START"
+	case evt.GetType() == napv1.SpeechEventType_SPEECH_EVENT_TYPE_ONGOING:
+		return "ONGOING"
+	case evt.GetType() == napv1.SpeechEventType_SPEECH_EVENT_TYPE_END:
+		return "END"
+	default:
+		return evt.GetType().String()
+	}
+}
+
+func (s *wsStream) SetHeader(md metadata.MD) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.headerSent {
+		return errors.New("gateway: header already sent")
+	}
+	s.header = metadata.Join(s.header, md)
+	return nil
+}
+
+func (s *wsStream) SendHeader(md metadata.MD) error {
+	if err := s.SetHeader(md); err != nil {
+		return err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.sendHeaderLocked()
+}
+
+// sendHeaderLocked sends the header unless it was sent already; s.mu must
+// be held.
+func (s *wsStream) sendHeaderLocked() error {
+	if s.headerSent {
+		return nil
+	}
+	s.headerSent = true
+	s.epoch, _ = server.ParseAudioEpoch(s.header)
+	return s.write(map[string]any{"type": "header", "metadata": metadataJSON(s.header)})
+}
+
+func (s *wsStream) SetTrailer(md metadata.MD) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.trailer = metadata.Join(s.trailer, md)
+}
+
+func (s *wsStream) Context() context.Context { return s.ctx }
+
+func (s *wsStream) SendMsg(m any) error {
+	evt, ok := m.(*napv1.SpeechEvent)
+	if !ok {
+		return errors.New("gateway: unexpected message type")
+	}
+	return s.Send(evt)
+}
+
+func (s *wsStream) RecvMsg(m any) error {
+	req, err := s.Recv()
+	if err != nil {
+		return err
+	}
+	out, ok := m.(*napv1.DetectSpeechRequest)
+	if !ok {
+		return errors.New("gateway: unexpected message type")
+	}
+	proto.Reset(out)
+	proto.Merge(out, req)
+	return nil
+}
+
+// finish sends the header, if no event did, and the status of the stream's
+// error, then closes the connection.
+func (s *wsStream) finish(err error) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	defer s.ws.Close()
+	if s.ctx.Err() != nil && status.Code(err) == codes.Canceled {
+		return nil // the client is gone
+	}
+	if err := s.sendHeaderLocked(); err != nil {
+		return err
+	}
+	st := status.Convert(err)
+	return s.write(map[string]any{
+		"type":    "status",
+		"code":    st.Code().String(),
+		"message": st.Message(),
+		"trailer": metadataJSON(s.trailer),
+	})
+}
+
+// write sends msg as a JSON text message.
+func (s *wsStream) write(msg map[string]any) error {
+	data, err := json.Marshal(msg)
+	if err != nil {
+		return fmt.Errorf("gateway: %w", err)
+	}
+	if err := websocket.Message.Send(s.ws, string(data)); err != nil {
+		s.cancel()
+		return status.Errorf(codes.Unavailable, "WebSocket send: %v", err)
+	}
+	return nil
+}
+
+// metadataJSON returns md for a JSON message, never null.
+func metadataJSON(md metadata.MD) metadata.MD {
+	if md == nil {
+		return metadata.MD{}
+	}
+	return md
+}
@@ -0,0 +1,82 @@
+package metrics
+
+import (
+	"expvar"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+)
+
+// Registry holds named, labeled counters. It has no external dependencies so
+// every build of the adapter carries the same basic observability.
+// Safe for concurrent use.
+type Registry struct {
+	mu       sync.Mutex
+	counters map[string]*Counter
+}
+
+// NewRegistry returns an empty Registry.
+func NewRegistry() *Registry {
+	return &Registry{counters: make(map[string]*Counter)}
+}
+
+// Counter returns the counter identified by name and label pairs
+// ("key", "value", ...), creating it on first use. Callers on hot paths
+// should resolve counters once and keep the returned pointer.
+func (r *Registry) Counter(name string, labels ...string) *Counter {
+	key := formatKey(name, labels)
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	c, ok := r.counters[key]
+	if !ok {
+		c = &Counter{}
+		r.counters[key] = c
+	}
+	return c
+}
+
+// Snapshot returns the current value of every counter keyed by its
+// Prometheus-style identifier, e.g. streams_total{variant="baseline"}.
+func (r *Registry) Snapshot() map[string]int64 {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	out := make(map[string]int64, len(r.counters))
+	for key, c := range r.counters {
+		out[key] = c.Value()
+	}
+	return out
+}
+
+// Var returns an expvar.Var that renders the registry's Snapshot as a JSON
+// object, for publishing with expvar.Publish.
+func (r *Registry) Var() expvar.Var {
+	return expvar.Func(func() any { return r.Snapshot() })
+}
+
+// Counter is a monotonically increasing int64.
+type Counter struct {
+	v atomic.Int64
+}
+
+// Add increments the counter by n.
+func (c *Counter) Add(n int64) { c.v.Add(n) }
+
+// Inc increments the counter by one.
+func (c *Counter) Inc() { c.v.Add(1) }
+
+// Value returns the current count.
+func (c *Counter) Value() int64 { return c.v.Load() }
+
+// formatKey renders name{k="v",...}. A trailing unpaired label is ignored.
+func formatKey(name string, labels []string) string {
+	if len(labels) < 2 {
+		return name
+	}
+	pairs := make([]string, 0, len(labels)/2)
+	for i := 0; i+1 < len(labels); i += 2 {
+		pairs = append(pairs, labels[i]+"=\""+labels[i+1]+"\"")
+	}
+	sort.Strings(pairs)
+	return name + "{" + strings.Join(pairs, ",") + "}"
+}
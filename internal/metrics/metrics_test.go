@@ -0,0 +1,53 @@
+package metrics
+
+import "testing"
+
+func TestCounterSameKeyShared(t *testing.T) {
+	r := NewRegistry()
+	a := r.Counter("streams_total", "variant", "baseline")
+	b := r.Counter("streams_total", "variant", "baseline")
+	a.Inc()
+	b.Add(2)
+	if a != b {
+		t.Fatal("expected the same counter for identical name and labels")
+	}
+	if got := a.Value(); got != 3 {
+		t.Fatalf("Value() = %d, want 3", got)
+	}
+}
+
+func TestSnapshotKeys(t *testing.T) {
+	r := NewRegistry()
+	r.Counter("frames_total").Add(5)
+	r.Counter("streams_total", "variant", "candidate", "engine", "stub").Inc()
+
+	snap := r.Snapshot()
+	if snap["frames_total"] != 5 {
+		t.Errorf("frames_total = %d, want 5", snap["frames_total"])
+	}
+	// Labels are sorted so call-site order does not create duplicate series.
+	key := `streams_total{engine="stub",variant="candidate"}`
+	if snap[key] != 1 {
+		t.Errorf("%s = %d, want 1 (snapshot: %v)", key, snap[key], snap)
+	}
+}
+
+func TestFormatKeyIgnoresUnpairedLabel(t *testing.T) {
+	if got := formatKey("x", []string{"a"}); got != "x" {
+		t.Errorf("formatKey = %q, want %q", got, "x")
+	}
+	if got := formatKey("x", []string{"a", "1", "b"}); got != `x{a="1"}` {
+		t.Errorf("formatKey = %q, want %q", got, `x{a="1"}`)
+	}
+}
+
+func TestVarRendersSnapshot(t *testing.T) {
+	r := NewRegistry()
+	r.Counter("frames_total").Add(7)
+	r.Counter("streams_total", "variant", "baseline").Inc()
+
+	want := `{"frames_total":7,"streams_total{variant=\"baseline\"}":1}`
+	if got := r.Var().String(); got != want {
+		t.Errorf("Var().String() = %s, want %s", got, want)
+	}
+}
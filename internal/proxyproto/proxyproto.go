@@ -0,0 +1,261 @@
+// Package proxyproto accepts connections behind an L4 load balancer that
+// prepends a PROXY protocol header (v1 text or v2 binary), so the adapter
+// sees the real client address instead of the balancer's. See
+// https://www.haproxy.org/download/2.9/doc/proxy-protocol.txt.
+package proxyproto
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// DefaultHeaderTimeout bounds how long a new connection may take to send
+// its header.
+const DefaultHeaderTimeout = 5 * time.Second
+
+// v1MaxLen is the longest valid v1 header, CRLF included.
+const v1MaxLen = 107
+
+var (
+	v1Prefix    = []byte("PROXY ")
+	v2Signature = []byte("\r\n\r\n\x00\r\nQUIT\n")
+)
+
+// ErrNoHeader means a connection did not start with a PROXY header. Every
+// connection on a PROXY listener must carry one.
+var ErrNoHeader = errors.New("proxyproto: connection did not start with a PROXY protocol header")
+
+// listener wraps accepted connections so their header is parsed on first
+// use, in the connection's own goroutine: a slow or silent client never
+// holds up Accept.
+type listener struct {
+	net.Listener
+	timeout time.Duration
+	onError func(remote net.Addr, err error)
+}
+
+// NewListener returns a listener whose connections report the client address
+// from their PROXY header. A connection whose header is missing, malformed
+// or not received within timeout (DefaultHeaderTimeout if zero) fails on
+// first read, after onError, if non-nil, is called with the balancer's
+// address and the error. Headers with the LOCAL command (v2) or UNKNOWN
+// protocol (v1), e.g. balancer health checks, keep the connection's own
+// addresses.
+func NewListener(l net.Listener, timeout time.Duration, onError func(remote net.Addr, err error)) net.Listener {
+	if timeout <= 0 {
+		timeout = DefaultHeaderTimeout
+	}
+	return &listener{Listener: l, timeout: timeout, onError: onError}
+}
+
+func (l *listener) Accept() (net.Conn, error) {
+	c, err := l.Listener.Accept()
+	if err != nil {
+		return nil, err
+	}
+	return &conn{Conn: c, l: l, r: bufio.NewReader(c)}, nil
+}
+
+// conn is a connection whose PROXY header is read on first use.
+type conn struct {
+	net.Conn
+	l    *listener
+	r    *bufio.Reader
+	once sync.Once
+	src  net.Addr
+	dst  net.Addr
+	err  error
+
+	// deadline is the read deadline set by the connection's user, restored
+	// once the header is read.
+	mu       sync.Mutex
+	deadline time.Time
+}
+
+func (c *conn) init() {
+	c.once.Do(func() {
+		c.mu.Lock()
+		user := c.deadline
+		c.mu.Unlock()
+		headerDeadline := time.Now().Add(c.l.timeout)
+		if !user.IsZero() && user.Before(headerDeadline) {
+			headerDeadline = user
+		}
+		c.Conn.SetReadDeadline(headerDeadline)
+		c.src, c.dst, c.err = readHeader(c.r)
+		c.mu.Lock()
+		c.Conn.SetReadDeadline(c.deadline)
+		c.mu.Unlock()
+		if c.err != nil && c.l.onError != nil {
+			c.l.onError(c.Conn.RemoteAddr(), c.err)
+		}
+	})
+}
+
+func (c *conn) Read(b []byte) (int, error) {
+	c.init()
+	if c.err != nil {
+		return 0, c.err
+	}
+	return c.r.Read(b)
+}
+
+func (c *conn) SetDeadline(t time.Time) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.deadline = t
+	return c.Conn.SetDeadline(t)
+}
+
+func (c *conn) SetReadDeadline(t time.Time) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.deadline = t
+	return c.Conn.SetReadDeadline(t)
+}
+
+// RemoteAddr returns the client address from the header.
+func (c *conn) RemoteAddr() net.Addr {
+	c.init()
+	if c.src != nil {
+		return c.src
+	}
+	return c.Conn.RemoteAddr()
+}
+
+// LocalAddr returns the address the client connected to, from the header.
+func (c *conn) LocalAddr() net.Addr {
+	c.init()
+	if c.dst != nil {
+		return c.dst
+	}
+	return c.Conn.LocalAddr()
+}
+
+// readHeader consumes a v1 or v2 header from r and returns its source and
+// destination addresses, both nil for LOCAL and UNKNOWN headers.
+func readHeader(r *bufio.Reader) (src, dst net.Addr, err error) {
+	start, err := r.Peek(len(v1Prefix))
+	if err != nil {
+		return nil, nil, headerError(err)
+	}
+	if bytes.Equal(start, v1Prefix) {
+		return readV1(r)
+	}
+	sig, err := r.Peek(len(v2Signature))
+	if err != nil {
+		return nil, nil, headerError(err)
+	}
+	if bytes.Equal(sig, v2Signature) {
+		return readV2(r)
+	}
+	return nil, nil, ErrNoHeader
+}
+
+func headerError(err error) error {
+	if errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF) {
+		return ErrNoHeader
+	}
+	return fmt.Errorf("proxyproto: read header: %w", err)
+}
+
+// readV1 parses "PROXY TCP4|TCP6 src dst sport dport\r\n" or
+// "PROXY UNKNOWN ...\r\n".
+func readV1(r *bufio.Reader) (src, dst net.Addr, err error) {
+	var line []byte
+	for len(line) < v1MaxLen {
+		b, err := r.ReadByte()
+		if err != nil {
+			return nil, nil, headerError(err)
+		}
+		line = append(line, b)
+		if bytes.HasSuffix(line, []byte("\r\n")) {
+			break
+		}
+	}
+	if !bytes.HasSuffix(line, []byte("\r\n")) {
+		return nil, nil, errors.New("proxyproto: v1 header longer than 107 bytes")
+	}
+	fields := strings.Split(strings.TrimSuffix(string(line), "\r\n"), " ")
+	if len(fields) >= 2 && fields[1] == "UNKNOWN" {
+		return nil, nil, nil
+	}
+	if len(fields) != 6 || (fields[1] != "TCP4" && fields[1] != "TCP6") {
+		return nil, nil, fmt.Errorf("proxyproto: malformed v1 header %q", line)
+	}
+	srcIP, dstIP := net.ParseIP(fields[2]), net.ParseIP(fields[3])
+	srcPort, err1 := parsePort(fields[4])
+	dstPort, err2 := parsePort(fields[5])
+	if srcIP == nil || dstIP == nil || err1 != nil || err2 != nil ||
+		(fields[1] == "TCP4") != (srcIP.To4() != nil && !strings.Contains(fields[2], ":")) {
+		return nil, nil, fmt.Errorf("proxyproto: malformed v1 header %q", line)
+	}
+	return &net.TCPAddr{IP: srcIP, Port: srcPort}, &net.TCPAddr{IP: dstIP, Port: dstPort}, nil
+}
+
+func parsePort(s string) (int, error) {
+	p, err := strconv.Atoi(s)
+	if err != nil || p < 0 || p > 65535 || (len(s) > 1 && s[0] == '0') {
+		return 0, fmt.Errorf("invalid port %q", s)
+	}
+	return p, nil
+}
+
+// v2 command and address family values.
+const (
+	v2Version = 0x20
+	v2Local   = 0x00
+	v2Proxy   = 0x01
+	v2TCP4    = 0x11
+	v2TCP6    = 0x21
+)
+
+// readV2 parses a binary header. TLVs after the addresses are skipped.
+func readV2(r *bufio.Reader) (src, dst net.Addr, err error) {
+	head := make([]byte, 16)
+	if _, err := io.ReadFull(r, head); err != nil {
+		return nil, nil, headerError(err)
+	}
+	verCmd, family := head[12], head[13]
+	body := make([]byte, binary.BigEndian.Uint16(head[14:16]))
+	if _, err := io.ReadFull(r, body); err != nil {
+		return nil, nil, headerError(err)
+	}
+	if verCmd&0xF0 != v2Version {
+		return nil, nil, fmt.Errorf("proxyproto: unsupported v2 version %#x", verCmd>>4)
+	}
+	switch verCmd & 0x0F {
+	case v2Local:
+		return nil, nil, nil
+	case v2Proxy:
+	default:
+		return nil, nil, fmt.Errorf("proxyproto: unsupported v2 command %#x", verCmd&0x0F)
+	}
+	switch family {
+	case v2TCP4:
+		if len(body) < 12 {
+			return nil, nil, errors.New("proxyproto: v2 TCP4 address block too short")
+		}
+		return &net.TCPAddr{IP: net.IP(body[0:4]), Port: int(binary.BigEndian.Uint16(body[8:10]))},
+			&net.TCPAddr{IP: net.IP(body[4:8]), Port: int(binary.BigEndian.Uint16(body[10:12]))}, nil
+	case v2TCP6:
+		if len(body) < 36 {
+			return nil, nil, errors.New("proxyproto: v2 TCP6 address block too short")
+		}
+		return &net.TCPAddr{IP: net.IP(body[0:16]), Port: int(binary.BigEndian.Uint16(body[32:34]))},
+			&net.TCPAddr{IP: net.IP(body[16:32]), Port: int(binary.BigEndian.Uint16(body[34:36]))}, nil
+	default:
+		// UDP and UNIX sources cannot reach a TCP listener meaningfully;
+		// like UNSPEC, keep the connection's addresses.
+		return nil, nil, nil
+	}
+}
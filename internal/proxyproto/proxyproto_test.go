@@ -0,0 +1,123 @@
+package proxyproto
+
+import (
+	"encoding/binary"
+	"errors"
+	"io"
+	"net"
+	"testing"
+	"time"
+)
+
+// roundTrip sends data to a PROXY listener and returns the accepted
+// connection's remote address and what it read.
+func roundTrip(t *testing.T, data []byte, timeout time.Duration) (net.Addr, []byte, error) {
+	t.Helper()
+	inner, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var reported error
+	l := NewListener(inner, timeout, func(_ net.Addr, err error) { reported = err })
+	defer l.Close()
+
+	client, err := net.Dial("tcp", inner.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer client.Close()
+	if _, err := client.Write(data); err != nil {
+		t.Fatal(err)
+	}
+	if timeout == 0 {
+		client.(*net.TCPConn).CloseWrite()
+	}
+
+	c, err := l.Accept()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+	got, err := io.ReadAll(c)
+	if err != nil {
+		if reported == nil {
+			t.Errorf("read failed (%v) without calling onError", err)
+		}
+		return nil, nil, err
+	}
+	return c.RemoteAddr(), got, nil
+}
+
+func v2Header(cmd, family byte, addrs []byte) []byte {
+	h := append([]byte{}, v2Signature...)
+	h = append(h, v2Version|cmd, family)
+	h = binary.BigEndian.AppendUint16(h, uint16(len(addrs)))
+	return append(h, addrs...)
+}
+
+func TestHeaders(t *testing.T) {
+	tcp4 := []byte{203, 0, 113, 7, 10, 0, 0, 1}
+	tcp4 = binary.BigEndian.AppendUint16(tcp4, 51000)
+	tcp4 = binary.BigEndian.AppendUint16(tcp4, 50051)
+	tcp6 := append(net.ParseIP("2001:db8::7").To16(), net.ParseIP("fd00::1").To16()...)
+	tcp6 = binary.BigEndian.AppendUint16(tcp6, 51000)
+	tcp6 = binary.BigEndian.AppendUint16(tcp6, 50051)
+	tcp6 = append(tcp6, 0x04, 0x00, 0x01, 0xff) // a TLV, skipped
+
+	tests := []struct {
+		name   string
+		header []byte
+		want   string // "" keeps the connection's own address
+	}{
+		{"v1 tcp4", []byte("PROXY TCP4 203.0.113.7 10.0.0.1 51000 50051\r\n"), "203.0.113.7:51000"},
+		{"v1 tcp6", []byte("PROXY TCP6 2001:db8::7 fd00::1 51000 50051\r\n"), "[2001:db8::7]:51000"},
+		{"v1 unknown", []byte("PROXY UNKNOWN\r\n"), ""},
+		{"v2 tcp4", v2Header(v2Proxy, v2TCP4, tcp4), "203.0.113.7:51000"},
+		{"v2 tcp6", v2Header(v2Proxy, v2TCP6, tcp6), "[2001:db8::7]:51000"},
+		{"v2 local", v2Header(v2Local, 0, nil), ""},
+	}
+	for _, tt := range tests {
+		addr, got, err := roundTrip(t, append(tt.header, "payload"...), 0)
+		if err != nil {
+			t.Errorf("%s: %v", tt.name, err)
+			continue
+		}
+		if string(got) != "payload" {
+			t.Errorf("%s: read %q, want the payload after the header", tt.name, got)
+		}
+		if tt.want != "" && addr.String() != tt.want {
+			t.Errorf("%s: RemoteAddr = %v, want %s", tt.name, addr, tt.want)
+		}
+		if tt.want == "" && !addr.(*net.TCPAddr).IP.IsLoopback() {
+			t.Errorf("%s: RemoteAddr = %v, want the loopback client", tt.name, addr)
+		}
+	}
+}
+
+func TestInvalidHeaders(t *testing.T) {
+	for name, data := range map[string][]byte{
+		"none":         []byte("PRI * HTTP/2.0\r\n\r\nSM\r\n\r\n"),
+		"short":        []byte("GET"),
+		"v1 bad port":  []byte("PROXY TCP4 203.0.113.7 10.0.0.1 99999 50051\r\n"),
+		"v1 family":    []byte("PROXY TCP4 2001:db8::7 fd00::1 51000 50051\r\n"),
+		"v1 too long":  append([]byte("PROXY TCP4 "), make([]byte, 200)...),
+		"v2 short":     v2Header(v2Proxy, v2TCP4, []byte{1, 2, 3}),
+		"v2 truncated": v2Header(v2Proxy, v2TCP4, nil)[:14],
+	} {
+		if _, _, err := roundTrip(t, data, 0); err == nil {
+			t.Errorf("%s: connection accepted", name)
+		}
+	}
+	if _, _, err := roundTrip(t, []byte("PROXY TCP4"), 0); !errors.Is(err, ErrNoHeader) {
+		t.Errorf("header cut short: err = %v, want ErrNoHeader", err)
+	}
+}
+
+func TestHeaderTimeout(t *testing.T) {
+	// The client sends half a header and waits.
+	_, _, err := roundTrip(t, []byte("PROXY "), 50*time.Millisecond)
+	var ne net.Error
+	if !errors.As(err, &ne) || !ne.Timeout() {
+		t.Errorf("err = %v, want a timeout", err)
+	}
+}
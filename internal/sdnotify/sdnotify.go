@@ -0,0 +1,95 @@
+// Package sdnotify implements the client side of the systemd service
+// notification protocol (sd_notify(3)), so the adapter can run as a
+// Type=notify unit with a watchdog. It needs no cgo or systemd library: the
+// protocol is a datagram of newline-separated VAR=value assignments sent to
+// the socket named by NOTIFY_SOCKET.
+package sdnotify
+
+import (
+	"fmt"
+	"net"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Standard notification states.
+const (
+	Ready    = "READY=1"
+	Stopping = "STOPPING=1"
+	Watchdog = "WATCHDOG=1"
+)
+
+// Status returns the STATUS= assignment systemctl status shows for the unit.
+func Status(msg string) string {
+	return "STATUS=" + msg
+}
+
+// Notifier sends notifications to the service manager. Its zero value, and
+// the Notifier of a process not started by systemd, is disabled: Notify is
+// a no-op.
+type Notifier struct {
+	socket   string
+	watchdog time.Duration
+}
+
+// New reads NOTIFY_SOCKET, WATCHDOG_USEC and WATCHDOG_PID through lookup
+// (os.LookupEnv when nil).
+func New(lookup func(string) (string, bool)) *Notifier {
+	if lookup == nil {
+		lookup = os.LookupEnv
+	}
+	n := &Notifier{}
+	socket, _ := lookup("NOTIFY_SOCKET")
+	if socket == "" {
+		return n
+	}
+	// A leading '@' names a Linux abstract socket.
+	if strings.HasPrefix(socket, "@") {
+		socket = "\x00" + socket[1:]
+	}
+	n.socket = socket
+	if usec, ok := lookup("WATCHDOG_USEC"); ok {
+		v, err := strconv.ParseUint(usec, 10, 63)
+		pid, hasPID := lookup("WATCHDOG_PID")
+		// The watchdog is for us unless WATCHDOG_PID names another process,
+		// e.g. a wrapper script that exec'd something else.
+		if err == nil && v > 0 && (!hasPID || pid == strconv.Itoa(os.Getpid())) {
+			n.watchdog = time.Duration(v) * time.Microsecond
+		}
+	}
+	return n
+}
+
+// Enabled reports whether the process runs under a service manager that
+// accepts notifications.
+func (n *Notifier) Enabled() bool {
+	return n != nil && n.socket != ""
+}
+
+// WatchdogInterval returns how often WATCHDOG=1 must be sent: half the
+// unit's WatchdogSec, as sd_watchdog_enabled(3) recommends. Zero means the
+// watchdog is disabled.
+func (n *Notifier) WatchdogInterval() time.Duration {
+	if !n.Enabled() {
+		return 0
+	}
+	return n.watchdog / 2
+}
+
+// Notify sends the state assignments in one datagram.
+func (n *Notifier) Notify(states ...string) error {
+	if !n.Enabled() || len(states) == 0 {
+		return nil
+	}
+	conn, err := net.DialUnix("unixgram", nil, &net.UnixAddr{Name: n.socket, Net: "unixgram"})
+	if err != nil {
+		return fmt.Errorf("sdnotify: %w", err)
+	}
+	defer conn.Close()
+	if _, err := conn.Write([]byte(strings.Join(states, "\n"))); err != nil {
+		return fmt.Errorf("sdnotify: %w", err)
+	}
+	return nil
+}
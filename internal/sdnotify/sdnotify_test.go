@@ -0,0 +1,78 @@
+package sdnotify
+
+import (
+	"net"
+	"os"
+	"path/filepath"
+	"strconv"
+	"testing"
+	"time"
+)
+
+func lookupIn(env map[string]string) func(string) (string, bool) {
+	return func(key string) (string, bool) {
+		v, ok := env[key]
+		return v, ok
+	}
+}
+
+func TestNotify(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "notify.sock")
+	conn, err := net.ListenUnixgram("unixgram", &net.UnixAddr{Name: path, Net: "unixgram"})
+	if err != nil {
+		t.Skipf("unixgram sockets unavailable: %v", err)
+	}
+	defer conn.Close()
+
+	n := New(lookupIn(map[string]string{"NOTIFY_SOCKET": path}))
+	if !n.Enabled() {
+		t.Fatal("notifier disabled with NOTIFY_SOCKET set")
+	}
+	if err := n.Notify(Ready, Status("serving")); err != nil {
+		t.Fatal(err)
+	}
+	buf := make([]byte, 256)
+	conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+	m, err := conn.Read(buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := string(buf[:m]); got != "READY=1\nSTATUS=serving" {
+		t.Errorf("datagram = %q", got)
+	}
+}
+
+func TestDisabled(t *testing.T) {
+	for _, n := range []*Notifier{nil, {}, New(lookupIn(nil))} {
+		if n.Enabled() || n.WatchdogInterval() != 0 {
+			t.Errorf("%+v: enabled without NOTIFY_SOCKET", n)
+		}
+		if err := n.Notify(Ready); err != nil {
+			t.Errorf("disabled Notify: %v", err)
+		}
+	}
+}
+
+func TestWatchdogInterval(t *testing.T) {
+	self := strconv.Itoa(os.Getpid())
+	tests := []struct {
+		env  map[string]string
+		want time.Duration
+	}{
+		{map[string]string{"WATCHDOG_USEC": "30000000"}, 15 * time.Second},
+		{map[string]string{"WATCHDOG_USEC": "30000000", "WATCHDOG_PID": self}, 15 * time.Second},
+		{map[string]string{"WATCHDOG_USEC": "30000000", "WATCHDOG_PID": "1"}, 0}, // another process
+		{map[string]string{"WATCHDOG_USEC": "0"}, 0},
+		{map[string]string{"WATCHDOG_USEC": "soon"}, 0},
+		{map[string]string{}, 0},
+	}
+	for _, tt := range tests {
+		tt.env["NOTIFY_SOCKET"] = "/run/systemd/notify"
+		if got := New(lookupIn(tt.env)).WatchdogInterval(); got != tt.want {
+			t.Errorf("%v: WatchdogInterval = %v, want %v", tt.env, got, tt.want)
+		}
+	}
+	if got := New(lookupIn(map[string]string{"WATCHDOG_USEC": "30000000"})).WatchdogInterval(); got != 0 {
+		t.Errorf("watchdog without NOTIFY_SOCKET = %v, want 0", got)
+	}
+}
@@ -0,0 +1,6 @@
+//go:build !unix
+
+package selfcheck
+
+// openFilesLimit reports 0 (unknown) on platforms without RLIMIT_NOFILE.
+func openFilesLimit() uint64 { return 0 }
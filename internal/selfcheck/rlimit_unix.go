@@ -0,0 +1,16 @@
+//go:build unix
+
+package selfcheck
+
+import "syscall"
+
+// openFilesLimit returns the soft RLIMIT_NOFILE. The Go runtime already
+// raises the soft limit to the hard limit at startup, so this is the
+// effective ceiling.
+func openFilesLimit() uint64 {
+	var lim syscall.Rlimit
+	if err := syscall.Getrlimit(syscall.RLIMIT_NOFILE, &lim); err != nil {
+		return 0
+	}
+	return uint64(lim.Cur)
+}
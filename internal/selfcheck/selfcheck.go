@@ -0,0 +1,181 @@
+// Package selfcheck inspects the OS resources available to the adapter at
+// startup and reports settings likely to fail under load, so mis-sized
+// containers are caught when they start rather than at peak traffic.
+package selfcheck
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"runtime"
+	"strconv"
+	"strings"
+)
+
+// Options describes the workload the adapter is configured for.
+type Options struct {
+	// MaxStreams is the number of concurrent streams the deployment must
+	// sustain; zero means unbounded, which limits the memory check to a
+	// capacity estimate.
+	MaxStreams int
+	// StreamMemoryBytes is the estimated memory footprint of one stream
+	// (engine session, tensors and buffers).
+	StreamMemoryBytes uint64
+	// InferenceThreads is the number of threads each engine may use for
+	// inference; zero means the runtime default (one per visible core).
+	InferenceThreads int
+}
+
+// Report holds the measured resources and the warnings derived from them.
+// Zero values mean the resource could not be determined on this platform.
+type Report struct {
+	OpenFilesLimit uint64
+	MemoryBytes    uint64  // memory available to the process (min of host and cgroup)
+	CPUQuota       float64 // cgroup CPU quota in CPUs; 0 if unlimited
+	VisibleCPUs    int
+	GOMAXPROCS     int
+	StreamCapacity int // streams that fit in MemoryBytes; 0 if unknown
+	Warnings       []string
+}
+
+// minOpenFiles is the descriptor headroom kept for listeners, the model,
+// shared libraries and logs, on top of one descriptor per stream.
+const minOpenFiles = 256
+
+// Paths of the files inspected on Linux. Variables so tests can point them
+// at fixtures.
+var (
+	procMemInfo       = "/proc/meminfo"
+	cgroupV2MemoryMax = "/sys/fs/cgroup/memory.max"
+	cgroupV1MemLimit  = "/sys/fs/cgroup/memory/memory.limit_in_bytes"
+	cgroupV2CPUMax    = "/sys/fs/cgroup/cpu.max"
+	cgroupV1CPUQuota  = "/sys/fs/cgroup/cpu/cpu.cfs_quota_us"
+	cgroupV1CPUPeriod = "/sys/fs/cgroup/cpu/cpu.cfs_period_us"
+)
+
+// Run measures the current process's resources against opts.
+func Run(opts Options) Report {
+	r := Report{
+		OpenFilesLimit: openFilesLimit(),
+		MemoryBytes:    availableMemory(),
+		CPUQuota:       cpuQuota(),
+		VisibleCPUs:    runtime.NumCPU(),
+		GOMAXPROCS:     runtime.GOMAXPROCS(0),
+	}
+
+	if r.OpenFilesLimit > 0 {
+		need := uint64(minOpenFiles + opts.MaxStreams)
+		if r.OpenFilesLimit < need {
+			r.Warnings = append(r.Warnings, fmt.Sprintf(
+				"open file limit (RLIMIT_NOFILE) is %d, below the %d needed for %d streams; raise it with ulimit -n or the container's nofile limit",
+				r.OpenFilesLimit, need, opts.MaxStreams))
+		}
+	}
+
+	if r.MemoryBytes > 0 && opts.StreamMemoryBytes > 0 {
+		r.StreamCapacity = int(r.MemoryBytes / opts.StreamMemoryBytes)
+		if opts.MaxStreams > 0 && r.StreamCapacity < opts.MaxStreams {
+			r.Warnings = append(r.Warnings, fmt.Sprintf(
+				"available memory (%d MiB) fits about %d streams at %d MiB each, below the configured maximum of %d; raise the memory limit or lower max streams",
+				r.MemoryBytes>>20, r.StreamCapacity, opts.StreamMemoryBytes>>20, opts.MaxStreams))
+		}
+	}
+
+	cpus := float64(r.VisibleCPUs)
+	if r.CPUQuota > 0 && r.CPUQuota < cpus {
+		cpus = r.CPUQuota
+		if float64(r.GOMAXPROCS) > r.CPUQuota+1 {
+			r.Warnings = append(r.Warnings, fmt.Sprintf(
+				"GOMAXPROCS is %d but the container CPU quota is %.1f CPUs; set GOMAXPROCS=%d to avoid throttling",
+				r.GOMAXPROCS, r.CPUQuota, int(r.CPUQuota+0.5)))
+		}
+		if opts.InferenceThreads == 0 {
+			r.Warnings = append(r.Warnings, fmt.Sprintf(
+				"container CPU quota is %.1f CPUs but %d are visible; inference thread pools size themselves from visible cores and will be throttled",
+				r.CPUQuota, r.VisibleCPUs))
+		}
+	}
+	if opts.InferenceThreads > 0 && float64(opts.InferenceThreads) > cpus {
+		r.Warnings = append(r.Warnings, fmt.Sprintf(
+			"%d inference threads per stream exceed the %.1f CPUs available; lower the thread setting",
+			opts.InferenceThreads, cpus))
+	}
+	return r
+}
+
+// availableMemory returns the memory the process can still use: the host's
+// MemAvailable, capped by the cgroup limit when one is set.
+func availableMemory() uint64 {
+	avail := readMemAvailable(procMemInfo)
+	for _, path := range []string{cgroupV2MemoryMax, cgroupV1MemLimit} {
+		if limit := readCgroupLimit(path); limit > 0 && (avail == 0 || limit < avail) {
+			avail = limit
+			break
+		}
+	}
+	return avail
+}
+
+// readMemAvailable returns MemAvailable from a /proc/meminfo-formatted file,
+// in bytes, or 0.
+func readMemAvailable(path string) uint64 {
+	f, err := os.Open(path)
+	if err != nil {
+		return 0
+	}
+	defer f.Close()
+	sc := bufio.NewScanner(f)
+	for sc.Scan() {
+		fields := strings.Fields(sc.Text())
+		if len(fields) >= 2 && fields[0] == "MemAvailable:" {
+			kb, err := strconv.ParseUint(fields[1], 10, 64)
+			if err != nil {
+				return 0
+			}
+			return kb << 10
+		}
+	}
+	return 0
+}
+
+// readCgroupLimit parses a cgroup memory limit file. "max" and the cgroup v1
+// "unlimited" sentinel (a value near MaxInt64) yield 0.
+func readCgroupLimit(path string) uint64 {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return 0
+	}
+	v, err := strconv.ParseUint(strings.TrimSpace(string(data)), 10, 64)
+	if err != nil || v >= 1<<62 {
+		return 0
+	}
+	return v
+}
+
+// cpuQuota returns the cgroup CPU quota in CPUs, or 0 when unlimited.
+func cpuQuota() float64 {
+	if data, err := os.ReadFile(cgroupV2CPUMax); err == nil {
+		return parseCPUMax(string(data))
+	}
+	quota, err1 := os.ReadFile(cgroupV1CPUQuota)
+	period, err2 := os.ReadFile(cgroupV1CPUPeriod)
+	if err1 != nil || err2 != nil {
+		return 0
+	}
+	return parseCPUMax(strings.TrimSpace(string(quota)) + " " + strings.TrimSpace(string(period)))
+}
+
+// parseCPUMax parses "<quota> <period>" as found in cgroup v2 cpu.max.
+// "max" or a negative quota (cgroup v1) means unlimited.
+func parseCPUMax(s string) float64 {
+	fields := strings.Fields(s)
+	if len(fields) != 2 || fields[0] == "max" {
+		return 0
+	}
+	quota, err1 := strconv.ParseFloat(fields[0], 64)
+	period, err2 := strconv.ParseFloat(fields[1], 64)
+	if err1 != nil || err2 != nil || quota <= 0 || period <= 0 {
+		return 0
+	}
+	return quota / period
+}
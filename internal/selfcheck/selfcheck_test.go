@@ -0,0 +1,130 @@
+package selfcheck
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func writeFile(t *testing.T, dir, name, content string) string {
+	t.Helper()
+	path := filepath.Join(dir, name)
+	if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+// useFixtures points every inspected path at files in a temp dir (missing
+// unless written by the test).
+func useFixtures(t *testing.T) string {
+	t.Helper()
+	dir := t.TempDir()
+	saved := []*string{&procMemInfo, &cgroupV2MemoryMax, &cgroupV1MemLimit, &cgroupV2CPUMax, &cgroupV1CPUQuota, &cgroupV1CPUPeriod}
+	old := make([]string, len(saved))
+	for i, p := range saved {
+		old[i] = *p
+		*p = filepath.Join(dir, "missing-"+filepath.Base(*p))
+	}
+	t.Cleanup(func() {
+		for i, p := range saved {
+			*p = old[i]
+		}
+	})
+	return dir
+}
+
+func TestParseCPUMax(t *testing.T) {
+	tests := []struct {
+		in   string
+		want float64
+	}{
+		{"max 100000", 0},
+		{"200000 100000", 2},
+		{"50000 100000\n", 0.5},
+		{"-1 100000", 0},
+		{"garbage", 0},
+	}
+	for _, tt := range tests {
+		if got := parseCPUMax(tt.in); got != tt.want {
+			t.Errorf("parseCPUMax(%q) = %v, want %v", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestAvailableMemory(t *testing.T) {
+	dir := useFixtures(t)
+	procMemInfo = writeFile(t, dir, "meminfo", "MemTotal:       16000000 kB\nMemFree:         1000000 kB\nMemAvailable:    8000000 kB\n")
+
+	if got, want := availableMemory(), uint64(8000000)<<10; got != want {
+		t.Errorf("host only: availableMemory() = %d, want %d", got, want)
+	}
+
+	// A smaller cgroup limit caps the host value.
+	cgroupV2MemoryMax = writeFile(t, dir, "memory.max", "536870912\n")
+	if got := availableMemory(); got != 512<<20 {
+		t.Errorf("with cgroup limit: availableMemory() = %d, want %d", got, 512<<20)
+	}
+
+	// "max" means no cgroup limit.
+	cgroupV2MemoryMax = writeFile(t, dir, "memory.max", "max\n")
+	if got, want := availableMemory(), uint64(8000000)<<10; got != want {
+		t.Errorf("unlimited cgroup: availableMemory() = %d, want %d", got, want)
+	}
+}
+
+func TestRunMemoryWarning(t *testing.T) {
+	dir := useFixtures(t)
+	cgroupV2MemoryMax = writeFile(t, dir, "memory.max", "104857600\n") // 100 MiB
+
+	r := Run(Options{MaxStreams: 50, StreamMemoryBytes: 16 << 20})
+	if r.StreamCapacity != 6 {
+		t.Errorf("StreamCapacity = %d, want 6", r.StreamCapacity)
+	}
+	if !containsWarning(r.Warnings, "fits about 6 streams") {
+		t.Errorf("expected memory warning, got %v", r.Warnings)
+	}
+
+	// Unbounded streams: capacity is reported but not warned about.
+	r = Run(Options{StreamMemoryBytes: 16 << 20})
+	if containsWarning(r.Warnings, "fits about") {
+		t.Errorf("unexpected memory warning without MaxStreams: %v", r.Warnings)
+	}
+}
+
+func TestRunCPUQuotaWarning(t *testing.T) {
+	dir := useFixtures(t)
+	// A quota of a tenth of a CPU is below any machine running the tests.
+	cgroupV2CPUMax = writeFile(t, dir, "cpu.max", "10000 100000\n")
+
+	r := Run(Options{})
+	if r.CPUQuota != 0.1 {
+		t.Fatalf("CPUQuota = %v, want 0.1", r.CPUQuota)
+	}
+	if r.VisibleCPUs > 1 && !containsWarning(r.Warnings, "inference thread pools") {
+		t.Errorf("expected thread pool warning, got %v", r.Warnings)
+	}
+
+	r = Run(Options{InferenceThreads: 4})
+	if !containsWarning(r.Warnings, "4 inference threads") {
+		t.Errorf("expected inference thread warning, got %v", r.Warnings)
+	}
+
+	// Explicit threads within the quota: pools do not size themselves from
+	// the visible cores, so nothing is throttled.
+	cgroupV2CPUMax = writeFile(t, dir, "cpu.max", "200000 100000\n")
+	r = Run(Options{InferenceThreads: 1})
+	if containsWarning(r.Warnings, "inference thread") {
+		t.Errorf("unexpected thread warning with 1 thread under a 2 CPU quota: %v", r.Warnings)
+	}
+}
+
+func containsWarning(warnings []string, substr string) bool {
+	for _, w := range warnings {
+		if strings.Contains(w, substr) {
+			return true
+		}
+	}
+	return false
+}
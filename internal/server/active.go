@@ -0,0 +1,39 @@
+package server
+
+import (
+	"slices"
+	"time"
+)
+
+// ActiveStream describes a stream that is currently open. The IDs and
+// variant are empty until the stream's first audio chunk arrives.
+type ActiveStream struct {
+	SessionID string
+	StreamID  string
+	Variant   string
+	Peer      string
+	OpenedAt  time.Time
+	// Frames is the number of inference frames processed so far.
+	Frames int64
+}
+
+// ListActiveStreams returns the open streams, oldest first.
+func (s *Server) ListActiveStreams() []ActiveStream {
+	s.streamsMu.Lock()
+	list := make([]ActiveStream, 0, len(s.streams))
+	for h := range s.streams {
+		list = append(list, h.active())
+	}
+	s.streamsMu.Unlock()
+	slices.SortFunc(list, func(a, b ActiveStream) int { return a.OpenedAt.Compare(b.OpenedAt) })
+	return list
+}
+
+// active describes the stream h for ListActiveStreams.
+func (h *streamHandle) active() ActiveStream {
+	st := ActiveStream{Peer: h.peer, OpenedAt: h.opened, Frames: h.frames.Load()}
+	if ids := h.ids.Load(); ids != nil {
+		st.SessionID, st.StreamID, st.Variant = ids.session, ids.stream, ids.variant
+	}
+	return st
+}
@@ -0,0 +1,76 @@
+package server
+
+import (
+	"encoding/json"
+	"io"
+	"log/slog"
+	"sync"
+	"time"
+
+	"google.golang.org/grpc/status"
+)
+
+// BillingRecord is the per-stream completion record written to
+// billing_output, one JSON object per line, when a stream closes. Durations
+// are audio time, so they do not depend on how fast the client sent it.
+type BillingRecord struct {
+	Record        string    `json:"record"` // always "stream_completed"
+	InstanceID    string    `json:"instance_id,omitempty"`
+	SessionID     string    `json:"session_id"`
+	StreamID      string    `json:"stream_id"`
+	Variant       string    `json:"variant"`
+	OpenedAt      time.Time `json:"opened_at"`
+	ClosedAt      time.Time `json:"closed_at"`
+	AudioSeconds  float64   `json:"audio_seconds"`
+	SpeechSeconds float64   `json:"speech_seconds"`
+	Segments      int       `json:"segments"`
+	// Status is the gRPC status code the stream ended with ("OK" for a
+	// clean close), so collectors can decide whether to bill failed streams.
+	Status string `json:"status"`
+}
+
+// WithBillingOutput writes a BillingRecord line to w for every closed
+// stream. Writes are serialized; a write error disables the output.
+func WithBillingOutput(w io.Writer) Option {
+	return func(s *Server) {
+		if w != nil {
+			s.billing = &billingWriter{enc: json.NewEncoder(w)}
+		}
+	}
+}
+
+// billingWriter serializes BillingRecord lines to the billing output.
+type billingWriter struct {
+	mu     sync.Mutex
+	enc    *json.Encoder
+	log    *slog.Logger
+	failed bool
+}
+
+func (s *Server) newBillingRecord(sum StreamSummary, streamErr error) BillingRecord {
+	return BillingRecord{
+		Record:        "stream_completed",
+		InstanceID:    s.cfg.InstanceID,
+		SessionID:     sum.SessionID,
+		StreamID:      sum.StreamID,
+		Variant:       sum.Variant,
+		OpenedAt:      sum.OpenedAt.UTC(),
+		ClosedAt:      sum.ClosedAt.UTC(),
+		AudioSeconds:  sum.AudioDuration.Seconds(),
+		SpeechSeconds: sum.SpeechDuration.Seconds(),
+		Segments:      sum.Segments,
+		Status:        status.Code(streamErr).String(),
+	}
+}
+
+func (b *billingWriter) write(rec BillingRecord) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.failed {
+		return
+	}
+	if err := b.enc.Encode(rec); err != nil {
+		b.failed = true
+		b.log.Error("billing output write failed, billing records disabled", "error", err)
+	}
+}
@@ -0,0 +1,88 @@
+package server
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"strings"
+	"sync"
+	"testing"
+
+	napv1 "github.com/nupi-ai/nupi/api/nap/v1"
+
+	"github.com/nupi-ai/plugin-vad-local-silero/internal/config"
+	"github.com/nupi-ai/plugin-vad-local-silero/internal/engine"
+)
+
+// lockedBuffer is a bytes.Buffer safe to read while the server writes to it.
+type lockedBuffer struct {
+	mu  sync.Mutex
+	buf bytes.Buffer
+}
+
+func (b *lockedBuffer) Write(p []byte) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.Write(p)
+}
+
+func (b *lockedBuffer) String() string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.String()
+}
+
+func TestDetectSpeechWritesBillingRecord(t *testing.T) {
+	cfg := config.Config{
+		Threshold:            0.5,
+		MinSpeechDurationMs:  20,
+		MinSilenceDurationMs: 20,
+		InstanceID:           "vad-1",
+	}
+	var out lockedBuffer
+	srv := New(cfg, nil, func() engine.Engine { return engine.NewStubEngine() }, WithBillingOutput(&out))
+	client, cleanup := startTestServerWith(t, srv)
+	defer cleanup()
+
+	stream, err := client.DetectSpeech(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < engine.StubToggleInterval*3-1; i++ {
+		if err := stream.Send(&napv1.DetectSpeechRequest{
+			SessionId: "sess-1",
+			StreamId:  "mic-1",
+			Format:    &napv1.AudioFormat{SampleRate: 16000},
+			PcmData:   make([]byte, 640),
+		}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	stream.CloseSend()
+	for {
+		if _, err := stream.Recv(); err == io.EOF {
+			break
+		} else if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	lines := strings.Split(strings.TrimSpace(out.String()), "\n")
+	if len(lines) != 1 {
+		t.Fatalf("billing output has %d lines, want 1:\n%s", len(lines), out.String())
+	}
+	var rec BillingRecord
+	if err := json.Unmarshal([]byte(lines[0]), &rec); err != nil {
+		t.Fatal(err)
+	}
+	if rec.Record != "stream_completed" || rec.InstanceID != "vad-1" || rec.SessionID != "sess-1" || rec.StreamID != "mic-1" {
+		t.Errorf("record identity = %+v", rec)
+	}
+	if rec.AudioSeconds != 2.98 || rec.SpeechSeconds != 1 || rec.Segments != 1 {
+		t.Errorf("audio, speech, segments = %v, %v, %d, want 2.98, 1, 1", rec.AudioSeconds, rec.SpeechSeconds, rec.Segments)
+	}
+	if rec.Status != "OK" {
+		t.Errorf("status = %q, want OK", rec.Status)
+	}
+}
@@ -0,0 +1,218 @@
+package server
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/nupi-ai/plugin-vad-local-silero/internal/config"
+	"github.com/nupi-ai/plugin-vad-local-silero/internal/engine"
+	"github.com/nupi-ai/plugin-vad-local-silero/internal/metrics"
+)
+
+// WithDowngradeEngine sets the factory of the cheaper engine streams switch
+// to when they exceed cpu_budget with action "downgrade". Without it such
+// streams are terminated.
+func WithDowngradeEngine(newEngine func() engine.Engine) Option {
+	return func(s *Server) {
+		s.newDowngradeEngine = newEngine
+	}
+}
+
+// budgetMetrics counts CPU budget violations per action taken.
+type budgetMetrics struct {
+	downgraded *metrics.Counter
+	terminated *metrics.Counter
+}
+
+func newBudgetMetrics(reg *metrics.Registry) *budgetMetrics {
+	return &budgetMetrics{
+		downgraded: reg.Counter("vad_cpu_budget_exceeded_total", "action", config.CPUBudgetDowngrade),
+		terminated: reg.Counter("vad_cpu_budget_exceeded_total", "action", config.CPUBudgetTerminate),
+	}
+}
+
+// budgetEngine enforces a stream's CPU budget around its engine. Inference
+// time is accumulated per slice of audio; once a slice's time exceeds
+// percent of the slice's audio, the stream is either switched to a cheaper
+// engine or ProcessChunk fails with a CPU_BUDGET_EXCEEDED termination.
+//
+// After a downgrade the engine keeps reporting the original engine's frame
+// geometry, so the stream's frame clock and boundary detector carry on: one
+// result is emitted per original frame of audio, carrying the cheaper
+// engine's latest probability.
+type budgetEngine struct {
+	cur        engine.Engine
+	newCheaper func() engine.Engine // nil: terminate on the first violation
+
+	// onExceeded reports a violation: the action taken and the inference
+	// time and audio of the slice.
+	onExceeded func(action string, used, audio time.Duration)
+	now        func() time.Time
+
+	// percent is the budget; sliceUsed is the inference time spent on the
+	// sliceFilled audio of the current slice, which ends after sliceAudio.
+	percent     int64
+	sliceAudio  time.Duration
+	sliceUsed   time.Duration
+	sliceFilled time.Duration
+
+	// Geometry of the original engine, and its counters since the last
+	// reset: samples fed and frames returned.
+	frameSamples int64
+	sampleRate   uint32
+	lag          int64 // window samples beyond the first frame
+	fed          int64
+	frames       int64
+
+	downgraded bool
+	residual   int64   // samples fed since the last emitted frame, after downgrade
+	last       float32 // latest probability, carried into emitted frames
+
+	stride    int              // inference stride, passed on to a cheaper engine
+	frameSkip engine.FrameSkip // likewise
+}
+
+// newBudgetEngine wraps eng, which must already be configured for the
+// stream's sample rate.
+func newBudgetEngine(eng engine.Engine, budget config.CPUBudget, newCheaper func() engine.Engine, onExceeded func(string, time.Duration, time.Duration)) *budgetEngine {
+	if budget.Action != config.CPUBudgetDowngrade {
+		newCheaper = nil
+	}
+	return &budgetEngine{
+		cur:          eng,
+		newCheaper:   newCheaper,
+		onExceeded:   onExceeded,
+		now:          time.Now,
+		percent:      int64(budget.Percent),
+		sliceAudio:   time.Duration(budget.EffectiveSliceSec()) * time.Second,
+		frameSamples: int64(eng.FrameSamples()),
+		sampleRate:   eng.SampleRate(),
+		lag:          int64(engine.WindowSamples(eng) - eng.FrameSamples()),
+	}
+}
+
+// ProcessChunk runs the current engine on pcm and charges its time to the
+// current slice.
+func (b *budgetEngine) ProcessChunk(pcm []byte, sampleRate uint32) ([]engine.Result, error) {
+	start := b.now()
+	results, err := b.cur.ProcessChunk(pcm, sampleRate)
+	used := b.now().Sub(start)
+	if err != nil {
+		return nil, err
+	}
+	samples := int64(len(pcm) / 2)
+	if b.downgraded {
+		results = b.regrid(results, samples)
+	} else if len(results) > 0 {
+		b.last = results[len(results)-1].Confidence
+	}
+	b.fed += samples
+	b.frames += int64(len(results))
+
+	b.sliceUsed += used
+	b.sliceFilled += time.Duration(samples) * time.Second / time.Duration(b.sampleRate)
+	// Once the slice's time exceeds the allowance of the whole slice, the
+	// slice cannot end within budget: the stream is stopped right away, not
+	// at the slice's end. A chunk longer than the slice extends it.
+	limit := max(b.sliceAudio, b.sliceFilled) * time.Duration(b.percent) / 100
+	over := b.sliceUsed > limit
+	used, audio := b.sliceUsed, b.sliceFilled
+	if over || b.sliceFilled >= b.sliceAudio {
+		b.sliceUsed, b.sliceFilled = 0, 0
+	}
+	if over {
+		if err := b.exceeded(used, audio); err != nil {
+			return nil, err
+		}
+	}
+	return results, nil
+}
+
+// exceeded downgrades the stream, or returns its termination error when
+// it has nowhere cheaper to go.
+func (b *budgetEngine) exceeded(used, audio time.Duration) error {
+	if !b.downgraded && b.newCheaper != nil {
+		if cheaper := b.newCheaper(); cheaper != nil {
+			if err := cheaper.SetSampleRate(b.sampleRate); err == nil {
+				engine.SetStride(cheaper, b.stride)
+				engine.SetFrameSkip(cheaper, b.frameSkip)
+				b.cur.Close()
+				b.cur = cheaper
+				b.downgraded = true
+				b.residual = max(0, b.fed-b.frames*b.frameSamples-b.lag)
+				b.onExceeded(config.CPUBudgetDowngrade, used, audio)
+				return nil
+			}
+			cheaper.Close()
+		}
+	}
+	b.onExceeded(config.CPUBudgetTerminate, used, audio)
+	return TerminationError(ReasonCPUBudget, fmt.Sprintf(
+		"stream exceeded its CPU budget: %s of inference for %s of audio, limit %d%%",
+		used.Round(time.Millisecond), audio.Round(time.Millisecond), b.percent))
+}
+
+// regrid turns the cheaper engine's results for samples new samples into
+// one result per original frame.
+func (b *budgetEngine) regrid(results []engine.Result, samples int64) []engine.Result {
+	if len(results) > 0 {
+		b.last = results[len(results)-1].Confidence
+	}
+	b.residual += samples
+	n := b.residual / b.frameSamples
+	b.residual -= n * b.frameSamples
+	out := make([]engine.Result, n)
+	for i := range out {
+		out[i].Confidence = b.last
+	}
+	return out
+}
+
+// Reset resets the current engine and the frame counters. The budget slice
+// carries on: a reset does not refund inference time.
+func (b *budgetEngine) Reset() error {
+	b.fed, b.frames = 0, 0
+	b.residual = -b.lag
+	return b.cur.Reset()
+}
+
+// SetStride sets the current engine's inference stride.
+func (b *budgetEngine) SetStride(n int) {
+	b.stride = n
+	engine.SetStride(b.cur, n)
+}
+
+// SetFrameSkip sets the current engine's frame skipping.
+func (b *budgetEngine) SetFrameSkip(fs engine.FrameSkip) {
+	b.frameSkip = fs
+	engine.SetFrameSkip(b.cur, fs)
+}
+
+// Close closes the current engine.
+func (b *budgetEngine) Close() error { return b.cur.Close() }
+
+// FrameSamples returns the original engine's frame size.
+func (b *budgetEngine) FrameSamples() int { return int(b.frameSamples) }
+
+// FrameDurationMs returns the original engine's frame duration.
+func (b *budgetEngine) FrameDurationMs() int {
+	return int(b.frameSamples * 1000 / int64(b.sampleRate))
+}
+
+// SampleRate returns the rate the engine was configured for.
+func (b *budgetEngine) SampleRate() uint32 { return b.sampleRate }
+
+// SetSampleRate is only valid for the configured rate: the budget wraps an
+// engine after the stream's rate is known.
+func (b *budgetEngine) SetSampleRate(sampleRate uint32) error {
+	if sampleRate != b.sampleRate {
+		return fmt.Errorf("budget: sample rate is fixed at %d Hz, got %d", b.sampleRate, sampleRate)
+	}
+	return nil
+}
+
+// WindowSamples returns the original engine's window.
+func (b *budgetEngine) WindowSamples() int { return int(b.frameSamples + b.lag) }
+
+// BufferedSamples returns the samples the current engine holds.
+func (b *budgetEngine) BufferedSamples() int { return engine.BufferedSamples(b.cur) }
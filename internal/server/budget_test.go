@@ -0,0 +1,164 @@
+package server
+
+import (
+	"context"
+	"io"
+	"testing"
+	"time"
+
+	napv1 "github.com/nupi-ai/nupi/api/nap/v1"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	"github.com/nupi-ai/plugin-vad-local-silero/internal/config"
+	"github.com/nupi-ai/plugin-vad-local-silero/internal/engine"
+)
+
+// coarseEngine scores frames of 800 samples (50 ms at 16 kHz) with a fixed
+// probability, so it does not share the stub's 20 ms frame grid.
+type coarseEngine struct {
+	engine.StubEngine
+	buffered int
+}
+
+func (e *coarseEngine) ProcessChunk(pcm []byte, _ uint32) ([]engine.Result, error) {
+	e.buffered += len(pcm) / 2
+	results := make([]engine.Result, e.buffered/800)
+	e.buffered %= 800
+	for i := range results {
+		results[i].Confidence = 0.7
+	}
+	return results, nil
+}
+
+// steppedClock returns a clock that advances by step on every other call,
+// so each ProcessChunk is charged step.
+func steppedClock(step time.Duration) func() time.Time {
+	now := time.Unix(0, 0)
+	calls := 0
+	return func() time.Time {
+		calls++
+		if calls%2 == 0 {
+			now = now.Add(step)
+		}
+		return now
+	}
+}
+
+func TestBudgetEngineDowngradesThenTerminates(t *testing.T) {
+	primary := engine.NewStubEngine()
+	if err := primary.SetSampleRate(16000); err != nil {
+		t.Fatal(err)
+	}
+	var actions []string
+	budget := config.CPUBudget{Percent: 25, SliceSec: 1, Action: config.CPUBudgetDowngrade, Engine: config.EngineStub}
+	b := newBudgetEngine(primary, budget, func() engine.Engine { return &coarseEngine{} },
+		func(action string, used, audio time.Duration) { actions = append(actions, action) })
+	b.now = steppedClock(10 * time.Millisecond) // 50% of every 20 ms chunk
+
+	// 250 ms of inference is the slice's allowance: the 26th chunk is over it.
+	chunk := make([]byte, 640)
+	for i := 1; i <= 26; i++ {
+		results, err := b.ProcessChunk(chunk, 16000)
+		if err != nil {
+			t.Fatalf("chunk %d: %v", i, err)
+		}
+		if len(results) != 1 {
+			t.Fatalf("chunk %d: %d results, want 1", i, len(results))
+		}
+	}
+	if len(actions) != 1 || actions[0] != config.CPUBudgetDowngrade {
+		t.Fatalf("actions = %v, want [downgrade]", actions)
+	}
+
+	// Downgraded: still one 20 ms frame per chunk, carrying the coarse
+	// engine's latest probability once it has scored a frame.
+	var conf []float32
+	for i := 1; i <= 25; i++ {
+		results, err := b.ProcessChunk(chunk, 16000)
+		if err != nil {
+			t.Fatalf("downgraded chunk %d: %v", i, err)
+		}
+		if len(results) != 1 {
+			t.Fatalf("downgraded chunk %d: %d results, want 1", i, len(results))
+		}
+		conf = append(conf, results[0].Confidence)
+	}
+	if conf[0] != 0.1 || conf[len(conf)-1] != 0.7 {
+		t.Errorf("downgraded probabilities = %v, want the stub's last 0.1 then 0.7", conf)
+	}
+	if b.FrameSamples() != 320 {
+		t.Errorf("FrameSamples = %d after downgrade, want 320", b.FrameSamples())
+	}
+
+	// The cheaper engine over budget too: the stream is terminated.
+	_, err := b.ProcessChunk(chunk, 16000)
+	if reason, retryable, ok := TerminationReason(err); !ok || reason != ReasonCPUBudget || retryable {
+		t.Fatalf("err = %v, want non-retryable %s", err, ReasonCPUBudget)
+	}
+	if len(actions) != 2 || actions[1] != config.CPUBudgetTerminate {
+		t.Errorf("actions = %v, want [downgrade terminate]", actions)
+	}
+}
+
+func TestBudgetEngineWithinBudget(t *testing.T) {
+	primary := engine.NewStubEngine()
+	if err := primary.SetSampleRate(16000); err != nil {
+		t.Fatal(err)
+	}
+	b := newBudgetEngine(primary, config.CPUBudget{Percent: 25, SliceSec: 1}, nil,
+		func(action string, used, audio time.Duration) {
+			t.Errorf("unexpected %s after %s for %s", action, used, audio)
+		})
+	b.now = steppedClock(4 * time.Millisecond) // 20% of every chunk
+
+	for i := 0; i < 200; i++ {
+		if _, err := b.ProcessChunk(make([]byte, 640), 16000); err != nil {
+			t.Fatalf("chunk %d: %v", i, err)
+		}
+	}
+	// A chunk longer than the slice is judged on its own length.
+	b.now = steppedClock(400 * time.Millisecond)
+	if _, err := b.ProcessChunk(make([]byte, 2*32000*2), 16000); err != nil {
+		t.Fatalf("2 s chunk at 20%%: %v", err)
+	}
+}
+
+func TestCPUBudgetTerminatesStream(t *testing.T) {
+	cfg := config.Config{Threshold: 0.5, CPUBudget: config.CPUBudget{Percent: 1, SliceSec: 1, Action: config.CPUBudgetTerminate}}
+	srv := New(cfg, nil, func() engine.Engine {
+		return engine.NewFaultEngine(engine.NewStubEngine(), engine.DelayChunks(2*time.Millisecond))
+	})
+	client, cleanup := startTestServerWith(t, srv)
+	defer cleanup()
+
+	stream, err := client.DetectSpeech(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	// 10 ms of inference is the whole allowance; each chunk takes 2 ms.
+	go func() {
+		for i := 0; i < 50; i++ {
+			if stream.Send(&napv1.DetectSpeechRequest{Format: &napv1.AudioFormat{SampleRate: 16000}, PcmData: make([]byte, 640)}) != nil {
+				return
+			}
+		}
+		stream.CloseSend()
+	}()
+	for {
+		_, err = stream.Recv()
+		if err != nil {
+			break
+		}
+	}
+	if err == io.EOF {
+		t.Fatal("stream closed cleanly, want a CPU budget termination")
+	}
+	if reason, _, ok := TerminationReason(err); !ok || reason != ReasonCPUBudget || status.Code(err) != codes.ResourceExhausted {
+		t.Errorf("Recv error = %v, want RESOURCE_EXHAUSTED %s", err, ReasonCPUBudget)
+	}
+	key := `vad_cpu_budget_exceeded_total{action="terminate"}`
+	if got := srv.Metrics().Snapshot()[key]; got != 1 {
+		t.Errorf("%s = %d, want 1", key, got)
+	}
+}
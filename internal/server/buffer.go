@@ -0,0 +1,201 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"io"
+	"time"
+
+	napv1 "github.com/nupi-ai/nupi/api/nap/v1"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/protobuf/proto"
+
+	"github.com/nupi-ai/plugin-vad-local-silero/internal/audio/decode"
+	"github.com/nupi-ai/plugin-vad-local-silero/internal/engine"
+	"github.com/nupi-ai/plugin-vad-local-silero/internal/errs"
+)
+
+// EncodingWAV is the format encoding that marks pcm_data as a complete WAV
+// file on DetectSpeechInBuffer.
+const EncodingWAV = "wav"
+
+// BufferSegment is one speech segment found by DetectSpeechInBuffer, as
+// offsets into the buffer's audio.
+type BufferSegment struct {
+	Start time.Duration
+	End   time.Duration
+}
+
+// BufferResult is the outcome of DetectSpeechInBuffer.
+type BufferResult struct {
+	// Segments holds every START/END pair, in order. A segment still open
+	// at the end of the buffer is closed there, as on a stream's EOF.
+	Segments []BufferSegment
+	// Events holds every event the equivalent stream would have sent, with
+	// timestamps relative to Epoch.
+	Events []*napv1.SpeechEvent
+	// Epoch is the timestamp of the buffer's first sample.
+	Epoch time.Time
+	// AudioDuration is the length of the buffer's audio.
+	AudioDuration time.Duration
+}
+
+// DetectSpeechInBuffer runs detection over a complete PCM buffer: req is
+// handled like a stream whose only message is req, closed right after it,
+// so config_json, format validation and the final END flush behave exactly
+// as on DetectSpeech. Buffers larger than max_chunk_bytes are fed to the
+// engine in chunks of that size.
+//
+// With format encoding "wav", pcm_data holds a complete WAV file instead: it
+// is decoded to mono 16-bit PCM and, when its rate is not one the engines
+// take natively, resampled to 16 kHz. Segment offsets are then offsets into
+// the recording.
+func (s *Server) DetectSpeechInBuffer(ctx context.Context, req *napv1.DetectSpeechRequest) (BufferResult, error) {
+	if req.GetFormat().GetEncoding() == EncodingWAV {
+		decoded, err := decodeWAVRequest(req)
+		if err != nil {
+			return BufferResult{}, errs.ToStatus(err)
+		}
+		req = decoded
+	}
+	pcm := req.GetPcmData()
+	if len(pcm) == 0 {
+		return BufferResult{}, errs.ToStatus(errs.New(errs.ErrInvalidPCM, "pcm_data is required"))
+	}
+	stream := &bufferStream{ctx: ctx}
+	for first := true; first || len(pcm) > 0; first = false {
+		n := min(len(pcm), s.maxChunkBytes())
+		chunk := &napv1.DetectSpeechRequest{PcmData: pcm[:n]}
+		if first {
+			chunk.SessionId = req.GetSessionId()
+			chunk.StreamId = req.GetStreamId()
+			chunk.Format = req.GetFormat()
+			chunk.ConfigJson = req.GetConfigJson()
+		}
+		stream.requests = append(stream.requests, chunk)
+		pcm = pcm[n:]
+	}
+	if err := s.DetectSpeech(stream); err != nil {
+		return BufferResult{}, err
+	}
+
+	res := BufferResult{Events: stream.events}
+	res.Epoch, _ = ParseAudioEpoch(stream.header)
+	if rate := int64(req.GetFormat().GetSampleRate()); rate > 0 {
+		samples := int64(len(req.GetPcmData()) / 2)
+		res.AudioDuration = time.Duration(samples * int64(time.Second) / rate)
+	}
+	var open *BufferSegment
+	for _, evt := range stream.events {
+		offset := AudioOffset(evt, res.Epoch)
+		switch evt.GetType() {
+		case napv1.SpeechEventType_SPEECH_EVENT_TYPE_START:
+			open = &BufferSegment{Start: offset}
+		case napv1.SpeechEventType_SPEECH_EVENT_TYPE_END:
+			if open != nil {
+				open.End = offset
+				res.Segments = append(res.Segments, *open)
+				open = nil
+			}
+		}
+	}
+	return res, nil
+}
+
+// decodeWAVRequest returns req with its WAV file replaced by the PCM
+// request DetectSpeech expects. A sample_rate set on the format must match
+// the file's.
+func decodeWAVRequest(req *napv1.DetectSpeechRequest) (*napv1.DetectSpeechRequest, error) {
+	if len(req.GetPcmData()) == 0 {
+		return nil, errs.New(errs.ErrInvalidPCM, "pcm_data is required")
+	}
+	file, err := decode.File(req.GetPcmData())
+	if err != nil {
+		return nil, err
+	}
+	if rate := req.GetFormat().GetSampleRate(); rate != 0 && rate != file.SampleRate {
+		return nil, errs.New(errs.ErrUnsupportedFormat,
+			"format sample_rate %d does not match the WAV file's %d Hz", rate, file.SampleRate)
+	}
+	if !engine.SupportedSampleRate(file.SampleRate) {
+		if file, err = file.Resample(engine.ExpectedSampleRate); err != nil {
+			return nil, err
+		}
+	}
+	if len(file.PCM) == 0 {
+		return nil, errs.New(errs.ErrInvalidPCM, "WAV file contains no audio")
+	}
+	return &napv1.DetectSpeechRequest{
+		SessionId:  req.GetSessionId(),
+		StreamId:   req.GetStreamId(),
+		ConfigJson: req.GetConfigJson(),
+		PcmData:    file.PCM,
+		Format: &napv1.AudioFormat{
+			Encoding:   "pcm_s16le",
+			SampleRate: file.SampleRate,
+			Channels:   1,
+			BitDepth:   16,
+		},
+	}, nil
+}
+
+// bufferStream is the in-process DetectSpeech stream behind
+// DetectSpeechInBuffer: Recv hands out the prepared requests, then io.EOF;
+// Send and the metadata setters record what a client would receive.
+type bufferStream struct {
+	ctx      context.Context
+	requests []*napv1.DetectSpeechRequest
+	events   []*napv1.SpeechEvent
+	header   metadata.MD
+	trailer  metadata.MD
+}
+
+func (b *bufferStream) Recv() (*napv1.DetectSpeechRequest, error) {
+	if err := b.ctx.Err(); err != nil {
+		return nil, err
+	}
+	if len(b.requests) == 0 {
+		return nil, io.EOF
+	}
+	req := b.requests[0]
+	b.requests = b.requests[1:]
+	return req, nil
+}
+
+func (b *bufferStream) Send(evt *napv1.SpeechEvent) error {
+	b.events = append(b.events, evt)
+	return nil
+}
+
+func (b *bufferStream) SetHeader(md metadata.MD) error {
+	b.header = metadata.Join(b.header, md)
+	return nil
+}
+
+func (b *bufferStream) SendHeader(md metadata.MD) error { return b.SetHeader(md) }
+
+func (b *bufferStream) SetTrailer(md metadata.MD) { b.trailer = metadata.Join(b.trailer, md) }
+
+func (b *bufferStream) Context() context.Context { return b.ctx }
+
+func (b *bufferStream) SendMsg(m any) error {
+	evt, ok := m.(*napv1.SpeechEvent)
+	if !ok {
+		return errors.New("buffer stream: unexpected message type")
+	}
+	return b.Send(evt)
+}
+
+func (b *bufferStream) RecvMsg(m any) error {
+	req, err := b.Recv()
+	if err != nil {
+		return err
+	}
+	out, ok := m.(*napv1.DetectSpeechRequest)
+	if !ok {
+		return errors.New("buffer stream: unexpected message type")
+	}
+	proto.Reset(out)
+	proto.Merge(out, req)
+	return nil
+}
@@ -0,0 +1,158 @@
+package server
+
+import (
+	"context"
+	"encoding/binary"
+	"testing"
+	"time"
+
+	napv1 "github.com/nupi-ai/nupi/api/nap/v1"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	"github.com/nupi-ai/plugin-vad-local-silero/internal/config"
+	"github.com/nupi-ai/plugin-vad-local-silero/internal/engine"
+)
+
+func TestDetectSpeechInBuffer(t *testing.T) {
+	// 149 stub frames hold one segment (speech on frames 49-98). A small
+	// max_chunk_bytes splits the buffer into many engine chunks without
+	// changing the result.
+	for _, maxChunk := range []int{0, 1024} {
+		cfg := config.Config{Threshold: 0.5, MinSpeechDurationMs: 20, MinSilenceDurationMs: 20, MaxChunkBytes: maxChunk}
+		srv := New(cfg, nil, func() engine.Engine { return engine.NewStubEngine() })
+		res, err := srv.DetectSpeechInBuffer(context.Background(), &napv1.DetectSpeechRequest{
+			Format:  &napv1.AudioFormat{SampleRate: 16000},
+			PcmData: make([]byte, 149*640),
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		want := BufferSegment{Start: 980 * time.Millisecond, End: 1980 * time.Millisecond}
+		if len(res.Segments) != 1 || res.Segments[0] != want {
+			t.Errorf("max_chunk_bytes=%d: segments = %v, want [%v]", maxChunk, res.Segments, want)
+		}
+		if res.AudioDuration != 2980*time.Millisecond {
+			t.Errorf("max_chunk_bytes=%d: audio duration = %v, want 2.98s", maxChunk, res.AudioDuration)
+		}
+		if len(res.Events) < 2 {
+			t.Errorf("max_chunk_bytes=%d: %d events, want START, ONGOING..., END", maxChunk, len(res.Events))
+		}
+	}
+}
+
+func TestDetectSpeechInBufferOpenSegment(t *testing.T) {
+	// The buffer ends mid-speech: the segment is closed at its end.
+	srv := New(config.Config{Threshold: 0.5, MinSpeechDurationMs: 20, MinSilenceDurationMs: 20}, nil,
+		func() engine.Engine { return engine.NewStubEngine() })
+	res, err := srv.DetectSpeechInBuffer(context.Background(), &napv1.DetectSpeechRequest{
+		Format:     &napv1.AudioFormat{SampleRate: 16000},
+		PcmData:    make([]byte, 70*640),
+		ConfigJson: `{"min_silence_duration_ms": 40}`,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := BufferSegment{Start: 980 * time.Millisecond, End: 1400 * time.Millisecond}
+	if len(res.Segments) != 1 || res.Segments[0] != want {
+		t.Errorf("segments = %v, want [%v]", res.Segments, want)
+	}
+}
+
+func TestDetectSpeechInBufferErrors(t *testing.T) {
+	srv := New(config.Config{Threshold: 0.5}, nil, func() engine.Engine { return engine.NewStubEngine() })
+	for name, req := range map[string]*napv1.DetectSpeechRequest{
+		"empty":     {Format: &napv1.AudioFormat{SampleRate: 16000}},
+		"no format": {PcmData: make([]byte, 640)},
+		"odd":       {Format: &napv1.AudioFormat{SampleRate: 16000}, PcmData: make([]byte, 641)},
+		"config":    {Format: &napv1.AudioFormat{SampleRate: 16000}, PcmData: make([]byte, 640), ConfigJson: `{"threshold": 2}`},
+	} {
+		if _, err := srv.DetectSpeechInBuffer(context.Background(), req); status.Code(err) != codes.InvalidArgument {
+			t.Errorf("%s: err = %v, want InvalidArgument", name, err)
+		}
+	}
+}
+
+// wavFile builds a 16-bit PCM WAV file of frames silent frames.
+func wavFile(rate uint32, channels, frames int) []byte {
+	data := 2 * channels * frames
+	b := []byte("RIFF")
+	b = binary.LittleEndian.AppendUint32(b, uint32(36+data))
+	b = append(b, "WAVEfmt "...)
+	b = binary.LittleEndian.AppendUint32(b, 16)
+	b = binary.LittleEndian.AppendUint16(b, 1)
+	b = binary.LittleEndian.AppendUint16(b, uint16(channels))
+	b = binary.LittleEndian.AppendUint32(b, rate)
+	b = binary.LittleEndian.AppendUint32(b, rate*uint32(2*channels))
+	b = binary.LittleEndian.AppendUint16(b, uint16(2*channels))
+	b = binary.LittleEndian.AppendUint16(b, 16)
+	b = append(b, "data"...)
+	b = binary.LittleEndian.AppendUint32(b, uint32(data))
+	return append(b, make([]byte, data)...)
+}
+
+func TestDetectSpeechInBufferWAV(t *testing.T) {
+	srv := New(config.Config{Threshold: 0.5, MinSpeechDurationMs: 20, MinSilenceDurationMs: 20}, nil,
+		func() engine.Engine { return engine.NewStubEngine() })
+	// A stereo file is downmixed; its 149*320 frames match the PCM test.
+	res, err := srv.DetectSpeechInBuffer(context.Background(), &napv1.DetectSpeechRequest{
+		Format:  &napv1.AudioFormat{Encoding: EncodingWAV},
+		PcmData: wavFile(16000, 2, 149*320),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := BufferSegment{Start: 980 * time.Millisecond, End: 1980 * time.Millisecond}
+	if len(res.Segments) != 1 || res.Segments[0] != want {
+		t.Errorf("segments = %v, want [%v]", res.Segments, want)
+	}
+	if res.AudioDuration != 2980*time.Millisecond {
+		t.Errorf("audio duration = %v, want 2.98s", res.AudioDuration)
+	}
+
+	// A 44.1 kHz file is resampled without allow_resampling.
+	if _, err := srv.DetectSpeechInBuffer(context.Background(), &napv1.DetectSpeechRequest{
+		Format:  &napv1.AudioFormat{Encoding: EncodingWAV},
+		PcmData: wavFile(44100, 1, 44100),
+	}); err != nil {
+		t.Errorf("44.1 kHz file: %v", err)
+	}
+
+	for name, req := range map[string]*napv1.DetectSpeechRequest{
+		"not wav":       {Format: &napv1.AudioFormat{Encoding: EncodingWAV}, PcmData: make([]byte, 640)},
+		"rate mismatch": {Format: &napv1.AudioFormat{Encoding: EncodingWAV, SampleRate: 8000}, PcmData: wavFile(16000, 1, 320)},
+		"no audio":      {Format: &napv1.AudioFormat{Encoding: EncodingWAV}, PcmData: wavFile(16000, 1, 0)},
+	} {
+		if _, err := srv.DetectSpeechInBuffer(context.Background(), req); status.Code(err) != codes.InvalidArgument {
+			t.Errorf("%s: err = %v, want InvalidArgument", name, err)
+		}
+	}
+}
+
+func TestDetectSpeechInBufferScriptedBoundaries(t *testing.T) {
+	// A 100 ms blip is shorter than min_speech_duration_ms and a 100 ms dip
+	// shorter than min_silence_duration_ms: one segment, for speech from 900
+	// to 2000 ms, with each boundary reported on the frame that confirms it.
+	script := engine.Script{Steps: []engine.ScriptStep{
+		{Frames: 20, Confidence: 0.1},
+		{Frames: 5, Confidence: 0.8},
+		{Frames: 20, Confidence: 0.1},
+		{Frames: 30, Confidence: 0.9},
+		{Frames: 5, Confidence: 0.3},
+		{Frames: 20, Confidence: 0.9},
+		{Frames: 25, Confidence: 0.1},
+	}}
+	cfg := config.Config{Threshold: 0.5, MinSpeechDurationMs: 200, MinSilenceDurationMs: 200}
+	srv := New(cfg, nil, func() engine.Engine { return engine.NewStubEngine(engine.WithScript(script)) })
+	res, err := srv.DetectSpeechInBuffer(context.Background(), &napv1.DetectSpeechRequest{
+		Format:  &napv1.AudioFormat{SampleRate: 16000},
+		PcmData: make([]byte, 125*640),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := BufferSegment{Start: 1080 * time.Millisecond, End: 2180 * time.Millisecond}
+	if len(res.Segments) != 1 || res.Segments[0] != want {
+		t.Errorf("segments = %v, want [%v]", res.Segments, want)
+	}
+}
@@ -0,0 +1,92 @@
+package server
+
+import (
+	"time"
+
+	"github.com/nupi-ai/plugin-vad-local-silero/internal/engine"
+	"github.com/nupi-ai/plugin-vad-local-silero/internal/errs"
+)
+
+// WithEngineName sets the engine name reported by Capabilities (the engine
+// the adapter resolved "auto" to).
+func WithEngineName(name string) Option {
+	return func(s *Server) {
+		s.engineName = name
+	}
+}
+
+// Capabilities describes the frame timing of the server's engine, so clients
+// can size their chunks to whole inference frames instead of hard-coding
+// 640-byte chunks.
+type Capabilities struct {
+	Engine string
+	// Rates holds the timing at each rate the engine runs at natively.
+	Rates []RateTiming
+	// Resampling reports that other rates (audio.MinSampleRate to
+	// audio.MaxSampleRate) are accepted and resampled to 16 kHz.
+	Resampling bool
+	// MaxChunkBytes is the largest accepted pcm_data (max_chunk_bytes).
+	MaxChunkBytes int
+}
+
+// RateTiming is an engine's frame timing at one sample rate.
+type RateTiming struct {
+	SampleRate uint32
+	// FrameSamples is the stride between results (the hop) and
+	// FrameDuration the audio it covers.
+	FrameSamples  int
+	FrameDuration time.Duration
+	// WindowSamples is the audio each result is scored over; it exceeds
+	// FrameSamples when windows overlap.
+	WindowSamples int
+	// ChunkAlignBytes is the s16le size of one frame. Chunks that are a
+	// multiple of it produce the same number of results every time, with
+	// nothing left buffered in the engine.
+	ChunkAlignBytes int
+}
+
+// Capabilities reports the engine's frame timing. The first successful call
+// creates an engine to measure it; the result is cached.
+func (s *Server) Capabilities() (Capabilities, error) {
+	s.capsMu.Lock()
+	defer s.capsMu.Unlock()
+	if s.caps == nil {
+		caps, err := s.measureCapabilities()
+		if err != nil {
+			return Capabilities{}, err
+		}
+		s.caps = &caps
+	}
+	return *s.caps, nil
+}
+
+func (s *Server) measureCapabilities() (Capabilities, error) {
+	eng := s.newEngine()
+	if eng == nil {
+		return Capabilities{}, errs.New(errs.ErrEngineUnavailable, "engine creation failed: factory returned nil")
+	}
+	defer eng.Close()
+
+	caps := Capabilities{
+		Engine:        s.engineName,
+		Resampling:    s.cfg.AllowResampling,
+		MaxChunkBytes: s.maxChunkBytes(),
+	}
+	for _, rate := range []uint32{engine.ExpectedSampleRate, engine.TelephonySampleRate} {
+		if err := eng.SetSampleRate(rate); err != nil {
+			continue
+		}
+		clock, err := newFrameClock(eng)
+		if err != nil {
+			return Capabilities{}, errs.New(errs.ErrInternal, "engine returned %v", err)
+		}
+		caps.Rates = append(caps.Rates, RateTiming{
+			SampleRate:      rate,
+			FrameSamples:    int(clock.frameSamples),
+			FrameDuration:   clock.frameDuration(),
+			WindowSamples:   engine.WindowSamples(eng),
+			ChunkAlignBytes: int(clock.frameSamples) * 2,
+		})
+	}
+	return caps, nil
+}
@@ -0,0 +1,61 @@
+package server
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/nupi-ai/plugin-vad-local-silero/internal/config"
+	"github.com/nupi-ai/plugin-vad-local-silero/internal/engine"
+	"github.com/nupi-ai/plugin-vad-local-silero/internal/errs"
+)
+
+func TestCapabilities(t *testing.T) {
+	created := 0
+	srv := New(config.Config{AllowResampling: true}, nil, func() engine.Engine {
+		created++
+		return engine.NewStubEngine()
+	}, WithEngineName(config.EngineStub))
+
+	caps, err := srv.Capabilities()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if caps.Engine != config.EngineStub || !caps.Resampling || caps.MaxChunkBytes != MaxPCMChunkBytes {
+		t.Errorf("caps = %+v", caps)
+	}
+	want := []RateTiming{
+		{SampleRate: 16000, FrameSamples: 320, FrameDuration: 20 * time.Millisecond, WindowSamples: 320, ChunkAlignBytes: 640},
+		{SampleRate: 8000, FrameSamples: 160, FrameDuration: 20 * time.Millisecond, WindowSamples: 160, ChunkAlignBytes: 320},
+	}
+	if len(caps.Rates) != len(want) {
+		t.Fatalf("rates = %+v, want %+v", caps.Rates, want)
+	}
+	for i := range want {
+		if caps.Rates[i] != want[i] {
+			t.Errorf("rate %d = %+v, want %+v", i, caps.Rates[i], want[i])
+		}
+	}
+
+	if _, err := srv.Capabilities(); err != nil || created != 1 {
+		t.Errorf("second call: err = %v, engines created = %d, want cached result", err, created)
+	}
+}
+
+func TestCapabilitiesEngineUnavailable(t *testing.T) {
+	fail := true
+	srv := New(config.Config{}, nil, func() engine.Engine {
+		if fail {
+			return nil
+		}
+		return engine.NewStubEngine()
+	})
+	if _, err := srv.Capabilities(); !errors.Is(err, errs.ErrEngineUnavailable) {
+		t.Fatalf("err = %v, want ErrEngineUnavailable", err)
+	}
+	// Failures are not cached.
+	fail = false
+	if _, err := srv.Capabilities(); err != nil {
+		t.Fatalf("after recovery: %v", err)
+	}
+}
@@ -0,0 +1,47 @@
+package server
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/nupi-ai/plugin-vad-local-silero/internal/engine"
+)
+
+// frameClock converts frame counts to audio time. It works in samples so
+// that windows which are not a whole number of milliseconds (e.g. 400
+// samples at 16 kHz = 25 ms, but 256 at 11025 Hz ≈ 23.2 ms) produce exact
+// offsets: every timestamp is computed from the frame index, never summed
+// from rounded per-frame durations.
+type frameClock struct {
+	frameSamples int64
+	sampleRate   int64
+}
+
+// newFrameClock returns the clock of an engine configured for its stream.
+func newFrameClock(eng engine.Engine) (frameClock, error) {
+	c := frameClock{frameSamples: int64(eng.FrameSamples()), sampleRate: int64(eng.SampleRate())}
+	if c.frameSamples <= 0 || c.sampleRate <= 0 {
+		return frameClock{}, fmt.Errorf("invalid frame geometry: %d samples at %d Hz", c.frameSamples, c.sampleRate)
+	}
+	return c, nil
+}
+
+// offset returns the audio time at the start of frame index frames.
+func (c frameClock) offset(frames int64) time.Duration {
+	samples := frames * c.frameSamples
+	secs := samples / c.sampleRate
+	rem := samples % c.sampleRate
+	return time.Duration(secs)*time.Second + time.Duration(rem)*time.Second/time.Duration(c.sampleRate)
+}
+
+// frameDuration returns the duration of one frame, rounded to the
+// nanosecond. Use it for display only; offsets come from offset.
+func (c frameClock) frameDuration() time.Duration {
+	return time.Duration(c.frameSamples) * time.Second / time.Duration(c.sampleRate)
+}
+
+// framesFor returns the number of whole frames needed to cover ms
+// milliseconds of audio, rounding up.
+func (c frameClock) framesFor(ms int) int {
+	return ceilDiv(ms*int(c.sampleRate), 1000*int(c.frameSamples))
+}
@@ -0,0 +1,87 @@
+package server
+
+import (
+	"testing"
+	"time"
+
+	"github.com/nupi-ai/plugin-vad-local-silero/internal/engine"
+)
+
+func TestFrameClockOffsetExact(t *testing.T) {
+	// 256 samples at 11025 Hz ≈ 23.2199546 ms: not a whole number of
+	// milliseconds (or nanoseconds).
+	c := frameClock{frameSamples: 256, sampleRate: 11025}
+
+	// One hour of frames. Summing a per-frame duration rounded to whole
+	// milliseconds would drift by seconds; the clock must stay within a
+	// nanosecond of the exact value.
+	frames := int64(time.Hour / c.frameDuration())
+	exactNs := float64(frames*256) * 1e9 / 11025
+	if got := float64(c.offset(frames)); exactNs-got >= 1 || got > exactNs {
+		t.Errorf("offset(%d) = %v, want %.0fns (floor)", frames, c.offset(frames), exactNs)
+	}
+	naive := time.Duration(frames) * time.Duration(int(c.frameDuration()/time.Millisecond)) * time.Millisecond
+	if drift := c.offset(frames) - naive; drift < time.Second {
+		t.Errorf("integer-ms accumulation drifts only %v over an hour; test premise broken", drift)
+	}
+}
+
+func TestFrameClockWholeMilliseconds(t *testing.T) {
+	tests := []struct {
+		samples, rate int64
+		frames        int64
+		want          time.Duration
+	}{
+		{320, 16000, 50, time.Second},          // stub, 20ms
+		{512, 16000, 1, 32 * time.Millisecond}, // silero 16kHz
+		{256, 8000, 1000, 32 * time.Second},    // silero 8kHz
+		{0, 16000, 0, 0},
+	}
+	for _, tt := range tests {
+		c := frameClock{frameSamples: tt.samples, sampleRate: tt.rate}
+		if got := c.offset(tt.frames); got != tt.want {
+			t.Errorf("%d samples @ %d Hz: offset(%d) = %v, want %v", tt.samples, tt.rate, tt.frames, got, tt.want)
+		}
+	}
+}
+
+func TestFrameClockFramesFor(t *testing.T) {
+	tests := []struct {
+		samples, rate int64
+		ms            int
+		want          int
+	}{
+		{320, 16000, 250, 13}, // 250 / 20 = 12.5 → 13
+		{512, 16000, 300, 10}, // 300 / 32 = 9.375 → 10
+		{512, 16000, 32, 1},
+		{256, 16000, 300, 19}, // silero 16ms hop: 18.75 → 19
+		{128, 16000, 250, 32}, // silero 8ms hop: 31.25 → 32
+		{64, 8000, 250, 32},   // 8ms hop at 8kHz
+		{400, 16000, 75, 3},   // 25ms frames: exact
+		{441, 44100, 25, 3},   // 10ms frames: 2.5 → 3
+		{256, 11025, 100, 5},  // 23.22ms frames: 4.31 → 5
+	}
+	for _, tt := range tests {
+		c := frameClock{frameSamples: tt.samples, sampleRate: tt.rate}
+		if got := c.framesFor(tt.ms); got != tt.want {
+			t.Errorf("%d samples @ %d Hz: framesFor(%d) = %d, want %d", tt.samples, tt.rate, tt.ms, got, tt.want)
+		}
+	}
+}
+
+func TestNewFrameClockFromEngine(t *testing.T) {
+	eng := engine.NewStubEngine()
+	if err := eng.SetSampleRate(8000); err != nil {
+		t.Fatal(err)
+	}
+	c, err := newFrameClock(eng)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if c.frameSamples != 160 || c.sampleRate != 8000 {
+		t.Errorf("clock = %+v, want 160 samples at 8000 Hz", c)
+	}
+	if d := c.frameDuration(); d != 20*time.Millisecond {
+		t.Errorf("frameDuration() = %v, want 20ms", d)
+	}
+}
@@ -0,0 +1,115 @@
+package server
+
+import (
+	"time"
+
+	"github.com/nupi-ai/plugin-vad-local-silero/internal/config"
+	"github.com/nupi-ai/plugin-vad-local-silero/internal/metrics"
+)
+
+// inferenceMetrics counts windows over the inference budget, per action
+// taken.
+type inferenceMetrics struct {
+	warned  *metrics.Counter
+	skipped *metrics.Counter
+}
+
+func newInferenceMetrics(reg *metrics.Registry) *inferenceMetrics {
+	return &inferenceMetrics{
+		warned:  reg.Counter("vad_inference_budget_exceeded_total", "action", config.InferenceBudgetWarn),
+		skipped: reg.Counter("vad_inference_budget_exceeded_total", "action", config.InferenceBudgetSkip),
+	}
+}
+
+// frameDeadline watches a stream's inference time per frame against its
+// inference budget. Time is averaged over windows of frames, so a single
+// slow call (a GC pause, a cold cache) does not count; only a window whose
+// mean exceeds the budget does.
+type frameDeadline struct {
+	limit  time.Duration
+	frames int64 // frames per window
+	skip   bool  // degrade by skipping frames when over budget
+
+	used time.Duration // inference time of the current window
+	seen int64         // frames of the current window
+
+	// degraded is set while the engine skips alternate frames.
+	degraded bool
+}
+
+// Outcomes of a frameDeadline window.
+const (
+	withinDeadline = iota
+	overDeadline
+	restoredDeadline // a degraded stream would fit the budget at full rate
+)
+
+// newFrameDeadline returns the tracker of budget; skip is whether the
+// stream's engine can skip frames.
+func newFrameDeadline(budget config.InferenceBudget, skip bool) *frameDeadline {
+	return &frameDeadline{
+		limit:  time.Duration(budget.FrameMs * float64(time.Millisecond)),
+		frames: int64(budget.EffectiveFrames()),
+		skip:   skip && budget.Action == config.InferenceBudgetSkip,
+	}
+}
+
+// observe charges an engine call that took used for frames frames. At the
+// end of a window it returns the window's outcome and mean time per frame;
+// in between it returns withinDeadline.
+//
+// A window over budget degrades the stream when skipping is enabled. A
+// degraded stream pays for about half its frames, so it is restored once
+// twice its mean fits the budget.
+func (d *frameDeadline) observe(used time.Duration, frames int) (int, time.Duration) {
+	d.used += used
+	d.seen += int64(frames)
+	if d.seen < d.frames {
+		return withinDeadline, 0
+	}
+	mean := d.used / time.Duration(d.seen)
+	d.used, d.seen = 0, 0
+	switch {
+	case mean > d.limit:
+		if d.skip {
+			d.degraded = true
+		}
+		return overDeadline, mean
+	case d.degraded && 2*mean <= d.limit:
+		d.degraded = false
+		return restoredDeadline, mean
+	}
+	return withinDeadline, mean
+}
+
+// checkDeadline charges a ProcessChunk call to the stream's deadline, and
+// logs, counts and degrades or restores the stream at the end of a window.
+func (s *Server) checkDeadline(d *frameDeadline, strides *strideControl, used time.Duration, frames int, sessionID, streamID string) {
+	outcome, mean := d.observe(used, frames)
+	switch outcome {
+	case overDeadline:
+		action, counter := config.InferenceBudgetWarn, s.inference.warned
+		if d.degraded {
+			action, counter = config.InferenceBudgetSkip, s.inference.skipped
+			strides.budget = 2
+			strides.apply()
+		}
+		counter.Inc()
+		s.log.Warn("stream exceeded inference budget",
+			"session_id", sessionID,
+			"stream_id", streamID,
+			"action", action,
+			"frame_ms", durationMs(mean),
+			"budget_ms", s.cfg.InferenceBudget.FrameMs,
+		)
+	case restoredDeadline:
+		strides.budget = 0
+		strides.apply()
+		s.log.Info("stream back within inference budget, scoring every frame",
+			"session_id", sessionID,
+			"stream_id", streamID,
+			"frame_ms", durationMs(mean),
+			"budget_ms", s.cfg.InferenceBudget.FrameMs,
+		)
+	}
+}
@@ -0,0 +1,133 @@
+package server
+
+import (
+	"context"
+	"io"
+	"testing"
+	"time"
+
+	napv1 "github.com/nupi-ai/nupi/api/nap/v1"
+
+	"github.com/nupi-ai/plugin-vad-local-silero/internal/config"
+	"github.com/nupi-ai/plugin-vad-local-silero/internal/engine"
+)
+
+func TestFrameDeadline(t *testing.T) {
+	budget := config.InferenceBudget{FrameMs: 5, Frames: 4, Action: config.InferenceBudgetSkip}
+	d := newFrameDeadline(budget, true)
+
+	// A slow call alone does not fail the window: the mean does.
+	for i, used := range []time.Duration{14 * time.Millisecond, time.Millisecond, time.Millisecond} {
+		if outcome, _ := d.observe(used, 1); outcome != withinDeadline {
+			t.Fatalf("call %d: outcome %d before the window ended", i, outcome)
+		}
+	}
+	if outcome, mean := d.observe(time.Millisecond, 1); outcome != withinDeadline || mean != 4250*time.Microsecond {
+		t.Fatalf("window 1: outcome %d, mean %v; want within at 4.25ms", outcome, mean)
+	}
+
+	if outcome, mean := d.observe(24*time.Millisecond, 4); outcome != overDeadline || mean != 6*time.Millisecond || !d.degraded {
+		t.Fatalf("window 2: outcome %d, mean %v, degraded %v; want over and degraded", outcome, mean, d.degraded)
+	}
+	// Degraded, 3ms per frame would be 6ms at full rate: still degraded.
+	if outcome, _ := d.observe(12*time.Millisecond, 4); outcome != withinDeadline || !d.degraded {
+		t.Fatalf("window 3: outcome %d, degraded %v; want within, still degraded", outcome, d.degraded)
+	}
+	if outcome, _ := d.observe(10*time.Millisecond, 4); outcome != restoredDeadline || d.degraded {
+		t.Fatalf("window 4: outcome %d, degraded %v; want restored", outcome, d.degraded)
+	}
+
+	// Without a strided engine, the stream is only reported.
+	d = newFrameDeadline(budget, false)
+	if outcome, _ := d.observe(40*time.Millisecond, 4); outcome != overDeadline || d.degraded {
+		t.Errorf("unstrided: outcome %d, degraded %v; want over, not degraded", outcome, d.degraded)
+	}
+}
+
+func TestInferenceBudgetSkipsFrames(t *testing.T) {
+	cfg := config.Config{
+		Threshold:            0.5,
+		MinSpeechDurationMs:  20,
+		MinSilenceDurationMs: 20,
+		InferenceBudget:      config.InferenceBudget{FrameMs: 1, Frames: 4, Action: config.InferenceBudgetSkip},
+	}
+	script := engine.Script{Loop: true, Steps: []engine.ScriptStep{
+		{Frames: 1, Confidence: 0.2}, {Frames: 1, Confidence: 0.4}, {Frames: 1, Confidence: 0.6},
+	}}
+	newEngine := func() engine.Engine {
+		return engine.NewFaultEngine(engine.NewStubEngine(engine.WithScript(script)), engine.DelayChunks(2*time.Millisecond))
+	}
+	// With expected_streams set, streams get engines from a warm pool; the
+	// skipping must reach the engine through its wrapper.
+	pool := engine.NewPool(newEngine, 1)
+	defer pool.Close()
+	pool.Warm()
+	for _, tc := range []struct {
+		name      string
+		newEngine func() engine.Engine
+	}{
+		{"engine", newEngine},
+		{"pooled", pool.Get},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			testInferenceBudgetSkipsFrames(t, New(cfg, nil, tc.newEngine))
+		})
+	}
+}
+
+func testInferenceBudgetSkipsFrames(t *testing.T, srv *Server) {
+	client, cleanup := startTestServerWith(t, srv)
+	defer cleanup()
+
+	stream, err := client.DetectSpeech(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := stream.Send(&napv1.DetectSpeechRequest{ConfigJson: `{"emit_probabilities": true}`}); err != nil {
+		t.Fatal(err)
+	}
+	const frames = 12
+	for range frames {
+		if err := stream.Send(&napv1.DetectSpeechRequest{Format: &napv1.AudioFormat{SampleRate: 16000}, PcmData: make([]byte, 640)}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	stream.CloseSend()
+
+	var probs []float32
+	for {
+		evt, err := stream.Recv()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		if evt.GetType() == ProbabilityEventType {
+			probs = append(probs, evt.GetConfidence())
+		}
+	}
+	if len(probs) != frames {
+		t.Fatalf("probability samples = %d, want %d", len(probs), frames)
+	}
+	// The first window runs at full rate; every 2ms call is over the 1ms
+	// budget, so from then on every other frame repeats its predecessor.
+	for i := 1; i < 4; i++ {
+		if probs[i] == probs[i-1] {
+			t.Errorf("full rate: frame %d repeats frame %d: %v", i, i-1, probs)
+		}
+	}
+	for i := 4; i < frames; i += 2 {
+		if probs[i+1] != probs[i] || (i > 4 && probs[i] == probs[i-1]) {
+			t.Errorf("skipping: frames %d-%d are not a scored frame and its repeat: %v", i, i+1, probs)
+		}
+	}
+
+	snapshot := srv.Metrics().Snapshot()
+	if got := snapshot[`vad_inference_budget_exceeded_total{action="skip"}`]; got != frames/4 {
+		t.Errorf("skip violations = %d, want one per window (%d)", got, frames/4)
+	}
+	if got := snapshot[`vad_inference_budget_exceeded_total{action="warn"}`]; got != 0 {
+		t.Errorf("warn violations = %d, want 0", got)
+	}
+}
@@ -0,0 +1,157 @@
+package server
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"math"
+	"sync/atomic"
+	"time"
+
+	"github.com/nupi-ai/plugin-vad-local-silero/internal/engine"
+	"github.com/nupi-ai/plugin-vad-local-silero/internal/metrics"
+)
+
+// driftBuckets is the number of equal-width confidence buckets in [0, 1].
+const driftBuckets = 10
+
+// driftWindow accumulates per-frame observations across all streams for one
+// reporting interval. Counters are atomic so streams never contend on a lock.
+type driftWindow struct {
+	buckets [driftBuckets]atomic.Int64
+	speech  atomic.Int64
+}
+
+// driftMonitor aggregates fleet-wide confidence distributions and speech
+// ratios per interval and compares each interval with the long-run
+// distribution, so a deployment-wide shift (new microphones, a codec change)
+// shows up as drift before it shows up as accuracy complaints.
+type driftMonitor struct {
+	window atomic.Pointer[driftWindow]
+
+	// Long-run distribution of all previously reported windows. Only the
+	// reporting goroutine touches these.
+	baseline       [driftBuckets]int64
+	baselineFrames int64
+	baselineSpeech int64
+
+	bucketCounters [driftBuckets]*metrics.Counter
+	log            *slog.Logger
+}
+
+// driftReport is the summary of one reporting window.
+type driftReport struct {
+	Frames      int64
+	SpeechRatio float64
+	Histogram   [driftBuckets]int64
+	// Drift is the total variation distance in [0, 1] between this window's
+	// confidence distribution and the long-run one; -1 when there is no
+	// baseline yet or the window is empty.
+	Drift float64
+}
+
+func newDriftMonitor(reg *metrics.Registry, logger *slog.Logger) *driftMonitor {
+	m := &driftMonitor{log: logger}
+	m.window.Store(&driftWindow{})
+	for i := range m.bucketCounters {
+		m.bucketCounters[i] = reg.Counter("vad_confidence_frames_total", "bucket", bucketLabel(i))
+	}
+	return m
+}
+
+// bucketLabel renders the confidence range of bucket i, e.g. "0.3-0.4".
+func bucketLabel(i int) string {
+	return fmt.Sprintf("%.1f-%.1f", float64(i)/driftBuckets, float64(i+1)/driftBuckets)
+}
+
+func confidenceBucket(c float32) int {
+	if math.IsNaN(float64(c)) || c <= 0 {
+		return 0
+	}
+	b := int(c * driftBuckets)
+	if b >= driftBuckets {
+		b = driftBuckets - 1
+	}
+	return b
+}
+
+// observe records one inferred frame and whether the stream's detector
+// classified it as speech.
+func (m *driftMonitor) observe(r engine.Result, speech bool) {
+	b := confidenceBucket(r.Confidence)
+	w := m.window.Load()
+	w.buckets[b].Add(1)
+	if speech {
+		w.speech.Add(1)
+	}
+	m.bucketCounters[b].Inc()
+}
+
+// report closes the current window, folds it into the long-run baseline and
+// returns its summary. Observations racing with the swap may land in the
+// closed window after it was read; losing a handful of frames is acceptable
+// for a distribution report.
+func (m *driftMonitor) report() driftReport {
+	w := m.window.Swap(&driftWindow{})
+
+	var rep driftReport
+	for i := range w.buckets {
+		rep.Histogram[i] = w.buckets[i].Load()
+		rep.Frames += rep.Histogram[i]
+	}
+	speech := w.speech.Load()
+	rep.Drift = -1
+	if rep.Frames > 0 {
+		rep.SpeechRatio = float64(speech) / float64(rep.Frames)
+		if m.baselineFrames > 0 {
+			var tv float64
+			for i := range rep.Histogram {
+				p := float64(rep.Histogram[i]) / float64(rep.Frames)
+				q := float64(m.baseline[i]) / float64(m.baselineFrames)
+				tv += math.Abs(p - q)
+			}
+			rep.Drift = tv / 2
+		}
+	}
+
+	for i := range rep.Histogram {
+		m.baseline[i] += rep.Histogram[i]
+	}
+	m.baselineFrames += rep.Frames
+	m.baselineSpeech += speech
+	return rep
+}
+
+// RunDriftMonitor logs a confidence distribution report every interval until
+// ctx is cancelled. It is a no-op unless drift monitoring is enabled in the
+// config (drift_report_interval_s > 0).
+func (s *Server) RunDriftMonitor(ctx context.Context, interval time.Duration) {
+	if s.drift == nil || interval <= 0 {
+		return
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			rep := s.drift.report()
+			if rep.Frames == 0 {
+				continue
+			}
+			var baselineRatio float64
+			if s.drift.baselineFrames > 0 {
+				baselineRatio = float64(s.drift.baselineSpeech) / float64(s.drift.baselineFrames)
+			}
+			s.drift.log.Info("confidence distribution",
+				"window", interval,
+				"frames", rep.Frames,
+				"speech_ratio", rep.SpeechRatio,
+				"baseline_speech_ratio", baselineRatio,
+				"histogram", rep.Histogram[:],
+				"drift", rep.Drift,
+			)
+		}
+	}
+}
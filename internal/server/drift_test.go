@@ -0,0 +1,91 @@
+package server
+
+import (
+	"log/slog"
+	"math"
+	"testing"
+
+	"github.com/nupi-ai/plugin-vad-local-silero/internal/engine"
+	"github.com/nupi-ai/plugin-vad-local-silero/internal/metrics"
+)
+
+func TestConfidenceBucket(t *testing.T) {
+	tests := []struct {
+		c    float32
+		want int
+	}{
+		{0, 0},
+		{-0.5, 0},
+		{0.05, 0},
+		{0.1, 1},
+		{0.55, 5},
+		{0.99, 9},
+		{1.0, 9},
+		{float32(math.NaN()), 0},
+	}
+	for _, tt := range tests {
+		if got := confidenceBucket(tt.c); got != tt.want {
+			t.Errorf("confidenceBucket(%v) = %d, want %d", tt.c, got, tt.want)
+		}
+	}
+}
+
+func TestDriftReportWindows(t *testing.T) {
+	reg := metrics.NewRegistry()
+	m := newDriftMonitor(reg, slog.Default())
+
+	// Window 1: mostly low-confidence silence.
+	for i := 0; i < 90; i++ {
+		m.observe(engine.Result{Confidence: 0.05}, false)
+	}
+	for i := 0; i < 10; i++ {
+		m.observe(engine.Result{Confidence: 0.95}, true)
+	}
+	first := m.report()
+	if first.Frames != 100 {
+		t.Fatalf("frames = %d, want 100", first.Frames)
+	}
+	if first.SpeechRatio != 0.1 {
+		t.Errorf("speech ratio = %v, want 0.1", first.SpeechRatio)
+	}
+	if first.Histogram[0] != 90 || first.Histogram[9] != 10 {
+		t.Errorf("histogram = %v, want 90 in bucket 0 and 10 in bucket 9", first.Histogram)
+	}
+	if first.Drift != -1 {
+		t.Errorf("first window drift = %v, want -1 (no baseline)", first.Drift)
+	}
+
+	// Window 2: same distribution → no drift.
+	for i := 0; i < 9; i++ {
+		m.observe(engine.Result{Confidence: 0.01}, false)
+	}
+	m.observe(engine.Result{Confidence: 0.99}, true)
+	if same := m.report(); same.Drift > 1e-9 {
+		t.Errorf("identical distribution drift = %v, want 0", same.Drift)
+	}
+
+	// Window 3: everything moved to the middle bucket → maximal drift.
+	for i := 0; i < 50; i++ {
+		m.observe(engine.Result{Confidence: 0.45}, false)
+	}
+	if shifted := m.report(); math.Abs(shifted.Drift-1) > 1e-9 {
+		t.Errorf("disjoint distribution drift = %v, want 1", shifted.Drift)
+	}
+
+	// Cumulative bucket counters survive window resets.
+	snap := reg.Snapshot()
+	if got := snap[`vad_confidence_frames_total{bucket="0.0-0.1"}`]; got != 99 {
+		t.Errorf("bucket 0.0-0.1 total = %d, want 99", got)
+	}
+	if got := snap[`vad_confidence_frames_total{bucket="0.4-0.5"}`]; got != 50 {
+		t.Errorf("bucket 0.4-0.5 total = %d, want 50", got)
+	}
+}
+
+func TestDriftEmptyWindow(t *testing.T) {
+	m := newDriftMonitor(metrics.NewRegistry(), slog.Default())
+	rep := m.report()
+	if rep.Frames != 0 || rep.Drift != -1 {
+		t.Errorf("empty report = %+v, want zero frames and drift -1", rep)
+	}
+}
@@ -0,0 +1,203 @@
+package server
+
+import (
+	"bufio"
+	"encoding/binary"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// wavHeaderSize is the size of the canonical PCM WAV header written before
+// the samples of a debug dump.
+const wavHeaderSize = 44
+
+// audioDump writes the PCM a stream received, as the client sent it, to a
+// mono s16le WAV file. The header's sizes are patched when the dump closes.
+// Like the frame trace, write failures disable the dump and never fail the
+// stream.
+type audioDump struct {
+	path    string
+	f       *os.File
+	w       *bufio.Writer
+	log     *slog.Logger
+	rate    uint32
+	limit   int64 // most PCM bytes kept
+	written int64
+	capped  bool
+	failed  bool
+}
+
+// newAudioDump creates the WAV file at path for audio at sampleRate.
+func newAudioDump(path string, sampleRate uint32, limit int64, logger *slog.Logger) (*audioDump, error) {
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0o600)
+	if err != nil {
+		return nil, err
+	}
+	d := &audioDump{path: path, f: f, w: bufio.NewWriter(f), log: logger, rate: sampleRate, limit: limit &^ 1}
+	if _, err := d.w.Write(wavHeader(sampleRate, 0)); err != nil {
+		f.Close()
+		os.Remove(path)
+		return nil, err
+	}
+	return d, nil
+}
+
+// wavHeader returns the header of a mono 16-bit PCM WAV file holding
+// dataBytes of samples.
+func wavHeader(sampleRate uint32, dataBytes uint32) []byte {
+	h := make([]byte, 0, wavHeaderSize)
+	h = append(h, "RIFF"...)
+	h = binary.LittleEndian.AppendUint32(h, 36+dataBytes)
+	h = append(h, "WAVEfmt "...)
+	h = binary.LittleEndian.AppendUint32(h, 16) // fmt chunk size
+	h = binary.LittleEndian.AppendUint16(h, 1)  // PCM
+	h = binary.LittleEndian.AppendUint16(h, 1)  // channels
+	h = binary.LittleEndian.AppendUint32(h, sampleRate)
+	h = binary.LittleEndian.AppendUint32(h, sampleRate*2) // byte rate
+	h = binary.LittleEndian.AppendUint16(h, 2)            // block align
+	h = binary.LittleEndian.AppendUint16(h, 16)           // bits per sample
+	h = append(h, "data"...)
+	h = binary.LittleEndian.AppendUint32(h, dataBytes)
+	return h
+}
+
+// write appends a chunk of received PCM, up to the dump's limit.
+func (d *audioDump) write(pcm []byte) {
+	if d.failed || d.capped {
+		return
+	}
+	if room := d.limit - d.written; int64(len(pcm)) > room {
+		pcm = pcm[:room]
+		d.capped = true
+		d.log.Warn("debug dump audio cap reached, later audio not dumped", "path", d.path, "bytes", d.limit)
+	}
+	if _, err := d.w.Write(pcm); err != nil {
+		d.failed = true
+		d.log.Warn("debug dump write failed, audio dump disabled for this stream", "path", d.path, "error", err)
+		return
+	}
+	d.written += int64(len(pcm))
+}
+
+// close flushes the samples and rewrites the header with their size. A
+// trailing odd byte, which only an odd-length chunk leaves, is not counted.
+func (d *audioDump) close() {
+	err := d.w.Flush()
+	if err == nil {
+		_, err = d.f.WriteAt(wavHeader(d.rate, uint32(d.written&^1)), 0)
+	}
+	if err != nil && !d.failed {
+		d.log.Warn("debug dump finalize failed", "path", d.path, "error", err)
+	}
+	if err := d.f.Close(); err != nil {
+		d.log.Warn("debug dump close failed", "path", d.path, "error", err)
+	}
+	d.log.Info("debug dump written", "path", d.path, "bytes", d.written, "capped", d.capped)
+}
+
+// dumpRotator keeps the debug dump directory under its size cap by deleting
+// the oldest dump files once a stream's dump closes. Files of dumps still
+// being written are never deleted.
+type dumpRotator struct {
+	dir   string
+	limit int64
+	log   *slog.Logger
+
+	mu     sync.Mutex
+	active map[string]bool // dump file paths still open
+}
+
+func newDumpRotator(dir string, limit int64, logger *slog.Logger) *dumpRotator {
+	return &dumpRotator{dir: dir, limit: limit, log: logger, active: make(map[string]bool)}
+}
+
+// acquire marks paths as being written.
+func (r *dumpRotator) acquire(paths ...string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, p := range paths {
+		r.active[p] = true
+	}
+}
+
+// release marks paths as complete and rotates the directory.
+func (r *dumpRotator) release(paths ...string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, p := range paths {
+		delete(r.active, p)
+	}
+	r.rotate()
+}
+
+// rotate deletes the oldest complete dump files until the directory's dump
+// files fit in the limit. Called with r.mu held.
+func (r *dumpRotator) rotate() {
+	entries, err := os.ReadDir(r.dir)
+	if err != nil {
+		r.log.Warn("debug dump rotation failed", "dir", r.dir, "error", err)
+		return
+	}
+	type dumpFile struct {
+		path string
+		size int64
+		mod  int64
+	}
+	var (
+		files []dumpFile
+		total int64
+	)
+	for _, e := range entries {
+		name := e.Name()
+		if !e.Type().IsRegular() || !(strings.HasSuffix(name, ".wav") || strings.HasSuffix(name, ".ndjson")) {
+			continue
+		}
+		info, err := e.Info()
+		if err != nil {
+			continue // removed meanwhile
+		}
+		total += info.Size()
+		path := filepath.Join(r.dir, name)
+		if !r.active[path] {
+			files = append(files, dumpFile{path, info.Size(), info.ModTime().UnixNano()})
+		}
+	}
+	sort.Slice(files, func(i, j int) bool { return files[i].mod < files[j].mod })
+	for _, f := range files {
+		if total <= r.limit {
+			return
+		}
+		if err := os.Remove(f.path); err != nil {
+			r.log.Warn("debug dump rotation failed", "path", f.path, "error", err)
+			continue
+		}
+		total -= f.size
+		r.log.Info("debug dump rotated out", "path", f.path, "bytes", f.size)
+	}
+}
+
+// openDebugDump creates a stream's debug dump: the WAV of its received audio
+// and, under the same name, its frame trace. On failure it logs and returns
+// nils; the stream continues without a dump.
+func (s *Server) openDebugDump(open traceOpen, logger *slog.Logger) (*audioDump, *frameTracer) {
+	base := filepath.Join(s.cfg.DebugDumpDir, traceFileBase(open.StreamID, open.SessionID))
+	wavPath, tracePath := base+".wav", base+".ndjson"
+	s.dumps.acquire(wavPath, tracePath)
+	d, err := newAudioDump(wavPath, open.SampleRate, s.cfg.EffectiveDebugDumpMaxStreamBytes(), logger)
+	if err == nil {
+		var t *frameTracer
+		if t, err = createFrameTracer(tracePath, open, logger); err == nil {
+			logger.Info("debug dump started", "path", base)
+			return d, t
+		}
+		d.f.Close()
+		os.Remove(wavPath)
+	}
+	s.dumps.release(wavPath, tracePath)
+	logger.Warn("debug dump unavailable, stream continues without dump", "error", err)
+	return nil, nil
+}
@@ -0,0 +1,127 @@
+package server
+
+import (
+	"bytes"
+	"encoding/binary"
+	"io"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	"github.com/nupi-ai/plugin-vad-local-silero/internal/config"
+)
+
+func TestDebugDump(t *testing.T) {
+	dir := t.TempDir()
+	cfg := config.Config{Threshold: 0.5, MinSpeechDurationMs: 20, MinSilenceDurationMs: 20, DebugDumpDir: dir}
+	client, cleanup := startTestServer(t, cfg)
+	defer cleanup()
+
+	chunk := make([]byte, 640)
+	for i := range chunk {
+		chunk[i] = byte(i)
+	}
+	if err := runPreprocessStream(t, client, chunk, "", ""); err != io.EOF {
+		t.Fatal(err)
+	}
+	if files, _ := os.ReadDir(dir); len(files) != 0 {
+		t.Fatalf("stream without debug_dump left %d files", len(files))
+	}
+	if err := runPreprocessStream(t, client, chunk, `{"debug_dump": true}`, ""); err != io.EOF {
+		t.Fatal(err)
+	}
+
+	wavs, _ := filepath.Glob(filepath.Join(dir, "*.wav"))
+	if len(wavs) != 1 {
+		t.Fatalf("got %d WAV files, want 1", len(wavs))
+	}
+	data, err := os.ReadFile(wavs[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := bytes.Repeat(chunk, 50)
+	if !bytes.Equal(data[:wavHeaderSize], wavHeader(16000, uint32(len(want)))) {
+		t.Errorf("WAV header = %x", data[:wavHeaderSize])
+	}
+	if !bytes.Equal(data[wavHeaderSize:], want) {
+		t.Errorf("WAV holds %d bytes of audio, want the %d received", len(data)-wavHeaderSize, len(want))
+	}
+	trace, err := os.ReadFile(strings.TrimSuffix(wavs[0], ".wav") + ".ndjson")
+	if err != nil {
+		t.Fatalf("no trace next to the audio: %v", err)
+	}
+	if lines := strings.Split(strings.TrimSpace(string(trace)), "\n"); len(lines) != 52 {
+		t.Errorf("trace has %d records, want open + 50 frames + close", len(lines))
+	}
+
+	// Changing it mid-stream is refused.
+	if err := runPreprocessStream(t, client, chunk, "", `{"debug_dump": true}`); status.Code(err) != codes.InvalidArgument {
+		t.Errorf("debug_dump change after audio: got %v, want InvalidArgument", err)
+	}
+}
+
+func TestDebugDumpRequiresDir(t *testing.T) {
+	client, cleanup := startTestServer(t, config.Config{Threshold: 0.5, MinSpeechDurationMs: 20, MinSilenceDurationMs: 20})
+	defer cleanup()
+	if err := runPreprocessStream(t, client, make([]byte, 640), `{"debug_dump": true}`, ""); status.Code(err) != codes.InvalidArgument {
+		t.Errorf("debug_dump without debug_dump_dir: got %v, want InvalidArgument", err)
+	}
+}
+
+func TestAudioDumpCap(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "s.wav")
+	d, err := newAudioDump(path, 8000, 101, slog.New(slog.NewTextHandler(io.Discard, nil)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 3; i++ {
+		d.write(make([]byte, 64))
+	}
+	d.close()
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// The limit is rounded down to whole samples.
+	if len(data) != wavHeaderSize+100 || binary.LittleEndian.Uint32(data[40:]) != 100 || !d.capped {
+		t.Errorf("capped dump: %d bytes, data size %d, capped %v", len(data), binary.LittleEndian.Uint32(data[40:]), d.capped)
+	}
+	if rate := binary.LittleEndian.Uint32(data[24:]); rate != 8000 {
+		t.Errorf("sample rate = %d, want 8000", rate)
+	}
+}
+
+func TestDumpRotator(t *testing.T) {
+	dir := t.TempDir()
+	now := time.Now()
+	write := func(name string, size int, age time.Duration) string {
+		path := filepath.Join(dir, name)
+		if err := os.WriteFile(path, make([]byte, size), 0o600); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.Chtimes(path, now.Add(-age), now.Add(-age)); err != nil {
+			t.Fatal(err)
+		}
+		return path
+	}
+	oldest := write("a.wav", 100, 4*time.Hour)
+	old := write("a.ndjson", 100, 3*time.Hour)
+	active := write("b.wav", 100, 5*time.Hour) // oldest, but still being written
+	kept := write("c.wav", 100, time.Hour)
+	other := write("notes.txt", 1000, 6*time.Hour)
+
+	r := newDumpRotator(dir, 250, slog.New(slog.NewTextHandler(io.Discard, nil)))
+	r.acquire(active)
+	r.release()
+	for path, want := range map[string]bool{oldest: false, old: false, active: true, kept: true, other: true} {
+		if _, err := os.Stat(path); (err == nil) != want {
+			t.Errorf("%s: exists %v, want %v", filepath.Base(path), err == nil, want)
+		}
+	}
+}
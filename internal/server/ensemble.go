@@ -0,0 +1,47 @@
+package server
+
+import (
+	"github.com/nupi-ai/plugin-vad-local-silero/internal/config"
+	"github.com/nupi-ai/plugin-vad-local-silero/internal/engine"
+	"github.com/nupi-ai/plugin-vad-local-silero/internal/errs"
+)
+
+// WithEnsembleEngines sets the factories for the second engine of ensemble
+// streams, keyed by engine name (config.EngineSilero, config.EngineStub).
+// Streams that ask for an engine without a factory fail with
+// errs.ErrInvalidConfig.
+func WithEnsembleEngines(factories map[string]func() engine.Engine) Option {
+	return func(s *Server) {
+		s.ensembleEngines = factories
+	}
+}
+
+// newEnsemble wraps primary with the stream's ensemble engine. It closes
+// primary when the ensemble cannot be built.
+func (s *Server) newEnsemble(primary engine.Engine, ens config.Ensemble) (engine.Engine, error) {
+	newSecondary, ok := s.ensembleEngines[ens.Engine]
+	if !ok {
+		primary.Close()
+		return nil, errs.New(errs.ErrInvalidConfig, "ensemble engine %q is not available", ens.Engine)
+	}
+	secondary := newSecondary()
+	if secondary == nil {
+		primary.Close()
+		return nil, errs.New(errs.ErrEngineUnavailable, "ensemble engine %q creation failed", ens.Engine)
+	}
+	return engine.NewEnsemble(primary, secondary, ensembleCombiner(ens)), nil
+}
+
+// ensembleCombiner returns the function merging the two engines'
+// probabilities for ens.Mode.
+func ensembleCombiner(ens config.Ensemble) func(p, s float32) float32 {
+	switch ens.Mode {
+	case config.EnsembleOr:
+		return func(p, s float32) float32 { return max(p, s) }
+	case config.EnsembleWeighted:
+		w := float32(ens.EffectiveWeight())
+		return func(p, s float32) float32 { return w*p + (1-w)*s }
+	default:
+		return func(p, s float32) float32 { return min(p, s) }
+	}
+}
@@ -0,0 +1,95 @@
+package server
+
+import (
+	"context"
+	"io"
+	"testing"
+
+	napv1 "github.com/nupi-ai/nupi/api/nap/v1"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	"github.com/nupi-ai/plugin-vad-local-silero/internal/config"
+	"github.com/nupi-ai/plugin-vad-local-silero/internal/engine"
+)
+
+func TestEnsembleModes(t *testing.T) {
+	// The stub primary speaks for one second; the secondary never does.
+	// AND suppresses the segment, OR and a primary-heavy weighting keep it.
+	silent := map[string]func() engine.Engine{
+		config.EngineSilero: func() engine.Engine { return &constEngine{} },
+	}
+	base := config.Config{
+		Threshold:            0.5,
+		MinSpeechDurationMs:  20,
+		MinSilenceDurationMs: 20,
+	}
+	newStub := func() engine.Engine { return engine.NewStubEngine() }
+	want := runShadowStream(t, New(base, nil, newStub))
+	if want == 0 {
+		t.Fatal("baseline stream produced no events")
+	}
+
+	tests := []struct {
+		name     string
+		ensemble config.Ensemble
+		events   int
+	}{
+		{"and", config.Ensemble{Engine: config.EngineSilero, Mode: config.EnsembleAnd}, 0},
+		{"or", config.Ensemble{Engine: config.EngineSilero, Mode: config.EnsembleOr}, want},
+		{"weighted", config.Ensemble{Engine: config.EngineSilero, Mode: config.EnsembleWeighted, Weight: 0.9}, want},
+		{"even weights", config.Ensemble{Engine: config.EngineSilero, Mode: config.EnsembleWeighted}, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := base
+			cfg.Ensemble = tt.ensemble
+			srv := New(cfg, nil, newStub, WithEnsembleEngines(silent))
+			if got := runShadowStream(t, srv); got != tt.events {
+				t.Errorf("events = %d, want %d", got, tt.events)
+			}
+		})
+	}
+}
+
+func TestEnsembleStreamConfig(t *testing.T) {
+	srv := New(config.Config{Threshold: 0.5, MinSpeechDurationMs: 20, MinSilenceDurationMs: 20}, nil,
+		func() engine.Engine { return engine.NewStubEngine() },
+		WithEnsembleEngines(map[string]func() engine.Engine{
+			config.EngineStub: func() engine.Engine { return engine.NewStubEngine() },
+		}))
+	client, cleanup := startTestServerWith(t, srv)
+	defer cleanup()
+
+	run := func(first, later string) error {
+		stream, err := client.DetectSpeech(context.Background())
+		if err != nil {
+			t.Fatal(err)
+		}
+		for i, cfgJSON := range []string{first, later} {
+			if err := stream.Send(&napv1.DetectSpeechRequest{
+				PcmData:    make([]byte, 640),
+				Format:     &napv1.AudioFormat{SampleRate: 16000},
+				ConfigJson: cfgJSON,
+			}); err != nil && i == 0 {
+				t.Fatal(err)
+			}
+		}
+		stream.CloseSend()
+		for {
+			if _, err := stream.Recv(); err != nil {
+				return err
+			}
+		}
+	}
+
+	if err := run(`{"ensemble":{"engine":"stub","mode":"or"}}`, ""); err != io.EOF {
+		t.Fatalf("stub ensemble stream failed: %v", err)
+	}
+	if err := run(`{"ensemble":{"engine":"silero"}}`, ""); status.Code(err) != codes.InvalidArgument {
+		t.Errorf("unavailable ensemble engine: got %v, want InvalidArgument", err)
+	}
+	if err := run("", `{"ensemble":{"engine":"stub"}}`); status.Code(err) != codes.InvalidArgument {
+		t.Errorf("ensemble change after audio: got %v, want InvalidArgument", err)
+	}
+}
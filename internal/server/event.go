@@ -0,0 +1,39 @@
+package server
+
+import (
+	"time"
+
+	napv1 "github.com/nupi-ai/nupi/api/nap/v1"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+// timedEvent is a SpeechEvent allocated together with its timestamp. A
+// stream in speech produces an ONGOING event per frame, and one more with
+// emit_probabilities, so every allocation saved per event counts. Events
+// are not reused: the event queue, the watchdog's sender and buffer
+// detection all hold on to sent events.
+type timedEvent struct {
+	evt napv1.SpeechEvent
+	ts  timestamppb.Timestamp
+}
+
+// newEvent returns an event of type typ whose Timestamp is allocated with
+// it, ready for stampEvent.
+func newEvent(typ napv1.SpeechEventType, confidence float32) *napv1.SpeechEvent {
+	te := &timedEvent{}
+	te.evt.Type = typ
+	te.evt.Confidence = confidence
+	te.evt.Timestamp = &te.ts
+	return &te.evt
+}
+
+// stampEvent sets evt's timestamp to t, in place when evt was created by
+// newEvent.
+func stampEvent(evt *napv1.SpeechEvent, t time.Time) {
+	if evt.Timestamp == nil {
+		evt.Timestamp = timestamppb.New(t)
+		return
+	}
+	evt.Timestamp.Seconds = t.Unix()
+	evt.Timestamp.Nanos = int32(t.Nanosecond())
+}
@@ -0,0 +1,91 @@
+package server
+
+import (
+	"context"
+	"io"
+	"log/slog"
+	"testing"
+	"time"
+
+	napv1 "github.com/nupi-ai/nupi/api/nap/v1"
+
+	"github.com/nupi-ai/plugin-vad-local-silero/internal/config"
+	"github.com/nupi-ai/plugin-vad-local-silero/internal/engine"
+)
+
+func TestStampEvent(t *testing.T) {
+	at := time.Date(2026, 10, 16, 14, 5, 9, 123456789, time.UTC)
+	evt := newEvent(napv1.SpeechEventType_SPEECH_EVENT_TYPE_ONGOING, 0.9)
+	ts := evt.Timestamp
+	stampEvent(evt, at)
+	if evt.Timestamp != ts || !evt.Timestamp.AsTime().Equal(at) {
+		t.Errorf("timestamp = %v, want %v set in place", evt.Timestamp.AsTime(), at)
+	}
+	bare := &napv1.SpeechEvent{}
+	stampEvent(bare, at)
+	if !bare.Timestamp.AsTime().Equal(at) {
+		t.Errorf("timestamp = %v, want %v", bare.Timestamp.AsTime(), at)
+	}
+}
+
+// TestBoundaryDetectorAllocs guards the per-frame allocations of a long
+// segment: one for the ONGOING event and its timestamp, none in silence.
+func TestBoundaryDetectorAllocs(t *testing.T) {
+	clock := frameClock{frameSamples: 512, sampleRate: 16000}
+	bd := newBoundaryDetector(config.Config{Threshold: 0.5, MinSpeechDurationMs: 64, MinSilenceDurationMs: 64}, clock)
+	for range 10 {
+		bd.process(engine.Result{Confidence: 0.9})
+	}
+	if !bd.inSpeech {
+		t.Fatal("detector not in speech")
+	}
+	speech := testing.AllocsPerRun(100, func() {
+		evt := bd.process(engine.Result{Confidence: 0.9})[0]
+		stampEvent(evt, time.Now())
+	})
+	if speech != 1 {
+		t.Errorf("%v allocations per speech frame, want 1", speech)
+	}
+
+	for range 10 {
+		bd.process(engine.Result{Confidence: 0.1})
+	}
+	silence := testing.AllocsPerRun(100, func() {
+		bd.process(engine.Result{Confidence: 0.1})
+	})
+	if silence != 0 {
+		t.Errorf("%v allocations per silence frame, want 0", silence)
+	}
+}
+
+func BenchmarkBoundaryDetectorSpeech(b *testing.B) {
+	clock := frameClock{frameSamples: 512, sampleRate: 16000}
+	bd := newBoundaryDetector(config.Config{Threshold: 0.5, MinSpeechDurationMs: 64, MinSilenceDurationMs: 64}, clock)
+	start := time.Now()
+	var frame int64
+	b.ReportAllocs()
+	for b.Loop() {
+		for _, evt := range bd.process(engine.Result{Confidence: 0.9}) {
+			stampEvent(evt, start.Add(clock.offset(frame)))
+		}
+		frame++
+	}
+}
+
+// BenchmarkDetectSpeechInBuffer runs 10 s of audio, half of it speech, with
+// probability events, through the whole stream pipeline.
+func BenchmarkDetectSpeechInBuffer(b *testing.B) {
+	srv := New(config.Config{Threshold: 0.5, MinSpeechDurationMs: 100, MinSilenceDurationMs: 100},
+		slog.New(slog.NewTextHandler(io.Discard, nil)), func() engine.Engine { return engine.NewStubEngine() })
+	req := &napv1.DetectSpeechRequest{
+		Format:     &napv1.AudioFormat{SampleRate: 16000},
+		PcmData:    make([]byte, 500*640),
+		ConfigJson: `{"emit_probabilities": true}`,
+	}
+	b.ReportAllocs()
+	for b.Loop() {
+		if _, err := srv.DetectSpeechInBuffer(context.Background(), req); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
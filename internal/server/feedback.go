@@ -0,0 +1,225 @@
+package server
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"log/slog"
+	"math"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/nupi-ai/plugin-vad-local-silero/internal/config"
+	"github.com/nupi-ai/plugin-vad-local-silero/internal/metrics"
+)
+
+// Feedback kinds: a reported segment held no usable speech, or speech was
+// missed.
+const (
+	FeedbackFalsePositive = "false_positive"
+	FeedbackFalseNegative = "false_negative"
+)
+
+// variantUnknown labels feedback that cannot be attributed to a variant.
+const variantUnknown = "unknown"
+
+// Feedback is a downstream consumer's verdict on a stream's segmentation,
+// e.g. an ASR rejecting a segment as empty. Start and End locate the segment
+// in the stream's audio and are only logged.
+type Feedback struct {
+	SessionID string
+	StreamID  string
+	Kind      string
+	Start     time.Duration
+	End       time.Duration
+}
+
+// FeedbackResult is the tuning state after a report: the variant it was
+// attributed to (empty if none) and that variant's threshold offset.
+type FeedbackResult struct {
+	Variant         string
+	ThresholdOffset float64
+	Adjusted        bool
+}
+
+// WithFeedbackOffsets seeds the per-variant threshold offsets, typically
+// from ReadFeedbackState.
+func WithFeedbackOffsets(offsets map[string]float64) Option {
+	return func(s *Server) {
+		s.feedbackOffsets = offsets
+	}
+}
+
+// ReadFeedbackState loads the offsets saved in a feedback.state_file. A
+// missing file is an empty state.
+func ReadFeedbackState(path string) (map[string]float64, error) {
+	data, err := os.ReadFile(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var offsets map[string]float64
+	if err := json.Unmarshal(data, &offsets); err != nil {
+		return nil, fmt.Errorf("decode feedback state %s: %w", path, err)
+	}
+	return offsets, nil
+}
+
+// feedbackTuner aggregates feedback reports and, when tuning is enabled,
+// keeps each variant's threshold offset.
+type feedbackTuner struct {
+	cfg     config.FeedbackTuning
+	log     *slog.Logger
+	reports map[string]map[string]*metrics.Counter // variant, kind
+	adjusts map[string]*metrics.Counter
+
+	mu      sync.Mutex
+	offsets map[string]float64
+}
+
+func newFeedbackTuner(cfg config.FeedbackTuning, offsets map[string]float64, reg *metrics.Registry, logger *slog.Logger) *feedbackTuner {
+	t := &feedbackTuner{
+		cfg:     cfg,
+		log:     logger,
+		reports: make(map[string]map[string]*metrics.Counter),
+		adjusts: make(map[string]*metrics.Counter),
+		offsets: make(map[string]float64),
+	}
+	for _, variant := range []string{VariantBaseline, VariantCandidate, variantUnknown} {
+		t.reports[variant] = map[string]*metrics.Counter{
+			FeedbackFalsePositive: reg.Counter("vad_feedback_reports_total", "variant", variant, "kind", FeedbackFalsePositive),
+			FeedbackFalseNegative: reg.Counter("vad_feedback_reports_total", "variant", variant, "kind", FeedbackFalseNegative),
+		}
+	}
+	for _, variant := range []string{VariantBaseline, VariantCandidate} {
+		t.adjusts[variant] = reg.Counter("vad_feedback_threshold_adjustments_total", "variant", variant)
+		if cfg.Enabled() {
+			// Saved offsets may predate a lower max_offset.
+			limit := cfg.EffectiveMaxOffset()
+			if off := offsets[variant]; !math.IsNaN(off) {
+				t.offsets[variant] = max(-limit, min(limit, off))
+			}
+		}
+	}
+	return t
+}
+
+// report records fb against variant ("" when unknown) and adjusts the
+// variant's offset: a false positive raises its threshold, a false negative
+// lowers it.
+func (t *feedbackTuner) report(variant string, fb Feedback) FeedbackResult {
+	label := variant
+	if label == "" {
+		label = variantUnknown
+	}
+	if c := t.reports[label][fb.Kind]; c != nil {
+		c.Inc()
+	}
+	res := FeedbackResult{Variant: variant}
+	if variant == "" || !t.cfg.Enabled() {
+		res.ThresholdOffset = t.offset(variant)
+		return res
+	}
+
+	delta := t.cfg.Step
+	if fb.Kind == FeedbackFalseNegative {
+		delta = -delta
+	}
+	limit := t.cfg.EffectiveMaxOffset()
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	old := t.offsets[variant]
+	res.ThresholdOffset = max(-limit, min(limit, old+delta))
+	if res.ThresholdOffset == old {
+		return res
+	}
+	res.Adjusted = true
+	t.offsets[variant] = res.ThresholdOffset
+	t.adjusts[variant].Inc()
+	t.log.Info("feedback adjusted threshold",
+		"variant", variant, "kind", fb.Kind, "threshold_offset", res.ThresholdOffset)
+	if t.cfg.StateFile != "" {
+		if err := t.save(); err != nil {
+			t.log.Warn("failed to save feedback state", "path", t.cfg.StateFile, "error", err)
+		}
+	}
+	return res
+}
+
+// offset returns variant's current threshold offset.
+func (t *feedbackTuner) offset(variant string) float64 {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.offsets[variant]
+}
+
+// tune returns cfg with variant's offset applied to its thresholds.
+func (t *feedbackTuner) tune(variant string, cfg config.Config) config.Config {
+	off := t.offset(variant)
+	if off == 0 {
+		return cfg
+	}
+	cfg.Threshold = max(0, min(1, cfg.Threshold+off))
+	if cfg.NegThreshold > 0 {
+		cfg.NegThreshold = min(cfg.Threshold, max(0.01, cfg.NegThreshold+off))
+	}
+	return cfg
+}
+
+// save writes the offsets to the state file, replacing it atomically. The
+// caller holds t.mu.
+func (t *feedbackTuner) save() error {
+	data, err := json.Marshal(t.offsets)
+	if err != nil {
+		return err
+	}
+	tmp := t.cfg.StateFile + ".tmp"
+	if err := os.WriteFile(tmp, data, 0o644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, t.cfg.StateFile)
+}
+
+// ReportFeedback records a downstream verdict on a stream's segmentation.
+// The stream's variant is taken from the recent-stream history, or derived
+// from the session ID as at stream start; feedback that matches neither is
+// counted under variant "unknown" and never adjusts a threshold. fb.Kind
+// must be FeedbackFalsePositive or FeedbackFalseNegative.
+func (s *Server) ReportFeedback(fb Feedback) FeedbackResult {
+	variant := s.feedbackVariant(fb.SessionID, fb.StreamID)
+	res := s.feedback.report(variant, fb)
+	s.log.Info("segment feedback",
+		"session_id", fb.SessionID,
+		"stream_id", fb.StreamID,
+		"variant", variant,
+		"kind", fb.Kind,
+		"start_ms", fb.Start.Milliseconds(),
+		"end_ms", fb.End.Milliseconds(),
+	)
+	return res
+}
+
+// feedbackVariant resolves the variant a stream ran with.
+func (s *Server) feedbackVariant(sessionID, streamID string) string {
+	if sessionID != "" || streamID != "" {
+		for _, sum := range s.recent.list(sessionID, 0) {
+			if streamID == "" || sum.StreamID == streamID {
+				return sum.Variant
+			}
+		}
+	}
+	switch {
+	case s.cfg.CandidatePercent <= 0:
+		return VariantBaseline
+	case s.cfg.CandidatePercent >= 100:
+		return VariantCandidate
+	case sessionID != "":
+		return chooseVariant(sessionID, s.cfg.CandidatePercent)
+	}
+	return ""
+}
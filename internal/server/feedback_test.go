@@ -0,0 +1,91 @@
+package server
+
+import (
+	"context"
+	"math"
+	"path/filepath"
+	"testing"
+
+	napv1 "github.com/nupi-ai/nupi/api/nap/v1"
+
+	"github.com/nupi-ai/plugin-vad-local-silero/internal/config"
+	"github.com/nupi-ai/plugin-vad-local-silero/internal/engine"
+)
+
+func TestReportFeedbackTunesVariant(t *testing.T) {
+	state := filepath.Join(t.TempDir(), "tuning.json")
+	cfg := config.Config{
+		Threshold: 0.5, NegThreshold: 0.4, MinSpeechDurationMs: 20, MinSilenceDurationMs: 20, RecentStreams: 10,
+		Feedback: config.FeedbackTuning{Step: 0.1, MaxOffset: 0.3, StateFile: state},
+	}
+	srv := New(cfg, nil, func() engine.Engine { return engine.NewStubEngine() })
+
+	fp := Feedback{SessionID: "sess-1", Kind: FeedbackFalsePositive}
+	for i, want := range []float64{0.1, 0.2, 0.3, 0.3} {
+		res := srv.ReportFeedback(fp)
+		if res.Variant != VariantBaseline || math.Abs(res.ThresholdOffset-want) > 1e-9 || res.Adjusted != (i < 3) {
+			t.Errorf("report %d: %+v, want baseline offset %.1f", i, res, want)
+		}
+	}
+
+	// New streams of the variant run with the shifted thresholds.
+	if _, err := srv.DetectSpeechInBuffer(context.Background(), &napv1.DetectSpeechRequest{
+		SessionId: "sess-1",
+		StreamId:  "stream-1",
+		Format:    &napv1.AudioFormat{SampleRate: 16000},
+		PcmData:   make([]byte, 149*640),
+	}); err != nil {
+		t.Fatal(err)
+	}
+	sums := srv.RecentStreams("sess-1", 1)
+	if len(sums) != 1 || math.Abs(sums[0].Threshold-0.8) > 1e-9 || math.Abs(sums[0].NegThreshold-0.7) > 1e-9 {
+		t.Fatalf("stream summary = %+v, want threshold 0.8, neg_threshold 0.7", sums)
+	}
+
+	res := srv.ReportFeedback(Feedback{StreamID: "stream-1", Kind: FeedbackFalseNegative})
+	if res.Variant != VariantBaseline || math.Abs(res.ThresholdOffset-0.2) > 1e-9 || !res.Adjusted {
+		t.Errorf("false negative: %+v, want baseline offset 0.2", res)
+	}
+	key := `vad_feedback_reports_total{kind="false_positive",variant="baseline"}`
+	if got := srv.Metrics().Snapshot()[key]; got != 4 {
+		t.Errorf("%s = %d, want 4", key, got)
+	}
+
+	// The offsets survive a restart through the state file.
+	offsets, err := ReadFeedbackState(state)
+	if err != nil {
+		t.Fatal(err)
+	}
+	restarted := New(cfg, nil, func() engine.Engine { return engine.NewStubEngine() }, WithFeedbackOffsets(offsets))
+	if got := restarted.feedback.offset(VariantBaseline); math.Abs(got-0.2) > 1e-9 {
+		t.Errorf("restored offset = %f, want 0.2", got)
+	}
+	if offsets, err := ReadFeedbackState(filepath.Join(t.TempDir(), "missing.json")); err != nil || offsets != nil {
+		t.Errorf("missing state file: %v, %v, want empty state", offsets, err)
+	}
+}
+
+func TestReportFeedbackUnattributed(t *testing.T) {
+	cfg := config.Config{Threshold: 0.5, CandidatePercent: 50, Feedback: config.FeedbackTuning{Step: 0.1}}
+	srv := New(cfg, nil, func() engine.Engine { return engine.NewStubEngine() })
+
+	res := srv.ReportFeedback(Feedback{Kind: FeedbackFalsePositive})
+	if res.Variant != "" || res.Adjusted {
+		t.Errorf("anonymous report during a rollout: %+v, want unattributed", res)
+	}
+	key := `vad_feedback_reports_total{kind="false_positive",variant="unknown"}`
+	if got := srv.Metrics().Snapshot()[key]; got != 1 {
+		t.Errorf("%s = %d, want 1", key, got)
+	}
+
+	// A session ID is bucketed as at stream start.
+	if res := srv.ReportFeedback(Feedback{SessionID: "sess-1", Kind: FeedbackFalsePositive}); res.Variant != chooseVariant("sess-1", 50) || !res.Adjusted {
+		t.Errorf("session report: %+v", res)
+	}
+
+	// Without feedback.step reports are only counted.
+	counting := New(config.Config{Threshold: 0.5}, nil, func() engine.Engine { return engine.NewStubEngine() })
+	if res := counting.ReportFeedback(Feedback{SessionID: "s", Kind: FeedbackFalseNegative}); res.Adjusted || res.ThresholdOffset != 0 {
+		t.Errorf("tuning disabled: %+v", res)
+	}
+}
@@ -0,0 +1,80 @@
+package server
+
+import (
+	"time"
+
+	napv1 "github.com/nupi-ai/nupi/api/nap/v1"
+)
+
+// heartbeat schedules a stream's heartbeat events: one is due once no event
+// was sent for the interval. The timer fires at most once per interval, and
+// sending an event only records its time, so a busy stream pays nothing for
+// it.
+type heartbeat struct {
+	interval time.Duration
+	last     time.Time // when the stream last sent an event, or opened
+	timer    *time.Timer
+}
+
+func newHeartbeat(now time.Time) *heartbeat {
+	return &heartbeat{last: now}
+}
+
+// setInterval applies heartbeat_interval_ms; zero disables heartbeats.
+func (h *heartbeat) setInterval(ms int, now time.Time) {
+	interval := time.Duration(ms) * time.Millisecond
+	if interval == h.interval {
+		return
+	}
+	h.interval = interval
+	if h.timer != nil {
+		h.timer.Stop()
+		h.timer = nil
+	}
+	if interval > 0 {
+		h.timer = time.NewTimer(h.wait(now))
+	}
+}
+
+// C returns the channel the timer fires on, nil when heartbeats are off.
+func (h *heartbeat) C() <-chan time.Time {
+	if h.timer == nil {
+		return nil
+	}
+	return h.timer.C
+}
+
+// sent records an event sent at now, also while heartbeats are off, so
+// turning them on mid-stream counts from the last event.
+func (h *heartbeat) sent(now time.Time) {
+	h.last = now
+}
+
+// due reports, after the timer fired, whether a heartbeat should be sent
+// now; either way the timer is re-armed for the next one.
+func (h *heartbeat) due(now time.Time) bool {
+	due := now.Sub(h.last) >= h.interval
+	if due {
+		h.last = now
+	}
+	h.timer.Reset(h.wait(now))
+	return due
+}
+
+// wait returns how long after now the next heartbeat is due.
+func (h *heartbeat) wait(now time.Time) time.Duration {
+	return max(h.last.Add(h.interval).Sub(now), 0)
+}
+
+func (h *heartbeat) stop() {
+	if h.timer != nil {
+		h.timer.Stop()
+	}
+}
+
+// newHeartbeatEvent returns a heartbeat event at audio time ts.
+func newHeartbeatEvent(ts time.Time) *napv1.SpeechEvent {
+	evt := newEvent(HeartbeatEventType, HeartbeatConfidence)
+	stampEvent(evt, ts)
+	return evt
+}
@@ -0,0 +1,143 @@
+package server
+
+import (
+	"context"
+	"io"
+	"strconv"
+	"testing"
+	"time"
+
+	napv1 "github.com/nupi-ai/nupi/api/nap/v1"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	"github.com/nupi-ai/plugin-vad-local-silero/internal/config"
+	"github.com/nupi-ai/plugin-vad-local-silero/internal/engine"
+)
+
+func TestHeartbeats(t *testing.T) {
+	cfg := config.Config{
+		Threshold:            0.5,
+		MinSpeechDurationMs:  20,
+		MinSilenceDurationMs: 20,
+		HeartbeatIntervalMs:  100,
+	}
+	srv := New(cfg, nil, func() engine.Engine { return &constEngine{} })
+	client, cleanup := startTestServerWith(t, srv)
+	defer cleanup()
+
+	open := func(configJSON string) napv1.VoiceActivityDetectionService_DetectSpeechClient {
+		stream, err := client.DetectSpeech(context.Background())
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := stream.Send(&napv1.DetectSpeechRequest{
+			PcmData:    make([]byte, 640),
+			Format:     &napv1.AudioFormat{SampleRate: 16000},
+			ConfigJson: configJSON,
+		}); err != nil {
+			t.Fatal(err)
+		}
+		return stream
+	}
+
+	// A silent stream gets heartbeats at the audio time reached.
+	stream := open("")
+	md, err := stream.Header()
+	if err != nil {
+		t.Fatal(err)
+	}
+	epoch, ok := ParseAudioEpoch(md)
+	if !ok {
+		t.Fatal("no audio epoch header")
+	}
+	for i := 0; i < 2; i++ {
+		evt, err := stream.Recv()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !IsHeartbeat(evt) {
+			t.Fatalf("event %d = %v, want a heartbeat", i, evt)
+		}
+		if at := AudioOffset(evt, epoch); at != 20*time.Millisecond {
+			t.Errorf("heartbeat %d at %v, want 20ms", i, at)
+		}
+	}
+	stream.CloseSend()
+	received := 2
+	for {
+		evt, err := stream.Recv()
+		if err == io.EOF {
+			break
+		} else if err != nil {
+			t.Fatal(err)
+		}
+		if !IsHeartbeat(evt) {
+			t.Fatalf("event = %v, want a heartbeat", evt)
+		}
+		received++
+	}
+	if got := stream.Trailer().Get(EventCountTrailer); len(got) != 1 || got[0] != strconv.Itoa(received) {
+		t.Errorf("event count trailer = %v, want %d", got, received)
+	}
+	if got := srv.Metrics().Snapshot()["vad_heartbeats_total"]; got != int64(received) {
+		t.Errorf("vad_heartbeats_total = %d, want %d", got, received)
+	}
+
+	// A stream can turn them off.
+	stream = open(`{"heartbeat_interval_ms": 0}`)
+	time.Sleep(300 * time.Millisecond)
+	stream.CloseSend()
+	if evt, err := stream.Recv(); err != io.EOF {
+		t.Errorf("stream without heartbeats: got %v, %v; want EOF", evt, err)
+	}
+
+	stream = open(`{"heartbeat_interval_ms": 50}`)
+	if _, err := stream.Recv(); status.Code(err) != codes.InvalidArgument {
+		t.Errorf("heartbeat_interval_ms 50: got %v, want InvalidArgument", err)
+	}
+}
+
+func TestHeartbeatEnabledMidStream(t *testing.T) {
+	cfg := config.Config{Threshold: 0.5, MinSpeechDurationMs: 20, MinSilenceDurationMs: 20}
+	client, cleanup := startTestServerWith(t, New(cfg, nil, func() engine.Engine { return &constEngine{speech: true} }))
+	defer cleanup()
+
+	stream, err := client.DetectSpeech(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	// The stream opens well before its first event, with heartbeats off.
+	if err := stream.Send(&napv1.DetectSpeechRequest{Format: &napv1.AudioFormat{SampleRate: 16000}}); err != nil {
+		t.Fatal(err)
+	}
+	time.Sleep(300 * time.Millisecond)
+	for range 2 {
+		if err := stream.Send(&napv1.DetectSpeechRequest{Format: &napv1.AudioFormat{SampleRate: 16000}, PcmData: make([]byte, 640)}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if evt, err := stream.Recv(); err != nil || evt.GetType() != napv1.SpeechEventType_SPEECH_EVENT_TYPE_START {
+		t.Fatalf("got %v, %v; want START", evt, err)
+	}
+
+	// Turned on right after START, the first heartbeat is due an interval
+	// after it, not at once for the time since the stream opened.
+	enabled := time.Now()
+	if err := stream.Send(&napv1.DetectSpeechRequest{ConfigJson: `{"heartbeat_interval_ms": 200}`}); err != nil {
+		t.Fatal(err)
+	}
+	for {
+		evt, err := stream.Recv()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if IsHeartbeat(evt) {
+			break
+		}
+	}
+	if elapsed := time.Since(enabled); elapsed < 150*time.Millisecond {
+		t.Errorf("first heartbeat %v after enabling, want about 200ms", elapsed)
+	}
+	stream.CloseSend()
+}
@@ -0,0 +1,137 @@
+package server
+
+import (
+	"context"
+	"strconv"
+	"strings"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/encoding/protowire"
+	"google.golang.org/protobuf/proto"
+
+	napv1 "github.com/nupi-ai/nupi/api/nap/v1"
+
+	"github.com/nupi-ai/plugin-vad-local-silero/internal/metrics"
+)
+
+// Kinds of ignored request data, used as the "kind" label of
+// vad_ignored_fields_total.
+const (
+	ignoredProtoField = "proto_field"
+	ignoredMetadata   = "metadata"
+)
+
+// ignoredFieldMetrics counts request data the adapter drops. A core that
+// speaks a newer protocol revision shows up here before it shows up as a
+// behavior difference.
+type ignoredFieldMetrics struct {
+	protoFields *metrics.Counter
+	metadata    *metrics.Counter
+}
+
+func newIgnoredFieldMetrics(reg *metrics.Registry) *ignoredFieldMetrics {
+	return &ignoredFieldMetrics{
+		protoFields: reg.Counter("vad_ignored_fields_total", "kind", ignoredProtoField),
+		metadata:    reg.Counter("vad_ignored_fields_total", "kind", ignoredMetadata),
+	}
+}
+
+// ignoredFieldTracker reports each ignored field or metadata key once per
+// stream: the counter and the debug log show which streams carry unexpected
+// data without growing with the number of messages.
+type ignoredFieldTracker struct {
+	s    *Server
+	seen map[string]bool
+}
+
+func newIgnoredFieldTracker(s *Server) *ignoredFieldTracker {
+	return &ignoredFieldTracker{s: s, seen: make(map[string]bool)}
+}
+
+// first reports whether key has not been seen on this stream yet.
+func (t *ignoredFieldTracker) first(key string) bool {
+	if t.seen[key] {
+		return false
+	}
+	t.seen[key] = true
+	return true
+}
+
+// metadata reports incoming gRPC metadata keys the adapter does not use.
+// Transport-level keys set by every gRPC client are skipped.
+func (t *ignoredFieldTracker) metadata(ctx context.Context, sessionID, streamID string) {
+	md, ok := metadata.FromIncomingContext(ctx)
+	if !ok {
+		return
+	}
+	for key := range md {
+		if transportMetadataKey(key) || !t.first("md:"+key) {
+			continue
+		}
+		t.s.ignoredMetrics.metadata.Inc()
+		t.s.log.Debug("ignored request metadata",
+			"session_id", sessionID,
+			"stream_id", streamID,
+			"key", key,
+		)
+	}
+}
+
+// request reports unknown fields of a DetectSpeechRequest and its format:
+// fields added to the protocol after this adapter was built. In strict_proto
+// mode the first unknown field fails the stream with Unimplemented instead,
+// so a newer core cannot silently lose a feature.
+func (t *ignoredFieldTracker) request(req *napv1.DetectSpeechRequest, sessionID, streamID string) error {
+	if err := t.unknown("DetectSpeechRequest", req, sessionID, streamID); err != nil {
+		return err
+	}
+	if af := req.GetFormat(); af != nil {
+		return t.unknown("AudioFormat", af, sessionID, streamID)
+	}
+	return nil
+}
+
+func (t *ignoredFieldTracker) unknown(message string, m proto.Message, sessionID, streamID string) error {
+	raw := m.ProtoReflect().GetUnknown()
+	for len(raw) > 0 {
+		num, typ, n := protowire.ConsumeField(raw)
+		if n < 0 {
+			return nil
+		}
+		raw = raw[n:]
+		if !t.first(message + ":" + strconv.Itoa(int(num))) {
+			continue
+		}
+		t.s.ignoredMetrics.protoFields.Inc()
+		if t.s.cfg.StrictProto {
+			t.s.log.Warn("stream rejected: unknown request field (strict_proto)",
+				"session_id", sessionID,
+				"stream_id", streamID,
+				"message", message,
+				"field_number", int(num),
+			)
+			return status.Errorf(codes.Unimplemented,
+				"%s field %d is not supported by this adapter version (strict_proto)", message, num)
+		}
+		t.s.log.Debug("ignored unknown request field",
+			"session_id", sessionID,
+			"stream_id", streamID,
+			"message", message,
+			"field_number", int(num),
+			"wire_type", int(typ),
+		)
+	}
+	return nil
+}
+
+// transportMetadataKey reports whether key is set by the gRPC transport
+// rather than by the caller.
+func transportMetadataKey(key string) bool {
+	switch key {
+	case ":authority", "content-type", "user-agent", "te":
+		return true
+	}
+	return strings.HasPrefix(key, "grpc-")
+}
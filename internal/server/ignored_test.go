@@ -0,0 +1,131 @@
+package server
+
+import (
+	"context"
+	"io"
+	"testing"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/encoding/protowire"
+	"google.golang.org/protobuf/proto"
+
+	napv1 "github.com/nupi-ai/nupi/api/nap/v1"
+
+	"github.com/nupi-ai/plugin-vad-local-silero/internal/config"
+	"github.com/nupi-ai/plugin-vad-local-silero/internal/engine"
+)
+
+// withUnknownField returns a copy of req carrying a varint field that the
+// generated code does not know, as a newer core would send it.
+func withUnknownField(t *testing.T, req *napv1.DetectSpeechRequest, num protowire.Number) *napv1.DetectSpeechRequest {
+	t.Helper()
+	raw, err := proto.Marshal(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	raw = protowire.AppendTag(raw, num, protowire.VarintType)
+	raw = protowire.AppendVarint(raw, 1)
+	out := &napv1.DetectSpeechRequest{}
+	if err := proto.Unmarshal(raw, out); err != nil {
+		t.Fatal(err)
+	}
+	return out
+}
+
+func TestDetectSpeechCountsIgnoredFields(t *testing.T) {
+	cfg := config.Config{
+		Threshold:            0.5,
+		MinSpeechDurationMs:  20,
+		MinSilenceDurationMs: 20,
+	}
+	srv := New(cfg, nil, func() engine.Engine { return engine.NewStubEngine() })
+	client, cleanup := startTestServerWith(t, srv)
+	defer cleanup()
+
+	ctx := metadata.AppendToOutgoingContext(context.Background(), "x-core-feature", "1")
+	stream, err := client.DetectSpeech(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	req := withUnknownField(t, &napv1.DetectSpeechRequest{
+		PcmData: make([]byte, 640),
+		Format:  &napv1.AudioFormat{SampleRate: 16000},
+	}, 1000)
+	// The same unknown field on every message is reported once per stream.
+	for i := 0; i < 3; i++ {
+		if err := stream.Send(req); err != nil {
+			t.Fatal(err)
+		}
+	}
+	stream.CloseSend()
+	for {
+		if _, err := stream.Recv(); err == io.EOF {
+			break
+		} else if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	snap := srv.Metrics().Snapshot()
+	if got := snap[`vad_ignored_fields_total{kind="proto_field"}`]; got != 1 {
+		t.Errorf("ignored proto fields = %d, want 1 (snapshot: %v)", got, snap)
+	}
+	if got := snap[`vad_ignored_fields_total{kind="metadata"}`]; got != 1 {
+		t.Errorf("ignored metadata keys = %d, want 1 (snapshot: %v)", got, snap)
+	}
+}
+
+func TestDetectSpeechStrictProtoRejectsUnknownFields(t *testing.T) {
+	cfg := config.Config{
+		Threshold:            0.5,
+		MinSpeechDurationMs:  20,
+		MinSilenceDurationMs: 20,
+		StrictProto:          true,
+	}
+	srv := New(cfg, nil, func() engine.Engine { return engine.NewStubEngine() })
+	client, cleanup := startTestServerWith(t, srv)
+	defer cleanup()
+
+	// Metadata alone is not a protocol mismatch.
+	ctx := metadata.AppendToOutgoingContext(context.Background(), "x-core-feature", "1")
+	stream, err := client.DetectSpeech(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	plain := &napv1.DetectSpeechRequest{
+		PcmData: make([]byte, 640),
+		Format:  &napv1.AudioFormat{SampleRate: 16000},
+	}
+	if err := stream.Send(plain); err != nil {
+		t.Fatal(err)
+	}
+	if err := stream.Send(withUnknownField(t, plain, 1000)); err != nil {
+		t.Fatal(err)
+	}
+	stream.CloseSend()
+	for {
+		_, err = stream.Recv()
+		if err != nil {
+			break
+		}
+	}
+	if status.Code(err) != codes.Unimplemented {
+		t.Fatalf("Recv() error = %v, want Unimplemented", err)
+	}
+	if got := srv.Metrics().Snapshot()[`vad_ignored_fields_total{kind="proto_field"}`]; got != 1 {
+		t.Errorf("ignored proto fields = %d, want 1", got)
+	}
+}
+
+func TestTransportMetadataKey(t *testing.T) {
+	for _, key := range []string{":authority", "content-type", "user-agent", "grpc-accept-encoding"} {
+		if !transportMetadataKey(key) {
+			t.Errorf("transportMetadataKey(%q) = false, want true", key)
+		}
+	}
+	if transportMetadataKey("x-nupi-trace") {
+		t.Error("transportMetadataKey(x-nupi-trace) = true, want false")
+	}
+}
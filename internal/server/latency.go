@@ -0,0 +1,248 @@
+package server
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"google.golang.org/grpc/metadata"
+
+	"github.com/nupi-ai/plugin-vad-local-silero/internal/metrics"
+)
+
+// ClientSentAtKey is the config_json key a client sets on a chunk to measure
+// latency: its send time, RFC 3339 with up to nanoseconds. The key is
+// removed before the rest of config_json is applied, so a message carrying
+// only it is not a config update.
+const ClientSentAtKey = "client_sent_at"
+
+// LatencyTrailer carries a stream's latency distribution, one value per
+// stage that was measured, e.g.
+// "stage=network,count=120,mean_ms=14.2,p95_ms=20,max_ms=31.5". Only
+// streams whose chunks carried ClientSentAtKey have it.
+const LatencyTrailer = "x-nupi-vad-latency"
+
+// Latency stages of a chunk carrying ClientSentAtKey.
+const (
+	// LatencyNetwork is client send to adapter receipt. It includes the
+	// clock offset between client and adapter; negative values count as 0.
+	LatencyNetwork = "network"
+	// LatencyQueue is receipt to the start of processing, while the stream's
+	// earlier chunks were still being processed.
+	LatencyQueue = "queue"
+	// LatencyInference is the engine time spent on the chunk.
+	LatencyInference = "inference"
+	// LatencyEvent is receipt to a speech event caused by the chunk being
+	// sent.
+	LatencyEvent = "event"
+)
+
+// latencyStages lists the stages in the order they happen.
+var latencyStages = [...]string{LatencyNetwork, LatencyQueue, LatencyInference, LatencyEvent}
+
+// latencyBounds are the upper bounds of the latency histogram buckets; the
+// last bucket is unbounded.
+var latencyBounds = [...]time.Duration{
+	1 * time.Millisecond,
+	2 * time.Millisecond,
+	5 * time.Millisecond,
+	10 * time.Millisecond,
+	20 * time.Millisecond,
+	50 * time.Millisecond,
+	100 * time.Millisecond,
+	200 * time.Millisecond,
+	500 * time.Millisecond,
+	time.Second,
+}
+
+const latencyBuckets = len(latencyBounds) + 1
+
+// latencyBucket returns the bucket of d.
+func latencyBucket(d time.Duration) int {
+	for i, b := range latencyBounds {
+		if d <= b {
+			return i
+		}
+	}
+	return len(latencyBounds)
+}
+
+// latencyBucketLabel renders bucket i's range in milliseconds, e.g. "5-10"
+// or "1000+".
+func latencyBucketLabel(i int) string {
+	if i == len(latencyBounds) {
+		return fmt.Sprintf("%d+", latencyBounds[i-1].Milliseconds())
+	}
+	var lo int64
+	if i > 0 {
+		lo = latencyBounds[i-1].Milliseconds()
+	}
+	return fmt.Sprintf("%d-%d", lo, latencyBounds[i].Milliseconds())
+}
+
+// latencyMetrics holds the fleet-wide latency histograms:
+// vad_latency_observations_total{stage,bucket} and the total observed time
+// per stage, vad_latency_us_sum{stage}.
+type latencyMetrics struct {
+	buckets [len(latencyStages)][latencyBuckets]*metrics.Counter
+	sumUs   [len(latencyStages)]*metrics.Counter
+}
+
+func newLatencyMetrics(reg *metrics.Registry) *latencyMetrics {
+	m := &latencyMetrics{}
+	for s, stage := range latencyStages {
+		for b := range m.buckets[s] {
+			m.buckets[s][b] = reg.Counter("vad_latency_observations_total", "stage", stage, "bucket", latencyBucketLabel(b))
+		}
+		m.sumUs[s] = reg.Counter("vad_latency_us_sum", "stage", stage)
+	}
+	return m
+}
+
+// LatencyStats summarizes one stage's latency on a stream. P95 is the upper
+// bound of the histogram bucket holding the 95th percentile, capped at Max.
+type LatencyStats struct {
+	Count int64
+	Mean  time.Duration
+	P95   time.Duration
+	Max   time.Duration
+}
+
+// stageHistogram accumulates one stage on one stream.
+type stageHistogram struct {
+	buckets [latencyBuckets]int64
+	count   int64
+	sum     time.Duration
+	max     time.Duration
+}
+
+// latencyRecorder measures the latency of a stream's chunks that carry
+// ClientSentAtKey, into the stream's histograms and the fleet-wide metrics.
+type latencyRecorder struct {
+	metrics *latencyMetrics
+	stages  [len(latencyStages)]stageHistogram
+}
+
+func newLatencyRecorder(m *latencyMetrics) *latencyRecorder {
+	return &latencyRecorder{metrics: m}
+}
+
+// observe records d for stage index s.
+func (r *latencyRecorder) observe(s int, d time.Duration) {
+	d = max(d, 0)
+	b := r.stages[s].add(d)
+	r.metrics.buckets[s][b].Inc()
+	r.metrics.sumUs[s].Add(d.Microseconds())
+}
+
+func (r *latencyRecorder) network(d time.Duration)   { r.observe(0, d) }
+func (r *latencyRecorder) queue(d time.Duration)     { r.observe(1, d) }
+func (r *latencyRecorder) inference(d time.Duration) { r.observe(2, d) }
+func (r *latencyRecorder) event(d time.Duration)     { r.observe(3, d) }
+
+// stats returns the stream's latency per measured stage, nil if nothing was
+// measured.
+func (r *latencyRecorder) stats() map[string]LatencyStats {
+	var out map[string]LatencyStats
+	for s, h := range r.stages {
+		if h.count == 0 {
+			continue
+		}
+		if out == nil {
+			out = make(map[string]LatencyStats, len(latencyStages))
+		}
+		out[latencyStages[s]] = h.stats()
+	}
+	return out
+}
+
+// add records d, which must not be negative, and returns its bucket.
+func (h *stageHistogram) add(d time.Duration) int {
+	b := latencyBucket(d)
+	h.buckets[b]++
+	h.count++
+	h.sum += d
+	h.max = max(h.max, d)
+	return b
+}
+
+// stats summarizes the histogram; the zero LatencyStats when empty.
+func (h *stageHistogram) stats() LatencyStats {
+	if h.count == 0 {
+		return LatencyStats{}
+	}
+	return LatencyStats{
+		Count: h.count,
+		Mean:  h.sum / time.Duration(h.count),
+		P95:   h.percentile(0.95),
+		Max:   h.max,
+	}
+}
+
+// percentile returns the upper bound of the bucket holding quantile q,
+// capped at the largest observation.
+func (h *stageHistogram) percentile(q float64) time.Duration {
+	rank := int64(q*float64(h.count) + 0.5)
+	var seen int64
+	for b, n := range h.buckets {
+		seen += n
+		if seen >= rank && b < len(latencyBounds) {
+			return min(latencyBounds[b], h.max)
+		}
+	}
+	return h.max
+}
+
+// latencyTrailer returns the LatencyTrailer values for stats, in stage order.
+func latencyTrailer(stats map[string]LatencyStats) metadata.MD {
+	md := metadata.MD{}
+	for _, stage := range latencyStages {
+		st, ok := stats[stage]
+		if !ok {
+			continue
+		}
+		md.Append(LatencyTrailer, fmt.Sprintf("stage=%s,count=%d,mean_ms=%s,p95_ms=%s,max_ms=%s",
+			stage, st.Count, formatMs(st.Mean), formatMs(st.P95), formatMs(st.Max)))
+	}
+	return md
+}
+
+// formatMs renders d in milliseconds with up to one decimal.
+func formatMs(d time.Duration) string {
+	return strings.TrimSuffix(fmt.Sprintf("%.1f", float64(d)/float64(time.Millisecond)), ".0")
+}
+
+// splitClientSentAt removes ClientSentAtKey from configJSON. It returns the
+// send time, zero when the key is absent, and the remaining config_json,
+// empty when the key was all it held.
+func splitClientSentAt(configJSON string) (time.Time, string, error) {
+	if !strings.Contains(configJSON, ClientSentAtKey) {
+		return time.Time{}, configJSON, nil
+	}
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal([]byte(configJSON), &fields); err != nil {
+		return time.Time{}, "", fmt.Errorf("invalid config_json: %w", err)
+	}
+	raw, ok := fields[ClientSentAtKey]
+	if !ok {
+		return time.Time{}, configJSON, nil
+	}
+	var s string
+	if err := json.Unmarshal(raw, &s); err != nil {
+		return time.Time{}, "", fmt.Errorf("%s must be an RFC 3339 timestamp string", ClientSentAtKey)
+	}
+	sentAt, err := time.Parse(time.RFC3339Nano, s)
+	if err != nil {
+		return time.Time{}, "", fmt.Errorf("%s must be an RFC 3339 timestamp: %v", ClientSentAtKey, err)
+	}
+	delete(fields, ClientSentAtKey)
+	if len(fields) == 0 {
+		return sentAt, "", nil
+	}
+	rest, err := json.Marshal(fields)
+	if err != nil {
+		return time.Time{}, "", fmt.Errorf("invalid config_json: %w", err)
+	}
+	return sentAt, string(rest), nil
+}
@@ -0,0 +1,169 @@
+package server
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"strings"
+	"testing"
+	"time"
+
+	napv1 "github.com/nupi-ai/nupi/api/nap/v1"
+
+	"github.com/nupi-ai/plugin-vad-local-silero/internal/config"
+	"github.com/nupi-ai/plugin-vad-local-silero/internal/engine"
+	"github.com/nupi-ai/plugin-vad-local-silero/internal/metrics"
+)
+
+func TestSplitClientSentAt(t *testing.T) {
+	sent := "2026-10-16T14:05:09.123456789Z"
+	tests := []struct {
+		in, rest string
+		sent     bool
+		err      bool
+	}{
+		{in: "", rest: ""},
+		{in: `{"threshold":0.6}`, rest: `{"threshold":0.6}`},
+		{in: `{"client_sent_at":"` + sent + `"}`, rest: "", sent: true},
+		{in: `{"client_sent_at":"` + sent + `","reset":true}`, rest: `{"reset":true}`, sent: true},
+		{in: `{"client_sent_at":1760623509}`, err: true},
+		{in: `{"client_sent_at":"yesterday"}`, err: true},
+		{in: `{"client_sent_at":`, err: true},
+	}
+	for _, tt := range tests {
+		got, rest, err := splitClientSentAt(tt.in)
+		if tt.err {
+			if err == nil {
+				t.Errorf("%s: no error", tt.in)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s: %v", tt.in, err)
+			continue
+		}
+		if rest != tt.rest {
+			t.Errorf("%s: rest = %q, want %q", tt.in, rest, tt.rest)
+		}
+		if want, _ := time.Parse(time.RFC3339Nano, sent); tt.sent != !got.IsZero() || (tt.sent && !got.Equal(want)) {
+			t.Errorf("%s: sent at %v", tt.in, got)
+		}
+	}
+}
+
+func TestLatencyRecorderStats(t *testing.T) {
+	reg := metrics.NewRegistry()
+	r := newLatencyRecorder(newLatencyMetrics(reg))
+	if r.stats() != nil {
+		t.Fatal("stats before any observation are not nil")
+	}
+	// 19 observations of 3 ms and one of 40 ms: the 95th percentile is in
+	// the 2-5 ms bucket.
+	for i := 0; i < 19; i++ {
+		r.network(3 * time.Millisecond)
+	}
+	r.network(40 * time.Millisecond)
+	r.inference(-time.Millisecond) // clock steps count as zero
+
+	stats := r.stats()
+	want := LatencyStats{Count: 20, Mean: 4850 * time.Microsecond, P95: 5 * time.Millisecond, Max: 40 * time.Millisecond}
+	if got := stats[LatencyNetwork]; got != want {
+		t.Errorf("network = %+v, want %+v", got, want)
+	}
+	if got := stats[LatencyInference]; got != (LatencyStats{Count: 1}) {
+		t.Errorf("inference = %+v, want one zero observation", got)
+	}
+	if _, ok := stats[LatencyEvent]; ok {
+		t.Error("event stage reported without observations")
+	}
+
+	snap := reg.Snapshot()
+	if got := snap[`vad_latency_observations_total{bucket="2-5",stage="network"}`]; got != 19 {
+		t.Errorf("2-5 ms network bucket = %d, want 19", got)
+	}
+	if got := snap[`vad_latency_observations_total{bucket="20-50",stage="network"}`]; got != 1 {
+		t.Errorf("20-50 ms network bucket = %d, want 1", got)
+	}
+	if got := snap[`vad_latency_us_sum{stage="network"}`]; got != 97000 {
+		t.Errorf("network sum = %d us, want 97000", got)
+	}
+
+	trailer := latencyTrailer(stats).Get(LatencyTrailer)
+	wantTrailer := []string{
+		"stage=network,count=20,mean_ms=4.8,p95_ms=5,max_ms=40",
+		"stage=inference,count=1,mean_ms=0,p95_ms=0,max_ms=0",
+	}
+	if fmt.Sprint(trailer) != fmt.Sprint(wantTrailer) {
+		t.Errorf("trailer = %q, want %q", trailer, wantTrailer)
+	}
+}
+
+func TestLatencyBucketLabels(t *testing.T) {
+	if got := latencyBucketLabel(0); got != "0-1" {
+		t.Errorf("first bucket = %q", got)
+	}
+	if got := latencyBucketLabel(latencyBuckets - 1); got != "1000+" {
+		t.Errorf("last bucket = %q", got)
+	}
+	if got := latencyBucket(time.Hour); got != latencyBuckets-1 {
+		t.Errorf("bucket of an hour = %d", got)
+	}
+}
+
+func TestDetectSpeechLatencyEcho(t *testing.T) {
+	cfg := config.Config{Threshold: 0.5, MinSpeechDurationMs: 20, MinSilenceDurationMs: 20, RecentStreams: 1}
+	srv := New(cfg, slog.Default(), func() engine.Engine { return engine.NewStubEngine() })
+	client, cleanup := startTestServerWith(t, srv)
+	defer cleanup()
+
+	stream, err := client.DetectSpeech(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	// 70 chunks of one stub frame, every other one carrying a send time
+	// 10 ms in the past.
+	for i := 0; i < 70; i++ {
+		req := &napv1.DetectSpeechRequest{
+			PcmData: make([]byte, 640),
+			Format:  &napv1.AudioFormat{SampleRate: 16000},
+		}
+		if i%2 == 1 {
+			req.ConfigJson = fmt.Sprintf(`{"client_sent_at":%q}`, time.Now().Add(-10*time.Millisecond).Format(time.RFC3339Nano))
+		}
+		if err := stream.Send(req); err != nil {
+			t.Fatal(err)
+		}
+	}
+	stream.CloseSend()
+	for {
+		if _, err := stream.Recv(); err == io.EOF {
+			break
+		} else if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	trailer := stream.Trailer().Get(LatencyTrailer)
+	if len(trailer) != 4 {
+		t.Fatalf("%s = %q, want all four stages", LatencyTrailer, trailer)
+	}
+	for i, prefix := range []string{"stage=network,count=35,", "stage=queue,count=35,", "stage=inference,count=35,", "stage=event,"} {
+		if !strings.HasPrefix(trailer[i], prefix) {
+			t.Errorf("trailer %d = %q, want prefix %q", i, trailer[i], prefix)
+		}
+	}
+
+	recent := srv.RecentStreams("", 0)
+	if len(recent) != 1 {
+		t.Fatalf("%d recent streams, want 1", len(recent))
+	}
+	network := recent[0].Latency[LatencyNetwork]
+	if network.Count != 35 || network.Mean < 10*time.Millisecond {
+		t.Errorf("network latency = %+v, want 35 observations of at least 10 ms", network)
+	}
+	// The send time alone is not a config update.
+	if recent[0].Threshold != 0.5 {
+		t.Errorf("threshold = %v, want 0.5", recent[0].Threshold)
+	}
+}
@@ -0,0 +1,86 @@
+package server
+
+import (
+	"fmt"
+	"math"
+
+	napv1 "github.com/nupi-ai/nupi/api/nap/v1"
+	"google.golang.org/protobuf/encoding/protowire"
+
+	"github.com/nupi-ai/plugin-vad-local-silero/internal/audio"
+	"github.com/nupi-ai/plugin-vad-local-silero/internal/audio/dsp"
+	"github.com/nupi-ai/plugin-vad-local-silero/internal/engine"
+	"github.com/nupi-ai/plugin-vad-local-silero/internal/errs"
+)
+
+// RequestFieldsBudget is the room a DetectSpeechRequest gets for everything
+// besides pcm_data (session and stream IDs, format, config_json) on top of a
+// full-size chunk. A request exceeding it fails in the gRPC transport with
+// ResourceExhausted.
+const RequestFieldsBudget = 64 << 10
+
+// MaxRecvMsgSize returns the gRPC receive limit for chunks of up to
+// maxChunkBytes: the encoded size of a pcm_data field of that length, tag
+// and length prefix included, plus RequestFieldsBudget. It is the one place
+// the transport limit is derived from the application limit, so the two
+// cannot drift apart.
+func MaxRecvMsgSize(maxChunkBytes int) (int, error) {
+	if maxChunkBytes <= 0 {
+		return 0, fmt.Errorf("max chunk size must be positive, got %d", maxChunkBytes)
+	}
+	field := (&napv1.DetectSpeechRequest{}).ProtoReflect().Descriptor().Fields().ByName("pcm_data")
+	if field == nil {
+		return 0, fmt.Errorf("DetectSpeechRequest has no pcm_data field")
+	}
+	size := protowire.SizeTag(field.Number()) + protowire.SizeBytes(maxChunkBytes) + RequestFieldsBudget
+	if size > math.MaxInt32 {
+		return 0, fmt.Errorf("max chunk size %d exceeds the gRPC message limit", maxChunkBytes)
+	}
+	return size, nil
+}
+
+// bufferedPCMBytes returns the PCM a stream holds between chunks: samples
+// its engine and shadow engine buffered short of a frame, the resampler's
+// history and the denoiser's partial hop. The chunk in hand is bounded by
+// max_chunk_bytes instead, as are the pipeline_depth chunks the receiver
+// queues while it is processed.
+func bufferedPCMBytes(eng engine.Engine, resampler *audio.Resampler, denoiser *dsp.Denoiser, shadow *shadowRunner) int64 {
+	samples := engine.BufferedSamples(eng)
+	if resampler != nil {
+		samples += resampler.Buffered()
+	}
+	if denoiser != nil {
+		samples += denoiser.Buffered()
+	}
+	if shadow != nil {
+		samples += shadow.bufferedSamples()
+	}
+	return 2 * int64(samples)
+}
+
+// maxChunkBytes returns the pcm_data limit of the server's config.
+func (s *Server) maxChunkBytes() int {
+	return s.cfg.EffectiveMaxChunkBytes()
+}
+
+// maxBufferedBytes returns the per-stream buffered PCM limit of the
+// server's config.
+func (s *Server) maxBufferedBytes() int {
+	return s.cfg.EffectiveMaxBufferedBytes()
+}
+
+// checkChunk validates a request's PCM as it is received, before it is
+// queued for inference or an engine is created for it: s16le needs an even
+// length, and a chunk may not exceed max_chunk_bytes.
+func (s *Server) checkChunk(req *napv1.DetectSpeechRequest) error {
+	pcm := req.GetPcmData()
+	if len(pcm)%2 != 0 {
+		return errs.New(errs.ErrInvalidPCM,
+			"PCM buffer has odd length %d (s16le requires 2 bytes per sample)", len(pcm))
+	}
+	if len(pcm) > s.maxChunkBytes() {
+		return errs.New(errs.ErrInvalidPCM,
+			"PCM chunk too large: %d bytes (max %d)", len(pcm), s.maxChunkBytes())
+	}
+	return nil
+}
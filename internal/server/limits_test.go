@@ -0,0 +1,146 @@
+package server
+
+import (
+	"context"
+	"log/slog"
+	"strings"
+	"testing"
+
+	napv1 "github.com/nupi-ai/nupi/api/nap/v1"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
+
+	"github.com/nupi-ai/plugin-vad-local-silero/internal/audio"
+	"github.com/nupi-ai/plugin-vad-local-silero/internal/audio/dsp"
+	"github.com/nupi-ai/plugin-vad-local-silero/internal/config"
+	"github.com/nupi-ai/plugin-vad-local-silero/internal/engine"
+)
+
+func TestMaxRecvMsgSize(t *testing.T) {
+	for _, n := range []int{config.MinMaxChunkBytes, config.DefaultMaxChunkBytes, config.MaxMaxChunkBytes} {
+		got, err := MaxRecvMsgSize(n)
+		if err != nil {
+			t.Fatalf("MaxRecvMsgSize(%d): %v", n, err)
+		}
+		// The budget is exactly what is left beside a full chunk.
+		full := proto.Size(&napv1.DetectSpeechRequest{PcmData: make([]byte, n)})
+		if got-full != RequestFieldsBudget {
+			t.Errorf("MaxRecvMsgSize(%d) = %d, want encoded full chunk %d + %d", n, got, full, RequestFieldsBudget)
+		}
+	}
+	for _, n := range []int{0, -1, 1 << 31} {
+		if _, err := MaxRecvMsgSize(n); err == nil {
+			t.Errorf("MaxRecvMsgSize(%d): expected an error", n)
+		}
+	}
+}
+
+func TestDetectSpeechConfiguredMaxChunkBytes(t *testing.T) {
+	client, cleanup := startTestServer(t, config.Config{Threshold: 0.5, MaxChunkBytes: 1024})
+	defer cleanup()
+
+	stream, err := client.DetectSpeech(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, n := range []int{1024, 1026} {
+		if err := stream.Send(&napv1.DetectSpeechRequest{
+			Format:  &napv1.AudioFormat{SampleRate: 16000},
+			PcmData: make([]byte, n),
+		}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	stream.CloseSend()
+	for {
+		_, err = stream.Recv()
+		if err != nil {
+			break
+		}
+	}
+	if status.Code(err) != codes.InvalidArgument || !strings.Contains(err.Error(), "max 1024") {
+		t.Errorf("err = %v, want InvalidArgument naming the 1024-byte limit", err)
+	}
+}
+
+// hoardingEngine buffers all audio and never completes a frame, as a broken
+// engine would.
+type hoardingEngine struct {
+	*engine.StubEngine
+	held int
+}
+
+func (e *hoardingEngine) ProcessChunk(pcm []byte, _ uint32) ([]engine.Result, error) {
+	e.held += len(pcm) / 2
+	return nil, nil
+}
+
+func (e *hoardingEngine) BufferedSamples() int { return e.held }
+
+func TestDetectSpeechMaxBufferedBytes(t *testing.T) {
+	cfg := config.Config{Threshold: 0.5, MaxBufferedBytes: config.MinMaxBufferedBytes, RecentStreams: 1}
+	srv := New(cfg, slog.Default(), func() engine.Engine {
+		return &hoardingEngine{StubEngine: engine.NewStubEngine()}
+	})
+	client, cleanup := startTestServerWith(t, srv)
+	defer cleanup()
+
+	stream, err := client.DetectSpeech(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	// The fifth 4 KiB chunk takes the stream past the 16 KiB limit.
+	for i := 0; i < 5; i++ {
+		if err := stream.Send(&napv1.DetectSpeechRequest{
+			Format:  &napv1.AudioFormat{SampleRate: 16000},
+			PcmData: make([]byte, 4096),
+		}); err != nil {
+			break // the server may already have ended the stream
+		}
+	}
+	stream.CloseSend()
+	for err == nil {
+		_, err = stream.Recv()
+	}
+	if reason, _, ok := TerminationReason(err); !ok || reason != ReasonBufferLimit {
+		t.Fatalf("err = %v, want a %s termination", err, ReasonBufferLimit)
+	}
+	if status.Code(err) != codes.ResourceExhausted {
+		t.Errorf("code = %v, want ResourceExhausted", status.Code(err))
+	}
+	key := `vad_buffer_limit_exceeded_total{variant="baseline"}`
+	if got := srv.Metrics().Snapshot()[key]; got != 1 {
+		t.Errorf("%s = %d, want 1", key, got)
+	}
+	if recent := srv.RecentStreams("", 0); len(recent) != 1 || recent[0].PeakBufferedBytes != 5*4096 {
+		t.Errorf("recent streams = %+v, want one with a 20480-byte peak", recent)
+	}
+}
+
+func TestBufferedPCMBytes(t *testing.T) {
+	eng := engine.NewStubEngine()
+	if _, err := eng.ProcessChunk(make([]byte, 700), engine.ExpectedSampleRate); err != nil {
+		t.Fatal(err)
+	}
+	// 350 samples: one 320-sample frame consumed, 30 held.
+	if got := bufferedPCMBytes(eng, nil, nil, nil); got != 60 {
+		t.Errorf("engine only: %d bytes, want 60", got)
+	}
+	r, err := audio.NewResampler(48000, engine.ExpectedSampleRate)
+	if err != nil {
+		t.Fatal(err)
+	}
+	r.Process(make([]byte, 960))
+	if got, want := bufferedPCMBytes(eng, r, nil, nil), int64(60+2*r.Buffered()); got != want || r.Buffered() == 0 {
+		t.Errorf("with resampler: %d bytes, want %d (resampler holds %d samples)", got, want, r.Buffered())
+	}
+	d, err := dsp.NewDenoiser(engine.ExpectedSampleRate)
+	if err != nil {
+		t.Fatal(err)
+	}
+	d.Process(make([]byte, 200)) // 100 samples, short of a 256-sample hop
+	if got, want := bufferedPCMBytes(eng, r, d, nil), int64(60+2*r.Buffered()+200); got != want {
+		t.Errorf("with denoiser: %d bytes, want %d", got, want)
+	}
+}
@@ -0,0 +1,124 @@
+package server
+
+import (
+	"fmt"
+	"time"
+
+	"google.golang.org/grpc/metadata"
+
+	napv1 "github.com/nupi-ai/nupi/api/nap/v1"
+)
+
+// InstanceIDHeader is the response header carrying the configured
+// instance_id. SpeechEvent has no field for it, so it is attached once per
+// stream; every event on the stream was produced by that instance.
+const InstanceIDHeader = "x-nupi-vad-instance-id"
+
+// AudioEpochHeader is the response header carrying the timestamp of audio
+// offset zero (the stream's first PCM sample), RFC 3339 with nanoseconds in
+// UTC. Every event timestamp is this epoch plus the event's audio offset, so
+// clients recover the exact offset by subtraction, independent of any skew
+// between their clock and the server's. It is set when the first PCM chunk
+// arrives, before any event is sent.
+const AudioEpochHeader = "x-nupi-vad-audio-epoch"
+
+// FrameGeometryHeader is the response header carrying the stream's frame
+// geometry as "samples=<n>,sample_rate=<n>": the engine's stride in samples
+// and the rate it runs at. It is sent with AudioEpochHeader. SpeechEvent has
+// no field for the frame an event was produced on; FrameGeometry.Index
+// recovers it from the event's audio offset.
+const FrameGeometryHeader = "x-nupi-vad-frame-geometry"
+
+// EventCountTrailer is the trailer carrying the number of SpeechEvents sent
+// on the stream. Clients that number events in receive order (1, 2, ...) as
+// they forward them, e.g. onto a message bus, can check the last number
+// against it.
+//
+// It stands in for a per-event sequence number, which SpeechEvent has no
+// field for (only type, confidence and timestamp), and the adapter does not
+// extend the NAP proto. It is weaker: loss is only detectable once the
+// stream has ended, and reordering after the client is not detectable from
+// it at all, so consumers behind a bus need the client's own numbering.
+const EventCountTrailer = "x-nupi-vad-event-count"
+
+// ProbabilityEventType marks the per-frame probability samples streamed with
+// emit_probabilities: NAP has no event type for them, so they use the
+// unspecified type, with the frame's raw speech probability as Confidence and
+// its audio time as Timestamp. Each sample is sent before the boundary events
+// of the same frame.
+const ProbabilityEventType = napv1.SpeechEventType_SPEECH_EVENT_TYPE_UNSPECIFIED
+
+// HeartbeatEventType and HeartbeatConfidence mark the heartbeat events sent
+// with heartbeat_interval_ms once a stream sent no event for the interval.
+// They share the unspecified type with probability samples, told apart by a
+// Confidence no probability has. Their Timestamp is the audio time reached;
+// heartbeats start with the stream's first audio.
+const (
+	HeartbeatEventType  = napv1.SpeechEventType_SPEECH_EVENT_TYPE_UNSPECIFIED
+	HeartbeatConfidence = -1
+)
+
+// IsHeartbeat reports whether evt is a heartbeat event.
+func IsHeartbeat(evt *napv1.SpeechEvent) bool {
+	return evt.GetType() == HeartbeatEventType && evt.GetConfidence() == HeartbeatConfidence
+}
+
+// ParseAudioEpoch returns the audio epoch from a stream's response header.
+func ParseAudioEpoch(md metadata.MD) (time.Time, bool) {
+	v := md.Get(AudioEpochHeader)
+	if len(v) == 0 {
+		return time.Time{}, false
+	}
+	epoch, err := time.Parse(time.RFC3339Nano, v[0])
+	if err != nil {
+		return time.Time{}, false
+	}
+	return epoch, true
+}
+
+// FrameGeometry is the frame stride of a stream; see FrameGeometryHeader.
+type FrameGeometry struct {
+	Samples    int64
+	SampleRate int64
+}
+
+func (g FrameGeometry) String() string {
+	return fmt.Sprintf("samples=%d,sample_rate=%d", g.Samples, g.SampleRate)
+}
+
+// Index returns the index of the frame starting nearest to offset. Event
+// offsets are exact frame starts, except for a SPEECH_START moved by
+// preroll_ms, which maps to the nearest frame.
+func (g FrameGeometry) Index(offset time.Duration) int64 {
+	unit := g.Samples * int64(time.Second) // frame duration in ns, times SampleRate
+	return (int64(offset)*g.SampleRate + unit/2) / unit
+}
+
+// ParseFrameGeometry returns the frame geometry from a stream's response
+// header.
+func ParseFrameGeometry(md metadata.MD) (FrameGeometry, bool) {
+	v := md.Get(FrameGeometryHeader)
+	if len(v) == 0 {
+		return FrameGeometry{}, false
+	}
+	var g FrameGeometry
+	if _, err := fmt.Sscanf(v[0], "samples=%d,sample_rate=%d", &g.Samples, &g.SampleRate); err != nil || g.Samples <= 0 || g.SampleRate <= 0 {
+		return FrameGeometry{}, false
+	}
+	return g, true
+}
+
+// AudioOffset returns the position of evt in the stream's audio, given the
+// stream's audio epoch.
+func AudioOffset(evt *napv1.SpeechEvent, epoch time.Time) time.Duration {
+	return evt.GetTimestamp().AsTime().Sub(epoch)
+}
+
+// streamHeader returns the response header metadata sent at stream start, or
+// nil when there is nothing to send.
+func (s *Server) streamHeader() metadata.MD {
+	if s.cfg.InstanceID == "" {
+		return nil
+	}
+	return metadata.Pairs(InstanceIDHeader, s.cfg.InstanceID)
+}
@@ -0,0 +1,166 @@
+package server
+
+import (
+	"context"
+	"io"
+	"strconv"
+	"testing"
+	"time"
+
+	"google.golang.org/grpc/metadata"
+
+	napv1 "github.com/nupi-ai/nupi/api/nap/v1"
+
+	"github.com/nupi-ai/plugin-vad-local-silero/internal/config"
+)
+
+func TestDetectSpeechInstanceIDHeader(t *testing.T) {
+	tests := []struct {
+		name       string
+		instanceID string
+	}{
+		{"configured", "eu-west-1/vad-3"},
+		{"unset", ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := config.Config{
+				InstanceID:           tt.instanceID,
+				Threshold:            0.5,
+				MinSpeechDurationMs:  20,
+				MinSilenceDurationMs: 20,
+			}
+			client, cleanup := startTestServer(t, cfg)
+			defer cleanup()
+
+			stream, err := client.DetectSpeech(context.Background())
+			if err != nil {
+				t.Fatal(err)
+			}
+			// A short silent stream produces no events; the header must still
+			// be delivered.
+			if err := stream.Send(&napv1.DetectSpeechRequest{
+				Format:  &napv1.AudioFormat{Encoding: "pcm_s16le", SampleRate: 16000, Channels: 1, BitDepth: 16},
+				PcmData: make([]byte, 640),
+			}); err != nil {
+				t.Fatal(err)
+			}
+			stream.CloseSend()
+			if _, err := stream.Recv(); err != io.EOF {
+				t.Fatalf("Recv() = %v, want io.EOF", err)
+			}
+
+			md, err := stream.Header()
+			if err != nil {
+				t.Fatal(err)
+			}
+			got := md.Get(InstanceIDHeader)
+			if tt.instanceID == "" {
+				if len(got) != 0 {
+					t.Errorf("header %s = %v, want absent", InstanceIDHeader, got)
+				}
+				return
+			}
+			if len(got) != 1 || got[0] != tt.instanceID {
+				t.Errorf("header %s = %v, want [%s]", InstanceIDHeader, got, tt.instanceID)
+			}
+		})
+	}
+}
+
+func TestDetectSpeechAudioEpochHeader(t *testing.T) {
+	// StubEngine's START fires on frame 49 (20ms frames): offset 980ms from
+	// the epoch, whatever the client's clock says.
+	cfg := config.Config{
+		Threshold:            0.5,
+		MinSpeechDurationMs:  20,
+		MinSilenceDurationMs: 20,
+	}
+	client, cleanup := startTestServer(t, cfg)
+	defer cleanup()
+
+	stream, err := client.DetectSpeech(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 60; i++ {
+		if err := stream.Send(&napv1.DetectSpeechRequest{
+			Format:  &napv1.AudioFormat{SampleRate: 16000},
+			PcmData: make([]byte, 640),
+		}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	stream.CloseSend()
+	first, err := stream.Recv()
+	if err != nil {
+		t.Fatal(err)
+	}
+	md, err := stream.Header()
+	if err != nil {
+		t.Fatal(err)
+	}
+	epoch, ok := ParseAudioEpoch(md)
+	if !ok {
+		t.Fatalf("header %v has no parsable %s", md, AudioEpochHeader)
+	}
+	if got := AudioOffset(first, epoch); got != 980*time.Millisecond {
+		t.Errorf("START audio offset = %v, want 980ms", got)
+	}
+	geometry, ok := ParseFrameGeometry(md)
+	if !ok {
+		t.Fatalf("header %v has no parsable %s", md, FrameGeometryHeader)
+	}
+	if got := geometry.Index(AudioOffset(first, epoch)); got != 49 {
+		t.Errorf("START frame index = %d, want 49", got)
+	}
+
+	received := 1
+	for {
+		if _, err := stream.Recv(); err == io.EOF {
+			break
+		} else if err != nil {
+			t.Fatal(err)
+		}
+		received++
+	}
+	if got := stream.Trailer().Get(EventCountTrailer); len(got) != 1 || got[0] != strconv.Itoa(received) {
+		t.Errorf("%s = %q, want %d", EventCountTrailer, got, received)
+	}
+}
+
+func TestFrameGeometry(t *testing.T) {
+	// 400 samples at 16 kHz is 25 ms; 256 at 11025 Hz is not a whole number
+	// of nanoseconds, so offsets are truncated frame starts.
+	for _, g := range []FrameGeometry{{400, 16000}, {256, 11025}, {256, 8000}} {
+		parsed, ok := ParseFrameGeometry(metadata.Pairs(FrameGeometryHeader, g.String()))
+		if !ok || parsed != g {
+			t.Errorf("ParseFrameGeometry(%q) = %v, %v", g.String(), parsed, ok)
+		}
+		clock := frameClock{frameSamples: g.Samples, sampleRate: g.SampleRate}
+		for _, frame := range []int64{0, 1, 49, 12345} {
+			if got := g.Index(clock.offset(frame)); got != frame {
+				t.Errorf("%v: Index(offset(%d)) = %d", g, frame, got)
+			}
+		}
+	}
+	for _, bad := range []string{"", "samples=0,sample_rate=16000", "512@16000"} {
+		if _, ok := ParseFrameGeometry(metadata.Pairs(FrameGeometryHeader, bad)); ok {
+			t.Errorf("ParseFrameGeometry(%q) ok = true", bad)
+		}
+	}
+}
+
+func TestParseAudioEpoch(t *testing.T) {
+	if _, ok := ParseAudioEpoch(metadata.MD{}); ok {
+		t.Error("ParseAudioEpoch(empty) ok = true")
+	}
+	if _, ok := ParseAudioEpoch(metadata.Pairs(AudioEpochHeader, "yesterday")); ok {
+		t.Error("ParseAudioEpoch(invalid) ok = true")
+	}
+	want := time.Date(2026, 1, 2, 3, 4, 5, 123456789, time.UTC)
+	got, ok := ParseAudioEpoch(metadata.Pairs(AudioEpochHeader, want.Format(time.RFC3339Nano)))
+	if !ok || !got.Equal(want) {
+		t.Errorf("ParseAudioEpoch = %v, %v, want %v", got, ok, want)
+	}
+}
@@ -0,0 +1,87 @@
+package server
+
+import (
+	"time"
+
+	"github.com/nupi-ai/plugin-vad-local-silero/internal/config"
+	"github.com/nupi-ai/plugin-vad-local-silero/internal/engine"
+	"github.com/nupi-ai/plugin-vad-local-silero/internal/errs"
+	"github.com/nupi-ai/plugin-vad-local-silero/internal/metrics"
+)
+
+// WithModelBEngine sets the factory of the engines running model_b, for
+// streams and shadows that select model "b". Without it such streams fail
+// with errs.ErrInvalidConfig.
+func WithModelBEngine(newEngine func() engine.Engine) Option {
+	return func(s *Server) {
+		s.newModelBEngine = newEngine
+	}
+}
+
+// modelMetrics holds the counters of the streams running one model, so
+// the two models of an A/B evaluation can be compared.
+type modelMetrics struct {
+	streams      *metrics.Counter
+	frames       *metrics.Counter
+	speechFrames *metrics.Counter
+	segments     *metrics.Counter
+	inferenceUs  *metrics.Counter
+}
+
+func newModelMetrics(reg *metrics.Registry, model string) *modelMetrics {
+	return &modelMetrics{
+		streams:      reg.Counter("vad_model_streams_total", "model", model),
+		frames:       reg.Counter("vad_model_frames_total", "model", model),
+		speechFrames: reg.Counter("vad_model_speech_frames_total", "model", model),
+		segments:     reg.Counter("vad_model_speech_segments_total", "model", model),
+		inferenceUs:  reg.Counter("vad_model_inference_microseconds_total", "model", model),
+	}
+}
+
+// chunk counts a processed chunk: its frames, how many of them were
+// speech, and the engine time it took.
+func (m *modelMetrics) chunk(speech []bool, inference time.Duration) {
+	m.frames.Add(int64(len(speech)))
+	n := int64(0)
+	for _, s := range speech {
+		if s {
+			n++
+		}
+	}
+	m.speechFrames.Add(n)
+	m.inferenceUs.Add(inference.Microseconds())
+}
+
+// modelEngine returns the factory of the engines running model, or an
+// error when that model is not loaded.
+func (s *Server) modelEngine(model string) (func() engine.Engine, error) {
+	if model != config.ModelB {
+		return s.newEngine, nil
+	}
+	if s.newModelBEngine == nil {
+		return nil, errs.New(errs.ErrInvalidConfig, "stream config: model %q is not loaded", config.ModelB)
+	}
+	return s.newModelBEngine, nil
+}
+
+// shadowEngine returns a new shadow engine for a stream whose shadow runs
+// shadowCfg: one of the model the shadow parameters select, else one of
+// shadow_engine. It returns nil when that model is not loaded.
+func (s *Server) shadowEngine(shadowCfg config.Config) engine.Engine {
+	if s.cfg.Shadow.Model == nil {
+		return s.newShadowEngine()
+	}
+	newEngine, err := s.modelEngine(shadowCfg.Model)
+	if err != nil {
+		return nil
+	}
+	return newEngine()
+}
+
+// streamModel returns the model label of a stream's config: "a" or "b".
+func streamModel(cfg config.Config) string {
+	if cfg.Model == config.ModelB {
+		return config.ModelB
+	}
+	return config.ModelA
+}
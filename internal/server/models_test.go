@@ -0,0 +1,119 @@
+package server
+
+import (
+	"context"
+	"io"
+	"testing"
+
+	napv1 "github.com/nupi-ai/nupi/api/nap/v1"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	"github.com/nupi-ai/plugin-vad-local-silero/internal/config"
+	"github.com/nupi-ai/plugin-vad-local-silero/internal/engine"
+)
+
+func TestModelSelection(t *testing.T) {
+	// Model a never hears speech, model b always does.
+	cfg := config.Config{
+		Threshold:            0.5,
+		MinSpeechDurationMs:  20,
+		MinSilenceDurationMs: 20,
+		ModelB:               config.SecondModel{Version: engine.ModelV4},
+	}
+	srv := New(cfg, nil,
+		func() engine.Engine { return &constEngine{} },
+		WithModelBEngine(func() engine.Engine { return &constEngine{speech: true} }),
+	)
+	client, cleanup := startTestServerWith(t, srv)
+	defer cleanup()
+
+	const chunks = 10
+	run := func(first, later string) (starts int, err error) {
+		stream, err := client.DetectSpeech(context.Background())
+		if err != nil {
+			t.Fatal(err)
+		}
+		for i := 0; i < chunks; i++ {
+			req := &napv1.DetectSpeechRequest{
+				PcmData: make([]byte, 640),
+				Format:  &napv1.AudioFormat{SampleRate: 16000},
+			}
+			switch i {
+			case 0:
+				req.ConfigJson = first
+			case 1:
+				req.ConfigJson = later
+			}
+			if err := stream.Send(req); err != nil {
+				break
+			}
+		}
+		stream.CloseSend()
+		for {
+			evt, err := stream.Recv()
+			if err != nil {
+				return starts, err
+			}
+			if evt.GetType() == napv1.SpeechEventType_SPEECH_EVENT_TYPE_START {
+				starts++
+			}
+		}
+	}
+
+	if starts, err := run(`{"model": "b"}`, ""); err != io.EOF || starts != 1 {
+		t.Errorf("model b stream: %d START, err %v; want 1 START", starts, err)
+	}
+	if starts, err := run("", ""); err != io.EOF || starts != 0 {
+		t.Errorf("model a stream: %d START, err %v; want none", starts, err)
+	}
+	if _, err := run("", `{"model": "b"}`); status.Code(err) != codes.InvalidArgument {
+		t.Errorf("model change after audio: got %v, want InvalidArgument", err)
+	}
+
+	snap := srv.Metrics().Snapshot()
+	for key, want := range map[string]int64{
+		`vad_model_streams_total{model="a"}`:         2,
+		`vad_model_streams_total{model="b"}`:         1,
+		`vad_model_frames_total{model="b"}`:          chunks,
+		`vad_model_speech_frames_total{model="a"}`:   0,
+		`vad_model_speech_segments_total{model="b"}`: 1,
+	} {
+		if got := snap[key]; got != want {
+			t.Errorf("%s = %d, want %d", key, got, want)
+		}
+	}
+	if got := snap[`vad_model_speech_frames_total{model="b"}`]; got == 0 || got > chunks {
+		t.Errorf("model b speech frames = %d, want some of %d", got, chunks)
+	}
+}
+
+func TestModelBNotLoaded(t *testing.T) {
+	// model_b is configured but no engine was loaded for it, as in a build
+	// without the silero backend.
+	cfg := config.Config{
+		Threshold:            0.5,
+		MinSpeechDurationMs:  20,
+		MinSilenceDurationMs: 20,
+		ModelB:               config.SecondModel{Version: engine.ModelV4},
+	}
+	srv := New(cfg, nil, func() engine.Engine { return engine.NewStubEngine() })
+	client, cleanup := startTestServerWith(t, srv)
+	defer cleanup()
+
+	stream, err := client.DetectSpeech(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := stream.Send(&napv1.DetectSpeechRequest{
+		PcmData:    make([]byte, 640),
+		Format:     &napv1.AudioFormat{SampleRate: 16000},
+		ConfigJson: `{"model": "b"}`,
+	}); err != nil {
+		t.Fatal(err)
+	}
+	stream.CloseSend()
+	if _, err := stream.Recv(); status.Code(err) != codes.InvalidArgument {
+		t.Errorf("model b without its engine: got %v, want InvalidArgument", err)
+	}
+}
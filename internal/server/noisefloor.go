@@ -0,0 +1,74 @@
+package server
+
+import (
+	"github.com/nupi-ai/plugin-vad-local-silero/internal/config"
+)
+
+const (
+	// noiseFloorInitMs of frames outside speech are averaged before the
+	// threshold first adapts; until then the configured threshold applies.
+	noiseFloorInitMs = 500
+	// noiseFloorTrackMs is the time constant the noise floor follows the
+	// stream's background with afterwards.
+	noiseFloorTrackMs = 5000
+)
+
+// noiseFloor estimates a stream's noise floor, the mean speech probability
+// the engine gives the frames outside speech, and derives the adaptive
+// threshold from it (see config.AdaptiveThreshold).
+type noiseFloor struct {
+	min, max, margin float64
+	// negGap is how far the configured neg_threshold sits below the
+	// configured threshold; the adapted pair keeps that distance.
+	negGap float64
+
+	initFrames int
+	alpha      float64
+	frames     int
+	mean       float64
+}
+
+// newNoiseFloor returns nil when the adaptive threshold is disabled.
+func newNoiseFloor(cfg config.Config, clock frameClock) *noiseFloor {
+	at := cfg.AdaptiveThreshold
+	if !at.Enabled() {
+		return nil
+	}
+	return &noiseFloor{
+		min:        at.Min,
+		max:        at.Max,
+		margin:     at.EffectiveMargin(),
+		negGap:     cfg.Threshold - cfg.EffectiveNegThreshold(),
+		initFrames: max(1, clock.framesFor(noiseFloorInitMs)),
+		alpha:      1 / float64(max(1, clock.framesFor(noiseFloorTrackMs))),
+	}
+}
+
+// add records the probability of a frame outside speech.
+func (nf *noiseFloor) add(p float32) {
+	nf.frames++
+	if nf.frames <= nf.initFrames {
+		nf.mean += (float64(p) - nf.mean) / float64(nf.frames)
+		return
+	}
+	nf.mean += (float64(p) - nf.mean) * nf.alpha
+}
+
+// ready reports whether the initial estimate is complete.
+func (nf *noiseFloor) ready() bool {
+	return nf.frames >= nf.initFrames
+}
+
+// thresholds returns the adapted threshold and neg_threshold.
+func (nf *noiseFloor) thresholds() (threshold, negThreshold float64) {
+	t := min(max(nf.mean+nf.margin, nf.min), nf.max)
+	return t, min(t, max(t-nf.negGap, 0.01))
+}
+
+// apply sets bd's thresholds from the estimate once it is ready.
+func (nf *noiseFloor) apply(bd *boundaryDetector) {
+	if nf.ready() {
+		t, neg := nf.thresholds()
+		bd.threshold, bd.negThreshold = float32(t), float32(neg)
+	}
+}
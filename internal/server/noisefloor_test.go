@@ -0,0 +1,82 @@
+package server
+
+import (
+	"testing"
+
+	napv1 "github.com/nupi-ai/nupi/api/nap/v1"
+
+	"github.com/nupi-ai/plugin-vad-local-silero/internal/config"
+	"github.com/nupi-ai/plugin-vad-local-silero/internal/engine"
+)
+
+func TestBoundaryDetectorAdaptiveThreshold(t *testing.T) {
+	clock := frameClock{frameSamples: 320, sampleRate: 16000} // 20 ms frames
+	cfg := config.Config{
+		Threshold:            0.5,
+		MinSpeechDurationMs:  100,
+		MinSilenceDurationMs: 100,
+		AdaptiveThreshold:    config.AdaptiveThreshold{Min: 0.3, Max: 0.8, Margin: 0.2},
+	}
+	// starts feeds one second of background at noise, then half a second at
+	// burst, and returns the STARTs and the threshold in effect.
+	starts := func(cfg config.Config, noise, burst float32) (int, float32) {
+		bd := newBoundaryDetector(cfg, clock)
+		n := 0
+		for i := 0; i < 75; i++ {
+			p := noise
+			if i >= 50 {
+				p = burst
+			}
+			for _, evt := range bd.process(engine.Result{Confidence: p}) {
+				if evt.Type == napv1.SpeechEventType_SPEECH_EVENT_TYPE_START {
+					n++
+				}
+			}
+		}
+		return n, bd.threshold
+	}
+
+	// Quiet room: the threshold drops to min, so soft speech opens a segment
+	// the fixed threshold would miss.
+	if n, th := starts(cfg, 0.02, 0.4); n != 1 || abs32(th-0.3) > 1e-6 {
+		t.Errorf("quiet room: %d STARTs at threshold %v, want 1 at 0.3", n, th)
+	}
+	fixed := cfg
+	fixed.AdaptiveThreshold = config.AdaptiveThreshold{}
+	if n, _ := starts(fixed, 0.02, 0.4); n != 0 {
+		t.Errorf("quiet room, fixed threshold: %d STARTs, want 0", n)
+	}
+
+	// Noisy room: the threshold rises above the noise, so a noise surge the
+	// fixed threshold would take for speech does not (and is learned).
+	if n, th := starts(cfg, 0.4, 0.55); n != 0 || th < 0.6-1e-6 {
+		t.Errorf("noisy room: %d STARTs at threshold %v, want 0 at 0.6 or more", n, th)
+	}
+	if n, _ := starts(fixed, 0.4, 0.55); n != 1 {
+		t.Errorf("noisy room, fixed threshold: %d STARTs, want 1", n)
+	}
+
+	// Until half a second of background was measured, the configured
+	// threshold applies.
+	bd := newBoundaryDetector(cfg, clock)
+	for i := 0; i < 20; i++ {
+		bd.process(engine.Result{Confidence: 0.02})
+	}
+	if bd.threshold != 0.5 {
+		t.Errorf("threshold after 400 ms = %v, want the configured 0.5", bd.threshold)
+	}
+	for i := 0; i < 5; i++ {
+		bd.process(engine.Result{Confidence: 0.02})
+	}
+	if abs32(bd.threshold-0.3) > 1e-6 || abs32(bd.negThreshold-0.15) > 1e-6 {
+		t.Errorf("thresholds after 500 ms = %v/%v, want 0.3/0.15", bd.threshold, bd.negThreshold)
+	}
+
+	// A config update keeps the estimate.
+	next := cfg
+	next.MinSilenceDurationMs = 200
+	bd.reconfigure(next, cfg, clock)
+	if abs32(bd.threshold-0.3) > 1e-6 {
+		t.Errorf("threshold after reconfigure = %v, want 0.3", bd.threshold)
+	}
+}
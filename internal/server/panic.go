@@ -0,0 +1,49 @@
+package server
+
+import (
+	"fmt"
+	"log/slog"
+	"runtime/debug"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	"github.com/nupi-ai/plugin-vad-local-silero/internal/metrics"
+)
+
+// panicGuard recovers panics on the goroutines the server starts besides the
+// RPC handlers: a stream's receive loop, event sender or event queue, and
+// the segment sink worker. The RPC recovery interceptor only covers the
+// handler's own goroutine, so a panic there would otherwise crash the
+// process and every stream with it.
+type panicGuard struct {
+	panics *metrics.Counter
+	log    *slog.Logger
+}
+
+func newPanicGuard(reg *metrics.Registry, logger *slog.Logger) *panicGuard {
+	return &panicGuard{panics: reg.Counter("vad_panics_total"), log: logger}
+}
+
+// errPanicked is the status of a stream ended by a recovered panic, as the
+// recovery interceptor reports it.
+var errPanicked = status.Error(codes.Internal, "internal error, the call was aborted")
+
+// recoverTo, deferred on such a goroutine, recovers a panic: it is logged
+// with its stack and counted in vad_panics_total and, when h is not nil, the
+// stream h ends with errPanicked. Other streams are not affected.
+func (g *panicGuard) recoverTo(h *streamHandle, goroutine string) {
+	p := recover()
+	if p == nil {
+		return
+	}
+	g.panics.Inc()
+	g.log.Error("recovered from panic in stream goroutine",
+		"goroutine", goroutine,
+		"panic", fmt.Sprint(p),
+		"stack", string(debug.Stack()),
+	)
+	if h != nil {
+		h.terminate(errPanicked)
+	}
+}
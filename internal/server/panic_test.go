@@ -0,0 +1,105 @@
+package server
+
+import (
+	"context"
+	"io"
+	"log/slog"
+	"testing"
+	"time"
+
+	napv1 "github.com/nupi-ai/nupi/api/nap/v1"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	"github.com/nupi-ai/plugin-vad-local-silero/internal/config"
+	"github.com/nupi-ai/plugin-vad-local-silero/internal/engine"
+)
+
+// panicStream panics in Send or in Recv after its requests, as a transport
+// or interceptor bug would on the goroutine calling it.
+type panicStream struct {
+	*bufferStream
+	onSend bool
+}
+
+func (p *panicStream) Send(evt *napv1.SpeechEvent) error {
+	if p.onSend {
+		panic("send bug")
+	}
+	return p.bufferStream.Send(evt)
+}
+
+func (p *panicStream) Recv() (*napv1.DetectSpeechRequest, error) {
+	req, err := p.bufferStream.Recv()
+	if err == io.EOF && !p.onSend {
+		panic("recv bug")
+	}
+	return req, err
+}
+
+// heldStream is a bufferStream whose Recv blocks at the end of its requests
+// until release is closed, keeping the stream open.
+type heldStream struct {
+	*bufferStream
+	waiting chan struct{}
+	release chan struct{}
+}
+
+func (h *heldStream) Recv() (*napv1.DetectSpeechRequest, error) {
+	if len(h.requests) == 0 {
+		signal(h.waiting)
+		<-h.release
+	}
+	return h.bufferStream.Recv()
+}
+
+func TestStreamGoroutinePanicFailsOnlyThatStream(t *testing.T) {
+	for _, tc := range []struct {
+		name   string
+		onSend bool
+	}{
+		{"event queue", true},
+		{"receive", false},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			srv := New(config.Config{Threshold: 0.5, EventQueueSize: 4}, slog.New(slog.NewTextHandler(io.Discard, nil)),
+				func() engine.Engine { return engine.NewStubEngine() })
+			chunks := func() []*napv1.DetectSpeechRequest {
+				var reqs []*napv1.DetectSpeechRequest
+				for range engine.StubToggleInterval + 1 {
+					reqs = append(reqs, &napv1.DetectSpeechRequest{Format: &napv1.AudioFormat{SampleRate: 16000}, PcmData: make([]byte, 640)})
+				}
+				return reqs
+			}
+
+			healthy := &heldStream{
+				bufferStream: &bufferStream{ctx: context.Background(), requests: chunks()},
+				waiting:      make(chan struct{}, 1),
+				release:      make(chan struct{}),
+			}
+			healthyDone := make(chan error, 1)
+			go func() { healthyDone <- srv.DetectSpeech(healthy) }()
+			select {
+			case <-healthy.waiting:
+			case <-time.After(5 * time.Second):
+				t.Fatal("healthy stream did not read its chunks")
+			}
+
+			broken := &panicStream{bufferStream: &bufferStream{ctx: context.Background(), requests: chunks()}, onSend: tc.onSend}
+			if err := srv.DetectSpeech(broken); status.Code(err) != codes.Internal {
+				t.Errorf("panicking stream: err = %v, want Internal", err)
+			}
+			if got := srv.Metrics().Snapshot()["vad_panics_total"]; got != 1 {
+				t.Errorf("vad_panics_total = %d, want 1", got)
+			}
+
+			close(healthy.release)
+			if err := <-healthyDone; err != nil {
+				t.Errorf("concurrent stream: err = %v, want nil", err)
+			}
+			if len(healthy.events) == 0 {
+				t.Error("concurrent stream got no events")
+			}
+		})
+	}
+}
@@ -0,0 +1,190 @@
+package server
+
+import (
+	"context"
+	"encoding/binary"
+	"io"
+	"math"
+	"testing"
+
+	napv1 "github.com/nupi-ai/nupi/api/nap/v1"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	"github.com/nupi-ai/plugin-vad-local-silero/internal/config"
+	"github.com/nupi-ai/plugin-vad-local-silero/internal/engine"
+)
+
+func TestDenoiseStreamConfig(t *testing.T) {
+	srv := New(config.Config{Threshold: 0.5, MinSpeechDurationMs: 20, MinSilenceDurationMs: 20}, nil,
+		func() engine.Engine { return engine.NewStubEngine() })
+	client, cleanup := startTestServerWith(t, srv)
+	defer cleanup()
+
+	run := func(first, later string) (int, error) {
+		stream, err := client.DetectSpeech(context.Background())
+		if err != nil {
+			t.Fatal(err)
+		}
+		// Two seconds of audio in 20 ms chunks, the config on the first and
+		// second chunk.
+		for i := 0; i < 100; i++ {
+			req := &napv1.DetectSpeechRequest{
+				PcmData: make([]byte, 640),
+				Format:  &napv1.AudioFormat{SampleRate: 16000},
+			}
+			switch i {
+			case 0:
+				req.ConfigJson = first
+			case 1:
+				req.ConfigJson = later
+			}
+			if err := stream.Send(req); err != nil {
+				break
+			}
+		}
+		stream.CloseSend()
+		events := 0
+		for {
+			if _, err := stream.Recv(); err != nil {
+				return events, err
+			}
+			events++
+		}
+	}
+
+	plain, err := run("", "")
+	if err != io.EOF {
+		t.Fatalf("plain stream failed: %v", err)
+	}
+	denoised, err := run(`{"denoise": true}`, "")
+	if err != io.EOF {
+		t.Fatalf("denoised stream failed: %v", err)
+	}
+	// The stub's decisions follow frame timing, not content: the denoiser's
+	// delay may cost the tail frame, nothing more.
+	if denoised == 0 || denoised > plain {
+		t.Errorf("denoised stream produced %d events, plain %d", denoised, plain)
+	}
+	if _, err := run("", `{"denoise": true}`); status.Code(err) != codes.InvalidArgument {
+		t.Errorf("denoise change after audio: got %v, want InvalidArgument", err)
+	}
+}
+
+// levelEngine is a stub engine that records the mean and RMS sample value
+// of the last chunk it saw.
+type levelEngine struct {
+	engine.StubEngine
+	mean, rms float64
+}
+
+func (e *levelEngine) ProcessChunk(pcm []byte, sampleRate uint32) ([]engine.Result, error) {
+	var sum, sumSq float64
+	for i := 0; i+1 < len(pcm); i += 2 {
+		v := float64(int16(binary.LittleEndian.Uint16(pcm[i:])))
+		sum += v
+		sumSq += v * v
+	}
+	if n := float64(len(pcm) / 2); n > 0 {
+		e.mean, e.rms = sum/n, math.Sqrt(sumSq/n)
+	}
+	return e.StubEngine.ProcessChunk(pcm, sampleRate)
+}
+
+// runPreprocessStream sends chunk fifty times (one second for a 20 ms
+// chunk), with first and later as config_json of the first two, and returns
+// the stream's final error.
+func runPreprocessStream(t *testing.T, client napv1.VoiceActivityDetectionServiceClient, chunk []byte, first, later string) error {
+	t.Helper()
+	stream, err := client.DetectSpeech(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 50; i++ {
+		req := &napv1.DetectSpeechRequest{
+			PcmData: chunk,
+			Format:  &napv1.AudioFormat{SampleRate: 16000},
+		}
+		switch i {
+		case 0:
+			req.ConfigJson = first
+		case 1:
+			req.ConfigJson = later
+		}
+		if err := stream.Send(req); err != nil {
+			break
+		}
+	}
+	stream.CloseSend()
+	for {
+		if _, err := stream.Recv(); err != nil {
+			return err
+		}
+	}
+}
+
+func TestHighPassStreamConfig(t *testing.T) {
+	var last *levelEngine
+	srv := New(config.Config{Threshold: 0.5, MinSpeechDurationMs: 20, MinSilenceDurationMs: 20}, nil,
+		func() engine.Engine {
+			last = &levelEngine{StubEngine: *engine.NewStubEngine()}
+			return last
+		})
+	client, cleanup := startTestServerWith(t, srv)
+	defer cleanup()
+
+	// A constant quarter-scale offset.
+	chunk := make([]byte, 640)
+	for i := 0; i < len(chunk); i += 2 {
+		binary.LittleEndian.PutUint16(chunk[i:], 8192)
+	}
+	if err := runPreprocessStream(t, client, chunk, "", ""); err != io.EOF {
+		t.Fatalf("plain stream failed: %v", err)
+	}
+	if last.mean != 8192 {
+		t.Errorf("unfiltered engine input mean = %.1f, want 8192", last.mean)
+	}
+	if err := runPreprocessStream(t, client, chunk, `{"high_pass_hz": 100}`, ""); err != io.EOF {
+		t.Fatalf("filtered stream failed: %v", err)
+	}
+	if last.mean > 1 || last.mean < -1 {
+		t.Errorf("filtered engine input mean = %.1f, want the offset removed", last.mean)
+	}
+	if err := runPreprocessStream(t, client, chunk, "", `{"high_pass_hz": 100}`); status.Code(err) != codes.InvalidArgument {
+		t.Errorf("high_pass_hz change after audio: got %v, want InvalidArgument", err)
+	}
+	if err := runPreprocessStream(t, client, chunk, `{"high_pass_hz": 5000}`, ""); status.Code(err) != codes.InvalidArgument {
+		t.Errorf("high_pass_hz out of range: got %v, want InvalidArgument", err)
+	}
+}
+
+func TestAGCStreamConfig(t *testing.T) {
+	var last *levelEngine
+	srv := New(config.Config{Threshold: 0.5, MinSpeechDurationMs: 20, MinSilenceDurationMs: 20}, nil,
+		func() engine.Engine {
+			last = &levelEngine{StubEngine: *engine.NewStubEngine()}
+			return last
+		})
+	client, cleanup := startTestServerWith(t, srv)
+	defer cleanup()
+
+	// A 400 Hz tone (eight periods per chunk) at -50 dBFS RMS.
+	chunk := make([]byte, 640)
+	amp := math.Sqrt2 * math.Pow(10, -50.0/20) * 32768
+	for i := 0; i < len(chunk)/2; i++ {
+		v := int16(math.Round(amp * math.Sin(2*math.Pi*400*float64(i)/16000)))
+		binary.LittleEndian.PutUint16(chunk[2*i:], uint16(v))
+	}
+	if err := runPreprocessStream(t, client, chunk, `{"agc": {"target_dbfs": -20, "max_gain_db": 40}}`, ""); err != io.EOF {
+		t.Fatalf("agc stream failed: %v", err)
+	}
+	if level := 20 * math.Log10(last.rms/32768); math.Abs(level+20) > 1 {
+		t.Errorf("engine input level = %.1f dBFS, want -20", level)
+	}
+	if err := runPreprocessStream(t, client, chunk, "", `{"agc": {"target_dbfs": -20}}`); status.Code(err) != codes.InvalidArgument {
+		t.Errorf("agc change after audio: got %v, want InvalidArgument", err)
+	}
+	if err := runPreprocessStream(t, client, chunk, `{"agc": {"target_dbfs": 3}}`, ""); status.Code(err) != codes.InvalidArgument {
+		t.Errorf("positive target_dbfs: got %v, want InvalidArgument", err)
+	}
+}
@@ -0,0 +1,203 @@
+package server
+
+import (
+	"sync"
+	"time"
+
+	napv1 "github.com/nupi-ai/nupi/api/nap/v1"
+
+	"github.com/nupi-ai/plugin-vad-local-silero/internal/config"
+	"github.com/nupi-ai/plugin-vad-local-silero/internal/metrics"
+)
+
+// queueMetrics counts events dropped by full event queues, per event type.
+type queueMetrics struct {
+	ongoing     *metrics.Counter
+	probability *metrics.Counter
+	heartbeat   *metrics.Counter
+}
+
+func newQueueMetrics(reg *metrics.Registry) *queueMetrics {
+	return &queueMetrics{
+		ongoing:     reg.Counter("vad_events_dropped_total", "type", "ongoing"),
+		probability: reg.Counter("vad_events_dropped_total", "type", "probability"),
+		heartbeat:   reg.Counter("vad_events_dropped_total", "type", "heartbeat"),
+	}
+}
+
+// droppable reports whether evt may be dropped from a full queue: ONGOING
+// and probability events are superseded by the next one, and a full queue
+// already tells the client the stream is alive; boundaries are never dropped.
+func droppable(evt *napv1.SpeechEvent) bool {
+	t := evt.GetType()
+	return t == napv1.SpeechEventType_SPEECH_EVENT_TYPE_ONGOING || t == ProbabilityEventType
+}
+
+// eventQueue sends a stream's events from its own goroutine through a
+// bounded queue (event_queue_size), so the handler keeps consuming audio
+// while the client is slow to read. Send only queues the event; an error
+// from the stream is returned by the next Send or by flush. Sends are timed
+// for the watchdog like eventSender's.
+type eventQueue struct {
+	h       *streamHandle
+	size    int
+	policy  string
+	metrics *queueMetrics
+	guard   *panicGuard
+
+	mu       sync.Mutex
+	events   []*napv1.SpeechEvent
+	inFlight bool
+	err      error
+	dropped  int64
+
+	// wake tells the sender an event was queued; moved tells Send and flush
+	// that one left the queue, or that the sender failed.
+	wake  chan struct{}
+	moved chan struct{}
+}
+
+// newEventQueue starts the sending goroutine; it exits once done is closed
+// or the stream fails, and a panic on it ends the stream through g.
+func newEventQueue(stream napv1.VoiceActivityDetectionService_DetectSpeechServer, h *streamHandle, size int, policy string, m *queueMetrics, g *panicGuard, done <-chan struct{}) *eventQueue {
+	q := &eventQueue{
+		h:       h,
+		size:    size,
+		policy:  policy,
+		metrics: m,
+		guard:   g,
+		events:  make([]*napv1.SpeechEvent, 0, size),
+		wake:    make(chan struct{}, 1),
+		moved:   make(chan struct{}, 1),
+	}
+	go q.run(stream, done)
+	return q
+}
+
+func (q *eventQueue) run(stream napv1.VoiceActivityDetectionService_DetectSpeechServer, done <-chan struct{}) {
+	defer q.guard.recoverTo(q.h, "event queue")
+	for {
+		q.mu.Lock()
+		if len(q.events) == 0 {
+			q.mu.Unlock()
+			select {
+			case <-q.wake:
+				continue
+			case <-done:
+				return
+			}
+		}
+		evt := q.events[0]
+		q.events = q.events[1:]
+		q.inFlight = true
+		q.mu.Unlock()
+		signal(q.moved)
+
+		q.h.begin(opSend, time.Now())
+		err := stream.Send(evt)
+		q.h.end()
+
+		q.mu.Lock()
+		q.inFlight = false
+		if err != nil {
+			q.err = err
+		}
+		q.mu.Unlock()
+		signal(q.moved)
+		if err != nil {
+			return
+		}
+	}
+}
+
+// signal wakes the waiter on c, if any, without blocking.
+func signal(c chan struct{}) {
+	select {
+	case c <- struct{}{}:
+	default:
+	}
+}
+
+// Send queues evt. When the queue is full, the drop_ongoing policy drops
+// evt if it is droppable and otherwise makes room by dropping the oldest
+// droppable event queued; with nothing to drop, or with the block policy,
+// Send waits for the sender.
+func (q *eventQueue) Send(evt *napv1.SpeechEvent) error {
+	for {
+		q.mu.Lock()
+		if q.err != nil {
+			err := q.err
+			q.mu.Unlock()
+			return err
+		}
+		if len(q.events) < q.size {
+			q.events = append(q.events, evt)
+			q.mu.Unlock()
+			signal(q.wake)
+			return nil
+		}
+		if q.policy == config.EventQueueDropOngoing {
+			if droppable(evt) {
+				q.drop(evt)
+				q.mu.Unlock()
+				return nil
+			}
+			for i, queued := range q.events {
+				if droppable(queued) {
+					q.drop(queued)
+					q.events = append(q.events[:i], q.events[i+1:]...)
+					q.events = append(q.events, evt)
+					q.mu.Unlock()
+					signal(q.wake)
+					return nil
+				}
+			}
+		}
+		q.mu.Unlock()
+		select {
+		case <-q.moved:
+		case <-q.h.stop:
+			return q.h.cause
+		}
+	}
+}
+
+// drop counts evt as dropped; q.mu must be held.
+func (q *eventQueue) drop(evt *napv1.SpeechEvent) {
+	q.dropped++
+	switch {
+	case IsHeartbeat(evt):
+		q.metrics.heartbeat.Inc()
+	case evt.GetType() == ProbabilityEventType:
+		q.metrics.probability.Inc()
+	default:
+		q.metrics.ongoing.Inc()
+	}
+}
+
+// flush waits until every queued event was sent and returns the stream's
+// send error, if any. The handler calls it before returning, so the final
+// events reach the client ahead of the status; it returns early when the
+// stream is terminated.
+func (q *eventQueue) flush() error {
+	for {
+		q.mu.Lock()
+		err, idle := q.err, len(q.events) == 0 && !q.inFlight
+		q.mu.Unlock()
+		if err != nil || idle {
+			return err
+		}
+		select {
+		case <-q.moved:
+		case <-q.h.stop:
+			return q.h.cause
+		}
+	}
+}
+
+// droppedEvents returns the number of events dropped so far.
+func (q *eventQueue) droppedEvents() int64 {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	return q.dropped
+}
@@ -0,0 +1,203 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"slices"
+	"testing"
+	"time"
+
+	napv1 "github.com/nupi-ai/nupi/api/nap/v1"
+
+	"github.com/nupi-ai/plugin-vad-local-silero/internal/config"
+	"github.com/nupi-ai/plugin-vad-local-silero/internal/engine"
+	"github.com/nupi-ai/plugin-vad-local-silero/internal/metrics"
+)
+
+// gatedStream is a DetectSpeech stream whose Send reports each event on
+// sending and then blocks until gate is closed.
+type gatedStream struct {
+	napv1.VoiceActivityDetectionService_DetectSpeechServer
+	sending chan *napv1.SpeechEvent
+	gate    chan struct{}
+	err     error
+	sent    []napv1.SpeechEventType
+}
+
+func newGatedStream() *gatedStream {
+	return &gatedStream{sending: make(chan *napv1.SpeechEvent, 16), gate: make(chan struct{})}
+}
+
+func (g *gatedStream) Send(evt *napv1.SpeechEvent) error {
+	g.sending <- evt
+	<-g.gate
+	if g.err != nil {
+		return g.err
+	}
+	g.sent = append(g.sent, evt.GetType())
+	return nil
+}
+
+func event(t napv1.SpeechEventType) *napv1.SpeechEvent {
+	return &napv1.SpeechEvent{Type: t}
+}
+
+const (
+	evStart   = napv1.SpeechEventType_SPEECH_EVENT_TYPE_START
+	evOngoing = napv1.SpeechEventType_SPEECH_EVENT_TYPE_ONGOING
+	evEnd     = napv1.SpeechEventType_SPEECH_EVENT_TYPE_END
+)
+
+func TestEventQueueDropsOngoing(t *testing.T) {
+	reg := metrics.NewRegistry()
+	stream := newGatedStream()
+	done := make(chan struct{})
+	defer close(done)
+	q := newEventQueue(stream, newStreamHandle(time.Now(), ""), 2, config.EventQueueDropOngoing, newQueueMetrics(reg), newPanicGuard(reg, slog.Default()), done)
+
+	// START is in flight, then two ONGOING fill the queue.
+	for _, typ := range []napv1.SpeechEventType{evStart, evOngoing, evOngoing} {
+		if err := q.Send(event(typ)); err != nil {
+			t.Fatal(err)
+		}
+		if typ == evStart {
+			<-stream.sending
+		}
+	}
+	// A third ONGOING is dropped; END and the next START each replace the
+	// oldest ONGOING queued.
+	for _, typ := range []napv1.SpeechEventType{evOngoing, evEnd, evStart} {
+		if err := q.Send(event(typ)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if n := q.droppedEvents(); n != 3 {
+		t.Errorf("dropped %d events, want 3", n)
+	}
+
+	close(stream.gate)
+	if err := q.flush(); err != nil {
+		t.Fatal(err)
+	}
+	if want := []napv1.SpeechEventType{evStart, evEnd, evStart}; !slices.Equal(stream.sent, want) {
+		t.Errorf("sent %v, want %v", stream.sent, want)
+	}
+	if n := reg.Counter("vad_events_dropped_total", "type", "ongoing").Value(); n != 3 {
+		t.Errorf("vad_events_dropped_total{type=ongoing} = %d, want 3", n)
+	}
+}
+
+func TestEventQueueBlockPolicy(t *testing.T) {
+	stream := newGatedStream()
+	done := make(chan struct{})
+	defer close(done)
+	h := newStreamHandle(time.Now(), "")
+	q := newEventQueue(stream, h, 1, config.EventQueueBlock, newQueueMetrics(metrics.NewRegistry()), newPanicGuard(metrics.NewRegistry(), slog.Default()), done)
+
+	q.Send(event(evStart))
+	<-stream.sending
+	q.Send(event(evOngoing))
+	returned := make(chan error, 1)
+	go func() { returned <- q.Send(event(evOngoing)) }()
+	select {
+	case err := <-returned:
+		t.Fatalf("Send on a full queue returned %v, want it to wait", err)
+	case <-time.After(20 * time.Millisecond):
+	}
+
+	close(stream.gate)
+	if err := <-returned; err != nil {
+		t.Fatal(err)
+	}
+	if err := q.flush(); err != nil {
+		t.Fatal(err)
+	}
+	if len(stream.sent) != 3 || q.droppedEvents() != 0 {
+		t.Errorf("sent %v, dropped %d; want all 3 sent", stream.sent, q.droppedEvents())
+	}
+
+	// A terminated stream stops waiting.
+	stream = newGatedStream()
+	h = newStreamHandle(time.Now(), "")
+	q = newEventQueue(stream, h, 1, config.EventQueueBlock, newQueueMetrics(metrics.NewRegistry()), newPanicGuard(metrics.NewRegistry(), slog.Default()), done)
+	q.Send(event(evStart))
+	<-stream.sending
+	q.Send(event(evEnd))
+	cause := errors.New("terminated")
+	go h.terminate(cause)
+	if err := q.Send(event(evStart)); err != cause {
+		t.Errorf("Send after termination = %v, want the cause", err)
+	}
+	if err := q.flush(); err != cause {
+		t.Errorf("flush after termination = %v, want the cause", err)
+	}
+	close(stream.gate)
+}
+
+func TestEventQueueSendError(t *testing.T) {
+	stream := newGatedStream()
+	stream.err = errors.New("connection reset")
+	done := make(chan struct{})
+	defer close(done)
+	q := newEventQueue(stream, newStreamHandle(time.Now(), ""), 4, config.EventQueueDropOngoing, newQueueMetrics(metrics.NewRegistry()), newPanicGuard(metrics.NewRegistry(), slog.Default()), done)
+
+	q.Send(event(evStart))
+	close(stream.gate)
+	if err := q.flush(); err != stream.err {
+		t.Fatalf("flush = %v, want the send error", err)
+	}
+	if err := q.Send(event(evEnd)); err != stream.err {
+		t.Errorf("Send after the error = %v, want it", err)
+	}
+}
+
+// slowStream is a bufferStream whose client takes delay to read each event.
+type slowStream struct {
+	*bufferStream
+	delay time.Duration
+}
+
+func (s *slowStream) Send(evt *napv1.SpeechEvent) error {
+	time.Sleep(s.delay)
+	return s.bufferStream.Send(evt)
+}
+
+func TestDetectSpeechEventQueueSlowClient(t *testing.T) {
+	// 4 s of stub audio with a probability event per frame, read at 2 ms an
+	// event: the queue drops probability events but every boundary arrives.
+	cfg := config.Config{Threshold: 0.5, MinSpeechDurationMs: 20, MinSilenceDurationMs: 20, RecentStreams: 1, EventQueueSize: 8, EventQueuePolicy: config.EventQueueDropOngoing}
+	srv := New(cfg, nil, func() engine.Engine { return engine.NewStubEngine() })
+	stream := &slowStream{bufferStream: &bufferStream{ctx: context.Background()}, delay: 2 * time.Millisecond}
+	stream.requests = append(stream.requests, &napv1.DetectSpeechRequest{
+		StreamId:   "slow",
+		Format:     &napv1.AudioFormat{SampleRate: 16000},
+		ConfigJson: `{"emit_probabilities": true}`,
+		PcmData:    make([]byte, 640),
+	})
+	for range 199 {
+		stream.requests = append(stream.requests, &napv1.DetectSpeechRequest{PcmData: make([]byte, 640)})
+	}
+	if err := srv.DetectSpeech(stream); err != nil {
+		t.Fatal(err)
+	}
+
+	var boundaries []napv1.SpeechEventType
+	for _, evt := range stream.events {
+		if t := evt.GetType(); t == evStart || t == evEnd {
+			boundaries = append(boundaries, t)
+		}
+	}
+	// The stub switches between silence and speech every second; the last
+	// segment ends at EOF.
+	if want := []napv1.SpeechEventType{evStart, evEnd, evStart, evEnd}; !slices.Equal(boundaries, want) {
+		t.Errorf("boundaries %v, want %v", boundaries, want)
+	}
+	sum := srv.RecentStreams("", 1)
+	if len(sum) != 1 || sum[0].EventsDropped == 0 || sum[0].Events != int64(len(stream.events)) {
+		t.Errorf("summary %+v, want dropped events and %d sent", sum, len(stream.events))
+	}
+	if n := srv.Metrics().Counter("vad_events_dropped_total", "type", "probability").Value(); n == 0 {
+		t.Error("no probability events counted as dropped")
+	}
+}
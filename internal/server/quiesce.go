@@ -0,0 +1,39 @@
+package server
+
+// WithQuiesceHook sets a function called with the new state whenever
+// SetQuiesced changes it, e.g. to flip health readiness.
+func WithQuiesceHook(hook func(quiesced bool)) Option {
+	return func(s *Server) {
+		s.quiesceHook = hook
+	}
+}
+
+// SetQuiesced turns quiesce mode on or off and returns the number of active
+// streams. While quiesced, streams already open continue undisturbed but new
+// DetectSpeech streams fail with TerminationError(ReasonQuiesced, ...), so a
+// load balancer can drain the instance without cutting conversations short.
+func (s *Server) SetQuiesced(on bool) int {
+	if s.quiesced.Swap(on) != on {
+		if on {
+			s.log.Info("quiesce mode enabled, rejecting new streams", "active_streams", s.ActiveStreams())
+		} else {
+			s.log.Info("quiesce mode disabled, accepting new streams")
+		}
+		if s.quiesceHook != nil {
+			s.quiesceHook(on)
+		}
+	}
+	return s.ActiveStreams()
+}
+
+// Quiesced reports whether quiesce mode is on.
+func (s *Server) Quiesced() bool {
+	return s.quiesced.Load()
+}
+
+// ActiveStreams returns the number of streams currently open.
+func (s *Server) ActiveStreams() int {
+	s.streamsMu.Lock()
+	defer s.streamsMu.Unlock()
+	return len(s.streams)
+}
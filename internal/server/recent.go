@@ -0,0 +1,112 @@
+package server
+
+import (
+	"sync"
+	"time"
+)
+
+// StreamSummary describes a closed stream: who sent it, what it processed,
+// how it ended and the VAD parameters it ran with.
+type StreamSummary struct {
+	SessionID string
+	StreamID  string
+	Variant   string
+	// Model is the model the stream ran, "a" or "b", when model_b is
+	// configured.
+	Model    string
+	OpenedAt time.Time
+	ClosedAt time.Time
+	// Peer is the client's address, the original client's when the
+	// listener accepts PROXY protocol headers.
+	Peer string
+
+	// AudioDuration is the audio inferred by the engine and SpeechDuration
+	// the part of it inside closed speech segments; SampleRate is the
+	// client's rate (zero if no audio arrived). MeanConfidence is the mean
+	// raw speech probability over all frames.
+	AudioDuration  time.Duration
+	SpeechDuration time.Duration
+	SampleRate     uint32
+	Frames         int64
+	Segments       int
+	MeanConfidence float64
+	// Events is the number of SpeechEvents sent to the client, and
+	// EventsDropped the number a full event queue dropped instead.
+	Events        int64
+	EventsDropped int64
+	// PeakBufferedBytes is the most PCM the stream held between chunks
+	// (see max_buffered_bytes).
+	PeakBufferedBytes int64
+	// Chunks counts the PCM chunks processed and MaxChunkBytes is the
+	// largest of them; Inference is the engine time per chunk over all of
+	// them, whether or not they carried client_sent_at.
+	Chunks        int64
+	MaxChunkBytes int
+	Inference     LatencyStats
+	// Latency is the stream's latency per stage, measured on the chunks
+	// that carried client_sent_at; nil if none did.
+	Latency map[string]LatencyStats
+
+	// Error is the stream's final error, empty for a clean close.
+	Error string
+
+	// Threshold and NegThreshold are the ones in effect at close: with
+	// adaptive_threshold, the adapted values.
+	Threshold            float64
+	NegThreshold         float64
+	MinSpeechDurationMs  int
+	MinSilenceDurationMs int
+	MaxSpeechDurationMs  int
+	PrerollMs            int
+	MinGapMs             int
+}
+
+// recentStreams keeps the summaries of the last N closed streams. The oldest
+// summary is evicted first.
+type recentStreams struct {
+	mu    sync.Mutex
+	ring  []StreamSummary
+	next  int // ring index of the next insert
+	count int // number of valid entries, at most len(ring)
+}
+
+func newRecentStreams(size int) *recentStreams {
+	return &recentStreams{ring: make([]StreamSummary, size)}
+}
+
+func (r *recentStreams) add(sum StreamSummary) {
+	if len(r.ring) == 0 {
+		return
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.ring[r.next] = sum
+	r.next = (r.next + 1) % len(r.ring)
+	r.count = min(r.count+1, len(r.ring))
+}
+
+// list returns up to limit summaries, most recently closed first. A non-empty
+// sessionID keeps only that session's streams; limit <= 0 means no limit.
+func (r *recentStreams) list(sessionID string, limit int) []StreamSummary {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	var out []StreamSummary
+	for i := 0; i < r.count; i++ {
+		sum := r.ring[(r.next-1-i+len(r.ring))%len(r.ring)]
+		if sessionID != "" && sum.SessionID != sessionID {
+			continue
+		}
+		out = append(out, sum)
+		if limit > 0 && len(out) == limit {
+			break
+		}
+	}
+	return out
+}
+
+// RecentStreams returns the summaries of recently closed streams, most recent
+// first, optionally filtered by session ID. At most limit summaries are
+// returned (no limit if limit <= 0); the server keeps recent_streams of them.
+func (s *Server) RecentStreams(sessionID string, limit int) []StreamSummary {
+	return s.recent.list(sessionID, limit)
+}
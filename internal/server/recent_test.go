@@ -0,0 +1,197 @@
+package server
+
+import (
+	"context"
+	"io"
+	"net"
+	"testing"
+	"time"
+
+	napv1 "github.com/nupi-ai/nupi/api/nap/v1"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials/insecure"
+
+	"github.com/nupi-ai/plugin-vad-local-silero/internal/config"
+	"github.com/nupi-ai/plugin-vad-local-silero/internal/engine"
+	"github.com/nupi-ai/plugin-vad-local-silero/internal/proxyproto"
+)
+
+func TestRecentStreamsEvictsOldest(t *testing.T) {
+	r := newRecentStreams(3)
+	for _, id := range []string{"a", "b", "c", "d"} {
+		r.add(StreamSummary{StreamID: id, SessionID: "s-" + id})
+	}
+	got := r.list("", 0)
+	if len(got) != 3 || got[0].StreamID != "d" || got[2].StreamID != "b" {
+		t.Fatalf("list = %+v, want d, c, b", got)
+	}
+	if got := r.list("", 2); len(got) != 2 || got[1].StreamID != "c" {
+		t.Errorf("list(limit 2) = %+v, want d, c", got)
+	}
+	if got := r.list("s-c", 0); len(got) != 1 || got[0].StreamID != "c" {
+		t.Errorf("list(s-c) = %+v, want c", got)
+	}
+	if got := r.list("s-a", 0); len(got) != 0 {
+		t.Errorf("evicted stream still listed: %+v", got)
+	}
+
+	disabled := newRecentStreams(0)
+	disabled.add(StreamSummary{StreamID: "x"})
+	if got := disabled.list("", 0); len(got) != 0 {
+		t.Errorf("disabled history listed %+v", got)
+	}
+}
+
+func TestDetectSpeechRecordsStreamSummary(t *testing.T) {
+	cfg := config.Config{
+		Threshold:            0.5,
+		MinSpeechDurationMs:  20,
+		MinSilenceDurationMs: 20,
+		RecentStreams:        10,
+	}
+	srv := New(cfg, nil, func() engine.Engine { return engine.NewStubEngine() })
+	client, cleanup := startTestServerWith(t, srv)
+	defer cleanup()
+
+	stream, err := client.DetectSpeech(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < engine.StubToggleInterval*3-1; i++ {
+		if err := stream.Send(&napv1.DetectSpeechRequest{
+			SessionId: "sess-1",
+			StreamId:  "mic-1",
+			Format:    &napv1.AudioFormat{SampleRate: 16000},
+			PcmData:   make([]byte, 640),
+		}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	stream.CloseSend()
+	for {
+		if _, err := stream.Recv(); err == io.EOF {
+			break
+		} else if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	got := srv.RecentStreams("sess-1", 0)
+	if len(got) != 1 {
+		t.Fatalf("RecentStreams = %+v, want 1 summary", got)
+	}
+	sum := got[0]
+	if sum.StreamID != "mic-1" || sum.Variant != VariantBaseline || sum.Error != "" {
+		t.Errorf("summary identity = %+v", sum)
+	}
+	if sum.Frames != 149 || sum.Segments != 1 || sum.SampleRate != 16000 {
+		t.Errorf("frames, segments, rate = %d, %d, %d, want 149, 1, 16000", sum.Frames, sum.Segments, sum.SampleRate)
+	}
+	if want := 149 * 20 * 1e6; float64(sum.AudioDuration) != want {
+		t.Errorf("AudioDuration = %v, want 2.98s", sum.AudioDuration)
+	}
+	if sum.ClosedAt.Before(sum.OpenedAt) {
+		t.Errorf("ClosedAt %v before OpenedAt %v", sum.ClosedAt, sum.OpenedAt)
+	}
+	if host, _, err := net.SplitHostPort(sum.Peer); err != nil || !net.ParseIP(host).IsLoopback() {
+		t.Errorf("Peer = %q, want the loopback client", sum.Peer)
+	}
+}
+
+func TestStreamSummaryPeerFromProxyHeader(t *testing.T) {
+	srv := New(config.Config{Threshold: 0.5, RecentStreams: 10}, nil, func() engine.Engine { return engine.NewStubEngine() })
+	inner, err := net.Listen("tcp", "localhost:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	grpcServer := grpc.NewServer()
+	napv1.RegisterVoiceActivityDetectionServiceServer(grpcServer, srv)
+	go grpcServer.Serve(proxyproto.NewListener(inner, 0, nil))
+	defer grpcServer.Stop()
+
+	// The client plays the load balancer: every connection starts with a
+	// PROXY header naming the original client.
+	conn, err := grpc.NewClient(inner.Addr().String(),
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+		grpc.WithContextDialer(func(ctx context.Context, addr string) (net.Conn, error) {
+			c, err := (&net.Dialer{}).DialContext(ctx, "tcp", addr)
+			if err != nil {
+				return nil, err
+			}
+			if _, err := c.Write([]byte("PROXY TCP4 203.0.113.7 10.0.0.1 51000 50051\r\n")); err != nil {
+				c.Close()
+				return nil, err
+			}
+			return c, nil
+		}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+
+	stream, err := napv1.NewVoiceActivityDetectionServiceClient(conn).DetectSpeech(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := stream.Send(&napv1.DetectSpeechRequest{SessionId: "sess-1", Format: &napv1.AudioFormat{SampleRate: 16000}, PcmData: make([]byte, 640)}); err != nil {
+		t.Fatal(err)
+	}
+	stream.CloseSend()
+	for {
+		if _, err := stream.Recv(); err == io.EOF {
+			break
+		} else if err != nil {
+			t.Fatal(err)
+		}
+	}
+	if got := srv.RecentStreams("sess-1", 1); len(got) != 1 || got[0].Peer != "203.0.113.7:51000" {
+		t.Errorf("RecentStreams = %+v, want peer 203.0.113.7:51000", got)
+	}
+}
+
+func TestListActiveStreams(t *testing.T) {
+	srv := New(config.Config{Threshold: 0.5}, nil, func() engine.Engine { return engine.NewStubEngine() })
+	client, cleanup := startTestServerWith(t, srv)
+	defer cleanup()
+
+	stream, err := client.DetectSpeech(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	for range 2 {
+		if err := stream.Send(&napv1.DetectSpeechRequest{
+			SessionId: "sess-1",
+			StreamId:  "mic-1",
+			Format:    &napv1.AudioFormat{SampleRate: 16000},
+			PcmData:   make([]byte, 640),
+		}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	deadline := time.Now().Add(5 * time.Second)
+	var active []ActiveStream
+	for {
+		active = srv.ListActiveStreams()
+		if len(active) == 1 && active[0].Frames == 2 {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("ListActiveStreams = %+v, want one stream with 2 frames", active)
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+	if got := active[0]; got.SessionID != "sess-1" || got.StreamID != "mic-1" || got.Variant != VariantBaseline || got.Peer == "" || got.OpenedAt.IsZero() {
+		t.Errorf("active stream = %+v", got)
+	}
+
+	stream.CloseSend()
+	if _, err := stream.Recv(); err != io.EOF {
+		t.Fatalf("Recv: %v", err)
+	}
+	for len(srv.ListActiveStreams()) != 0 {
+		if time.Now().After(deadline) {
+			t.Fatal("closed stream still listed")
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
@@ -0,0 +1,32 @@
+package server
+
+import (
+	"github.com/nupi-ai/plugin-vad-local-silero/internal/config"
+)
+
+// streamConfigs are the configs new streams start from, per variant.
+type streamConfigs struct {
+	baseline  config.Config
+	candidate config.Config
+}
+
+func newStreamConfigs(cfg config.Config) *streamConfigs {
+	return &streamConfigs{baseline: cfg, candidate: cfg.CandidateConfig()}
+}
+
+// SetVADParams applies the non-nil fields of p to the config new streams
+// start from, e.g. after the config file changed. The blue/green candidate
+// parameters, feedback offsets and config_json still apply on top. Open
+// streams keep the parameters they started with. An invalid result is
+// rejected and leaves the config unchanged.
+func (s *Server) SetVADParams(p config.VADParams) error {
+	s.configsMu.Lock()
+	defer s.configsMu.Unlock()
+	next := s.configs.Load().baseline
+	p.Apply(&next)
+	if err := next.Validate(); err != nil {
+		return err
+	}
+	s.configs.Store(newStreamConfigs(next))
+	return nil
+}
@@ -0,0 +1,133 @@
+package server
+
+import (
+	"context"
+	"io"
+	"strings"
+	"testing"
+	"time"
+
+	napv1 "github.com/nupi-ai/nupi/api/nap/v1"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	"github.com/nupi-ai/plugin-vad-local-silero/internal/config"
+	"github.com/nupi-ai/plugin-vad-local-silero/internal/engine"
+)
+
+func TestDetectSpeechResampling(t *testing.T) {
+	cfg := config.Config{
+		Threshold:            0.5,
+		MinSpeechDurationMs:  20,
+		MinSilenceDurationMs: 20,
+		AllowResampling:      true,
+	}
+	client, cleanup := startTestServer(t, cfg)
+	defer cleanup()
+
+	stream, err := client.DetectSpeech(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// 20ms at 48kHz = 960 samples = 1920 bytes, resampled to 320 samples.
+	// One extra chunk covers the resampler's look-ahead.
+	totalChunks := engine.StubToggleInterval * 3
+	chunk := make([]byte, 1920)
+	for i := 0; i < totalChunks; i++ {
+		if err := stream.Send(&napv1.DetectSpeechRequest{
+			PcmData: chunk,
+			Format:  &napv1.AudioFormat{SampleRate: 48000},
+		}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := stream.CloseSend(); err != nil {
+		t.Fatal(err)
+	}
+
+	var events []*napv1.SpeechEvent
+	for {
+		evt, err := stream.Recv()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		events = append(events, evt)
+	}
+	if len(events) < 2 {
+		t.Fatalf("got %d events, want a full START…END segment", len(events))
+	}
+	if events[0].Type != napv1.SpeechEventType_SPEECH_EVENT_TYPE_START {
+		t.Errorf("first event = %v, want START", events[0].Type)
+	}
+	last := events[len(events)-1]
+	if last.Type != napv1.SpeechEventType_SPEECH_EVENT_TYPE_END {
+		t.Errorf("last event = %v, want END", last.Type)
+	}
+	// Timing follows the resampled audio, so a stub speech segment still
+	// spans StubToggleInterval 20ms frames.
+	span := last.Timestamp.AsTime().Sub(events[0].Timestamp.AsTime())
+	if want := time.Duration(engine.StubToggleInterval) * 20 * time.Millisecond; span != want {
+		t.Errorf("START→END span = %v, want %v", span, want)
+	}
+}
+
+func TestDetectSpeechResamplingDisabled(t *testing.T) {
+	cfg := config.Config{
+		Threshold:            0.5,
+		MinSpeechDurationMs:  20,
+		MinSilenceDurationMs: 20,
+	}
+	client, cleanup := startTestServer(t, cfg)
+	defer cleanup()
+
+	stream, err := client.DetectSpeech(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := stream.Send(&napv1.DetectSpeechRequest{
+		PcmData: make([]byte, 1920),
+		Format:  &napv1.AudioFormat{SampleRate: 48000},
+	}); err != nil {
+		t.Fatal(err)
+	}
+	stream.CloseSend()
+
+	_, err = stream.Recv()
+	if status.Code(err) != codes.InvalidArgument {
+		t.Fatalf("Recv() error = %v, want InvalidArgument", err)
+	}
+	if !strings.Contains(err.Error(), "allow_resampling") {
+		t.Errorf("error should point at allow_resampling, got: %v", err)
+	}
+}
+
+func TestDetectSpeechResamplingOutOfRange(t *testing.T) {
+	cfg := config.Config{
+		Threshold:            0.5,
+		MinSpeechDurationMs:  20,
+		MinSilenceDurationMs: 20,
+		AllowResampling:      true,
+	}
+	client, cleanup := startTestServer(t, cfg)
+	defer cleanup()
+
+	stream, err := client.DetectSpeech(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := stream.Send(&napv1.DetectSpeechRequest{
+		PcmData: make([]byte, 640),
+		Format:  &napv1.AudioFormat{SampleRate: 4000},
+	}); err != nil {
+		t.Fatal(err)
+	}
+	stream.CloseSend()
+
+	if _, err := stream.Recv(); status.Code(err) != codes.InvalidArgument {
+		t.Fatalf("Recv() error = %v, want InvalidArgument", err)
+	}
+}
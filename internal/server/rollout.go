@@ -0,0 +1,37 @@
+package server
+
+import (
+	"hash/fnv"
+	"math/rand/v2"
+)
+
+// Rollout variants. Every stream is assigned exactly one; it labels the
+// stream's metrics and log lines so tuning changes can be compared live.
+const (
+	VariantBaseline  = "baseline"
+	VariantCandidate = "candidate"
+)
+
+// chooseVariant assigns a stream to the baseline or candidate parameter set.
+// Streams carrying a session ID are bucketed by its hash, so every stream of a
+// session lands in the same variant; anonymous streams are bucketed randomly.
+func chooseVariant(sessionID string, percent int) string {
+	if percent <= 0 {
+		return VariantBaseline
+	}
+	if percent >= 100 {
+		return VariantCandidate
+	}
+	var bucket int
+	if sessionID != "" {
+		h := fnv.New32a()
+		h.Write([]byte(sessionID))
+		bucket = int(h.Sum32() % 100)
+	} else {
+		bucket = rand.IntN(100)
+	}
+	if bucket < percent {
+		return VariantCandidate
+	}
+	return VariantBaseline
+}
@@ -0,0 +1,112 @@
+package server
+
+import (
+	"context"
+	"io"
+	"testing"
+
+	napv1 "github.com/nupi-ai/nupi/api/nap/v1"
+
+	"github.com/nupi-ai/plugin-vad-local-silero/internal/config"
+	"github.com/nupi-ai/plugin-vad-local-silero/internal/engine"
+)
+
+func TestChooseVariantBounds(t *testing.T) {
+	for i := 0; i < 100; i++ {
+		if v := chooseVariant("", 0); v != VariantBaseline {
+			t.Fatalf("percent=0: got %q, want %q", v, VariantBaseline)
+		}
+		if v := chooseVariant("", 100); v != VariantCandidate {
+			t.Fatalf("percent=100: got %q, want %q", v, VariantCandidate)
+		}
+	}
+}
+
+func TestChooseVariantStickyPerSession(t *testing.T) {
+	// The same session must always land in the same variant so that all of
+	// its streams are evaluated with one parameter set.
+	for _, session := range []string{"a", "session-42", "user/7/call/3"} {
+		first := chooseVariant(session, 50)
+		for i := 0; i < 20; i++ {
+			if got := chooseVariant(session, 50); got != first {
+				t.Fatalf("session %q: variant changed from %q to %q", session, first, got)
+			}
+		}
+	}
+}
+
+func TestChooseVariantDistribution(t *testing.T) {
+	const n = 10000
+	candidates := 0
+	for i := 0; i < n; i++ {
+		if chooseVariant("", 20) == VariantCandidate {
+			candidates++
+		}
+	}
+	// 20% ± 3% is far outside any realistic random deviation for n=10000.
+	if candidates < n*17/100 || candidates > n*23/100 {
+		t.Errorf("candidate share = %d/%d, want ~20%%", candidates, n)
+	}
+}
+
+func TestDetectSpeechCandidateVariantApplied(t *testing.T) {
+	// All streams use the candidate, whose min_speech_duration_ms (1100 ms =
+	// 55 stub frames) exceeds the stub's 50-frame speech burst. The baseline
+	// (20 ms) would emit START; the candidate must not.
+	minSpeech := 1100
+	cfg := config.Config{
+		Threshold:            0.5,
+		MinSpeechDurationMs:  20,
+		MinSilenceDurationMs: 20,
+		Candidate:            config.VADParams{MinSpeechDurationMs: &minSpeech},
+		CandidatePercent:     100,
+	}
+	srv := New(cfg, nil, func() engine.Engine { return engine.NewStubEngine() })
+	client, cleanup := startTestServerWith(t, srv)
+	defer cleanup()
+
+	stream, err := client.DetectSpeech(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	chunk := make([]byte, 640)
+	for i := 0; i < engine.StubToggleInterval*3-1; i++ {
+		if err := stream.Send(&napv1.DetectSpeechRequest{
+			PcmData:   chunk,
+			Format:    &napv1.AudioFormat{SampleRate: 16000},
+			SessionId: "candidate-session",
+		}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	stream.CloseSend()
+
+	var events int
+	for {
+		_, err := stream.Recv()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		events++
+	}
+	if events != 0 {
+		t.Errorf("got %d events, want 0 with candidate min_speech_duration_ms", events)
+	}
+
+	snap := srv.Metrics().Snapshot()
+	if got := snap[`vad_streams_total{variant="candidate"}`]; got != 1 {
+		t.Errorf("candidate streams = %d, want 1 (snapshot: %v)", got, snap)
+	}
+	if got := snap[`vad_streams_total{variant="baseline"}`]; got != 0 {
+		t.Errorf("baseline streams = %d, want 0", got)
+	}
+	if got := snap[`vad_frames_total{variant="candidate"}`]; got != int64(engine.StubToggleInterval*3-1) {
+		t.Errorf("candidate frames = %d, want %d", got, engine.StubToggleInterval*3-1)
+	}
+	if got := snap[`vad_speech_events_total{variant="candidate"}`]; got != int64(events) {
+		t.Errorf("candidate events = %d, want %d", got, events)
+	}
+}
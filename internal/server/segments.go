@@ -0,0 +1,92 @@
+package server
+
+import (
+	"fmt"
+	"strconv"
+	"time"
+
+	"google.golang.org/grpc/metadata"
+)
+
+// SpeechEvent carries a single timestamp, so the segments of a stream are
+// reported in its trailer: one SegmentTrailer value per closed segment, in
+// order, as "start_ms=<n>,end_ms=<n>,duration_ms=<n>". Offsets are audio time
+// relative to the first PCM sample of the stream, so they are exact however
+// late the events were delivered.
+const (
+	SegmentTrailer      = "x-nupi-vad-segment"
+	SegmentCountTrailer = "x-nupi-vad-segment-count"
+)
+
+// SummaryTrailer carries the stream's totals, so callers can log quality
+// stats without counting events: "audio_ms=<n>,segments=<n>,speech_ms=<n>,
+// mean_confidence=<f>". audio_ms is the audio inferred, speech_ms the part of
+// it inside closed segments (including the END flushed when the client closes
+// the stream) and mean_confidence the mean raw speech probability over all
+// frames. It is sent with every stream that received audio.
+const SummaryTrailer = "x-nupi-vad-summary"
+
+// maxTrailerSegments bounds the trailer size for very long streams. Later
+// segments are still counted in SegmentCountTrailer.
+const maxTrailerSegments = 512
+
+// segment is a closed speech segment as offsets from the stream start.
+type segment struct {
+	start, end time.Duration
+}
+
+func (sg segment) String() string {
+	return fmt.Sprintf("start_ms=%d,end_ms=%d,duration_ms=%d",
+		sg.start.Milliseconds(), sg.end.Milliseconds(), (sg.end - sg.start).Milliseconds())
+}
+
+// segmentLog pairs the START and END events of a stream into segments.
+type segmentLog struct {
+	open     bool
+	start    time.Duration
+	segments []segment
+	count    int
+	// speech is the total duration of the closed segments.
+	speech time.Duration
+}
+
+// started records a SPEECH_START at offset.
+func (l *segmentLog) started(offset time.Duration) {
+	l.open = true
+	l.start = offset
+}
+
+// ended records a SPEECH_END at offset and returns the closed segment.
+func (l *segmentLog) ended(offset time.Duration) (segment, bool) {
+	if !l.open {
+		return segment{}, false
+	}
+	l.open = false
+	sg := segment{start: l.start, end: offset}
+	l.count++
+	l.speech += sg.end - sg.start
+	if len(l.segments) < maxTrailerSegments {
+		l.segments = append(l.segments, sg)
+	}
+	return sg, true
+}
+
+// summaryTrailer returns the SummaryTrailer and EventCountTrailer metadata
+// for sum.
+func summaryTrailer(sum StreamSummary) metadata.MD {
+	return metadata.Pairs(
+		SummaryTrailer, fmt.Sprintf("audio_ms=%d,segments=%d,speech_ms=%d,mean_confidence=%.4f",
+			sum.AudioDuration.Milliseconds(), sum.Segments, sum.SpeechDuration.Milliseconds(), sum.MeanConfidence),
+		EventCountTrailer, strconv.FormatInt(sum.Events, 10),
+	)
+}
+
+// trailer returns the stream trailer describing the closed segments. A
+// stream that never received audio has no trailer.
+func (l *segmentLog) trailer() metadata.MD {
+	md := metadata.Pairs(SegmentCountTrailer, strconv.Itoa(l.count))
+	for _, sg := range l.segments {
+		md.Append(SegmentTrailer, sg.String())
+	}
+	return md
+}
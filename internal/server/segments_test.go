@@ -0,0 +1,123 @@
+package server
+
+import (
+	"context"
+	"io"
+	"strconv"
+	"testing"
+
+	napv1 "github.com/nupi-ai/nupi/api/nap/v1"
+
+	"github.com/nupi-ai/plugin-vad-local-silero/internal/config"
+)
+
+func TestDetectSpeechSegmentTrailer(t *testing.T) {
+	// StubEngine speaks for frames 49-98 (20ms each).
+	tests := []struct {
+		name    string
+		chunks  int
+		cfg     string
+		want    []string
+		summary string
+	}{
+		{
+			name:    "end by silence",
+			chunks:  149,
+			want:    []string{"start_ms=980,end_ms=1980,duration_ms=1000"},
+			summary: "audio_ms=2980,segments=1,speech_ms=1000,mean_confidence=0.3685",
+		},
+		{
+			name:    "end flushed at EOF",
+			chunks:  70,
+			want:    []string{"start_ms=980,end_ms=1400,duration_ms=420"},
+			summary: "audio_ms=1400,segments=1,speech_ms=420,mean_confidence=0.3400",
+		},
+		{
+			name:   "forced splits",
+			chunks: 149,
+			cfg:    `{"max_speech_duration_ms":400}`,
+			want: []string{
+				"start_ms=980,end_ms=1380,duration_ms=400",
+				"start_ms=1380,end_ms=1780,duration_ms=400",
+				"start_ms=1780,end_ms=1980,duration_ms=200",
+			},
+			summary: "audio_ms=2980,segments=3,speech_ms=1000,mean_confidence=0.3685",
+		},
+		{
+			name:    "no speech",
+			chunks:  40,
+			summary: "audio_ms=800,segments=0,speech_ms=0,mean_confidence=0.1000",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := config.Config{
+				Threshold:            0.5,
+				MinSpeechDurationMs:  20,
+				MinSilenceDurationMs: 20,
+			}
+			client, cleanup := startTestServer(t, cfg)
+			defer cleanup()
+
+			stream, err := client.DetectSpeech(context.Background())
+			if err != nil {
+				t.Fatal(err)
+			}
+			for i := 0; i < tt.chunks; i++ {
+				req := &napv1.DetectSpeechRequest{
+					PcmData: make([]byte, 640),
+					Format:  &napv1.AudioFormat{SampleRate: 16000},
+				}
+				if i == 0 {
+					req.ConfigJson = tt.cfg
+				}
+				if err := stream.Send(req); err != nil {
+					t.Fatal(err)
+				}
+			}
+			stream.CloseSend()
+			for {
+				if _, err := stream.Recv(); err == io.EOF {
+					break
+				} else if err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			trailer := stream.Trailer()
+			got := trailer.Get(SegmentTrailer)
+			if len(got) != len(tt.want) {
+				t.Fatalf("segments = %q, want %q", got, tt.want)
+			}
+			for i := range tt.want {
+				if got[i] != tt.want[i] {
+					t.Errorf("segment %d = %q, want %q", i, got[i], tt.want[i])
+				}
+			}
+			if count := trailer.Get(SegmentCountTrailer); len(count) != 1 || count[0] != strconv.Itoa(len(tt.want)) {
+				t.Errorf("%s = %q, want %d", SegmentCountTrailer, count, len(tt.want))
+			}
+			if summary := trailer.Get(SummaryTrailer); len(summary) != 1 || summary[0] != tt.summary {
+				t.Errorf("%s = %q, want %q", SummaryTrailer, summary, tt.summary)
+			}
+		})
+	}
+}
+
+func TestSegmentLogCapsTrailer(t *testing.T) {
+	var l segmentLog
+	for i := 0; i < maxTrailerSegments+10; i++ {
+		l.started(0)
+		l.ended(1)
+	}
+	md := l.trailer()
+	if got := len(md.Get(SegmentTrailer)); got != maxTrailerSegments {
+		t.Errorf("trailer segments = %d, want %d", got, maxTrailerSegments)
+	}
+	if got := md.Get(SegmentCountTrailer); got[0] != strconv.Itoa(maxTrailerSegments+10) {
+		t.Errorf("segment count = %q, want %d", got, maxTrailerSegments+10)
+	}
+	if _, ok := l.ended(2); ok {
+		t.Error("ended() without an open segment must not record one")
+	}
+}
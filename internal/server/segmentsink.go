@@ -0,0 +1,309 @@
+package server
+
+import (
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/nupi-ai/plugin-vad-local-silero/internal/config"
+	"github.com/nupi-ai/plugin-vad-local-silero/internal/metrics"
+)
+
+// SegmentAudio is the audio of a closed speech segment: the s16le mono PCM
+// the client sent between Start and End, audio offsets from the stream's
+// first sample that include the pre-roll.
+type SegmentAudio struct {
+	SessionID  string
+	StreamID   string
+	Start      time.Duration
+	End        time.Duration
+	SampleRate uint32
+	PCM        []byte
+}
+
+// SegmentSink receives the audio of closed speech segments. Publish is
+// called from a single goroutine, never from a stream's.
+type SegmentSink interface {
+	Publish(seg SegmentAudio) error
+}
+
+// segmentHeader describes a published segment: the JSON sidecar of a WAV
+// file, or the first line sent to a socket.
+type segmentHeader struct {
+	SessionID  string `json:"session_id"`
+	StreamID   string `json:"stream_id"`
+	StartMs    int64  `json:"start_ms"`
+	EndMs      int64  `json:"end_ms"`
+	SampleRate uint32 `json:"sample_rate"`
+	Encoding   string `json:"encoding"` // always "s16le"
+	Bytes      int    `json:"bytes"`
+}
+
+func newSegmentHeader(seg SegmentAudio) segmentHeader {
+	return segmentHeader{
+		SessionID:  seg.SessionID,
+		StreamID:   seg.StreamID,
+		StartMs:    seg.Start.Milliseconds(),
+		EndMs:      seg.End.Milliseconds(),
+		SampleRate: seg.SampleRate,
+		Encoding:   "s16le",
+		Bytes:      len(seg.PCM),
+	}
+}
+
+// NewSegmentSink returns the sink of a segment_sink target (see
+// config.Config.SegmentSinkTarget). A directory must exist; a socket is
+// only dialled when a segment is published.
+func NewSegmentSink(kind, path string) (SegmentSink, error) {
+	switch kind {
+	case config.SegmentSinkDir:
+		info, err := os.Stat(path)
+		if err != nil {
+			return nil, fmt.Errorf("segment_sink: %w", err)
+		}
+		if !info.IsDir() {
+			return nil, fmt.Errorf("segment_sink: %s is not a directory", path)
+		}
+		return segmentDirSink{dir: path}, nil
+	case config.SegmentSinkUnix:
+		return segmentSocketSink{path: path, timeout: segmentSinkTimeout}, nil
+	default:
+		return nil, fmt.Errorf("segment_sink: unknown kind %q", kind)
+	}
+}
+
+// segmentDirSink writes each segment as <id>-<n>.wav, with its header in
+// <id>-<n>.json. The WAV file is renamed into place last, so a consumer
+// watching for it finds both complete.
+type segmentDirSink struct {
+	dir string
+}
+
+func (d segmentDirSink) Publish(seg SegmentAudio) error {
+	base := filepath.Join(d.dir, traceFileBase(seg.StreamID, seg.SessionID))
+	header, err := json.Marshal(newSegmentHeader(seg))
+	if err != nil {
+		return err
+	}
+	if err := os.WriteFile(base+".json", append(header, '\n'), 0o644); err != nil {
+		return err
+	}
+	tmp := base + ".wav.partial"
+	data := append(wavHeader(seg.SampleRate, uint32(len(seg.PCM))), seg.PCM...)
+	if err := os.WriteFile(tmp, data, 0o644); err != nil {
+		os.Remove(tmp)
+		return err
+	}
+	return os.Rename(tmp, base+".wav")
+}
+
+// segmentSinkTimeout bounds connecting to and writing a segment to a socket.
+const segmentSinkTimeout = 5 * time.Second
+
+// segmentSocketSink sends each segment over a new connection to a Unix
+// socket: its header as one JSON line, then its PCM, then it closes the
+// connection.
+type segmentSocketSink struct {
+	path    string
+	timeout time.Duration
+}
+
+func (u segmentSocketSink) Publish(seg SegmentAudio) error {
+	conn, err := net.DialTimeout("unix", u.path, u.timeout)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	conn.SetWriteDeadline(time.Now().Add(u.timeout))
+	header, err := json.Marshal(newSegmentHeader(seg))
+	if err != nil {
+		return err
+	}
+	if _, err := conn.Write(append(header, '\n')); err != nil {
+		return err
+	}
+	if _, err := conn.Write(seg.PCM); err != nil {
+		return err
+	}
+	return conn.Close()
+}
+
+// segmentSinkQueue is how many segments may wait for the sink before new
+// ones are dropped.
+const segmentSinkQueue = 64
+
+// WithSegmentSink publishes the audio of every closed speech segment to
+// sink. Publishing runs on its own goroutine, so a slow sink never delays a
+// stream; CloseSegmentSink waits for the queued segments.
+func WithSegmentSink(sink SegmentSink) Option {
+	return func(s *Server) {
+		if sink != nil {
+			s.segmentSink = &segmentPublisher{
+				sink:  sink,
+				queue: make(chan SegmentAudio, segmentSinkQueue),
+				done:  make(chan struct{}),
+			}
+		}
+	}
+}
+
+// segmentPublisher hands closed segments to the sink in order. closed,
+// under mu, drops the segments of streams still running after
+// CloseSegmentSink.
+type segmentPublisher struct {
+	sink      SegmentSink
+	mu        sync.Mutex
+	closed    bool
+	queue     chan SegmentAudio
+	done      chan struct{}
+	log       *slog.Logger
+	published *metrics.Counter
+	errors    *metrics.Counter
+	queueFull *metrics.Counter
+	tooLong   *metrics.Counter
+	guard     *panicGuard
+}
+
+func (p *segmentPublisher) start(reg *metrics.Registry, logger *slog.Logger) {
+	p.log = logger
+	p.guard = newPanicGuard(reg, logger)
+	p.published = reg.Counter("vad_segment_sink_published_total")
+	p.errors = reg.Counter("vad_segment_sink_errors_total")
+	p.queueFull = reg.Counter("vad_segment_sink_dropped_total", "reason", "queue_full")
+	p.tooLong = reg.Counter("vad_segment_sink_dropped_total", "reason", "too_long")
+	go p.run()
+}
+
+func (p *segmentPublisher) run() {
+	defer close(p.done)
+	for seg := range p.queue {
+		p.deliver(seg)
+	}
+}
+
+// deliver publishes seg. A panic in the sink drops only seg: the worker
+// serves every stream and keeps running.
+func (p *segmentPublisher) deliver(seg SegmentAudio) {
+	defer p.guard.recoverTo(nil, "segment sink")
+	if err := p.sink.Publish(seg); err != nil {
+		p.errors.Inc()
+		p.log.Warn("segment sink publish failed, segment dropped",
+			"session_id", seg.SessionID,
+			"stream_id", seg.StreamID,
+			"start_ms", seg.Start.Milliseconds(),
+			"error", err,
+		)
+		return
+	}
+	p.published.Inc()
+}
+
+// publish queues seg, dropping it when the queue is full.
+func (p *segmentPublisher) publish(seg SegmentAudio) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.closed {
+		return
+	}
+	select {
+	case p.queue <- seg:
+	default:
+		p.queueFull.Inc()
+		p.log.Warn("segment sink queue full, segment dropped",
+			"session_id", seg.SessionID,
+			"stream_id", seg.StreamID,
+			"start_ms", seg.Start.Milliseconds(),
+		)
+	}
+}
+
+// CloseSegmentSink publishes the queued segments and stops the segment
+// sink; segments closed afterwards are dropped. It is a no-op without a
+// sink.
+func (s *Server) CloseSegmentSink() {
+	if s.segmentSink == nil {
+		return
+	}
+	p := s.segmentSink
+	p.mu.Lock()
+	if !p.closed {
+		p.closed = true
+		close(p.queue)
+	}
+	p.mu.Unlock()
+	<-p.done
+}
+
+// segmentAudioMaxBytes is the most PCM kept for one segment, about 5
+// minutes at 16 kHz; longer segments are not published.
+const segmentAudioMaxBytes = 10 << 20
+
+// segmentRecorder keeps a stream's recent PCM so the audio of each segment
+// can be cut out when it ends. While no segment is open it keeps only what
+// a START may still reach back to: the pre-roll, min_speech_duration_ms and
+// a margin for smoothing.
+type segmentRecorder struct {
+	rate uint32
+	keep int64 // bytes kept before the latest chunk while no segment is open
+
+	buf  []byte
+	base int64 // stream byte offset of buf[0]
+
+	open     bool
+	start    int64 // stream byte offset of the open segment's start
+	overflow bool  // the open segment outgrew segmentAudioMaxBytes
+}
+
+func newSegmentRecorder(cfg config.Config, sampleRate uint32) *segmentRecorder {
+	keep := time.Duration(cfg.PrerollMs+cfg.MinSpeechDurationMs)*time.Millisecond + time.Second
+	return &segmentRecorder{rate: sampleRate, keep: pcmBytesAt(keep, sampleRate)}
+}
+
+// pcmBytesAt returns the byte offset of audio offset d in s16le PCM at rate.
+func pcmBytesAt(d time.Duration, rate uint32) int64 {
+	return 2 * (int64(d) * int64(rate) / int64(time.Second))
+}
+
+// write records a chunk of the stream's PCM.
+func (r *segmentRecorder) write(pcm []byte) {
+	if !r.open || r.overflow {
+		if n := int64(len(r.buf)) - r.keep; n > 0 {
+			r.buf = r.buf[:copy(r.buf, r.buf[n:])]
+			r.base += n
+		}
+	}
+	if r.open && !r.overflow && int64(len(r.buf))+int64(len(pcm)) > r.start-r.base+segmentAudioMaxBytes {
+		r.overflow = true
+	}
+	r.buf = append(r.buf, pcm...)
+}
+
+// started records a SPEECH_START at offset.
+func (r *segmentRecorder) started(offset time.Duration) {
+	r.open, r.overflow = true, false
+	r.start = max(pcmBytesAt(offset, r.rate), r.base)
+}
+
+// ended records a SPEECH_END at offset and returns a copy of the segment's
+// PCM. ok is false when no segment was open; tooLong reports a segment
+// dropped for exceeding segmentAudioMaxBytes.
+func (r *segmentRecorder) ended(offset time.Duration) (pcm []byte, ok, tooLong bool) {
+	if !r.open {
+		return nil, false, false
+	}
+	r.open = false
+	if r.overflow {
+		return nil, false, true
+	}
+	from := r.start - r.base
+	to := min(pcmBytesAt(offset, r.rate)-r.base, int64(len(r.buf)))
+	if to < from {
+		to = from
+	}
+	return append([]byte(nil), r.buf[from:to]...), true, false
+}
@@ -0,0 +1,181 @@
+package server
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"net"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	napv1 "github.com/nupi-ai/nupi/api/nap/v1"
+
+	"github.com/nupi-ai/plugin-vad-local-silero/internal/config"
+	"github.com/nupi-ai/plugin-vad-local-silero/internal/engine"
+)
+
+// memorySink collects the published segments.
+type memorySink struct {
+	mu       sync.Mutex
+	segments []SegmentAudio
+}
+
+func (m *memorySink) Publish(seg SegmentAudio) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.segments = append(m.segments, seg)
+	return nil
+}
+
+func TestSegmentSinkPublishesSegmentAudio(t *testing.T) {
+	cfg := config.Config{
+		Threshold:            0.5,
+		MinSpeechDurationMs:  20,
+		MinSilenceDurationMs: 20,
+		PrerollMs:            100,
+	}
+	sink := &memorySink{}
+	srv := New(cfg, nil, func() engine.Engine { return engine.NewStubEngine() }, WithSegmentSink(sink))
+	client, cleanup := startTestServerWith(t, srv)
+	defer cleanup()
+
+	stream, err := client.DetectSpeech(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Each 20 ms chunk holds its index, so the published audio shows which
+	// chunks it was cut from.
+	const chunks = engine.StubToggleInterval*3 - 1
+	for i := 0; i < chunks; i++ {
+		if err := stream.Send(&napv1.DetectSpeechRequest{
+			PcmData: bytes.Repeat([]byte{byte(i)}, 640),
+			Format:  &napv1.AudioFormat{SampleRate: 16000},
+		}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	stream.CloseSend()
+	for {
+		if _, err := stream.Recv(); err == io.EOF {
+			break
+		} else if err != nil {
+			t.Fatal(err)
+		}
+	}
+	srv.CloseSegmentSink()
+
+	if len(sink.segments) != 1 {
+		t.Fatalf("published %d segments, want 1", len(sink.segments))
+	}
+	seg := sink.segments[0]
+	if seg.SampleRate != 16000 || seg.End <= seg.Start {
+		t.Fatalf("segment = %v-%v at %d Hz", seg.Start, seg.End, seg.SampleRate)
+	}
+	if want := int(pcmBytesAt(seg.End, 16000) - pcmBytesAt(seg.Start, 16000)); len(seg.PCM) != want {
+		t.Errorf("segment holds %d bytes, want %d for %v", len(seg.PCM), want, seg.End-seg.Start)
+	}
+	// The stub speaks from chunk 49; pre-roll reaches back 5 chunks.
+	if first, want := int(seg.PCM[0]), engine.StubToggleInterval-1-5; first != want {
+		t.Errorf("segment starts in chunk %d, want %d (pre-roll)", first, want)
+	}
+	if got := srv.Metrics().Snapshot()["vad_segment_sink_published_total"]; got != 1 {
+		t.Errorf("published = %d, want 1", got)
+	}
+}
+
+func TestSegmentRecorderDropsLongSegments(t *testing.T) {
+	r := newSegmentRecorder(config.Config{}, 16000)
+	r.started(0)
+	chunk := make([]byte, 1<<20)
+	for i := 0; i <= segmentAudioMaxBytes>>20; i++ {
+		r.write(chunk)
+	}
+	if _, ok, tooLong := r.ended(time.Minute); ok || !tooLong {
+		t.Errorf("ended: ok %v, tooLong %v; want the segment dropped as too long", ok, tooLong)
+	}
+	// The buffer shrinks back once the segment is closed.
+	r.write(chunk[:640])
+	if int64(len(r.buf)) > r.keep+640 {
+		t.Errorf("recorder keeps %d bytes after the segment, want at most %d", len(r.buf), r.keep+640)
+	}
+}
+
+func TestSegmentDirSink(t *testing.T) {
+	dir := t.TempDir()
+	sink, err := NewSegmentSink(config.SegmentSinkDir, dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	seg := SegmentAudio{SessionID: "s", StreamID: "a/b", Start: time.Second, End: 2 * time.Second, SampleRate: 8000, PCM: []byte{1, 2, 3, 4}}
+	if err := sink.Publish(seg); err != nil {
+		t.Fatal(err)
+	}
+	wavs, _ := filepath.Glob(filepath.Join(dir, "a_b-*.wav"))
+	if len(wavs) != 1 {
+		t.Fatalf("WAV files = %v, want one", wavs)
+	}
+	data, err := os.ReadFile(wavs[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(data[wavHeaderSize:], seg.PCM) || !bytes.Equal(data[:wavHeaderSize], wavHeader(8000, 4)) {
+		t.Errorf("WAV file = %v", data)
+	}
+	var header segmentHeader
+	sidecar, err := os.ReadFile(wavs[0][:len(wavs[0])-len(".wav")] + ".json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := json.Unmarshal(sidecar, &header); err != nil || header.StartMs != 1000 || header.EndMs != 2000 || header.StreamID != "a/b" {
+		t.Errorf("sidecar = %s, %v", sidecar, err)
+	}
+
+	if _, err := NewSegmentSink(config.SegmentSinkDir, filepath.Join(dir, "missing")); err == nil {
+		t.Error("accepted a missing directory")
+	}
+}
+
+func TestSegmentSocketSink(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "segments.sock")
+	ln, err := net.Listen("unix", path)
+	if err != nil {
+		t.Skipf("unix sockets unavailable: %v", err)
+	}
+	defer ln.Close()
+	received := make(chan []byte, 1)
+	go func() {
+		conn, err := ln.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		data, _ := io.ReadAll(conn)
+		received <- data
+	}()
+
+	sink, err := NewSegmentSink(config.SegmentSinkUnix, path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	seg := SegmentAudio{StreamID: "x", End: 20 * time.Millisecond, SampleRate: 16000, PCM: []byte("pcm!")}
+	if err := sink.Publish(seg); err != nil {
+		t.Fatal(err)
+	}
+	r := bufio.NewReader(bytes.NewReader(<-received))
+	line, err := r.ReadBytes('\n')
+	if err != nil {
+		t.Fatal(err)
+	}
+	var header segmentHeader
+	if err := json.Unmarshal(line, &header); err != nil || header.Bytes != 4 || header.Encoding != "s16le" {
+		t.Errorf("header = %s, %v", line, err)
+	}
+	if pcm, _ := io.ReadAll(r); string(pcm) != "pcm!" {
+		t.Errorf("PCM = %q", pcm)
+	}
+}
@@ -6,22 +6,30 @@ import (
 	"fmt"
 	"io"
 	"log/slog"
+	"math"
+	"strconv"
 	"strings"
+	"sync"
+	"sync/atomic"
 	"time"
 
 	napv1 "github.com/nupi-ai/nupi/api/nap/v1"
-	"google.golang.org/grpc/codes"
-	"google.golang.org/grpc/status"
-	"google.golang.org/protobuf/types/known/timestamppb"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
 
+	"github.com/nupi-ai/plugin-vad-local-silero/internal/audio"
+	"github.com/nupi-ai/plugin-vad-local-silero/internal/audio/dsp"
 	"github.com/nupi-ai/plugin-vad-local-silero/internal/config"
 	"github.com/nupi-ai/plugin-vad-local-silero/internal/engine"
+	"github.com/nupi-ai/plugin-vad-local-silero/internal/errs"
+	"github.com/nupi-ai/plugin-vad-local-silero/internal/metrics"
 )
 
-// MaxPCMChunkBytes limits the size of a single PCM chunk to prevent
-// memory spikes from oversized messages. 1 MB ≈ 32 seconds at 16 kHz mono s16le.
-// This is also enforced at gRPC transport level via MaxRecvMsgSize.
-const MaxPCMChunkBytes = 1 << 20
+// MaxPCMChunkBytes is the default limit on the size of a single PCM chunk,
+// preventing memory spikes from oversized messages. 1 MB ≈ 32 seconds at 16
+// kHz mono s16le. max_chunk_bytes changes it; the gRPC transport limit
+// follows via MaxRecvMsgSize.
+const MaxPCMChunkBytes = config.DefaultMaxChunkBytes
 
 // Server implements napv1.VoiceActivityDetectionServiceServer.
 // Each DetectSpeech stream gets its own engine instance and config copy,
@@ -32,83 +40,739 @@ type Server struct {
 	cfg       config.Config
 	log       *slog.Logger
 	newEngine func() engine.Engine
+
+	// engineName is reported by Capabilities; caps caches its result.
+	engineName string
+	capsMu     sync.Mutex
+	caps       *Capabilities
+
+	// configs holds the configs new streams start from: cfg, or with
+	// SetVADParams, cfg with reloaded VAD parameters.
+	configs   atomic.Pointer[streamConfigs]
+	configsMu sync.Mutex
+
+	metrics        *metrics.Registry
+	variantMetrics map[string]*streamMetrics
+
+	newShadowEngine func() engine.Engine
+	shadowMetrics   *shadowMetrics
+
+	// newModelBEngine creates the engines of streams running model "b";
+	// modelMetrics is set, per model, when model_b is configured.
+	newModelBEngine func() engine.Engine
+	modelMetrics    map[string]*modelMetrics
+
+	ignoredMetrics *ignoredFieldMetrics
+	latencyMetrics *latencyMetrics
+
+	// ensembleEngines creates the second engine of ensemble streams.
+	ensembleEngines map[string]func() engine.Engine
+
+	// drift is nil unless drift monitoring is enabled.
+	drift *driftMonitor
+
+	// dumps is nil unless debug_dump_dir is set.
+	dumps *dumpRotator
+
+	// recent holds summaries of the last cfg.RecentStreams closed streams.
+	recent *recentStreams
+
+	// billing is nil unless billing_output is configured.
+	billing *billingWriter
+
+	// segmentSink is nil unless segment_sink is configured.
+	segmentSink *segmentPublisher
+
+	// streams holds the handles of active streams, for TerminateStreams,
+	// and sessions groups those that sent audio by session_id.
+	streamsMu sync.Mutex
+	streams   map[*streamHandle]struct{}
+	sessions  map[string]*session
+
+	// feedback aggregates ReportFeedback calls and holds the per-variant
+	// threshold offsets, seeded from feedbackOffsets.
+	feedback        *feedbackTuner
+	feedbackOffsets map[string]float64
+
+	// watchdog is set when stuck_stream_timeout_s enables RunWatchdog.
+	watchdog *watchdogMetrics
+
+	// queue is set when event_queue_size enables per-stream event queues.
+	queue *queueMetrics
+
+	// budget is set when cpu_budget is enabled; newDowngradeEngine creates
+	// the engine of streams downgraded by it.
+	budget             *budgetMetrics
+	newDowngradeEngine func() engine.Engine
+
+	// inference is set when inference_budget is enabled.
+	inference *inferenceMetrics
+
+	// overloaded counts streams rejected by max_streams.
+	overloaded *metrics.Counter
+	// heartbeats counts heartbeat events sent on quiet streams.
+	heartbeats *metrics.Counter
+
+	// guard recovers panics on the goroutines streams start.
+	guard *panicGuard
+
+	// quiesced rejects new streams (SetQuiesced); quiesceHook observes it.
+	quiesced    atomic.Bool
+	quiesceHook func(quiesced bool)
+}
+
+// Option configures optional Server dependencies.
+type Option func(*Server)
+
+// WithMetrics records server counters in reg instead of a private registry.
+func WithMetrics(reg *metrics.Registry) Option {
+	return func(s *Server) {
+		if reg != nil {
+			s.metrics = reg
+		}
+	}
+}
+
+// streamMetrics holds the counters a stream updates, resolved once per
+// variant so the per-frame path never touches the registry lock.
+type streamMetrics struct {
+	streams      *metrics.Counter
+	errors       *metrics.Counter
+	frames       *metrics.Counter
+	speechFrames *metrics.Counter
+	segments     *metrics.Counter
+	forcedSplits *metrics.Counter
+	events       *metrics.Counter
+	bufferLimit  *metrics.Counter
+}
+
+func newStreamMetrics(reg *metrics.Registry, variant string) *streamMetrics {
+	return &streamMetrics{
+		streams:      reg.Counter("vad_streams_total", "variant", variant),
+		errors:       reg.Counter("vad_stream_errors_total", "variant", variant),
+		frames:       reg.Counter("vad_frames_total", "variant", variant),
+		speechFrames: reg.Counter("vad_speech_frames_total", "variant", variant),
+		segments:     reg.Counter("vad_speech_segments_total", "variant", variant),
+		forcedSplits: reg.Counter("vad_forced_segment_splits_total", "variant", variant),
+		events:       reg.Counter("vad_speech_events_total", "variant", variant),
+		bufferLimit:  reg.Counter("vad_buffer_limit_exceeded_total", "variant", variant),
+	}
 }
 
 // New returns a new Server instance. The newEngine factory is called once per
 // stream to create an isolated engine instance.
-func New(cfg config.Config, logger *slog.Logger, newEngine func() engine.Engine) *Server {
+func New(cfg config.Config, logger *slog.Logger, newEngine func() engine.Engine, opts ...Option) *Server {
 	if logger == nil {
 		logger = slog.Default()
 	}
-	return &Server{
+	s := &Server{
 		cfg:       cfg,
 		log:       logger.With("component", "server"),
 		newEngine: newEngine,
+		metrics:   metrics.NewRegistry(),
+		streams:   make(map[*streamHandle]struct{}),
+		sessions:  make(map[string]*session),
+	}
+	s.configs.Store(newStreamConfigs(cfg))
+	for _, opt := range opts {
+		opt(s)
+	}
+	if s.newShadowEngine == nil {
+		s.newShadowEngine = newEngine
+	}
+	if s.billing != nil {
+		s.billing.log = s.log.With("component", "billing")
+	}
+	if s.segmentSink != nil {
+		s.segmentSink.start(s.metrics, s.log.With("component", "segment_sink"))
+	}
+	s.shadowMetrics = newShadowMetrics(s.metrics)
+	s.ignoredMetrics = newIgnoredFieldMetrics(s.metrics)
+	s.latencyMetrics = newLatencyMetrics(s.metrics)
+	s.recent = newRecentStreams(cfg.RecentStreams)
+	s.overloaded = s.metrics.Counter("vad_streams_rejected_total", "reason", "overload")
+	s.heartbeats = s.metrics.Counter("vad_heartbeats_total")
+	s.guard = newPanicGuard(s.metrics, s.log)
+	s.feedback = newFeedbackTuner(cfg.Feedback, s.feedbackOffsets, s.metrics, s.log.With("component", "feedback"))
+	if cfg.StuckStreamTimeoutSec > 0 {
+		s.watchdog = newWatchdogMetrics(s.metrics)
+	}
+	if cfg.EventQueueSize > 0 {
+		s.queue = newQueueMetrics(s.metrics)
+	}
+	if cfg.CPUBudget.Enabled() {
+		s.budget = newBudgetMetrics(s.metrics)
+	}
+	if cfg.InferenceBudget.Enabled() {
+		s.inference = newInferenceMetrics(s.metrics)
 	}
+	if cfg.DriftReportIntervalSec > 0 {
+		s.drift = newDriftMonitor(s.metrics, s.log.With("component", "drift"))
+	}
+	if cfg.DebugDumpDir != "" {
+		s.dumps = newDumpRotator(cfg.DebugDumpDir, cfg.EffectiveDebugDumpMaxTotalBytes(), s.log.With("component", "debug_dump"))
+	}
+	if cfg.ModelB.Enabled() {
+		s.modelMetrics = map[string]*modelMetrics{
+			config.ModelA: newModelMetrics(s.metrics, config.ModelA),
+			config.ModelB: newModelMetrics(s.metrics, config.ModelB),
+		}
+	}
+	s.variantMetrics = map[string]*streamMetrics{
+		VariantBaseline:  newStreamMetrics(s.metrics, VariantBaseline),
+		VariantCandidate: newStreamMetrics(s.metrics, VariantCandidate),
+	}
+	return s
+}
+
+// Metrics returns the registry holding the server's counters.
+func (s *Server) Metrics() *metrics.Registry {
+	return s.metrics
+}
+
+// Config returns the server-wide config streams start from, before any
+// per-stream config_json.
+func (s *Server) Config() config.Config {
+	return s.configs.Load().baseline
 }
 
 // DetectSpeech implements the bidirectional streaming RPC. It receives audio
 // chunks, feeds them to the engine, and applies speech boundary detection to
 // emit START/END/ONGOING events.
-func (s *Server) DetectSpeech(stream napv1.VoiceActivityDetectionService_DetectSpeechServer) error {
+func (s *Server) DetectSpeech(stream napv1.VoiceActivityDetectionService_DetectSpeechServer) (err error) {
+	if s.quiesced.Load() {
+		return TerminationError(ReasonQuiesced, "instance is quiesced for maintenance, open the stream on another instance")
+	}
+	opened := time.Now()
+	var peerAddr string
+	if p, ok := peer.FromContext(stream.Context()); ok && p.Addr != nil {
+		peerAddr = p.Addr.String()
+	}
+	if md := s.streamHeader(); md != nil {
+		// Headers are flushed with the first event (or the final status), so
+		// clients can attribute the stream even when no speech is detected.
+		if err := stream.SetHeader(md); err != nil {
+			s.log.Warn("failed to set response header", "error", err)
+		}
+	}
+
 	// Per-stream state: own config copy + own engine instance.
 	// Engine is created lazily on first PCM to avoid resource waste from idle streams.
-	streamCfg := s.cfg
-	var eng engine.Engine
+	// The base config (baseline or candidate) is chosen on the first request,
+	// before any config_json override is applied on top of it.
+	configs := s.configs.Load()
+	streamCfg := configs.baseline
+	var (
+		variant string
+		sm      *streamMetrics
+		// mm is nil unless model_b is configured.
+		mm *modelMetrics
+	)
+	var (
+		eng    engine.Engine
+		shadow *shadowRunner
+		tracer *frameTracer
+		dump   *audioDump
+		// eofEvents holds the events flushed when the client closes the
+		// stream, for the trace's close record.
+		eofEvents []*napv1.SpeechEvent
+		segments  segmentLog
+		// recorder is nil unless segment_sink is configured.
+		recorder *segmentRecorder
+		// queue is nil unless event_queue_size is set.
+		queue *eventQueue
+		// deadline is nil unless inference_budget is set; it changes the
+		// engine's stride through strides. frame_skip is applied by the
+		// engine itself.
+		deadline *frameDeadline
+		strides  *strideControl
+	)
 	defer func() {
 		if eng != nil {
+			stream.SetTrailer(segments.trailer())
 			eng.Close()
 		}
+		if shadow != nil {
+			shadow.close()
+		}
+		if tracer != nil {
+			tracer.close(eofEvents, err)
+		}
+		if dump != nil {
+			dump.close()
+			s.dumps.release(dump.path, tracer.path)
+		}
+		if err != nil && sm != nil {
+			sm.errors.Inc()
+		}
 	}()
 
 	var (
-		engineReady     bool // engine created and configured
-		formatKnown     bool // audio format validated (at first PCM)
-		bd              *boundaryDetector
-		cachedFormat    *napv1.AudioFormat // cached from any message (for clients that send format before PCM)
-		sampleRate      uint32
-		frameDurationMs int
-		streamStart     time.Time
-		frameCount      int64
-		sessionId       string
-		streamId        string
+		engineReady  bool // engine created and configured
+		formatKnown  bool // audio format validated (at first PCM)
+		bd           *boundaryDetector
+		cachedFormat *napv1.AudioFormat // cached from any message (for clients that send format before PCM)
+		sampleRate   uint32             // client audio rate
+		engineRate   uint32             // rate fed to the engine (differs when resampling)
+		resampler    *audio.Resampler
+		highPass     *dsp.HighPass
+		denoiser     *dsp.Denoiser
+		agc          *dsp.AGC
+		clock        frameClock
+		streamStart  time.Time
+		lastEnd      time.Time // timestamp of the last END sent, bounds pre-roll
+		frameCount   int64
+		probSum      float64 // sum of the raw frame probabilities
+		eventsSent   int64
+		peakBuffered int64  // most PCM bytes held between chunks
+		fedSamples   int64  // samples fed to the engine, at engineRate
+		tail         []byte // the last frame of PCM fed to the engine
+		speech       []bool // per-frame decisions of the current chunk
+		sessionId    string
+		streamId     string
+		streamLog    *slog.Logger // the session's logger with stream_id, from the first PCM
+		ignored      = newIgnoredFieldTracker(s)
+		latency      *latencyRecorder // set by the first chunk with client_sent_at
+		stats        streamStats
+		firstRequest = true
 	)
+	defer func() {
+		if variant == "" {
+			return // no request received
+		}
+		var model string
+		if mm != nil {
+			model = streamModel(streamCfg)
+		}
+		sum := StreamSummary{
+			SessionID:            sessionId,
+			StreamID:             streamId,
+			Variant:              variant,
+			Model:                model,
+			Peer:                 peerAddr,
+			OpenedAt:             opened,
+			ClosedAt:             time.Now(),
+			SampleRate:           sampleRate,
+			Frames:               frameCount,
+			Segments:             segments.count,
+			Events:               eventsSent,
+			PeakBufferedBytes:    peakBuffered,
+			SpeechDuration:       segments.speech,
+			Threshold:            streamCfg.Threshold,
+			NegThreshold:         streamCfg.EffectiveNegThreshold(),
+			MinSpeechDurationMs:  streamCfg.MinSpeechDurationMs,
+			MinSilenceDurationMs: streamCfg.MinSilenceDurationMs,
+			MaxSpeechDurationMs:  streamCfg.MaxSpeechDurationMs,
+			PrerollMs:            streamCfg.PrerollMs,
+			MinGapMs:             streamCfg.MinGapMs,
+		}
+		stats.fill(&sum)
+		if queue != nil {
+			sum.EventsDropped = queue.droppedEvents()
+			sum.Events -= sum.EventsDropped
+		}
+		if latency != nil {
+			sum.Latency = latency.stats()
+			stream.SetTrailer(latencyTrailer(sum.Latency))
+		}
+		if engineReady {
+			if bd.noise != nil && bd.noise.ready() {
+				sum.Threshold, sum.NegThreshold = bd.noise.thresholds()
+			}
+			sum.AudioDuration = clock.offset(frameCount)
+			if frameCount > 0 {
+				sum.MeanConfidence = probSum / float64(frameCount)
+			}
+			stream.SetTrailer(summaryTrailer(sum))
+		}
+		if err != nil {
+			sum.Error = err.Error()
+		}
+		s.logStreamClosed(sum)
+		s.recent.add(sum)
+		if s.billing != nil {
+			s.billing.write(s.newBillingRecord(sum, err))
+		}
+	}()
+
+	// The handle lets TerminateStreams end the stream while the handler is
+	// blocked waiting for the next request.
+	handle := newStreamHandle(opened, peerAddr)
+	if !s.trackStream(handle) {
+		s.overloaded.Inc()
+		s.log.Debug("stream rejected, max_streams reached", "max_streams", s.cfg.MaxStreams, "peer", peerAddr)
+		stream.SetTrailer(metadata.Pairs(RetryPushbackKey, strconv.FormatInt(OverloadRetryDelay.Milliseconds(), 10)))
+		return overloadedError(s.cfg.MaxStreams, OverloadRetryDelay)
+	}
+	defer s.untrackStream(handle)
+	var durationLimit <-chan time.Time
+	if s.cfg.MaxStreamDurationSec > 0 {
+		timer := time.NewTimer(time.Until(opened.Add(time.Duration(s.cfg.MaxStreamDurationSec) * time.Second)))
+		defer timer.Stop()
+		durationLimit = timer.C
+	}
+	done := make(chan struct{})
+	defer close(done)
+	requests := receive(stream, handle, s.cfg.EffectivePipelineDepth(), s.checkChunk, s.guard, done)
+	// With the watchdog, events go through a sender goroutine so a Send the
+	// client never drains can be abandoned. The event queue has its own.
+	send := stream.Send
+	switch {
+	case s.queue != nil:
+		queue = newEventQueue(stream, handle, s.cfg.EventQueueSize, s.cfg.EventQueuePolicy, s.queue, s.guard, done)
+		send = queue.Send
+	case s.watchdog != nil:
+		send = newEventSender(stream, handle, s.guard, done).Send
+	}
+	// Every event sent postpones the next heartbeat.
+	hb := newHeartbeat(time.Now())
+	defer hb.stop()
+	sendEvent := send
+	send = func(evt *napv1.SpeechEvent) error {
+		hb.sent(time.Now())
+		return sendEvent(evt)
+	}
+	// Registered last so it runs first: the summary, trace and billing
+	// defers above see the gRPC status the client gets.
+	defer func() { err = errs.ToStatus(err) }()
+	if queue != nil {
+		// Queued events, such as the END sent before a stream limit error,
+		// reach the client ahead of the status.
+		defer func() {
+			if flushErr := queue.flush(); err == nil {
+				err = flushErr
+			}
+		}()
+	}
+
+	// endOpenSegment sends the END of a segment still open, or held back by
+	// min_gap_ms, when the stream's audio ends (EOF or reset). It returns the
+	// event sent, nil when no segment was open.
+	endOpenSegment := func() (*napv1.SpeechEvent, error) {
+		if bd == nil || !(bd.inSpeech || bd.pendingEnd != nil) {
+			return nil, nil
+		}
+		ts := streamStart.Add(clock.offset(frameCount))
+		evt := newEvent(napv1.SpeechEventType_SPEECH_EVENT_TYPE_END, bd.lastConfidence)
+		if bd.pendingEnd != nil {
+			// An END held for min_gap_ms keeps the frame it was decided on;
+			// frameCount is one past the last frame.
+			evt = bd.pendingEnd
+			ts = streamStart.Add(clock.offset(frameCount - 1 - int64(bd.pendingFrames)))
+		}
+		stampEvent(evt, ts)
+		lastEnd = ts
+		s.segmentEnded(&segments, recorder, ts.Sub(streamStart), sessionId, streamId)
+		if sendErr := send(evt); sendErr != nil {
+			return evt, sendErr
+		}
+		eventsSent++
+		sm.events.Inc()
+		return evt, nil
+	}
+
+	// streamLimitExceeded ends a stream that reached max_stream_duration_s
+	// or max_stream_audio_mb: the END of an open segment is sent first, so
+	// the client's last utterance is closed cleanly.
+	streamLimitExceeded := func(limit, msg string) error {
+		evt, err := endOpenSegment()
+		if evt != nil {
+			eofEvents = append(eofEvents, evt)
+		}
+		if err != nil {
+			return err
+		}
+		s.metrics.Counter("vad_stream_limit_exceeded_total", "limit", limit).Inc()
+		s.log.Warn("stream exceeded its limit",
+			"session_id", sessionId,
+			"stream_id", streamId,
+			"limit", limit,
+			"duration_ms", time.Since(opened).Milliseconds(),
+			"audio_bytes", stats.bytes,
+		)
+		return TerminationError(ReasonStreamLimit, msg)
+	}
+
+	// resetStream starts a new turn on the stream ({"reset": true}): it ends
+	// an open segment, then resets the engine and the boundary detector.
+	// Timestamps keep counting from the stream's first audio.
+	resetStream := func() error {
+		if _, err := endOpenSegment(); err != nil {
+			return err
+		}
+		if err := eng.Reset(); err != nil {
+			return errs.New(errs.ErrInternal, "engine reset failed: %v", err)
+		}
+		// Reset drops the partial frame the engine had buffered. Feeding it
+		// back keeps later frames on the stream's frame grid, so event
+		// timestamps and FrameGeometry.Index stay exact.
+		var partial []byte
+		if n := 2 * (fedSamples - frameCount*clock.frameSamples); n > 0 && n <= int64(len(tail)) {
+			partial = tail[len(tail)-int(n):]
+			if _, err := eng.ProcessChunk(partial, engineRate); err != nil {
+				return errs.New(errs.ErrInternal, "engine reset failed: %v", err)
+			}
+		}
+		prev := bd
+		bd = newBoundaryDetector(streamCfg, clock)
+		bd.inheritNoiseFloor(prev)
+		if shadow != nil {
+			shadow.reset(partial, engineRate)
+		}
+		if tracer != nil {
+			tracer.reset(frameCount)
+		}
+		s.log.Info("stream reset",
+			"session_id", sessionId,
+			"stream_id", streamId,
+			"frame", frameCount,
+		)
+		return nil
+	}
+
+	// updateConfig applies config_json received after audio started. The
+	// boundary detector keeps its state (in speech or not, running counters)
+	// and uses the new parameters from the next frame on.
+	updateConfig := func(configJSON string) error {
+		if strings.TrimSpace(configJSON) == "" {
+			return nil
+		}
+		next := streamCfg
+		if err := applyStreamConfig(configJSON, &next); err != nil {
+			return errs.New(errs.ErrInvalidConfig, "stream config: %v", err)
+		}
+		if next.Model != streamCfg.Model {
+			return errs.New(errs.ErrInvalidConfig, "stream config: model cannot change after audio started")
+		}
+		if next.Ensemble != streamCfg.Ensemble {
+			return errs.New(errs.ErrInvalidConfig, "stream config: ensemble cannot change after audio started")
+		}
+		if next.Denoise != streamCfg.Denoise {
+			return errs.New(errs.ErrInvalidConfig, "stream config: denoise cannot change after audio started")
+		}
+		if next.HighPassHz != streamCfg.HighPassHz {
+			return errs.New(errs.ErrInvalidConfig, "stream config: high_pass_hz cannot change after audio started")
+		}
+		if next.AGC != streamCfg.AGC {
+			return errs.New(errs.ErrInvalidConfig, "stream config: agc cannot change after audio started")
+		}
+		if next.DebugDump != streamCfg.DebugDump {
+			return errs.New(errs.ErrInvalidConfig, "stream config: debug_dump cannot change after audio started")
+		}
+		if next.FrameSkip != streamCfg.FrameSkip {
+			engine.SetFrameSkip(eng, frameSkip(next.FrameSkip, clock))
+		}
+		if streamReset(configJSON) {
+			streamCfg = next
+			return resetStream()
+		}
+		bd.reconfigure(next, streamCfg, clock)
+		streamCfg = next
+		if tracer != nil {
+			tracer.reconfigured(frameCount, streamCfg, bd)
+		}
+		s.log.Info("stream config updated",
+			"session_id", sessionId,
+			"stream_id", streamId,
+			"frame", frameCount,
+			"threshold", streamCfg.Threshold,
+			"neg_threshold", streamCfg.EffectiveNegThreshold(),
+			"min_speech_duration_ms", streamCfg.MinSpeechDurationMs,
+			"min_silence_duration_ms", streamCfg.MinSilenceDurationMs,
+		)
+		return nil
+	}
 
 	// initEngine creates the engine and applies config. Called once on first PCM.
 	initEngine := func() error {
 		if engineReady {
 			return nil
 		}
-		eng = s.newEngine()
+		newEngine, err := s.modelEngine(streamCfg.Model)
+		if err != nil {
+			return err
+		}
+		eng = newEngine()
 		if eng == nil {
-			return status.Error(codes.Internal, "engine creation failed: factory returned nil")
+			return errs.New(errs.ErrInternal, "engine creation failed: factory returned nil")
+		}
+		if streamCfg.Ensemble.Enabled() {
+			ens, err := s.newEnsemble(eng, streamCfg.Ensemble)
+			if err != nil {
+				eng = nil
+				return err
+			}
+			eng = ens
+			s.log.Info("ensemble enabled",
+				"session_id", sessionId,
+				"stream_id", streamId,
+				"ensemble_engine", streamCfg.Ensemble.Engine,
+				"mode", streamCfg.Ensemble.Mode,
+			)
+		}
+		if err := eng.SetSampleRate(engineRate); err != nil {
+			return errs.New(errs.ErrUnsupportedFormat, "unsupported sample_rate %d: %v", engineRate, err)
+		}
+		if s.budget != nil {
+			eng = newBudgetEngine(eng, s.cfg.CPUBudget, s.newDowngradeEngine, func(action string, used, audio time.Duration) {
+				counter := s.budget.terminated
+				if action == config.CPUBudgetDowngrade {
+					counter = s.budget.downgraded
+				}
+				counter.Inc()
+				s.log.Warn("stream exceeded cpu budget",
+					"session_id", sessionId,
+					"stream_id", streamId,
+					"action", action,
+					"inference_ms", used.Milliseconds(),
+					"audio_ms", audio.Milliseconds(),
+				)
+			})
 		}
-		eng.SetThreshold(streamCfg.Threshold)
-		frameDurationMs = eng.FrameDurationMs()
-		if frameDurationMs <= 0 {
-			return status.Errorf(codes.Internal, "engine returned invalid frame duration: %d ms", frameDurationMs)
+		if s.inference != nil {
+			_, strided := eng.(engine.Strided)
+			deadline = newFrameDeadline(s.cfg.InferenceBudget, strided)
+		}
+		if streamCfg.HighPassHz > 0 {
+			h, err := dsp.NewHighPass(float64(streamCfg.HighPassHz), engineRate)
+			if err != nil {
+				return errs.New(errs.ErrUnsupportedFormat, "sample_rate %d: %v", engineRate, err)
+			}
+			highPass = h
+		}
+		if streamCfg.Denoise {
+			d, err := dsp.NewDenoiser(engineRate)
+			if err != nil {
+				return errs.New(errs.ErrUnsupportedFormat, "sample_rate %d: %v", engineRate, err)
+			}
+			denoiser = d
+		}
+		if streamCfg.AGC.Enabled() {
+			a, err := dsp.NewAGC(streamCfg.AGC.TargetDbfs, streamCfg.AGC.EffectiveMaxGainDb(), engineRate)
+			if err != nil {
+				return errs.New(errs.ErrInvalidConfig, "stream config: %v", err)
+			}
+			agc = a
+		}
+		c, err := newFrameClock(eng)
+		if err != nil {
+			return errs.New(errs.ErrInternal, "engine returned %v", err)
+		}
+		clock = c
+		bd = newBoundaryDetector(streamCfg, clock)
+		strides = &strideControl{eng: eng, cur: 1}
+		if streamCfg.FrameSkip.Enabled() {
+			engine.SetFrameSkip(eng, frameSkip(streamCfg.FrameSkip, clock))
 		}
-		bd = newBoundaryDetector(streamCfg, frameDurationMs)
 		engineReady = true
+
+		open := traceOpen{
+			SessionID:         sessionId,
+			StreamID:          streamId,
+			Variant:           variant,
+			SampleRate:        sampleRate,
+			EngineSampleRate:  engineRate,
+			FrameSamples:      int(clock.frameSamples),
+			FrameDurationMs:   durationMs(clock.frameDuration()),
+			Threshold:         streamCfg.Threshold,
+			NegThreshold:      streamCfg.EffectiveNegThreshold(),
+			SmoothingFrames:   streamCfg.SmoothingFrames,
+			SmoothingMethod:   streamCfg.SmoothingMethod,
+			MinSpeechFrames:   bd.minSpeechFrames,
+			MinSilenceFrames:  bd.minSilenceFrames,
+			MaxSpeechFrames:   bd.maxSpeechFrames,
+			MinGapFrames:      bd.gapFrames,
+			AdaptiveSilence:   adaptiveSilenceTrace(streamCfg.AdaptiveSilence),
+			AdaptiveThreshold: adaptiveThresholdTrace(streamCfg.AdaptiveThreshold),
+		}
+		switch {
+		case streamCfg.DebugDump && s.dumps != nil:
+			// A dumped stream's trace goes with its audio, even when
+			// trace_stream_id also selects it.
+			dump, tracer = s.openDebugDump(open, streamLog)
+		case traceSelected(s.cfg, sessionId, streamId):
+			t, err := newFrameTracer(s.cfg.TraceDir, open, streamLog)
+			if err != nil {
+				s.log.Warn("frame trace unavailable, stream continues untraced",
+					"session_id", sessionId,
+					"stream_id", streamId,
+					"error", err,
+				)
+			} else {
+				tracer = t
+			}
+		}
+
+		if shadowSelected(s.cfg.ShadowPercent) {
+			shadowCfg := s.cfg.ShadowConfig(streamCfg)
+			if shadowEng := s.shadowEngine(shadowCfg); shadowEng != nil {
+				shadow = newShadowRunner(shadowEng, shadowCfg, engineRate, clock,
+					streamLog, s.shadowMetrics)
+			} else {
+				s.shadowMetrics.errors.Inc()
+				s.log.Warn("shadow engine creation failed, stream continues without shadow",
+					"session_id", sessionId,
+					"stream_id", streamId,
+				)
+			}
+		}
 		return nil
 	}
 
+	// recvSince is when the stream started waiting for the next request;
+	// heartbeats sent meanwhile do not restart the watchdog's wait.
+	var recvSince time.Time
 	for {
-		req, err := stream.Recv()
+		var (
+			req    *napv1.DetectSpeechRequest
+			recvAt time.Time
+			err    error
+		)
+		if !streamStart.IsZero() {
+			// Not before the first audio: a heartbeat would flush the
+			// response header without the audio epoch.
+			hb.setInterval(streamCfg.HeartbeatIntervalMs, time.Now())
+		}
+		if recvSince.IsZero() {
+			recvSince = time.Now()
+		}
+		handle.begin(opRecv, recvSince)
+		select {
+		case r := <-requests:
+			handle.end()
+			recvSince = time.Time{}
+			req, recvAt, err = r.req, r.at, r.err
+		case now := <-hb.C():
+			if !hb.due(now) {
+				continue
+			}
+			if sendErr := send(newHeartbeatEvent(streamStart.Add(clock.offset(frameCount)))); sendErr != nil {
+				return sendErr
+			}
+			eventsSent++
+			s.heartbeats.Inc()
+			continue
+		case <-handle.stop:
+			reason, _, _ := TerminationReason(handle.cause)
+			s.log.Info("stream terminated by server",
+				"session_id", sessionId,
+				"stream_id", streamId,
+				"reason", reason,
+			)
+			return handle.cause
+		case <-durationLimit:
+			handle.end()
+			return streamLimitExceeded("duration", fmt.Sprintf(
+				"stream exceeded max_stream_duration_s (%d s), open a new stream to continue", s.cfg.MaxStreamDurationSec))
+		}
 		if err != nil {
 			if errors.Is(err, io.EOF) {
 				// Client closed the stream — flush any pending speech end.
-				if bd != nil && bd.inSpeech {
-					ts := streamStart.Add(time.Duration(frameCount) * time.Duration(frameDurationMs) * time.Millisecond)
-					if sendErr := stream.Send(&napv1.SpeechEvent{
-						Type:       napv1.SpeechEventType_SPEECH_EVENT_TYPE_END,
-						Confidence: bd.lastConfidence,
-						Timestamp:  timestamppb.New(ts),
-					}); sendErr != nil {
-						return sendErr
-					}
+				evt, err := endOpenSegment()
+				if evt != nil {
+					eofEvents = append(eofEvents, evt)
 				}
-				return nil
+				return err
 			}
 			return err
 		}
@@ -116,6 +780,15 @@ func (s *Server) DetectSpeech(stream napv1.VoiceActivityDetectionService_DetectS
 			continue
 		}
 
+		if variant == "" {
+			variant = chooseVariant(req.GetSessionId(), s.cfg.CandidatePercent)
+			if variant == VariantCandidate {
+				streamCfg = configs.candidate
+			}
+			streamCfg = s.feedback.tune(variant, streamCfg)
+			sm = s.variantMetrics[variant]
+		}
+
 		// Capture session/stream IDs from any request that provides them.
 		if sessionId == "" {
 			if id := req.GetSessionId(); id != "" {
@@ -127,6 +800,28 @@ func (s *Server) DetectSpeech(stream napv1.VoiceActivityDetectionService_DetectS
 				streamId = id
 			}
 		}
+		if firstRequest {
+			firstRequest = false
+			ignored.metadata(stream.Context(), sessionId, streamId)
+		}
+		if err := ignored.request(req, sessionId, streamId); err != nil {
+			return err
+		}
+
+		// A client send time in config_json measures this message's latency;
+		// the rest of config_json is applied as usual.
+		sentAt, configJSON, err := splitClientSentAt(req.GetConfigJson())
+		if err != nil {
+			return errs.New(errs.ErrInvalidConfig, "stream config: %v", err)
+		}
+		if !sentAt.IsZero() {
+			if latency == nil {
+				latency = newLatencyRecorder(s.latencyMetrics)
+			}
+			latency.network(recvAt.Sub(sentAt))
+		}
+		// Only messages that carry their own send time are measured.
+		measured := !sentAt.IsZero()
 
 		// Cache/update audio format from any message until first PCM.
 		// Only cache formats with sample_rate > 0 to avoid overwriting valid
@@ -137,20 +832,19 @@ func (s *Server) DetectSpeech(stream napv1.VoiceActivityDetectionService_DetectS
 				// Validate format fields before caching to prevent invalid formats
 				// from slipping through when PCM arrives without format.
 				if enc := af.GetEncoding(); enc != "" && enc != "pcm_s16le" {
-					return status.Errorf(codes.InvalidArgument,
+					return errs.New(errs.ErrUnsupportedFormat,
 						"unsupported encoding %q, only pcm_s16le is supported", enc)
 				}
 				if ch := af.GetChannels(); ch != 0 && ch != 1 {
-					return status.Errorf(codes.InvalidArgument,
+					return errs.New(errs.ErrUnsupportedFormat,
 						"unsupported channels %d, only mono (1) is supported", ch)
 				}
 				if bits := af.GetBitDepth(); bits != 0 && bits != 16 {
-					return status.Errorf(codes.InvalidArgument,
+					return errs.New(errs.ErrUnsupportedFormat,
 						"unsupported bit_depth %d, only 16-bit is supported", bits)
 				}
-				if af.GetSampleRate() != engine.ExpectedSampleRate {
-					return status.Errorf(codes.InvalidArgument,
-						"unsupported sample_rate %d, engine requires %d", af.GetSampleRate(), engine.ExpectedSampleRate)
+				if !s.acceptedSampleRate(af.GetSampleRate()) {
+					return s.sampleRateError(af.GetSampleRate())
 				}
 				cachedFormat = af
 			}
@@ -159,19 +853,19 @@ func (s *Server) DetectSpeech(stream napv1.VoiceActivityDetectionService_DetectS
 			// (including empty ones) to catch protocol misuse early.
 			if af := req.GetFormat(); af != nil {
 				if sr := af.GetSampleRate(); sr != 0 && sr != sampleRate {
-					return status.Errorf(codes.InvalidArgument,
+					return errs.New(errs.ErrUnsupportedFormat,
 						"sample_rate changed mid-stream: initial=%d, got=%d", sampleRate, sr)
 				}
 				if enc := af.GetEncoding(); enc != "" && enc != "pcm_s16le" {
-					return status.Errorf(codes.InvalidArgument,
+					return errs.New(errs.ErrUnsupportedFormat,
 						"unsupported encoding %q, only pcm_s16le is supported", enc)
 				}
 				if ch := af.GetChannels(); ch != 0 && ch != 1 {
-					return status.Errorf(codes.InvalidArgument,
+					return errs.New(errs.ErrUnsupportedFormat,
 						"unsupported channels %d, only mono (1) is supported", ch)
 				}
 				if bits := af.GetBitDepth(); bits != 0 && bits != 16 {
-					return status.Errorf(codes.InvalidArgument,
+					return errs.New(errs.ErrUnsupportedFormat,
 						"unsupported bit_depth %d, only 16-bit is supported", bits)
 				}
 			}
@@ -187,15 +881,11 @@ func (s *Server) DetectSpeech(stream napv1.VoiceActivityDetectionService_DetectS
 			// rather than logging and ignoring. This helps clients catch config
 			// bugs early instead of silently using default values.
 			if !engineReady {
-				if err := applyStreamConfig(req.GetConfigJson(), &streamCfg); err != nil {
-					return status.Errorf(codes.InvalidArgument, "stream config: %v", err)
+				if err := applyStreamConfig(configJSON, &streamCfg); err != nil {
+					return errs.New(errs.ErrInvalidConfig, "stream config: %v", err)
 				}
-			} else if cj := req.GetConfigJson(); cj != "" {
-				// Config after audio started is ignored — log warning for debugging.
-				s.log.Warn("config_json ignored after audio started",
-					"session_id", sessionId,
-					"stream_id", streamId,
-				)
+			} else if err := updateConfig(configJSON); err != nil {
+				return err
 			}
 			continue
 		}
@@ -212,21 +902,21 @@ func (s *Server) DetectSpeech(stream napv1.VoiceActivityDetectionService_DetectS
 				// This catches client bugs where format changes between messages.
 				if cachedFormat != nil {
 					if sr := reqFmt.GetSampleRate(); sr != 0 && sr != cachedFormat.GetSampleRate() {
-						return status.Errorf(codes.InvalidArgument,
+						return errs.New(errs.ErrUnsupportedFormat,
 							"sample_rate mismatch: cached=%d, request=%d",
 							cachedFormat.GetSampleRate(), sr)
 					}
 				}
 				if enc := reqFmt.GetEncoding(); enc != "" && enc != "pcm_s16le" {
-					return status.Errorf(codes.InvalidArgument,
+					return errs.New(errs.ErrUnsupportedFormat,
 						"unsupported encoding %q, only pcm_s16le is supported", enc)
 				}
 				if ch := reqFmt.GetChannels(); ch != 0 && ch != 1 {
-					return status.Errorf(codes.InvalidArgument,
+					return errs.New(errs.ErrUnsupportedFormat,
 						"unsupported channels %d, only mono (1) is supported", ch)
 				}
 				if bits := reqFmt.GetBitDepth(); bits != 0 && bits != 16 {
-					return status.Errorf(codes.InvalidArgument,
+					return errs.New(errs.ErrUnsupportedFormat,
 						"unsupported bit_depth %d, only 16-bit is supported", bits)
 				}
 			}
@@ -237,87 +927,357 @@ func (s *Server) DetectSpeech(stream napv1.VoiceActivityDetectionService_DetectS
 				af = req.GetFormat()
 			}
 			if af == nil {
-				return status.Errorf(codes.InvalidArgument,
+				return errs.New(errs.ErrUnsupportedFormat,
 					"audio format required: send format with PCM data or in a prior message")
 			}
 			sampleRate = af.GetSampleRate()
 			if sampleRate == 0 {
-				return status.Errorf(codes.InvalidArgument,
+				return errs.New(errs.ErrUnsupportedFormat,
 					"audio format must include sample_rate")
 			}
 			// Validate against known constant — engine not yet created.
-			if sampleRate != engine.ExpectedSampleRate {
-				return status.Errorf(codes.InvalidArgument,
-					"unsupported sample_rate %d, engine requires %d", sampleRate, engine.ExpectedSampleRate)
+			if !s.acceptedSampleRate(sampleRate) {
+				return s.sampleRateError(sampleRate)
+			}
+			engineRate = sampleRate
+			if !engine.SupportedSampleRate(sampleRate) {
+				// Only reachable with allow_resampling (see acceptedSampleRate).
+				r, err := audio.NewResampler(sampleRate, engine.ExpectedSampleRate)
+				if err != nil {
+					return errs.New(errs.ErrUnsupportedFormat, "sample_rate %d: %v", sampleRate, err)
+				}
+				resampler = r
+				engineRate = engine.ExpectedSampleRate
 			}
 			formatKnown = true
 		}
 
-		// Validate PCM input BEFORE engine creation to prevent DoS via
-		// requests with valid format but invalid PCM (odd length, too large).
-		if len(pcm)%2 != 0 {
-			return status.Errorf(codes.InvalidArgument,
-				"PCM buffer has odd length %d (s16le requires 2 bytes per sample)", len(pcm))
-		}
-		if len(pcm) > MaxPCMChunkBytes {
-			return status.Errorf(codes.InvalidArgument,
-				"PCM chunk too large: %d bytes (max %d)", len(pcm), MaxPCMChunkBytes)
-		}
-
 		// First PCM: finalize config and initialize engine.
 		// Format and PCM already validated above, so engine creation is safe.
 		if !engineReady {
 			// Apply config_json from the first PCM message (if present).
 			// Invalid config returns error intentionally (see NOTE above).
-			if err := applyStreamConfig(req.GetConfigJson(), &streamCfg); err != nil {
-				return status.Errorf(codes.InvalidArgument, "stream config: %v", err)
+			if err := applyStreamConfig(configJSON, &streamCfg); err != nil {
+				return errs.New(errs.ErrInvalidConfig, "stream config: %v", err)
 			}
+			// Concurrent streams of one session share its log context.
+			sessionLog, siblings := s.joinSession(handle, sessionId)
+			streamLog = sessionLog.With("stream_id", streamId)
 			if err := initEngine(); err != nil {
 				return err
 			}
-			s.log.Info("stream opened",
-				"session_id", sessionId,
-				"stream_id", streamId,
+			if s.segmentSink != nil {
+				recorder = newSegmentRecorder(streamCfg, sampleRate)
+			}
+			sm.streams.Inc()
+			mm = s.modelMetrics[streamModel(streamCfg)]
+			if mm != nil {
+				mm.streams.Inc()
+			}
+			handle.ids.Store(&streamIDs{session: sessionId, stream: streamId, variant: variant})
+			attrs := []any{
+				"peer", peerAddr,
 				"sample_rate", sampleRate,
-			)
-		} else if cj := req.GetConfigJson(); cj != "" {
-			// Config after audio started is ignored — log warning for debugging.
-			s.log.Warn("config_json ignored after audio started",
-				"session_id", sessionId,
-				"stream_id", streamId,
-			)
+				"engine_sample_rate", engineRate,
+				"variant", variant,
+			}
+			if mm != nil {
+				attrs = append(attrs, "model", streamModel(streamCfg))
+			}
+			if len(siblings) > 0 {
+				attrs = append(attrs, "sibling_streams", siblings)
+			}
+			streamLog.Info("stream opened", attrs...)
+		} else if err := updateConfig(configJSON); err != nil {
+			return err
 		}
 
 		// Anchor stream clock to the first non-empty PCM chunk.
 		if streamStart.IsZero() {
 			streamStart = time.Now()
+			epoch := metadata.Pairs(
+				AudioEpochHeader, streamStart.UTC().Format(time.RFC3339Nano),
+				FrameGeometryHeader, FrameGeometry{clock.frameSamples, clock.sampleRate}.String(),
+			)
+			if err := stream.SetHeader(epoch); err != nil {
+				s.log.Warn("failed to set audio epoch header",
+					"session_id", sessionId,
+					"stream_id", streamId,
+					"error", err,
+				)
+			}
+		}
+
+		if limit := int64(s.cfg.MaxStreamAudioMB) << 20; limit > 0 && stats.bytes+int64(len(pcm)) > limit {
+			return streamLimitExceeded("audio", fmt.Sprintf(
+				"stream exceeded max_stream_audio_mb (%d MiB), open a new stream to continue", s.cfg.MaxStreamAudioMB))
+		}
+		stats.chunk(len(pcm))
+		if dump != nil {
+			dump.write(pcm)
+		}
+		if recorder != nil {
+			recorder.write(pcm)
+		}
+		if measured {
+			latency.queue(time.Since(recvAt))
+		}
+		enginePCM := pcm
+		if resampler != nil {
+			enginePCM = resampler.Process(pcm)
+		}
+		if highPass != nil {
+			enginePCM = highPass.Process(enginePCM)
+		}
+		if denoiser != nil {
+			enginePCM = denoiser.Process(enginePCM)
+		}
+		if agc != nil {
+			enginePCM = agc.Process(enginePCM)
 		}
 
-		results, err := eng.ProcessChunk(pcm, sampleRate)
+		inferStart := time.Now()
+		results, err := eng.ProcessChunk(enginePCM, engineRate)
+		inferTime := time.Since(inferStart)
+		stats.inferred(inferTime)
+		if measured {
+			latency.inference(inferTime)
+		}
 		if err != nil {
-			s.log.Error("engine error", "error", err)
-			return status.Error(codes.Internal, "audio processing failed")
+			if _, _, ok := TerminationReason(err); ok {
+				return err
+			}
+			s.log.Error("engine error",
+				"session_id", sessionId,
+				"stream_id", streamId,
+				"error", err,
+			)
+			if errors.Is(err, errs.ErrInvalidPCM) {
+				return err
+			}
+			return errs.New(errs.ErrInternal, "audio processing failed")
+		}
+		// A confidence outside [0, 1] would silently corrupt the boundary
+		// detector, so a broken model or custom engine fails the stream.
+		if conf, ok := invalidConfidence(results); ok {
+			s.log.Error("engine returned an invalid confidence",
+				"session_id", sessionId,
+				"stream_id", streamId,
+				"confidence", fmt.Sprint(conf), // NaN is not valid JSON
+			)
+			return errs.New(errs.ErrInternal, "audio processing failed")
 		}
+		if deadline != nil {
+			s.checkDeadline(deadline, strides, inferTime, len(results), sessionId, streamId)
+		}
+		fedSamples += int64(len(enginePCM) / 2)
+		tail = appendTail(tail, enginePCM, 2*int(clock.frameSamples))
 
+		sm.frames.Add(int64(len(results)))
+		handle.frames.Add(int64(len(results)))
+		speech = speech[:0]
 		for _, result := range results {
 			events := bd.process(result)
+			probSum += float64(result.Confidence)
+			speech = append(speech, bd.speech)
+			if bd.speech {
+				sm.speechFrames.Inc()
+			}
+			if s.drift != nil {
+				s.drift.observe(result, bd.speech)
+			}
+			if tracer != nil {
+				tracer.frame(frameCount, clock, result, bd, events)
+			}
+			if streamCfg.EmitProbabilities {
+				prob := newEvent(ProbabilityEventType, result.Confidence)
+				stampEvent(prob, streamStart.Add(clock.offset(frameCount)))
+				if sendErr := send(prob); sendErr != nil {
+					return sendErr
+				}
+				eventsSent++
+			}
 			for _, evt := range events {
+				if evt.Type == napv1.SpeechEventType_SPEECH_EVENT_TYPE_END && bd.inSpeech {
+					// END while the detector is still in speech: a split forced
+					// by max_speech_duration_ms.
+					sm.forcedSplits.Inc()
+				}
+				if evt.Type == napv1.SpeechEventType_SPEECH_EVENT_TYPE_START {
+					sm.segments.Inc()
+					if mm != nil {
+						mm.segments.Inc()
+					}
+					if shadow != nil {
+						shadow.recordPrimarySegment()
+					}
+				}
 				// Timestamp represents AUDIO TIME (position in stream), not wall-clock.
-				// Calculated as: streamStart + (frameIndex * frameDurationMs).
+				// Calculated as: streamStart + frameIndex * frameSamples / sampleRate.
 				// This is the time when the audio frame occurred relative to stream start,
 				// NOT when the event was sent. Under backpressure or large chunks,
 				// timestamps may appear "in the future" relative to event delivery time.
 				// Clients should use these timestamps for audio synchronization, not
 				// as wall-clock event times.
-				ts := streamStart.Add(time.Duration(frameCount) * time.Duration(frameDurationMs) * time.Millisecond)
-				evt.Timestamp = timestamppb.New(ts)
-				if sendErr := stream.Send(evt); sendErr != nil {
+				ts := streamStart.Add(clock.offset(frameCount))
+				// An END held back by min_gap_ms points at the frame it was
+				// decided on.
+				if evt.Type == napv1.SpeechEventType_SPEECH_EVENT_TYPE_END && bd.endLag > 0 {
+					ts = streamStart.Add(clock.offset(frameCount - int64(bd.endLag)))
+				}
+				// With preroll_ms, START points before the speech onset instead.
+				if evt.Type == napv1.SpeechEventType_SPEECH_EVENT_TYPE_START && streamCfg.PrerollMs > 0 {
+					ts = prerollStart(streamStart.Add(clock.offset(frameCount-int64(bd.onsetLag))),
+						streamCfg.PrerollMs, streamStart, lastEnd)
+				}
+				switch evt.Type {
+				case napv1.SpeechEventType_SPEECH_EVENT_TYPE_START:
+					segments.started(ts.Sub(streamStart))
+					if recorder != nil {
+						recorder.started(ts.Sub(streamStart))
+					}
+				case napv1.SpeechEventType_SPEECH_EVENT_TYPE_END:
+					lastEnd = ts
+					s.segmentEnded(&segments, recorder, ts.Sub(streamStart), sessionId, streamId)
+				}
+				stampEvent(evt, ts)
+				if sendErr := send(evt); sendErr != nil {
 					return sendErr
 				}
+				if measured {
+					latency.event(time.Since(recvAt))
+				}
+				eventsSent++
+				sm.events.Inc()
 			}
 			frameCount++
 		}
+		if mm != nil {
+			mm.chunk(speech, inferTime)
+		}
+		if shadow != nil {
+			shadow.process(enginePCM, engineRate, speech, bd.inSpeech)
+		}
+
+		buffered := bufferedPCMBytes(eng, resampler, denoiser, shadow)
+		peakBuffered = max(peakBuffered, buffered)
+		if limit := s.maxBufferedBytes(); buffered > int64(limit) {
+			sm.bufferLimit.Inc()
+			s.log.Warn("stream exceeded buffered audio limit",
+				"session_id", sessionId,
+				"stream_id", streamId,
+				"buffered_bytes", buffered,
+				"max_buffered_bytes", limit,
+			)
+			return TerminationError(ReasonBufferLimit, fmt.Sprintf(
+				"stream holds %d bytes of buffered audio (max %d)", buffered, limit))
+		}
+	}
+}
+
+// streamReset reports whether configJSON carries {"reset": true}. It is
+// called after configJSON was applied, so it is known to be valid.
+func streamReset(configJSON string) bool {
+	var c struct {
+		Reset bool `json:"reset"`
+	}
+	return json.Unmarshal([]byte(configJSON), &c) == nil && c.Reset
+}
+
+// invalidConfidence returns the first confidence in results that is NaN or
+// outside [0, 1].
+func invalidConfidence(results []engine.Result) (float32, bool) {
+	for _, r := range results {
+		if math.IsNaN(float64(r.Confidence)) || r.Confidence < 0 || r.Confidence > 1 {
+			return r.Confidence, true
+		}
+	}
+	return 0, false
+}
+
+// appendTail appends pcm to tail and keeps only the last n bytes.
+func appendTail(tail, pcm []byte, n int) []byte {
+	if len(pcm) >= n {
+		return append(tail[:0], pcm[len(pcm)-n:]...)
+	}
+	tail = append(tail, pcm...)
+	if len(tail) > n {
+		tail = tail[:copy(tail, tail[len(tail)-n:])]
+	}
+	return tail
+}
+
+// segmentEnded closes the open segment at offset and logs it. With a
+// recorder, the segment's audio is published to the segment sink.
+func (s *Server) segmentEnded(segments *segmentLog, recorder *segmentRecorder, offset time.Duration, sessionID, streamID string) {
+	sg, ok := segments.ended(offset)
+	if !ok {
+		return
+	}
+	s.log.Debug("speech segment",
+		"session_id", sessionID,
+		"stream_id", streamID,
+		"start_ms", sg.start.Milliseconds(),
+		"end_ms", sg.end.Milliseconds(),
+		"duration_ms", (sg.end - sg.start).Milliseconds(),
+	)
+	if recorder == nil {
+		return
+	}
+	pcm, ok, tooLong := recorder.ended(offset)
+	switch {
+	case tooLong:
+		s.segmentSink.tooLong.Inc()
+		s.log.Warn("segment too long for the segment sink, not published",
+			"session_id", sessionID,
+			"stream_id", streamID,
+			"start_ms", sg.start.Milliseconds(),
+			"duration_ms", (sg.end - sg.start).Milliseconds(),
+		)
+	case ok:
+		s.segmentSink.publish(SegmentAudio{
+			SessionID:  sessionID,
+			StreamID:   streamID,
+			Start:      sg.start,
+			End:        sg.end,
+			SampleRate: recorder.rate,
+			PCM:        pcm,
+		})
+	}
+}
+
+// prerollStart returns the SPEECH_START timestamp for a speech run whose
+// first frame is at onset: onset minus prerollMs, but never before the stream
+// start or the previous segment's END (zero if none), so segments do not
+// overlap.
+func prerollStart(onset time.Time, prerollMs int, streamStart, lastEnd time.Time) time.Time {
+	ts := onset.Add(-time.Duration(prerollMs) * time.Millisecond)
+	for _, floor := range []time.Time{streamStart, lastEnd} {
+		if ts.Before(floor) {
+			ts = floor
+		}
+	}
+	return ts
+}
+
+// acceptedSampleRate reports whether a stream may send audio at rate: the
+// rates engines support natively, plus any rate the resampler handles when
+// allow_resampling is enabled.
+func (s *Server) acceptedSampleRate(rate uint32) bool {
+	if engine.SupportedSampleRate(rate) {
+		return true
 	}
+	return s.cfg.AllowResampling && rate >= audio.MinSampleRate && rate <= audio.MaxSampleRate
+}
+
+func (s *Server) sampleRateError(rate uint32) error {
+	if s.cfg.AllowResampling {
+		return errs.New(errs.ErrUnsupportedFormat,
+			"unsupported sample_rate %d, resampling supports %d-%d Hz", rate, audio.MinSampleRate, audio.MaxSampleRate)
+	}
+	return errs.New(errs.ErrUnsupportedFormat,
+		"unsupported sample_rate %d, engine requires %d or %d (enable allow_resampling for other rates)",
+		rate, engine.ExpectedSampleRate, engine.TelephonySampleRate)
 }
 
 // applyStreamConfig parses optional JSON config from the first request and
@@ -328,10 +1288,11 @@ func applyStreamConfig(configJSON string, cfg *config.Config) error {
 		return nil
 	}
 	type streamCfg struct {
-		Threshold            *float64 `json:"threshold"`
-		MinSpeechDurationMs  *int     `json:"min_speech_duration_ms"`
-		MinSilenceDurationMs *int     `json:"min_silence_duration_ms"`
-		SpeechPadMs          *int     `json:"speech_pad_ms"` // unsupported, for error only
+		config.VADParams
+		EmitProbabilities   *bool `json:"emit_probabilities"`
+		HeartbeatIntervalMs *int  `json:"heartbeat_interval_ms"`
+		DebugDump           *bool `json:"debug_dump"`
+		SpeechPadMs         *int  `json:"speech_pad_ms"` // unsupported, for error only
 	}
 	var sc streamCfg
 	if err := json.Unmarshal([]byte(configJSON), &sc); err != nil {
@@ -340,14 +1301,18 @@ func applyStreamConfig(configJSON string, cfg *config.Config) error {
 	if sc.SpeechPadMs != nil {
 		return fmt.Errorf("speech_pad_ms is not supported; use min_speech_duration_ms and min_silence_duration_ms instead")
 	}
-	if sc.Threshold != nil {
-		cfg.Threshold = *sc.Threshold
+	sc.Apply(cfg)
+	if sc.EmitProbabilities != nil {
+		cfg.EmitProbabilities = *sc.EmitProbabilities
 	}
-	if sc.MinSpeechDurationMs != nil {
-		cfg.MinSpeechDurationMs = *sc.MinSpeechDurationMs
+	if sc.HeartbeatIntervalMs != nil {
+		cfg.HeartbeatIntervalMs = *sc.HeartbeatIntervalMs
 	}
-	if sc.MinSilenceDurationMs != nil {
-		cfg.MinSilenceDurationMs = *sc.MinSilenceDurationMs
+	if sc.DebugDump != nil {
+		if *sc.DebugDump && cfg.DebugDumpDir == "" {
+			return fmt.Errorf("debug_dump requires the server to set debug_dump_dir")
+		}
+		cfg.DebugDump = *sc.DebugDump
 	}
 	return cfg.ValidateVADParams()
 }
@@ -355,29 +1320,138 @@ func applyStreamConfig(configJSON string, cfg *config.Config) error {
 // boundaryDetector applies hysteresis to raw per-frame engine results,
 // emitting speech events only after sustained speech/silence thresholds.
 //
-// NOTE: threshold is applied inside the engine (Engine.ProcessChunk returns
-// IsSpeech already thresholded). Speech boundary padding (lookahead/lookbehind)
-// is not yet implemented and may be added in a future version.
+// Engines return raw probabilities; the detector classifies each frame. Out
+// of speech a frame is speech at >= threshold; in speech it stays speech
+// until the probability drops below negThreshold, so probabilities hovering
+// around threshold do not flap between states. Speech boundary padding
+// (lookahead/lookbehind) is not yet implemented and may be added in a future
+// version.
 //
-// Frame duration is provided by Engine.FrameDurationMs() — 20ms for StubEngine,
-// 32ms for SileroEngine (512 samples at 16kHz). Each Result in the slice
-// returned by ProcessChunk represents one inferred frame.
+// Frame timing comes from Engine.FrameSamples() and SampleRate() — 20ms for
+// StubEngine, 32ms for SileroEngine (512 samples at 16kHz, 256 at 8kHz). Each
+// Result in the slice returned by ProcessChunk represents one inferred frame.
 type boundaryDetector struct {
+	threshold    float32
+	negThreshold float32
+	// smooth is nil unless smoothing_frames is set; probability is the
+	// (smoothed) value the last frame was classified on and speech the
+	// classification.
+	smooth      *smoother
+	probability float32
+	speech      bool
+
 	inSpeech       bool
 	speechFrames   int
 	silenceFrames  int
 	lastConfidence float32
 
+	// segmentFrames counts the frames since the current segment's START.
+	segmentFrames int
+	// onsetLag is, for the last START, the number of frames between the
+	// first speech frame of the run and the frame that emitted START: the
+	// hysteresis delay. Forced splits start on their own frame (lag 0).
+	onsetLag int
+
+	// With min_gap_ms, a SPEECH_END is held in pendingEnd for up to
+	// gapFrames frames; pendingFrames counts the frames since it was
+	// decided. endLag is, for the last END, how many frames after that
+	// decision it was emitted.
+	gapFrames     int
+	pendingEnd    *napv1.SpeechEvent
+	pendingFrames int
+	endLag        int
+
+	// events backs the slice process returns, reused from frame to frame.
+	events []*napv1.SpeechEvent
+
 	// Derived from config: number of consecutive frames needed.
 	minSpeechFrames  int
 	minSilenceFrames int
+	// maxSpeechFrames is the segment length that forces a split; 0 disables it.
+	maxSpeechFrames int
+
+	// With adaptive silence, the silence that ends a segment grows from
+	// adaptiveMinFrames by adaptiveGrowth frames per segment frame, up to
+	// adaptiveMaxFrames, instead of being minSilenceFrames.
+	adaptive          bool
+	adaptiveMinFrames int
+	adaptiveMaxFrames int
+	adaptiveGrowth    float64
+
+	// noise is nil unless adaptive_threshold is set; it moves threshold and
+	// negThreshold with the stream's noise floor.
+	noise *noiseFloor
+}
+
+func newBoundaryDetector(cfg config.Config, clock frameClock) *boundaryDetector {
+	bd := &boundaryDetector{
+		threshold:        float32(cfg.Threshold),
+		negThreshold:     float32(cfg.EffectiveNegThreshold()),
+		smooth:           newSmoother(cfg),
+		minSpeechFrames:  max(1, clock.framesFor(cfg.MinSpeechDurationMs)),
+		minSilenceFrames: max(1, clock.framesFor(cfg.MinSilenceDurationMs)),
+	}
+	if cfg.MaxSpeechDurationMs > 0 {
+		bd.maxSpeechFrames = max(1, clock.framesFor(cfg.MaxSpeechDurationMs))
+	}
+	if cfg.MinGapMs > 0 {
+		bd.gapFrames = max(1, clock.framesFor(cfg.MinGapMs))
+	}
+	if as := cfg.AdaptiveSilence; as.Enabled() {
+		bd.adaptive = true
+		bd.adaptiveMinFrames = max(1, clock.framesFor(as.MinMs))
+		bd.adaptiveMaxFrames = max(1, clock.framesFor(as.MaxMs))
+		bd.adaptiveGrowth = as.Growth
+	}
+	bd.noise = newNoiseFloor(cfg, clock)
+	return bd
+}
+
+// inheritNoiseFloor carries prev's noise floor estimate over to bd, when
+// both adapt their threshold, and applies it: the background does not change
+// with a reset or a config update.
+func (bd *boundaryDetector) inheritNoiseFloor(prev *boundaryDetector) {
+	if bd.noise == nil || prev.noise == nil {
+		return
+	}
+	bd.noise.frames, bd.noise.mean = prev.noise.frames, prev.noise.mean
+	bd.noise.apply(bd)
 }
 
-func newBoundaryDetector(cfg config.Config, frameDurationMs int) *boundaryDetector {
-	return &boundaryDetector{
-		minSpeechFrames:  max(1, ceilDiv(cfg.MinSpeechDurationMs, frameDurationMs)),
-		minSilenceFrames: max(1, ceilDiv(cfg.MinSilenceDurationMs, frameDurationMs)),
+// reconfigure switches the detector to the parameters of cfg, keeping its
+// state, so a stream's endpointing can change mid-stream. prev is the config
+// the detector ran with; the smoothing window restarts only if the smoothing
+// settings changed.
+func (bd *boundaryDetector) reconfigure(cfg, prev config.Config, clock frameClock) {
+	next := newBoundaryDetector(cfg, clock)
+	next.probability = bd.probability
+	next.speech = bd.speech
+	next.inSpeech = bd.inSpeech
+	next.speechFrames = bd.speechFrames
+	next.silenceFrames = bd.silenceFrames
+	next.lastConfidence = bd.lastConfidence
+	next.segmentFrames = bd.segmentFrames
+	next.onsetLag = bd.onsetLag
+	next.pendingEnd = bd.pendingEnd
+	next.pendingFrames = bd.pendingFrames
+	next.endLag = bd.endLag
+	if cfg.SmoothingFrames == prev.SmoothingFrames && cfg.SmoothingMethod == prev.SmoothingMethod {
+		next.smooth = bd.smooth
 	}
+	next.inheritNoiseFloor(bd)
+	*bd = *next
+}
+
+// silenceFramesToEnd returns the silence run that ends the current segment.
+// With adaptive silence it depends on the segment's length before the
+// current silence run.
+func (bd *boundaryDetector) silenceFramesToEnd() int {
+	if !bd.adaptive {
+		return bd.minSilenceFrames
+	}
+	segment := max(0, bd.segmentFrames-(bd.silenceFrames-1))
+	grown := bd.adaptiveMinFrames + int(math.Ceil(bd.adaptiveGrowth*float64(segment)))
+	return min(grown, bd.adaptiveMaxFrames)
 }
 
 // ceilDiv returns the ceiling of a/b for positive integers.
@@ -385,38 +1459,82 @@ func ceilDiv(a, b int) int {
 	return (a + b - 1) / b
 }
 
+// process classifies one frame and returns the boundary events it
+// produced, without timestamps. The slice is reused by the next call; the
+// events are not.
 func (bd *boundaryDetector) process(result engine.Result) []*napv1.SpeechEvent {
 	bd.lastConfidence = result.Confidence
-	var events []*napv1.SpeechEvent
+	bd.probability = result.Confidence
+	if bd.smooth != nil {
+		bd.probability = bd.smooth.add(result.Confidence)
+	}
+	if bd.inSpeech {
+		bd.speech = bd.probability >= bd.negThreshold
+	} else {
+		bd.speech = bd.probability >= bd.threshold
+	}
+	if bd.pendingEnd != nil {
+		bd.pendingFrames++
+	}
+	events := bd.events[:0]
 
-	if result.IsSpeech {
+	if bd.speech {
 		bd.speechFrames++
 		bd.silenceFrames = 0
 
-		if !bd.inSpeech && bd.speechFrames >= bd.minSpeechFrames {
+		if !bd.inSpeech && bd.speechFrames >= bd.minSpeechFrames && bd.pendingEnd != nil {
+			// Speech resumed within min_gap_ms of the held END: drop it and
+			// the START, and continue the original segment.
 			bd.inSpeech = true
-			events = append(events, &napv1.SpeechEvent{
-				Type:       napv1.SpeechEventType_SPEECH_EVENT_TYPE_START,
-				Confidence: result.Confidence,
-			})
+			bd.pendingEnd = nil
+			events = append(events, newEvent(napv1.SpeechEventType_SPEECH_EVENT_TYPE_ONGOING, result.Confidence))
+		} else if !bd.inSpeech && bd.speechFrames >= bd.minSpeechFrames {
+			bd.inSpeech = true
+			bd.segmentFrames = 0
+			bd.onsetLag = bd.speechFrames - 1
+			events = append(events, newEvent(napv1.SpeechEventType_SPEECH_EVENT_TYPE_START, result.Confidence))
+		} else if bd.inSpeech && bd.maxSpeechFrames > 0 && bd.segmentFrames >= bd.maxSpeechFrames {
+			// The segment reached max_speech_duration_ms: close it and open a
+			// new one on the same frame. Splits only happen on speech frames,
+			// so a segment already in its silence hangover ends naturally.
+			bd.segmentFrames = 0
+			bd.onsetLag = 0
+			bd.endLag = 0
+			events = append(events,
+				newEvent(napv1.SpeechEventType_SPEECH_EVENT_TYPE_END, result.Confidence),
+				newEvent(napv1.SpeechEventType_SPEECH_EVENT_TYPE_START, result.Confidence))
 		} else if bd.inSpeech {
-			events = append(events, &napv1.SpeechEvent{
-				Type:       napv1.SpeechEventType_SPEECH_EVENT_TYPE_ONGOING,
-				Confidence: result.Confidence,
-			})
+			events = append(events, newEvent(napv1.SpeechEventType_SPEECH_EVENT_TYPE_ONGOING, result.Confidence))
 		}
 	} else {
 		bd.silenceFrames++
 		bd.speechFrames = 0
 
-		if bd.inSpeech && bd.silenceFrames >= bd.minSilenceFrames {
+		if bd.inSpeech && bd.silenceFrames >= bd.silenceFramesToEnd() {
 			bd.inSpeech = false
-			events = append(events, &napv1.SpeechEvent{
-				Type:       napv1.SpeechEventType_SPEECH_EVENT_TYPE_END,
-				Confidence: result.Confidence,
-			})
+			end := newEvent(napv1.SpeechEventType_SPEECH_EVENT_TYPE_END, result.Confidence)
+			if bd.gapFrames > 0 {
+				bd.pendingEnd = end
+				bd.pendingFrames = 0
+			} else {
+				bd.endLag = 0
+				events = append(events, end)
+			}
 		}
 	}
 
+	if bd.pendingEnd != nil && bd.pendingFrames >= bd.gapFrames {
+		// No speech within min_gap_ms: the held END stands.
+		events = append(events, bd.pendingEnd)
+		bd.endLag = bd.pendingFrames
+		bd.pendingEnd = nil
+	}
+	if bd.inSpeech || bd.pendingEnd != nil {
+		bd.segmentFrames++
+	} else if bd.noise != nil && !bd.speech {
+		bd.noise.add(bd.probability)
+		bd.noise.apply(bd)
+	}
+	bd.events = events
 	return events
 }
@@ -2,9 +2,13 @@ package server
 
 import (
 	"context"
+	"errors"
+	"fmt"
 	"io"
 	"log/slog"
+	"math"
 	"net"
+	"slices"
 	"strings"
 	"sync"
 	"testing"
@@ -18,6 +22,7 @@ import (
 
 	"github.com/nupi-ai/plugin-vad-local-silero/internal/config"
 	"github.com/nupi-ai/plugin-vad-local-silero/internal/engine"
+	"github.com/nupi-ai/plugin-vad-local-silero/internal/errs"
 )
 
 // startTestServer creates a gRPC server with the VAD service using a
@@ -25,15 +30,21 @@ import (
 func startTestServer(t *testing.T, cfg config.Config) (napv1.VoiceActivityDetectionServiceClient, func()) {
 	t.Helper()
 
+	newEngine := func() engine.Engine { return engine.NewStubEngine() }
+	logger := slog.Default()
+	return startTestServerWith(t, New(cfg, logger, newEngine))
+}
+
+// startTestServerWith serves an already constructed Server over a local gRPC
+// listener. Tests use it when they need access to the Server (e.g. metrics).
+func startTestServerWith(t *testing.T, srv *Server) (napv1.VoiceActivityDetectionServiceClient, func()) {
+	t.Helper()
+
 	lis, err := net.Listen("tcp", "localhost:0")
 	if err != nil {
 		t.Fatal(err)
 	}
 
-	newEngine := func() engine.Engine { return engine.NewStubEngine() }
-	logger := slog.Default()
-	srv := New(cfg, logger, newEngine)
-
 	grpcServer := grpc.NewServer()
 	napv1.RegisterVoiceActivityDetectionServiceServer(grpcServer, srv)
 	go grpcServer.Serve(lis)
@@ -255,6 +266,63 @@ func TestDetectSpeechFullCycle(t *testing.T) {
 	}
 }
 
+func TestDetectSpeech8kHz(t *testing.T) {
+	// Telephony clients stream 8kHz PCM directly: 20ms = 160 samples = 320 bytes.
+	cfg := config.Config{
+		Threshold:            0.5,
+		MinSpeechDurationMs:  20,
+		MinSilenceDurationMs: 20,
+	}
+	client, cleanup := startTestServer(t, cfg)
+	defer cleanup()
+
+	stream, err := client.DetectSpeech(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	totalChunks := engine.StubToggleInterval*3 - 1
+	chunk := make([]byte, 320)
+	for i := 0; i < totalChunks; i++ {
+		if err := stream.Send(&napv1.DetectSpeechRequest{
+			PcmData: chunk,
+			Format:  &napv1.AudioFormat{SampleRate: 8000},
+		}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := stream.CloseSend(); err != nil {
+		t.Fatal(err)
+	}
+
+	var events []*napv1.SpeechEvent
+	for {
+		evt, err := stream.Recv()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		events = append(events, evt)
+	}
+
+	// Same frame timing as 16kHz: one 20ms frame per 320-byte chunk.
+	if len(events) != engine.StubToggleInterval+1 {
+		t.Fatalf("got %d events, want %d (START + ONGOING + END)", len(events), engine.StubToggleInterval+1)
+	}
+	if events[0].Type != napv1.SpeechEventType_SPEECH_EVENT_TYPE_START {
+		t.Errorf("first event = %v, want START", events[0].Type)
+	}
+	if last := events[len(events)-1]; last.Type != napv1.SpeechEventType_SPEECH_EVENT_TYPE_END {
+		t.Errorf("last event = %v, want END", last.Type)
+	}
+	span := events[len(events)-1].Timestamp.AsTime().Sub(events[0].Timestamp.AsTime())
+	if want := time.Duration(engine.StubToggleInterval) * 20 * time.Millisecond; span != want {
+		t.Errorf("START→END span = %v, want %v", span, want)
+	}
+}
+
 func TestDetectSpeechConcurrentStreamsIsolation(t *testing.T) {
 	// Two concurrent streams must not share engine state. Each gets its own
 	// StubEngine via the factory, so their toggle counters are independent.
@@ -1883,3 +1951,661 @@ func TestDetectSpeechLargeChunkMultipleEvents(t *testing.T) {
 		}
 	}
 }
+
+func TestDetectSpeechMaxSpeechDurationSplits(t *testing.T) {
+	// StubEngine speaks for frames 49-98 (1s). With max_speech_duration_ms=400
+	// (20 frames) from config_json, the segment is split at frames 69 and 89:
+	// each split is an END and a START with the same timestamp.
+	cfg := config.Config{
+		Threshold:            0.5,
+		MinSpeechDurationMs:  20,
+		MinSilenceDurationMs: 20,
+	}
+	client, cleanup := startTestServer(t, cfg)
+	defer cleanup()
+
+	stream, err := client.DetectSpeech(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	chunk := make([]byte, 640)
+	for i := 0; i < engine.StubToggleInterval*3-1; i++ {
+		req := &napv1.DetectSpeechRequest{
+			PcmData: chunk,
+			Format:  &napv1.AudioFormat{SampleRate: 16000},
+		}
+		if i == 0 {
+			req.ConfigJson = `{"max_speech_duration_ms":400}`
+		}
+		if err := stream.Send(req); err != nil {
+			t.Fatal(err)
+		}
+	}
+	stream.CloseSend()
+
+	var boundaries []*napv1.SpeechEvent
+	for {
+		evt, err := stream.Recv()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		if evt.Type != napv1.SpeechEventType_SPEECH_EVENT_TYPE_ONGOING {
+			boundaries = append(boundaries, evt)
+		}
+	}
+
+	start := napv1.SpeechEventType_SPEECH_EVENT_TYPE_START
+	end := napv1.SpeechEventType_SPEECH_EVENT_TYPE_END
+	want := []struct {
+		typ   napv1.SpeechEventType
+		frame int
+	}{
+		{start, 49}, {end, 69}, {start, 69}, {end, 89}, {start, 89}, {end, 99},
+	}
+	if len(boundaries) != len(want) {
+		types := make([]string, len(boundaries))
+		for i, e := range boundaries {
+			types[i] = e.Type.String()
+		}
+		t.Fatalf("got %d boundary events %v, want %d", len(boundaries), types, len(want))
+	}
+	base := boundaries[0].Timestamp.AsTime()
+	for i, w := range want {
+		evt := boundaries[i]
+		if evt.Type != w.typ {
+			t.Errorf("event %d type = %v, want %v", i, evt.Type, w.typ)
+		}
+		offset := evt.Timestamp.AsTime().Sub(base)
+		if wantOffset := time.Duration(w.frame-49) * 20 * time.Millisecond; offset != wantOffset {
+			t.Errorf("event %d (%v) offset = %v, want %v", i, evt.Type, offset, wantOffset)
+		}
+	}
+}
+
+func TestDetectSpeechPrerollBackdatesStart(t *testing.T) {
+	// StubEngine speaks for frames 49-98. With min_speech_duration_ms=200
+	// (10 frames) START is confirmed at frame 58 and END fires at frame 99.
+	tests := []struct {
+		name      string
+		prerollMs int
+		wantSpan  time.Duration // END - START
+	}{
+		{"disabled", 0, (99 - 58) * 20 * time.Millisecond},
+		{"onset minus preroll", 100, (99-49)*20*time.Millisecond + 100*time.Millisecond},
+		{"clamped to stream start", 5000, 99 * 20 * time.Millisecond},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := config.Config{
+				Threshold:            0.5,
+				MinSpeechDurationMs:  200,
+				MinSilenceDurationMs: 20,
+				PrerollMs:            tt.prerollMs,
+			}
+			client, cleanup := startTestServer(t, cfg)
+			defer cleanup()
+
+			stream, err := client.DetectSpeech(context.Background())
+			if err != nil {
+				t.Fatal(err)
+			}
+			chunk := make([]byte, 640)
+			for i := 0; i < engine.StubToggleInterval*3-1; i++ {
+				if err := stream.Send(&napv1.DetectSpeechRequest{
+					PcmData: chunk,
+					Format:  &napv1.AudioFormat{SampleRate: 16000},
+				}); err != nil {
+					t.Fatal(err)
+				}
+			}
+			stream.CloseSend()
+
+			var start, end *napv1.SpeechEvent
+			for {
+				evt, err := stream.Recv()
+				if err == io.EOF {
+					break
+				}
+				if err != nil {
+					t.Fatal(err)
+				}
+				switch evt.Type {
+				case napv1.SpeechEventType_SPEECH_EVENT_TYPE_START:
+					start = evt
+				case napv1.SpeechEventType_SPEECH_EVENT_TYPE_END:
+					end = evt
+				}
+			}
+			if start == nil || end == nil {
+				t.Fatalf("missing START or END: start=%v end=%v", start, end)
+			}
+			if span := end.Timestamp.AsTime().Sub(start.Timestamp.AsTime()); span != tt.wantSpan {
+				t.Errorf("END - START = %v, want %v", span, tt.wantSpan)
+			}
+		})
+	}
+}
+
+func TestPrerollStartNeverOverlapsPreviousSegment(t *testing.T) {
+	streamStart := time.Unix(1000, 0)
+	onset := streamStart.Add(2 * time.Second)
+	lastEnd := streamStart.Add(1900 * time.Millisecond)
+
+	if got := prerollStart(onset, 300, streamStart, lastEnd); !got.Equal(lastEnd) {
+		t.Errorf("prerollStart = %v, want clamped to previous END %v", got, lastEnd)
+	}
+	if got, want := prerollStart(onset, 50, streamStart, lastEnd), onset.Add(-50*time.Millisecond); !got.Equal(want) {
+		t.Errorf("prerollStart = %v, want %v", got, want)
+	}
+	if got := prerollStart(onset, 300, streamStart, time.Time{}); !got.Equal(onset.Add(-300 * time.Millisecond)) {
+		t.Errorf("prerollStart without previous END = %v", got)
+	}
+}
+
+func TestBoundaryDetectorNegThresholdHysteresis(t *testing.T) {
+	clock := frameClock{frameSamples: 320, sampleRate: 16000}
+	cfg := config.Config{
+		Threshold:            0.5,
+		MinSpeechDurationMs:  20,
+		MinSilenceDurationMs: 40,
+	}
+	// Probabilities hovering just under threshold after onset.
+	probs := []float32{0.6, 0.45, 0.4, 0.45, 0.2, 0.2}
+
+	run := func(cfg config.Config) []napv1.SpeechEventType {
+		bd := newBoundaryDetector(cfg, clock)
+		var types []napv1.SpeechEventType
+		for _, p := range probs {
+			for _, evt := range bd.process(engine.Result{Confidence: p}) {
+				types = append(types, evt.Type)
+			}
+		}
+		return types
+	}
+	start := napv1.SpeechEventType_SPEECH_EVENT_TYPE_START
+	ongoing := napv1.SpeechEventType_SPEECH_EVENT_TYPE_ONGOING
+	end := napv1.SpeechEventType_SPEECH_EVENT_TYPE_END
+
+	// Default neg_threshold (0.35): the 0.4-0.45 frames continue the segment,
+	// which ends after two frames below 0.35.
+	if got, want := run(cfg), []napv1.SpeechEventType{start, ongoing, ongoing, ongoing, end}; !slices.Equal(got, want) {
+		t.Errorf("default neg_threshold events = %v, want %v", got, want)
+	}
+
+	// neg_threshold == threshold disables hysteresis: the segment ends two
+	// frames after the probability first drops below 0.5.
+	cfg.NegThreshold = 0.5
+	if got, want := run(cfg), []napv1.SpeechEventType{start, end}; !slices.Equal(got, want) {
+		t.Errorf("neg_threshold=threshold events = %v, want %v", got, want)
+	}
+}
+
+func TestBoundaryDetectorAdaptiveSilence(t *testing.T) {
+	clock := frameClock{frameSamples: 320, sampleRate: 16000} // 20ms frames
+	cfg := config.Config{Threshold: 0.5, MinSpeechDurationMs: 20, MinSilenceDurationMs: 20}
+	if err := applyStreamConfig(`{"adaptive_silence": {"min_ms": 100, "max_ms": 300, "growth": 0.5}}`, &cfg); err != nil {
+		t.Fatal(err)
+	}
+
+	// endAfter returns how many silence frames end a segment of speech frames.
+	endAfter := func(speech int) int {
+		bd := newBoundaryDetector(cfg, clock)
+		for i := 0; i < speech; i++ {
+			bd.process(engine.Result{Confidence: 0.9})
+		}
+		for n := 1; n < 1000; n++ {
+			for _, evt := range bd.process(engine.Result{Confidence: 0.1}) {
+				if evt.Type == napv1.SpeechEventType_SPEECH_EVENT_TYPE_END {
+					return n
+				}
+			}
+		}
+		return -1
+	}
+	// 5 frames (100 ms) minimum, plus half the segment, capped at 15 (300 ms).
+	for _, tt := range []struct{ speech, want int }{{1, 6}, {10, 10}, {20, 15}, {200, 15}} {
+		if got := endAfter(tt.speech); got != tt.want {
+			t.Errorf("segment of %d frames ended after %d silence frames, want %d", tt.speech, got, tt.want)
+		}
+	}
+
+	for _, bad := range []string{
+		`{"adaptive_silence": {"min_ms": 0, "max_ms": 300}}`,
+		`{"adaptive_silence": {"min_ms": 400, "max_ms": 300}}`,
+		`{"adaptive_silence": {"min_ms": 100, "max_ms": 300, "growth": -1}}`,
+	} {
+		c := config.Config{Threshold: 0.5, MinSpeechDurationMs: 20, MinSilenceDurationMs: 20}
+		if err := applyStreamConfig(bad, &c); err == nil || !strings.Contains(err.Error(), "adaptive_silence") {
+			t.Errorf("%s: err = %v, want adaptive_silence error", bad, err)
+		}
+	}
+}
+
+func TestBoundaryDetectorMinGapMergesSegments(t *testing.T) {
+	clock := frameClock{frameSamples: 320, sampleRate: 16000} // 20ms frames
+	cfg := config.Config{Threshold: 0.5, MinSpeechDurationMs: 20, MinSilenceDurationMs: 40}
+	// A breath pause (frames 5-7) inside one utterance, then a long silence.
+	probs := []float32{0.9, 0.9, 0.9, 0.9, 0.9, 0.1, 0.1, 0.1, 0.9, 0.9,
+		0.1, 0.1, 0.1, 0.1, 0.1, 0.1, 0.1, 0.1, 0.1, 0.1}
+
+	type boundary struct {
+		frame  int
+		typ    napv1.SpeechEventType
+		endLag int
+	}
+	run := func(cfg config.Config) []boundary {
+		bd := newBoundaryDetector(cfg, clock)
+		var got []boundary
+		for i, p := range probs {
+			for _, evt := range bd.process(engine.Result{Confidence: p}) {
+				if evt.Type != napv1.SpeechEventType_SPEECH_EVENT_TYPE_ONGOING {
+					got = append(got, boundary{i, evt.Type, bd.endLag})
+				}
+			}
+		}
+		return got
+	}
+
+	start, end := napv1.SpeechEventType_SPEECH_EVENT_TYPE_START, napv1.SpeechEventType_SPEECH_EVENT_TYPE_END
+	if got, want := run(cfg), []boundary{{0, start, 0}, {6, end, 0}, {8, start, 0}, {11, end, 0}}; !slices.Equal(got, want) {
+		t.Errorf("without min_gap_ms: %v, want %v", got, want)
+	}
+	// With a 100 ms gap the pause is merged, and the final END, decided on
+	// frame 11, is emitted 5 frames later pointing back at it.
+	cfg.MinGapMs = 100
+	if got, want := run(cfg), []boundary{{0, start, 0}, {16, end, 5}}; !slices.Equal(got, want) {
+		t.Errorf("min_gap_ms=100: %v, want %v", got, want)
+	}
+}
+
+func TestDetectSpeechEmitProbabilities(t *testing.T) {
+	cfg := config.Config{
+		Threshold:            0.5,
+		MinSpeechDurationMs:  20,
+		MinSilenceDurationMs: 20,
+	}
+	client, cleanup := startTestServer(t, cfg)
+	defer cleanup()
+
+	stream, err := client.DetectSpeech(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := stream.Send(&napv1.DetectSpeechRequest{ConfigJson: `{"emit_probabilities": true}`}); err != nil {
+		t.Fatal(err)
+	}
+	const frames = engine.StubToggleInterval*3 - 1
+	for i := 0; i < frames; i++ {
+		if err := stream.Send(&napv1.DetectSpeechRequest{
+			Format:  &napv1.AudioFormat{SampleRate: 16000},
+			PcmData: make([]byte, 640),
+		}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	stream.CloseSend()
+
+	var probs, starts, ends int
+	var prev time.Time
+	for {
+		evt, err := stream.Recv()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		switch evt.GetType() {
+		case ProbabilityEventType:
+			probs++
+			if c := evt.GetConfidence(); c != engine.StubConfidence && c != engine.StubSilenceConfidence {
+				t.Errorf("probability sample %d = %v", probs, c)
+			}
+			if ts := evt.GetTimestamp().AsTime(); !ts.After(prev) {
+				t.Errorf("probability sample %d timestamp %v not after %v", probs, ts, prev)
+			} else {
+				prev = ts
+			}
+		case napv1.SpeechEventType_SPEECH_EVENT_TYPE_START:
+			starts++
+		case napv1.SpeechEventType_SPEECH_EVENT_TYPE_END:
+			ends++
+		}
+	}
+	if probs != frames {
+		t.Errorf("probability samples = %d, want %d", probs, frames)
+	}
+	if starts != 1 || ends != 1 {
+		t.Errorf("boundary events: %d START, %d END, want 1 each", starts, ends)
+	}
+}
+
+func TestDetectSpeechMinGapFlushesHeldEndAtEOF(t *testing.T) {
+	cfg := config.Config{
+		Threshold:            0.5,
+		MinSpeechDurationMs:  20,
+		MinSilenceDurationMs: 20,
+	}
+	client, cleanup := startTestServer(t, cfg)
+	defer cleanup()
+
+	// segment returns the START and END timestamps of a stream that closes
+	// shortly after its only speech segment ended.
+	segment := func(configJSON string) (start, end time.Time) {
+		stream, err := client.DetectSpeech(context.Background())
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := stream.Send(&napv1.DetectSpeechRequest{ConfigJson: configJSON}); err != nil {
+			t.Fatal(err)
+		}
+		for i := 0; i < engine.StubToggleInterval*2+10; i++ {
+			if err := stream.Send(&napv1.DetectSpeechRequest{
+				Format:  &napv1.AudioFormat{SampleRate: 16000},
+				PcmData: make([]byte, 640),
+			}); err != nil {
+				t.Fatal(err)
+			}
+		}
+		stream.CloseSend()
+		for {
+			evt, err := stream.Recv()
+			if err == io.EOF {
+				return start, end
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			switch evt.GetType() {
+			case napv1.SpeechEventType_SPEECH_EVENT_TYPE_START:
+				start = evt.GetTimestamp().AsTime()
+			case napv1.SpeechEventType_SPEECH_EVENT_TYPE_END:
+				end = evt.GetTimestamp().AsTime()
+			}
+		}
+	}
+
+	start, end := segment(`{}`)
+	heldStart, heldEnd := segment(`{"min_gap_ms": 1000}`)
+	if heldEnd.IsZero() {
+		t.Fatal("held SPEECH_END was not flushed at end of stream")
+	}
+	if got, want := heldEnd.Sub(heldStart), end.Sub(start); got != want {
+		t.Errorf("segment with held END lasted %v, want %v", got, want)
+	}
+}
+
+func TestDetectSpeechMidStreamConfigUpdate(t *testing.T) {
+	cfg := config.Config{
+		Threshold:            0.5,
+		MinSpeechDurationMs:  20,
+		MinSilenceDurationMs: 20,
+	}
+	client, cleanup := startTestServer(t, cfg)
+	defer cleanup()
+
+	// segment sends a full stub speech cycle with update sent as its own
+	// message after frame 60 (inside the segment), and returns the segment.
+	segment := func(update string) (start, end time.Time, err error) {
+		stream, err := client.DetectSpeech(context.Background())
+		if err != nil {
+			t.Fatal(err)
+		}
+		for i := 0; i < engine.StubToggleInterval*3-1; i++ {
+			if i == 60 {
+				if err := stream.Send(&napv1.DetectSpeechRequest{ConfigJson: update}); err != nil {
+					t.Fatal(err)
+				}
+			}
+			if err := stream.Send(&napv1.DetectSpeechRequest{
+				Format:  &napv1.AudioFormat{SampleRate: 16000},
+				PcmData: make([]byte, 640),
+			}); err != nil {
+				break // the server may already have failed the stream
+			}
+		}
+		stream.CloseSend()
+		for {
+			evt, err := stream.Recv()
+			if err == io.EOF {
+				return start, end, nil
+			}
+			if err != nil {
+				return start, end, err
+			}
+			switch evt.GetType() {
+			case napv1.SpeechEventType_SPEECH_EVENT_TYPE_START:
+				start = evt.GetTimestamp().AsTime()
+			case napv1.SpeechEventType_SPEECH_EVENT_TYPE_END:
+				end = evt.GetTimestamp().AsTime()
+			}
+		}
+	}
+
+	start, end, err := segment(`{}`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	base := end.Sub(start)
+
+	// Raising min silence mid-segment delays its END by 9 more frames; the
+	// segment is not restarted.
+	start, end, err = segment(`{"min_silence_duration_ms": 200}`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := end.Sub(start), base+180*time.Millisecond; got != want {
+		t.Errorf("segment after min_silence update lasted %v, want %v", got, want)
+	}
+
+	if _, _, err := segment(`{"threshold": 2}`); status.Code(err) != codes.InvalidArgument {
+		t.Errorf("invalid mid-stream config: err = %v, want InvalidArgument", err)
+	}
+}
+
+func TestBoundaryDetectorReconfigureKeepsState(t *testing.T) {
+	clock := frameClock{frameSamples: 320, sampleRate: 16000}
+	cfg := config.Config{Threshold: 0.5, MinSpeechDurationMs: 20, MinSilenceDurationMs: 100, SmoothingFrames: 3}
+	bd := newBoundaryDetector(cfg, clock)
+	for i := 0; i < 5; i++ {
+		bd.process(engine.Result{Confidence: 0.9})
+	}
+	bd.process(engine.Result{Confidence: 0.1})
+	smooth := bd.smooth
+
+	next := cfg
+	next.Threshold = 0.95
+	next.MinSilenceDurationMs = 40
+	bd.reconfigure(next, cfg, clock)
+	if !bd.inSpeech || bd.segmentFrames != 6 || bd.silenceFrames != 0 {
+		t.Errorf("state after reconfigure: inSpeech=%v segmentFrames=%d silenceFrames=%d, want true 6 0",
+			bd.inSpeech, bd.segmentFrames, bd.silenceFrames)
+	}
+	if bd.threshold != 0.95 || bd.minSilenceFrames != 2 {
+		t.Errorf("params after reconfigure: threshold=%v minSilenceFrames=%d, want 0.95 2", bd.threshold, bd.minSilenceFrames)
+	}
+	if bd.smooth != smooth {
+		t.Error("unchanged smoothing settings should keep the smoothing window")
+	}
+	next.SmoothingFrames = 5
+	bd.reconfigure(next, next, clock)
+	if bd.smooth != smooth {
+		t.Error("prev == cfg should keep the smoothing window")
+	}
+	bd.reconfigure(next, cfg, clock)
+	if bd.smooth == smooth {
+		t.Error("changed smoothing_frames should restart the smoothing window")
+	}
+}
+
+func TestDetectSpeechReset(t *testing.T) {
+	cfg := config.Config{
+		Threshold:            0.5,
+		MinSpeechDurationMs:  20,
+		MinSilenceDurationMs: 20,
+	}
+	client, cleanup := startTestServer(t, cfg)
+	defer cleanup()
+
+	stream, err := client.DetectSpeech(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	send := func(req *napv1.DetectSpeechRequest) {
+		t.Helper()
+		if err := stream.Send(req); err != nil {
+			t.Fatal(err)
+		}
+	}
+	pcm := func(n int) *napv1.DetectSpeechRequest {
+		return &napv1.DetectSpeechRequest{Format: &napv1.AudioFormat{SampleRate: 16000}, PcmData: make([]byte, n)}
+	}
+	// 70 frames (speech from frame 49) and half a frame, then a reset in
+	// the middle of the segment and 60 more frames.
+	for i := 0; i < 70; i++ {
+		send(pcm(640))
+	}
+	send(pcm(300))
+	send(&napv1.DetectSpeechRequest{ConfigJson: `{"reset": true}`})
+	for i := 0; i < 60; i++ {
+		send(pcm(640))
+	}
+	stream.CloseSend()
+
+	header, err := stream.Header()
+	if err != nil {
+		t.Fatal(err)
+	}
+	epoch, ok := ParseAudioEpoch(header)
+	if !ok {
+		t.Fatal("missing audio epoch header")
+	}
+	var got []string
+	for {
+		evt, err := stream.Recv()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		if evt.GetType() == napv1.SpeechEventType_SPEECH_EVENT_TYPE_ONGOING {
+			continue
+		}
+		got = append(got, fmt.Sprintf("%s@%d", strings.TrimPrefix(evt.GetType().String(), "SPEECH_EVENT_TYPE_"),
+			AudioOffset(evt, epoch).Milliseconds()))
+	}
+	// The reset ends the open segment at frame 70; the stub then starts over
+	// and speaks again from its 49th frame after the reset (frame 119).
+	want := []string{"START@980", "END@1400", "START@2380", "END@2600"}
+	if strings.Join(got, " ") != strings.Join(want, " ") {
+		t.Errorf("events = %v, want %v", got, want)
+	}
+}
+
+func TestAppendTail(t *testing.T) {
+	var tail []byte
+	tail = appendTail(tail, []byte{1, 2, 3}, 4)
+	tail = appendTail(tail, []byte{4, 5}, 4)
+	if string(tail) != string([]byte{2, 3, 4, 5}) {
+		t.Errorf("tail = %v, want [2 3 4 5]", tail)
+	}
+	tail = appendTail(tail, []byte{6, 7, 8, 9, 10}, 4)
+	if string(tail) != string([]byte{7, 8, 9, 10}) {
+		t.Errorf("tail = %v, want [7 8 9 10]", tail)
+	}
+}
+
+func TestApplyStreamConfigPreset(t *testing.T) {
+	cfg := config.Config{Threshold: 0.5, MinSpeechDurationMs: 250, MinSilenceDurationMs: 300}
+	if err := applyStreamConfig(`{"preset": "dictation", "min_silence_duration_ms": 1000}`, &cfg); err != nil {
+		t.Fatal(err)
+	}
+	dictation, _ := config.PresetParams(config.PresetDictation)
+	if cfg.Preset != config.PresetDictation || cfg.Threshold != *dictation.Threshold || cfg.PrerollMs != *dictation.PrerollMs {
+		t.Errorf("preset not expanded: %+v", cfg)
+	}
+	if cfg.MinSilenceDurationMs != 1000 {
+		t.Errorf("min_silence_duration_ms = %d, want the explicit 1000 over the preset", cfg.MinSilenceDurationMs)
+	}
+
+	c := config.Config{Threshold: 0.5, MinSpeechDurationMs: 250, MinSilenceDurationMs: 300}
+	if err := applyStreamConfig(`{"preset": "fast"}`, &c); err == nil || !strings.Contains(err.Error(), "preset") {
+		t.Errorf("unknown preset: err = %v", err)
+	}
+}
+
+func TestDetectSpeechEngineFailureAfterEvents(t *testing.T) {
+	// The stub starts speech on frame 49; the engine fails on the chunk
+	// after frame 60. The START already sent reaches the client before the
+	// stream's Internal status.
+	cfg := config.Config{Threshold: 0.5, MinSpeechDurationMs: 20, MinSilenceDurationMs: 20}
+	srv := New(cfg, slog.New(slog.DiscardHandler), func() engine.Engine {
+		return engine.NewFaultEngine(engine.NewStubEngine(), engine.FailAfter(60, errors.New("inference failed")))
+	})
+	client, cleanup := startTestServerWith(t, srv)
+	defer cleanup()
+
+	stream, err := client.DetectSpeech(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	go func() {
+		for i := 0; i < 100; i++ {
+			if stream.Send(&napv1.DetectSpeechRequest{
+				PcmData: make([]byte, 640),
+				Format:  &napv1.AudioFormat{SampleRate: 16000},
+			}) != nil {
+				return
+			}
+		}
+		stream.CloseSend()
+	}()
+
+	var starts int
+	for {
+		evt, err := stream.Recv()
+		if err != nil {
+			if st := status.Convert(err); st.Code() != codes.Internal || st.Message() != "audio processing failed" {
+				t.Errorf("stream ended with %v, want Internal", err)
+			}
+			break
+		}
+		if evt.GetType() == napv1.SpeechEventType_SPEECH_EVENT_TYPE_START {
+			starts++
+		}
+	}
+	if starts != 1 {
+		t.Errorf("got %d START events before the failure, want 1", starts)
+	}
+}
+
+func TestDetectSpeechEngineFaults(t *testing.T) {
+	tests := map[string]struct {
+		fault engine.FaultOption
+		code  codes.Code
+	}{
+		"error":           {engine.FailAfter(10, errors.New("inference failed")), codes.Internal},
+		"invalid_pcm":     {engine.FailAfter(10, errs.New(errs.ErrInvalidPCM, "bad samples")), codes.InvalidArgument},
+		"nan":             {engine.MalformAfter(10, float32(math.NaN())), codes.Internal},
+		"above_one":       {engine.MalformAfter(10, 1.5), codes.Internal},
+		"negative":        {engine.MalformAfter(10, -0.1), codes.Internal},
+		"slow_but_intact": {engine.DelayChunks(time.Millisecond), codes.OK},
+	}
+	for name, tt := range tests {
+		cfg := config.Config{Threshold: 0.5, MinSpeechDurationMs: 20, MinSilenceDurationMs: 20, MaxChunkBytes: 1280}
+		srv := New(cfg, slog.New(slog.DiscardHandler), func() engine.Engine {
+			return engine.NewFaultEngine(engine.NewStubEngine(), tt.fault)
+		})
+		_, err := srv.DetectSpeechInBuffer(context.Background(), &napv1.DetectSpeechRequest{
+			Format:  &napv1.AudioFormat{SampleRate: 16000},
+			PcmData: make([]byte, 40*640),
+		})
+		if code := status.Code(err); code != tt.code {
+			t.Errorf("%s: code %v (%v), want %v", name, code, err, tt.code)
+		}
+	}
+}
@@ -0,0 +1,109 @@
+package server
+
+import (
+	"log/slog"
+	"slices"
+	"time"
+)
+
+// session groups the open streams of one session_id, e.g. the far-field and
+// headset microphones of one user, so they can be listed and ended together
+// and log under one shared context.
+type session struct {
+	id     string
+	opened time.Time
+	// log is the server logger with session_id; the session's streams
+	// derive their loggers from it.
+	log     *slog.Logger
+	streams map[*streamHandle]struct{}
+}
+
+// ActiveSession describes a session with open streams.
+type ActiveSession struct {
+	SessionID string
+	// OpenedAt is when the session's first stream sent audio; the session
+	// lasts as long as any of its streams is open.
+	OpenedAt time.Time
+	// Streams are the session's open streams, oldest first.
+	Streams []ActiveStream
+}
+
+// joinSession adds h to the session sessionID once the stream's IDs are
+// known, and returns the session's logger and the stream IDs of its other
+// open streams. Streams without a session_id are not grouped.
+func (s *Server) joinSession(h *streamHandle, sessionID string) (*slog.Logger, []string) {
+	if sessionID == "" {
+		return s.log.With("session_id", sessionID), nil
+	}
+	s.streamsMu.Lock()
+	defer s.streamsMu.Unlock()
+	sess := s.sessions[sessionID]
+	if sess == nil {
+		sess = &session{
+			id:      sessionID,
+			opened:  time.Now(),
+			log:     s.log.With("session_id", sessionID),
+			streams: make(map[*streamHandle]struct{}),
+		}
+		s.sessions[sessionID] = sess
+	}
+	var siblings []string
+	for other := range sess.streams {
+		if ids := other.ids.Load(); ids != nil {
+			siblings = append(siblings, ids.stream)
+		}
+	}
+	slices.Sort(siblings)
+	sess.streams[h] = struct{}{}
+	h.session = sess
+	return sess.log, siblings
+}
+
+// leaveSession removes h from its session, dropping the session with its
+// last stream; s.streamsMu must be held.
+func (s *Server) leaveSession(h *streamHandle) {
+	sess := h.session
+	if sess == nil {
+		return
+	}
+	delete(sess.streams, h)
+	if len(sess.streams) == 0 {
+		delete(s.sessions, sess.id)
+	}
+}
+
+// ListSessions returns the sessions with open streams, oldest first.
+// Streams without a session_id, or that have not sent audio yet, belong to
+// none.
+func (s *Server) ListSessions() []ActiveSession {
+	s.streamsMu.Lock()
+	list := make([]ActiveSession, 0, len(s.sessions))
+	for _, sess := range s.sessions {
+		as := ActiveSession{SessionID: sess.id, OpenedAt: sess.opened}
+		for h := range sess.streams {
+			as.Streams = append(as.Streams, h.active())
+		}
+		slices.SortFunc(as.Streams, func(a, b ActiveStream) int { return a.OpenedAt.Compare(b.OpenedAt) })
+		list = append(list, as)
+	}
+	s.streamsMu.Unlock()
+	slices.SortFunc(list, func(a, b ActiveSession) int { return a.OpenedAt.Compare(b.OpenedAt) })
+	return list
+}
+
+// TerminateSession ends every open stream of sessionID with
+// TerminationError(ReasonAdminAction, msg) and returns how many were
+// terminated.
+func (s *Server) TerminateSession(sessionID, msg string) int {
+	err := TerminationError(ReasonAdminAction, msg)
+	s.streamsMu.Lock()
+	defer s.streamsMu.Unlock()
+	sess := s.sessions[sessionID]
+	if sess == nil {
+		return 0
+	}
+	for h := range sess.streams {
+		h.terminate(err)
+	}
+	return len(sess.streams)
+}
@@ -0,0 +1,89 @@
+package server
+
+import (
+	"context"
+	"log/slog"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	napv1 "github.com/nupi-ai/nupi/api/nap/v1"
+
+	"github.com/nupi-ai/plugin-vad-local-silero/internal/config"
+	"github.com/nupi-ai/plugin-vad-local-silero/internal/engine"
+)
+
+func TestSessions(t *testing.T) {
+	var logs lockedBuffer
+	logger := slog.New(slog.NewTextHandler(&logs, nil))
+	srv := New(config.Config{Threshold: 0.5}, logger, func() engine.Engine { return engine.NewStubEngine() })
+	client, cleanup := startTestServerWith(t, srv)
+	defer cleanup()
+
+	open := func(session, id string) napv1.VoiceActivityDetectionService_DetectSpeechClient {
+		stream, err := client.DetectSpeech(context.Background())
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := stream.Send(&napv1.DetectSpeechRequest{
+			SessionId: session,
+			StreamId:  id,
+			Format:    &napv1.AudioFormat{SampleRate: 16000},
+			PcmData:   make([]byte, 640),
+		}); err != nil {
+			t.Fatal(err)
+		}
+		// Wait for the first chunk to be handled, so the streams join in
+		// order.
+		deadline := time.Now().Add(5 * time.Second)
+		for !slices.ContainsFunc(srv.ListActiveStreams(), func(st ActiveStream) bool { return st.StreamID == id }) {
+			if time.Now().After(deadline) {
+				t.Fatalf("stream %s never became active", id)
+			}
+			time.Sleep(5 * time.Millisecond)
+		}
+		return stream
+	}
+	farField := open("user-1", "far-field")
+	headset := open("user-1", "headset")
+	other := open("user-2", "mic")
+	open("", "anonymous")
+
+	sessions := srv.ListSessions()
+	if len(sessions) != 2 || sessions[0].SessionID != "user-1" || sessions[1].SessionID != "user-2" {
+		t.Fatalf("sessions = %+v, want user-1 and user-2", sessions)
+	}
+	if got := sessions[0].Streams; len(got) != 2 || got[0].StreamID != "far-field" || got[1].StreamID != "headset" {
+		t.Errorf("user-1 streams = %+v, want far-field and headset", got)
+	}
+	if !strings.Contains(logs.String(), `session_id=user-1 stream_id=headset`) || !strings.Contains(logs.String(), `sibling_streams=[far-field]`) {
+		t.Errorf("logs = %s, want the headset stream opened with its sibling", logs.String())
+	}
+
+	if n := srv.TerminateSession("user-1", "ended by operator"); n != 2 {
+		t.Errorf("TerminateSession = %d, want 2", n)
+	}
+	for _, stream := range []napv1.VoiceActivityDetectionService_DetectSpeechClient{farField, headset} {
+		_, err := stream.Recv()
+		if reason, _, _ := TerminationReason(err); reason != ReasonAdminAction {
+			t.Errorf("terminated stream: err = %v, want %s", err, ReasonAdminAction)
+		}
+	}
+	if n := srv.TerminateSession("user-1", "again"); n != 0 {
+		t.Errorf("TerminateSession after close = %d, want 0", n)
+	}
+
+	// The other session is untouched, and a session goes with its last
+	// stream.
+	deadline := time.Now().Add(5 * time.Second)
+	for len(srv.ListSessions()) != 1 {
+		if time.Now().After(deadline) {
+			t.Fatalf("sessions = %+v, want only user-2", srv.ListSessions())
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+	if err := other.Send(&napv1.DetectSpeechRequest{PcmData: make([]byte, 640)}); err != nil {
+		t.Errorf("user-2 stream: %v", err)
+	}
+}
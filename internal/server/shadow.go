@@ -0,0 +1,354 @@
+package server
+
+import (
+	"fmt"
+	"log/slog"
+	"math/rand/v2"
+	"runtime/debug"
+	"slices"
+	"sync"
+	"sync/atomic"
+
+	napv1 "github.com/nupi-ai/nupi/api/nap/v1"
+
+	"github.com/nupi-ai/plugin-vad-local-silero/internal/config"
+	"github.com/nupi-ai/plugin-vad-local-silero/internal/engine"
+	"github.com/nupi-ai/plugin-vad-local-silero/internal/metrics"
+)
+
+// WithShadowEngine sets the factory used for shadow comparison engines.
+// Without it, shadow streams use the primary engine factory.
+func WithShadowEngine(newEngine func() engine.Engine) Option {
+	return func(s *Server) {
+		s.newShadowEngine = newEngine
+	}
+}
+
+// shadowMetrics holds the fleet-wide divergence counters.
+type shadowMetrics struct {
+	streams        *metrics.Counter
+	errors         *metrics.Counter
+	frames         *metrics.Counter
+	disagreements  *metrics.Counter
+	primarySegs    *metrics.Counter
+	shadowSegments *metrics.Counter
+	// panics is the adapter-wide vad_panics_total, which also counts
+	// panics the RPC recovery interceptor catches.
+	panics *metrics.Counter
+}
+
+func newShadowMetrics(reg *metrics.Registry) *shadowMetrics {
+	return &shadowMetrics{
+		streams:        reg.Counter("vad_shadow_streams_total"),
+		errors:         reg.Counter("vad_shadow_errors_total"),
+		frames:         reg.Counter("vad_shadow_compared_frames_total"),
+		disagreements:  reg.Counter("vad_shadow_frame_disagreements_total"),
+		primarySegs:    reg.Counter("vad_shadow_primary_segments_total"),
+		shadowSegments: reg.Counter("vad_shadow_segments_total"),
+		panics:         reg.Counter("vad_panics_total"),
+	}
+}
+
+// shadowSelected reports whether a new stream should run a shadow engine.
+func shadowSelected(percent int) bool {
+	return percent > 0 && (percent >= 100 || rand.IntN(100) < percent)
+}
+
+// shadowMaxQueuedBytes is how much PCM a shadow engine may fall behind the
+// primary, about 32 s at 16 kHz, before it is dropped for the rest of the
+// stream.
+const shadowMaxQueuedBytes = 1 << 20
+
+// shadowRunner feeds a stream's audio to a secondary engine and boundary
+// detector and compares its decisions with the primary's. The shadow runs on
+// its own goroutine, so its inference never delays the stream's events.
+// Nothing it produces is sent to the client, and its failures never fail the
+// stream: on the first error, or when it falls shadowMaxQueuedBytes behind,
+// the shadow is disabled for the rest of the stream.
+type shadowRunner struct {
+	eng     engine.Engine
+	bd      *boundaryDetector
+	cfg     config.Config
+	clock   frameClock
+	log     *slog.Logger
+	metrics *shadowMetrics
+
+	// jobs queues the stream's chunks and resets, queuedBytes of PCM, for
+	// the worker, woken through wake. The worker owns the fields below up
+	// to failed until done is closed.
+	mu          sync.Mutex
+	jobs        []shadowJob
+	queuedBytes int
+	closed      bool
+	wake        chan struct{}
+	done        chan struct{}
+	// stopped is set once the shadow is disabled; buffered holds the samples
+	// its engine buffered short of a frame after the last chunk.
+	stopped  atomic.Bool
+	buffered atomic.Int64
+
+	// frameAligned is true when both engines use the same frame geometry, so
+	// decisions can be compared frame by frame. Otherwise only the boundary
+	// state at the end of each chunk is compared.
+	frameAligned bool
+	// pending holds primary decisions not yet matched by a shadow frame (the
+	// two engines may buffer partial frames differently).
+	pending []bool
+
+	frames         int64
+	disagreements  int64
+	shadowSegments int64
+	failed         bool
+
+	// primarySegs is counted on the stream's goroutine.
+	primarySegs int64
+}
+
+// shadowJob is a chunk the primary processed, or with reset set a stream
+// reset and the PCM the primary re-buffered after it.
+type shadowJob struct {
+	pcm             []byte
+	sampleRate      uint32
+	primary         []bool
+	primaryInSpeech bool
+	reset           bool
+}
+
+func newShadowRunner(eng engine.Engine, cfg config.Config, sampleRate uint32, primaryClock frameClock, logger *slog.Logger, m *shadowMetrics) *shadowRunner {
+	m.streams.Inc()
+	r := &shadowRunner{
+		eng:     eng,
+		log:     logger,
+		metrics: m,
+		wake:    make(chan struct{}, 1),
+		done:    make(chan struct{}),
+	}
+	if err := eng.SetSampleRate(sampleRate); err != nil {
+		r.fail()
+		logger.Warn("shadow engine does not support the stream's sample rate, disabling shadow for this stream",
+			"sample_rate", sampleRate, "error", err)
+	}
+	clock, err := newFrameClock(eng)
+	if err != nil {
+		clock = primaryClock
+	}
+	r.cfg, r.clock = cfg, clock
+	r.bd = newBoundaryDetector(cfg, clock)
+	r.frameAligned = clock == primaryClock
+	go r.run()
+	return r
+}
+
+// process queues the PCM the primary just processed for the shadow engine.
+// primary holds the primary's per-frame speech decisions for this chunk and
+// primaryInSpeech its boundary state after them; both are copied.
+func (r *shadowRunner) process(pcm []byte, sampleRate uint32, primary []bool, primaryInSpeech bool) {
+	r.send(shadowJob{
+		pcm:             slices.Clone(pcm),
+		sampleRate:      sampleRate,
+		primary:         slices.Clone(primary),
+		primaryInSpeech: primaryInSpeech,
+	})
+}
+
+// reset follows a stream reset: the shadow engine and detector start over.
+// Decisions still pending comparison are dropped. partial is the PCM the
+// primary re-buffered after its reset; a frame-aligned shadow re-buffers it
+// too, so both stay on the same frame grid.
+func (r *shadowRunner) reset(partial []byte, sampleRate uint32) {
+	r.send(shadowJob{pcm: slices.Clone(partial), sampleRate: sampleRate, reset: true})
+}
+
+// send queues job, disabling the shadow when its queue is full.
+func (r *shadowRunner) send(job shadowJob) {
+	if r.stopped.Load() {
+		return
+	}
+	r.mu.Lock()
+	if r.queuedBytes+len(job.pcm) > shadowMaxQueuedBytes {
+		r.mu.Unlock()
+		r.stopped.Store(true)
+		r.metrics.errors.Inc()
+		r.log.Warn("shadow engine fell behind, disabling shadow for this stream",
+			"queued_bytes", r.queuedBytes)
+		return
+	}
+	r.jobs = append(r.jobs, job)
+	r.queuedBytes += len(job.pcm)
+	r.mu.Unlock()
+	r.notify()
+}
+
+// notify wakes the worker.
+func (r *shadowRunner) notify() {
+	select {
+	case r.wake <- struct{}{}:
+	default:
+	}
+}
+
+// next returns the next queued job, waiting for one; ok is false once the
+// queue is closed and drained.
+func (r *shadowRunner) next() (job shadowJob, ok bool) {
+	for {
+		r.mu.Lock()
+		if len(r.jobs) > 0 {
+			job = r.jobs[0]
+			r.jobs[0] = shadowJob{}
+			r.jobs = r.jobs[1:]
+			r.queuedBytes -= len(job.pcm)
+			r.mu.Unlock()
+			return job, true
+		}
+		closed := r.closed
+		r.mu.Unlock()
+		if closed {
+			return job, false
+		}
+		<-r.wake
+	}
+}
+
+// run is the shadow's worker: it processes the queued jobs until close. A
+// panic in the shadow engine disables the shadow like an error: it runs on
+// its own goroutine, outside the RPC recovery interceptor, and must not take
+// the primary streams down with it.
+func (r *shadowRunner) run() {
+	defer close(r.done)
+	defer func() {
+		if p := recover(); p != nil {
+			r.metrics.panics.Inc()
+			r.fail()
+			r.log.Error("shadow engine panicked, disabling shadow for this stream",
+				"panic", fmt.Sprint(p),
+				"stack", string(debug.Stack()),
+			)
+		}
+	}()
+	for {
+		job, ok := r.next()
+		if !ok {
+			return
+		}
+		if r.failed {
+			continue
+		}
+		if job.reset {
+			r.restart(job.pcm, job.sampleRate)
+		} else {
+			r.compare(job.pcm, job.sampleRate, job.primary, job.primaryInSpeech)
+		}
+		r.buffered.Store(int64(engine.BufferedSamples(r.eng)))
+	}
+}
+
+// fail disables the shadow for the rest of the stream.
+func (r *shadowRunner) fail() {
+	r.failed = true
+	r.stopped.Store(true)
+	r.buffered.Store(0)
+	r.metrics.errors.Inc()
+}
+
+// compare runs the shadow engine on a chunk and records where its decisions
+// disagree with the primary's.
+func (r *shadowRunner) compare(pcm []byte, sampleRate uint32, primary []bool, primaryInSpeech bool) {
+	r.pending = append(r.pending, primary...)
+	results, err := r.eng.ProcessChunk(pcm, sampleRate)
+	if err != nil {
+		r.fail()
+		r.log.Warn("shadow engine failed, disabling shadow for this stream", "error", err)
+		return
+	}
+	speech := make([]bool, 0, len(results))
+	for _, res := range results {
+		for _, evt := range r.bd.process(res) {
+			if evt.Type == napv1.SpeechEventType_SPEECH_EVENT_TYPE_START {
+				r.shadowSegments++
+				r.metrics.shadowSegments.Inc()
+			}
+		}
+		speech = append(speech, r.bd.speech)
+	}
+
+	var compared, disagreed int64
+	if r.frameAligned {
+		n := min(len(r.pending), len(speech))
+		for i := 0; i < n; i++ {
+			compared++
+			if r.pending[i] != speech[i] {
+				disagreed++
+			}
+		}
+		r.pending = r.pending[n:]
+	} else if len(primary) > 0 {
+		r.pending = r.pending[:0]
+		compared = 1
+		if primaryInSpeech != r.bd.inSpeech {
+			disagreed = 1
+		}
+	}
+	r.frames += compared
+	r.disagreements += disagreed
+	r.metrics.frames.Add(compared)
+	r.metrics.disagreements.Add(disagreed)
+}
+
+// restart resets the shadow engine and detector (see reset).
+func (r *shadowRunner) restart(partial []byte, sampleRate uint32) {
+	r.pending = r.pending[:0]
+	if err := r.eng.Reset(); err != nil {
+		r.fail()
+		r.log.Warn("shadow engine reset failed, disabling shadow for this stream", "error", err)
+		return
+	}
+	if r.frameAligned && len(partial) > 0 {
+		if _, err := r.eng.ProcessChunk(partial, sampleRate); err != nil {
+			r.fail()
+			r.log.Warn("shadow engine reset failed, disabling shadow for this stream", "error", err)
+			return
+		}
+	}
+	prev := r.bd
+	r.bd = newBoundaryDetector(r.cfg, r.clock)
+	r.bd.inheritNoiseFloor(prev)
+}
+
+// bufferedSamples returns the samples the shadow engine buffered short of a
+// frame after the last chunk it processed.
+func (r *shadowRunner) bufferedSamples() int {
+	if r.stopped.Load() {
+		return 0
+	}
+	return int(r.buffered.Load())
+}
+
+// recordPrimarySegment counts a primary SPEECH_START on a shadowed stream, so
+// segment counts can be compared on the same population of streams.
+func (r *shadowRunner) recordPrimarySegment() {
+	r.primarySegs++
+	r.metrics.primarySegs.Inc()
+}
+
+// close waits for the shadow to process the chunks it was sent, releases its
+// engine and logs the stream's comparison summary.
+func (r *shadowRunner) close() {
+	r.mu.Lock()
+	r.closed = true
+	r.mu.Unlock()
+	r.notify()
+	<-r.done
+	r.eng.Close()
+	var rate float64
+	if r.frames > 0 {
+		rate = float64(r.disagreements) / float64(r.frames)
+	}
+	r.log.Info("shadow comparison",
+		"compared_frames", r.frames,
+		"disagreements", r.disagreements,
+		"disagreement_rate", rate,
+		"primary_segments", r.primarySegs,
+		"shadow_segments", r.shadowSegments,
+		"shadow_failed", r.stopped.Load(),
+	)
+}
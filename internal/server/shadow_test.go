@@ -0,0 +1,252 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"io"
+	"testing"
+
+	napv1 "github.com/nupi-ai/nupi/api/nap/v1"
+
+	"github.com/nupi-ai/plugin-vad-local-silero/internal/config"
+	"github.com/nupi-ai/plugin-vad-local-silero/internal/engine"
+)
+
+// constEngine reports the same decision for every 20ms frame, or fails every
+// call when err is set.
+type constEngine struct {
+	engine.StubEngine
+	speech bool
+	err    error
+}
+
+func (e *constEngine) ProcessChunk(pcm []byte, sampleRate uint32) ([]engine.Result, error) {
+	if e.err != nil {
+		return nil, e.err
+	}
+	results, err := e.StubEngine.ProcessChunk(pcm, sampleRate)
+	for i := range results {
+		results[i].Confidence = 0
+		if e.speech {
+			results[i].Confidence = 1
+		}
+	}
+	return results, err
+}
+
+// runShadowStream sends a full stub speech cycle and returns the number of
+// events the client received.
+func runShadowStream(t *testing.T, srv *Server) int {
+	t.Helper()
+	client, cleanup := startTestServerWith(t, srv)
+	defer cleanup()
+
+	stream, err := client.DetectSpeech(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	chunk := make([]byte, 640)
+	for i := 0; i < engine.StubToggleInterval*3-1; i++ {
+		if err := stream.Send(&napv1.DetectSpeechRequest{
+			PcmData: chunk,
+			Format:  &napv1.AudioFormat{SampleRate: 16000},
+		}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	stream.CloseSend()
+
+	events := 0
+	for {
+		_, err := stream.Recv()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		events++
+	}
+	return events
+}
+
+func TestShadowConfigOnlyComparison(t *testing.T) {
+	// Same engine, stricter shadow min_speech: frames agree, but the shadow
+	// never reaches START, so segment counts diverge.
+	shadowMinSpeech := 1100
+	cfg := config.Config{
+		Threshold:            0.5,
+		MinSpeechDurationMs:  20,
+		MinSilenceDurationMs: 20,
+		Shadow:               config.VADParams{MinSpeechDurationMs: &shadowMinSpeech},
+		ShadowPercent:        100,
+	}
+	srv := New(cfg, nil, func() engine.Engine { return engine.NewStubEngine() })
+	if events := runShadowStream(t, srv); events == 0 {
+		t.Fatal("primary events must not be affected by the shadow")
+	}
+
+	snap := srv.Metrics().Snapshot()
+	frames := int64(engine.StubToggleInterval*3 - 1)
+	if got := snap["vad_shadow_compared_frames_total"]; got != frames {
+		t.Errorf("compared frames = %d, want %d", got, frames)
+	}
+	if got := snap["vad_shadow_frame_disagreements_total"]; got != 0 {
+		t.Errorf("disagreements = %d, want 0 (same engine)", got)
+	}
+	if got := snap["vad_shadow_primary_segments_total"]; got != 1 {
+		t.Errorf("primary segments = %d, want 1", got)
+	}
+	if got := snap["vad_shadow_segments_total"]; got != 0 {
+		t.Errorf("shadow segments = %d, want 0", got)
+	}
+}
+
+func TestShadowEngineDisagreements(t *testing.T) {
+	cfg := config.Config{
+		Threshold:            0.5,
+		MinSpeechDurationMs:  20,
+		MinSilenceDurationMs: 20,
+		ShadowPercent:        100,
+	}
+	srv := New(cfg, nil,
+		func() engine.Engine { return engine.NewStubEngine() },
+		WithShadowEngine(func() engine.Engine { return &constEngine{speech: true} }),
+	)
+	runShadowStream(t, srv)
+
+	// The stub is silent for 49 + 50 frames of the 149 sent; an always-speech
+	// shadow disagrees on exactly those.
+	snap := srv.Metrics().Snapshot()
+	want := int64(engine.StubToggleInterval*2 - 1)
+	if got := snap["vad_shadow_frame_disagreements_total"]; got != want {
+		t.Errorf("disagreements = %d, want %d", got, want)
+	}
+}
+
+func TestShadowFailureDoesNotFailStream(t *testing.T) {
+	cfg := config.Config{
+		Threshold:            0.5,
+		MinSpeechDurationMs:  20,
+		MinSilenceDurationMs: 20,
+		ShadowPercent:        100,
+	}
+	srv := New(cfg, nil,
+		func() engine.Engine { return engine.NewStubEngine() },
+		WithShadowEngine(func() engine.Engine { return &constEngine{err: errors.New("boom")} }),
+	)
+	if events := runShadowStream(t, srv); events == 0 {
+		t.Fatal("expected primary events despite shadow failure")
+	}
+	if got := srv.Metrics().Snapshot()["vad_shadow_errors_total"]; got != 1 {
+		t.Errorf("shadow errors = %d, want 1 (shadow disabled after first failure)", got)
+	}
+}
+
+// panickingEngine panics on every chunk.
+type panickingEngine struct {
+	engine.StubEngine
+}
+
+func (e *panickingEngine) ProcessChunk([]byte, uint32) ([]engine.Result, error) {
+	panic("shadow model bug")
+}
+
+func TestShadowPanicDoesNotFailStream(t *testing.T) {
+	cfg := config.Config{
+		Threshold:            0.5,
+		MinSpeechDurationMs:  20,
+		MinSilenceDurationMs: 20,
+		ShadowPercent:        100,
+	}
+	srv := New(cfg, nil,
+		func() engine.Engine { return engine.NewStubEngine() },
+		WithShadowEngine(func() engine.Engine { return &panickingEngine{} }),
+	)
+	// runShadowStream fails the test unless the primary stream completes.
+	if events := runShadowStream(t, srv); events == 0 {
+		t.Fatal("expected primary events despite the shadow panic")
+	}
+	snap := srv.Metrics().Snapshot()
+	if got := snap["vad_shadow_errors_total"]; got != 1 {
+		t.Errorf("shadow errors = %d, want 1", got)
+	}
+	if got := snap["vad_panics_total"]; got != 1 {
+		t.Errorf("vad_panics_total = %d, want 1", got)
+	}
+}
+
+// stalledEngine blocks every call until release is closed.
+type stalledEngine struct {
+	engine.StubEngine
+	release chan struct{}
+}
+
+func (e *stalledEngine) ProcessChunk(pcm []byte, sampleRate uint32) ([]engine.Result, error) {
+	<-e.release
+	return e.StubEngine.ProcessChunk(pcm, sampleRate)
+}
+
+func TestShadowFallingBehindDoesNotDelayStream(t *testing.T) {
+	cfg := config.Config{
+		Threshold:            0.5,
+		MinSpeechDurationMs:  20,
+		MinSilenceDurationMs: 20,
+		ShadowPercent:        100,
+	}
+	release := make(chan struct{})
+	srv := New(cfg, nil,
+		func() engine.Engine { return engine.NewStubEngine() },
+		WithShadowEngine(func() engine.Engine { return &stalledEngine{release: release} }),
+	)
+	client, cleanup := startTestServerWith(t, srv)
+	defer cleanup()
+
+	stream, err := client.DetectSpeech(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	// 24 chunks of 2 s: the shadow falls more than shadowMaxQueuedBytes
+	// behind.
+	for i := 0; i < 24; i++ {
+		if err := stream.Send(&napv1.DetectSpeechRequest{
+			PcmData: make([]byte, 64<<10),
+			Format:  &napv1.AudioFormat{SampleRate: 16000},
+		}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	stream.CloseSend()
+
+	// The primary's segments arrive while the shadow is still stuck on the
+	// first chunk.
+	for {
+		evt, err := stream.Recv()
+		if err != nil {
+			t.Fatalf("stream failed before END: %v", err)
+		}
+		if evt.GetType() == napv1.SpeechEventType_SPEECH_EVENT_TYPE_END {
+			break
+		}
+	}
+	close(release)
+	for {
+		if _, err := stream.Recv(); err == io.EOF {
+			break
+		} else if err != nil {
+			t.Fatal(err)
+		}
+	}
+	if got := srv.Metrics().Snapshot()["vad_shadow_errors_total"]; got != 1 {
+		t.Errorf("shadow errors = %d, want 1 (shadow dropped when it fell behind)", got)
+	}
+}
+
+func TestShadowSelected(t *testing.T) {
+	if shadowSelected(0) {
+		t.Error("percent=0 must never select a shadow")
+	}
+	if !shadowSelected(100) {
+		t.Error("percent=100 must always select a shadow")
+	}
+}
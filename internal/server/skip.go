@@ -0,0 +1,37 @@
+package server
+
+import (
+	"github.com/nupi-ai/plugin-vad-local-silero/internal/config"
+	"github.com/nupi-ai/plugin-vad-local-silero/internal/engine"
+)
+
+// strideControl sets a stream's engine stride for the inference budget.
+type strideControl struct {
+	eng    engine.Engine
+	budget int // 2 while the inference budget degrades the stream
+	cur    int
+}
+
+// apply passes a changed stride on to the engine.
+func (c *strideControl) apply() {
+	if n := max(c.budget, 1); n != c.cur {
+		engine.SetStride(c.eng, n)
+		c.cur = n
+	}
+}
+
+// frameSkip returns the engine's FrameSkip for fs, whose durations are
+// counted in clock's frames. A disabled fs gives the zero FrameSkip, which
+// scores every frame.
+func frameSkip(fs config.FrameSkip, clock frameClock) engine.FrameSkip {
+	if !fs.Enabled() {
+		return engine.FrameSkip{}
+	}
+	margin := float32(fs.EffectiveMargin())
+	return engine.FrameSkip{
+		Stride: fs.Stride,
+		After:  max(1, clock.framesFor(fs.EffectiveAfterMs())),
+		Low:    margin,
+		High:   1 - margin,
+	}
+}
@@ -0,0 +1,139 @@
+package server
+
+import (
+	"context"
+	"io"
+	"slices"
+	"testing"
+
+	napv1 "github.com/nupi-ai/nupi/api/nap/v1"
+
+	"github.com/nupi-ai/plugin-vad-local-silero/internal/config"
+	"github.com/nupi-ai/plugin-vad-local-silero/internal/engine"
+)
+
+// strideRecorder records the strides set on it.
+type strideRecorder struct {
+	engine.StubEngine
+	strides []int
+}
+
+func (e *strideRecorder) SetStride(n int) { e.strides = append(e.strides, n) }
+
+func TestFrameSkipConversion(t *testing.T) {
+	clock := frameClock{frameSamples: 320, sampleRate: 16000} // 20 ms
+	got := frameSkip(config.FrameSkip{Stride: 3, AfterMs: 60, Margin: 0.2}, clock)
+	want := engine.FrameSkip{Stride: 3, After: 3, Low: 0.2, High: 0.8}
+	if got != want {
+		t.Errorf("frameSkip = %+v, want %+v", got, want)
+	}
+	if got := frameSkip(config.FrameSkip{}, clock); got != (engine.FrameSkip{}) {
+		t.Errorf("disabled frame_skip = %+v, want the zero FrameSkip", got)
+	}
+}
+
+func TestStrideControl(t *testing.T) {
+	eng := &strideRecorder{}
+	c := &strideControl{eng: eng, cur: 1}
+	c.apply()
+	c.budget = 2
+	c.apply()
+	c.apply()
+	c.budget = 0
+	c.apply()
+	if want := []int{2, 1}; !slices.Equal(eng.strides, want) {
+		t.Errorf("strides set = %v, want %v", eng.strides, want)
+	}
+}
+
+func TestDetectSpeechFrameSkip(t *testing.T) {
+	cfg := config.Config{
+		Threshold:            0.5,
+		MinSpeechDurationMs:  20,
+		MinSilenceDurationMs: 20,
+	}
+	// 40 frames of silence, then 40 of speech, each alternating between
+	// two probabilities so repeated frames stand out.
+	var script engine.Script
+	for i := range 80 {
+		conf := float32(0.01) + float32(i%2)*0.01
+		if i >= 40 {
+			conf = 0.95 + float32(i%2)*0.01
+		}
+		script.Steps = append(script.Steps, engine.ScriptStep{Frames: 1, Confidence: conf})
+	}
+	newEngine := func() engine.Engine { return engine.NewStubEngine(engine.WithScript(script)) }
+	// A warm pool (expected_streams) hands out wrapped engines, which
+	// must still reach the engine's frame skipping.
+	pool := engine.NewPool(newEngine, 1)
+	defer pool.Close()
+	pool.Warm()
+	for _, tc := range []struct {
+		name      string
+		newEngine func() engine.Engine
+	}{
+		{"engine", newEngine},
+		{"pooled", pool.Get},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			testDetectSpeechFrameSkip(t, New(cfg, nil, tc.newEngine))
+		})
+	}
+}
+
+func testDetectSpeechFrameSkip(t *testing.T, srv *Server) {
+	client, cleanup := startTestServerWith(t, srv)
+	defer cleanup()
+
+	stream, err := client.DetectSpeech(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := stream.Send(&napv1.DetectSpeechRequest{ConfigJson: `{"emit_probabilities": true, "frame_skip": {"stride": 4, "after_ms": 100}}`}); err != nil {
+		t.Fatal(err)
+	}
+	for range 80 {
+		if err := stream.Send(&napv1.DetectSpeechRequest{Format: &napv1.AudioFormat{SampleRate: 16000}, PcmData: make([]byte, 640)}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	stream.CloseSend()
+
+	var probs []float32
+	starts := 0
+	for {
+		evt, err := stream.Recv()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		switch evt.GetType() {
+		case ProbabilityEventType:
+			probs = append(probs, evt.GetConfidence())
+		case napv1.SpeechEventType_SPEECH_EVENT_TYPE_START:
+			starts++
+		}
+	}
+	if len(probs) != 80 || starts != 1 {
+		t.Fatalf("%d probabilities, %d START; want 80 and 1", len(probs), starts)
+	}
+	// Full rate for the first 100 ms (5 frames), then every 4th frame from
+	// the frame completing the run, though each chunk is a single frame.
+	for i := 1; i < 5; i++ {
+		if probs[i] == probs[i-1] {
+			t.Errorf("frame %d repeated before frame_skip.after_ms: %v", i, probs[:10])
+		}
+	}
+	for i := 5; i < 8; i++ {
+		if probs[i] != probs[4] {
+			t.Errorf("frame %d = %v, want frame 4's %v repeated", i, probs[i], probs[4])
+		}
+	}
+	// Speech is seen within a stride of its onset and brings full rate
+	// back right away.
+	if probs[43] < 0.9 || probs[43] == probs[44] {
+		t.Errorf("frames 40-47 = %v, want speech at full rate by frame 43", probs[40:48])
+	}
+}
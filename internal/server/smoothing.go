@@ -0,0 +1,57 @@
+package server
+
+import (
+	"slices"
+
+	"github.com/nupi-ai/plugin-vad-local-silero/internal/config"
+)
+
+// smoother smooths frame probabilities over a sliding window of the last
+// frames. Until the window fills, it smooths over the frames seen so far, so
+// the first frames of a stream are not biased towards silence.
+type smoother struct {
+	median bool
+	window []float32 // ring of the last len(window) probabilities
+	next   int
+	count  int
+	sum    float64
+	sorted []float32 // scratch for the median
+}
+
+// newSmoother returns nil when smoothing is disabled (fewer than 2 frames).
+func newSmoother(cfg config.Config) *smoother {
+	if cfg.SmoothingFrames < 2 {
+		return nil
+	}
+	sm := &smoother{
+		median: cfg.SmoothingMethod == config.SmoothingMedian,
+		window: make([]float32, cfg.SmoothingFrames),
+	}
+	if sm.median {
+		sm.sorted = make([]float32, 0, cfg.SmoothingFrames)
+	}
+	return sm
+}
+
+// add records p and returns the smoothed probability.
+func (sm *smoother) add(p float32) float32 {
+	if sm.count == len(sm.window) {
+		sm.sum -= float64(sm.window[sm.next])
+	} else {
+		sm.count++
+	}
+	sm.window[sm.next] = p
+	sm.sum += float64(p)
+	sm.next = (sm.next + 1) % len(sm.window)
+
+	if !sm.median {
+		return float32(sm.sum / float64(sm.count))
+	}
+	sm.sorted = append(sm.sorted[:0], sm.window[:sm.count]...)
+	slices.Sort(sm.sorted)
+	mid := sm.count / 2
+	if sm.count%2 == 1 {
+		return sm.sorted[mid]
+	}
+	return (sm.sorted[mid-1] + sm.sorted[mid]) / 2
+}
@@ -0,0 +1,81 @@
+package server
+
+import (
+	"testing"
+
+	napv1 "github.com/nupi-ai/nupi/api/nap/v1"
+
+	"github.com/nupi-ai/plugin-vad-local-silero/internal/config"
+	"github.com/nupi-ai/plugin-vad-local-silero/internal/engine"
+)
+
+func TestSmoother(t *testing.T) {
+	if sm := newSmoother(config.Config{SmoothingFrames: 1}); sm != nil {
+		t.Error("smoothing_frames=1 should disable smoothing")
+	}
+
+	mean := newSmoother(config.Config{SmoothingFrames: 3})
+	median := newSmoother(config.Config{SmoothingFrames: 3, SmoothingMethod: config.SmoothingMedian})
+	tests := []struct {
+		p, mean, median float32
+	}{
+		{0.3, 0.3, 0.3},
+		{0.9, 0.6, 0.6}, // window still filling: [0.3 0.9]
+		{0.6, 0.6, 0.6}, // [0.3 0.9 0.6]
+		{0.0, 0.5, 0.6}, // [0.9 0.6 0.0]
+		{0.0, 0.2, 0.0}, // [0.6 0.0 0.0]
+		{0.9, 0.3, 0.0}, // [0.0 0.0 0.9]
+	}
+	for i, tt := range tests {
+		if got := mean.add(tt.p); abs32(got-tt.mean) > 1e-6 {
+			t.Errorf("frame %d: mean = %v, want %v", i, got, tt.mean)
+		}
+		if got := median.add(tt.p); abs32(got-tt.median) > 1e-6 {
+			t.Errorf("frame %d: median = %v, want %v", i, got, tt.median)
+		}
+	}
+}
+
+func abs32(v float32) float32 {
+	if v < 0 {
+		return -v
+	}
+	return v
+}
+
+func TestBoundaryDetectorSmoothingSuppressesFlapping(t *testing.T) {
+	clock := frameClock{frameSamples: 320, sampleRate: 16000}
+	cfg := config.Config{
+		Threshold:            0.5,
+		NegThreshold:         0.5,
+		MinSpeechDurationMs:  20,
+		MinSilenceDurationMs: 20,
+	}
+	// Speech with isolated dropouts, as on noisy audio.
+	probs := []float32{0.1, 0.9, 0.9, 0.1, 0.9, 0.9, 0.1, 0.9, 0.9, 0.1, 0.1, 0.1}
+
+	segments := func(cfg config.Config) int {
+		bd := newBoundaryDetector(cfg, clock)
+		n := 0
+		for _, p := range probs {
+			for _, evt := range bd.process(engine.Result{Confidence: p}) {
+				if evt.Type == napv1.SpeechEventType_SPEECH_EVENT_TYPE_START {
+					n++
+				}
+			}
+		}
+		return n
+	}
+
+	if n := segments(cfg); n != 3 {
+		t.Errorf("unsmoothed segments = %d, want 3 (flapping)", n)
+	}
+	cfg.SmoothingFrames = 3
+	if n := segments(cfg); n != 1 {
+		t.Errorf("mean-smoothed segments = %d, want 1", n)
+	}
+	cfg.SmoothingMethod = config.SmoothingMedian
+	if n := segments(cfg); n != 1 {
+		t.Errorf("median-smoothed segments = %d, want 1", n)
+	}
+}
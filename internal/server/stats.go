@@ -0,0 +1,65 @@
+package server
+
+import (
+	"time"
+)
+
+// streamStats collects the per-chunk statistics of one stream for its
+// StreamSummary and the "stream closed" log line.
+type streamStats struct {
+	chunks        int64
+	bytes         int64
+	maxChunkBytes int
+	inference     stageHistogram
+}
+
+// chunk records a received PCM chunk of n bytes.
+func (st *streamStats) chunk(n int) {
+	st.chunks++
+	st.bytes += int64(n)
+	st.maxChunkBytes = max(st.maxChunkBytes, n)
+}
+
+// inferred records the engine time spent on a chunk.
+func (st *streamStats) inferred(d time.Duration) {
+	st.inference.add(max(d, 0))
+}
+
+// fill copies the statistics into sum.
+func (st *streamStats) fill(sum *StreamSummary) {
+	sum.Chunks = st.chunks
+	sum.MaxChunkBytes = st.maxChunkBytes
+	sum.Inference = st.inference.stats()
+}
+
+// logStreamClosed writes the summary of a closed stream as one structured
+// log line, so slow endpointing reports can be traced to a stream's audio,
+// chunking and inference time.
+func (s *Server) logStreamClosed(sum StreamSummary) {
+	attrs := []any{
+		"session_id", sum.SessionID,
+		"stream_id", sum.StreamID,
+		"variant", sum.Variant,
+		"duration_ms", sum.ClosedAt.Sub(sum.OpenedAt).Milliseconds(),
+		"audio_s", sum.AudioDuration.Seconds(),
+		"speech_s", sum.SpeechDuration.Seconds(),
+		"frames", sum.Frames,
+		"segments", sum.Segments,
+		"events", sum.Events,
+		"chunks", sum.Chunks,
+		"max_chunk_bytes", sum.MaxChunkBytes,
+		"inference_mean_ms", durationMs(sum.Inference.Mean),
+		"inference_p95_ms", durationMs(sum.Inference.P95),
+		"inference_max_ms", durationMs(sum.Inference.Max),
+	}
+	if sum.Model != "" {
+		attrs = append(attrs, "model", sum.Model)
+	}
+	if sum.EventsDropped > 0 {
+		attrs = append(attrs, "events_dropped", sum.EventsDropped)
+	}
+	if sum.Error != "" {
+		attrs = append(attrs, "error", sum.Error)
+	}
+	s.log.Info("stream closed", attrs...)
+}
@@ -0,0 +1,84 @@
+package server
+
+import (
+	"context"
+	"io"
+	"log/slog"
+	"strings"
+	"testing"
+
+	napv1 "github.com/nupi-ai/nupi/api/nap/v1"
+
+	"github.com/nupi-ai/plugin-vad-local-silero/internal/config"
+	"github.com/nupi-ai/plugin-vad-local-silero/internal/engine"
+)
+
+func TestDetectSpeechLogsStreamStats(t *testing.T) {
+	var out lockedBuffer
+	cfg := config.Config{Threshold: 0.5, MinSpeechDurationMs: 20, MinSilenceDurationMs: 20, RecentStreams: 1}
+	srv := New(cfg, slog.New(slog.NewTextHandler(&out, nil)), func() engine.Engine { return engine.NewStubEngine() })
+	client, cleanup := startTestServerWith(t, srv)
+	defer cleanup()
+
+	stream, err := client.DetectSpeech(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Two seconds of audio: 99 chunks of 20 ms and one of 40 ms, plus a
+	// config-only message that is not a chunk.
+	for i := 0; i < 100; i++ {
+		size := 640
+		if i == 50 {
+			size = 1280
+		}
+		if err := stream.Send(&napv1.DetectSpeechRequest{
+			SessionId: "sess-1",
+			StreamId:  "mic-1",
+			Format:    &napv1.AudioFormat{SampleRate: 16000},
+			PcmData:   make([]byte, size),
+		}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := stream.Send(&napv1.DetectSpeechRequest{ConfigJson: `{"threshold": 0.6}`}); err != nil {
+		t.Fatal(err)
+	}
+	stream.CloseSend()
+	for {
+		if _, err := stream.Recv(); err == io.EOF {
+			break
+		} else if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	recent := srv.RecentStreams("", 0)
+	if len(recent) != 1 {
+		t.Fatalf("recent streams = %d, want 1", len(recent))
+	}
+	sum := recent[0]
+	if sum.Chunks != 100 || sum.MaxChunkBytes != 1280 {
+		t.Errorf("chunks, max chunk = %d, %d, want 100, 1280", sum.Chunks, sum.MaxChunkBytes)
+	}
+	if sum.Inference.Count != 100 || sum.Inference.Max < sum.Inference.Mean || sum.Inference.P95 > sum.Inference.Max {
+		t.Errorf("inference stats = %+v, want 100 chunks with mean <= p95 <= max", sum.Inference)
+	}
+
+	var line string
+	for _, l := range strings.Split(out.String(), "\n") {
+		if strings.Contains(l, `msg="stream closed"`) {
+			line = l
+		}
+	}
+	for _, want := range []string{
+		"session_id=sess-1", "stream_id=mic-1", "variant=baseline", "audio_s=2.02",
+		"segments=1", "chunks=100", "max_chunk_bytes=1280", "inference_mean_ms=", "inference_p95_ms=",
+	} {
+		if !strings.Contains(line, want) {
+			t.Errorf("stream closed log %q lacks %s", line, want)
+		}
+	}
+	if strings.Contains(line, "error=") {
+		t.Errorf("clean stream logged an error: %q", line)
+	}
+}
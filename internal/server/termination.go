@@ -0,0 +1,235 @@
+package server
+
+import (
+	"fmt"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/durationpb"
+
+	napv1 "github.com/nupi-ai/nupi/api/nap/v1"
+)
+
+// ErrorDomain is the google.rpc.ErrorInfo domain of errors raised by the
+// adapter itself.
+const ErrorDomain = "vad-local-silero.nupi.ai"
+
+// Reasons the adapter terminates a stream on its own initiative. They are
+// sent as the google.rpc.ErrorInfo reason in the status details, with a
+// "retryable" metadata entry, so clients can tell "retry elsewhere" from
+// "do not retry" without parsing messages.
+const (
+	// ReasonShutdown: the instance is stopping. Retry on another instance.
+	ReasonShutdown = "SHUTDOWN"
+	// ReasonOverload: the instance has no capacity. Retry with backoff.
+	ReasonOverload = "OVERLOAD"
+	// ReasonIdleTimeout: the client sent nothing for too long. Open a new
+	// stream when there is audio again.
+	ReasonIdleTimeout = "IDLE_TIMEOUT"
+	// ReasonAdminAction: an operator ended the stream. Do not retry.
+	ReasonAdminAction = "ADMIN_ACTION"
+	// ReasonQuiesced: the instance is in maintenance and accepts no new
+	// streams. Retry on another instance.
+	ReasonQuiesced = "QUIESCED"
+	// ReasonSendStalled: the client stopped reading events for too long.
+	// Do not retry without fixing the client.
+	ReasonSendStalled = "SEND_STALLED"
+	// ReasonCPUBudget: the stream's audio took more inference time than
+	// cpu_budget allows. Do not retry the same audio.
+	ReasonCPUBudget = "CPU_BUDGET_EXCEEDED"
+	// ReasonBufferLimit: the stream held more PCM between chunks than
+	// max_buffered_bytes allows. Do not retry as is.
+	ReasonBufferLimit = "BUFFER_LIMIT_EXCEEDED"
+	// ReasonStreamLimit: the stream ran longer or sent more audio than
+	// max_stream_duration_s or max_stream_audio_mb allow. Open a new stream
+	// to continue.
+	ReasonStreamLimit = "STREAM_LIMIT_EXCEEDED"
+)
+
+// terminationCodes maps each reason to its status code and whether the same
+// request may be retried.
+var terminationCodes = map[string]struct {
+	code      codes.Code
+	retryable bool
+}{
+	ReasonShutdown:    {codes.Unavailable, true},
+	ReasonOverload:    {codes.ResourceExhausted, true},
+	ReasonIdleTimeout: {codes.DeadlineExceeded, false},
+	ReasonAdminAction: {codes.Aborted, false},
+	ReasonQuiesced:    {codes.Unavailable, true},
+	ReasonSendStalled: {codes.DeadlineExceeded, false},
+	ReasonCPUBudget:   {codes.ResourceExhausted, false},
+	ReasonBufferLimit: {codes.ResourceExhausted, false},
+	ReasonStreamLimit: {codes.ResourceExhausted, false},
+}
+
+// TerminationError returns the status error for a stream terminated for
+// reason, with a google.rpc.ErrorInfo detail carrying the reason.
+func TerminationError(reason, msg string) error {
+	tc, ok := terminationCodes[reason]
+	if !ok {
+		tc.code = codes.Internal
+	}
+	st := status.New(tc.code, msg)
+	withInfo, err := st.WithDetails(&errdetails.ErrorInfo{
+		Reason:   reason,
+		Domain:   ErrorDomain,
+		Metadata: map[string]string{"retryable": strconv.FormatBool(tc.retryable)},
+	})
+	if err != nil {
+		return st.Err()
+	}
+	return withInfo.Err()
+}
+
+// RetryPushbackKey is the gRPC trailer key grpc-go clients with a retry
+// policy honor as the delay before the next attempt.
+const RetryPushbackKey = "grpc-retry-pushback-ms"
+
+// OverloadRetryDelay is the retry hint of streams rejected by max_streams:
+// long enough for a few streams to end, short enough to stay conversational.
+const OverloadRetryDelay = time.Second
+
+// overloadedError is TerminationError(ReasonOverload, ...) with a
+// google.rpc.RetryInfo detail carrying hint.
+func overloadedError(limit int, hint time.Duration) error {
+	st := status.Convert(TerminationError(ReasonOverload, fmt.Sprintf("instance is at its limit of %d concurrent streams, retry in %s or on another instance", limit, hint)))
+	withRetry, err := st.WithDetails(&errdetails.RetryInfo{RetryDelay: durationpb.New(hint)})
+	if err != nil {
+		return st.Err()
+	}
+	return withRetry.Err()
+}
+
+// TerminationReason extracts the reason and retryability from an error
+// returned by TerminationError (as received by a client). ok is false for
+// errors without an adapter ErrorInfo.
+func TerminationReason(err error) (reason string, retryable bool, ok bool) {
+	st, isStatus := status.FromError(err)
+	if !isStatus {
+		return "", false, false
+	}
+	for _, d := range st.Details() {
+		if info, isInfo := d.(*errdetails.ErrorInfo); isInfo && info.GetDomain() == ErrorDomain {
+			return info.GetReason(), info.GetMetadata()["retryable"] == "true", true
+		}
+	}
+	return "", false, false
+}
+
+// streamHandle lets the server end a running stream from outside its
+// handler goroutine.
+type streamHandle struct {
+	stop  chan struct{}
+	once  sync.Once
+	cause error
+
+	// op is the stream operation in progress (opRecv, opSend or opNone)
+	// and since when, in Unix nanoseconds, for the watchdog; ids names the
+	// stream in its diagnostics once known.
+	op    atomic.Int32
+	since atomic.Int64
+	ids   atomic.Pointer[streamIDs]
+
+	// opened, peer and frames describe the stream in ListActiveStreams.
+	opened time.Time
+	peer   string
+	frames atomic.Int64
+
+	// session is the session the stream joined, guarded by the server's
+	// streamsMu; nil until its first audio chunk or without a session_id.
+	session *session
+}
+
+type streamIDs struct {
+	session string
+	stream  string
+	variant string
+}
+
+func newStreamHandle(opened time.Time, peer string) *streamHandle {
+	return &streamHandle{stop: make(chan struct{}), opened: opened, peer: peer}
+}
+
+// terminate ends the stream with err. Only the first call has an effect.
+func (h *streamHandle) terminate(err error) {
+	h.once.Do(func() {
+		h.cause = err
+		close(h.stop)
+	})
+}
+
+// trackStream registers h as active. It returns false, without registering
+// it, when max_streams streams are already active.
+func (s *Server) trackStream(h *streamHandle) bool {
+	s.streamsMu.Lock()
+	defer s.streamsMu.Unlock()
+	if s.cfg.MaxStreams > 0 && len(s.streams) >= s.cfg.MaxStreams {
+		return false
+	}
+	s.streams[h] = struct{}{}
+	return true
+}
+
+func (s *Server) untrackStream(h *streamHandle) {
+	s.streamsMu.Lock()
+	delete(s.streams, h)
+	s.leaveSession(h)
+	s.streamsMu.Unlock()
+}
+
+// TerminateStreams ends every active stream with TerminationError(reason,
+// msg) and returns how many were terminated. Streams opened afterwards are
+// not affected.
+func (s *Server) TerminateStreams(reason, msg string) int {
+	err := TerminationError(reason, msg)
+	s.streamsMu.Lock()
+	defer s.streamsMu.Unlock()
+	for h := range s.streams {
+		h.terminate(err)
+	}
+	return len(s.streams)
+}
+
+// recvResult is one stream.Recv outcome, passed from the receive goroutine.
+type recvResult struct {
+	req *napv1.DetectSpeechRequest
+	at  time.Time // when Recv returned, the start of the message's latency
+	err error
+}
+
+// receive reads the stream on its own goroutine so the handler can also
+// wait on termination, and so reading runs ahead of inference: up to depth
+// requests wait for the handler, after which the goroutine stops reading
+// and gRPC flow control holds the client back. Each request is checked
+// before it is queued; a request failing check is passed on as its error.
+// The goroutine exits after the first error or once done is closed; a panic
+// on it ends the stream h through g.
+func receive(stream napv1.VoiceActivityDetectionService_DetectSpeechServer, h *streamHandle, depth int, check func(*napv1.DetectSpeechRequest) error, g *panicGuard, done <-chan struct{}) <-chan recvResult {
+	out := make(chan recvResult, depth)
+	go func() {
+		defer g.recoverTo(h, "receive")
+		for {
+			req, err := stream.Recv()
+			if err == nil {
+				if err = check(req); err != nil {
+					req = nil
+				}
+			}
+			select {
+			case out <- recvResult{req, time.Now(), err}:
+			case <-done:
+				return
+			}
+			if err != nil {
+				return
+			}
+		}
+	}()
+	return out
+}
@@ -0,0 +1,361 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"io"
+	"slices"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	napv1 "github.com/nupi-ai/nupi/api/nap/v1"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	"github.com/nupi-ai/plugin-vad-local-silero/internal/config"
+	"github.com/nupi-ai/plugin-vad-local-silero/internal/engine"
+)
+
+func TestTerminationReason(t *testing.T) {
+	tests := []struct {
+		reason    string
+		code      codes.Code
+		retryable bool
+	}{
+		{ReasonShutdown, codes.Unavailable, true},
+		{ReasonOverload, codes.ResourceExhausted, true},
+		{ReasonIdleTimeout, codes.DeadlineExceeded, false},
+		{ReasonAdminAction, codes.Aborted, false},
+		{ReasonQuiesced, codes.Unavailable, true},
+		{ReasonSendStalled, codes.DeadlineExceeded, false},
+		{ReasonCPUBudget, codes.ResourceExhausted, false},
+		{ReasonBufferLimit, codes.ResourceExhausted, false},
+	}
+	for _, tt := range tests {
+		err := TerminationError(tt.reason, "msg")
+		if got := status.Code(err); got != tt.code {
+			t.Errorf("%s: code = %v, want %v", tt.reason, got, tt.code)
+		}
+		reason, retryable, ok := TerminationReason(err)
+		if !ok || reason != tt.reason || retryable != tt.retryable {
+			t.Errorf("%s: TerminationReason = %q, %v, %v", tt.reason, reason, retryable, ok)
+		}
+	}
+
+	if _, _, ok := TerminationReason(status.Error(codes.Unavailable, "plain")); ok {
+		t.Error("status without ErrorInfo reported a termination reason")
+	}
+	if _, _, ok := TerminationReason(errors.New("not a status")); ok {
+		t.Error("non-status error reported a termination reason")
+	}
+}
+
+func TestTerminateStreamsDeliversReason(t *testing.T) {
+	srv := New(config.Config{Threshold: 0.5}, nil, func() engine.Engine { return engine.NewStubEngine() })
+	client, cleanup := startTestServerWith(t, srv)
+	defer cleanup()
+
+	stream, err := client.DetectSpeech(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := stream.Send(&napv1.DetectSpeechRequest{
+		SessionId: "sess-1",
+		Format:    &napv1.AudioFormat{SampleRate: 16000},
+		PcmData:   make([]byte, 640),
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	deadline := time.Now().Add(5 * time.Second)
+	for srv.TerminateStreams(ReasonShutdown, "shutting down") == 0 {
+		if time.Now().After(deadline) {
+			t.Fatal("stream never became active")
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+
+	_, err = stream.Recv()
+	if status.Code(err) != codes.Unavailable {
+		t.Fatalf("Recv error = %v, want Unavailable", err)
+	}
+	reason, retryable, ok := TerminationReason(err)
+	if !ok || reason != ReasonShutdown || !retryable {
+		t.Errorf("TerminationReason = %q, %v, %v, want SHUTDOWN, retryable", reason, retryable, ok)
+	}
+}
+
+func TestQuiesceRejectsNewStreamsOnly(t *testing.T) {
+	var hook []bool
+	srv := New(config.Config{Threshold: 0.5}, nil, func() engine.Engine { return engine.NewStubEngine() },
+		WithQuiesceHook(func(q bool) { hook = append(hook, q) }))
+	client, cleanup := startTestServerWith(t, srv)
+	defer cleanup()
+
+	send := func(stream napv1.VoiceActivityDetectionService_DetectSpeechClient) error {
+		return stream.Send(&napv1.DetectSpeechRequest{Format: &napv1.AudioFormat{SampleRate: 16000}, PcmData: make([]byte, 640)})
+	}
+	open, err := client.DetectSpeech(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := send(open); err != nil {
+		t.Fatal(err)
+	}
+	deadline := time.Now().Add(5 * time.Second)
+	for srv.ActiveStreams() == 0 {
+		if time.Now().After(deadline) {
+			t.Fatal("stream never became active")
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+
+	if n := srv.SetQuiesced(true); n != 1 {
+		t.Errorf("SetQuiesced(true) = %d active streams, want 1", n)
+	}
+	srv.SetQuiesced(true) // no change, no hook call
+
+	rejected, err := client.DetectSpeech(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	send(rejected)
+	_, err = rejected.Recv()
+	if reason, retryable, ok := TerminationReason(err); !ok || reason != ReasonQuiesced || !retryable || status.Code(err) != codes.Unavailable {
+		t.Errorf("new stream while quiesced: err = %v, want retryable UNAVAILABLE %s", err, ReasonQuiesced)
+	}
+
+	// The stream opened before quiesce keeps working.
+	if err := send(open); err != nil {
+		t.Fatal(err)
+	}
+	open.CloseSend()
+	if _, err := open.Recv(); err != io.EOF {
+		t.Errorf("open stream: err = %v, want clean EOF", err)
+	}
+
+	srv.SetQuiesced(false)
+	if !slices.Equal(hook, []bool{true, false}) {
+		t.Errorf("hook calls = %v, want [true false]", hook)
+	}
+}
+
+func TestMaxStreamsRejectsOverload(t *testing.T) {
+	srv := New(config.Config{Threshold: 0.5, MaxStreams: 1}, nil, func() engine.Engine { return engine.NewStubEngine() })
+	client, cleanup := startTestServerWith(t, srv)
+	defer cleanup()
+
+	send := func(stream napv1.VoiceActivityDetectionService_DetectSpeechClient) error {
+		return stream.Send(&napv1.DetectSpeechRequest{Format: &napv1.AudioFormat{SampleRate: 16000}, PcmData: make([]byte, 640)})
+	}
+	open, err := client.DetectSpeech(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := send(open); err != nil {
+		t.Fatal(err)
+	}
+	deadline := time.Now().Add(5 * time.Second)
+	for srv.ActiveStreams() == 0 {
+		if time.Now().After(deadline) {
+			t.Fatal("stream never became active")
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+
+	rejected, err := client.DetectSpeech(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	send(rejected)
+	_, err = rejected.Recv()
+	if reason, retryable, ok := TerminationReason(err); !ok || reason != ReasonOverload || !retryable || status.Code(err) != codes.ResourceExhausted {
+		t.Errorf("stream over max_streams: err = %v, want retryable RESOURCE_EXHAUSTED %s", err, ReasonOverload)
+	}
+	var hint time.Duration
+	for _, d := range status.Convert(err).Details() {
+		if info, ok := d.(*errdetails.RetryInfo); ok {
+			hint = info.GetRetryDelay().AsDuration()
+		}
+	}
+	if hint != OverloadRetryDelay {
+		t.Errorf("RetryInfo delay = %v, want %v", hint, OverloadRetryDelay)
+	}
+	if got := rejected.Trailer().Get(RetryPushbackKey); len(got) != 1 || got[0] != "1000" {
+		t.Errorf("trailer %s = %v, want [1000]", RetryPushbackKey, got)
+	}
+	if got := srv.Metrics().Counter("vad_streams_rejected_total", "reason", "overload").Value(); got != 1 {
+		t.Errorf("vad_streams_rejected_total = %d, want 1", got)
+	}
+
+	// Once the first stream ends, a new one is admitted.
+	open.CloseSend()
+	if _, err := open.Recv(); err != io.EOF {
+		t.Fatalf("open stream: err = %v, want clean EOF", err)
+	}
+	for srv.ActiveStreams() != 0 {
+		if time.Now().After(deadline) {
+			t.Fatal("stream never became inactive")
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+	next, err := client.DetectSpeech(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := send(next); err != nil {
+		t.Fatal(err)
+	}
+	next.CloseSend()
+	if _, err := next.Recv(); err != io.EOF {
+		t.Errorf("stream after capacity freed: err = %v, want clean EOF", err)
+	}
+}
+
+func TestMaxStreamDurationFlushesEnd(t *testing.T) {
+	srv := New(config.Config{Threshold: 0.5, MinSpeechDurationMs: 20, MinSilenceDurationMs: 20, MaxStreamDurationSec: 1}, nil,
+		func() engine.Engine { return engine.NewStubEngine() })
+	client, cleanup := startTestServerWith(t, srv)
+	defer cleanup()
+
+	stream, err := client.DetectSpeech(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Into the stub's first speech run, then idle until the limit.
+	for i := 0; i < engine.StubToggleInterval+10; i++ {
+		if err := stream.Send(&napv1.DetectSpeechRequest{Format: &napv1.AudioFormat{SampleRate: 16000}, PcmData: make([]byte, 640)}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	var types []napv1.SpeechEventType
+	for {
+		evt, err := stream.Recv()
+		if err != nil {
+			if reason, retryable, ok := TerminationReason(err); !ok || reason != ReasonStreamLimit || retryable || status.Code(err) != codes.ResourceExhausted {
+				t.Errorf("err = %v, want RESOURCE_EXHAUSTED %s", err, ReasonStreamLimit)
+			}
+			break
+		}
+		if evt.GetType() != napv1.SpeechEventType_SPEECH_EVENT_TYPE_ONGOING {
+			types = append(types, evt.GetType())
+		}
+	}
+	want := []napv1.SpeechEventType{napv1.SpeechEventType_SPEECH_EVENT_TYPE_START, napv1.SpeechEventType_SPEECH_EVENT_TYPE_END}
+	if !slices.Equal(types, want) {
+		t.Errorf("events = %v, want START then the END flushed at the limit", types)
+	}
+	if got := srv.Metrics().Counter("vad_stream_limit_exceeded_total", "limit", "duration").Value(); got != 1 {
+		t.Errorf("vad_stream_limit_exceeded_total{limit=duration} = %d, want 1", got)
+	}
+}
+
+func TestMaxStreamAudio(t *testing.T) {
+	srv := New(config.Config{Threshold: 0.5, MaxStreamAudioMB: 1, RecentStreams: 1}, nil, func() engine.Engine { return engine.NewStubEngine() })
+	client, cleanup := startTestServerWith(t, srv)
+	defer cleanup()
+
+	stream, err := client.DetectSpeech(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	// 17 chunks of 64 KiB: the 17th crosses 1 MiB and is not processed.
+	go func() {
+		for i := 0; i < 17; i++ {
+			if stream.Send(&napv1.DetectSpeechRequest{Format: &napv1.AudioFormat{SampleRate: 16000}, PcmData: make([]byte, 64<<10)}) != nil {
+				return
+			}
+		}
+		stream.CloseSend()
+	}()
+	for {
+		_, err := stream.Recv()
+		if err == nil {
+			continue
+		}
+		if reason, _, _ := TerminationReason(err); reason != ReasonStreamLimit {
+			t.Fatalf("err = %v, want %s", err, ReasonStreamLimit)
+		}
+		break
+	}
+	recent := srv.RecentStreams("", 0)
+	if len(recent) != 1 || recent[0].Frames != (16<<16)/640 {
+		t.Errorf("recent streams = %+v, want one with the frames of 16 chunks", recent)
+	}
+}
+
+// gateEngine blocks in ProcessChunk until gate is closed.
+type gateEngine struct {
+	engine.Engine
+	entered chan struct{}
+	gate    chan struct{}
+}
+
+func (e *gateEngine) ProcessChunk(pcm []byte, sampleRate uint32) ([]engine.Result, error) {
+	signal(e.entered)
+	<-e.gate
+	return e.Engine.ProcessChunk(pcm, sampleRate)
+}
+
+// countingStream counts the requests read from a bufferStream.
+type countingStream struct {
+	*bufferStream
+	reads atomic.Int64
+}
+
+func (c *countingStream) Recv() (*napv1.DetectSpeechRequest, error) {
+	c.reads.Add(1)
+	return c.bufferStream.Recv()
+}
+
+func TestDetectSpeechReadsAhead(t *testing.T) {
+	eng := &gateEngine{Engine: engine.NewStubEngine(), entered: make(chan struct{}, 1), gate: make(chan struct{})}
+	srv := New(config.Config{Threshold: 0.5, PipelineDepth: 3}, nil, func() engine.Engine { return eng })
+	stream := &countingStream{bufferStream: &bufferStream{ctx: context.Background()}}
+	for range 10 {
+		stream.requests = append(stream.requests, &napv1.DetectSpeechRequest{Format: &napv1.AudioFormat{SampleRate: 16000}, PcmData: make([]byte, 640)})
+	}
+	done := make(chan error, 1)
+	go func() { done <- srv.DetectSpeech(stream) }()
+
+	// While the first chunk is in the engine, the receiver queues three
+	// more and holds a fourth it has no room for, then stops reading.
+	<-eng.entered
+	deadline := time.Now().Add(time.Second)
+	for stream.reads.Load() < 5 && time.Now().Before(deadline) {
+		time.Sleep(time.Millisecond)
+	}
+	time.Sleep(20 * time.Millisecond)
+	if n := stream.reads.Load(); n != 5 {
+		t.Errorf("read %d requests during inference, want 5", n)
+	}
+
+	close(eng.gate)
+	if err := <-done; err != nil {
+		t.Fatal(err)
+	}
+	// 10 requests and the EOF.
+	if n := stream.reads.Load(); n != 11 {
+		t.Errorf("read %d times, want 11", n)
+	}
+}
+
+func TestDetectSpeechRejectsChunkInOrder(t *testing.T) {
+	// A malformed chunk fails the stream only after the chunks read before
+	// it were processed.
+	srv := New(config.Config{Threshold: 0.5, RecentStreams: 1}, nil, func() engine.Engine { return engine.NewStubEngine() })
+	stream := &bufferStream{ctx: context.Background()}
+	for range 3 {
+		stream.requests = append(stream.requests, &napv1.DetectSpeechRequest{Format: &napv1.AudioFormat{SampleRate: 16000}, PcmData: make([]byte, 640)})
+	}
+	stream.requests = append(stream.requests, &napv1.DetectSpeechRequest{PcmData: make([]byte, 641)})
+	err := srv.DetectSpeech(stream)
+	if status.Code(err) != codes.InvalidArgument {
+		t.Fatalf("err = %v, want InvalidArgument", err)
+	}
+	if recent := srv.RecentStreams("", 0); len(recent) != 1 || recent[0].Frames != 3 {
+		t.Errorf("recent streams = %+v, want the 3 frames before the bad chunk", recent)
+	}
+}
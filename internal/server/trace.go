@@ -0,0 +1,274 @@
+package server
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	napv1 "github.com/nupi-ai/nupi/api/nap/v1"
+
+	"github.com/nupi-ai/plugin-vad-local-silero/internal/config"
+	"github.com/nupi-ai/plugin-vad-local-silero/internal/engine"
+)
+
+// traceOpen is the first record of a trace file: the stream's identity and
+// the effective parameters every later frame was evaluated against.
+type traceOpen struct {
+	Record           string  `json:"record"` // "open"
+	Time             string  `json:"time"`
+	SessionID        string  `json:"session_id"`
+	StreamID         string  `json:"stream_id"`
+	Variant          string  `json:"variant"`
+	SampleRate       uint32  `json:"sample_rate"`
+	EngineSampleRate uint32  `json:"engine_sample_rate"`
+	FrameSamples     int     `json:"frame_samples"`
+	FrameDurationMs  float64 `json:"frame_duration_ms"`
+	Threshold        float64 `json:"threshold"`
+	NegThreshold     float64 `json:"neg_threshold"`
+	MinSpeechFrames  int     `json:"min_speech_frames"`
+	MinSilenceFrames int     `json:"min_silence_frames"`
+	MaxSpeechFrames  int     `json:"max_speech_frames,omitempty"`
+	MinGapFrames     int     `json:"min_gap_frames,omitempty"`
+	SmoothingFrames  int     `json:"smoothing_frames,omitempty"`
+	SmoothingMethod  string  `json:"smoothing_method,omitempty"`
+
+	AdaptiveSilence   *config.AdaptiveSilence   `json:"adaptive_silence,omitempty"`
+	AdaptiveThreshold *config.AdaptiveThreshold `json:"adaptive_threshold,omitempty"`
+}
+
+// adaptiveSilenceTrace returns the adaptive silence settings to trace, nil
+// when disabled.
+func adaptiveSilenceTrace(as config.AdaptiveSilence) *config.AdaptiveSilence {
+	if !as.Enabled() {
+		return nil
+	}
+	return &as
+}
+
+// adaptiveThresholdTrace returns the adaptive threshold settings to trace,
+// nil when disabled.
+func adaptiveThresholdTrace(at config.AdaptiveThreshold) *config.AdaptiveThreshold {
+	if !at.Enabled() {
+		return nil
+	}
+	return &at
+}
+
+// traceFrame records one inferred frame: the engine output, the boundary
+// detector's counters after the frame, and any events it emitted.
+type traceFrame struct {
+	Record        string   `json:"record"` // "frame"
+	Frame         int64    `json:"frame"`
+	OffsetMs      float64  `json:"offset_ms"`
+	Probability   float32  `json:"probability"`
+	Smoothed      *float32 `json:"smoothed_probability,omitempty"` // with smoothing_frames
+	Threshold     *float32 `json:"threshold,omitempty"`            // with adaptive_threshold, after the frame
+	IsSpeech      bool     `json:"is_speech"`
+	SpeechFrames  int      `json:"speech_frames"`
+	SilenceFrames int      `json:"silence_frames"`
+	InSpeech      bool     `json:"in_speech"`
+	Events        []string `json:"events,omitempty"`
+}
+
+// traceConfig records a mid-stream config_json update: the detector
+// parameters in effect from Frame on.
+type traceConfig struct {
+	Record           string  `json:"record"` // "config"
+	Frame            int64   `json:"frame"`
+	Threshold        float64 `json:"threshold"`
+	NegThreshold     float64 `json:"neg_threshold"`
+	MinSpeechFrames  int     `json:"min_speech_frames"`
+	MinSilenceFrames int     `json:"min_silence_frames"`
+	MaxSpeechFrames  int     `json:"max_speech_frames,omitempty"`
+	MinGapFrames     int     `json:"min_gap_frames,omitempty"`
+	SmoothingFrames  int     `json:"smoothing_frames,omitempty"`
+	SmoothingMethod  string  `json:"smoothing_method,omitempty"`
+
+	AdaptiveSilence   *config.AdaptiveSilence   `json:"adaptive_silence,omitempty"`
+	AdaptiveThreshold *config.AdaptiveThreshold `json:"adaptive_threshold,omitempty"`
+}
+
+// traceReset records a stream reset: the engine and boundary detector start
+// over at Frame.
+type traceReset struct {
+	Record string `json:"record"` // "reset"
+	Frame  int64  `json:"frame"`
+}
+
+// traceClose is the last record: totals and how the stream ended.
+type traceClose struct {
+	Record string   `json:"record"` // "close"
+	Time   string   `json:"time"`
+	Frames int64    `json:"frames"`
+	Events []string `json:"events,omitempty"` // events emitted at close (EOF flush)
+	Error  string   `json:"error,omitempty"`
+}
+
+// frameTracer writes a per-frame NDJSON trace of one stream. Write failures
+// disable the tracer for the rest of the stream; tracing never fails a stream.
+type frameTracer struct {
+	path   string
+	f      *os.File
+	w      *bufio.Writer
+	enc    *json.Encoder
+	log    *slog.Logger
+	failed bool
+	frames int64
+}
+
+// traceSelected reports whether a stream matches trace_stream_id, which may
+// name either its stream ID or its session ID.
+func traceSelected(cfg config.Config, sessionID, streamID string) bool {
+	id := cfg.TraceStreamID
+	return cfg.TraceDir != "" && id != "" && (id == streamID || id == sessionID)
+}
+
+// newFrameTracer creates the trace file in dir and writes the open record.
+func newFrameTracer(dir string, open traceOpen, logger *slog.Logger) (*frameTracer, error) {
+	return createFrameTracer(filepath.Join(dir, traceFileBase(open.StreamID, open.SessionID)+".ndjson"), open, logger)
+}
+
+// createFrameTracer creates the trace file at path and writes the open
+// record.
+func createFrameTracer(path string, open traceOpen, logger *slog.Logger) (*frameTracer, error) {
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0o600)
+	if err != nil {
+		return nil, err
+	}
+	w := bufio.NewWriter(f)
+	t := &frameTracer{path: path, f: f, w: w, enc: json.NewEncoder(w), log: logger}
+	open.Record = "open"
+	open.Time = time.Now().UTC().Format(time.RFC3339Nano)
+	t.write(open)
+	return t, nil
+}
+
+// traceFileBase returns the name, without extension, of a stream's trace or
+// dump files: its ID and the creation time, so reopened IDs do not collide.
+func traceFileBase(streamID, sessionID string) string {
+	return fmt.Sprintf("%s-%d", traceFileID(streamID, sessionID), time.Now().UnixNano())
+}
+
+// traceFileID turns a client-supplied ID into a safe file name component.
+func traceFileID(streamID, sessionID string) string {
+	id := streamID
+	if id == "" {
+		id = sessionID
+	}
+	id = strings.Map(func(r rune) rune {
+		switch {
+		case r >= 'a' && r <= 'z', r >= 'A' && r <= 'Z', r >= '0' && r <= '9', r == '-', r == '_', r == '.':
+			return r
+		default:
+			return '_'
+		}
+	}, id)
+	if len(id) > 64 {
+		id = id[:64]
+	}
+	if id == "" || strings.Trim(id, ".") == "" {
+		id = "stream"
+	}
+	return id
+}
+
+func (t *frameTracer) write(v any) {
+	if t.failed {
+		return
+	}
+	if err := t.enc.Encode(v); err != nil {
+		t.failed = true
+		t.log.Warn("frame trace write failed, tracing disabled for this stream", "path", t.path, "error", err)
+	}
+}
+
+// frame records one inferred frame after bd has processed it.
+func (t *frameTracer) frame(index int64, clock frameClock, r engine.Result, bd *boundaryDetector, events []*napv1.SpeechEvent) {
+	t.frames++
+	rec := traceFrame{
+		Record:        "frame",
+		Frame:         index,
+		OffsetMs:      durationMs(clock.offset(index)),
+		Probability:   r.Confidence,
+		IsSpeech:      bd.speech,
+		SpeechFrames:  bd.speechFrames,
+		SilenceFrames: bd.silenceFrames,
+		InSpeech:      bd.inSpeech,
+		Events:        eventNames(events),
+	}
+	if bd.smooth != nil {
+		smoothed := bd.probability
+		rec.Smoothed = &smoothed
+	}
+	if bd.noise != nil {
+		threshold := bd.threshold
+		rec.Threshold = &threshold
+	}
+	t.write(rec)
+}
+
+// reconfigured records that cfg applies from frame index on.
+func (t *frameTracer) reconfigured(index int64, cfg config.Config, bd *boundaryDetector) {
+	t.write(traceConfig{
+		Record:            "config",
+		Frame:             index,
+		Threshold:         cfg.Threshold,
+		NegThreshold:      cfg.EffectiveNegThreshold(),
+		MinSpeechFrames:   bd.minSpeechFrames,
+		MinSilenceFrames:  bd.minSilenceFrames,
+		MaxSpeechFrames:   bd.maxSpeechFrames,
+		MinGapFrames:      bd.gapFrames,
+		SmoothingFrames:   cfg.SmoothingFrames,
+		SmoothingMethod:   cfg.SmoothingMethod,
+		AdaptiveSilence:   adaptiveSilenceTrace(cfg.AdaptiveSilence),
+		AdaptiveThreshold: adaptiveThresholdTrace(cfg.AdaptiveThreshold),
+	})
+}
+
+// reset records a stream reset before frame index.
+func (t *frameTracer) reset(index int64) {
+	t.write(traceReset{Record: "reset", Frame: index})
+}
+
+// close writes the close record and flushes the file. streamErr is the
+// error the stream ended with, if any.
+func (t *frameTracer) close(finalEvents []*napv1.SpeechEvent, streamErr error) {
+	rec := traceClose{
+		Record: "close",
+		Time:   time.Now().UTC().Format(time.RFC3339Nano),
+		Frames: t.frames,
+		Events: eventNames(finalEvents),
+	}
+	if streamErr != nil {
+		rec.Error = streamErr.Error()
+	}
+	t.write(rec)
+	if err := t.w.Flush(); err != nil && !t.failed {
+		t.log.Warn("frame trace flush failed", "path", t.path, "error", err)
+	}
+	if err := t.f.Close(); err != nil {
+		t.log.Warn("frame trace close failed", "path", t.path, "error", err)
+	}
+	t.log.Info("frame trace written", "path", t.path, "frames", t.frames)
+}
+
+// durationMs renders d in fractional milliseconds.
+func durationMs(d time.Duration) float64 {
+	return float64(d) / float64(time.Millisecond)
+}
+
+func eventNames(events []*napv1.SpeechEvent) []string {
+	if len(events) == 0 {
+		return nil
+	}
+	names := make([]string, len(events))
+	for i, evt := range events {
+		names[i] = strings.TrimPrefix(evt.GetType().String(), "SPEECH_EVENT_TYPE_")
+	}
+	return names
+}
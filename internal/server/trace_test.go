@@ -0,0 +1,136 @@
+package server
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+
+	napv1 "github.com/nupi-ai/nupi/api/nap/v1"
+
+	"github.com/nupi-ai/plugin-vad-local-silero/internal/config"
+	"github.com/nupi-ai/plugin-vad-local-silero/internal/engine"
+)
+
+// runTracedStream sends chunks 20ms stub frames on a stream with the given
+// stream ID and drains the events.
+func runTracedStream(t *testing.T, client napv1.VoiceActivityDetectionServiceClient, streamID string, chunks int) {
+	t.Helper()
+	stream, err := client.DetectSpeech(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < chunks; i++ {
+		if err := stream.Send(&napv1.DetectSpeechRequest{
+			StreamId: streamID,
+			Format:   &napv1.AudioFormat{SampleRate: 16000},
+			PcmData:  make([]byte, 640),
+		}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	stream.CloseSend()
+	for {
+		if _, err := stream.Recv(); err == io.EOF {
+			return
+		} else if err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestFrameTrace(t *testing.T) {
+	dir := t.TempDir()
+	cfg := config.Config{
+		Threshold:            0.5,
+		MinSpeechDurationMs:  20,
+		MinSilenceDurationMs: 20,
+		TraceDir:             dir,
+		TraceStreamID:        "trace-me",
+	}
+	client, cleanup := startTestServer(t, cfg)
+	defer cleanup()
+
+	// Silence, then speech cut off by EOF: the END is flushed at close.
+	chunks := engine.StubToggleInterval + 10
+	runTracedStream(t, client, "other", chunks)
+	// The handler closes the trace before returning, so the file is complete
+	// once the client has seen EOF.
+	runTracedStream(t, client, "trace-me", chunks)
+
+	files, err := filepath.Glob(filepath.Join(dir, "*.ndjson"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(files) != 1 {
+		t.Fatalf("got %d trace files, want 1 (only the selected stream): %v", len(files), files)
+	}
+	if base := filepath.Base(files[0]); base[:len("trace-me-")] != "trace-me-" {
+		t.Errorf("trace file %q should be named after the stream ID", base)
+	}
+
+	f, err := os.Open(files[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+
+	var records []map[string]any
+	sc := bufio.NewScanner(f)
+	for sc.Scan() {
+		var rec map[string]any
+		if err := json.Unmarshal(sc.Bytes(), &rec); err != nil {
+			t.Fatalf("invalid NDJSON line %q: %v", sc.Text(), err)
+		}
+		records = append(records, rec)
+	}
+	if len(records) != chunks+2 {
+		t.Fatalf("got %d records, want open + %d frames + close", len(records), chunks)
+	}
+
+	open := records[0]
+	if open["record"] != "open" || open["stream_id"] != "trace-me" || open["frame_duration_ms"] != float64(20) {
+		t.Errorf("unexpected open record: %v", open)
+	}
+
+	// The stub switches to speech on frame 49 (0-based); with a 1-frame
+	// minimum the START is emitted on that frame.
+	start := records[1+engine.StubToggleInterval-1]
+	if start["record"] != "frame" || start["is_speech"] != true || start["in_speech"] != true {
+		t.Errorf("unexpected frame record at speech onset: %v", start)
+	}
+	if evts, _ := start["events"].([]any); len(evts) != 1 || evts[0] != "START" {
+		t.Errorf("onset frame events = %v, want [START]", start["events"])
+	}
+	if before := records[engine.StubToggleInterval-1]; before["events"] != nil || before["silence_frames"] != float64(engine.StubToggleInterval-1) {
+		t.Errorf("unexpected frame record before onset: %v", before)
+	}
+
+	closeRec := records[len(records)-1]
+	if closeRec["record"] != "close" || closeRec["frames"] != float64(chunks) {
+		t.Errorf("unexpected close record: %v", closeRec)
+	}
+	if evts, _ := closeRec["events"].([]any); len(evts) != 1 || evts[0] != "END" {
+		t.Errorf("close events = %v, want [END] flushed at EOF", closeRec["events"])
+	}
+}
+
+func TestTraceFileID(t *testing.T) {
+	tests := []struct {
+		streamID, sessionID, want string
+	}{
+		{"abc-1", "sess", "abc-1"},
+		{"", "sess", "sess"},
+		{"../../etc/passwd", "", ".._.._etc_passwd"},
+		{"..", "", "stream"},
+		{"", "", "stream"},
+	}
+	for _, tt := range tests {
+		if got := traceFileID(tt.streamID, tt.sessionID); got != tt.want {
+			t.Errorf("traceFileID(%q, %q) = %q, want %q", tt.streamID, tt.sessionID, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,169 @@
+package server
+
+import (
+	"context"
+	"runtime"
+	"time"
+
+	napv1 "github.com/nupi-ai/nupi/api/nap/v1"
+
+	"github.com/nupi-ai/plugin-vad-local-silero/internal/metrics"
+)
+
+// Stream operations timed by the watchdog.
+const (
+	opNone int32 = iota
+	opRecv
+	opSend
+)
+
+var opNames = [...]string{opNone: "none", opRecv: "recv", opSend: "send"}
+
+// begin records that the stream started waiting on op at now.
+func (h *streamHandle) begin(op int32, now time.Time) {
+	h.since.Store(now.UnixNano())
+	h.op.Store(op)
+}
+
+// end records that the stream is no longer waiting.
+func (h *streamHandle) end() {
+	h.op.Store(opNone)
+}
+
+// blocked returns the operation the stream waits on and for how long at now.
+func (h *streamHandle) blocked(now time.Time) (op int32, d time.Duration) {
+	op = h.op.Load()
+	if op == opNone {
+		return opNone, 0
+	}
+	return op, now.Sub(time.Unix(0, h.since.Load()))
+}
+
+// watchdogMetrics counts watchdog terminations per blocked operation.
+type watchdogMetrics struct {
+	recv *metrics.Counter
+	send *metrics.Counter
+}
+
+func newWatchdogMetrics(reg *metrics.Registry) *watchdogMetrics {
+	return &watchdogMetrics{
+		recv: reg.Counter("vad_watchdog_terminations_total", "op", opNames[opRecv]),
+		send: reg.Counter("vad_watchdog_terminations_total", "op", opNames[opSend]),
+	}
+}
+
+// RunWatchdog terminates stuck streams until ctx is cancelled, checking
+// several times per stuck_stream_timeout_s. It is a no-op unless the
+// watchdog is enabled in the config.
+func (s *Server) RunWatchdog(ctx context.Context) {
+	if s.watchdog == nil {
+		return
+	}
+	timeout := time.Duration(s.cfg.StuckStreamTimeoutSec) * time.Second
+	ticker := time.NewTicker(max(timeout/4, 100*time.Millisecond))
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case now := <-ticker.C:
+			s.terminateStuckStreams(now, timeout)
+		}
+	}
+}
+
+// terminateStuckStreams ends every stream blocked in Recv or Send for longer
+// than timeout at now and returns how many it ended. A stream waiting for
+// requests ends with IDLE_TIMEOUT, one whose client stopped reading with
+// SEND_STALLED. The handler returns at once either way, which cancels the
+// stream's context, unblocks its gRPC calls and frees its engine.
+func (s *Server) terminateStuckStreams(now time.Time, timeout time.Duration) int {
+	type stuck struct {
+		h  *streamHandle
+		op int32
+		d  time.Duration
+	}
+	var found []stuck
+	s.streamsMu.Lock()
+	for h := range s.streams {
+		if op, d := h.blocked(now); op != opNone && d > timeout {
+			found = append(found, stuck{h, op, d})
+		}
+	}
+	active := len(s.streams)
+	s.streamsMu.Unlock()
+
+	for _, st := range found {
+		var ids streamIDs
+		if p := st.h.ids.Load(); p != nil {
+			ids = *p
+		}
+		s.log.Warn("watchdog terminating stuck stream",
+			"session_id", ids.session,
+			"stream_id", ids.stream,
+			"blocked_in", opNames[st.op],
+			"blocked_for", st.d,
+			"active_streams", active,
+			"goroutines", runtime.NumGoroutine(),
+		)
+		if st.op == opSend {
+			s.watchdog.send.Inc()
+			st.h.terminate(TerminationError(ReasonSendStalled, "client stopped reading events, stream terminated"))
+		} else {
+			s.watchdog.recv.Inc()
+			st.h.terminate(TerminationError(ReasonIdleTimeout, "no request received within the stuck-stream timeout, stream terminated"))
+		}
+	}
+	return len(found)
+}
+
+// eventSender sends a stream's events from its own goroutine, so a Send
+// blocked by a client that stopped reading can be abandoned: the handler
+// returns on termination, and gRPC then cancels the stream, which unblocks
+// the Send.
+type eventSender struct {
+	stream napv1.VoiceActivityDetectionService_DetectSpeechServer
+	h      *streamHandle
+	in     chan *napv1.SpeechEvent
+	out    chan error
+}
+
+// newEventSender starts the sending goroutine; it exits once done is
+// closed, and a panic on it ends the stream through g.
+func newEventSender(stream napv1.VoiceActivityDetectionService_DetectSpeechServer, h *streamHandle, g *panicGuard, done <-chan struct{}) *eventSender {
+	e := &eventSender{stream: stream, h: h, in: make(chan *napv1.SpeechEvent), out: make(chan error)}
+	go func() {
+		defer g.recoverTo(h, "event sender")
+		for {
+			select {
+			case evt := <-e.in:
+				h.begin(opSend, time.Now())
+				err := stream.Send(evt)
+				h.end()
+				select {
+				case e.out <- err:
+				case <-done:
+					return
+				}
+			case <-done:
+				return
+			}
+		}
+	}()
+	return e
+}
+
+// Send sends evt and waits for the result, or for the stream's termination.
+func (e *eventSender) Send(evt *napv1.SpeechEvent) error {
+	select {
+	case e.in <- evt:
+	case <-e.h.stop:
+		return e.h.cause
+	}
+	select {
+	case err := <-e.out:
+		return err
+	case <-e.h.stop:
+		return e.h.cause
+	}
+}
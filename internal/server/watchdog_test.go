@@ -0,0 +1,104 @@
+package server
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	napv1 "github.com/nupi-ai/nupi/api/nap/v1"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	"github.com/nupi-ai/plugin-vad-local-silero/internal/config"
+	"github.com/nupi-ai/plugin-vad-local-silero/internal/engine"
+)
+
+func TestWatchdogTerminatesIdleStream(t *testing.T) {
+	srv := New(config.Config{Threshold: 0.5, StuckStreamTimeoutSec: 30}, nil, func() engine.Engine { return engine.NewStubEngine() })
+	client, cleanup := startTestServerWith(t, srv)
+	defer cleanup()
+
+	stream, err := client.DetectSpeech(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := stream.Send(&napv1.DetectSpeechRequest{Format: &napv1.AudioFormat{SampleRate: 16000}, PcmData: make([]byte, 640)}); err != nil {
+		t.Fatal(err)
+	}
+	deadline := time.Now().Add(5 * time.Second)
+	for srv.ActiveStreams() == 0 {
+		if time.Now().After(deadline) {
+			t.Fatal("stream never became active")
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+
+	// Waiting for the next chunk is not stuck until the timeout passes.
+	timeout := 30 * time.Second
+	if n := srv.terminateStuckStreams(time.Now(), timeout); n != 0 {
+		t.Fatalf("terminated %d streams before the timeout", n)
+	}
+	var n int
+	for n == 0 {
+		if time.Now().After(deadline) {
+			t.Fatal("stream never waited for a request")
+		}
+		n = srv.terminateStuckStreams(time.Now().Add(timeout+time.Second), timeout)
+	}
+
+	_, err = stream.Recv()
+	if reason, retryable, ok := TerminationReason(err); !ok || reason != ReasonIdleTimeout || retryable || status.Code(err) != codes.DeadlineExceeded {
+		t.Errorf("Recv error = %v, want DEADLINE_EXCEEDED %s", err, ReasonIdleTimeout)
+	}
+	key := `vad_watchdog_terminations_total{op="recv"}`
+	if got := srv.Metrics().Snapshot()[key]; got != 1 {
+		t.Errorf("%s = %d, want 1", key, got)
+	}
+}
+
+// stalledStream is a DetectSpeech stream whose client never reads: Send
+// blocks until the stream's context is cancelled.
+type stalledStream struct {
+	bufferStream
+	sending chan struct{}
+}
+
+func (s *stalledStream) Send(*napv1.SpeechEvent) error {
+	close(s.sending)
+	<-s.ctx.Done()
+	return s.ctx.Err()
+}
+
+func TestWatchdogTerminatesStalledSend(t *testing.T) {
+	srv := New(config.Config{Threshold: 0.5, MinSpeechDurationMs: 20, MinSilenceDurationMs: 20, StuckStreamTimeoutSec: 30}, nil, func() engine.Engine { return engine.NewStubEngine() })
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel() // plays gRPC, which cancels the stream once the handler returns
+	stream := &stalledStream{
+		bufferStream: bufferStream{ctx: ctx, requests: []*napv1.DetectSpeechRequest{{
+			Format:     &napv1.AudioFormat{SampleRate: 16000},
+			PcmData:    make([]byte, 640),
+			ConfigJson: `{"emit_probabilities": true}`,
+		}}},
+		sending: make(chan struct{}),
+	}
+	result := make(chan error, 1)
+	go func() { result <- srv.DetectSpeech(stream) }()
+
+	select {
+	case <-stream.sending:
+	case err := <-result:
+		t.Fatalf("DetectSpeech returned %v before sending", err)
+	}
+	timeout := 30 * time.Second
+	if n := srv.terminateStuckStreams(time.Now().Add(timeout+time.Second), timeout); n != 1 {
+		t.Fatalf("terminated %d streams, want 1", n)
+	}
+	select {
+	case err := <-result:
+		if reason, _, ok := TerminationReason(err); !ok || reason != ReasonSendStalled {
+			t.Errorf("DetectSpeech error = %v, want %s", err, ReasonSendStalled)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("handler still blocked after termination")
+	}
+}
@@ -0,0 +1,71 @@
+// Package timestamp renders SpeechEvent timestamps for consumers outside
+// gRPC: gateways and command-line output. Downstream systems ingest
+// different representations, so the format is chosen by configuration
+// instead of each consumer re-converting (and rounding) one fixed format.
+package timestamp
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"time"
+)
+
+// Format selects how a timestamp is rendered.
+type Format string
+
+// Supported formats.
+const (
+	// RFC3339 is the absolute time in UTC, RFC 3339 with nanoseconds.
+	RFC3339 Format = "rfc3339"
+	// EpochMillis is the absolute time in milliseconds since the Unix epoch.
+	EpochMillis Format = "epoch_ms"
+	// OffsetMillis is the position in the stream's audio, in milliseconds
+	// since the audio epoch, with microsecond precision.
+	OffsetMillis Format = "offset_ms"
+)
+
+// Formats lists the supported formats, for help and error messages.
+var Formats = []Format{RFC3339, EpochMillis, OffsetMillis}
+
+// Parse returns the Format named s.
+func Parse(s string) (Format, error) {
+	for _, f := range Formats {
+		if Format(s) == f {
+			return f, nil
+		}
+	}
+	return "", fmt.Errorf("timestamp format must be %q, %q or %q, got %q", RFC3339, EpochMillis, OffsetMillis, s)
+}
+
+// Value renders ts for a JSON document: a string for RFC3339, a number for
+// the others. epoch is the stream's audio epoch (server.ParseAudioEpoch),
+// only used by OffsetMillis.
+func (f Format) Value(ts, epoch time.Time) any {
+	switch f {
+	case EpochMillis:
+		return ts.UnixMilli()
+	case OffsetMillis:
+		return offsetMillis(ts, epoch)
+	default:
+		return ts.UTC().Format(time.RFC3339Nano)
+	}
+}
+
+// Render renders ts as text; see Value.
+func (f Format) Render(ts, epoch time.Time) string {
+	switch f {
+	case EpochMillis:
+		return strconv.FormatInt(ts.UnixMilli(), 10)
+	case OffsetMillis:
+		return strconv.FormatFloat(offsetMillis(ts, epoch), 'f', -1, 64)
+	default:
+		return ts.UTC().Format(time.RFC3339Nano)
+	}
+}
+
+// offsetMillis returns ts - epoch in milliseconds, rounded to the
+// microsecond so the float carries no binary noise.
+func offsetMillis(ts, epoch time.Time) float64 {
+	return math.Round(float64(ts.Sub(epoch))/float64(time.Microsecond)) / 1000
+}
@@ -0,0 +1,41 @@
+package timestamp
+
+import (
+	"testing"
+	"time"
+)
+
+func TestFormats(t *testing.T) {
+	loc := time.FixedZone("CEST", 2*3600)
+	epoch := time.Date(2026, 5, 4, 12, 0, 0, 0, loc)
+	ts := epoch.Add(1980*time.Millisecond + 62500*time.Nanosecond)
+
+	tests := []struct {
+		format Format
+		value  any
+		text   string
+	}{
+		{RFC3339, "2026-05-04T10:00:01.9800625Z", "2026-05-04T10:00:01.9800625Z"},
+		{EpochMillis, int64(1777888801980), "1777888801980"},
+		{OffsetMillis, 1980.063, "1980.063"},
+	}
+	for _, tt := range tests {
+		if got := tt.format.Value(ts, epoch); got != tt.value {
+			t.Errorf("%s: Value = %v (%T), want %v (%T)", tt.format, got, got, tt.value, tt.value)
+		}
+		if got := tt.format.Render(ts, epoch); got != tt.text {
+			t.Errorf("%s: Render = %q, want %q", tt.format, got, tt.text)
+		}
+	}
+}
+
+func TestParse(t *testing.T) {
+	for _, f := range Formats {
+		if got, err := Parse(string(f)); err != nil || got != f {
+			t.Errorf("Parse(%q) = %q, %v", f, got, err)
+		}
+	}
+	if _, err := Parse("iso"); err == nil {
+		t.Error("Parse(iso): no error")
+	}
+}
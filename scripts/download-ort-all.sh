@@ -4,7 +4,8 @@
 #
 # Downloads ORT for each target platform inline (with SHA256 verification).
 # Libraries are placed in lib/<os>-<arch>/ directories matching the
-# resolveORTLibPath() convention in ort_lib.go.
+# resolveORTLibPath() convention in ort_lib.go, under their versioned names;
+# together the directories form the fat layout (see make fat).
 #
 # Usage: ./scripts/download-ort-all.sh [version]
 set -euo pipefail
@@ -48,13 +49,12 @@ for entry in "${PLATFORMS[@]}"; do
   read -r ORT_PLATFORM GOOS GOARCH EXT <<< "${entry}"
 
   DEST_DIR="lib/${GOOS}-${GOARCH}"
-  if [ "${GOOS}" = "windows" ]; then
-    DEST_NAME="onnxruntime.dll"
-    ARCHIVE_EXT="zip"
-  else
-    DEST_NAME="libonnxruntime.${EXT}"
-    ARCHIVE_EXT="tgz"
-  fi
+  # Versioned names, as in download-ort.sh.
+  case "${GOOS}" in
+    windows) DEST_NAME="onnxruntime.dll"; ARCHIVE_EXT="zip" ;;
+    darwin)  DEST_NAME="libonnxruntime.${ORT_VERSION}.dylib"; ARCHIVE_EXT="tgz" ;;
+    *)       DEST_NAME="libonnxruntime.so.${ORT_VERSION}"; ARCHIVE_EXT="tgz" ;;
+  esac
 
   # Skip if already downloaded.
   if [ -f "${DEST_DIR}/${DEST_NAME}" ]; then
@@ -81,8 +81,9 @@ fi
 URL="https://github.com/microsoft/onnxruntime/releases/download/v${ORT_VERSION}/onnxruntime-${PLATFORM}-${ORT_VERSION}.${ARCHIVE_EXT}"
 
 # Pinned SHA256 checksums for onnxruntime-<platform>-<version>.{tgz,zip} archives.
-# NOTE: download-ort-all.sh duplicates checksums for its 4 target platforms.
-# When updating, keep both files in sync.
+# NOTE: download-ort-all.sh duplicates checksums for its 4 target platforms, and
+# cmd/adapter/fetch.go (the fetch-runtime subcommand) for all of them.
+# When updating, keep all three in sync.
 get_expected_sha256() {
   case "${PLATFORM}:${ORT_VERSION}" in
     osx-arm64:1.23.0)     echo "8182db0ebb5caa21036a3c78178f17fabb98a7916bdab454467c8f4cf34bcfdf" ;;
@@ -134,7 +135,10 @@ fi
 mkdir -p "${DEST_DIR}"
 
 # Find and copy the shared library from the lib/ subdirectory.
-# Windows uses onnxruntime.dll, others use libonnxruntime.{so,dylib}.
+# Windows uses onnxruntime.dll; others are installed under the versioned
+# name resolveORTLibPath() looks for first (libonnxruntime.so.<version>,
+# libonnxruntime.<version>.dylib), so libraries of several versions can
+# sit side by side.
 # We search in */lib/* to avoid picking up wrong files from other locations.
 #
 # macOS archives may contain versioned dylibs in different naming schemes:
@@ -152,7 +156,11 @@ else
     -name "libonnxruntime.${EXT}.*" -o \
     -name "libonnxruntime.*.${EXT}" \
   \) -type f | head -1)"
-  DEST_NAME="libonnxruntime.${EXT}"
+  if [ "${OS}" = "darwin" ]; then
+    DEST_NAME="libonnxruntime.${ORT_VERSION}.dylib"
+  else
+    DEST_NAME="libonnxruntime.so.${ORT_VERSION}"
+  fi
 fi
 
 if [ -z "${LIB_FILE}" ]; then
@@ -0,0 +1,49 @@
+#!/usr/bin/env bash
+# Quantize the Silero VAD model's weights to int8 for model_precision "int8".
+# Usage: ./scripts/quantize-model.sh [input.onnx] [output.onnx]
+#
+# Uses ONNX Runtime's dynamic quantization (pip install onnxruntime), which
+# stores MatMul/Conv weights as int8 and keeps the model's float32 inputs
+# and outputs, so the engine binds the same tensors. The If branches that
+# select the 8/16 kHz path are quantized too (EnableSubgraph).
+#
+# The output is not bit-for-bit reproducible across onnxruntime versions;
+# its SHA256 is printed so it can be pinned with NUPI_VAD_MODEL_SHA256 when
+# shipped with model_path.
+set -euo pipefail
+
+INPUT="${1:-models/silero_vad.onnx}"
+OUTPUT="${2:-models/silero_vad_int8.onnx}"
+
+if [ ! -f "${INPUT}" ]; then
+  echo "ERROR: ${INPUT} not found. Download it first:"
+  echo "  make download-model"
+  exit 1
+fi
+if ! command -v python3 >/dev/null 2>&1; then
+  echo "ERROR: python3 not found."
+  exit 1
+fi
+if ! python3 -c "import onnxruntime.quantization" 2>/dev/null; then
+  echo "ERROR: the onnxruntime Python package is required: pip install onnxruntime"
+  exit 1
+fi
+
+python3 - "${INPUT}" "${OUTPUT}" <<'PY'
+import sys
+from onnxruntime.quantization import QuantType, quantize_dynamic
+
+quantize_dynamic(
+    sys.argv[1],
+    sys.argv[2],
+    weight_type=QuantType.QInt8,
+    extra_options={"EnableSubgraph": True},
+)
+PY
+
+if command -v shasum >/dev/null 2>&1; then
+  SUM="$(shasum -a 256 "${OUTPUT}" | awk '{print $1}')"
+else
+  SUM="$(sha256sum "${OUTPUT}" | awk '{print $1}')"
+fi
+echo "Quantized ${INPUT} -> ${OUTPUT} ($(wc -c < "${OUTPUT}" | tr -d ' ') bytes, SHA256 ${SUM})"
@@ -0,0 +1,256 @@
+// Command new-engine scaffolds a custom VAD engine in internal/engine:
+//
+//	go run ./tools/new-engine --name foo
+//
+// It writes engine_foo.go, a FooEngine that frames audio and registers
+// itself as engine "foo", and engine_foo_test.go, which runs it through the
+// engine conformance test. Both are compiled in only with the engine_foo
+// build tag, so a fork can carry private engines without touching the
+// default build:
+//
+//	go test -tags engine_foo ./internal/engine
+//	make build ENGINE_TAGS=engine_foo
+//	NUPI_VAD_ENGINE=foo ./vad-local-silero
+package main
+
+import (
+	"bytes"
+	"errors"
+	"flag"
+	"fmt"
+	"go/format"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strings"
+	"text/template"
+)
+
+// validName is the form engine names take: they are used as a config value,
+// a build tag suffix and, capitalized, a Go type name.
+var validName = regexp.MustCompile(`^[a-z][a-z0-9_]*$`)
+
+// reservedNames are the engine names the adapter resolves itself.
+var reservedNames = []string{"silero", "stub", "energy", "auto"}
+
+func main() {
+	os.Exit(run(os.Args[1:], os.Stdout, os.Stderr))
+}
+
+func run(args []string, stdout, stderr io.Writer) int {
+	flags := flag.NewFlagSet("new-engine", flag.ContinueOnError)
+	flags.SetOutput(stderr)
+	name := flags.String("name", "", "engine name, as set in NUPI_VAD_ENGINE (lower case, e.g. foo)")
+	dir := flags.String("dir", filepath.Join("internal", "engine"), "engine package directory")
+	if err := flags.Parse(args); err != nil {
+		return 2
+	}
+	files, err := scaffold(*name)
+	if err == nil {
+		err = writeFiles(*dir, files)
+	}
+	if err != nil {
+		fmt.Fprintf(stderr, "new-engine: %v\n", err)
+		return 1
+	}
+	tag := buildTag(*name)
+	for _, f := range files {
+		fmt.Fprintf(stdout, "created %s\n", filepath.Join(*dir, f.name))
+	}
+	fmt.Fprintf(stdout, "\nImplement %s.score, then:\n", typeName(*name))
+	fmt.Fprintf(stdout, "  go test -tags %s ./internal/engine\n", tag)
+	fmt.Fprintf(stdout, "  make build ENGINE_TAGS=%s\n", tag)
+	fmt.Fprintf(stdout, "  NUPI_VAD_ENGINE=%s ./vad-local-silero\n", *name)
+	return 0
+}
+
+// file is a generated source file.
+type file struct {
+	name string
+	data []byte
+}
+
+// scaffold renders the engine and its conformance test for name.
+func scaffold(name string) ([]file, error) {
+	if !validName.MatchString(name) {
+		return nil, fmt.Errorf("--name must be lower-case letters, digits and underscores, starting with a letter, got %q", name)
+	}
+	if slices.Contains(reservedNames, name) {
+		return nil, fmt.Errorf("--name %q is a built-in engine", name)
+	}
+	data := templateData{Name: name, Type: typeName(name), Tag: buildTag(name)}
+	var files []file
+	for _, t := range []struct {
+		name string
+		tmpl *template.Template
+	}{
+		{"engine_" + name + ".go", engineTemplate},
+		{"engine_" + name + "_test.go", testTemplate},
+	} {
+		var buf bytes.Buffer
+		if err := t.tmpl.Execute(&buf, data); err != nil {
+			return nil, err
+		}
+		src, err := format.Source(buf.Bytes())
+		if err != nil {
+			return nil, fmt.Errorf("format %s: %w", t.name, err)
+		}
+		files = append(files, file{t.name, src})
+	}
+	return files, nil
+}
+
+// writeFiles writes files into dir, refusing to overwrite any.
+func writeFiles(dir string, files []file) error {
+	if _, err := os.Stat(filepath.Join(dir, "engine.go")); err != nil {
+		return fmt.Errorf("%s is not the engine package (run from the repository root or set --dir): %w", dir, err)
+	}
+	for _, f := range files {
+		if _, err := os.Stat(filepath.Join(dir, f.name)); err == nil {
+			return fmt.Errorf("%s already exists", filepath.Join(dir, f.name))
+		} else if !errors.Is(err, os.ErrNotExist) {
+			return err
+		}
+	}
+	for _, f := range files {
+		if err := os.WriteFile(filepath.Join(dir, f.name), f.data, 0o644); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// typeName returns the Go type of engine name: foo_bar is FooBarEngine.
+func typeName(name string) string {
+	var b strings.Builder
+	for _, part := range strings.Split(name, "_") {
+		if part != "" {
+			b.WriteString(strings.ToUpper(part[:1]) + part[1:])
+		}
+	}
+	return b.String() + "Engine"
+}
+
+// buildTag returns the build tag that compiles engine name in.
+func buildTag(name string) string {
+	return "engine_" + name
+}
+
+type templateData struct {
+	Name string // engine name
+	Type string // Go type name
+	Tag  string // build tag
+}
+
+var engineTemplate = template.Must(template.New("engine").Parse(`//go:build {{.Tag}}
+
+package engine
+
+import "github.com/nupi-ai/plugin-vad-local-silero/internal/errs"
+
+func init() {
+	Register("{{.Name}}", func(int) (Engine, error) {
+		return New{{.Type}}(), nil
+	})
+}
+
+// {{.Type}} is the "{{.Name}}" engine, compiled in with the {{.Tag}} build
+// tag. It scores audio in frames of 32 ms.
+//
+// TODO: describe the engine.
+type {{.Type}} struct {
+	buf        *windowBuffer
+	sampleRate uint32
+}
+
+// New{{.Type}} creates a {{.Type}} configured for 16kHz input.
+func New{{.Type}}() *{{.Type}} {
+	e := &{{.Type}}{sampleRate: ExpectedSampleRate}
+	e.buf = newWindowBuffer(e.FrameSamples(), e.FrameSamples())
+	return e
+}
+
+// ProcessChunk returns one Result per frame of pcm, buffering the rest.
+func (e *{{.Type}}) ProcessChunk(pcm []byte, sampleRate uint32) ([]Result, error) {
+	if sampleRate != e.sampleRate {
+		return nil, ErrWrongSampleRate
+	}
+	if len(pcm)%2 != 0 {
+		return nil, errs.New(errs.ErrInvalidPCM, "{{.Name}}: PCM buffer has odd length %d, expected even (s16le requires 2 bytes per sample)", len(pcm))
+	}
+	e.buf.write(pcm)
+	var results []Result
+	for frame := e.buf.next(); frame != nil; frame = e.buf.next() {
+		results = append(results, Result{Confidence: e.score(frame)})
+	}
+	return results, nil
+}
+
+// score returns the speech probability, in [0, 1], of a frame of samples
+// normalized to [-1, 1].
+func (e *{{.Type}}) score(frame []float32) float32 {
+	// TODO: run the engine.
+	return 0
+}
+
+// Reset drops buffered audio and any state carried between frames.
+func (e *{{.Type}}) Reset() error {
+	e.buf.reset()
+	return nil
+}
+
+// Close releases the engine's resources.
+func (e *{{.Type}}) Close() error {
+	return nil
+}
+
+// FrameDurationMs returns the frame duration in milliseconds.
+func (e *{{.Type}}) FrameDurationMs() int {
+	return e.FrameSamples() * 1000 / int(e.sampleRate)
+}
+
+// FrameSamples returns the samples per frame: 32 ms at the configured rate.
+func (e *{{.Type}}) FrameSamples() int {
+	return int(e.sampleRate) * 32 / 1000
+}
+
+// SetSampleRate configures the engine for 16 or 8 kHz input and clears
+// buffered audio.
+func (e *{{.Type}}) SetSampleRate(sampleRate uint32) error {
+	if !SupportedSampleRate(sampleRate) {
+		return ErrWrongSampleRate
+	}
+	e.sampleRate = sampleRate
+	e.buf.configure(e.FrameSamples(), e.FrameSamples())
+	return nil
+}
+
+// SampleRate returns the configured input rate.
+func (e *{{.Type}}) SampleRate() uint32 {
+	return e.sampleRate
+}
+
+// BufferedSamples returns the samples held for the next frame.
+func (e *{{.Type}}) BufferedSamples() int {
+	return e.buf.buffered()
+}
+`))
+
+var testTemplate = template.Must(template.New("test").Parse(`//go:build {{.Tag}}
+
+package engine
+
+import "testing"
+
+func Test{{.Type}}Conformance(t *testing.T) {
+	testConformance(t, func() (Engine, error) {
+		factory, ok := Lookup("{{.Name}}")
+		if !ok {
+			t.Fatal("engine {{.Name}} is not registered")
+		}
+		return factory(DefaultHopMs)
+	})
+}
+`))
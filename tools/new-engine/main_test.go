@@ -0,0 +1,54 @@
+package main
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestRun(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "engine.go"), []byte("package engine\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	var stdout, stderr bytes.Buffer
+	if code := run([]string{"--name", "my_dsp", "--dir", dir}, &stdout, &stderr); code != 0 {
+		t.Fatalf("exit %d: %s", code, stderr.String())
+	}
+	src, err := os.ReadFile(filepath.Join(dir, "engine_my_dsp.go"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{"//go:build engine_my_dsp", `Register("my_dsp"`, "type MyDspEngine struct"} {
+		if !bytes.Contains(src, []byte(want)) {
+			t.Errorf("engine_my_dsp.go lacks %q", want)
+		}
+	}
+	test, err := os.ReadFile(filepath.Join(dir, "engine_my_dsp_test.go"))
+	if err != nil || !bytes.Contains(test, []byte("func TestMyDspEngineConformance(")) {
+		t.Errorf("engine_my_dsp_test.go does not run the conformance test (err %v)", err)
+	}
+	if !strings.Contains(stdout.String(), "go test -tags engine_my_dsp ./internal/engine") {
+		t.Errorf("output does not say how to test the engine:\n%s", stdout.String())
+	}
+
+	// Existing files are never overwritten.
+	stderr.Reset()
+	if code := run([]string{"--name", "my_dsp", "--dir", dir}, &stdout, &stderr); code == 0 || !strings.Contains(stderr.String(), "already exists") {
+		t.Errorf("second run: exit %d, %q; want an already exists error", code, stderr.String())
+	}
+	// Nor is anything written outside the engine package.
+	if code := run([]string{"--name", "other", "--dir", t.TempDir()}, &stdout, &stderr); code == 0 {
+		t.Error("directory without engine.go: exit 0")
+	}
+}
+
+func TestScaffoldRejectsNames(t *testing.T) {
+	for _, name := range []string{"", "Foo", "1dsp", "my-dsp", "silero", "stub", "auto"} {
+		if _, err := scaffold(name); err == nil {
+			t.Errorf("scaffold(%q): no error", name)
+		}
+	}
+}